@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+// handleAnalyzeCommand checks the tool calls of a Claude Code transcript
+// against the current rules, without running them, and reports the calls
+// that would have been blocked or held for approval.
+func handleAnalyzeCommand(args []string) {
+	fs := cmd.NewFlagSet("analyze", "[FLAGS] TRANSCRIPT.jsonl", "Report which calls of a Claude Code transcript the current rules would block")
+	var dbPath string
+	var all bool
+	fs.StringVar(&dbPath, "db", "ARMOUR_RULES_DB", server.DefaultRulesDBPath(), "Rules database path")
+	fs.BoolVar(&all, "all", "", false, "List allowed calls too")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy analyze [FLAGS] TRANSCRIPT.jsonl")
+		os.Exit(2)
+	}
+
+	calls, err := server.LoadTranscript(fs.Arg(0))
+	if err != nil {
+		exitWithError("analyze", err)
+	}
+	rs, err := server.NewRulesServer(server.RulesServerConfig{
+		DBPath: dbPath,
+		APIKey: os.Getenv("ANTHROPIC_API_KEY"),
+	})
+	if err != nil {
+		exitWithError("analyze", err)
+	}
+	report := rs.AnalyzeTranscript(context.Background(), calls)
+
+	if jsonOutput {
+		emitJSON("analyze", report)
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "LINE\tTOOL\tDECISION\tREASON\tINPUT")
+		for _, v := range report.Verdicts {
+			if v.Decision == "allow" && !all {
+				continue
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", v.Line, v.Tool, v.Decision, orNone(v.Reason), transcriptInput(v.Input))
+		}
+		tw.Flush()
+		fmt.Printf("\n%d calls: %d would have been blocked, %d held for approval, %d allowed\n", report.Calls, report.Blocked, report.Asked, report.Allowed)
+	}
+	if report.Blocked+report.Asked > 0 {
+		os.Exit(2)
+	}
+}
+
+// transcriptInput shortens a call's input to fit a table row.
+func transcriptInput(input map[string]interface{}) string {
+	data, _ := json.Marshal(input)
+	text := []rune(string(data))
+	if len(text) > 60 {
+		return string(text[:59]) + "…"
+	}
+	return string(text)
+}
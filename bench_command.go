@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+// benchComparison is a result next to the same benchmark in a baseline run.
+type benchComparison struct {
+	server.BenchResult
+	BaselineNsPerOp int64   `json:"baseline_ns_per_op,omitempty"`
+	ChangePct       float64 `json:"change_pct,omitempty"`
+	Regression      bool    `json:"regression,omitempty"`
+}
+
+// handleBenchCommand measures the proxy's per-call overhead on the tools/call
+// hot path at several rule counts and, with -baseline, compares it with an
+// earlier run saved from 'mcp-proxy bench -json'.
+func handleBenchCommand(args []string) {
+	fs := cmd.NewFlagSet("bench", "[-rules N,N,...] [-baseline FILE] [-threshold PCT] [-json]", "Benchmark the proxy's per-call overhead against the built-in demo server")
+	var rules, baseline string
+	var threshold int
+	fs.StringVar(&rules, "rules", "", joinInts(server.BenchRuleCounts), "Comma-separated numbers of blocklist rules to measure with")
+	fs.StringVar(&baseline, "baseline", "", "", "Compare with results saved from 'mcp-proxy bench -json'")
+	fs.IntVar(&threshold, "threshold", "", 20, "Percent slowdown from -baseline reported as a regression")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	counts, err := parseInts(rules)
+	if err != nil {
+		exitWithError("bench", fmt.Errorf("invalid -rules: %w", err))
+	}
+	var previous []server.BenchResult
+	if baseline != "" {
+		if previous, err = loadBenchBaseline(baseline); err != nil {
+			exitWithError("bench", err)
+		}
+	}
+
+	if !jsonOutput {
+		fmt.Fprintln(os.Stderr, "Benchmarking, this takes about a second per line...")
+	}
+	results, err := server.RunBenchmarks(counts)
+	if err != nil {
+		exitWithError("bench", err)
+	}
+	compared := compareBench(results, previous, float64(threshold))
+	regressions := 0
+	for _, c := range compared {
+		if c.Regression {
+			regressions++
+		}
+	}
+
+	if jsonOutput {
+		emitJSON("bench", map[string]interface{}{"results": compared, "regressions": regressions})
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "BENCHMARK\tRULES\tNS/OP\tALLOCS/OP\tBYTES/OP\tCHANGE")
+		for _, c := range compared {
+			change := "-"
+			if c.BaselineNsPerOp > 0 {
+				change = fmt.Sprintf("%+.1f%%", c.ChangePct)
+				if c.Regression {
+					change += " REGRESSION"
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", c.Name, c.Rules, c.NsPerOp, c.AllocsPerOp, c.BytesPerOp, change)
+		}
+		tw.Flush()
+	}
+	if regressions > 0 {
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "bench: %d benchmark(s) more than %d%% slower than %s\n", regressions, threshold, baseline)
+		}
+		os.Exit(1)
+	}
+}
+
+// loadBenchBaseline reads the results of an earlier 'mcp-proxy bench -json'.
+func loadBenchBaseline(path string) ([]server.BenchResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var env struct {
+		Data struct {
+			Results []server.BenchResult `json:"results"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &env); err != nil || len(env.Data.Results) == 0 {
+		return nil, fmt.Errorf("%s is not the output of 'mcp-proxy bench -json'", path)
+	}
+	return env.Data.Results, nil
+}
+
+// compareBench pairs results with the baseline's and flags those slower by
+// more than threshold percent.
+func compareBench(results, baseline []server.BenchResult, threshold float64) []benchComparison {
+	type key struct {
+		name  string
+		rules int
+	}
+	before := make(map[key]int64, len(baseline))
+	for _, r := range baseline {
+		before[key{r.Name, r.Rules}] = r.NsPerOp
+	}
+	compared := make([]benchComparison, len(results))
+	for i, r := range results {
+		compared[i].BenchResult = r
+		if ns := before[key{r.Name, r.Rules}]; ns > 0 {
+			compared[i].BaselineNsPerOp = ns
+			compared[i].ChangePct = float64(r.NsPerOp-ns) / float64(ns) * 100
+			compared[i].Regression = compared[i].ChangePct > threshold
+		}
+	}
+	return compared
+}
+
+func parseInts(s string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q is not a non-negative number", field)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+func joinInts(values []int) string {
+	fields := make([]string, len(values))
+	for i, n := range values {
+		fields[i] = strconv.Itoa(n)
+	}
+	return strings.Join(fields, ",")
+}
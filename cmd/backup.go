@@ -17,6 +17,12 @@ type BackupData struct {
 	ProjectConfigs  map[string]interface{} `json:"project_configs"`
 }
 
+// BackupPath returns the location of the backup file (~/.armour/backup.json).
+func BackupPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".armour", "backup.json")
+}
+
 // CreateBackup saves all MCP configurations to ~/.armour/backup.json
 func CreateBackup() error {
 	homeDir, err := os.UserHomeDir()
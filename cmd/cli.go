@@ -1,17 +1,61 @@
 package cmd
 
 import (
-	"flag"
+	"fmt"
 	"os"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
 )
 
 type CLIArgs struct {
-	ListenAddr string
-	Mode       string
-	LogLevel   string
-	DBPath     string
-	ConfigPath string
-	Origins    string
+	ListenAddr     string
+	Mode           string
+	LogLevel       string
+	DBPath         string
+	ConfigPath     string
+	Origins        string
+	AllowedHosts   string
+	JSON           bool
+	NoUpdateCheck  bool
+	Record         string
+	MaxMessageSize int
+
+	Workers            int
+	SessionConcurrency int
+	QueueSize          int
+	PingInterval       time.Duration
+
+	TLSCert               string
+	TLSKey                string
+	TLSClientCA           string
+	TLSClientFingerprints string
+	Tenants               string
+	NoCompression         bool
+	IdleTimeout           time.Duration
+	H2C                   bool
+
+	DashboardAdminToken  string
+	DashboardViewerToken string
+
+	ManageListen                string
+	ManageToken                 string
+	ManageTLSCert               string
+	ManageTLSKey                string
+	ManageTLSClientCA           string
+	ManageTLSClientFingerprints string
+
+	PolicyPlugins string
+	OPAURL        string
+
+	AuditAnchor         string
+	AuditAnchorInterval time.Duration
+	Retention           time.Duration
+	WithDemoServer      bool
+	Chaos               bool
+	TraceSample         string
+	TracePersist        bool
+	TraceRetention      time.Duration
 }
 
 func ParseArgs() CLIArgs {
@@ -19,21 +63,73 @@ func ParseArgs() CLIArgs {
 }
 
 func ParseArgsWithArgs(args []string) CLIArgs {
-	cliArgs := CLIArgs{}
+	cliArgs, _, _ := ParseGlobalArgs(args)
+	return cliArgs
+}
 
-	fs := flag.NewFlagSet("mcp-proxy", flag.ContinueOnError)
-	fs.StringVar(&cliArgs.ListenAddr, "listen", ":8080", "HTTP listen address")
-	fs.StringVar(&cliArgs.Mode, "mode", "http", "Proxy mode: http or stdio")
-	fs.StringVar(&cliArgs.LogLevel, "log-level", "info", "Log level: debug, info, warn, error")
-	fs.StringVar(&cliArgs.DBPath, "db", "", "SQLite database path (default: in-memory)")
-	fs.StringVar(&cliArgs.ConfigPath, "config", "", "Server registry config JSON file")
-	fs.StringVar(&cliArgs.Origins, "origins", "", "Comma-separated allowed origins")
+// ParseGlobalArgs parses the top-level flags that may precede a subcommand and
+// returns the remaining arguments (subcommand name first, if any).
+func ParseGlobalArgs(args []string) (CLIArgs, []string, error) {
+	cliArgs := CLIArgs{}
 
-	fs.Parse(args)
+	fs := GlobalFlagSet(&cliArgs)
+	fs.SetOutput(os.Stderr)
+	// The caller prints the full help text; only report the parse error here
+	fs.Usage = func() {}
+	err := fs.FlagSet.Parse(args)
+	if err == nil {
+		// The flag package reports syntax errors itself, but not these
+		if err = fs.resolve(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+	}
 
-	return cliArgs
+	return cliArgs, fs.Args(), err
 }
 
-func printUsage() {
-	flag.Usage()
+// GlobalFlagSet defines the proxy-wide flags on a new flag set bound to cliArgs.
+func GlobalFlagSet(cliArgs *CLIArgs) *FlagSet {
+	fs := NewFlagSet("mcp-proxy", "[FLAGS] [COMMAND]", "")
+	fs.StringVar(&cliArgs.ListenAddr, "listen", "ARMOUR_LISTEN", ":8080", "HTTP listen address")
+	fs.StringVar(&cliArgs.Mode, "mode", "ARMOUR_MODE", "http", "Proxy mode: http or stdio")
+	fs.StringVar(&cliArgs.LogLevel, "log-level", "ARMOUR_LOG_LEVEL", "info", "Log level: debug, info, warn, error")
+	fs.StringVar(&cliArgs.DBPath, "db", "ARMOUR_DB", "", "SQLite database path (default: in-memory)")
+	fs.StringVar(&cliArgs.ConfigPath, "config", "ARMOUR_CONFIG", "", "Server registry config JSON file")
+	fs.StringVar(&cliArgs.Origins, "origins", "ARMOUR_ORIGINS", "", "Comma-separated browser origins allowed besides localhost (* for any)")
+	fs.StringVar(&cliArgs.AllowedHosts, "allowed-hosts", "ARMOUR_ALLOWED_HOSTS", "", "Comma-separated Host headers accepted by localhost-bound servers (* disables the check)")
+	fs.BoolVar(&cliArgs.JSON, "json", "ARMOUR_JSON", false, "Emit machine-readable JSON from subcommands")
+	fs.BoolVar(&cliArgs.NoUpdateCheck, "no-update-check", "ARMOUR_NO_UPDATE_CHECK", false, "Don't check for newer releases at startup")
+	fs.StringVar(&cliArgs.Record, "record", "ARMOUR_RECORD", "", "Record the stdio session's JSON-RPC traffic to this JSONL file")
+	fs.ByteSizeVar(&cliArgs.MaxMessageSize, "max-message-size", "ARMOUR_MAX_MESSAGE_SIZE", proxy.DefaultMaxMessageSize, "Largest JSON-RPC message accepted from clients, e.g. 4MB")
+	fs.IntVar(&cliArgs.Workers, "workers", "ARMOUR_WORKERS", 16, "Requests handled at once")
+	fs.IntVar(&cliArgs.SessionConcurrency, "session-concurrency", "ARMOUR_SESSION_CONCURRENCY", 8, "Requests handled at once per session")
+	fs.IntVar(&cliArgs.QueueSize, "queue-size", "ARMOUR_QUEUE_SIZE", 256, "Requests allowed to wait for a worker before new ones are rejected")
+	fs.DurationVar(&cliArgs.PingInterval, "ping-interval", "ARMOUR_PING_INTERVAL", 30*time.Second, "How often to ping backends; missed pings mark them unhealthy (0 disables)")
+	fs.StringVar(&cliArgs.TLSCert, "tls-cert", "ARMOUR_TLS_CERT", "", "TLS certificate for the HTTP listener")
+	fs.StringVar(&cliArgs.TLSKey, "tls-key", "ARMOUR_TLS_KEY", "", "TLS private key for the HTTP listener")
+	fs.StringVar(&cliArgs.TLSClientCA, "tls-client-ca", "ARMOUR_TLS_CLIENT_CA", "", "Require client certificates signed by this CA bundle")
+	fs.StringVar(&cliArgs.TLSClientFingerprints, "tls-client-fingerprints", "ARMOUR_TLS_CLIENT_FINGERPRINTS", "", "Comma-separated SHA-256 fingerprints of allowed client certificates")
+	fs.BoolVar(&cliArgs.NoCompression, "no-compression", "ARMOUR_NO_COMPRESSION", false, "Don't compress HTTP responses for clients that accept it")
+	fs.DurationVar(&cliArgs.IdleTimeout, "idle-timeout", "ARMOUR_IDLE_TIMEOUT", 2*time.Minute, "How long idle keep-alive connections to the HTTP listener stay open")
+	fs.BoolVar(&cliArgs.H2C, "h2c", "ARMOUR_H2C", false, "Also serve HTTP/2 without TLS to clients that speak it directly")
+	fs.StringVar(&cliArgs.Tenants, "tenants", "ARMOUR_TENANTS", "", "Tenants file mapping tenants to server registries and policies (HTTP mode)")
+	fs.StringVar(&cliArgs.DashboardAdminToken, "dashboard-admin-token", "ARMOUR_DASHBOARD_ADMIN_TOKEN", "", "Require this token to change rules, servers and policy on the dashboard")
+	fs.StringVar(&cliArgs.DashboardViewerToken, "dashboard-viewer-token", "ARMOUR_DASHBOARD_VIEWER_TOKEN", "", "Token that may only read the dashboard (needs -dashboard-admin-token)")
+	fs.StringVar(&cliArgs.ManageListen, "manage-listen", "ARMOUR_MANAGE_LISTEN", "", "Serve the management API for a fleet controller on this address (stdio mode)")
+	fs.StringVar(&cliArgs.ManageToken, "manage-token", "ARMOUR_MANAGE_TOKEN", "", "Bearer token the fleet controller must send")
+	fs.StringVar(&cliArgs.ManageTLSCert, "manage-tls-cert", "ARMOUR_MANAGE_TLS_CERT", "", "TLS certificate for the management API")
+	fs.StringVar(&cliArgs.ManageTLSKey, "manage-tls-key", "ARMOUR_MANAGE_TLS_KEY", "", "TLS private key for the management API")
+	fs.StringVar(&cliArgs.ManageTLSClientCA, "manage-tls-client-ca", "ARMOUR_MANAGE_TLS_CLIENT_CA", "", "Require controller certificates signed by this CA bundle")
+	fs.StringVar(&cliArgs.ManageTLSClientFingerprints, "manage-tls-client-fingerprints", "ARMOUR_MANAGE_TLS_CLIENT_FINGERPRINTS", "", "Comma-separated SHA-256 fingerprints of allowed controller certificates")
+	fs.StringVar(&cliArgs.PolicyPlugins, "policy-plugins", "ARMOUR_POLICY_PLUGINS", "", "Comma-separated executables or .wasm modules that check every tool call after the built-in policy")
+	fs.StringVar(&cliArgs.OPAURL, "opa-url", "ARMOUR_OPA_URL", "", "Data API URL of an OPA decision every tool call is checked against, e.g. http://127.0.0.1:8181/v1/data/armour/decision")
+	fs.StringVar(&cliArgs.AuditAnchor, "audit-anchor", "ARMOUR_AUDIT_ANCHOR", "", "Append the head of the audit log's hash chain to this file periodically")
+	fs.DurationVar(&cliArgs.AuditAnchorInterval, "audit-anchor-interval", "ARMOUR_AUDIT_ANCHOR_INTERVAL", time.Hour, "How often to write the audit anchor")
+	fs.DaysVar(&cliArgs.Retention, "retention", "ARMOUR_RETENTION", 0, "Purge audit entries and traces older than this, e.g. 30d (0 keeps them)")
+	fs.BoolVar(&cliArgs.WithDemoServer, "with-demo-server", "ARMOUR_WITH_DEMO_SERVER", false, "Also serve a built-in demo server with greet, echo, slow and fail tools")
+	fs.BoolVar(&cliArgs.Chaos, "chaos", "ARMOUR_CHAOS", false, "Allow latency and failures to be injected into backends from the dashboard API, for testing (stdio mode)")
+	fs.StringVar(&cliArgs.TraceSample, "trace-sample", "ARMOUR_TRACE_SAMPLE", "always", "Trace events to keep: always, errors, or 1/N for one in N (failures are always kept)")
+	fs.BoolVar(&cliArgs.TracePersist, "trace-persist", "ARMOUR_TRACE_PERSIST", false, "Also write kept trace events to the database")
+	fs.DaysVar(&cliArgs.TraceRetention, "trace-retention", "ARMOUR_TRACE_RETENTION", 7*24*time.Hour, "Purge persisted trace events older than this, e.g. 7d")
+	return fs
 }
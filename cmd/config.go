@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv overrides the path of the config file.
+const ConfigFileEnv = "ARMOUR_CONFIG_FILE"
+
+// Where an option's value came from, in order of precedence.
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// ConfigFile holds option values from the config file, keyed by flag name:
+// proxy-wide options at the top level and a subcommand's options under the
+// subcommand's name, e.g.
+//
+//	mode: stdio
+//	db: ~/.armour/proxy.db
+//	origins: [https://app.example.com]
+//	serve:
+//	  port: 9084
+type ConfigFile struct {
+	Path     string
+	Loaded   bool                         // false if there is no file
+	sections map[string]map[string]string // "" for proxy-wide options
+}
+
+// DefaultConfigFile returns $ARMOUR_CONFIG_FILE or ~/.armour/config.yaml.
+func DefaultConfigFile() string {
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return path
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".armour", "config.yaml")
+}
+
+// LoadConfigFile reads a config file. A missing file is only an error if
+// ARMOUR_CONFIG_FILE names it.
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	cf := &ConfigFile{Path: path, sections: map[string]map[string]string{"": {}}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && os.Getenv(ConfigFileEnv) == "" {
+		return cf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	for key, value := range raw {
+		if section, ok := value.(map[string]interface{}); ok {
+			cf.sections[key] = make(map[string]string)
+			for name, v := range section {
+				s, err := configValue(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid config file %s: %s.%s: %w", path, key, name, err)
+				}
+				cf.sections[key][name] = s
+			}
+			continue
+		}
+		s, err := configValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %s: %w", path, key, err)
+		}
+		cf.sections[""][key] = s
+	}
+	cf.Loaded = true
+	return cf, nil
+}
+
+// configValue turns a YAML value into flag syntax; a list becomes the
+// comma-separated form list flags take.
+func configValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("expected a value, not a section")
+	case string:
+		return expandHome(v), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// expandHome expands a leading ~/ the way a shell would have on the command line.
+func expandHome(s string) string {
+	if rest, ok := strings.CutPrefix(s, "~/"); ok {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, rest)
+		}
+	}
+	return s
+}
+
+// Values returns the options of a section: "" for proxy-wide options, else
+// a subcommand name.
+func (cf *ConfigFile) Values(section string) map[string]string {
+	return cf.sections[section]
+}
+
+// configFile is the file flag sets fall back to after flags and environment.
+var configFile *ConfigFile
+
+// UseConfigFile makes flag sets parsed from now on take options missing from
+// the command line and environment from cf.
+func UseConfigFile(cf *ConfigFile) {
+	configFile = cf
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useTestConfigFile(t *testing.T, content string) *ConfigFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cf, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("failed to load config file: %v", err)
+	}
+	UseConfigFile(cf)
+	t.Cleanup(func() { UseConfigFile(nil) })
+	return cf
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	useTestConfigFile(t, `
+listen: ":9000"
+mode: stdio
+log-level: warn
+origins: [https://a.example, https://b.example]
+ping-interval: 10s
+serve:
+  port: 9999
+`)
+	t.Setenv("ARMOUR_LOG_LEVEL", "debug")
+
+	var args CLIArgs
+	fs := GlobalFlagSet(&args)
+	if err := fs.Parse([]string{"-listen", ":7000"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args.ListenAddr != ":7000" || args.LogLevel != "debug" || args.Mode != "stdio" || args.DBPath != "" {
+		t.Errorf("expected flag > env > file > default, got %+v", args)
+	}
+	if args.Origins != "https://a.example,https://b.example" || args.PingInterval != 10*time.Second {
+		t.Errorf("expected typed values from the file, got origins=%q ping=%v", args.Origins, args.PingInterval)
+	}
+
+	sources := make(map[string]string)
+	for _, s := range fs.Settings() {
+		sources[s.Name] = s.Source
+	}
+	for name, want := range map[string]string{"listen": SourceFlag, "log-level": SourceEnv, "mode": SourceFile, "db": SourceDefault} {
+		if sources[name] != want {
+			t.Errorf("expected %s from %s, got %s", name, want, sources[name])
+		}
+	}
+
+	// A subcommand takes its own section
+	var port int
+	serve := NewFlagSet("serve", "", "")
+	serve.IntVar(&port, "port", "", 8084, "port")
+	if err := serve.Parse(nil); err != nil || port != 9999 {
+		t.Errorf("expected port 9999 from the serve section, got %d (%v)", port, err)
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	var args CLIArgs
+	useTestConfigFile(t, "mdoe: stdio\n")
+	if err := GlobalFlagSet(&args).Parse(nil); err == nil || !strings.Contains(err.Error(), `unknown option "mdoe"`) {
+		t.Errorf("expected unknown option error, got %v", err)
+	}
+
+	useTestConfigFile(t, "workers: many\n")
+	if err := GlobalFlagSet(&args).Parse(nil); err == nil || !strings.Contains(err.Error(), "invalid workers") {
+		t.Errorf("expected invalid value error, got %v", err)
+	}
+}
+
+func TestLoadConfigFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv(ConfigFileEnv, "")
+	cf, err := LoadConfigFile(path)
+	if err != nil || cf.Loaded {
+		t.Errorf("expected an empty config without a file, got %+v, %v", cf, err)
+	}
+
+	// Unless it was asked for
+	t.Setenv(ConfigFileEnv, path)
+	if _, err := LoadConfigFile(path); err == nil {
+		t.Error("expected error for a missing $ARMOUR_CONFIG_FILE")
+	}
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlagSet wraps flag.FlagSet with environment variable and config file
+// fallbacks and a consistent usage layout shared by every subcommand.
+type FlagSet struct {
+	*flag.FlagSet
+	usage   string
+	summary string
+	env     map[string]string // flag name -> environment variable
+	sources map[string]string // flag name -> Source* of its value, once parsed
+}
+
+// NewFlagSet creates a flag set for a subcommand. usage is the argument
+// synopsis shown after the command name, summary a one-line description.
+func NewFlagSet(name, usage, summary string) *FlagSet {
+	fs := &FlagSet{
+		FlagSet: flag.NewFlagSet(name, flag.ContinueOnError),
+		usage:   usage,
+		summary: summary,
+		env:     make(map[string]string),
+	}
+	fs.FlagSet.Usage = func() { fs.PrintUsage(fs.Output()) }
+	return fs
+}
+
+// StringVar defines a string flag with an optional environment fallback.
+func (fs *FlagSet) StringVar(p *string, name, env, value, usage string) {
+	fs.FlagSet.StringVar(p, name, value, usage)
+	fs.bindEnv(name, env)
+}
+
+// BoolVar defines a bool flag with an optional environment fallback.
+func (fs *FlagSet) BoolVar(p *bool, name, env string, value bool, usage string) {
+	fs.FlagSet.BoolVar(p, name, value, usage)
+	fs.bindEnv(name, env)
+}
+
+// IntVar defines an int flag with an optional environment fallback.
+func (fs *FlagSet) IntVar(p *int, name, env string, value int, usage string) {
+	fs.FlagSet.IntVar(p, name, value, usage)
+	fs.bindEnv(name, env)
+}
+
+// DurationVar defines a duration flag with an optional environment fallback.
+func (fs *FlagSet) DurationVar(p *time.Duration, name, env string, value time.Duration, usage string) {
+	fs.FlagSet.DurationVar(p, name, value, usage)
+	fs.bindEnv(name, env)
+}
+
+// ByteSizeVar defines a size flag such as "512KB" or "4MB" (a bare number is
+// bytes) with an optional environment fallback.
+func (fs *FlagSet) ByteSizeVar(p *int, name, env string, value int, usage string) {
+	*p = value
+	fs.FlagSet.Var((*byteSize)(p), name, usage)
+	fs.bindEnv(name, env)
+}
+
+// byteSize is a flag.Value for sizes with an optional KB/MB/GB suffix (powers of 1024).
+type byteSize int
+
+var byteSizeUnits = []struct {
+	suffix string
+	scale  int
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+}
+
+func (b *byteSize) String() string {
+	for _, u := range byteSizeUnits {
+		if int(*b) >= u.scale && int(*b)%u.scale == 0 {
+			return strconv.Itoa(int(*b)/u.scale) + u.suffix
+		}
+	}
+	return strconv.Itoa(int(*b))
+}
+
+func (b *byteSize) Set(s string) error {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	scale := 1
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, scale = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.scale
+			break
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid size %q (want e.g. 512KB or 4MB)", s)
+	}
+	*b = byteSize(n * scale)
+	return nil
+}
+
+// DaysVar defines a duration flag that also takes whole days, such as "30d",
+// with an optional environment fallback.
+func (fs *FlagSet) DaysVar(p *time.Duration, name, env string, value time.Duration, usage string) {
+	*p = value
+	fs.FlagSet.Var((*days)(p), name, usage)
+	fs.bindEnv(name, env)
+}
+
+// days is a flag.Value for Go durations or a number of days with a d suffix.
+type days time.Duration
+
+func (d *days) String() string {
+	v := time.Duration(*d)
+	if v > 0 && v%(24*time.Hour) == 0 {
+		return strconv.Itoa(int(v/(24*time.Hour))) + "d"
+	}
+	return v.String()
+}
+
+func (d *days) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		count, err := strconv.Atoi(n)
+		if err != nil || count < 0 {
+			return fmt.Errorf("invalid duration %q (want e.g. 30d or 12h)", s)
+		}
+		*d = days(time.Duration(count) * 24 * time.Hour)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid duration %q (want e.g. 30d or 12h)", s)
+	}
+	*d = days(v)
+	return nil
+}
+
+func (fs *FlagSet) bindEnv(name, env string) {
+	if env != "" {
+		fs.env[name] = env
+	}
+}
+
+// Parse parses args, then fills any flag not given on the command line from
+// its environment variable, and failing that from the config file (see
+// UseConfigFile). Returns flag.ErrHelp for -h/--help.
+func (fs *FlagSet) Parse(args []string) error {
+	if err := fs.FlagSet.Parse(args); err != nil {
+		return err
+	}
+	return fs.resolve()
+}
+
+// resolve fills the flags not given on the command line from the environment
+// and the config file, and records where each value came from.
+func (fs *FlagSet) resolve() error {
+	fs.sources = make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { fs.sources[f.Name] = SourceFlag })
+
+	for name, env := range fs.env {
+		if fs.sources[name] != "" {
+			continue
+		}
+		value, ok := os.LookupEnv(env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s=%q: %w", env, value, err)
+		}
+		fs.sources[name] = SourceEnv
+	}
+
+	if configFile == nil {
+		return nil
+	}
+	// A subcommand section may hold options of sibling subcommands
+	// ("rules add" and "rules list"), so only top-level typos are caught
+	section := fs.section()
+	for name, value := range configFile.Values(section) {
+		if fs.Lookup(name) == nil {
+			if section == "" {
+				return fmt.Errorf("%s: unknown option %q", configFile.Path, name)
+			}
+			continue
+		}
+		if fs.sources[name] != "" {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: invalid %s=%q: %w", configFile.Path, name, value, err)
+		}
+		fs.sources[name] = SourceFile
+	}
+	return nil
+}
+
+// section is the config file section of the flag set: "" for the proxy-wide
+// flags, else the subcommand name ("policy" for "policy apply").
+func (fs *FlagSet) section() string {
+	name, _, _ := strings.Cut(fs.Name(), " ")
+	if name == "mcp-proxy" {
+		return ""
+	}
+	return name
+}
+
+// Setting is the value a flag resolved to and where it came from.
+type Setting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // one of the Source* constants
+	Env    string `json:"env,omitempty"`
+}
+
+// Settings lists every flag after Parse, sorted by name.
+func (fs *FlagSet) Settings() []Setting {
+	var settings []Setting
+	fs.VisitAll(func(f *flag.Flag) {
+		source := fs.sources[f.Name]
+		if source == "" {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{Name: f.Name, Value: f.Value.String(), Source: source, Env: fs.env[f.Name]})
+	})
+	return settings
+}
+
+// MustParse parses args and exits on error: status 0 for --help, 2 otherwise.
+func (fs *FlagSet) MustParse(args []string) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		fmt.Fprintf(fs.Output(), "error: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+// PrintUsage writes the synopsis, summary and flag list (with env fallbacks).
+func (fs *FlagSet) PrintUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: mcp-proxy %s", fs.Name())
+	if fs.usage != "" {
+		fmt.Fprintf(w, " %s", fs.usage)
+	}
+	fmt.Fprintln(w)
+	if fs.summary != "" {
+		fmt.Fprintf(w, "\n%s\n", fs.summary)
+	}
+
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if !hasFlags {
+		return
+	}
+
+	fmt.Fprintln(w, "\nFLAGS:")
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		line := "  -" + f.Name
+		if name != "" {
+			line += " " + strings.ToUpper(name)
+		}
+		fmt.Fprintf(w, "%-28s %s", line, usage)
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" && f.DefValue != "0s" {
+			fmt.Fprintf(w, " (default: %s)", f.DefValue)
+		}
+		if env := fs.env[f.Name]; env != "" {
+			fmt.Fprintf(w, " [$%s]", env)
+		}
+		fmt.Fprintln(w)
+	})
+}
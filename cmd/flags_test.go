@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestFlagSetEnvFallback(t *testing.T) {
+	t.Setenv("ARMOUR_TEST_PORT", "9090")
+	t.Setenv("ARMOUR_TEST_INTERVAL", "5s")
+
+	var port int
+	var interval time.Duration
+	fs := NewFlagSet("test", "", "")
+	fs.IntVar(&port, "port", "ARMOUR_TEST_PORT", 8084, "port")
+	fs.DurationVar(&interval, "interval", "ARMOUR_TEST_INTERVAL", time.Second, "interval")
+
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if port != 9090 || interval != 5*time.Second {
+		t.Errorf("expected env values, got port=%d interval=%s", port, interval)
+	}
+}
+
+func TestFlagSetExplicitBeatsEnv(t *testing.T) {
+	t.Setenv("ARMOUR_TEST_PORT", "9090")
+
+	var port int
+	fs := NewFlagSet("test", "", "")
+	fs.IntVar(&port, "port", "ARMOUR_TEST_PORT", 8084, "port")
+
+	if err := fs.Parse([]string{"-port", "7000"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if port != 7000 {
+		t.Errorf("expected explicit flag to win, got %d", port)
+	}
+}
+
+func TestFlagSetInvalidEnv(t *testing.T) {
+	t.Setenv("ARMOUR_TEST_PORT", "not-a-number")
+
+	var port int
+	fs := NewFlagSet("test", "", "")
+	fs.IntVar(&port, "port", "ARMOUR_TEST_PORT", 8084, "port")
+
+	if err := fs.Parse(nil); err == nil {
+		t.Error("expected error for invalid env value")
+	}
+}
+
+func TestFlagSetHelp(t *testing.T) {
+	fs := NewFlagSet("test", "", "")
+	fs.SetOutput(nopWriter{})
+
+	if err := fs.Parse([]string{"--help"}); err != flag.ErrHelp {
+		t.Errorf("expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestParseGlobalArgsBeforeSubcommand(t *testing.T) {
+	args, rest, err := ParseGlobalArgs([]string{"-log-level", "debug", "serve", "-port", "9000"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args.LogLevel != "debug" {
+		t.Errorf("expected log level debug, got %s", args.LogLevel)
+	}
+	if len(rest) != 3 || rest[0] != "serve" || rest[2] != "9000" {
+		t.Errorf("expected subcommand args to be preserved, got %v", rest)
+	}
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestFlagSetByteSize(t *testing.T) {
+	t.Setenv("ARMOUR_TEST_SIZE", "2mb")
+
+	var size, other int
+	fs := NewFlagSet("test", "", "")
+	fs.ByteSizeVar(&size, "size", "ARMOUR_TEST_SIZE", 1024, "size")
+	fs.ByteSizeVar(&other, "other", "", 1024, "size")
+
+	if err := fs.Parse([]string{"-other", "512KB"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size != 2<<20 || other != 512<<10 {
+		t.Errorf("expected 2MB and 512KB, got %d and %d", size, other)
+	}
+	if got := fs.Lookup("size").DefValue; got != "1KB" {
+		t.Errorf("expected default shown as 1KB, got %s", got)
+	}
+	if err := fs.Set("other", "lots"); err == nil {
+		t.Error("expected error for invalid size")
+	}
+}
+
+func TestFlagSetDays(t *testing.T) {
+	t.Setenv("ARMOUR_TEST_RETENTION", "30d")
+
+	var retention, other time.Duration
+	fs := NewFlagSet("test", "", "")
+	fs.DaysVar(&retention, "retention", "ARMOUR_TEST_RETENTION", 0, "retention")
+	fs.DaysVar(&other, "other", "", 7*24*time.Hour, "retention")
+
+	if err := fs.Parse([]string{"-other", "36h"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if retention != 30*24*time.Hour || other != 36*time.Hour {
+		t.Errorf("expected 30 days and 36h, got %v and %v", retention, other)
+	}
+	if got := fs.Lookup("other").DefValue; got != "7d" {
+		t.Errorf("expected default shown as 7d, got %s", got)
+	}
+	if err := fs.Set("other", "a month"); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+}
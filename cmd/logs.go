@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TraceFilter narrows down which trace events are printed by `mcp-proxy logs`.
+type TraceFilter struct {
+	Tool        string // substring match on server, method, detail or attachment
+	Backend     string // exact match on the event's server
+	BlockedOnly bool   // only blocklist-stage events
+	ErrorsOnly  bool   // only events of failed steps
+	Since       time.Time
+}
+
+// Matches reports whether a trace event passes the filter.
+func (f TraceFilter) Matches(ev proxy.TraceEvent) bool {
+	if !f.Since.IsZero() && !ev.Time.After(f.Since) {
+		return false
+	}
+	if f.Backend != "" && ev.Server != f.Backend {
+		return false
+	}
+	if f.BlockedOnly && ev.Stage != "blocklist" {
+		return false
+	}
+	if f.ErrorsOnly && !ev.Error {
+		return false
+	}
+	if f.Tool != "" {
+		haystack := ev.Server + " " + ev.Method + " " + ev.Detail + " " + ev.Attachment
+		if !strings.Contains(haystack, f.Tool) {
+			return false
+		}
+	}
+	return true
+}
+
+// DashboardGet sends a GET request to a running dashboard, with token as
+// the bearer token when the dashboard requires one.
+func DashboardGet(url, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := http.Client{Timeout: 2 * time.Second}
+	return client.Do(req)
+}
+
+// DashboardPost sends body as JSON in a POST request to a running
+// dashboard, with token as the bearer token when the dashboard requires one.
+func DashboardPost(url, token string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := http.Client{Timeout: 2 * time.Second}
+	return client.Do(req)
+}
+
+// FetchTraceEvents reads the current trace buffer from a running dashboard.
+func FetchTraceEvents(baseURL, token string) ([]proxy.TraceEvent, error) {
+	resp, err := DashboardGet(strings.TrimSuffix(baseURL, "/")+"/api/trace", token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach dashboard: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dashboard returned status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Events []proxy.TraceEvent `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode trace response: %w", err)
+	}
+	return payload.Events, nil
+}
+
+// FetchStoredTraceEvents reads the trace events a running proxy persisted
+// (with -trace-persist) since a date or age such as 2h, oldest first.
+func FetchStoredTraceEvents(baseURL, token, since string) ([]proxy.TraceEvent, error) {
+	resp, err := DashboardGet(strings.TrimSuffix(baseURL, "/")+"/api/v1/trace/history?limit=100000&since="+url.QueryEscape(since), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach dashboard: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dashboard returned status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Events []proxy.TraceEvent `json:"events"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode trace history: %w", err)
+	}
+	return payload.Events, nil
+}
+
+// FormatTraceEvent renders a trace event as a single terminal line.
+func FormatTraceEvent(ev proxy.TraceEvent) string {
+	line := fmt.Sprintf("%s  %-10s %-20s %-24s %s",
+		ev.Time.Local().Format("15:04:05.000"), ev.Stage, ev.Server, ev.Method, ev.Detail)
+	if ev.Attachment != "" {
+		line += "  [" + ev.Attachment + "]"
+	}
+	return strings.TrimRight(line, " ")
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONSchemaVersion is bumped whenever a command's JSON data shape changes incompatibly.
+const JSONSchemaVersion = 1
+
+// JSONEnvelope is the stable wrapper every subcommand emits in -json mode.
+type JSONEnvelope struct {
+	SchemaVersion int         `json:"schema_version"`
+	Command       string      `json:"command"`
+	OK            bool        `json:"ok"`
+	Data          interface{} `json:"data,omitempty"`
+	Error         string      `json:"error,omitempty"`
+}
+
+// WriteJSON writes a single-line JSON envelope for command. A non-nil err marks the result as failed.
+func WriteJSON(w io.Writer, command string, data interface{}, err error) error {
+	env := JSONEnvelope{
+		SchemaVersion: JSONSchemaVersion,
+		Command:       command,
+		OK:            err == nil,
+		Data:          data,
+	}
+	if err != nil {
+		env.Error = err.Error()
+	}
+	return json.NewEncoder(w).Encode(env)
+}
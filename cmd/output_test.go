@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestWriteJSONSuccess(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, "detect", map[string]int{"count": 2}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var env struct {
+		SchemaVersion int            `json:"schema_version"`
+		Command       string         `json:"command"`
+		OK            bool           `json:"ok"`
+		Data          map[string]int `json:"data"`
+		Error         string         `json:"error"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &env); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if env.SchemaVersion != JSONSchemaVersion || env.Command != "detect" || !env.OK {
+		t.Errorf("unexpected envelope: %+v", env)
+	}
+	if env.Data["count"] != 2 || env.Error != "" {
+		t.Errorf("unexpected payload: %+v", env)
+	}
+}
+
+func TestWriteJSONError(t *testing.T) {
+	var buf bytes.Buffer
+	WriteJSON(&buf, "status", nil, errors.New("proxy not running"))
+
+	var env map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &env); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if env["ok"] != false || env["error"] != "proxy not running" {
+		t.Errorf("unexpected envelope: %v", env)
+	}
+	if _, ok := env["data"]; ok {
+		t.Error("expected data to be omitted on error")
+	}
+}
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// ServiceConfig describes the persistent Armour services: the rules server
+// and the HTTP proxy.
+type ServiceConfig struct {
+	Binary     string // absolute path to mcp-proxy
+	ListenAddr string // HTTP proxy listen address
+	RulesPort  int
+	ConfigPath string // server registry for the HTTP proxy
+	RulesDB    string
+	LogDir     string
+	LogLevel   string
+}
+
+// ServiceUnit is a generated unit file and where it is installed.
+type ServiceUnit struct {
+	Name    string // systemd unit name or launchd label
+	Path    string
+	Content string
+}
+
+const (
+	serviceRulesName = "armour-rules"
+	serviceProxyName = "armour-proxy"
+	launchdPrefix    = "dev.armour."
+)
+
+// DefaultServiceConfig uses the running binary and the standard ~/.armour paths.
+func DefaultServiceConfig() ServiceConfig {
+	homeDir, _ := os.UserHomeDir()
+	binary, _ := os.Executable()
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+	return ServiceConfig{
+		Binary:     binary,
+		ListenAddr: "127.0.0.1:8080",
+		RulesPort:  8084,
+		ConfigPath: filepath.Join(homeDir, ".claude", "mcp-proxy", "servers.json"), // written by `migrate`
+		RulesDB:    filepath.Join(homeDir, ".armour", "rules.db"),
+		LogDir:     filepath.Join(homeDir, ".armour", "logs"),
+		LogLevel:   "info",
+	}
+}
+
+type serviceProcess struct {
+	Name        string
+	Description string
+	Args        []string
+	Env         map[string]string
+	After       string // systemd ordering dependency, if any
+	LogFile     string
+}
+
+func (c ServiceConfig) processes() []serviceProcess {
+	return []serviceProcess{
+		{
+			Name:        serviceRulesName,
+			Description: "Armour rules server",
+			Args:        []string{c.Binary, "serve", "-port", fmt.Sprint(c.RulesPort), "-db", c.RulesDB, "-log-level", c.LogLevel},
+			LogFile:     filepath.Join(c.LogDir, serviceRulesName+".log"),
+		},
+		{
+			Name:        serviceProxyName,
+			Description: "Armour MCP proxy (HTTP mode)",
+			Args:        []string{c.Binary, "-mode", "http", "-listen", c.ListenAddr, "-config", c.ConfigPath, "-log-level", c.LogLevel},
+			Env:         map[string]string{"ARMOUR_RULES_URL": fmt.Sprintf("http://127.0.0.1:%d", c.RulesPort)},
+			After:       serviceRulesName + ".service",
+			LogFile:     filepath.Join(c.LogDir, serviceProxyName+".log"),
+		},
+	}
+}
+
+var systemdTemplate = template.Must(template.New("systemd").Funcs(template.FuncMap{"quote": systemdQuote}).Parse(`[Unit]
+Description={{.Description}}
+After=network-online.target{{if .After}} {{.After}}
+Wants={{.After}}{{end}}
+
+[Service]
+Type=simple
+ExecStart={{range $i, $a := .Args}}{{if $i}} {{end}}{{quote $a}}{{end}}
+{{- range $k, $v := .Env}}
+Environment={{quote (printf "%s=%s" $k $v)}}{{end}}
+Restart=on-failure
+RestartSec=5
+StandardOutput=append:{{.LogFile}}
+StandardError=append:{{.LogFile}}
+
+[Install]
+WantedBy=default.target
+`))
+
+var launchdTemplate = template.Must(template.New("launchd").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>{{xml .Label}}</string>
+  <key>ProgramArguments</key>
+  <array>
+{{- range .Args}}
+    <string>{{xml .}}</string>{{end}}
+  </array>
+{{- if .Env}}
+  <key>EnvironmentVariables</key>
+  <dict>
+{{- range $k, $v := .Env}}
+    <key>{{xml $k}}</key>
+    <string>{{xml $v}}</string>{{end}}
+  </dict>
+{{- end}}
+  <key>RunAtLoad</key>
+  <true/>
+  <key>KeepAlive</key>
+  <dict>
+    <key>SuccessfulExit</key>
+    <false/>
+  </dict>
+  <key>ThrottleInterval</key>
+  <integer>5</integer>
+  <key>StandardOutPath</key>
+  <string>{{xml .LogFile}}</string>
+  <key>StandardErrorPath</key>
+  <string>{{xml .LogFile}}</string>
+</dict>
+</plist>
+`))
+
+// ServiceUnits renders the unit files for goos ("linux" → systemd user units,
+// "darwin" → launchd agents) rooted at homeDir.
+func ServiceUnits(goos, homeDir string, c ServiceConfig) ([]ServiceUnit, error) {
+	var units []ServiceUnit
+	for _, p := range c.processes() {
+		var buf bytes.Buffer
+		var unit ServiceUnit
+		switch goos {
+		case "linux":
+			if err := systemdTemplate.Execute(&buf, p); err != nil {
+				return nil, err
+			}
+			unit = ServiceUnit{
+				Name: p.Name + ".service",
+				Path: filepath.Join(homeDir, ".config", "systemd", "user", p.Name+".service"),
+			}
+		case "darwin":
+			label := launchdPrefix + strings.TrimPrefix(p.Name, "armour-")
+			data := struct {
+				serviceProcess
+				Label string
+			}{p, label}
+			if err := launchdTemplate.Execute(&buf, data); err != nil {
+				return nil, err
+			}
+			unit = ServiceUnit{
+				Name: label,
+				Path: filepath.Join(homeDir, "Library", "LaunchAgents", label+".plist"),
+			}
+		default:
+			return nil, fmt.Errorf("service install is not supported on %s", goos)
+		}
+		unit.Content = buf.String()
+		units = append(units, unit)
+	}
+	return units, nil
+}
+
+// InstallServices writes the units and enables them with systemctl or launchctl.
+func InstallServices(goos string, units []ServiceUnit, logDir string) error {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	for _, u := range units {
+		if err := os.MkdirAll(filepath.Dir(u.Path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(u.Path, []byte(u.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", u.Path, err)
+		}
+	}
+
+	switch goos {
+	case "linux":
+		if err := runServiceCommand("systemctl", "--user", "daemon-reload"); err != nil {
+			return err
+		}
+		for _, u := range units {
+			if err := runServiceCommand("systemctl", "--user", "enable", "--now", u.Name); err != nil {
+				return err
+			}
+		}
+	case "darwin":
+		for _, u := range units {
+			// Reload if already loaded so changes take effect
+			runServiceCommand("launchctl", "unload", u.Path)
+			if err := runServiceCommand("launchctl", "load", "-w", u.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UninstallServices stops and removes the units.
+func UninstallServices(goos string, units []ServiceUnit) error {
+	for _, u := range units {
+		switch goos {
+		case "linux":
+			runServiceCommand("systemctl", "--user", "disable", "--now", u.Name)
+		case "darwin":
+			runServiceCommand("launchctl", "unload", "-w", u.Path)
+		}
+		if err := os.Remove(u.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", u.Path, err)
+		}
+	}
+	if goos == "linux" {
+		return runServiceCommand("systemctl", "--user", "daemon-reload")
+	}
+	return nil
+}
+
+func runServiceCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// systemdQuote quotes a word for ExecStart/Environment when it contains
+// spaces, quotes or backslashes.
+func systemdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	template.HTMLEscape(&buf, []byte(s))
+	return buf.String()
+}
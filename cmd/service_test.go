@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func testServiceConfig() ServiceConfig {
+	return ServiceConfig{
+		Binary:     "/opt/armour/mcp-proxy",
+		ListenAddr: "127.0.0.1:8080",
+		RulesPort:  9084,
+		ConfigPath: "/home/dev/My Config/servers.json",
+		RulesDB:    "/home/dev/.armour/rules.db",
+		LogDir:     "/home/dev/.armour/logs",
+		LogLevel:   "info",
+	}
+}
+
+func TestServiceUnitsSystemd(t *testing.T) {
+	units, err := ServiceUnits("linux", "/home/dev", testServiceConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(units) != 2 {
+		t.Fatalf("expected rules and proxy units, got %d", len(units))
+	}
+
+	rules, proxy := units[0], units[1]
+	if rules.Path != "/home/dev/.config/systemd/user/armour-rules.service" {
+		t.Errorf("unexpected unit path: %s", rules.Path)
+	}
+	if !strings.Contains(rules.Content, "ExecStart=/opt/armour/mcp-proxy serve -port 9084") {
+		t.Errorf("rules unit missing ExecStart:\n%s", rules.Content)
+	}
+	for _, want := range []string{
+		`"/home/dev/My Config/servers.json"`,
+		"Environment=ARMOUR_RULES_URL=http://127.0.0.1:9084",
+		"After=network-online.target armour-rules.service",
+		"Restart=on-failure",
+		"StandardOutput=append:/home/dev/.armour/logs/armour-proxy.log",
+	} {
+		if !strings.Contains(proxy.Content, want) {
+			t.Errorf("proxy unit missing %q:\n%s", want, proxy.Content)
+		}
+	}
+}
+
+func TestServiceUnitsLaunchd(t *testing.T) {
+	units, err := ServiceUnits("darwin", "/Users/dev", testServiceConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy := units[1]
+	if proxy.Name != "dev.armour.proxy" || proxy.Path != "/Users/dev/Library/LaunchAgents/dev.armour.proxy.plist" {
+		t.Errorf("unexpected launchd unit: %s at %s", proxy.Name, proxy.Path)
+	}
+	for _, want := range []string{
+		"<string>-mode</string>",
+		"<key>ARMOUR_RULES_URL</key>",
+		"<key>KeepAlive</key>",
+		"<string>/home/dev/.armour/logs/armour-proxy.log</string>",
+	} {
+		if !strings.Contains(proxy.Content, want) {
+			t.Errorf("plist missing %q:\n%s", want, proxy.Content)
+		}
+	}
+}
+
+func TestServiceUnitsUnsupported(t *testing.T) {
+	if _, err := ServiceUnits("windows", `C:\Users\dev`, testServiceConfig()); err == nil {
+		t.Error("expected error for unsupported OS")
+	}
+}
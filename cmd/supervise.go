@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// Environment the supervisor passes to the proxy it runs, so the proxy can
+// report its crash history in its health report.
+const (
+	SupervisorCrashesEnv   = "ARMOUR_SUPERVISOR_CRASHES"
+	SupervisorLastCrashEnv = "ARMOUR_SUPERVISOR_LAST_CRASH" // RFC 3339
+	SupervisorLastDumpEnv  = "ARMOUR_SUPERVISOR_LAST_DUMP"
+)
+
+const (
+	crashTailSize     = 1 << 20 // stderr kept for a crash dump
+	maxRestartBackoff = time.Minute
+	stableRunTime     = time.Minute // a proxy that ran this long ends a crash streak
+)
+
+// Supervisor runs the proxy as a child process and restarts it when it
+// crashes. The child inherits the supervisor's stdio, so an MCP client's
+// stream survives the restart; being the child's parent, the supervisor also
+// keeps its stdio session key stable.
+type Supervisor struct {
+	Command     []string // proxy binary and arguments
+	Stdin       io.Reader
+	Stdout      io.Writer
+	Stderr      io.Writer
+	CrashDir    string
+	KeepDumps   int           // crash dumps kept in CrashDir
+	MaxRestarts int           // restarts in a row before giving up; 0 for no limit
+	Backoff     time.Duration // delay before the first restart of a streak
+	Logger      *proxy.Logger
+
+	crashes   int
+	lastCrash time.Time
+	lastDump  string
+}
+
+// DefaultCrashDir is where crash dumps go unless -crash-dir says otherwise.
+func DefaultCrashDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".armour", "crashes")
+}
+
+// Run runs the proxy until it exits cleanly, ctx ends, or it crashes again
+// after MaxRestarts restarts in a row. It returns the proxy's last exit code.
+func (s *Supervisor) Run(ctx context.Context) (int, error) {
+	if len(s.Command) == 0 {
+		return 1, fmt.Errorf("no command to supervise")
+	}
+
+	streak := 0
+	for {
+		started := time.Now()
+		tail := &tailBuffer{limit: crashTailSize}
+		code, err := s.runChild(ctx, tail)
+		if err != nil {
+			return 1, err
+		}
+		if code == 0 || ctx.Err() != nil {
+			return code, nil
+		}
+
+		ran := time.Since(started)
+		if ran >= stableRunTime {
+			streak = 0
+		}
+		streak++
+		s.crashes++
+		s.lastCrash = time.Now()
+		if dump, err := s.writeCrashDump(code, ran, tail.Bytes()); err != nil {
+			s.Logger.Warn("failed to write crash dump: %v", err)
+		} else {
+			s.lastDump = dump
+		}
+
+		if s.MaxRestarts > 0 && streak > s.MaxRestarts {
+			s.Logger.Error("proxy exited with status %d after %d restarts in a row, giving up (crash dump: %s)", code, s.MaxRestarts, s.lastDump)
+			return code, nil
+		}
+		delay := s.Backoff << (streak - 1)
+		if delay > maxRestartBackoff || delay <= 0 {
+			delay = maxRestartBackoff
+		}
+		s.Logger.Warn("proxy exited with status %d, restarting in %v (crash dump: %s)", code, delay, s.lastDump)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return code, nil
+		}
+	}
+}
+
+// runChild runs the proxy once and returns its exit code. When ctx ends the
+// proxy is interrupted and given the chance to shut down as usual.
+func (s *Supervisor) runChild(ctx context.Context, tail *tailBuffer) (int, error) {
+	child := exec.Command(s.Command[0], s.Command[1:]...)
+	child.Stdin = s.Stdin
+	child.Stdout = s.Stdout
+	child.Stderr = tail
+	if s.Stderr != nil {
+		child.Stderr = io.MultiWriter(s.Stderr, tail)
+	}
+	child.Env = append(os.Environ(), SupervisorCrashesEnv+"="+strconv.Itoa(s.crashes))
+	if !s.lastCrash.IsZero() {
+		child.Env = append(child.Env,
+			SupervisorLastCrashEnv+"="+s.lastCrash.UTC().Format(time.RFC3339),
+			SupervisorLastDumpEnv+"="+s.lastDump)
+	}
+	// A panic's crash dump should show every goroutine, not just the one that panicked
+	if os.Getenv("GOTRACEBACK") == "" {
+		child.Env = append(child.Env, "GOTRACEBACK=all")
+	}
+	if err := child.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start proxy: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- child.Wait() }()
+	select {
+	case err := <-done:
+		return exitCode(err), nil
+	case <-ctx.Done():
+		if err := child.Process.Signal(os.Interrupt); err != nil {
+			child.Process.Kill() // no interrupt on Windows
+		}
+		return exitCode(<-done), nil
+	}
+}
+
+// exitCode is the status of a finished process; -1 if a signal killed it.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// writeCrashDump saves the end of the proxy's stderr, where a panic or fatal
+// error ends up, and deletes all but the newest KeepDumps dumps.
+func (s *Supervisor) writeCrashDump(code int, ran time.Duration, stderr []byte) (string, error) {
+	if err := os.MkdirAll(s.CrashDir, 0o700); err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	path := filepath.Join(s.CrashDir, "crash-"+now.Format("20060102-150405.000")+".log")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "mcp-proxy exited with status %d at %s after running %v\n", code, now.Format(time.RFC3339), ran.Round(time.Millisecond))
+	fmt.Fprintf(&b, "command: %s\n", strings.Join(s.Command, " "))
+	fmt.Fprintf(&b, "crashes under this supervisor: %d\n\n", s.crashes)
+	b.Write(stderr)
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		return "", err
+	}
+
+	dumps, _ := filepath.Glob(filepath.Join(s.CrashDir, "crash-*.log"))
+	sort.Strings(dumps) // timestamped names sort oldest first
+	for len(dumps) > s.KeepDumps && s.KeepDumps > 0 {
+		os.Remove(dumps[0])
+		dumps = dumps[1:]
+	}
+	return path, nil
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.limit {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.limit:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.buf...)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestSupervisorHelperProcess stands in for the proxy when the supervisor
+// tests run the test binary as their child.
+func TestSupervisorHelperProcess(t *testing.T) {
+	mode := os.Getenv("SUPERVISOR_TEST_HELPER")
+	if mode == "" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "panic: crash %s\n", os.Getenv(SupervisorCrashesEnv))
+	if mode == "crash-once" && os.Getenv(SupervisorCrashesEnv) != "0" {
+		os.Exit(0)
+	}
+	os.Exit(2)
+}
+
+func testSupervisor(t *testing.T, mode string) *Supervisor {
+	t.Setenv("SUPERVISOR_TEST_HELPER", mode)
+	return &Supervisor{
+		Command:     []string{os.Args[0], "-test.run=TestSupervisorHelperProcess"},
+		CrashDir:    t.TempDir(),
+		KeepDumps:   2,
+		MaxRestarts: 3,
+		Backoff:     time.Millisecond,
+		Logger:      proxy.NewLogger("error"),
+	}
+}
+
+func TestSupervisorRestartsAfterCrash(t *testing.T) {
+	sup := testSupervisor(t, "crash-once")
+	code, err := sup.Run(context.Background())
+	if err != nil || code != 0 {
+		t.Fatalf("expected clean exit after a restart, got %d, %v", code, err)
+	}
+	if sup.crashes != 1 {
+		t.Errorf("expected 1 crash, got %d", sup.crashes)
+	}
+
+	dumps, _ := filepath.Glob(filepath.Join(sup.CrashDir, "crash-*.log"))
+	if len(dumps) != 1 {
+		t.Fatalf("expected one crash dump, got %v", dumps)
+	}
+	data, _ := os.ReadFile(dumps[0])
+	if !strings.Contains(string(data), "exited with status 2") || !strings.Contains(string(data), "panic: crash 0") {
+		t.Errorf("crash dump lacks the exit status or stderr:\n%s", data)
+	}
+}
+
+func TestSupervisorGivesUp(t *testing.T) {
+	sup := testSupervisor(t, "crash")
+	code, err := sup.Run(context.Background())
+	if err != nil || code != 2 {
+		t.Fatalf("expected the crash status, got %d, %v", code, err)
+	}
+	if sup.crashes != 4 {
+		t.Errorf("expected the first run and 3 restarts to crash, got %d crashes", sup.crashes)
+	}
+
+	// Only the newest dumps are kept
+	dumps, _ := filepath.Glob(filepath.Join(sup.CrashDir, "crash-*.log"))
+	if len(dumps) != 2 {
+		t.Fatalf("expected 2 crash dumps, got %v", dumps)
+	}
+	data, _ := os.ReadFile(dumps[1])
+	if !strings.Contains(string(data), "panic: crash 3") {
+		t.Errorf("expected the newest dump last, got:\n%s", data)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	tail := &tailBuffer{limit: 4}
+	tail.Write([]byte("abc"))
+	tail.Write([]byte("defg"))
+	if got := string(tail.Bytes()); got != "defg" {
+		t.Errorf("expected last 4 bytes, got %q", got)
+	}
+}
@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+const (
+	// DefaultReleasesURL is the GitHub API endpoint for the latest release.
+	DefaultReleasesURL = "https://api.github.com/repos/fuushyn/armour/releases/latest"
+	// ChecksumsAssetName lists "<sha256>  <asset>" lines for every release asset.
+	ChecksumsAssetName = "checksums.txt"
+	// ChecksumsSignatureAssetName is a base64 ed25519 signature over checksums.txt.
+	ChecksumsSignatureAssetName = "checksums.txt.sig"
+	// ReleasePublicKey is the base64 ed25519 key releases sign checksums.txt
+	// with; the release workflow holds the private key as RELEASE_SIGNING_KEY.
+	ReleasePublicKey = "PcgkrBrakyr38RrkFaDLxUt0EFQbgOjIMQ6pq8U1BE4="
+	// InsecureSkipSignatureEnv installs releases without checking their
+	// signature, for builds of forks that don't sign theirs.
+	InsecureSkipSignatureEnv = "ARMOUR_UPDATE_INSECURE_SKIP_SIGNATURE"
+
+	// updateCheckInterval limits startup checks so the GitHub API isn't hit every session.
+	updateCheckInterval = 24 * time.Hour
+	// updateMaxBytes caps the size of any downloaded release asset.
+	updateMaxBytes = 100 << 20
+)
+
+// Release is the subset of the GitHub release API response used for updates.
+type Release struct {
+	TagName string         `json:"tag_name"`
+	HTMLURL string         `json:"html_url"`
+	Assets  []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is a downloadable file attached to a release.
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+}
+
+// Asset returns the asset with the given name, or nil.
+func (r *Release) Asset(name string) *ReleaseAsset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+// Updater checks for and installs new releases.
+type Updater struct {
+	ReleasesURL string
+	PublicKey   ed25519.PublicKey // checksums.txt must carry a valid signature by this key
+	Client      *http.Client
+
+	// InsecureSkipSignature installs releases whose checksums aren't signed
+	// by PublicKey. Anyone who can change the release can then replace the
+	// binary.
+	InsecureSkipSignature bool
+}
+
+// NewUpdaterFromEnv builds an Updater that checks releases against
+// ReleasePublicKey, or ARMOUR_UPDATE_KEY, from ARMOUR_UPDATE_URL.
+// ARMOUR_UPDATE_INSECURE_SKIP_SIGNATURE=1 turns the signature check off.
+func NewUpdaterFromEnv() (*Updater, error) {
+	u := &Updater{
+		ReleasesURL: DefaultReleasesURL,
+		Client:      &http.Client{Timeout: 60 * time.Second},
+	}
+	if v := os.Getenv("ARMOUR_UPDATE_URL"); v != "" {
+		u.ReleasesURL = v
+	}
+	key := ReleasePublicKey
+	if v := os.Getenv("ARMOUR_UPDATE_KEY"); v != "" {
+		key = v
+	}
+	pub, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("ARMOUR_UPDATE_KEY must be a base64 ed25519 public key")
+	}
+	u.PublicKey = pub
+	if v := os.Getenv(InsecureSkipSignatureEnv); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false", InsecureSkipSignatureEnv)
+		}
+		u.InsecureSkipSignature = skip
+	}
+	return u, nil
+}
+
+// UpdateAssetName returns the release bundle name for a platform.
+func UpdateAssetName(goos, goarch string) string {
+	return fmt.Sprintf("armour-plugin-%s-%s.tar.gz", goos, goarch)
+}
+
+// LatestRelease fetches the latest release metadata.
+func (u *Updater) LatestRelease(ctx context.Context) (*Release, error) {
+	body, err := u.fetch(ctx, u.ReleasesURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	var rel Release
+	if err := json.Unmarshal(body, &rel); err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+	if rel.TagName == "" {
+		return nil, fmt.Errorf("release has no tag")
+	}
+	return &rel, nil
+}
+
+// DownloadBinary downloads this platform's bundle from rel, verifies it against
+// the release checksums and their signature and returns the armour binary it
+// contains.
+func (u *Updater) DownloadBinary(ctx context.Context, rel *Release) ([]byte, error) {
+	assetName := UpdateAssetName(runtime.GOOS, runtime.GOARCH)
+	asset := rel.Asset(assetName)
+	if asset == nil {
+		return nil, fmt.Errorf("release %s has no asset for %s/%s", rel.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	checksumsAsset := rel.Asset(ChecksumsAssetName)
+	if checksumsAsset == nil {
+		return nil, fmt.Errorf("release %s has no %s; refusing to install an unverified binary", rel.TagName, ChecksumsAssetName)
+	}
+
+	checksums, err := u.fetch(ctx, checksumsAsset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch checksums: %w", err)
+	}
+	if !u.InsecureSkipSignature {
+		if u.PublicKey == nil {
+			return nil, fmt.Errorf("no release signing key configured; refusing to install an unverified binary")
+		}
+		sigAsset := rel.Asset(ChecksumsSignatureAssetName)
+		if sigAsset == nil {
+			return nil, fmt.Errorf("release %s is not signed; refusing to install an unverified binary", rel.TagName)
+		}
+		sig, err := u.fetch(ctx, sigAsset.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch signature: %w", err)
+		}
+		if err := VerifyChecksumsSignature(checksums, sig, u.PublicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	want, err := LookupChecksum(checksums, assetName)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := u.fetch(ctx, asset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", assetName, err)
+	}
+	sum := sha256.Sum256(bundle)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", assetName, got, want)
+	}
+
+	return ExtractBinary(bundle, "armour")
+}
+
+func (u *Updater) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "mcp-proxy/"+proxy.Version)
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, updateMaxBytes))
+}
+
+// VerifyChecksumsSignature checks a base64 ed25519 signature over checksums.
+func VerifyChecksumsSignature(checksums, signature []byte, key ed25519.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid checksums signature encoding: %w", err)
+	}
+	if !ed25519.Verify(key, checksums, sig) {
+		return fmt.Errorf("checksums signature verification failed")
+	}
+	return nil
+}
+
+// LookupChecksum finds the sha256 for name in sha256sum-formatted output.
+func LookupChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+// ExtractBinary returns the contents of the first regular file named binary
+// inside a gzipped tarball.
+func ExtractBinary(bundle []byte, binary string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == binary {
+			return io.ReadAll(io.LimitReader(tr, updateMaxBytes))
+		}
+	}
+	return nil, fmt.Errorf("bundle does not contain %s", binary)
+}
+
+// ReplaceExecutable atomically replaces the file at target with binary.
+func ReplaceExecutable(target string, binary []byte) error {
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".mcp-proxy-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+	return nil
+}
+
+// IsNewerVersion reports whether latest is a higher release than current.
+// Tags like "v1.2.3-abc123" compare by their numeric part; a current
+// version that doesn't parse (e.g. "dev") is always considered older.
+func IsNewerVersion(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var out [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
+
+// updateCheckState caches the last startup check in ~/.armour/update-check.json.
+type updateCheckState struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Latest    string    `json:"latest"`
+}
+
+func updateCheckStatePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".armour", "update-check.json")
+}
+
+// CheckForUpdate logs a notice when a release newer than the running version
+// exists. It queries GitHub at most once a day and never returns an error;
+// callers run it in a goroutine so startup is not delayed.
+func CheckForUpdate(ctx context.Context, logger *proxy.Logger) {
+	statePath := updateCheckStatePath()
+	var state updateCheckState
+	if data, err := os.ReadFile(statePath); err == nil {
+		json.Unmarshal(data, &state)
+	}
+
+	if time.Since(state.CheckedAt) >= updateCheckInterval {
+		updater, err := NewUpdaterFromEnv()
+		if err != nil {
+			logger.Debug("update check skipped: %v", err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		rel, err := updater.LatestRelease(ctx)
+		if err != nil {
+			logger.Debug("update check failed: %v", err)
+			return
+		}
+		state = updateCheckState{CheckedAt: time.Now(), Latest: rel.TagName}
+		if data, err := json.Marshal(state); err == nil {
+			os.MkdirAll(filepath.Dir(statePath), 0755)
+			os.WriteFile(statePath, data, 0644)
+		}
+	}
+
+	if IsNewerVersion(state.Latest, proxy.Version) {
+		logger.Info("a newer version of armour is available: %s (running %s); run 'mcp-proxy self-update' to install it", state.Latest, proxy.Version)
+	}
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	cases := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.0.20-abc123", "1.0.16", true},
+		{"v1.0.16-abc123", "1.0.16", false},
+		{"v1.0.9", "1.0.16", false},
+		{"v2.0.0", "v1.9.9", true},
+		{"v1.0.1", "dev", true},
+		{"nightly", "1.0.0", false},
+	}
+	for _, c := range cases {
+		if got := IsNewerVersion(c.latest, c.current); got != c.want {
+			t.Errorf("IsNewerVersion(%q, %q) = %v, want %v", c.latest, c.current, got, c.want)
+		}
+	}
+}
+
+func TestDownloadBinaryVerifiesChecksumAndSignature(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	binary := []byte("#!/bin/sh\necho armour\n")
+	bundle := makeBundle(t, "armour-plugin/armour", binary)
+	assetName := UpdateAssetName(runtime.GOOS, runtime.GOARCH)
+
+	sum := sha256.Sum256(bundle)
+	checksums := []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), assetName))
+	signature := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, checksums)))
+
+	files := map[string][]byte{
+		"/" + assetName:                   bundle,
+		"/" + ChecksumsAssetName:          checksums,
+		"/" + ChecksumsSignatureAssetName: signature,
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest" {
+			rel := Release{TagName: "v9.0.0"}
+			for name := range files {
+				rel.Assets = append(rel.Assets, ReleaseAsset{Name: name[1:], URL: srv.URL + name})
+			}
+			json.NewEncoder(w).Encode(rel)
+			return
+		}
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	updater := &Updater{ReleasesURL: srv.URL + "/latest", PublicKey: pub, Client: srv.Client()}
+	rel, err := updater.LatestRelease(context.Background())
+	if err != nil {
+		t.Fatalf("LatestRelease failed: %v", err)
+	}
+
+	got, err := updater.DownloadBinary(context.Background(), rel)
+	if err != nil {
+		t.Fatalf("DownloadBinary failed: %v", err)
+	}
+	if !bytes.Equal(got, binary) {
+		t.Errorf("unexpected binary contents: %q", got)
+	}
+
+	// A tampered bundle must be rejected
+	files["/"+assetName] = makeBundle(t, "armour-plugin/armour", []byte("evil"))
+	if _, err := updater.DownloadBinary(context.Background(), rel); err == nil {
+		t.Error("expected checksum mismatch for tampered bundle")
+	}
+
+	// A signature from another key must be rejected
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	files["/"+assetName] = bundle
+	updater.PublicKey = otherPub
+	if _, err := updater.DownloadBinary(context.Background(), rel); err == nil {
+		t.Error("expected signature verification failure with wrong key")
+	}
+
+	// An unsigned release is rejected unless the check is turned off
+	updater.PublicKey = pub
+	delete(files, "/"+ChecksumsSignatureAssetName)
+	rel, _ = updater.LatestRelease(context.Background())
+	if _, err := updater.DownloadBinary(context.Background(), rel); err == nil {
+		t.Error("expected an unsigned release rejected")
+	}
+	updater.PublicKey = nil
+	if _, err := updater.DownloadBinary(context.Background(), rel); err == nil {
+		t.Error("expected an updater without a key to refuse to install")
+	}
+	updater.InsecureSkipSignature = true
+	if got, err := updater.DownloadBinary(context.Background(), rel); err != nil || !bytes.Equal(got, binary) {
+		t.Errorf("expected the unsigned release installed with the check off, got %q, %v", got, err)
+	}
+}
+
+func TestNewUpdaterFromEnv(t *testing.T) {
+	t.Setenv("ARMOUR_UPDATE_KEY", "")
+	t.Setenv(InsecureSkipSignatureEnv, "")
+	u, err := NewUpdaterFromEnv()
+	if err != nil {
+		t.Fatalf("NewUpdaterFromEnv failed: %v", err)
+	}
+	if base64.StdEncoding.EncodeToString(u.PublicKey) != ReleasePublicKey || u.InsecureSkipSignature {
+		t.Errorf("expected the release key required by default, got %+v", u)
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	t.Setenv("ARMOUR_UPDATE_KEY", base64.StdEncoding.EncodeToString(other))
+	t.Setenv(InsecureSkipSignatureEnv, "1")
+	if u, err = NewUpdaterFromEnv(); err != nil || !bytes.Equal(u.PublicKey, other) || !u.InsecureSkipSignature {
+		t.Errorf("expected the key and opt-out from the environment, got %+v, %v", u, err)
+	}
+
+	t.Setenv("ARMOUR_UPDATE_KEY", "not-a-key")
+	if _, err := NewUpdaterFromEnv(); err == nil {
+		t.Error("expected an invalid ARMOUR_UPDATE_KEY rejected")
+	}
+}
+
+func makeBundle(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+const configUsage = `usage: mcp-proxy [FLAGS] config show [-json]
+
+Show the value of every option and where it came from. Options resolve in
+this order: command-line flags, ARMOUR_* environment variables, the config
+file ($ARMOUR_CONFIG_FILE or ~/.armour/config.yaml), then defaults.
+`
+
+// configPrecedence is the order options resolve in, highest first.
+var configPrecedence = []string{cmd.SourceFlag, cmd.SourceEnv, cmd.SourceFile, cmd.SourceDefault}
+
+// handleConfigCommand shows the resolved options. globalArgs are the flags
+// given before the subcommand, so `mcp-proxy -mode stdio config show` shows
+// the mode coming from a flag.
+func handleConfigCommand(args, globalArgs []string, configFile *cmd.ConfigFile) {
+	if len(args) < 1 || args[0] != "show" {
+		fmt.Fprint(os.Stderr, configUsage)
+		os.Exit(2)
+	}
+	fs := cmd.NewFlagSet("config show", "[-json]", "Show every option's value and where it came from")
+	addJSONFlag(fs)
+	fs.MustParse(args[1:])
+
+	var globals cmd.CLIArgs
+	global := cmd.GlobalFlagSet(&globals)
+	if err := global.Parse(globalArgs); err != nil {
+		exitWithError("config", err)
+	}
+	var rules server.RulesServerConfig
+	serve := serveFlagSet(&rules, globals)
+	if err := serve.Parse(nil); err != nil {
+		exitWithError("config", err)
+	}
+	sections := []struct {
+		name     string
+		settings []cmd.Setting
+	}{
+		{"", global.Settings()},
+		{"serve", serve.Settings()},
+	}
+	for _, section := range sections {
+		for i := range section.settings {
+			section.settings[i].Value = maskSecretOption(section.settings[i])
+		}
+	}
+
+	if jsonOutput {
+		options := make(map[string][]cmd.Setting)
+		for _, section := range sections {
+			name := section.name
+			if name == "" {
+				name = "global"
+			}
+			options[name] = section.settings
+		}
+		emitJSON("config", map[string]interface{}{
+			"config_file": configFile.Path,
+			"loaded":      configFile.Loaded,
+			"precedence":  configPrecedence,
+			"options":     options,
+		})
+		return
+	}
+
+	status := "loaded"
+	if !configFile.Loaded {
+		status = "not found"
+	}
+	fmt.Printf("Config file: %s (%s)\n", configFile.Path, status)
+	fmt.Printf("Precedence:  %s\n\n", strings.Join(configPrecedence, " > "))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPTION\tVALUE\tSOURCE")
+	for _, section := range sections {
+		for _, setting := range section.settings {
+			name := "-" + setting.Name
+			if section.name != "" {
+				name = section.name + " " + name
+			}
+			source := setting.Source
+			if source == cmd.SourceEnv {
+				source += " ($" + setting.Env + ")"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, setting.Value, source)
+		}
+	}
+	tw.Flush()
+}
+
+// maskSecretOption hides tokens and API keys so the output can be shared.
+func maskSecretOption(s cmd.Setting) string {
+	if s.Value != "" && (strings.HasSuffix(s.Name, "token") || s.Name == "api-key") {
+		return "********"
+	}
+	return s.Value
+}
@@ -0,0 +1,93 @@
+package dashboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// apiPrefix is the current version of the dashboard's JSON API.
+const apiPrefix = "/api/v1"
+
+// static holds the dashboard page and its assets.
+//
+//go:embed static
+var static embed.FS
+
+// dashboardAssets is the dashboard with content-hashed asset names.
+var dashboardAssets = loadAssets(static)
+
+// assets are the embedded files served under /static/. Each asset is also
+// served under a name with a hash of its content, e.g. app.3f2a9c1d.js,
+// which the page links to; those names are cached forever, so a new build
+// busts the cache only for the files that changed.
+type assets struct {
+	index  []byte            // index.html linking to the hashed names
+	files  map[string][]byte // by plain name, e.g. app.js
+	hashed map[string]string // hashed name -> plain name
+}
+
+func loadAssets(fsys embed.FS) *assets {
+	a := &assets{files: make(map[string][]byte), hashed: make(map[string]string)}
+	entries, err := fs.ReadDir(fsys, "static")
+	if err != nil {
+		panic(err)
+	}
+	var replacements []string
+	for _, entry := range entries {
+		data, err := fs.ReadFile(fsys, "static/"+entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		name := entry.Name()
+		if name == "index.html" {
+			a.index = data
+			continue
+		}
+		a.files[name] = data
+		sum := sha256.Sum256(data)
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+		a.hashed[hashed] = name
+		replacements = append(replacements, "/static/"+name+`"`, "/static/"+hashed+`"`)
+	}
+	a.index = []byte(strings.NewReplacer(replacements...).Replace(string(a.index)))
+	return a
+}
+
+// handleStatic serves the dashboard's assets. Hashed names never change
+// content and may be cached for good; plain names are revalidated.
+func handleStatic(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+	if plain, ok := dashboardAssets.hashed[name]; ok {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		name = plain
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	data, ok := dashboardAssets.files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// legacyAPI serves the unversioned /api/ paths of earlier releases from the
+// current version, marked as deprecated.
+func legacyAPI(api http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `<`+apiPrefix+strings.TrimPrefix(r.URL.Path, "/api")+`>; rel="successor-version"`)
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = apiPrefix + strings.TrimPrefix(r.URL.Path, "/api")
+		r2.URL.RawPath = ""
+		api.ServeHTTP(w, r2)
+	})
+}
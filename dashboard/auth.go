@@ -0,0 +1,160 @@
+package dashboard
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// role is what a dashboard caller may do.
+type role int
+
+const (
+	roleNone   role = iota
+	roleViewer      // read stats, audit, rules and servers
+	roleAdmin       // also change rules, servers and policy
+)
+
+func (r role) String() string {
+	switch r {
+	case roleAdmin:
+		return "admin"
+	case roleViewer:
+		return "viewer"
+	}
+	return "none"
+}
+
+// tokenCookie keeps the token a browser opened the dashboard with.
+const tokenCookie = "armour_token"
+
+// SetAccessTokens requires dashboard callers to present a token, for a
+// dashboard shared by a team: the admin token may change rules, servers and
+// policy, the viewer token may only read. Without an admin token every
+// caller is an admin, as the dashboard only listens on localhost.
+func (ds *Server) SetAccessTokens(admin, viewer string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.adminToken = admin
+	ds.viewerToken = viewer
+}
+
+// roleOf returns the role of the token sent as a bearer token or cookie.
+func (ds *Server) roleOf(r *http.Request) role {
+	ds.mu.RLock()
+	admin, viewer := ds.adminToken, ds.viewerToken
+	ds.mu.RUnlock()
+	if admin == "" {
+		return roleAdmin
+	}
+
+	token := requestToken(r)
+	switch {
+	case token == "":
+		return roleNone
+	case subtle.ConstantTimeCompare([]byte(token), []byte(admin)) == 1:
+		return roleAdmin
+	case viewer != "" && subtle.ConstantTimeCompare([]byte(token), []byte(viewer)) == 1:
+		return roleViewer
+	}
+	return roleNone
+}
+
+// requestToken returns the token sent as a bearer token or cookie.
+func requestToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		if cookie, err := r.Cookie(tokenCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+	return token
+}
+
+// newApproverKey returns a random key for approving blocked calls when the
+// dashboard has no admin token.
+func newApproverKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ApproverToken returns the token that may decide on blocked calls: the
+// admin token, or without one a key made when the proxy starts, which is
+// only printed on its stderr. Open the dashboard once with ?token=<token>.
+func (ds *Server) ApproverToken() string {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	if ds.adminToken != "" {
+		return ds.adminToken
+	}
+	return ds.approverKey
+}
+
+// canDecide checks that r may decide on an approval. The approval token only
+// names the call, and the agent sees it in the block error, so the caller
+// must also send the approver token, in a JSON request that a page on
+// another origin can't make without a preflight.
+func (ds *Server) canDecide(r *http.Request) bool {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return false
+	}
+	token := requestToken(r)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(ds.ApproverToken())) == 1
+}
+
+// requireRole checks the caller's role: the page and reads need a viewer
+// token, changes an admin token. Static assets are public. Opening a page
+// with ?token= keeps the token in a cookie and drops it from the address.
+func (ds *Server) requireRole(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token := r.URL.Query().Get("token"); token != "" && !strings.HasPrefix(r.URL.Path, "/api/") {
+			http.SetCookie(w, &http.Cookie{
+				Name:     tokenCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+			query := r.URL.Query()
+			query.Del("token")
+			target := *r.URL
+			target.RawQuery = query.Encode()
+			http.Redirect(w, r, target.RequestURI(), http.StatusSeeOther)
+			return
+		}
+
+		switch ds.roleOf(r) {
+		case roleNone:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="armour"`)
+			http.Error(w, "Unauthorized: open the dashboard with ?token=<token> or send Authorization: Bearer <token>", http.StatusUnauthorized)
+			return
+		case roleViewer:
+			if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+				http.Error(w, "Forbidden: viewers can't make changes", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleWhoAmIAPI returns the caller's role, so the page can hide the
+// controls a viewer can't use.
+func (ds *Server) handleWhoAmIAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"role": ds.roleOf(r).String()})
+}
@@ -0,0 +1,78 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+func newApprovalsDashboard(t *testing.T) (*Server, *server.ApprovalStore, *server.Approval) {
+	t.Helper()
+	ds := NewDashboardServer("127.0.0.1:0", nil, "", nil, nil, nil, nil, nil, proxy.NewLogger("error"), nil)
+	approvals := server.NewApprovalStore()
+	ds.SetApprovals(approvals)
+	approval := approvals.Request(&server.BlockError{Tool: "fs:write", BlockedBy: "rule"})
+	return ds, approvals, approval
+}
+
+func decide(ds *Server, token, contentType, auth string) int {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/approvals", strings.NewReader(`{"token":"`+token+`","decision":"once"}`))
+	req.Host = "127.0.0.1:13337"
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", "Bearer "+auth)
+	}
+	rec := httptest.NewRecorder()
+	ds.httpServer.Handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestApprovalDecisionNeedsApprover(t *testing.T) {
+	ds, approvals, approval := newApprovalsDashboard(t)
+
+	// The approval token alone, as an agent holding the block error has it
+	if code := decide(ds, approval.Token, "", ""); code != http.StatusForbidden {
+		t.Errorf("bare POST: expected 403, got %d", code)
+	}
+	if code := decide(ds, approval.Token, "application/json", ""); code != http.StatusForbidden {
+		t.Errorf("POST without the approver token: expected 403, got %d", code)
+	}
+	// A simple request another origin's page could send
+	if code := decide(ds, approval.Token, "text/plain", ds.ApproverToken()); code != http.StatusForbidden {
+		t.Errorf("text/plain POST: expected 403, got %d", code)
+	}
+	if _, ok := approvals.Get(approval.Token); !ok {
+		t.Fatal("approval decided by a rejected request")
+	}
+
+	if code := decide(ds, approval.Token, "application/json", ds.ApproverToken()); code != http.StatusOK {
+		t.Errorf("approver: expected 200, got %d", code)
+	}
+	if _, ok := approvals.Get(approval.Token); ok {
+		t.Error("approval still pending after the approver decided")
+	}
+}
+
+func TestApprovalDecisionWithAdminToken(t *testing.T) {
+	ds, _, approval := newApprovalsDashboard(t)
+	generated := ds.ApproverToken()
+	ds.SetAccessTokens("admin-secret", "viewer-secret")
+
+	if ds.ApproverToken() != "admin-secret" {
+		t.Fatalf("expected the admin token to approve, got %q", ds.ApproverToken())
+	}
+	for _, token := range []string{generated, "viewer-secret"} {
+		if code := decide(ds, approval.Token, "application/json", token); code != http.StatusForbidden && code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected the decision refused, got %d", token, code)
+		}
+	}
+	if code := decide(ds, approval.Token, "application/json", "admin-secret"); code != http.StatusOK {
+		t.Errorf("admin: expected 200, got %d", code)
+	}
+}
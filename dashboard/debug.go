@@ -0,0 +1,93 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/user/mcp-go-proxy/server"
+)
+
+// handleDebugAPI returns the proxy's self-metrics: goroutines, heap, running
+// subprocesses, database size and trace buffer occupancy.
+func (ds *Server) handleDebugAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ds.mu.RLock()
+	backends := ds.backends
+	ds.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(server.CollectSelfMetrics(r.Context(), ds.db, backends, ds.trace))
+}
+
+// handleChaosAPI lists the faults injected into backends (GET), injects one
+// (POST, a server.ChaosFault) or removes a server's fault (DELETE ?server=).
+// It is only available when the proxy was started with -chaos.
+func (ds *Server) handleChaosAPI(w http.ResponseWriter, r *http.Request) {
+	ds.mu.RLock()
+	backends := ds.backends
+	ds.mu.RUnlock()
+
+	if backends == nil || !backends.ChaosEnabled() {
+		http.Error(w, server.ErrChaosDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var fault server.ChaosFault
+		if err := json.NewDecoder(r.Body).Decode(&fault); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := backends.SetChaosFault(fault); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, server.ErrChaosDisabled) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		ds.logger.Warn("chaos: injecting faults into %s (latency %dms, error rate %.2f, drop rate %.2f)", fault.Server, fault.LatencyMs, fault.ErrorRate, fault.DropRate)
+	case http.MethodDelete:
+		name := r.URL.Query().Get("server")
+		if name == "" {
+			http.Error(w, "server is required", http.StatusBadRequest)
+			return
+		}
+		backends.ClearChaosFault(name)
+		ds.logger.Info("chaos: cleared faults of %s", name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"faults": backends.ChaosFaults()})
+}
+
+// pprofHandler serves the Go runtime profiles under /api/v1/debug/pprof/.
+// Profiles can hold secrets from memory, so only admins may fetch them.
+func (ds *Server) pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	profiles := http.StripPrefix(apiPrefix, mux)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ds.roleOf(r) != roleAdmin {
+			http.Error(w, "Forbidden: profiles need the admin token", http.StatusForbidden)
+			return
+		}
+		profiles.ServeHTTP(w, r)
+	})
+}
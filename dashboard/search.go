@@ -0,0 +1,223 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/user/mcp-go-proxy/proxy"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+// searchLimit caps the results of each kind returned by /api/v1/search.
+const searchLimit = 8
+
+// searchResult is one match of a global search, with the dashboard page
+// that shows it.
+type searchResult struct {
+	Kind   string `json:"kind"` // tool, rule, server, audit or trace
+	Title  string `json:"title"`
+	Detail string `json:"detail,omitempty"`
+	Link   string `json:"link"`
+}
+
+// handleSearchAPI searches tool names, rule patterns, server names and the
+// recent audit log for ?q=, for the dashboard's command palette. A request
+// ID also finds the trace events of that call.
+func (ds *Server) handleSearchAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	rules := ds.fetchRules()
+	results := []searchResult{}
+	results = append(results, searchTools(ds.toolCatalog(rules), query)...)
+	results = append(results, searchRules(rules, query)...)
+
+	ds.mu.RLock()
+	var servers []proxy.ServerEntry
+	if ds.registry != nil {
+		servers = append(servers, ds.registry.Servers...)
+	}
+	ds.mu.RUnlock()
+	results = append(results, searchServers(servers, query)...)
+
+	if ds.db != nil {
+		entries, err := server.QueryAuditLog(ds.db, server.AuditFilter{Query: query, Limit: searchLimit})
+		if err != nil {
+			ds.logger.Error("failed to search audit log: %v", err)
+		}
+		results = append(results, searchAudit(entries)...)
+	}
+	results = append(results, ds.searchTraces(query)...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   query,
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// matches reports whether any of fields contains query, ignoring case.
+func matches(query string, fields ...string) bool {
+	query = strings.ToLower(query)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func searchTools(tools []catalogTool, query string) []searchResult {
+	var results []searchResult
+	for _, tool := range tools {
+		if len(results) == searchLimit {
+			break
+		}
+		if !matches(query, tool.Name, tool.Description) {
+			continue
+		}
+		detail := tool.Risk
+		if tool.Server != "" {
+			detail = tool.Server + " · " + detail
+		}
+		results = append(results, searchResult{
+			Kind:   "tool",
+			Title:  tool.Name,
+			Detail: detail,
+			Link:   "/tools?tool=" + url.QueryEscape(tool.Name),
+		})
+	}
+	return results
+}
+
+func searchRules(rules []server.Rule, query string) []searchResult {
+	var results []searchResult
+	for _, rule := range rules {
+		if len(results) == searchLimit {
+			break
+		}
+		if !matches(query, rule.Name, rule.Pattern, rule.Tools) {
+			continue
+		}
+		title := rule.Pattern
+		if title == "" || rule.BlockAll {
+			title = rule.Name
+		}
+		tools := rule.Tools
+		if strings.TrimSpace(tools) == "" {
+			tools = "all tools"
+		}
+		results = append(results, searchResult{
+			Kind:   "rule",
+			Title:  title,
+			Detail: fmt.Sprintf("#%d · %s · %s", rule.ID, rule.Action, tools),
+			Link:   fmt.Sprintf("/#rule-%d", rule.ID),
+		})
+	}
+	return results
+}
+
+func searchServers(servers []proxy.ServerEntry, query string) []searchResult {
+	var results []searchResult
+	for _, entry := range servers {
+		if len(results) == searchLimit {
+			break
+		}
+		if !matches(query, append([]string{entry.Name}, entry.Tags...)...) {
+			continue
+		}
+		detail := entry.Transport
+		if len(entry.Tags) > 0 {
+			detail += " · " + strings.Join(entry.Tags, ", ")
+		}
+		results = append(results, searchResult{
+			Kind:   "server",
+			Title:  entry.Name,
+			Detail: detail,
+			Link:   "/#server-" + url.PathEscape(entry.Name),
+		})
+	}
+	return results
+}
+
+// searchAudit lists audit entries newest first, linking to their tool.
+func searchAudit(entries []server.AuditEntry) []searchResult {
+	results := make([]searchResult, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		name := e.ToolName
+		if name == "" {
+			name = e.Method
+		}
+		status := "allowed"
+		if e.Blocked {
+			status = "blocked: " + e.BlockReason
+		}
+		if e.Repo != "" {
+			status += " · " + e.Repo
+		}
+		if e.RequestID != "" {
+			status += " · " + e.RequestID
+		}
+		link := "/#server-" + url.PathEscape(e.ServerID)
+		if e.ToolName != "" {
+			link = "/tools?tool=" + url.QueryEscape(e.ToolName)
+		}
+		results = append(results, searchResult{
+			Kind:   "audit",
+			Title:  name,
+			Detail: e.Timestamp.Local().Format("Jan 2 15:04:05") + " · " + status,
+			Link:   link,
+		})
+	}
+	return results
+}
+
+// searchTraces lists the trace events of the call with request ID query:
+// the recent ones the recorder holds or, once they have scrolled out, those
+// persisted with -trace-persist.
+func (ds *Server) searchTraces(requestID string) []searchResult {
+	var events []proxy.TraceEvent
+	if ds.trace != nil {
+		for _, ev := range ds.trace.List() {
+			if ev.RequestID == requestID {
+				events = append(events, ev)
+			}
+		}
+	}
+	if len(events) == 0 && ds.db != nil {
+		stored, err := server.QueryStoredTraces(ds.db, server.TraceQuery{RequestID: requestID, Limit: searchLimit})
+		if err != nil {
+			ds.logger.Error("failed to search trace events: %v", err)
+		}
+		events = stored
+	}
+	if len(events) > searchLimit {
+		events = events[len(events)-searchLimit:]
+	}
+	results := make([]searchResult, 0, len(events))
+	for _, ev := range events {
+		detail := ev.Time.Local().Format("Jan 2 15:04:05") + " · " + ev.Detail
+		if ev.Error {
+			detail += " · failed"
+		}
+		results = append(results, searchResult{
+			Kind:   "trace",
+			Title:  ev.Stage + " " + ev.Server,
+			Detail: detail,
+			Link:   "/api/v1/trace/history?request_id=" + url.QueryEscape(requestID),
+		})
+	}
+	return results
+}
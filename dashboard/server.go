@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -31,9 +32,27 @@ type Server struct {
 	policyManager *server.PolicyManager
 	blocklist     *server.BlocklistMiddleware
 	toolRegistry  *server.ToolRegistry
+	backends      *server.BackendManager
+	queue         *server.WorkQueue
+	approvals     *server.ApprovalStore
+	killSwitch    *server.KillSwitch
+	offline       *server.OfflineSwitch
+	undo          *server.UndoJournal
+	discovery     *server.DiscoveryGate
+	clients       *server.ClientConfigReport
+	toolClasses   server.ToolClasses
+	adminToken    string
+	viewerToken   string
+	approverKey   string
 	db            *sql.DB
 	logger        *proxy.Logger
 	trace         *proxy.TraceRecorder
+	security      *proxy.SecurityManager
+
+	// settingsMu serializes read-modify-write of Claude's settings.json
+	settingsMu    sync.Mutex
+	settingsWatch *settingsWatcher
+	stopWatch     chan struct{}
 
 	mu sync.RWMutex
 }
@@ -62,38 +81,139 @@ func NewDashboardServer(
 		db:            db,
 		logger:        logger,
 		trace:         trace,
+		security:      proxy.NewSecurityManagerForListener(listenAddr, nil, nil),
+		approverKey:   newApproverKey(),
 	}
 
 	// Setup HTTP routes
 	mux := http.NewServeMux()
 
-	// API endpoints
-	mux.HandleFunc("/api/servers", ds.handleServersAPI)
-	mux.HandleFunc("/api/servers/", ds.handleServerDetailAPI)
-	mux.HandleFunc("/api/policy", ds.handlePolicyAPI)
-	mux.HandleFunc("/api/permissions", ds.handlePermissionsAPI)
-	mux.HandleFunc("/api/blocklist", ds.handleBlocklistAPI)
-	mux.HandleFunc("/api/tools", ds.handleToolsAPI)
-	mux.HandleFunc("/api/stats", ds.handleStatsAPI)
-	mux.HandleFunc("/api/audit", ds.handleAuditAPI)
-	mux.HandleFunc("/api/health", ds.handleHealthAPI)
-	mux.HandleFunc("/api/trace", ds.handleTraceAPI)
+	// API endpoints, versioned under /api/v1
+	api := http.NewServeMux()
+	api.HandleFunc(apiPrefix+"/servers", ds.handleServersAPI)
+	api.HandleFunc(apiPrefix+"/servers/", ds.handleServerDetailAPI)
+	api.HandleFunc(apiPrefix+"/policy", ds.handlePolicyAPI)
+	api.HandleFunc(apiPrefix+"/policy/simulate", ds.handlePolicySimulateAPI)
+	api.HandleFunc(apiPrefix+"/permissions", ds.handlePermissionsAPI)
+	api.HandleFunc(apiPrefix+"/blocklist", ds.handleBlocklistAPI)
+	api.HandleFunc(apiPrefix+"/permission-sync", ds.handlePermissionSyncAPI)
+	api.HandleFunc(apiPrefix+"/tools", ds.handleToolsAPI)
+	api.HandleFunc(apiPrefix+"/search", ds.handleSearchAPI)
+	api.HandleFunc(apiPrefix+"/whoami", ds.handleWhoAmIAPI)
+	api.HandleFunc(apiPrefix+"/stats", ds.handleStatsAPI)
+	api.HandleFunc(apiPrefix+"/audit", ds.handleAuditAPI)
+	api.HandleFunc(apiPrefix+"/audit/purge", ds.handleAuditPurgeAPI)
+	api.HandleFunc(apiPrefix+"/sessions", ds.handleSessionsAPI)
+	api.HandleFunc(apiPrefix+"/sessions/", ds.handleSessionDetailAPI)
+	api.HandleFunc(apiPrefix+"/health", ds.handleHealthAPI)
+	api.HandleFunc(apiPrefix+"/trace", ds.handleTraceAPI)
+	api.HandleFunc(apiPrefix+"/trace/history", ds.handleTraceHistoryAPI)
+	api.HandleFunc(apiPrefix+"/debug", ds.handleDebugAPI)
+	api.Handle(apiPrefix+"/debug/pprof/", ds.pprofHandler())
+	api.HandleFunc(apiPrefix+"/debug/chaos", ds.handleChaosAPI)
+	api.HandleFunc(apiPrefix+"/org-policy", ds.handleOrgPolicyAPI)
+	api.HandleFunc(apiPrefix+"/approvals", ds.handleApprovalsAPI)
+	api.HandleFunc(apiPrefix+"/approvals/ws", ds.handleApprovalsSocket)
+	api.HandleFunc(apiPrefix+"/killswitch", ds.handleKillSwitchAPI)
+	api.HandleFunc(apiPrefix+"/offline", ds.handleOfflineAPI)
+	api.HandleFunc(apiPrefix+"/undo", ds.handleUndoAPI)
+	api.HandleFunc(apiPrefix+"/discovered", ds.handleDiscoveredAPI)
+	api.HandleFunc(apiPrefix+"/packages/unlock", ds.handleUnlockPackageAPI)
+	mux.Handle(apiPrefix+"/", api)
+	mux.Handle("/api/", legacyAPI(api))
 
 	// UI endpoints
-	mux.HandleFunc("/", ds.handleDashboardUI)
-	mux.HandleFunc("/dashboard", ds.handleDashboardUI)
-	mux.HandleFunc("/blocklist", ds.handleBlocklistUI)
-	mux.HandleFunc("/audit", ds.handleAuditUI)
-	mux.HandleFunc("/settings", ds.handleSettingsUI)
+	mux.HandleFunc("/static/", handleStatic)
+	mux.HandleFunc("/", ds.handleUI)
+	mux.HandleFunc("/dashboard", ds.handleUI)
+	mux.HandleFunc("/blocklist", ds.handleUI)
+	mux.HandleFunc("/audit", ds.handleUI)
+	mux.HandleFunc("/settings", ds.handleUI)
+	mux.HandleFunc("/approvals", ds.handleUI)
+	mux.HandleFunc("/tools", ds.handleUI)
 
 	ds.httpServer = &http.Server{
 		Addr:    listenAddr,
-		Handler: mux,
+		Handler: ds.security.Middleware(ds.requireRole(mux)),
 	}
 
 	return ds
 }
 
+// AllowOrigins accepts additional browser origins and Host headers. By
+// default only localhost is accepted, which blocks DNS-rebinding attacks.
+func (ds *Server) AllowOrigins(origins, hosts []string) {
+	for _, origin := range origins {
+		ds.security.AddAllowedOrigin(strings.TrimSuffix(origin, "/"))
+	}
+	for _, host := range hosts {
+		ds.security.AddAllowedHost(host)
+	}
+}
+
+// SetBackendManager enables the backend summary in /api/v1/health.
+func (ds *Server) SetBackendManager(backends *server.BackendManager) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.backends = backends
+}
+
+// SetWorkQueue enables queue depth metrics in /api/v1/health.
+func (ds *Server) SetWorkQueue(queue *server.WorkQueue) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.queue = queue
+}
+
+// SetApprovals enables the approval links handed out in block errors.
+func (ds *Server) SetApprovals(approvals *server.ApprovalStore) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.approvals = approvals
+}
+
+// SetKillSwitch enables the panic button.
+func (ds *Server) SetKillSwitch(killSwitch *server.KillSwitch) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.killSwitch = killSwitch
+}
+
+// SetOfflineSwitch enables the offline mode toggle.
+func (ds *Server) SetOfflineSwitch(offline *server.OfflineSwitch) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.offline = offline
+}
+
+// SetUndoJournal enables restoring files changed by tool calls.
+func (ds *Server) SetUndoJournal(undo *server.UndoJournal) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.undo = undo
+}
+
+// SetDiscoveryGate enables approving servers found by discovery.
+func (ds *Server) SetDiscoveryGate(gate *server.DiscoveryGate) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.discovery = gate
+}
+
+// SetClientConfigs reports the startup client config check in /api/v1/health.
+func (ds *Server) SetClientConfigs(clients *server.ClientConfigReport) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.clients = clients
+}
+
+// SetToolClasses sets the tool class overrides shown in the tool catalog.
+func (ds *Server) SetToolClasses(classes server.ToolClasses) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.toolClasses = classes
+}
+
 // Start starts the dashboard server.
 func (ds *Server) Start() error {
 	listener, err := net.Listen("tcp", ds.listenAddr)
@@ -104,6 +224,12 @@ func (ds *Server) Start() error {
 	ds.listener = listener
 	ds.logger.Info("dashboard server started on http://%s", listener.Addr())
 
+	if settingsPath, err := claudeSettingsPath(); err == nil {
+		ds.settingsWatch = newSettingsWatcher(settingsPath)
+		ds.stopWatch = make(chan struct{})
+		go ds.settingsWatch.run(ds.stopWatch)
+	}
+
 	go func() {
 		if err := ds.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			ds.logger.Error("dashboard server error: %v", err)
@@ -115,6 +241,10 @@ func (ds *Server) Start() error {
 
 // Stop stops the dashboard server.
 func (ds *Server) Stop() error {
+	if ds.stopWatch != nil {
+		close(ds.stopWatch)
+		ds.stopWatch = nil
+	}
 	if ds.httpServer != nil {
 		return ds.httpServer.Close()
 	}
@@ -141,6 +271,7 @@ func (ds *Server) handleListServers(w http.ResponseWriter) {
 	if ds.registry != nil {
 		servers = append([]proxy.ServerEntry{}, ds.registry.Servers...)
 	}
+	backends := ds.backends
 	ds.mu.RUnlock()
 
 	response := map[string]interface{}{
@@ -148,6 +279,22 @@ func (ds *Server) handleListServers(w http.ResponseWriter) {
 		"servers": servers,
 		"path":    ds.configPath,
 	}
+	if backends != nil {
+		// Restart counts of stdio servers whose process exited, by name
+		response["restarts"] = backends.RestartStatus()
+		// Where each started server came from and the hash of its binary
+		if provenance, err := backends.Provenance(); err == nil {
+			response["provenance"] = provenance
+		} else {
+			ds.logger.Warn("failed to list server provenance: %v", err)
+		}
+		// Package versions npx/uvx servers are locked to
+		if locks, err := backends.PackageLocks(); err == nil {
+			response["packages"] = locks
+		} else {
+			ds.logger.Warn("failed to list package locks: %v", err)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -245,7 +392,11 @@ func (ds *Server) handleRegisterServer(w http.ResponseWriter, r *http.Request) {
 
 // handleServerDetailAPI handles individual server details and actions.
 func (ds *Server) handleServerDetailAPI(w http.ResponseWriter, r *http.Request) {
-	serverID := r.URL.Path[len("/api/servers/"):]
+	serverID := r.URL.Path[len(apiPrefix+"/servers/"):]
+	if id, ok := strings.CutSuffix(serverID, "/logs"); ok {
+		ds.handleServerLogsAPI(w, r, id)
+		return
+	}
 
 	if serverID == "" {
 		http.Error(w, "Server ID required", http.StatusBadRequest)
@@ -265,10 +416,21 @@ func (ds *Server) handleServerDetailAPI(w http.ResponseWriter, r *http.Request)
 
 	switch r.Method {
 	case http.MethodGet:
-		// Return server details
+		// Return server details with its keepalive health, if connected
 		response := map[string]interface{}{
 			"server": server,
-			"status": "running", // TODO: Track actual status
+			"status": "not connected",
+		}
+		ds.mu.RLock()
+		backends := ds.backends
+		ds.mu.RUnlock()
+		if backends != nil {
+			for _, h := range backends.BackendHealth() {
+				if h.Name == serverID {
+					response["status"] = h.Status
+					response["health"] = h
+				}
+			}
 		}
 		json.NewEncoder(w).Encode(response)
 
@@ -285,6 +447,35 @@ func (ds *Server) handleServerDetailAPI(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleServerLogsAPI returns the captured stderr of a stdio backend,
+// oldest line first. ?lines=N returns only the last N lines.
+func (ds *Server) handleServerLogsAPI(w http.ResponseWriter, r *http.Request, serverID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ds.mu.RLock()
+	entry := ds.registry.GetServer(serverID)
+	backends := ds.backends
+	ds.mu.RUnlock()
+	if entry == nil {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	lines := []string{}
+	if backends != nil {
+		n, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+		lines = backends.BackendLogs(serverID, n)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"server": serverID,
+		"lines":  lines,
+	})
+}
+
 // handlePolicyAPI gets/sets the policy mode.
 func (ds *Server) handlePolicyAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -299,6 +490,15 @@ func (ds *Server) handlePolicyAPI(w http.ResponseWriter, r *http.Request) {
 			"mode":        mode,
 			"description": desc,
 		}
+		// With scheduled modes, mode is the one in force and base_mode the
+		// one set here, which applies outside them
+		if schedule := ds.policyManager.GetModeSchedule(); !schedule.IsZero() {
+			response["base_mode"] = ds.policyManager.GetBaseMode()
+			response["schedule"] = schedule
+			if next := schedule.NextChange(time.Now()); !next.IsZero() {
+				response["next_change"] = next
+			}
+		}
 
 		json.NewEncoder(w).Encode(response)
 
@@ -331,6 +531,9 @@ func (ds *Server) handlePolicyAPI(w http.ResponseWriter, r *http.Request) {
 }
 
 // handlePermissionsAPI manages native tool permission rules in Claude settings.json.
+// Responses carry the file's version. A PUT with the version it was based on
+// is merged into the current file if settings.json changed since, unless a
+// rule it sets was changed there too (409 with the conflicts).
 func (ds *Server) handlePermissionsAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -343,26 +546,30 @@ func (ds *Server) handlePermissionsAPI(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		settings, err := loadSettings(settingsPath)
+		settings, version, err := loadSettings(settingsPath)
 		if err != nil {
 			ds.logger.Error("failed to load settings: %v", err)
 			http.Error(w, "Failed to load settings", http.StatusInternalServerError)
 			return
 		}
 
-		permissions := extractPermissions(settings)
 		response := map[string]interface{}{
 			"path":        settingsPath,
-			"permissions": permissions,
+			"version":     version,
+			"permissions": extractPermissions(settings),
+		}
+		if ds.settingsWatch != nil {
+			ds.settingsWatch.observe(version)
+			if changed := ds.settingsWatch.changedExternally(); !changed.IsZero() {
+				response["changed_externally_at"] = changed
+			}
 		}
 		json.NewEncoder(w).Encode(response)
 
 	case http.MethodPut:
 		var req struct {
-			Rules []struct {
-				Tool string `json:"tool"`
-				Mode string `json:"mode"`
-			} `json:"rules"`
+			Version *string          `json:"version"` // omitted: apply to whatever is on disk
+			Rules   []permissionRule `json:"rules"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -370,12 +577,32 @@ func (ds *Server) handlePermissionsAPI(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		settings, err := loadSettings(settingsPath)
+		ds.settingsMu.Lock()
+		defer ds.settingsMu.Unlock()
+
+		settings, version, err := loadSettings(settingsPath)
 		if err != nil {
 			ds.logger.Error("failed to load settings: %v", err)
 			http.Error(w, "Failed to load settings", http.StatusInternalServerError)
 			return
 		}
+		if ds.settingsWatch != nil {
+			ds.settingsWatch.observe(version)
+		}
+
+		merged := req.Version != nil && *req.Version != version
+		if merged {
+			if conflicts := permissionConflicts(settings, req.Rules); len(conflicts) > 0 {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"error":       "settings.json was changed outside the dashboard",
+					"conflicts":   conflicts,
+					"version":     version,
+					"permissions": extractPermissions(settings),
+				})
+				return
+			}
+		}
 
 		updated, err := applyPermissionRules(settings, req.Rules)
 		if err != nil {
@@ -383,15 +610,22 @@ func (ds *Server) handlePermissionsAPI(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := saveSettings(settingsPath, updated); err != nil {
+		version, err = saveSettings(settingsPath, updated)
+		if err != nil {
 			ds.logger.Error("failed to save settings: %v", err)
 			http.Error(w, "Failed to save settings", http.StatusInternalServerError)
 			return
 		}
+		if ds.settingsWatch != nil {
+			ds.settingsWatch.wrote(version)
+		}
 
 		response := map[string]interface{}{
-			"status": "success",
-			"path":   settingsPath,
+			"status":      "success",
+			"path":        settingsPath,
+			"version":     version,
+			"merged":      merged,
+			"permissions": extractPermissions(updated),
 		}
 		json.NewEncoder(w).Encode(response)
 
@@ -431,16 +665,20 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 		if ruleIDStr != "" {
 			// Single rule - transform to dashboard format
 			var rule struct {
-				ID         int    `json:"id"`
-				Name       string `json:"name"`
-				Pattern    string `json:"pattern"`
-				Topics     string `json:"topics"`
-				Tools      string `json:"tools"`
-				Scope      string `json:"scope"`
-				Action     string `json:"action"`
-				IsRegex    bool   `json:"is_regex"`
-				IsSemantic bool   `json:"is_semantic"`
-				Enabled    bool   `json:"enabled"`
+				ID             int        `json:"id"`
+				Name           string     `json:"name"`
+				Pattern        string     `json:"pattern"`
+				Topics         string     `json:"topics"`
+				Tools          string     `json:"tools"`
+				Scope          string     `json:"scope"`
+				Action         string     `json:"action"`
+				IsRegex        bool       `json:"is_regex"`
+				IsSemantic     bool       `json:"is_semantic"`
+				Schedule       string     `json:"schedule"`
+				Enabled        bool       `json:"enabled"`
+				Active         bool       `json:"active"`
+				NextActivation *time.Time `json:"next_activation"`
+				ActiveUntil    *time.Time `json:"active_until"`
 			}
 			if err := json.NewDecoder(resp.Body).Decode(&rule); err != nil {
 				http.Error(w, "Failed to parse rule", http.StatusInternalServerError)
@@ -448,14 +686,18 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			}
 
 			dashboardRule := map[string]interface{}{
-				"id":          rule.ID,
-				"pattern":     rule.Pattern,
-				"description": rule.Name,
-				"action":      rule.Action,
-				"is_regex":    rule.IsRegex,
-				"is_semantic": rule.IsSemantic,
-				"tools":       rule.Tools,
-				"enabled":     rule.Enabled,
+				"id":              rule.ID,
+				"pattern":         rule.Pattern,
+				"description":     rule.Name,
+				"action":          rule.Action,
+				"is_regex":        rule.IsRegex,
+				"is_semantic":     rule.IsSemantic,
+				"tools":           rule.Tools,
+				"schedule":        rule.Schedule,
+				"enabled":         rule.Enabled,
+				"active":          rule.Active,
+				"next_activation": rule.NextActivation,
+				"active_until":    rule.ActiveUntil,
 			}
 			json.NewEncoder(w).Encode(dashboardRule)
 			return
@@ -464,16 +706,20 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 		// List all rules - transform to dashboard format
 		var rulesResp struct {
 			Rules []struct {
-				ID         int    `json:"id"`
-				Name       string `json:"name"`
-				Pattern    string `json:"pattern"`
-				Topics     string `json:"topics"`
-				Tools      string `json:"tools"`
-				Scope      string `json:"scope"`
-				Action     string `json:"action"`
-				IsRegex    bool   `json:"is_regex"`
-				IsSemantic bool   `json:"is_semantic"`
-				Enabled    bool   `json:"enabled"`
+				ID             int        `json:"id"`
+				Name           string     `json:"name"`
+				Pattern        string     `json:"pattern"`
+				Topics         string     `json:"topics"`
+				Tools          string     `json:"tools"`
+				Scope          string     `json:"scope"`
+				Action         string     `json:"action"`
+				IsRegex        bool       `json:"is_regex"`
+				IsSemantic     bool       `json:"is_semantic"`
+				Schedule       string     `json:"schedule"`
+				Enabled        bool       `json:"enabled"`
+				Active         bool       `json:"active"`
+				NextActivation *time.Time `json:"next_activation"`
+				ActiveUntil    *time.Time `json:"active_until"`
 			} `json:"rules"`
 			Count int `json:"count"`
 		}
@@ -486,14 +732,18 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 		var dashboardRules []map[string]interface{}
 		for _, rule := range rules {
 			dashboardRules = append(dashboardRules, map[string]interface{}{
-				"id":          rule.ID,
-				"pattern":     rule.Pattern,
-				"description": rule.Name,
-				"action":      rule.Action,
-				"is_regex":    rule.IsRegex,
-				"is_semantic": rule.IsSemantic,
-				"tools":       rule.Tools,
-				"enabled":     rule.Enabled,
+				"id":              rule.ID,
+				"pattern":         rule.Pattern,
+				"description":     rule.Name,
+				"action":          rule.Action,
+				"is_regex":        rule.IsRegex,
+				"is_semantic":     rule.IsSemantic,
+				"tools":           rule.Tools,
+				"schedule":        rule.Schedule,
+				"enabled":         rule.Enabled,
+				"active":          rule.Active,
+				"next_activation": rule.NextActivation,
+				"active_until":    rule.ActiveUntil,
 			})
 		}
 
@@ -513,6 +763,7 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			IsRegex     bool   `json:"is_regex"`
 			IsSemantic  bool   `json:"is_semantic"`
 			Tools       string `json:"tools"`
+			Schedule    string `json:"schedule,omitempty"`
 			Enabled     *bool  `json:"enabled,omitempty"`
 		}
 
@@ -547,6 +798,7 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			"action":      action,
 			"is_regex":    req.IsRegex,
 			"is_semantic": req.IsSemantic,
+			"schedule":    req.Schedule,
 		}
 
 		body, _ := json.Marshal(rulesReq)
@@ -574,6 +826,7 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			Action     string `json:"action"`
 			IsRegex    bool   `json:"is_regex"`
 			IsSemantic bool   `json:"is_semantic"`
+			Schedule   string `json:"schedule"`
 			Enabled    bool   `json:"enabled"`
 		}
 		json.NewDecoder(resp.Body).Decode(&created)
@@ -586,6 +839,7 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			"is_regex":    created.IsRegex,
 			"is_semantic": created.IsSemantic,
 			"tools":       created.Tools,
+			"schedule":    created.Schedule,
 			"enabled":     created.Enabled,
 		}
 
@@ -605,6 +859,7 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			IsRegex     bool   `json:"is_regex"`
 			IsSemantic  bool   `json:"is_semantic"`
 			Tools       string `json:"tools"`
+			Schedule    string `json:"schedule,omitempty"`
 			Enabled     *bool  `json:"enabled,omitempty"`
 		}
 
@@ -644,6 +899,7 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			"action":      action,
 			"is_regex":    req.IsRegex,
 			"is_semantic": req.IsSemantic,
+			"schedule":    req.Schedule,
 			"enabled":     enabled,
 		}
 
@@ -675,6 +931,7 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			Action     string `json:"action"`
 			IsRegex    bool   `json:"is_regex"`
 			IsSemantic bool   `json:"is_semantic"`
+			Schedule   string `json:"schedule"`
 			Enabled    bool   `json:"enabled"`
 		}
 		json.NewDecoder(resp.Body).Decode(&updated)
@@ -687,6 +944,7 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 			"is_regex":    updated.IsRegex,
 			"is_semantic": updated.IsSemantic,
 			"tools":       updated.Tools,
+			"schedule":    updated.Schedule,
 			"enabled":     updated.Enabled,
 		}
 
@@ -725,37 +983,167 @@ func (ds *Server) handleBlocklistAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleToolsAPI returns list of all tools (native + MCP).
-// Tries multiple sources: tool registry, rules server, and servers.json.
+// handlePermissionSyncAPI shows and runs the sync between blocklist rules and
+// Claude settings.json permissions by proxying to the rules server.
+func (ds *Server) handlePermissionSyncAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(r.Context(), r.Method, rulesServerURL+"/api/permission-sync", r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		ds.logger.Error("failed to query rules server: %v", err)
+		http.Error(w, "Rules server unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		ds.logger.Error("rules server returned error: %s", string(body))
+		http.Error(w, strings.TrimSpace(string(body)), resp.StatusCode)
+		return
+	}
+	w.Write(body)
+}
+
+// handlePolicySimulateAPI replays the audited tool calls since ?since= (a
+// date or an age such as 7d, default 7d; at most ?limit= calls, default
+// 1000) against a proposed policy file and reports the decisions that
+// change. The body is {"policy": "<yaml>"}, with "base" for a base policy
+// file other than the current rules. The rules server does the evaluation.
+func (ds *Server) handlePolicySimulateAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ds.db == nil {
+		http.Error(w, "Audit log unavailable", http.StatusNotFound)
+		return
+	}
+
+	var req server.SimulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	since := query.Get("since")
+	if since == "" {
+		since = "7d"
+	}
+	after, err := server.ParsePurgeBefore(since, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := 1000
+	if n, err := strconv.Atoi(query.Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	entries, err := server.QueryAuditLog(ds.db, server.AuditFilter{Limit: limit})
+	if err != nil {
+		ds.logger.Error("failed to query audit log: %v", err)
+		http.Error(w, "Failed to query audit log", http.StatusInternalServerError)
+		return
+	}
+	req.Entries = server.AuditSince(entries, after)
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	httpReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, rulesServerURL+"/api/simulate", bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		ds.logger.Error("failed to query rules server: %v", err)
+		http.Error(w, "Rules server unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		http.Error(w, strings.TrimSpace(string(data)), resp.StatusCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// catalogTool is one entry of the tool catalog served by /api/v1/tools.
+type catalogTool struct {
+	Name         string                 `json:"name"`
+	Type         string                 `json:"type"` // native or mcp
+	Server       string                 `json:"server,omitempty"`
+	Description  string                 `json:"description"`
+	InputSchema  map[string]interface{} `json:"input_schema,omitempty"`
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
+	Risk         string                 `json:"risk"`
+	Hits         server.ToolHits        `json:"hits"`
+	Rules        []int                  `json:"rules"` // IDs of the rules targeting the tool
+}
+
+// nativeTools are Claude Code's built-in tools.
+var nativeTools = []struct{ name, description string }{
+	{"Bash", "Execute shell commands"},
+	{"Read", "Read files"},
+	{"Write", "Write files"},
+	{"Edit", "Edit files"},
+	{"WebFetch", "Fetch web content"},
+	{"WebSearch", "Search the web"},
+	{"Glob", "Find files by pattern"},
+	{"Grep", "Search file contents"},
+	{"Task", "Launch subagent tasks"},
+	{"TodoWrite", "Manage todo list"},
+	{"NotebookEdit", "Edit Jupyter notebooks"},
+}
+
+// handleToolsAPI returns the tool catalog.
 func (ds *Server) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Native tools (Claude Code built-ins)
-	nativeTools := []map[string]interface{}{
-		{"name": "Bash", "type": "native", "description": "Execute shell commands"},
-		{"name": "Read", "type": "native", "description": "Read files"},
-		{"name": "Write", "type": "native", "description": "Write files"},
-		{"name": "Edit", "type": "native", "description": "Edit files"},
-		{"name": "WebFetch", "type": "native", "description": "Fetch web content"},
-		{"name": "WebSearch", "type": "native", "description": "Search the web"},
-		{"name": "Glob", "type": "native", "description": "Find files by pattern"},
-		{"name": "Grep", "type": "native", "description": "Search file contents"},
-		{"name": "Task", "type": "native", "description": "Launch subagent tasks"},
-		{"name": "TodoWrite", "type": "native", "description": "Manage todo list"},
-		{"name": "NotebookEdit", "type": "native", "description": "Edit Jupyter notebooks"},
+	tools := ds.toolCatalog(ds.fetchRules())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tools": tools,
+		"count": len(tools),
+	})
+}
+
+// toolCatalog lists native tools and MCP tools with their backend, schemas,
+// risk class, call counts and the rules among rules that target them.
+// Tries multiple sources for MCP tools: tool registry, discovered tools,
+// rules server, and servers.json.
+func (ds *Server) toolCatalog(rules []server.Rule) []catalogTool {
+	tools := make([]catalogTool, 0, len(nativeTools))
+	for _, t := range nativeTools {
+		tools = append(tools, catalogTool{Name: t.name, Type: "native", Description: t.description})
 	}
 
 	// Get MCP tools from multiple sources and merge them
-	mcpTools := []map[string]interface{}{}
 	seenTools := make(map[string]bool)
-
-	// Source 1: Tool registry (live tools from connected backends)
-	if ds.toolRegistry != nil {
-		registeredTools := ds.toolRegistry.ListAllTools()
-		for _, tool := range registeredTools {
+	addRegistered := func(registered []server.RegisteredTool) {
+		for _, tool := range registered {
 			if seenTools[tool.Name] {
 				continue
 			}
@@ -767,42 +1155,31 @@ func (ds *Server) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 					backendName = parts[0]
 				}
 			}
-			mcpTools = append(mcpTools, map[string]interface{}{
-				"name":        tool.Name,
-				"type":        "mcp",
-				"server":      backendName,
-				"description": tool.Description,
+			tools = append(tools, catalogTool{
+				Name:         tool.Name,
+				Type:         "mcp",
+				Server:       backendName,
+				Description:  tool.Description,
+				InputSchema:  tool.InputSchema,
+				OutputSchema: tool.OutputSchema,
 			})
 		}
 	}
 
+	// Source 1: Tool registry (live tools from connected backends)
+	if ds.toolRegistry != nil {
+		addRegistered(ds.toolRegistry.ListAllTools())
+	}
+
 	// Source 2: Persisted discovered-tools.json (includes tools from all backends)
-	if discoveredTools, err := server.LoadDiscoveredTools(); err == nil && len(discoveredTools) > 0 {
-		for _, tool := range discoveredTools {
-			if seenTools[tool.Name] {
-				continue
-			}
-			seenTools[tool.Name] = true
-			backendName := tool.BackendID
-			if backendName == "" {
-				parts := strings.SplitN(tool.Name, ":", 2)
-				if len(parts) == 2 {
-					backendName = parts[0]
-				}
-			}
-			mcpTools = append(mcpTools, map[string]interface{}{
-				"name":        tool.Name,
-				"type":        "mcp",
-				"server":      backendName,
-				"description": tool.Description,
-			})
-		}
+	if discoveredTools, err := server.LoadDiscoveredTools(); err == nil {
+		addRegistered(discoveredTools)
 	}
 
 	// If still no MCP tools, try rules server
-	if len(mcpTools) == 0 {
+	if len(seenTools) == 0 {
 		client := &http.Client{Timeout: 500 * time.Millisecond}
-		if resp, err := client.Get("http://127.0.0.1:8084/api/tools"); err == nil && resp.StatusCode == 200 {
+		if resp, err := client.Get(rulesServerURL + "/api/tools"); err == nil {
 			defer resp.Body.Close()
 			var rulesData struct {
 				MCP []struct {
@@ -810,21 +1187,17 @@ func (ds *Server) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 					Description string `json:"description"`
 				} `json:"mcp"`
 			}
-			if json.NewDecoder(resp.Body).Decode(&rulesData) == nil {
+			if resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&rulesData) == nil {
 				for _, t := range rulesData.MCP {
-					mcpTools = append(mcpTools, map[string]interface{}{
-						"name":        t.Name,
-						"type":        "mcp",
-						"server":      t.Name,
-						"description": t.Description,
-					})
+					seenTools[t.Name] = true
+					tools = append(tools, catalogTool{Name: t.Name, Type: "mcp", Server: t.Name, Description: t.Description})
 				}
 			}
 		}
 	}
 
 	// If still no MCP tools, read from servers.json (only server names, not individual tools)
-	if len(mcpTools) == 0 {
+	if len(seenTools) == 0 {
 		homeDir, _ := os.UserHomeDir()
 		serversPath := filepath.Join(homeDir, ".armour", "servers.json")
 		if data, err := os.ReadFile(serversPath); err == nil {
@@ -835,24 +1208,74 @@ func (ds *Server) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 			}
 			if json.Unmarshal(data, &config) == nil {
 				for _, srv := range config.Servers {
-					mcpTools = append(mcpTools, map[string]interface{}{
-						"name":        srv.Name,
-						"type":        "mcp",
-						"server":      srv.Name,
-						"description": fmt.Sprintf("MCP server: %s", srv.Name),
+					tools = append(tools, catalogTool{
+						Name:        srv.Name,
+						Type:        "mcp",
+						Server:      srv.Name,
+						Description: fmt.Sprintf("MCP server: %s", srv.Name),
 					})
 				}
 			}
 		}
 	}
 
-	allTools := append(nativeTools, mcpTools...)
+	ds.mu.RLock()
+	classes := ds.toolClasses
+	ds.mu.RUnlock()
+	var hits map[string]server.ToolHits
+	if ds.statsTracker != nil {
+		hits = ds.statsTracker.ToolHits()
+	}
+	for i := range tools {
+		tool := &tools[i]
+		tool.Risk = classes.Classify(server.RegisteredTool{Name: tool.Name, InputSchema: tool.InputSchema})
+		tool.Hits = hits[tool.Name]
+		tool.Rules = relatedRules(rules, tool.Name, ds.serverTags(tool.Server))
+	}
+	return tools
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"tools": allTools,
-		"count": len(allTools),
-	})
+// fetchRules lists the rules server's rules, or none if it's unavailable.
+func (ds *Server) fetchRules() []server.Rule {
+	client := &http.Client{Timeout: 500 * time.Millisecond}
+	resp, err := client.Get(rulesServerURL + "/api/rules")
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	var data struct {
+		Rules []server.Rule `json:"rules"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&data) != nil {
+		return nil
+	}
+	return data.Rules
+}
+
+// serverTags returns the tags of a registered server.
+func (ds *Server) serverTags(name string) []string {
+	if ds.registry == nil || name == "" {
+		return nil
+	}
+	if entry := ds.registry.GetServer(name); entry != nil {
+		return entry.Tags
+	}
+	return nil
+}
+
+// relatedRules returns the IDs of the rules that target a tool by name,
+// pattern or server tag. Rules for all tools aren't listed.
+func relatedRules(rules []server.Rule, toolName string, tags []string) []int {
+	ids := []int{}
+	for _, rule := range rules {
+		if t := strings.TrimSpace(rule.Tools); t == "" || t == "*" {
+			continue
+		}
+		if rule.AppliesTo(toolName, tags) {
+			ids = append(ids, rule.ID)
+		}
+	}
+	return ids
 }
 
 func normalizeBlocklistAction(action string) (string, error) {
@@ -860,7 +1283,7 @@ func normalizeBlocklistAction(action string) (string, error) {
 	if normalized == "" {
 		return "", fmt.Errorf("Action required")
 	}
-	if normalized != "block" && normalized != "allow" && normalized != "ask" {
+	if normalized != "block" && normalized != "allow" && normalized != "ask" && normalized != server.DelegateAction {
 		return "", fmt.Errorf("Invalid action: %s", action)
 	}
 	return normalized, nil
@@ -908,36 +1331,59 @@ func claudeSettingsPath() (string, error) {
 	return filepath.Join(homeDir, ".claude", "settings.json"), nil
 }
 
-func loadSettings(path string) (map[string]interface{}, error) {
+// loadSettings reads settings.json and its version.
+func loadSettings(path string) (map[string]interface{}, string, error) {
 	settings := make(map[string]interface{})
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return settings, nil
+			return settings, "", nil
 		}
-		return nil, err
+		return nil, "", err
 	}
 
+	version := settingsVersion(data)
 	if len(data) == 0 {
-		return settings, nil
+		return settings, version, nil
 	}
 
 	if err := json.Unmarshal(data, &settings); err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return settings, nil
+	return settings, version, nil
 }
 
-func saveSettings(path string, settings map[string]interface{}) error {
+// saveSettings replaces settings.json atomically, so Claude Code never reads
+// a half-written file, and returns the new version.
+func saveSettings(path string, settings map[string]interface{}) (string, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
+		return "", err
 	}
 	data, err := json.MarshalIndent(settings, "", "  ")
 	if err != nil {
-		return err
+		return "", err
 	}
 	data = append(data, '\n')
-	return os.WriteFile(path, data, 0o644)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".settings-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return settingsVersion(data), nil
 }
 
 func extractPermissions(settings map[string]interface{}) map[string][]string {
@@ -953,10 +1399,7 @@ func extractPermissions(settings map[string]interface{}) map[string][]string {
 	return permissions
 }
 
-func applyPermissionRules(settings map[string]interface{}, rules []struct {
-	Tool string `json:"tool"`
-	Mode string `json:"mode"`
-}) (map[string]interface{}, error) {
+func applyPermissionRules(settings map[string]interface{}, rules []permissionRule) (map[string]interface{}, error) {
 	if len(rules) == 0 {
 		return settings, nil
 	}
@@ -1070,12 +1513,43 @@ func (ds *Server) handleStatsAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ?window=24h&step=5m returns time series instead of the totals
+	if window := r.URL.Query().Get("window"); window != "" {
+		ds.handleStatsSeries(w, window, r.URL.Query().Get("step"))
+		return
+	}
+
 	stats := ds.statsTracker.GetStats()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
 
+// handleStatsSeries returns blocked/allowed/latency series over a window;
+// without a step, one is picked for the window.
+func (ds *Server) handleStatsSeries(w http.ResponseWriter, window, step string) {
+	windowDur, err := server.ParseSeriesDuration(window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var stepDur time.Duration
+	if step != "" {
+		if stepDur, err = server.ParseSeriesDuration(step); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	series, err := ds.statsTracker.Series(windowDur, stepDur)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
 // handleAuditAPI returns audit log entries.
 func (ds *Server) handleAuditAPI(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -1083,1158 +1557,555 @@ func (ds *Server) handleAuditAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Implement audit log retrieval
+	entries := []server.AuditEntry{}
+	if ds.db != nil {
+		found, err := server.QueryAuditLog(ds.db, server.ParseAuditFilter(r.URL.Query()))
+		if err != nil {
+			ds.logger.Error("failed to query audit log: %v", err)
+			http.Error(w, "Failed to query audit log", http.StatusInternalServerError)
+			return
+		}
+		if found != nil {
+			entries = found
+		}
+	}
+
 	response := map[string]interface{}{
-		"entries": []interface{}{},
-		"count":   0,
+		"entries": entries,
+		"count":   len(entries),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// handleHealthAPI returns health status.
-func (ds *Server) handleHealthAPI(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// handleAuditPurgeAPI deletes audit entries and trace events recorded before
+// ?before= (a date or an age such as 30d) and reports the space reclaimed.
+func (ds *Server) handleAuditPurgeAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	before, err := server.ParsePurgeBefore(r.URL.Query().Get("before"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	response := map[string]interface{}{
-		"status":  "ok",
-		"version": "1.0.17",
+	result, err := server.Purge(ds.db, ds.trace, before)
+	if err != nil {
+		ds.logger.Error("failed to purge audit log: %v", err)
+		http.Error(w, "Failed to purge audit log", http.StatusInternalServerError)
+		return
 	}
+	ds.logger.Info("purged %d audit entries and %d trace events before %s", result.Entries, result.Traces, before.Format(time.RFC3339))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(result)
 }
 
-// handleTraceAPI returns recent trace events for observability.
-func (ds *Server) handleTraceAPI(w http.ResponseWriter, r *http.Request) {
+// handleSessionsAPI lists agent sessions from the audit log, riskiest first.
+// ?active=true keeps only sessions with recent calls; ?limit caps the list.
+func (ds *Server) handleSessionsAPI(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if ds.trace == nil {
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"events": []interface{}{},
-		})
-		return
+	sessions := []server.SessionSummary{}
+	if ds.db != nil {
+		q := r.URL.Query()
+		activeOnly := q.Get("active") == "true" || q.Get("active") == "1"
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		found, err := server.ListSessions(ds.db, activeOnly, limit)
+		if err != nil {
+			ds.logger.Error("failed to list sessions: %v", err)
+			http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+			return
+		}
+		sessions = found
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"events": ds.trace.List(),
+		"sessions": sessions,
+		"count":    len(sessions),
 	})
 }
 
-// UI Handlers
-
-// handleDashboardUI serves the main dashboard page.
-func (ds *Server) handleDashboardUI(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprint(w, getUnifiedDashboardHTML())
-}
-
-// handleAuditUI serves the audit log page.
-func (ds *Server) handleAuditUI(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprint(w, getUnifiedDashboardHTML())
-}
+// handleSessionDetailAPI returns one session's summary and its audit entries.
+func (ds *Server) handleSessionDetailAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionID := r.URL.Path[len(apiPrefix+"/sessions/"):]
+	if sessionID == "" {
+		http.Error(w, "Session ID required", http.StatusBadRequest)
+		return
+	}
+	if ds.db == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
 
-// handleBlocklistUI serves the blocklist management page.
-func (ds *Server) handleBlocklistUI(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprint(w, getUnifiedDashboardHTML())
-}
+	summary, entries, err := server.GetSession(ds.db, sessionID)
+	if err != nil {
+		ds.logger.Error("failed to load session %s: %v", sessionID, err)
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+	if summary == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
 
-// handleSettingsUI serves the settings page.
-func (ds *Server) handleSettingsUI(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprint(w, getUnifiedDashboardHTML())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session": summary,
+		"entries": entries,
+	})
 }
 
-// HTML Templates
-
-func getDashboardHTML() string {
-	return `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-	<meta charset="UTF-8">
-	<meta name="viewport" content="width=device-width, initial-scale=1.0">
-	<title>MCP Proxy Dashboard</title>
-	<style>
-		* {
-			margin: 0;
-			padding: 0;
-			box-sizing: border-box;
-		}
-
-		body {
-			font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-			background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-			min-height: 100vh;
-			padding: 20px;
-		}
-
-		.container {
-			max-width: 1200px;
-			margin: 0 auto;
-		}
-
-		header {
-			background: white;
-			padding: 30px;
-			border-radius: 10px;
-			margin-bottom: 30px;
-			box-shadow: 0 4px 6px rgba(0,0,0,0.1);
-		}
-
-		h1 {
-			color: #333;
-			margin-bottom: 10px;
-		}
-
-		.stats-grid {
-			display: grid;
-			grid-template-columns: repeat(auto-fit, minmax(250px, 1fr));
-			gap: 20px;
-			margin-bottom: 30px;
-		}
-
-		.stat-card {
-			background: white;
-			padding: 20px;
-			border-radius: 10px;
-			box-shadow: 0 4px 6px rgba(0,0,0,0.1);
-		}
-
-		.stat-card h3 {
-			color: #667eea;
-			font-size: 12px;
-			text-transform: uppercase;
-			margin-bottom: 10px;
-		}
-
-		.stat-value {
-			font-size: 32px;
-			font-weight: bold;
-			color: #333;
-		}
+// handleHealthAPI returns build metadata and dependency health.
+func (ds *Server) handleHealthAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		.servers-section {
-			background: white;
-			padding: 30px;
-			border-radius: 10px;
-			box-shadow: 0 4px 6px rgba(0,0,0,0.1);
-		}
-
-		.servers-section h2 {
-			color: #333;
-			margin-bottom: 20px;
-			border-bottom: 2px solid #667eea;
-			padding-bottom: 10px;
-		}
-
-		.server-list {
-			display: grid;
-			gap: 15px;
-		}
-
-		.server-item {
-			background: #f8f9fa;
-			padding: 15px;
-			border-radius: 8px;
-			border-left: 4px solid #667eea;
-			display: flex;
-			justify-content: space-between;
-			align-items: center;
-		}
-
-		.server-info h3 {
-			color: #333;
-			margin-bottom: 5px;
-		}
-
-		.server-info p {
-			color: #666;
-			font-size: 14px;
-		}
-
-		.server-status {
-			padding: 6px 12px;
-			border-radius: 6px;
-			background: #d4edda;
-			color: #155724;
-			font-size: 12px;
-			font-weight: bold;
-		}
-
-		.nav {
-			display: flex;
-			gap: 15px;
-			margin-top: 20px;
-			padding-top: 20px;
-			border-top: 1px solid #eee;
-		}
-
-		.nav a {
-			color: #667eea;
-			text-decoration: none;
-			font-size: 14px;
-		}
-
-		.nav a:hover {
-			text-decoration: underline;
-		}
-	</style>
-</head>
-<body>
-	<div class="container">
-		<header>
-			<h1>🛡️ MCP Proxy Dashboard</h1>
-			<p style="color: #666;">Security-enhanced MCP server management</p>
-		</header>
-
-		<div class="stats-grid" id="stats">
-			<div class="stat-card">
-				<h3>Destructive Calls Blocked</h3>
-				<div class="stat-value" id="blocked-count">0</div>
-			</div>
-			<div class="stat-card">
-				<h3>Allowed Calls</h3>
-				<div class="stat-value" id="allowed-count">0</div>
-			</div>
-			<div class="stat-card">
-				<h3>Block Rate</h3>
-				<div class="stat-value" id="block-rate">0%</div>
-			</div>
-			<div class="stat-card">
-				<h3>Unique Blocked Tools</h3>
-				<div class="stat-value" id="unique-blocked">0</div>
-			</div>
-		</div>
-
-		<div class="servers-section">
-			<h2>Configured Servers</h2>
-			<div class="server-list" id="server-list">
-				<p style="color: #999;">Loading servers...</p>
-			</div>
-
-			<div class="nav">
-				<a href="/audit">📊 Audit Log</a>
-				<a href="/settings">⚙️ Settings</a>
-				<a href="https://github.com/yourusername/mcp-go-proxy" target="_blank">📖 Documentation</a>
-			</div>
-		</div>
-	</div>
-
-	<script>
-		// Load stats
-		fetch('/api/stats')
-			.then(r => r.json())
-			.then(data => {
-				document.getElementById('blocked-count').textContent = data.blocked_calls_total;
-				document.getElementById('allowed-count').textContent = data.allowed_calls_total;
-				document.getElementById('block-rate').textContent = data.block_rate.toFixed(1) + '%';
-				document.getElementById('unique-blocked').textContent = data.unique_blocked_tools;
-			});
-
-		// Load servers
-		fetch('/api/servers')
-			.then(r => r.json())
-			.then(data => {
-				const list = document.getElementById('server-list');
-				list.innerHTML = '';
-
-				if (data.servers.length === 0) {
-					list.innerHTML = '<p style="color: #999;">No servers configured</p>';
-					return;
-				}
+	ds.mu.RLock()
+	backends := ds.backends
+	queue := ds.queue
+	clients := ds.clients
+	ds.mu.RUnlock()
 
-				data.servers.forEach(server => {
-					const item = document.createElement('div');
-					item.className = 'server-item';
-					item.innerHTML = ` + "`" + `
-						<div class="server-info">
-							<h3>${server.name}</h3>
-							<p>${server.transport} • ${server.url || server.command}</p>
-						</div>
-						<div class="server-status">Running</div>
-					` + "`" + `;
-					list.appendChild(item);
-				});
-			});
-
-		// Refresh stats every 5 seconds
-		setInterval(() => {
-			fetch('/api/stats')
-				.then(r => r.json())
-				.then(data => {
-					document.getElementById('blocked-count').textContent = data.blocked_calls_total;
-					document.getElementById('allowed-count').textContent = data.allowed_calls_total;
-					document.getElementById('block-rate').textContent = data.block_rate.toFixed(1) + '%';
-				});
-		}, 5000);
-	</script>
-</body>
-</html>
-`
-}
+	rulesURL := ""
+	if ds.blocklist != nil {
+		rulesURL = ds.blocklist.RulesServerURL()
+	}
 
-func getAuditHTML() string {
-	return `
-<!DOCTYPE html>
-<html>
-<head>
-	<title>Audit Log - MCP Proxy</title>
-	<style>
-		body {
-			font-family: monospace;
-			background: #1e1e1e;
-			color: #d4d4d4;
-			padding: 20px;
-		}
-		.container { max-width: 1200px; margin: 0 auto; }
-		h1 { color: #667eea; }
-		table { width: 100%; border-collapse: collapse; margin-top: 20px; }
-		th, td { padding: 12px; text-align: left; border-bottom: 1px solid #444; }
-		th { background: #333; color: #667eea; }
-		a { color: #667eea; text-decoration: none; }
-	</style>
-</head>
-<body>
-	<div class="container">
-		<h1>📊 Audit Log</h1>
-		<p>Tool call audit trail and blocking events</p>
-		<p style="color: #888; margin-top: 20px;">(Audit logging not yet implemented)</p>
-		<p><a href="/">← Back to Dashboard</a></p>
-	</div>
-</body>
-</html>
-`
-}
+	report := server.BuildHealthReport(r.Context(), ds.db, backends, rulesURL)
+	if queue != nil {
+		stats := queue.Stats()
+		report.Queue = &stats
+	}
+	report.SetClients(clients)
 
-func getSettingsHTML() string {
-	return `
-<!DOCTYPE html>
-<html>
-<head>
-	<title>Settings - MCP Proxy</title>
-	<style>
-		body {
-			font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-			background: #f5f5f5;
-			padding: 20px;
-		}
-		.container { max-width: 800px; margin: 0 auto; background: white; padding: 30px; border-radius: 10px; }
-		h1 { color: #333; }
-		.setting { margin: 20px 0; padding: 15px; background: #f9f9f9; border-radius: 8px; }
-		.setting h3 { color: #667eea; margin-bottom: 10px; }
-		label { display: block; margin-bottom: 5px; color: #666; }
-		select, input { padding: 8px; border: 1px solid #ddd; border-radius: 4px; }
-		button { background: #667eea; color: white; padding: 10px 20px; border: none; border-radius: 4px; cursor: pointer; }
-		button:hover { background: #764ba2; }
-		a { color: #667eea; text-decoration: none; }
-	</style>
-</head>
-<body>
-	<div class="container">
-		<h1>⚙️ Settings</h1>
-
-		<div class="setting">
-			<h3>Security Policy</h3>
-			<label for="policy">Policy Mode:</label>
-			<select id="policy" onchange="updatePolicy()">
-				<option value="strict">Strict</option>
-				<option value="moderate" selected>Moderate</option>
-				<option value="permissive">Permissive</option>
-			</select>
-		</div>
-
-		<p><a href="/">← Back to Dashboard</a></p>
-	</div>
-
-	<script>
-		// Load current policy
-		fetch('/api/policy')
-			.then(r => r.json())
-			.then(data => {
-				document.getElementById('policy').value = data.mode;
-			});
-
-		function updatePolicy() {
-			const mode = document.getElementById('policy').value;
-			fetch('/api/policy', {
-				method: 'PUT',
-				headers: { 'Content-Type': 'application/json' },
-				body: JSON.stringify({ mode })
-			})
-			.then(r => r.json())
-			.then(data => alert('Policy updated: ' + data.mode));
-		}
-	</script>
-</body>
-</html>
-`
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(report.HTTPStatus())
+	json.NewEncoder(w).Encode(report)
 }
 
-func getBlocklistHTML() string {
-	return `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-	<meta charset="UTF-8">
-	<meta name="viewport" content="width=device-width, initial-scale=1.0">
-	<title>Blocklist Management - MCP Proxy</title>
-	<style>
-		* {
-			margin: 0;
-			padding: 0;
-			box-sizing: border-box;
-		}
-
-		html {
-			scroll-behavior: smooth;
-		}
-
-		body {
-			font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
-			background: #f5f5f5;
-			padding: 20px;
-			line-height: 1.6;
-			color: #333;
-		}
-
-		.container {
-			max-width: 1200px;
-			margin: 0 auto;
-		}
-
-		header {
-			background: white;
-			padding: 30px;
-			border-radius: 10px;
-			margin-bottom: 30px;
-			box-shadow: 0 2px 8px rgba(0,0,0,0.1);
-		}
-
-		h1 {
-			color: #2c3e50;
-			margin-bottom: 8px;
-			font-size: 28px;
-		}
-
-		.subtitle {
-			color: #7f8c8d;
-			margin-bottom: 20px;
-			font-size: 14px;
-		}
-
-		.btn {
-			background: #5568d3;
-			color: white;
-			padding: 12px 24px;
-			border: none;
-			border-radius: 6px;
-			cursor: pointer;
-			font-size: 14px;
-			font-weight: 500;
-			transition: background-color 0.2s ease, outline-offset 0.2s ease;
-			min-height: 44px;
-			min-width: 44px;
-			display: inline-flex;
-			align-items: center;
-			justify-content: center;
-		}
-
-		.btn:hover {
-			background: #4557c0;
-		}
-
-		.btn:focus {
-			outline: 3px solid #5568d3;
-			outline-offset: 2px;
-		}
+// handleOrgPolicyAPI reports the organization policy sync state and its read-only rules.
+func (ds *Server) handleOrgPolicyAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		.btn:active {
-			background: #3d47a8;
-		}
+	var org *server.OrgPolicySync
+	if ds.blocklist != nil {
+		org = ds.blocklist.GetOrgPolicy()
+	}
 
-		.btn:disabled {
-			background: #bdc3c7;
-			cursor: not-allowed;
-			opacity: 0.6;
-		}
+	rules := []server.BlocklistRule{}
+	if org != nil {
+		rules = org.Rules()
+	}
 
-		.btn-danger {
-			background: #e74c3c;
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    org.Status(),
+		"rules":     rules,
+		"read_only": true,
+	})
+}
 
-		.btn-danger:hover {
-			background: #c0392b;
-		}
+// handleApprovalsAPI shows the blocked call behind an approval link (GET
+// ?token=) and applies the admin's decision on it (POST {token, decision}).
+func (ds *Server) handleApprovalsAPI(w http.ResponseWriter, r *http.Request) {
+	ds.mu.RLock()
+	approvals := ds.approvals
+	ds.mu.RUnlock()
 
-		.btn-danger:focus {
-			outline: 3px solid #e74c3c;
-			outline-offset: 2px;
-		}
+	if approvals == nil {
+		http.Error(w, "Approvals are not available", http.StatusNotFound)
+		return
+	}
 
-		.btn-secondary {
-			background: #95a5a6;
+	switch r.Method {
+	case http.MethodGet:
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"approvals": approvals.List()})
+			return
 		}
-
-		.btn-secondary:hover {
-			background: #7f8c8d;
+		approval, ok := approvals.Get(token)
+		if !ok {
+			http.Error(w, "Approval not found or expired", http.StatusNotFound)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(approval)
 
-		.btn-secondary:focus {
-			outline: 3px solid #95a5a6;
-			outline-offset: 2px;
+	case http.MethodPost:
+		if !ds.canDecide(r) {
+			http.Error(w, "Forbidden: open the dashboard with the approver link the proxy printed at startup", http.StatusForbidden)
+			return
 		}
-
-		.button-group {
-			display: flex;
-			gap: 8px;
-			flex-wrap: wrap;
+		var req struct {
+			Token    string `json:"token"`
+			Decision string `json:"decision"`
 		}
-
-		table {
-			width: 100%;
-			border-collapse: collapse;
-			background: white;
-			border-radius: 10px;
-			overflow: hidden;
-			box-shadow: 0 2px 8px rgba(0,0,0,0.1);
-			margin-bottom: 20px;
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
 		}
-
-		th, td {
-			padding: 14px 15px;
-			text-align: left;
-			border-bottom: 1px solid #ecf0f1;
+		if req.Decision != server.ApproveOnce && req.Decision != server.ApproveException && req.Decision != server.ApproveDeny {
+			http.Error(w, fmt.Sprintf("decision must be %s, %s or %s", server.ApproveOnce, server.ApproveException, server.ApproveDeny), http.StatusBadRequest)
+			return
 		}
-
-		th {
-			background: #34495e;
-			color: white;
-			font-weight: 600;
-			font-size: 13px;
-			text-transform: uppercase;
-			letter-spacing: 0.5px;
+		approval, err := approvals.Decide(req.Token, req.Decision)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
 		}
-
-		tr:hover {
-			background: #f9fafb;
+		if req.Decision == server.ApproveDeny {
+			ds.logger.Info("denied %s (%s) from the dashboard", approval.Block.Tool, approval.Block.BlockedBy)
+		} else {
+			ds.logger.Info("approved %s of %s (%s) from the dashboard", req.Decision, approval.Block.Tool, approval.Block.BlockedBy)
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "success",
+			"decision": req.Decision,
+			"approval": approval,
+		})
 
-		td code {
-			background: #ecf0f1;
-			padding: 2px 6px;
-			border-radius: 3px;
-			font-family: 'Courier New', monospace;
-			font-size: 13px;
-		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-		.badge {
-			display: inline-block;
-			padding: 6px 10px;
-			border-radius: 4px;
-			font-size: 12px;
-			font-weight: 600;
-			white-space: nowrap;
-		}
+// handleApprovalsSocket pushes the approvals awaiting a decision over a
+// WebSocket: the whole list on connect and again whenever it changes.
+func (ds *Server) handleApprovalsSocket(w http.ResponseWriter, r *http.Request) {
+	ds.mu.RLock()
+	approvals := ds.approvals
+	ds.mu.RUnlock()
 
-		.badge-block {
-			background: #fadbd8;
-			color: #78281f;
-			border: 1px solid #f5b7b1;
-		}
+	if approvals == nil {
+		http.Error(w, "Approvals are not available", http.StatusNotFound)
+		return
+	}
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
 
-		.badge-allow {
-			background: #d5f4e6;
-			color: #0b5345;
-			border: 1px solid #a9dfbf;
-		}
+	changes, stop := approvals.Watch()
+	defer stop()
+	closed := make(chan struct{})
+	go func() {
+		conn.readLoop()
+		close(closed)
+	}()
 
-		.badge-regex {
-			background: #d6eaf8;
-			color: #1a3a52;
-			border: 1px solid #aed6f1;
+	// Approvals also leave the list by expiring, which isn't a change
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		data, _ := json.Marshal(map[string]interface{}{"approvals": approvals.List()})
+		if err := conn.WriteText(data); err != nil {
+			return
 		}
-
-		.badge-semantic {
-			background: #f4ecf7;
-			color: #4a235a;
-			border: 1px solid #d7bde2;
+		select {
+		case <-changes:
+		case <-ticker.C:
+		case <-closed:
+			return
 		}
+	}
+}
 
-		.badge-enabled {
-			background: #d5f4e6;
-			color: #0b5345;
-		}
+// handleKillSwitchAPI reports the kill switch (GET) and engages or releases
+// it (POST {engaged, resources, reason}).
+func (ds *Server) handleKillSwitchAPI(w http.ResponseWriter, r *http.Request) {
+	ds.mu.RLock()
+	killSwitch := ds.killSwitch
+	ds.mu.RUnlock()
 
-		.badge-disabled {
-			background: #fadbd8;
-			color: #78281f;
-		}
+	if killSwitch == nil {
+		http.Error(w, "Kill switch is not available", http.StatusNotFound)
+		return
+	}
 
-		.modal {
-			display: none;
-			position: fixed;
-			top: 0;
-			left: 0;
-			width: 100%;
-			height: 100%;
-			background: rgba(0, 0, 0, 0.5);
-			z-index: 1000;
-			align-items: center;
-			justify-content: center;
-			padding: 20px;
-		}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(killSwitch.State())
 
-		.modal.active {
-			display: flex;
+	case http.MethodPost:
+		var req server.KillSwitchState
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
 		}
-
-		.modal-overlay {
-			position: fixed;
-			top: 0;
-			left: 0;
-			right: 0;
-			bottom: 0;
-			background: rgba(0, 0, 0, 0.5);
-			z-index: 999;
+		if err := killSwitch.Set(req); err != nil {
+			ds.logger.Error("failed to set kill switch: %v", err)
+			http.Error(w, "Failed to set kill switch", http.StatusInternalServerError)
+			return
 		}
-
-		.modal-content {
-			background: white;
-			padding: 30px;
-			border-radius: 10px;
-			max-width: 500px;
-			width: 100%;
-			max-height: 90vh;
-			overflow-y: auto;
-			position: relative;
-			z-index: 1001;
-			box-shadow: 0 4px 16px rgba(0, 0, 0, 0.15);
+		if req.Engaged {
+			ds.logger.Warn("kill switch engaged from the dashboard: blocking all tool calls")
+		} else {
+			ds.logger.Info("kill switch released from the dashboard")
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(killSwitch.State())
 
-		.modal-header {
-			margin-bottom: 24px;
-			padding-bottom: 16px;
-			border-bottom: 1px solid #ecf0f1;
-		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-		.modal-header h2 {
-			color: #2c3e50;
-			font-size: 20px;
-			margin: 0;
-		}
+// handleOfflineAPI returns (GET) or sets (POST {"enabled": bool}) offline mode.
+func (ds *Server) handleOfflineAPI(w http.ResponseWriter, r *http.Request) {
+	ds.mu.RLock()
+	offline := ds.offline
+	ds.mu.RUnlock()
 
-		.modal-footer {
-			display: flex;
-			gap: 12px;
-			margin-top: 24px;
-			justify-content: flex-end;
-		}
+	if offline == nil {
+		http.Error(w, "Offline mode is not available", http.StatusNotFound)
+		return
+	}
 
-		.form-group {
-			margin-bottom: 18px;
-		}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(offline.State())
 
-		label {
-			display: block;
-			margin-bottom: 6px;
-			color: #2c3e50;
-			font-weight: 500;
-			font-size: 14px;
+	case http.MethodPost:
+		var req server.OfflineState
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
 		}
-
-		input[type="text"],
-		input[type="email"],
-		input[type="password"],
-		textarea,
-		select {
-			width: 100%;
-			padding: 10px 12px;
-			border: 1px solid #bdc3c7;
-			border-radius: 6px;
-			font-family: inherit;
-			font-size: 14px;
-			transition: border-color 0.2s ease, box-shadow 0.2s ease;
+		if err := offline.Set(req); err != nil {
+			ds.logger.Error("failed to set offline mode: %v", err)
+			http.Error(w, "Failed to set offline mode", http.StatusInternalServerError)
+			return
 		}
-
-		input[type="text"]:focus,
-		input[type="email"]:focus,
-		input[type="password"]:focus,
-		textarea:focus,
-		select:focus {
-			border-color: #5568d3;
-			box-shadow: 0 0 0 3px rgba(85, 104, 211, 0.1);
-			outline: none;
+		if req.Enabled {
+			ds.logger.Warn("offline mode turned on: blocking network-bound tool calls")
+		} else {
+			ds.logger.Info("offline mode turned off")
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(offline.State())
 
-		input[type="text"]:disabled,
-		input[type="email"]:disabled,
-		input[type="password"]:disabled,
-		textarea:disabled,
-		select:disabled {
-			background-color: #ecf0f1;
-			cursor: not-allowed;
-			opacity: 0.6;
-		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-		textarea {
-			resize: vertical;
-			min-height: 80px;
-		}
+// handleUndoAPI lists the file snapshots taken before tool calls (GET,
+// ?limit=<n>, 50 by default) and restores one (POST {"id": n}).
+func (ds *Server) handleUndoAPI(w http.ResponseWriter, r *http.Request) {
+	ds.mu.RLock()
+	undo := ds.undo
+	ds.mu.RUnlock()
 
-		.checkbox-group {
-			display: flex;
-			align-items: center;
-			gap: 10px;
-			margin-bottom: 12px;
-		}
+	if undo == nil {
+		http.Error(w, "Undo journal is not available", http.StatusNotFound)
+		return
+	}
 
-		.checkbox-label {
-			display: inline-flex;
-			align-items: center;
-			gap: 8px;
-			cursor: pointer;
-			user-select: none;
+	switch r.Method {
+	case http.MethodGet:
+		limit := 50
+		if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+			limit = min(n, 1000)
 		}
-
-		input[type="checkbox"] {
-			width: 18px;
-			height: 18px;
-			cursor: pointer;
-			accent-color: #5568d3;
+		snapshots, err := undo.List(limit)
+		if err != nil {
+			ds.logger.Error("failed to list undo snapshots: %v", err)
+			http.Error(w, "Failed to list undo snapshots", http.StatusInternalServerError)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"snapshots": snapshots})
 
-		input[type="checkbox"]:focus {
-			outline: 2px solid #5568d3;
-			outline-offset: 2px;
+	case http.MethodPost:
+		var req struct {
+			ID int64 `json:"id"`
 		}
-
-		.permissions-grid {
-			background: #f9fafb;
-			padding: 12px;
-			border-radius: 6px;
-			border: 1px solid #ecf0f1;
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
 		}
-
-		.nav {
-			margin-top: 30px;
-			padding-top: 20px;
-			border-top: 1px solid #ecf0f1;
-			display: flex;
-			gap: 20px;
-			flex-wrap: wrap;
+		snap, err := undo.Restore(req.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
 		}
+		ds.logger.Info("restored %s from the dashboard (snapshot %d of %s)", snap.Path, snap.ID, snap.ToolName)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snap)
 
-		.nav a {
-			color: #5568d3;
-			text-decoration: none;
-			font-weight: 500;
-			transition: color 0.2s ease, outline-offset 0.2s ease;
-			padding: 4px 2px;
-		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-		.nav a:hover {
-			color: #4557c0;
-			text-decoration: underline;
-		}
+// handleDiscoveredAPI lists the servers found by discovery (GET) and
+// approves or rejects one (POST {"name": ..., "decision": "approve"|"reject"}).
+func (ds *Server) handleDiscoveredAPI(w http.ResponseWriter, r *http.Request) {
+	ds.mu.RLock()
+	gate := ds.discovery
+	ds.mu.RUnlock()
 
-		.nav a:focus {
-			outline: 2px solid #5568d3;
-			outline-offset: 4px;
-		}
+	if gate == nil {
+		http.Error(w, "Discovery gate is not available", http.StatusNotFound)
+		return
+	}
 
-		.alert {
-			padding: 12px 16px;
-			border-radius: 6px;
-			margin-bottom: 16px;
-			font-size: 14px;
-			border-left: 4px solid;
+	switch r.Method {
+	case http.MethodGet:
+		servers, err := gate.List()
+		if err != nil {
+			ds.logger.Error("failed to list discovered servers: %v", err)
+			http.Error(w, "Failed to list discovered servers", http.StatusInternalServerError)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"servers": servers})
 
-		.alert-error {
-			background: #fadbd8;
-			color: #78281f;
-			border-left-color: #e74c3c;
+	case http.MethodPost:
+		var req struct {
+			Name     string `json:"name"`
+			Decision string `json:"decision"`
 		}
-
-		.alert-success {
-			background: #d5f4e6;
-			color: #0b5345;
-			border-left-color: #27ae60;
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
 		}
-
-		.skip-link {
-			position: absolute;
-			top: -40px;
-			left: 0;
-			background: #5568d3;
-			color: white;
-			padding: 8px 16px;
-			text-decoration: none;
-			border-radius: 0 0 6px 0;
-			z-index: 9999;
+		if req.Decision != "approve" && req.Decision != "reject" {
+			http.Error(w, `decision must be "approve" or "reject"`, http.StatusBadRequest)
+			return
 		}
-
-		.skip-link:focus {
-			top: 0;
+		srv, err := gate.Decide(req.Name, req.Decision == "approve")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
 		}
+		ds.logger.Info("discovered server %s %s from the dashboard", srv.Name, srv.Status)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(srv)
 
-		@media (max-width: 768px) {
-			header {
-				padding: 20px;
-			}
-
-			h1 {
-				font-size: 22px;
-			}
-
-			.btn {
-				padding: 10px 16px;
-				font-size: 13px;
-			}
-
-			table {
-				font-size: 13px;
-			}
-
-			th, td {
-				padding: 10px 12px;
-			}
-
-			.modal-content {
-				max-width: 95vw;
-				padding: 20px;
-			}
-
-			.modal-footer {
-				flex-direction: column;
-			}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-			.button-group {
-				flex-direction: column;
-			}
+// handleUnlockPackageAPI forgets the package lock of an npx/uvx server
+// (POST {"server": ...}), so its next start locks the version it resolves.
+func (ds *Server) handleUnlockPackageAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ds.mu.RLock()
+	backends := ds.backends
+	ds.mu.RUnlock()
+	if backends == nil {
+		http.Error(w, "Package locks are not available", http.StatusNotFound)
+		return
+	}
 
-			.button-group .btn {
-				width: 100%;
-			}
+	var req struct {
+		Server string `json:"server"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := backends.UnlockPackage(req.Server); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ds.logger.Info("unlocked the package of %s from the dashboard", req.Server)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "unlocked"})
+}
 
-			.nav {
-				flex-direction: column;
-				gap: 10px;
-			}
-		}
+// handleTraceAPI returns recent trace events for observability.
+func (ds *Server) handleTraceAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		@media (prefers-reduced-motion: reduce) {
-			* {
-				animation-duration: 0.01ms !important;
-				animation-iteration-count: 1 !important;
-				transition-duration: 0.01ms !important;
-			}
-		}
-	</style>
-</head>
-<body>
-	<a href="#main-content" class="skip-link">Skip to main content</a>
-
-	<div class="container">
-		<header>
-			<h1>🔒 Blocklist Management</h1>
-			<p class="subtitle">Manage tool blocklisting rules and permissions</p>
-			<button class="btn" onclick="openModal()" aria-label="Create new blocklist rule">
-				+ New Rule
-			</button>
-		</header>
-
-		<div id="notification" role="status" aria-live="polite" aria-atomic="true"></div>
-
-		<main id="main-content">
-			<table role="table" aria-label="Blocklist rules">
-				<thead>
-					<tr>
-						<th scope="col">Pattern</th>
-						<th scope="col">Description</th>
-						<th scope="col">Action</th>
-						<th scope="col">Type</th>
-						<th scope="col">Tools</th>
-						<th scope="col">Status</th>
-						<th scope="col">Actions</th>
-					</tr>
-				</thead>
-				<tbody id="rules-table">
-					<tr><td colspan="7" style="text-align: center; color: #999; padding: 30px;">Loading rules...</td></tr>
-				</tbody>
-			</table>
-		</main>
-
-		<nav class="nav" aria-label="Secondary navigation">
-			<a href="/" title="Return to main dashboard">← Back to Dashboard</a>
-			<a href="/audit" title="View system audit log">📊 Audit Log</a>
-			<a href="/settings" title="Manage system settings">⚙️ Settings</a>
-		</nav>
-	</div>
-
-	<!-- Modal for create/edit -->
-	<div class="modal" id="modal" role="dialog" aria-modal="true" aria-labelledby="modal-title">
-		<div class="modal-overlay" onclick="closeModal()"></div>
-		<div class="modal-content">
-			<div class="modal-header">
-				<h2 id="modal-title">Add New Rule</h2>
-			</div>
-			<form onsubmit="saveRule(event)">
-				<div class="form-group">
-					<label for="pattern">Pattern/Topic:</label>
-					<input type="text" id="pattern" aria-required="true" required>
-				</div>
-
-				<div class="form-group">
-					<label for="description">Description:</label>
-					<textarea id="description" aria-describedby="description-help"></textarea>
-					<small id="description-help" style="color: #7f8c8d; display: block; margin-top: 4px;">
-						Briefly explain what this rule blocks or allows
-					</small>
-				</div>
-
-				<div class="form-group">
-					<label for="action">Action:</label>
-					<select id="action" aria-required="true" onchange="updatePermissions()" required>
-						<option value="">-- Select action --</option>
-						<option value="block">Block</option>
-						<option value="allow">Allow</option>
-					</select>
-				</div>
-
-				<fieldset class="form-group">
-					<legend style="font-weight: 500; color: #2c3e50; margin-bottom: 12px;">Match Type:</legend>
-					<div class="checkbox-group">
-						<input type="checkbox" id="is_regex">
-						<label for="is_regex" class="checkbox-label">Regex pattern matching (fast)</label>
-					</div>
-					<div class="checkbox-group">
-						<input type="checkbox" id="is_semantic" checked>
-						<label for="is_semantic" class="checkbox-label">Semantic matching via Claude API (flexible)</label>
-					</div>
-				</fieldset>
-
-				<div class="form-group">
-					<label for="tools">Tools (comma-separated):</label>
-					<input type="text" id="tools" placeholder="e.g., tool1, tool2" aria-describedby="tools-help">
-					<small id="tools-help" style="color: #7f8c8d; display: block; margin-top: 4px;">
-						Leave empty to apply rule to all tools
-					</small>
-				</div>
-
-				<div class="form-group">
-					<label>Permissions:</label>
-					<div class="permissions-grid" id="permissions-grid">
-						(Permissions configured automatically)
-					</div>
-				</div>
-
-				<div class="modal-footer">
-					<button type="submit" class="btn" aria-label="Save blocklist rule">
-						Save Rule
-					</button>
-					<button type="button" class="btn btn-secondary" onclick="closeModal()" aria-label="Cancel and close dialog">
-						Cancel
-					</button>
-				</div>
-			</form>
-		</div>
-	</div>
-
-	<script>
-		let editingRuleId = null;
-
-		// Notification system
-		function showNotification(message, type = 'success') {
-			const notif = document.getElementById('notification');
-			notif.innerHTML = ` + "`" + `<div class="alert alert-${type}" role="alert">${message}</div>` + "`" + `;
-			notif.focus();
-
-			// Auto-hide after 5 seconds
-			setTimeout(() => {
-				notif.innerHTML = '';
-			}, 5000);
-		}
-
-		function loadRules() {
-			fetch('/api/blocklist')
-				.then(r => r.json())
-				.then(data => {
-					const tbody = document.getElementById('rules-table');
-					tbody.innerHTML = '';
-
-					if (!data.rules || data.rules.length === 0) {
-						tbody.innerHTML = '<tr><td colspan="7" style="text-align: center; color: #999; padding: 30px;">No rules configured</td></tr>';
-						return;
-					}
-
-					data.rules.forEach(rule => {
-						const row = document.createElement('tr');
-						const typeBadges = [];
-						if (rule.is_regex) typeBadges.push('<span class="badge badge-regex">Regex</span>');
-						if (rule.is_semantic) typeBadges.push('<span class="badge badge-semantic">Semantic</span>');
-
-						const statusBadgeClass = rule.enabled ? 'badge-enabled' : 'badge-disabled';
-						const statusText = rule.enabled ? 'Enabled' : 'Disabled';
-
-						row.innerHTML = ` + "`" + `
-							<td><code>${escapeHtml(rule.pattern)}</code></td>
-							<td>${escapeHtml(rule.description || '-')}</td>
-							<td><span class="badge ${rule.action === 'block' ? 'badge-block' : 'badge-allow'}">${rule.action}</span></td>
-							<td>${typeBadges.join(' ')}</td>
-							<td>${escapeHtml(rule.tools || 'All')}</td>
-							<td><span class="badge ${statusBadgeClass}">${statusText}</span></td>
-							<td>
-								<div class="button-group">
-									<button class="btn" onclick="editRule(${rule.id})" aria-label="Edit rule: ${escapeHtml(rule.pattern)}">
-										Edit
-									</button>
-									<button class="btn btn-danger" onclick="deleteRule(${rule.id})" aria-label="Delete rule: ${escapeHtml(rule.pattern)}">
-										Delete
-									</button>
-								</div>
-							</td>
-						` + "`" + `;
-						tbody.appendChild(row);
-					});
-				})
-				.catch(err => {
-					console.error('Failed to load rules:', err);
-					showNotification('Error loading rules: ' + err.message, 'error');
-				});
-		}
-
-		function openModal() {
-			editingRuleId = null;
-			document.getElementById('modal').classList.add('active');
-			document.getElementById('pattern').value = '';
-			document.getElementById('description').value = '';
-			document.getElementById('action').value = '';
-			document.getElementById('is_regex').checked = false;
-			document.getElementById('is_semantic').checked = true;
-			document.getElementById('tools').value = '';
-			updatePermissions();
-
-			// Focus trap: move focus to first input
-			setTimeout(() => {
-				document.getElementById('pattern').focus();
-			}, 100);
-		}
-
-		function closeModal() {
-			document.getElementById('modal').classList.remove('active');
-			// Return focus to trigger button
-			document.querySelector('[onclick="openModal()"]').focus();
-		}
-
-		// Close modal on Escape key
-		document.addEventListener('keydown', (e) => {
-			if (e.key === 'Escape' && document.getElementById('modal').classList.contains('active')) {
-				closeModal();
-			}
-		});
-
-		function editRule(ruleId) {
-			fetch('/api/blocklist?id=' + ruleId)
-				.then(r => r.json())
-				.then(rule => {
-					editingRuleId = ruleId;
-					document.getElementById('pattern').value = rule.pattern;
-					document.getElementById('description').value = rule.description || '';
-					document.getElementById('action').value = rule.action;
-					document.getElementById('is_regex').checked = rule.is_regex;
-					document.getElementById('is_semantic').checked = rule.is_semantic;
-					document.getElementById('tools').value = rule.tools || '';
-					document.getElementById('modal-title').textContent = 'Edit Rule';
-					updatePermissions();
-					document.getElementById('modal').classList.add('active');
-					document.getElementById('pattern').focus();
-				})
-				.catch(err => {
-					showNotification('Error loading rule: ' + err.message, 'error');
-				});
-		}
+	w.Header().Set("Content-Type", "application/json")
+	if ds.trace == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"events": []interface{}{},
+		})
+		return
+	}
 
-		function saveRule(event) {
-			event.preventDefault();
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": ds.trace.List(),
+	})
+}
 
-			const pattern = document.getElementById('pattern').value.trim();
-			if (!pattern) {
-				showNotification('Pattern/Topic is required', 'error');
-				return;
-			}
+// handleTraceHistoryAPI returns trace events persisted with -trace-persist,
+// filtered by ?since=, ?until=, ?server=, ?request_id=, ?errors= and ?limit=.
+func (ds *Server) handleTraceHistoryAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := server.ParseTraceQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-			const rule = {
-				pattern: pattern,
-				description: document.getElementById('description').value.trim(),
-				action: document.getElementById('action').value,
-				is_regex: document.getElementById('is_regex').checked,
-				is_semantic: document.getElementById('is_semantic').checked,
-				tools: document.getElementById('tools').value.trim()
-			};
-
-			const url = editingRuleId ? '/api/blocklist?id=' + editingRuleId : '/api/blocklist';
-			const method = editingRuleId ? 'PUT' : 'POST';
-
-			fetch(url, {
-				method: method,
-				headers: { 'Content-Type': 'application/json' },
-				body: JSON.stringify(rule)
-			})
-			.then(r => r.json())
-			.then(data => {
-				closeModal();
-				loadRules();
-				showNotification('Rule ' + (editingRuleId ? 'updated' : 'created') + ' successfully', 'success');
-			})
-			.catch(err => {
-				showNotification('Error saving rule: ' + err.message, 'error');
-			});
-		}
-
-		function deleteRule(ruleId) {
-			if (confirm('Are you sure you want to delete this rule? This action cannot be undone.')) {
-				fetch('/api/blocklist?id=' + ruleId, { method: 'DELETE' })
-					.then(r => r.json())
-					.then(data => {
-						loadRules();
-						showNotification('Rule deleted successfully', 'success');
-					})
-					.catch(err => {
-						showNotification('Error deleting rule: ' + err.message, 'error');
-					});
-			}
+	events := []proxy.TraceEvent{}
+	if ds.db != nil {
+		found, err := server.QueryStoredTraces(ds.db, q)
+		if err != nil {
+			ds.logger.Error("failed to query trace events: %v", err)
+			http.Error(w, "Failed to query trace events", http.StatusInternalServerError)
+			return
 		}
-
-		function updatePermissions() {
-			// Placeholder for permissions UI
-			document.getElementById('permissions-grid').innerHTML = '<em style="color: #7f8c8d;">Permissions are configured automatically based on action</em>';
+		if found != nil {
+			events = found
 		}
+	}
+	response := map[string]interface{}{
+		"events": events,
+		"count":  len(events),
+	}
+	if ds.trace != nil {
+		response["sampling"] = ds.trace.Sampling().String()
+	}
 
-		function escapeHtml(text) {
-			const div = document.createElement('div');
-			div.textContent = text;
-			return div.innerHTML;
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
 
-		// Load rules on page load
-		loadRules();
+// UI Handlers
 
-		// Refresh rules every 30 seconds
-		setInterval(loadRules, 30000);
-	</script>
-</body>
-</html>
-`
+// handleUI serves the single-page dashboard for each of its pages. The page
+// is never cached, so a new binary's assets are picked up on reload.
+func (ds *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(dashboardAssets.index)
 }
@@ -0,0 +1,140 @@
+package dashboard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// settingsWatchInterval is how often settings.json is checked for edits
+// made outside the dashboard, e.g. by Claude Code or an editor.
+const settingsWatchInterval = 2 * time.Second
+
+// permissionRule sets the mode of one tool in settings.json. Previous is the
+// mode the client last saw; when the file changed since, the rule is only
+// applied if the tool's rule wasn't changed by someone else too.
+type permissionRule struct {
+	Tool     string `json:"tool"`
+	Mode     string `json:"mode"`
+	Previous string `json:"previous,omitempty"`
+}
+
+// permissionConflict is a rule that was changed both in the dashboard and
+// in settings.json since the dashboard loaded it.
+type permissionConflict struct {
+	Tool   string `json:"tool"`
+	Yours  string `json:"yours"`
+	Theirs string `json:"theirs"`
+}
+
+// settingsVersion identifies the content of settings.json; a missing file is "".
+func settingsVersion(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func readSettingsVersion(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return settingsVersion(data), nil
+}
+
+// permissionMode returns the list an exact tool rule is in: deny, ask,
+// allow, or unset if none.
+func permissionMode(permissions map[string][]string, tool string) string {
+	for _, mode := range []string{"deny", "ask", "allow"} {
+		if slices.Contains(permissions[mode], tool) {
+			return mode
+		}
+	}
+	return "unset"
+}
+
+// permissionConflicts returns the rules whose tool was changed in settings
+// since the client saw it, to something other than what the rule sets.
+func permissionConflicts(settings map[string]interface{}, rules []permissionRule) []permissionConflict {
+	permissions := extractPermissions(settings)
+	var conflicts []permissionConflict
+	for _, rule := range rules {
+		if rule.Previous == "" {
+			continue
+		}
+		yours := rule.Mode
+		if yours == "" {
+			yours = "unset"
+		}
+		theirs := permissionMode(permissions, rule.Tool)
+		if theirs != rule.Previous && theirs != yours {
+			conflicts = append(conflicts, permissionConflict{Tool: rule.Tool, Yours: yours, Theirs: theirs})
+		}
+	}
+	return conflicts
+}
+
+// settingsWatcher polls settings.json and remembers when it last changed
+// other than through the dashboard.
+type settingsWatcher struct {
+	path string
+
+	mu        sync.Mutex
+	version   string
+	changedAt time.Time
+}
+
+func newSettingsWatcher(path string) *settingsWatcher {
+	version, _ := readSettingsVersion(path)
+	return &settingsWatcher{path: path, version: version}
+}
+
+// run polls until done is closed.
+func (w *settingsWatcher) run(done <-chan struct{}) {
+	ticker := time.NewTicker(settingsWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if version, err := readSettingsVersion(w.path); err == nil {
+				w.observe(version)
+			}
+		}
+	}
+}
+
+// observe records the version read from the file; any version other than
+// the last one seen or written is an external change.
+func (w *settingsWatcher) observe(version string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if version != w.version {
+		w.version = version
+		w.changedAt = time.Now().UTC()
+	}
+}
+
+// wrote records a version the dashboard wrote itself.
+func (w *settingsWatcher) wrote(version string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.version = version
+}
+
+// changedExternally returns when settings.json was last changed outside the
+// dashboard, zero if not since the dashboard started.
+func (w *settingsWatcher) changedExternally() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.changedAt
+}
@@ -0,0 +1,1497 @@
+const DEFAULT_PERMISSIONS = {
+	block: {
+		tools_call: 'deny',
+		tools_list: 'allow',
+		resources_read: 'deny',
+		resources_list: 'allow',
+		resources_subscribe: 'deny',
+		prompts_get: 'deny',
+		prompts_list: 'allow',
+		sampling: 'deny'
+	},
+	allow: {
+		tools_call: 'allow',
+		tools_list: 'allow',
+		resources_read: 'allow',
+		resources_list: 'allow',
+		resources_subscribe: 'allow',
+		prompts_get: 'allow',
+		prompts_list: 'allow',
+		sampling: 'allow'
+	},
+	ask: {
+		tools_call: 'deny',
+		tools_list: 'allow',
+		resources_read: 'deny',
+		resources_list: 'allow',
+		resources_subscribe: 'deny',
+		prompts_get: 'deny',
+		prompts_list: 'allow',
+		sampling: 'deny'
+	}
+};
+
+const NATIVE_TOOLS = [
+	{ name: 'Bash', label: 'Bash' },
+	{ name: 'Read', label: 'Read' },
+	{ name: 'Write', label: 'Write' },
+	{ name: 'Edit', label: 'Edit' },
+	{ name: 'WebFetch', label: 'WebFetch' },
+	{ name: 'WebSearch', label: 'WebSearch' }
+];
+
+const PERMISSION_LABELS = [
+	{ key: 'tools_call', label: 'Tools Call' },
+	{ key: 'tools_list', label: 'Tools List' },
+	{ key: 'resources_read', label: 'Resources Read' },
+	{ key: 'resources_list', label: 'Resources List' },
+	{ key: 'resources_subscribe', label: 'Resources Subscribe' },
+	{ key: 'prompts_get', label: 'Prompts Get' },
+	{ key: 'prompts_list', label: 'Prompts List' },
+	{ key: 'sampling', label: 'Sampling' }
+];
+
+const state = {
+	rules: [],
+	servers: [],
+	tools: [],
+	registryPath: '',
+	permissionsVersion: undefined
+};
+
+let editingRuleId = null;
+
+const toast = document.getElementById('toast');
+const drawer = document.getElementById('rule-drawer');
+const overlay = document.getElementById('overlay');
+
+function showToast(message, type) {
+	toast.textContent = message;
+	toast.className = 'toast show' + (type ? ' ' + type : '');
+	setTimeout(() => {
+		toast.className = 'toast';
+	}, 2800);
+}
+
+function fetchJSON(url, options) {
+	return fetch(url, options).then((res) => {
+		if (!res.ok) {
+			throw new Error('HTTP ' + res.status);
+		}
+		return res.json();
+	});
+}
+
+function updateLastRefresh() {
+	const stamp = new Date().toLocaleTimeString();
+	document.getElementById('last-refresh').textContent = 'Last refreshed: ' + stamp;
+}
+
+function loadStats() {
+	return fetchJSON('/api/v1/stats')
+		.then((data) => {
+			document.getElementById('blocked-count').textContent = data.blocked_calls_total || 0;
+			document.getElementById('allowed-count').textContent = data.allowed_calls_total || 0;
+			document.getElementById('block-rate').textContent = (data.block_rate || 0).toFixed(1) + '%';
+			document.getElementById('unique-blocked').textContent = data.unique_blocked_tools || 0;
+		});
+}
+
+function loadServers() {
+	return fetchJSON('/api/v1/servers')
+		.then((data) => {
+			state.servers = data.servers || [];
+			state.restarts = data.restarts || {};
+			state.provenance = {};
+			(data.provenance || []).forEach((p) => { state.provenance[p.name] = p; });
+			state.packages = {};
+			(data.packages || []).forEach((lock) => { state.packages[lock.server] = lock; });
+			state.registryPath = data.path || '';
+			document.getElementById('server-count').textContent = state.servers.length;
+			renderRegistryPath();
+			renderServers();
+		});
+}
+
+function renderRegistryPath() {
+	const pathEl = document.getElementById('server-config-path');
+	if (!pathEl) {
+		return;
+	}
+	if (state.registryPath) {
+		pathEl.textContent = state.registryPath;
+	} else {
+		pathEl.textContent = 'Not persisted — start proxy with -config to write servers.json';
+	}
+}
+
+function renderServers() {
+	const container = document.getElementById('server-list');
+	container.innerHTML = '';
+
+	if (state.servers.length === 0) {
+		container.innerHTML = '<div class="empty-state">No servers configured.</div>';
+		return;
+	}
+
+	state.servers.forEach((server) => {
+		const item = document.createElement('div');
+		item.className = 'server-item';
+		item.id = 'server-' + server.name;
+		const transport = server.transport || 'unknown';
+		const target = transport === 'stdio'
+			? [server.command, ...(server.args || [])].filter(Boolean).join(' ')
+			: (server.url || server.command || '');
+		const summary = transport + ' — ' + (target || 'not configured');
+		item.innerHTML =
+			'<div>' +
+				'<h3>' + escapeHTML(server.name) + '</h3>' +
+		'<p>' + escapeHTML(summary) + '</p>' +
+		provenanceLine((state.provenance || {})[server.name]) +
+		packageLine((state.packages || {})[server.name]) +
+	'</div>' +
+	'<div class="hero-actions" style="margin: 0;">' +
+		(transport === 'stdio' ? '<button class="btn" type="button">Logs</button>' : '') +
+		restartBadge((state.restarts || {})[server.name]) +
+		provenanceBadge((state.provenance || {})[server.name]) +
+		'<span class="badge badge-ok">' + escapeHTML(transport.toUpperCase()) + '</span>' +
+	'</div>';
+	const logsButton = item.querySelector('.hero-actions button');
+	if (logsButton) {
+		logsButton.addEventListener('click', () => toggleServerLogs(item, server.name));
+	}
+	const unlockButton = item.querySelector('[data-unlock]');
+	if (unlockButton) {
+		unlockButton.addEventListener('click', () => unlockPackage(server.name));
+	}
+	container.appendChild(item);
+});
+}
+
+// provenanceLine shows where a server came from and what binary it runs.
+function provenanceLine(p) {
+	if (!p) {
+		return '';
+	}
+	const parts = ['from ' + p.source];
+	if (p.install_path) {
+		parts.push('installed at ' + p.install_path);
+	}
+	if (p.binary_hash) {
+		parts.push(p.binary_path + ' sha256 ' + p.binary_hash.slice(0, 12));
+	}
+	parts.push('first seen ' + new Date(p.first_seen).toLocaleString());
+	return '<p class="muted" title="' + escapeHTML(p.binary_hash || '') + '">' + escapeHTML(parts.join(' · ')) + '</p>';
+}
+
+// packageLine shows the package version an npx/uvx server is locked to.
+function packageLine(lock) {
+	if (!lock) {
+		return '';
+	}
+	return '<p class="muted" title="' + escapeHTML(lock.integrity) + '">' +
+		escapeHTML(lock.launcher + ' ' + lock.package + '@' + lock.version + ' locked ' + new Date(lock.locked_at).toLocaleDateString() +
+			', verified ' + new Date(lock.verified_at).toLocaleString()) +
+		' <button class="btn btn-ghost admin-only" type="button" data-unlock="1">Unlock</button></p>';
+}
+
+function unlockPackage(name) {
+	fetchJSON('/api/v1/packages/unlock', {
+		method: 'POST',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({ server: name })
+	})
+		.then(() => {
+			showToast(name + ' unlocked; its next start locks the version it resolves', 'success');
+			return loadServers();
+		})
+		.catch((err) => showToast('Unlock failed: ' + err.message, 'error'));
+}
+
+// provenanceBadge flags a binary that changed since the server last ran, and pinned servers.
+function provenanceBadge(p) {
+	if (!p) {
+		return '';
+	}
+	if (p.hash_changed_at) {
+		return '<span class="badge badge-danger" title="sha256 ' + escapeHTML(p.previous_hash) + ' -> ' + escapeHTML(p.binary_hash) + '">Binary changed ' +
+			escapeHTML(new Date(p.hash_changed_at).toLocaleDateString()) + '</span>';
+	}
+	return p.pinned ? '<span class="badge badge-ok" title="sha256 ' + escapeHTML(p.pinned) + '">Pinned</span>' : '';
+}
+
+// restartBadge shows how often a stdio server's process was restarted,
+// and which resource limit it was last stopped for
+function restartBadge(restarts) {
+	if (!restarts) {
+		return '';
+	}
+	const limit = restarts.limit_exceeded
+		? '<span class="badge badge-danger">Exceeded ' + escapeHTML(restarts.limit_exceeded.replace('_', ' ')) + '</span>'
+		: '';
+	return limit + restartCountBadge(restarts);
+}
+
+function restartCountBadge(restarts) {
+	if (restarts.quarantined) {
+		return '<span class="badge badge-danger" title="' + escapeHTML(restarts.last_exit || '') + '">Quarantined</span>';
+	}
+	if (!restarts.restarts) {
+		return '';
+	}
+	return '<span class="badge" title="' + escapeHTML(restarts.last_exit || '') + '">Restarted ' + restarts.restarts + '×</span>';
+}
+
+// toggleServerLogs shows the last stderr lines of a stdio backend under its card
+function toggleServerLogs(item, name) {
+	const existing = item.querySelector('.server-logs');
+	if (existing) {
+		existing.remove();
+		return;
+	}
+	fetchJSON('/api/v1/servers/' + encodeURIComponent(name) + '/logs?lines=50')
+		.then((data) => {
+			const pre = document.createElement('pre');
+			pre.className = 'server-logs';
+			pre.textContent = (data.lines || []).length ? data.lines.join('\n') : 'No stderr output captured.';
+			item.appendChild(pre);
+		})
+		.catch((err) => showToast('Failed to load logs: ' + err.message, 'error'));
+}
+
+function loadPolicy() {
+	return fetchJSON('/api/v1/policy')
+		.then((data) => {
+			let mode = data.mode || 'moderate';
+			if (data.base_mode && data.base_mode !== mode) {
+				mode += ' (scheduled, otherwise ' + data.base_mode + ')';
+			}
+			if (data.next_change) {
+				mode += ', changes ' + new Date(data.next_change).toLocaleString();
+			}
+			document.getElementById('policy-mode').textContent = mode;
+		});
+}
+
+function loadOrgPolicy() {
+	return fetchJSON('/api/v1/org-policy')
+		.then((data) => {
+			const status = data.status || {};
+			const el = document.getElementById('org-policy');
+			if (!status.enabled) {
+				el.textContent = 'not configured';
+				return;
+			}
+			let text = status.rule_count + ' rules';
+			if (status.version) {
+				text += ' (' + status.version + ')';
+			}
+			if (status.last_sync && !status.last_sync.startsWith('0001')) {
+				text += ', synced ' + new Date(status.last_sync).toLocaleTimeString();
+			} else {
+				text += ', never synced';
+			}
+			if (status.last_error) {
+				text += ' — last sync failed: ' + status.last_error;
+			}
+			el.textContent = text;
+		});
+}
+
+// Block errors link here with ?approval=<token>; show that call and
+// narrow the rules down to its tool
+const approvalToken = new URLSearchParams(window.location.search).get('approval');
+
+function loadApproval() {
+	if (!approvalToken) {
+		return Promise.resolve();
+	}
+	const section = document.getElementById('approval');
+	section.style.display = '';
+	return fetchJSON('/api/v1/approvals?token=' + encodeURIComponent(approvalToken))
+		.then((approval) => {
+			const block = approval.block || {};
+			let kind = block.blocked_by || 'blocked';
+			if (block.rule_id) {
+				kind += ' #' + block.rule_id;
+			}
+			document.getElementById('approval-kind').textContent = kind;
+			document.getElementById('approval-tool').textContent = block.tool ? block.tool + ' (' + block.operation + ')' : block.operation;
+			document.getElementById('approval-reason').textContent = block.reason || '';
+			document.getElementById('approval-expires').textContent = 'Link expires at ' + new Date(approval.expires).toLocaleTimeString();
+			if (block.tool) {
+				document.getElementById('rule-search').value = block.tool;
+			}
+		})
+		.catch(() => {
+			document.getElementById('approval-reason').textContent = 'This approval link was already used or has expired.';
+			document.getElementById('approve-once').disabled = true;
+			document.getElementById('approve-exception').disabled = true;
+		});
+}
+
+function decideApproval(decision) {
+	fetchJSON('/api/v1/approvals', {
+		method: 'POST',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({ token: approvalToken, decision: decision })
+	})
+		.then(() => {
+			showToast(decision === 'once' ? 'Approved once: retry the call' : 'Exception created until the proxy restarts', 'success');
+			document.getElementById('approve-once').disabled = true;
+			document.getElementById('approve-exception').disabled = true;
+		})
+		.catch((err) => showToast('Approval failed: ' + err.message, 'error'));
+}
+
+// ?page=<section> shows one section of the dashboard, and ?embed=1 drops
+// the top bar for editor webviews, e.g. ?embed=1&page=approvals
+const pageParams = new URLSearchParams(window.location.search);
+const embedded = pageParams.get('embed') === '1';
+const pagePaths = { '/approvals': 'approvals', '/tools': 'tools' };
+const singlePage = pageParams.get('page') || pagePaths[window.location.pathname] || '';
+
+// The approvals queue lists blocked calls awaiting a decision, pushed over
+// a WebSocket as they come and go. /approvals shows it on its own.
+const approvalsPage = singlePage === 'approvals';
+let approvalQueue = [];
+let approvalIndex = 0;
+
+function connectApprovals() {
+	const scheme = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+	const socket = new WebSocket(scheme + window.location.host + '/api/v1/approvals/ws');
+	socket.addEventListener('message', (event) => {
+		renderApprovalQueue(JSON.parse(event.data).approvals || []);
+	});
+	socket.addEventListener('close', () => setTimeout(connectApprovals, 3000));
+}
+
+function renderApprovalQueue(approvals) {
+	const selected = approvalQueue[approvalIndex];
+	approvalQueue = approvals;
+	// Keep the same call selected when others come and go
+	const kept = selected ? approvals.findIndex((a) => a.token === selected.token) : -1;
+	approvalIndex = kept >= 0 ? kept : Math.min(approvalIndex, Math.max(approvals.length - 1, 0));
+
+	document.getElementById('approval-count').textContent = approvals.length;
+	document.getElementById('approvals').style.display = approvalsPage || approvals.length ? '' : 'none';
+	document.getElementById('approval-empty').style.display = approvals.length ? 'none' : '';
+	document.getElementById('approval-layout').style.display = approvals.length ? '' : 'none';
+
+	const queue = document.getElementById('approval-queue');
+	queue.innerHTML = '';
+	approvals.forEach((approval, i) => {
+		const block = approval.block || {};
+		const item = document.createElement('div');
+		item.className = 'approval-item' + (i === approvalIndex ? ' is-selected' : '');
+		item.innerHTML = '<strong>' + escapeHTML(block.tool || block.operation || '--') + '</strong>' +
+			'<p>' + escapeHTML(block.blocked_by || 'blocked') + ' · ' + new Date(approval.created).toLocaleTimeString() + '</p>';
+		item.addEventListener('click', () => selectApproval(i));
+		queue.appendChild(item);
+	});
+	renderApprovalPreview();
+}
+
+function selectApproval(i) {
+	if (!approvalQueue.length) {
+		return;
+	}
+	approvalIndex = Math.max(0, Math.min(i, approvalQueue.length - 1));
+	document.querySelectorAll('#approval-queue .approval-item').forEach((item, j) => {
+		item.classList.toggle('is-selected', j === approvalIndex);
+	});
+	renderApprovalPreview();
+}
+
+// renderApprovalPreview shows the selected call's arguments like a diff:
+// the lines of risky arguments are marked, with the reason on their first line
+function renderApprovalPreview() {
+	const approval = approvalQueue[approvalIndex];
+	if (!approval) {
+		return;
+	}
+	const block = approval.block || {};
+	let kind = block.action === 'ask' ? 'ask' : (block.blocked_by || 'blocked');
+	if (block.rule_id) {
+		kind += ' #' + block.rule_id;
+	}
+	document.getElementById('queued-kind').textContent = kind;
+	document.getElementById('queued-tool').textContent = block.tool ? block.tool + ' (' + block.operation + ')' : block.operation;
+	document.getElementById('queued-reason').textContent = block.reason || '';
+
+	const risks = approval.risks || [];
+	const lines = JSON.stringify(approval.arguments || {}, null, 2).split('\n');
+	let field = null;
+	document.getElementById('queued-arguments').innerHTML = lines.map((text) => {
+		const key = text.match(/^  "((?:[^"\\]|\\.)*)":/);
+		if (key) {
+			field = JSON.parse('"' + key[1] + '"');
+		} else if (/^[{}]/.test(text)) {
+			field = null;
+		}
+		const hits = field === null ? [] : risks.filter((r) => r.field.split(/[.[]/)[0] === field);
+		const note = key && hits.length ? '<span class="diff-note">' + escapeHTML(hits.map((r) => r.reason).join('; ')) + '</span>' : '';
+		return '<div class="diff-line' + (hits.length ? ' is-risky' : '') + '">' + (hits.length ? '! ' : '  ') + escapeHTML(text) + note + '</div>';
+	}).join('');
+	renderCallPreview(approval.preview);
+}
+
+// renderCallPreview shows what a previewed call would change: a diff of the
+// file, or the output of the backend's dry run
+function renderCallPreview(preview) {
+	const title = document.getElementById('queued-preview-title');
+	const changes = document.getElementById('queued-preview');
+	title.style.display = changes.style.display = preview ? '' : 'none';
+	if (!preview) {
+		return;
+	}
+	const method = preview.method === 'dry_run' ? 'dry run' : 'diff';
+	title.textContent = preview.error ? 'Preview failed (' + method + '): ' + preview.error : 'Predicted changes (' + method + ')';
+	changes.style.display = preview.changes ? '' : 'none';
+	changes.innerHTML = (preview.changes || '').replace(/\n$/, '').split('\n').map((text) => {
+		const kind = /^\+(?!\+\+ )/.test(text) ? ' is-added' : (/^-(?!-- )/.test(text) ? ' is-removed' : '');
+		return '<div class="diff-line' + (preview.method === 'diff' ? kind : '') + '">' + escapeHTML(text) + '</div>';
+	}).join('');
+}
+
+function decideQueued(decision) {
+	const approval = approvalQueue[approvalIndex];
+	if (!approval) {
+		return;
+	}
+	fetchJSON('/api/v1/approvals', {
+		method: 'POST',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({ token: approval.token, decision: decision })
+	})
+		.then(() => {
+			const messages = { once: 'Approved once: retry the call', exception: 'Exception created until the proxy restarts', deny: 'Denied' };
+			showToast(messages[decision], decision === 'deny' ? 'error' : 'success');
+		})
+		.catch((err) => showToast('Decision failed: ' + err.message, 'error'));
+}
+
+function handleApprovalKeys(event) {
+	const target = event.target;
+	if (!approvalQueue.length || event.ctrlKey || event.metaKey || event.altKey ||
+		(target && (target.isContentEditable || ['INPUT', 'TEXTAREA', 'SELECT'].includes(target.tagName)))) {
+		return;
+	}
+	// Outside /approvals the keys only apply while the queue is on screen
+	const section = document.getElementById('approvals').getBoundingClientRect();
+	if (!approvalsPage && (section.bottom < 0 || section.top > window.innerHeight)) {
+		return;
+	}
+	const actions = {
+		j: () => selectApproval(approvalIndex + 1),
+		ArrowDown: () => selectApproval(approvalIndex + 1),
+		k: () => selectApproval(approvalIndex - 1),
+		ArrowUp: () => selectApproval(approvalIndex - 1),
+		a: () => decideQueued('once'),
+		e: () => decideQueued('exception'),
+		d: () => decideQueued('deny')
+	};
+	if (actions[event.key]) {
+		event.preventDefault();
+		actions[event.key]();
+	}
+}
+
+let killSwitchEngaged = false;
+
+function renderKillSwitch(state) {
+	killSwitchEngaged = !!state.engaged;
+	document.getElementById('proxy-status').classList.toggle('killed', killSwitchEngaged);
+	document.getElementById('proxy-status-text').textContent = killSwitchEngaged
+		? 'Kill switch engaged' + (state.resources ? ' (tools and resources)' : '')
+		: (offlineEnabled ? 'Offline mode' : 'Proxy online');
+	document.getElementById('killswitch').textContent = killSwitchEngaged ? 'Release kill switch' : 'Block all tools';
+	document.getElementById('killswitch-resources').checked = !!state.resources;
+	document.getElementById('killswitch-resources').disabled = killSwitchEngaged;
+}
+
+let offlineEnabled = false;
+
+function renderOffline(state) {
+	offlineEnabled = !!state.enabled;
+	const button = document.getElementById('offline');
+	button.style.display = '';
+	button.textContent = offlineEnabled ? 'Go online' : 'Go offline';
+	button.classList.toggle('btn-danger', offlineEnabled);
+	if (!killSwitchEngaged) {
+		document.getElementById('proxy-status-text').textContent = offlineEnabled ? 'Offline mode' : 'Proxy online';
+	}
+}
+
+function loadIncidents() {
+	return fetchJSON('/api/v1/audit?reason=decoy_called&limit=20')
+		.then((data) => {
+			const entries = (data.entries || []).slice().reverse();
+			document.getElementById('incidents').style.display = entries.length ? '' : 'none';
+			document.getElementById('incident-count').textContent = entries.length;
+			document.getElementById('incident-list').innerHTML = entries.map((e) =>
+				'<div class="rule-desc">' + escapeHTML(new Date(e.timestamp).toLocaleString()) + ': ' + escapeHTML(e.tool_name || e.method) + '</div>'
+			).join('');
+		});
+}
+
+function loadUndo() {
+	return fetchJSON('/api/v1/undo?limit=20')
+		.then((data) => {
+			const snapshots = data.snapshots || [];
+			document.getElementById('undo').style.display = snapshots.length ? '' : 'none';
+			document.getElementById('undo-count').textContent = snapshots.length;
+			document.getElementById('undo-list').innerHTML = snapshots.map((snap) => {
+				const state = snap.restored_at
+					? 'restored ' + new Date(snap.restored_at).toLocaleString()
+					: (snap.existed ? snap.size + ' bytes' : 'created by the call');
+				return '<div class="rule-desc">' + escapeHTML(new Date(snap.timestamp).toLocaleString()) + ': ' +
+					escapeHTML(snap.tool_name) + ' <code>' + escapeHTML(snap.path) + '</code> (' + escapeHTML(state) + ')' +
+					(snap.restored_at ? '' : ' <button class="btn btn-ghost admin-only" type="button" data-restore="' + snap.id + '">Restore</button>') +
+					'</div>';
+			}).join('');
+			document.querySelectorAll('#undo-list [data-restore]').forEach((button) => {
+				button.addEventListener('click', () => restoreSnapshot(Number(button.getAttribute('data-restore'))));
+			});
+		})
+		// Only stdio mode keeps an undo journal
+		.catch(() => {
+			document.getElementById('undo').style.display = 'none';
+		});
+}
+
+function loadDiscovered() {
+	return fetchJSON('/api/v1/discovered')
+		.then((data) => {
+			const pending = (data.servers || []).filter((srv) => srv.status === 'pending');
+			document.getElementById('discovered').style.display = pending.length ? '' : 'none';
+			document.getElementById('discovered-count').textContent = pending.length;
+			document.getElementById('discovered-list').innerHTML = pending.map((srv) =>
+				'<div class="rule-desc">' + escapeHTML(srv.name) + ' (' + escapeHTML(srv.tier) + '): <code>' + escapeHTML(srv.source) + '</code>' +
+				' <button class="btn btn-ghost admin-only" type="button" data-server="' + escapeHTML(srv.name) + '" data-decision="approve">Approve</button>' +
+				' <button class="btn btn-ghost admin-only" type="button" data-server="' + escapeHTML(srv.name) + '" data-decision="reject">Reject</button>' +
+				'</div>'
+			).join('');
+			document.querySelectorAll('#discovered-list [data-decision]').forEach((button) => {
+				button.addEventListener('click', () => decideDiscovered(button.getAttribute('data-server'), button.getAttribute('data-decision')));
+			});
+		})
+		// Only stdio mode gates discovered servers
+		.catch(() => {
+			document.getElementById('discovered').style.display = 'none';
+		});
+}
+
+function decideDiscovered(name, decision) {
+	fetchJSON('/api/v1/discovered', {
+		method: 'POST',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({ name: name, decision: decision })
+	})
+		.then((srv) => {
+			showToast(srv.name + ' ' + srv.status, 'success');
+			return Promise.all([loadDiscovered(), loadServers()]);
+		})
+		.catch((err) => showToast('Decision failed: ' + err.message, 'error'));
+}
+
+function restoreSnapshot(id) {
+	fetchJSON('/api/v1/undo', {
+		method: 'POST',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({ id: id })
+	})
+		.then((snap) => {
+			showToast('Restored ' + snap.path, 'success');
+			return loadUndo();
+		})
+		.catch((err) => showToast('Restore failed: ' + err.message, 'error'));
+}
+
+function loadHealth() {
+	// /api/v1/health answers 503 when unhealthy; the body is still the report
+	return fetch('/api/v1/health')
+		.then((res) => res.json())
+		.then((health) => {
+			renderCrashes(health.supervisor);
+			const bypasses = (health.clients && health.clients.bypasses) || [];
+			document.getElementById('bypasses').style.display = bypasses.length ? '' : 'none';
+			document.getElementById('bypass-count').textContent = bypasses.length;
+			if (health.clients && health.clients.remedy) {
+				document.getElementById('bypass-remedy').textContent = health.clients.remedy;
+			}
+			document.getElementById('bypass-list').innerHTML = bypasses.map((b) =>
+				'<div class="rule-desc">' + escapeHTML(b.server) + ' in ' + escapeHTML(b.client) + ' (' + escapeHTML(b.config_path + (b.project ? ' [' + b.project + ']' : '')) + ')</div>'
+			).join('');
+		});
+}
+
+// renderCrashes shows the crash history passed down by `mcp-proxy supervise`
+function renderCrashes(supervisor) {
+	const crashes = (supervisor && supervisor.crashes) || 0;
+	document.getElementById('crashes').style.display = crashes ? '' : 'none';
+	document.getElementById('crash-count').textContent = crashes;
+	if (crashes && supervisor.last_crash) {
+		document.getElementById('crash-detail').textContent = 'Last crash ' + new Date(supervisor.last_crash).toLocaleString() +
+			(supervisor.last_dump ? ', dump in ' + supervisor.last_dump : '');
+	}
+}
+
+// loadClaudePermissions shows the native permission rules in Claude's
+// settings.json; checkClaudePermissions only flags outside changes
+function loadClaudePermissions() {
+	return fetchJSON('/api/v1/permissions').then(renderClaudePermissions);
+}
+
+function checkClaudePermissions() {
+	return fetchJSON('/api/v1/permissions').then((data) => {
+		if (state.permissionsVersion !== undefined && data.version !== state.permissionsVersion) {
+			document.getElementById('permissions-changed').style.display = '';
+		}
+	});
+}
+
+function renderClaudePermissions(data) {
+	state.permissionsVersion = data.version;
+	document.getElementById('permissions-changed').style.display = 'none';
+	if (data.path) {
+		document.getElementById('permissions-path').textContent = data.path;
+	}
+	const classes = { allow: 'perm-allow', ask: 'perm-inherit', deny: 'perm-deny' };
+	const container = document.getElementById('permissions-lists');
+	container.innerHTML = ['deny', 'ask', 'allow'].map((mode) => {
+		const tools = (data.permissions || {})[mode] || [];
+		const chips = tools.length
+			? tools.map((tool) =>
+				'<span class="perm-chip ' + classes[mode] + '">' + escapeHTML(tool) +
+				' <button class="btn btn-ghost admin-only" type="button" title="Remove" data-mode="' + mode + '" data-tool="' + escapeHTML(tool) + '">×</button></span>'
+			).join('')
+			: '<span class="muted">None</span>';
+		return '<div class="rule-block"><strong>' + mode + '</strong><div class="permissions-list">' + chips + '</div></div>';
+	}).join('');
+	container.querySelectorAll('button').forEach((button) => {
+		button.addEventListener('click', () => removeClaudePermission(button.dataset.tool, button.dataset.mode));
+	});
+}
+
+// removeClaudePermission sends the version the list was loaded at, so the
+// server merges into outside edits instead of overwriting them
+function removeClaudePermission(tool, mode) {
+	fetch('/api/v1/permissions', {
+		method: 'PUT',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({
+			version: state.permissionsVersion,
+			rules: [{ tool: tool, mode: 'unset', previous: mode }]
+		})
+	})
+		.then((res) => {
+			if (!res.ok && res.status !== 409) {
+				throw new Error('HTTP ' + res.status);
+			}
+			return res.json().then((data) => ({ status: res.status, data: data }));
+		})
+		.then(({ status, data }) => {
+			renderClaudePermissions(data);
+			if (status === 409) {
+				const conflict = data.conflicts[0];
+				showToast(conflict.tool + ' was changed to ' + conflict.theirs + ' in settings.json; not removed', 'error');
+				return;
+			}
+			showToast(data.merged ? 'Removed ' + tool + ', keeping outside changes to settings.json' : 'Removed ' + tool, 'success');
+		})
+		.catch((err) => showToast('Failed to update permissions: ' + err.message, 'error'));
+}
+
+const SYNC_STATUS = {
+	synced: ['In sync', 'chip-allow'],
+	armour_only: ['Armour only', 'chip-off'],
+	claude_only: ['settings.json only', 'chip-off'],
+	differs: ['Differs', 'chip-off'],
+	conflict: ['Conflict', 'chip-block'],
+	unsupported: ['Not syncable', '']
+};
+
+// loadPermissionSync shows how blocklist rules and settings.json
+// permissions compare; the rules server may not be running
+function loadPermissionSync() {
+	return fetchJSON('/api/v1/permission-sync')
+		.then(renderPermissionSync)
+		.catch(() => {
+			document.getElementById('sync-summary').textContent = 'Rules server unavailable; start it with mcp-proxy serve.';
+		});
+}
+
+function renderPermissionSync(report) {
+	document.getElementById('sync-direction').value = report.direction;
+	document.getElementById('sync-summary').textContent = report.last_sync && !report.last_sync.startsWith('0001')
+		? 'Last synced ' + new Date(report.last_sync).toLocaleString() + ' with ' + report.settings_path
+		: 'Not synced yet with ' + report.settings_path;
+	const items = report.items || [];
+	document.getElementById('sync-items').innerHTML = items.length
+		? items.map((item) => {
+			const status = SYNC_STATUS[item.status] || [item.status, ''];
+			const sides = 'Armour: ' + (item.armour || 'none') + ', settings.json: ' + (item.claude || 'none');
+			return '<div class="rule-desc" style="margin-top: 6px;">' +
+				'<span class="chip ' + status[1] + '">' + escapeHTML(status[0]) + '</span> ' +
+				'<code>' + escapeHTML(item.entry) + '</code> ' + escapeHTML(sides) +
+				(item.reason ? ' — ' + escapeHTML(item.reason) : '') +
+			'</div>';
+		}).join('')
+		: '<div class="empty-state">No block-all rules or deny and ask entries to sync.</div>';
+}
+
+function syncPermissions() {
+	fetch('/api/v1/permission-sync', {
+		method: 'POST',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({ direction: document.getElementById('sync-direction').value })
+	})
+		.then((res) => {
+			if (!res.ok) {
+				return res.text().then((text) => { throw new Error(text || 'HTTP ' + res.status); });
+			}
+			return res.json();
+		})
+		.then((report) => {
+			renderPermissionSync(report);
+			loadClaudePermissions().catch(() => {});
+			loadRules().catch(() => {});
+			showToast(report.direction === 'off'
+				? 'Sync turned off'
+				: 'Synced: ' + report.claude_changes + ' settings.json entries and ' + report.armour_changes + ' rules changed', 'success');
+		})
+		.catch((err) => showToast('Sync failed: ' + err.message, 'error'));
+}
+
+function loadKillSwitch() {
+	return fetchJSON('/api/v1/killswitch').then(renderKillSwitch);
+}
+
+function loadOffline() {
+	return fetchJSON('/api/v1/offline')
+		.then(renderOffline)
+		// Only stdio mode has offline mode
+		.catch(() => {
+			document.getElementById('offline').style.display = 'none';
+		});
+}
+
+function toggleOffline() {
+	const enable = !offlineEnabled;
+	fetchJSON('/api/v1/offline', {
+		method: 'POST',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({ enabled: enable, reason: enable ? 'turned on from the dashboard' : '' })
+	})
+		.then((state) => {
+			renderOffline(state);
+			showToast(enable ? 'Offline mode on: network servers and URL arguments are blocked' : 'Offline mode off', 'success');
+		})
+		.catch((err) => showToast('Failed to set offline mode: ' + err.message, 'error'));
+}
+
+function toggleKillSwitch() {
+	const engage = !killSwitchEngaged;
+	if (engage && !window.confirm('Block every tool call in all sessions until the kill switch is released?')) {
+		return;
+	}
+	fetchJSON('/api/v1/killswitch', {
+		method: 'POST',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify({
+			engaged: engage,
+			resources: document.getElementById('killswitch-resources').checked,
+			reason: engage ? 'engaged from the dashboard' : ''
+		})
+	})
+		.then((state) => {
+			renderKillSwitch(state);
+			showToast(engage ? 'Kill switch engaged: all tool calls are blocked' : 'Kill switch released', engage ? 'error' : 'success');
+		})
+		.catch((err) => showToast('Failed to set kill switch: ' + err.message, 'error'));
+}
+
+function loadRules() {
+	return fetchJSON('/api/v1/blocklist')
+		.then((data) => {
+			state.rules = data.rules || [];
+			document.getElementById('rule-count').textContent = state.rules.length;
+			renderRules();
+			renderToolDetail();
+		});
+}
+
+function loadTools() {
+	return fetchJSON('/api/v1/tools')
+		.then((data) => {
+			state.tools = data.tools || [];
+			renderToolDropdown();
+			renderToolCatalog();
+			selectLinkedTool();
+		})
+		.catch(() => {
+			// Fallback to native tools if /api/v1/tools not available
+			state.tools = NATIVE_TOOLS.map(t => ({ name: t.name, type: 'native' }));
+			renderToolDropdown();
+			renderToolCatalog();
+		});
+}
+
+// The tool catalog lists every tool with its risk class, call counts and
+// schemas, and links to the rules that target it
+const RISK_CHIPS = { read: 'chip-allow', write: 'chip-off', exec: 'chip-block', delete: 'chip-block' };
+let catalogTools = [];
+let catalogIndex = 0;
+
+function renderToolCatalog() {
+	const search = document.getElementById('tool-search').value.toLowerCase();
+	const filter = document.getElementById('tool-filter').value;
+	const selected = catalogTools[catalogIndex];
+	catalogTools = state.tools.filter((tool) => {
+		const haystack = (tool.name + ' ' + (tool.server || '') + ' ' + (tool.description || '')).toLowerCase();
+		return (!search || haystack.includes(search)) && (filter === 'all' || tool.risk === filter);
+	});
+	// Keep the same tool selected while filtering
+	catalogIndex = Math.max(selected ? catalogTools.findIndex((t) => t.name === selected.name) : 0, 0);
+
+	const empty = document.getElementById('tool-empty');
+	empty.textContent = state.tools.length ? 'No tools match these filters.' : 'No tools found.';
+	empty.style.display = catalogTools.length ? 'none' : '';
+	document.getElementById('tool-layout').style.display = catalogTools.length ? '' : 'none';
+
+	const list = document.getElementById('tool-list');
+	list.innerHTML = '';
+	catalogTools.forEach((tool, i) => {
+		const hits = tool.hits || {};
+		const item = document.createElement('div');
+		item.className = 'catalog-item' + (i === catalogIndex ? ' is-selected' : '');
+		item.innerHTML = '<strong>' + escapeHTML(tool.name) + '</strong>' +
+			'<p>' + escapeHTML(tool.server || tool.type) + ' · ' + escapeHTML(tool.risk || 'unclassified') + ' · ' +
+			((hits.allowed || 0) + (hits.blocked || 0)) + ' calls</p>';
+		item.addEventListener('click', () => selectCatalogTool(i));
+		list.appendChild(item);
+	});
+	renderToolDetail();
+}
+
+// /tools?tool=<name>, e.g. from the command palette, selects that tool
+function selectLinkedTool() {
+	const name = pageParams.get('tool');
+	const i = name ? catalogTools.findIndex((t) => t.name === name) : -1;
+	if (i >= 0) {
+		selectCatalogTool(i);
+		document.querySelectorAll('#tool-list .catalog-item')[i].scrollIntoView({ block: 'nearest' });
+	}
+}
+
+function selectCatalogTool(i) {
+	catalogIndex = i;
+	document.querySelectorAll('#tool-list .catalog-item').forEach((item, j) => {
+		item.classList.toggle('is-selected', j === catalogIndex);
+	});
+	renderToolDetail();
+}
+
+function renderToolDetail() {
+	const tool = catalogTools[catalogIndex];
+	if (!tool) {
+		return;
+	}
+	const hits = tool.hits || {};
+	const risk = tool.risk || 'unclassified';
+	document.getElementById('tool-chips').innerHTML =
+		'<span class="chip ' + (RISK_CHIPS[risk] || '') + '">' + escapeHTML(risk) + '</span>' +
+		'<span class="chip">' + escapeHTML(tool.type) + '</span>' +
+		(tool.server ? '<span class="chip">' + escapeHTML(tool.server) + '</span>' : '');
+	document.getElementById('tool-name').textContent = tool.name;
+	document.getElementById('tool-description').textContent = tool.description || 'No description';
+	document.getElementById('tool-hits').textContent =
+		(hits.allowed || 0) + ' allowed · ' + (hits.blocked || 0) + ' blocked since the proxy started';
+
+	const actions = document.getElementById('tool-rules');
+	actions.innerHTML = '';
+	(tool.rules || []).forEach((id) => {
+		const rule = state.rules.find((r) => r.id === id);
+		const button = document.createElement('button');
+		button.className = 'btn';
+		button.type = 'button';
+		button.textContent = 'Rule #' + id + (rule ? ': ' + rule.pattern : '');
+		button.addEventListener('click', () => showRule(id));
+		actions.appendChild(button);
+	});
+	const newRule = document.createElement('button');
+	newRule.className = 'btn btn-ghost admin-only';
+	newRule.type = 'button';
+	newRule.textContent = 'New rule for this tool';
+	newRule.addEventListener('click', () => {
+		openDrawer(null);
+		document.getElementById('rule-tool').value = tool.name;
+	});
+	actions.appendChild(newRule);
+
+	document.getElementById('tool-input-schema').textContent =
+		tool.input_schema ? JSON.stringify(tool.input_schema, null, 2) : 'No schema published.';
+	document.getElementById('tool-output').style.display = tool.output_schema ? '' : 'none';
+	document.getElementById('tool-output-schema').textContent = JSON.stringify(tool.output_schema || {}, null, 2);
+}
+
+// showLinked shows what #rule-<id> or #server-<name> links to, as linked
+// from the tool catalog and the command palette
+function showLinked() {
+	const rule = window.location.hash.match(/^#rule-(\d+)$/);
+	if (rule) {
+		showRule(Number(rule[1]));
+		return;
+	}
+	if (window.location.hash.startsWith('#server-')) {
+		const item = document.getElementById(decodeURIComponent(window.location.hash.slice(1)));
+		if (item) {
+			item.scrollIntoView({ behavior: 'smooth', block: 'center' });
+		}
+	}
+}
+
+// showRule opens a rule in the rules list, going back to the full dashboard
+// from a single-section page
+function showRule(id) {
+	if (singlePage) {
+		window.location.href = '/#rule-' + id;
+		return;
+	}
+	document.getElementById('rule-search').value = '';
+	document.getElementById('rule-filter').value = 'all';
+	renderRules();
+	const card = document.getElementById('rule-' + id);
+	if (card) {
+		card.open = true;
+		card.scrollIntoView({ behavior: 'smooth', block: 'center' });
+	}
+}
+
+function renderToolDropdown() {
+	const select = document.getElementById('rule-tool');
+	if (!select) return;
+
+	// Keep the "All tools" option
+	select.innerHTML = '<option value="*">All tools</option>';
+
+	// Group tools by type
+	const nativeTools = state.tools.filter(t => t.type === 'native');
+	const mcpTools = state.tools.filter(t => t.type !== 'native');
+
+	if (nativeTools.length > 0) {
+		const group1 = document.createElement('optgroup');
+		group1.label = 'Native Tools';
+		nativeTools.forEach(tool => {
+			const opt = document.createElement('option');
+			opt.value = tool.name;
+			opt.textContent = tool.name;
+			group1.appendChild(opt);
+		});
+		select.appendChild(group1);
+	}
+
+	if (mcpTools.length > 0) {
+		const group2 = document.createElement('optgroup');
+		group2.label = 'MCP Tools';
+		mcpTools.forEach(tool => {
+			const opt = document.createElement('option');
+			opt.value = tool.name;
+			opt.textContent = tool.name + (tool.server ? ' (' + tool.server + ')' : '');
+			group2.appendChild(opt);
+		});
+		select.appendChild(group2);
+	}
+}
+
+function renderRules() {
+	const list = document.getElementById('rules-list');
+	const search = document.getElementById('rule-search').value.toLowerCase();
+	const filter = document.getElementById('rule-filter').value;
+
+	const filtered = state.rules.filter((rule) => {
+		const haystack = (rule.pattern + ' ' + (rule.description || '') + ' ' + (rule.tools || '')).toLowerCase();
+		if (search && !haystack.includes(search)) {
+			return false;
+		}
+		switch (filter) {
+			case 'block':
+				return rule.action === 'block';
+			case 'ask':
+				return rule.action === 'ask';
+			case 'allow':
+				return rule.action === 'allow';
+			case 'enabled':
+				return rule.enabled;
+			case 'disabled':
+				return !rule.enabled;
+			default:
+				return true;
+		}
+	});
+
+	list.innerHTML = '';
+	if (filtered.length === 0) {
+		list.innerHTML = '<div class="empty-state">No rules match these filters.</div>';
+		return;
+	}
+
+	filtered.forEach((rule) => {
+		const card = document.createElement('details');
+		card.className = 'rule-card';
+		card.id = 'rule-' + rule.id;
+
+		const actionClass = rule.action === 'block' ? 'chip-block' : (rule.action === 'ask' ? 'chip-off' : 'chip-allow');
+		const enabledClass = rule.enabled ? 'chip-on' : 'chip-off';
+		const enabledLabel = rule.enabled ? 'enabled' : 'disabled';
+		const toolsLabel = rule.tools && rule.tools.trim() ? rule.tools : 'all tools';
+		const typeLabels = [];
+		if (rule.block_all) {
+			typeLabels.push('<span class="chip chip-block">block all</span>');
+		}
+		if (rule.is_regex && !rule.block_all) {
+			typeLabels.push('<span class="chip">regex</span>');
+		}
+		if (rule.is_semantic) {
+			typeLabels.push('<span class="chip">semantic</span>');
+		}
+		if (rule.schedule) {
+			typeLabels.push(rule.active
+				? '<span class="chip chip-on">scheduled: in force</span>'
+				: '<span class="chip chip-off">scheduled: off</span>');
+		}
+
+		card.innerHTML =
+			'<summary>' +
+				'<div class="rule-main">' +
+					'<div class="rule-pattern">' + escapeHTML(rule.pattern) + '</div>' +
+					'<div class="rule-desc">' + escapeHTML(rule.description || 'No description') + '</div>' +
+				'</div>' +
+				'<div class="rule-meta">' +
+					'<span class="chip ' + actionClass + '">' + escapeHTML(rule.action) + '</span>' +
+					typeLabels.join('') +
+					'<span class="chip ' + enabledClass + '">' + enabledLabel + '</span>' +
+				'</div>' +
+			'</summary>' +
+			'<div class="rule-body">' +
+				'<div class="rule-columns">' +
+					'<div class="rule-block"><strong>Tools</strong>' + escapeHTML(toolsLabel) + '</div>' +
+					'<div class="rule-block"><strong>Pattern type</strong>' +
+						escapeHTML((rule.is_regex ? 'Regex ' : '') + (rule.is_semantic ? 'Semantic' : '')) +
+					'</div>' +
+				'</div>' +
+				(rule.schedule ? '<div class="rule-block"><strong>Schedule</strong>' + escapeHTML(rule.schedule) + renderScheduleState(rule) + '</div>' : '') +
+				'<div class="rule-block"><strong>Permissions</strong>' + renderPermissionChips(rule.permissions) + '</div>' +
+				'<div class="rule-actions admin-only">' +
+					'<label class="switch"><input type="checkbox" ' + (rule.enabled ? 'checked' : '') + ' data-toggle="' + rule.id + '" />Toggle</label>' +
+					'<div class="rule-controls">' +
+						'<button class="btn" data-edit="' + rule.id + '">Edit</button>' +
+						'<button class="btn btn-danger" data-delete="' + rule.id + '">Delete</button>' +
+					'</div>' +
+				'</div>' +
+			'</div>';
+
+		list.appendChild(card);
+	});
+
+	list.querySelectorAll('[data-edit]').forEach((button) => {
+		button.addEventListener('click', (event) => {
+			event.preventDefault();
+			const ruleId = Number(event.currentTarget.getAttribute('data-edit'));
+			const rule = state.rules.find((item) => item.id === ruleId);
+			if (rule) {
+				openDrawer(rule);
+			}
+		});
+	});
+
+	list.querySelectorAll('[data-delete]').forEach((button) => {
+		button.addEventListener('click', (event) => {
+			event.preventDefault();
+			const ruleId = Number(event.currentTarget.getAttribute('data-delete'));
+			deleteRule(ruleId);
+		});
+	});
+
+	list.querySelectorAll('[data-toggle]').forEach((input) => {
+		input.addEventListener('change', (event) => {
+			const ruleId = Number(event.currentTarget.getAttribute('data-toggle'));
+			const rule = state.rules.find((item) => item.id === ruleId);
+			if (!rule) {
+				return;
+			}
+			rule.enabled = event.currentTarget.checked;
+			saveRulePayload(rule, true);
+		});
+	});
+}
+
+// renderScheduleState says when a scheduled rule next comes into force or
+// lapses.
+function renderScheduleState(rule) {
+	if (!rule.active && rule.next_activation) {
+		return '<div class="muted">Next activation ' + escapeHTML(new Date(rule.next_activation).toLocaleString()) + '</div>';
+	}
+	if (rule.active && rule.active_until) {
+		return '<div class="muted">In force until ' + escapeHTML(new Date(rule.active_until).toLocaleString()) + '</div>';
+	}
+	return '';
+}
+
+function renderPermissionChips(permissions) {
+	if (!permissions) {
+		return '<span class="muted">No permissions set</span>';
+	}
+	return '<div class="permissions-list">' +
+		PERMISSION_LABELS.map((item) => {
+			const value = permissions[item.key] || 'inherit';
+			const cls = value === 'allow' ? 'perm-allow' : value === 'deny' ? 'perm-deny' : 'perm-inherit';
+			return '<span class="perm-chip ' + cls + '">' + item.label + ': ' + value + '</span>';
+		}).join('') +
+	'</div>';
+}
+
+function openDrawer(rule) {
+	editingRuleId = rule ? rule.id : null;
+	document.getElementById('drawer-title').textContent = rule ? 'Edit rule' : 'New rule';
+	document.getElementById('rule-tool').value = rule ? (rule.tools || '*') : '*';
+	document.getElementById('rule-keywords').value = rule ? rule.pattern : '';
+	document.getElementById('rule-action').value = rule ? rule.action : 'block';
+	document.getElementById('rule-schedule').value = rule ? (rule.schedule || '') : '';
+
+	// Handle block_all checkbox
+	const blockAllCheckbox = document.getElementById('rule-block-all');
+	const keywordsRow = document.getElementById('keywords-row');
+	blockAllCheckbox.checked = rule ? (rule.block_all || false) : false;
+	keywordsRow.style.display = blockAllCheckbox.checked ? 'none' : 'flex';
+
+	document.body.classList.add('drawer-open');
+	drawer.setAttribute('aria-hidden', 'false');
+}
+
+function closeDrawer() {
+	document.body.classList.remove('drawer-open');
+	drawer.setAttribute('aria-hidden', 'true');
+}
+
+function saveRulePayload(rule, silent) {
+	const payload = {
+		pattern: rule.pattern,
+		description: rule.description || '',
+		action: rule.action,
+		is_regex: rule.is_regex,
+		is_semantic: rule.is_semantic,
+		tools: rule.tools || '',
+		schedule: rule.schedule || '',
+		enabled: rule.enabled,
+		block_all: rule.block_all || false,
+		permissions: rule.permissions || DEFAULT_PERMISSIONS[rule.action]
+	};
+
+	return fetchJSON('/api/v1/blocklist?id=' + rule.id, {
+		method: 'PUT',
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify(payload)
+	})
+		.then(() => {
+			if (!silent) {
+				showToast('Rule updated', 'success');
+			}
+			return loadRules();
+		})
+		.catch((err) => {
+			showToast('Failed to update rule: ' + err.message, 'error');
+		});
+}
+
+function deleteRule(ruleId) {
+	if (!confirm('Delete this rule?')) {
+		return;
+	}
+	fetchJSON('/api/v1/blocklist?id=' + ruleId, { method: 'DELETE' })
+		.then(() => {
+			showToast('Rule deleted', 'success');
+			loadRules();
+		})
+		.catch((err) => {
+			showToast('Failed to delete rule: ' + err.message, 'error');
+		});
+}
+
+// Viewers can only read: the controls that change anything are hidden
+function loadRole() {
+	return fetchJSON('/api/v1/whoami')
+		.then((data) => document.body.classList.toggle('read-only', data.role === 'viewer'));
+}
+
+// The command palette (Ctrl+K / Cmd+K) searches tools, rules, servers and
+// the audit log, and goes to the page showing the picked result
+let paletteResults = [];
+let paletteIndex = 0;
+let paletteTimer = null;
+
+function openPalette() {
+	document.body.classList.add('palette-open');
+	document.getElementById('palette').setAttribute('aria-hidden', 'false');
+	const input = document.getElementById('palette-input');
+	input.value = '';
+	renderPalette([]);
+	input.focus();
+}
+
+function closePalette() {
+	document.body.classList.remove('palette-open');
+	document.getElementById('palette').setAttribute('aria-hidden', 'true');
+}
+
+function searchPalette() {
+	clearTimeout(paletteTimer);
+	const query = document.getElementById('palette-input').value.trim();
+	if (!query) {
+		renderPalette([]);
+		return;
+	}
+	paletteTimer = setTimeout(() => {
+		fetchJSON('/api/v1/search?q=' + encodeURIComponent(query))
+			.then((data) => {
+				// Drop answers to queries typed over since
+				if (document.getElementById('palette-input').value.trim() === query) {
+					renderPalette(data.results || []);
+				}
+			})
+			.catch((err) => showToast('Search failed: ' + err.message, 'error'));
+	}, 150);
+}
+
+function renderPalette(results) {
+	paletteResults = results;
+	paletteIndex = 0;
+	const list = document.getElementById('palette-results');
+	list.innerHTML = '';
+	if (!results.length && document.getElementById('palette-input').value.trim()) {
+		list.innerHTML = '<div class="empty-state">Nothing matches.</div>';
+		return;
+	}
+	results.forEach((result, i) => {
+		const item = document.createElement('div');
+		item.className = 'palette-result' + (i === paletteIndex ? ' is-selected' : '');
+		item.innerHTML = '<span class="chip">' + escapeHTML(result.kind) + '</span>' +
+			'<div><strong>' + escapeHTML(result.title) + '</strong><p>' + escapeHTML(result.detail || '') + '</p></div>';
+		item.addEventListener('click', () => openPaletteResult(i));
+		list.appendChild(item);
+	});
+}
+
+function selectPaletteResult(i) {
+	if (!paletteResults.length) {
+		return;
+	}
+	paletteIndex = (i + paletteResults.length) % paletteResults.length;
+	document.querySelectorAll('#palette-results .palette-result').forEach((item, j) => {
+		item.classList.toggle('is-selected', j === paletteIndex);
+		if (j === paletteIndex) {
+			item.scrollIntoView({ block: 'nearest' });
+		}
+	});
+}
+
+function openPaletteResult(i) {
+	const result = paletteResults[i];
+	if (!result) {
+		return;
+	}
+	closePalette();
+	// Links to the page already shown only change the hash
+	const link = new URL(result.link, window.location.href);
+	if (link.pathname === window.location.pathname && link.search === window.location.search && link.hash) {
+		if (link.hash === window.location.hash) {
+			showLinked();
+		} else {
+			window.location.hash = link.hash;
+		}
+		return;
+	}
+	window.location.href = result.link;
+}
+
+function handlePaletteKeys(event) {
+	if ((event.metaKey || event.ctrlKey) && event.key.toLowerCase() === 'k') {
+		event.preventDefault();
+		if (document.body.classList.contains('palette-open')) {
+			closePalette();
+		} else {
+			openPalette();
+		}
+		return;
+	}
+	if (!document.body.classList.contains('palette-open')) {
+		return;
+	}
+	const actions = {
+		Escape: closePalette,
+		ArrowDown: () => selectPaletteResult(paletteIndex + 1),
+		ArrowUp: () => selectPaletteResult(paletteIndex - 1),
+		Enter: () => openPaletteResult(paletteIndex)
+	};
+	if (actions[event.key]) {
+		event.preventDefault();
+		event.stopImmediatePropagation();
+		actions[event.key]();
+	}
+}
+
+function escapeHTML(text) {
+	const div = document.createElement('div');
+	div.textContent = text;
+	return div.innerHTML;
+}
+
+document.getElementById('server-refresh').addEventListener('click', () => {
+	loadServers()
+		.then(() => showToast('Servers refreshed', 'success'))
+		.catch((err) => showToast('Failed to reload servers: ' + err.message, 'error'));
+});
+
+document.getElementById('sync-now').addEventListener('click', syncPermissions);
+
+document.getElementById('permissions-refresh').addEventListener('click', () => {
+	loadClaudePermissions()
+		.then(() => showToast('Permissions reloaded', 'success'))
+		.catch((err) => showToast('Failed to reload permissions: ' + err.message, 'error'));
+});
+
+// Toggle keywords field visibility when block_all checkbox changes
+document.getElementById('rule-block-all').addEventListener('change', (event) => {
+	const keywordsRow = document.getElementById('keywords-row');
+	keywordsRow.style.display = event.target.checked ? 'none' : 'flex';
+});
+
+document.getElementById('rule-form').addEventListener('submit', (event) => {
+	event.preventDefault();
+	const blockAll = document.getElementById('rule-block-all').checked;
+	const keywords = document.getElementById('rule-keywords').value.trim();
+
+	if (!blockAll && !keywords) {
+		showToast('Keywords are required (or enable "Block all")', 'error');
+		return;
+	}
+	const tool = document.getElementById('rule-tool').value;
+	const action = document.getElementById('rule-action').value;
+
+	let pattern, description;
+	if (blockAll) {
+		pattern = '.*';
+		description = 'Block all calls to ' + (tool === '*' ? 'all tools' : tool);
+	} else {
+		// Build regex pattern from keywords
+		const keywordList = keywords.split(',').map(k => k.trim()).filter(k => k);
+		pattern = keywordList.map(k => k.replace(/[.*+?^${}()|[\]\\]/g, '\\$&')).join('|');
+		description = 'Block keywords: ' + keywordList.join(', ');
+	}
+
+	const payload = {
+		pattern: pattern,
+		description: description,
+		action: action,
+		is_regex: true,
+		is_semantic: false,
+		tools: tool === '*' ? '' : tool,
+		schedule: document.getElementById('rule-schedule').value.trim(),
+		enabled: true,
+		block_all: blockAll,
+		permissions: DEFAULT_PERMISSIONS[action] || DEFAULT_PERMISSIONS.block
+	};
+
+	const url = editingRuleId ? '/api/v1/blocklist?id=' + editingRuleId : '/api/v1/blocklist';
+	const method = editingRuleId ? 'PUT' : 'POST';
+
+	fetchJSON(url, {
+		method: method,
+		headers: { 'Content-Type': 'application/json' },
+		body: JSON.stringify(payload)
+	})
+		.then(() => {
+			showToast(editingRuleId ? 'Rule updated' : 'Rule created', 'success');
+			closeDrawer();
+			editingRuleId = null;
+			loadRules();
+		})
+		.catch((err) => {
+			showToast('Failed to save rule: ' + err.message, 'error');
+		});
+});
+
+document.getElementById('new-rule').addEventListener('click', () => openDrawer(null));
+document.getElementById('new-rule-secondary').addEventListener('click', () => openDrawer(null));
+document.getElementById('close-drawer').addEventListener('click', closeDrawer);
+overlay.addEventListener('click', closeDrawer);
+
+document.getElementById('refresh').addEventListener('click', () => {
+	Promise.all([loadStats(), loadServers(), loadRules(), loadPolicy(), loadOrgPolicy(), loadTools(), loadKillSwitch(), loadOffline(), loadIncidents(), loadUndo(), loadDiscovered(), loadHealth()])
+		.then(updateLastRefresh)
+		.catch((err) => showToast('Refresh failed: ' + err.message, 'error'));
+});
+
+document.getElementById('killswitch').addEventListener('click', toggleKillSwitch);
+document.getElementById('offline').addEventListener('click', toggleOffline);
+document.getElementById('approve-once').addEventListener('click', () => decideApproval('once'));
+document.getElementById('approve-exception').addEventListener('click', () => decideApproval('exception'));
+document.getElementById('queued-once').addEventListener('click', () => decideQueued('once'));
+document.getElementById('queued-exception').addEventListener('click', () => decideQueued('exception'));
+document.getElementById('queued-deny').addEventListener('click', () => decideQueued('deny'));
+document.addEventListener('keydown', handlePaletteKeys);
+document.addEventListener('keydown', handleApprovalKeys);
+document.getElementById('open-palette').addEventListener('click', openPalette);
+document.getElementById('palette-input').addEventListener('input', searchPalette);
+document.getElementById('palette').addEventListener('click', (event) => {
+	if (event.target.id === 'palette') {
+		closePalette();
+	}
+});
+window.addEventListener('hashchange', showLinked);
+
+document.getElementById('rule-search').addEventListener('input', renderRules);
+document.getElementById('tool-search').addEventListener('input', renderToolCatalog);
+document.getElementById('tool-filter').addEventListener('change', renderToolCatalog);
+document.getElementById('rule-filter').addEventListener('change', renderRules);
+
+document.getElementById('cancel-rule').addEventListener('click', closeDrawer);
+
+document.addEventListener('keydown', (event) => {
+	if (event.key === 'Escape') {
+		closeDrawer();
+	}
+});
+
+loadApproval()
+	.then(() => Promise.all([loadRole(), loadStats(), loadServers(), loadRules(), loadPolicy(), loadOrgPolicy(), loadTools(), loadKillSwitch(), loadOffline(), loadIncidents(), loadUndo(), loadDiscovered(), loadHealth(), loadClaudePermissions(), loadPermissionSync()]))
+	.then(showLinked)
+	.then(updateLastRefresh)
+	.catch((err) => showToast('Load failed: ' + err.message, 'error'));
+
+setInterval(() => {
+	loadStats();
+	loadKillSwitch().catch(() => {});
+	loadOffline();
+	loadIncidents().catch(() => {});
+	loadUndo();
+	loadDiscovered();
+	checkClaudePermissions().catch(() => {});
+}, 5000);
+
+const pageSection = singlePage ? document.getElementById(singlePage) : null;
+if (pageSection && pageSection.parentElement.tagName === 'MAIN') {
+	document.body.classList.add('single-page');
+	pageSection.classList.add('is-page');
+}
+if (embedded) {
+	document.body.classList.add('embedded');
+}
+connectApprovals();
+
+const themeSelect = document.getElementById('theme');
+themeSelect.value = window.armourTheme.choice();
+themeSelect.addEventListener('change', () => window.armourTheme.set(themeSelect.value));
+
+document.body.classList.add('is-ready');
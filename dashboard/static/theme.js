@@ -0,0 +1,27 @@
+// Runs before the page renders so it never flashes in the wrong theme. The
+// theme is ?theme=, else the choice stored by the theme menu, else the
+// system setting; ?theme= is for webviews matching their editor and isn't
+// stored.
+(function () {
+	const query = new URLSearchParams(window.location.search).get('theme');
+	const system = window.matchMedia('(prefers-color-scheme: light)');
+
+	function choice() {
+		return query || localStorage.getItem('armour-theme') || 'auto';
+	}
+
+	function apply() {
+		const theme = choice();
+		document.documentElement.dataset.theme = theme === 'auto' ? (system.matches ? 'light' : 'dark') : theme;
+	}
+
+	window.armourTheme = {
+		choice: choice,
+		set: function (theme) {
+			localStorage.setItem('armour-theme', theme);
+			apply();
+		}
+	};
+	system.addEventListener('change', apply);
+	apply();
+})();
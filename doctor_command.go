@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+// handleDoctorCommand snapshots the running proxy's self-metrics and, with
+// -out, its heap profile and goroutine stacks for a bug report.
+func handleDoctorCommand(args []string) {
+	fs := cmd.NewFlagSet("doctor", "[-dashboard URL] [-token TOKEN] [-out DIR] [-json]", "Snapshot the running proxy's memory, goroutines and other self-metrics")
+	var dashboardURL, token, outDir string
+	fs.StringVar(&dashboardURL, "dashboard", "ARMOUR_DASHBOARD_URL", "http://127.0.0.1:13337", "Dashboard URL of the running proxy")
+	fs.StringVar(&token, "token", "ARMOUR_DASHBOARD_TOKEN", "", "Dashboard token; profiles need the admin token")
+	fs.StringVar(&outDir, "out", "", "", "Also save metrics.json, heap.pprof and goroutines.txt to this directory")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+	base := strings.TrimSuffix(dashboardURL, "/")
+
+	data, err := fetchDashboard(base+"/api/v1/debug", token)
+	if err != nil {
+		exitWithError("doctor", err)
+	}
+	var metrics server.SelfMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		exitWithError("doctor", fmt.Errorf("invalid debug response: %w", err))
+	}
+
+	var saved []string
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			exitWithError("doctor", err)
+		}
+		files := []struct{ name, path string }{
+			{"heap.pprof", "/api/v1/debug/pprof/heap"},
+			{"goroutines.txt", "/api/v1/debug/pprof/goroutine?debug=2"},
+		}
+		for _, f := range files {
+			profile, err := fetchDashboard(base+f.path, token)
+			if err != nil {
+				exitWithError("doctor", fmt.Errorf("failed to fetch %s: %w", f.name, err))
+			}
+			if err := os.WriteFile(filepath.Join(outDir, f.name), profile, 0o600); err != nil {
+				exitWithError("doctor", err)
+			}
+			saved = append(saved, filepath.Join(outDir, f.name))
+		}
+		pretty, _ := json.MarshalIndent(metrics, "", "  ")
+		if err := os.WriteFile(filepath.Join(outDir, "metrics.json"), pretty, 0o600); err != nil {
+			exitWithError("doctor", err)
+		}
+		saved = append([]string{filepath.Join(outDir, "metrics.json")}, saved...)
+	}
+
+	if jsonOutput {
+		emitJSON("doctor", map[string]interface{}{"metrics": metrics, "saved": saved})
+		return
+	}
+	fmt.Print(formatSelfMetrics(metrics))
+	for _, path := range saved {
+		fmt.Printf("Saved:        %s\n", path)
+	}
+}
+
+// fetchDashboard GETs a dashboard API path and returns the body, failing on
+// any status but 200.
+func fetchDashboard(url, token string) ([]byte, error) {
+	resp, err := cmd.DashboardGet(url, token)
+	if err != nil {
+		return nil, fmt.Errorf("proxy not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dashboard returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func formatSelfMetrics(m server.SelfMetrics) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Proxy:        v%s (pid %d, %s, up %s)\n", strings.TrimPrefix(m.Version, "v"), m.PID, m.GoVersion, (time.Duration(m.UptimeSeconds) * time.Second).String())
+	fmt.Fprintf(&b, "Goroutines:   %d\n", m.Goroutines)
+	fmt.Fprintf(&b, "Heap:         %s live in %d objects, %s in use, %s from the OS\n", formatBytes(int64(m.HeapAllocBytes)), m.HeapObjects, formatBytes(int64(m.HeapInuseBytes)), formatBytes(int64(m.SysBytes)))
+	fmt.Fprintf(&b, "GC:           %d runs, %dms paused\n", m.NumGC, m.GCPauseTotalMS)
+	fmt.Fprintf(&b, "Subprocesses: %d\n", m.Subprocesses)
+	if m.DBSizeBytes >= 0 {
+		fmt.Fprintf(&b, "Database:     %s\n", formatBytes(m.DBSizeBytes))
+	}
+	if m.TraceCapacity > 0 {
+		fmt.Fprintf(&b, "Trace buffer: %d/%d events\n", m.TraceEvents, m.TraceCapacity)
+	}
+	return b.String()
+}
+
+// formatBytes prints a size in the largest unit it reaches, e.g. 12.3MB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGT"[exp])
+}
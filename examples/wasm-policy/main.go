@@ -0,0 +1,65 @@
+//go:build wasip1
+
+// wasm-policy is a policy plugin compiled to WebAssembly. It blocks tool
+// calls whose arguments hold something that looks like an AWS access key.
+//
+// Build it with Go or TinyGo:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o wasm-policy.wasm ./examples/wasm-policy
+//	tinygo build -target=wasip1 -buildmode=c-shared -o wasm-policy.wasm ./examples/wasm-policy
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"unsafe"
+)
+
+var awsKey = regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)
+
+type call struct {
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+type verdict struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+// input and output keep the buffers shared with the proxy alive between calls.
+var input, output []byte
+
+// alloc returns a buffer of size bytes for the proxy to write the call to.
+//
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	input = make([]byte, size+1)
+	return uint32(uintptr(unsafe.Pointer(&input[0])))
+}
+
+// check decides on the JSON call at ptr and returns where the JSON verdict
+// is, as its pointer shifted 32 bits left ORed with its length.
+//
+//go:wasmexport check
+func check(ptr, size uint32) uint64 {
+	data := unsafe.Slice((*byte)(unsafe.Pointer(uintptr(ptr))), size)
+	v := decide(data)
+	output, _ = json.Marshal(v)
+	return uint64(uintptr(unsafe.Pointer(&output[0])))<<32 | uint64(len(output))
+}
+
+func decide(data []byte) verdict {
+	var c call
+	if err := json.Unmarshal(data, &c); err != nil {
+		return verdict{Decision: "block", Reason: "unreadable call"}
+	}
+	args, _ := json.Marshal(c.Arguments)
+	if awsKey.Match(args) {
+		return verdict{Decision: "block", Reason: "arguments hold an AWS access key", Rule: "aws_access_key"}
+	}
+	return verdict{Decision: "allow"}
+}
+
+func main() {}
@@ -5,7 +5,11 @@ go 1.24.0
 toolchain go1.24.11
 
 require (
+	github.com/klauspost/compress v1.19.2
 	github.com/modelcontextprotocol/go-sdk v1.2.0
+	github.com/tetratelabs/wazero v1.10.1
+	gopkg.in/yaml.v3 v3.0.1
+	golang.org/x/sys v0.36.0
 	modernc.org/sqlite v1.43.0
 )
 
@@ -19,7 +23,6 @@ require (
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
 	golang.org/x/oauth2 v0.30.0 // indirect
-	golang.org/x/sys v0.36.0 // indirect
 	modernc.org/libc v1.66.10 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
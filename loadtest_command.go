@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+// handleLoadtestCommand drives an HTTP-mode proxy with synthetic tools/call
+// traffic and reports throughput, latency percentiles and memory. Without
+// -target it runs the proxy in-process in front of the built-in demo server,
+// configured by the global flags such as -workers and -queue-size.
+func handleLoadtestCommand(args []string, globals cmd.CLIArgs) {
+	fs := cmd.NewFlagSet("loadtest", "[-rps N] [-duration D] [-target URL] [FLAGS]", "Load-test an HTTP-mode proxy with synthetic tools/call traffic")
+	cfg := server.LoadTestConfig{}
+	var arguments string
+	fs.IntVar(&cfg.RPS, "rps", "", 200, "Calls started per second")
+	fs.DurationVar(&cfg.Duration, "duration", "", 60*time.Second, "How long to generate traffic")
+	fs.IntVar(&cfg.Concurrency, "concurrency", "", 256, "Calls in flight at most; calls due beyond it are dropped")
+	fs.IntVar(&cfg.Sessions, "sessions", "", 16, "MCP sessions to spread the calls over")
+	fs.StringVar(&cfg.URL, "target", "", "", "MCP endpoint of a running proxy, e.g. http://127.0.0.1:8080/mcp/demo (default: an in-process proxy)")
+	fs.StringVar(&cfg.Tool, "tool", "", "echo", "Tool to call")
+	fs.StringVar(&arguments, "args", "", `{"message":"the quick brown fox jumps over the lazy dog"}`, "JSON arguments of every call")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	if !json.Valid([]byte(arguments)) {
+		exitWithError("loadtest", fmt.Errorf("-args is not valid JSON"))
+	}
+	cfg.Arguments = json.RawMessage(arguments)
+
+	if cfg.URL == "" {
+		url, stop, err := server.StartLoadTestProxy(convertCLIArgsToServerConfig(globals))
+		if err != nil {
+			exitWithError("loadtest", fmt.Errorf("failed to start proxy: %w", err))
+		}
+		defer stop()
+		cfg.URL = url
+		cfg.SampleMemory = true
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if !jsonOutput {
+		fmt.Fprintf(os.Stderr, "Sending %d calls/s to %s for %s (Ctrl+C to stop early)...\n", cfg.RPS, cfg.URL, cfg.Duration)
+	}
+	result, err := server.RunLoadTest(ctx, cfg)
+	if err != nil {
+		exitWithError("loadtest", err)
+	}
+
+	if jsonOutput {
+		emitJSON("loadtest", result)
+		return
+	}
+	fmt.Printf("Target:       %s\n", result.URL)
+	fmt.Printf("Requests:     %d in %.1fs (%d succeeded, %d failed, %d dropped)\n", result.Requests, result.Seconds, result.Succeeded, result.Failed, result.Dropped)
+	fmt.Printf("Throughput:   %.1f calls/s (target %d)\n", result.Throughput, result.TargetRPS)
+	l := result.Latency
+	fmt.Printf("Latency:      p50 %.2fms  p90 %.2fms  p99 %.2fms  max %.2fms  mean %.2fms\n", l.P50, l.P90, l.P99, l.Max, l.Mean)
+	if result.HeapPeakBytes > 0 {
+		fmt.Printf("Memory:       heap peak %s, %s after GC; %d goroutines at peak (proxy and load generator)\n",
+			formatBytes(int64(result.HeapPeakBytes)), formatBytes(int64(result.HeapEndBytes)), result.GoroutinesPeak)
+	}
+	reasons := make([]string, 0, len(result.Errors))
+	for reason := range result.Errors {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("Error:        %s (%d)\n", reason, result.Errors[reason])
+	}
+	if result.Dropped > 0 {
+		fmt.Printf("\nThe proxy did not keep up: %d calls were due while %d were in flight.\n", result.Dropped, cfg.Concurrency)
+	}
+}
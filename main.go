@@ -2,14 +2,17 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
@@ -21,40 +24,89 @@ import (
 )
 
 func main() {
-	// Handle subcommands first
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
+	configFile, err := cmd.LoadConfigFile(cmd.DefaultConfigFile())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	cmd.UseConfigFile(configFile)
+
+	args, rest, err := cmd.ParseGlobalArgs(os.Args[1:])
+	if err == flag.ErrHelp {
+		printHelp()
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Run 'mcp-proxy help' for usage.")
+		os.Exit(2)
+	}
+
+	jsonOutput = args.JSON
+
+	// Handle subcommands; global flags may precede the subcommand name
+	if len(rest) > 0 {
+		name, subArgs := rest[0], rest[1:]
+		switch name {
 		case "detect":
-			handleDetectCommand()
-			return
+			handleDetectCommand(subArgs)
 		case "up":
-			handleAutoDiscoverCommand()
-			return
+			handleAutoDiscoverCommand(subArgs)
 		case "migrate":
-			handleMigrateCommand()
-			return
+			handleMigrateCommand(subArgs)
 		case "status":
-			handleStatusCommand()
-			return
+			handleStatusCommand(subArgs)
+		case "offline":
+			handleOfflineCommand(subArgs)
+		case "doctor":
+			handleDoctorCommand(subArgs)
+		case "bench":
+			handleBenchCommand(subArgs)
+		case "loadtest":
+			handleLoadtestCommand(subArgs, args)
+		case "supervise":
+			handleSuperviseCommand(subArgs)
+		case "config":
+			handleConfigCommand(subArgs, os.Args[1:len(os.Args)-len(rest)], configFile)
 		case "backup":
-			handleBackupCommand()
-			return
+			handleBackupCommand(subArgs)
 		case "recover":
-			handleRecoverCommand()
-			return
+			handleRecoverCommand(subArgs)
 		case "serve":
-			handleServeCommand()
-			return
+			handleServeCommand(subArgs, args)
+		case "logs":
+			handleLogsCommand(subArgs)
+		case "audit":
+			handleAuditCommand(subArgs)
+		case "rules":
+			handleRulesCommand(subArgs)
+		case "policy":
+			handlePolicyCommand(subArgs)
+		case "replay":
+			handleReplayCommand(subArgs, args)
+		case "analyze":
+			handleAnalyzeCommand(subArgs)
+		case "self-update":
+			handleSelfUpdateCommand(subArgs)
+		case "service":
+			handleServiceCommand(subArgs)
 		case "version":
-			fmt.Println("mcp-proxy v1.0.16")
-			return
+			fs := cmd.NewFlagSet("version", "[-json]", "Print version")
+			addJSONFlag(fs)
+			fs.MustParse(subArgs)
+			if jsonOutput {
+				emitJSON("version", map[string]string{"version": proxy.Version})
+			} else {
+				fmt.Printf("mcp-proxy v%s\n", strings.TrimPrefix(proxy.Version, "v"))
+			}
 		case "help":
 			printHelp()
-			return
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command: %s\nRun 'mcp-proxy help' for usage.\n", name)
+			os.Exit(2)
 		}
+		return
 	}
 
-	args := cmd.ParseArgs()
 	config := convertCLIArgsToServerConfig(args)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -69,6 +121,11 @@ func main() {
 		cancel()
 	}()
 
+	// Non-blocking; logs to stderr so stdio MCP traffic is unaffected
+	if !args.NoUpdateCheck {
+		go cmd.CheckForUpdate(ctx, proxy.NewLogger(config.LogLevel))
+	}
+
 	// Route to appropriate mode
 	if config.Mode == "stdio" {
 		err := runStdioMode(ctx, config)
@@ -96,13 +153,28 @@ func runHTTPMode(ctx context.Context, config server.Config) error {
 		return fmt.Errorf("failed to create HTTP server: %v", err)
 	}
 	defer srv.Close()
+	stored := applyStoredPolicy(srv.GetRegistry(), server.NewPolicyManager(nil))
+	srv.SetTrustPolicy(stored.Trust)
+	srv.SetEgressPolicy(stored.Egress)
+	srv.SetDLPPolicy(stored.DLP)
+	srv.SetCedarPolicy(stored.Cedar)
+	srv.SetAuthorizerPolicy(stored.Authorizer)
+	srv.SetAuditPolicy(stored.Audit)
+	checkClientConfigs(srv.GetRegistry())
 
+	for _, entry := range srv.GetRegistry().Servers {
+		log.Printf("routing %s -> %s", entry.RoutePath(), entry.Name)
+	}
 	log.Printf("HTTP server starting on %s", config.ListenAddr)
 
 	return srv.ListenAndServe(ctx)
 }
 
 func runStdioMode(ctx context.Context, config server.Config) error {
+	if config.DashboardViewerToken != "" && config.DashboardAdminToken == "" {
+		return fmt.Errorf("-dashboard-viewer-token requires -dashboard-admin-token")
+	}
+
 	// 1. Initialize shared components
 	registry, err := proxy.LoadServerRegistry(config.ConfigPath)
 	if err != nil {
@@ -113,6 +185,7 @@ func runStdioMode(ctx context.Context, config server.Config) error {
 
 	statsTracker := server.NewStatsTracker()
 	policyManager := server.NewPolicyManager(statsTracker)
+	stored := applyStoredPolicy(registry, policyManager)
 	logger := proxy.NewLogger(config.LogLevel)
 	traceRecorder := proxy.NewTraceRecorder(200)
 
@@ -125,17 +198,43 @@ func runStdioMode(ctx context.Context, config server.Config) error {
 		return fmt.Errorf("failed to create stdio server: %v", err)
 	}
 	defer stdioSrv.Close()
+	stdioSrv.SetStoredPolicy(stored)
+	clients := checkClientConfigs(registry)
+
+	if config.RecordPath != "" {
+		recorder, err := proxy.OpenSessionRecorder(config.RecordPath)
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+		stdioSrv.SetRecorder(recorder)
+		log.Printf("Recording session to %s", config.RecordPath)
+	}
 
 	// 2. Start Dashboard (Dual-Head)
 	// Bind to localhost for security, hardcoded port for now (as per architecture)
 	dashboardAddr := "127.0.0.1:13337"
 	dashboardSrv := dashboard.NewDashboardServer(dashboardAddr, registry, config.ConfigPath, statsTracker, policyManager, stdioSrv.GetBlocklist(), stdioSrv.GetToolRegistry(), stdioSrv.GetDB(), logger, traceRecorder)
+	dashboardSrv.SetBackendManager(stdioSrv.GetBackendManager())
+	dashboardSrv.SetWorkQueue(stdioSrv.GetWorkQueue())
+	dashboardSrv.SetApprovals(stdioSrv.GetApprovals())
+	dashboardSrv.SetKillSwitch(stdioSrv.GetKillSwitch())
+	dashboardSrv.SetOfflineSwitch(stdioSrv.GetOfflineSwitch())
+	dashboardSrv.SetUndoJournal(stdioSrv.GetUndoJournal())
+	dashboardSrv.SetDiscoveryGate(stdioSrv.GetDiscoveryGate())
+	dashboardSrv.SetClientConfigs(clients)
+	dashboardSrv.SetToolClasses(stored.Classes)
+	dashboardSrv.SetAccessTokens(config.DashboardAdminToken, config.DashboardViewerToken)
+	dashboardSrv.AllowOrigins(config.AllowedOrigins, config.AllowedHosts)
 
 	if err := dashboardSrv.Start(); err != nil {
 		log.Printf("Warning: failed to start dashboard: %v", err)
 	} else {
 		// Log to stderr so it doesn't interfere with stdio MCP traffic on stdout
 		fmt.Fprintf(os.Stderr, "Dashboard started on http://%s\n", dashboardAddr)
+		if config.DashboardAdminToken == "" {
+			fmt.Fprintf(os.Stderr, "Approve blocked calls from http://%s/?token=%s\n", dashboardAddr, dashboardSrv.ApproverToken())
+		}
 	}
 	// Keep dashboard running even after stdio exits
 	defer func() {
@@ -143,6 +242,17 @@ func runStdioMode(ctx context.Context, config server.Config) error {
 		dashboardSrv.Stop()
 	}()
 
+	if config.Management.ListenAddr != "" {
+		manageSrv, err := server.NewManagementServer(config.Management, stdioSrv)
+		if err != nil {
+			return fmt.Errorf("failed to create management API: %v", err)
+		}
+		if err := manageSrv.Start(); err != nil {
+			return err
+		}
+		defer manageSrv.Stop()
+	}
+
 	// 3. Start Stdio Server in a separate goroutine
 	log.Printf("Stdio server starting (config: %s)", config.ConfigPath)
 
@@ -159,72 +269,118 @@ func runStdioMode(ctx context.Context, config server.Config) error {
 	return ctx.Err()
 }
 
-func handleDetectCommand() {
+// checkClientConfigs warns about MCP servers that Claude Code, Claude
+// Desktop or Cursor run directly instead of through the proxy, e.g. after
+// an agent silently re-added a raw server.
+func checkClientConfigs(registry *proxy.ServerRegistry) *server.ClientConfigReport {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	projectDir, _ := os.Getwd()
+	report := server.CheckClientConfigs(homeDir, projectDir, registry)
+	for _, bypass := range report.Bypasses {
+		log.Printf("WARNING: %s", bypass)
+	}
+	if len(report.Bypasses) > 0 {
+		log.Printf("WARNING: run '%s' to route these servers through the proxy again", report.Remedy)
+	}
+	return report
+}
+
+func handleDetectCommand(args []string) {
+	fs := cmd.NewFlagSet("detect", "[-json]", "Detect existing MCP servers in standard locations")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
 	detector, err := cmd.NewServerDetector()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		exitWithError("detect", err)
 	}
 
 	servers, err := detector.DetectAll()
 	if err != nil && len(servers) == 0 {
-		fmt.Fprintf(os.Stderr, "detection failed: %v\n", err)
-		os.Exit(1)
+		exitWithError("detect", fmt.Errorf("detection failed: %w", err))
 	}
 
 	// Output JSON for machine parsing
-	if len(os.Args) > 2 && os.Args[2] == "--json" {
-		data, _ := json.Marshal(servers)
-		fmt.Println(string(data))
+	if jsonOutput {
+		if servers == nil {
+			servers = []cmd.DetectedServer{}
+		}
+		emitJSON("detect", map[string]interface{}{
+			"servers": servers,
+			"count":   len(servers),
+		})
 	} else {
 		// Human-readable output
 		fmt.Println(cmd.FormatDetectionResults(servers))
 	}
 }
 
-func handleAutoDiscoverCommand() {
-	scanner := cmd.NewProjectScanner(".")
+func handleAutoDiscoverCommand(args []string) {
+	fs := cmd.NewFlagSet("up", "[-json] [-dir PATH]", "Auto-discover and start MCP servers in the current project")
+	var dir string
+	addJSONFlag(fs)
+	fs.StringVar(&dir, "dir", "", ".", "Project directory to scan")
+	fs.MustParse(args)
+
+	scanner := cmd.NewProjectScanner(dir)
 	project, err := scanner.Scan()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "auto-discovery failed: %v\n", err)
-		os.Exit(1)
+		exitWithError("up", fmt.Errorf("auto-discovery failed: %w", err))
 	}
 
-	if len(os.Args) > 2 && os.Args[2] == "--json" {
-		data, _ := json.Marshal(project)
-		fmt.Println(string(data))
-	} else {
-		fmt.Println(cmd.FormatDiscoveryResults(project))
+	if jsonOutput {
+		emitJSON("up", map[string]interface{}{
+			"project": project,
+			"started": false,
+		})
+		return
 	}
 
+	fmt.Println(cmd.FormatDiscoveryResults(project))
+
 	// TODO: Actually start the servers and create temp config
 	fmt.Println("\n(Server startup not yet implemented)")
 }
 
-func handleMigrateCommand() {
-	fmt.Println("🔍 Sentinel Proxy Migration Tool")
-	fmt.Println("================================")
+func handleMigrateCommand(args []string) {
+	fs := cmd.NewFlagSet("migrate", "[-policy MODE] [-json]", "Route detected MCP servers through the proxy")
+	var policy string
+	fs.StringVar(&policy, "policy", "ARMOUR_POLICY", "moderate", "Policy mode: strict, moderate, permissive")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	// Progress output is suppressed in JSON mode so stdout stays parseable
+	say := func(format string, a ...interface{}) {
+		if !jsonOutput {
+			fmt.Printf(format, a...)
+		}
+	}
+
+	say("🔍 Sentinel Proxy Migration Tool\n")
+	say("================================\n")
 
 	// 1. Detect existing servers
-	fmt.Print("• Detecting existing MCP servers... ")
+	say("• Detecting existing MCP servers... ")
 	detector, err := cmd.NewServerDetector()
 	if err != nil {
-		fmt.Printf("Failed to create detector: %v\n", err)
-		os.Exit(1)
+		exitWithError("migrate", fmt.Errorf("failed to create detector: %w", err))
 	}
 
 	servers, err := detector.DetectAll()
 	if err != nil {
-		fmt.Printf("Detection error: %v\n", err)
+		say("Detection error: %v\n", err)
 		// Continue if we found any servers
 	}
 
 	if len(servers) == 0 {
-		fmt.Println("No existing servers found.")
+		say("No existing servers found.\n")
 		// Ask if user wants to install anyway? For now, just exit or proceed with empty
-		fmt.Println("Proceeding with empty registry.")
+		say("Proceeding with empty registry.\n")
 	} else {
-		fmt.Printf("Found %d servers.\n", len(servers))
+		say("Found %d servers.\n", len(servers))
 	}
 
 	// 2. Convert types (cmd.DetectedServer -> server.DetectedServer)
@@ -243,18 +399,26 @@ func handleMigrateCommand() {
 	}
 
 	// 3. Perform Migration
-	fmt.Print("• Migrating configuration... ")
+	say("• Migrating configuration... ")
 	migrator, err := server.NewConfigMigrator()
 	if err != nil {
-		fmt.Printf("Failed to create migrator: %v\n", err)
-		os.Exit(1)
+		exitWithError("migrate", fmt.Errorf("failed to create migrator: %w", err))
 	}
 
-	// Default to moderate policy
-	result, err := migrator.MigrateWithServers(serverServers, "moderate")
+	result, err := migrator.MigrateWithServers(serverServers, policy)
 	if err != nil {
-		fmt.Printf("Migration failed: %v\n", err)
-		os.Exit(1)
+		exitWithError("migrate", fmt.Errorf("migration failed: %w", err))
+	}
+
+	if jsonOutput {
+		emitJSON("migrate", map[string]interface{}{
+			"servers_migrated":  result.ServersMigrated,
+			"proxy_config_path": result.ProxyConfigPath,
+			"backup_path":       result.BackupPath,
+			"policy":            policy,
+			"restart_required":  true,
+		})
+		return
 	}
 
 	fmt.Println("Done!")
@@ -265,35 +429,72 @@ func handleMigrateCommand() {
 	fmt.Println("\n⚠️  Action Required: Restart Claude Code to apply changes.")
 }
 
-func handleStatusCommand() {
-	dashboardURL := "http://localhost:13337"
+func handleStatusCommand(args []string) {
+	fs := cmd.NewFlagSet("status", "[-dashboard URL] [-token TOKEN] [-check] [-json]", "Show proxy health and open the dashboard")
+	var dashboardURL, token string
+	var check bool
+	fs.StringVar(&dashboardURL, "dashboard", "ARMOUR_DASHBOARD_URL", "http://localhost:13337", "Dashboard URL of the running proxy")
+	fs.StringVar(&token, "token", "ARMOUR_DASHBOARD_TOKEN", "", "Dashboard token, if the dashboard requires one")
+	fs.BoolVar(&check, "check", "", false, "Only check health; exit 1 if unhealthy (for container healthchecks)")
+	addJSONFlag(fs)
+	fs.MustParse(args)
 
 	// Check if dashboard is reachable
-	client := http.Client{
-		Timeout: 2 * time.Second,
-	}
-
-	resp, err := client.Get(dashboardURL + "/api/health")
+	resp, err := cmd.DashboardGet(dashboardURL+"/api/health", token)
 	if err != nil {
+		if jsonOutput {
+			cmd.WriteJSON(os.Stdout, "status", map[string]interface{}{
+				"running":       false,
+				"dashboard_url": dashboardURL,
+			}, fmt.Errorf("proxy not running: %w", err))
+			os.Exit(1)
+		}
 		fmt.Println("Proxy not running. Start Claude Code to activate.")
 		os.Exit(1)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Proxy returned status %d\n", resp.StatusCode)
+	var health server.HealthReport
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		exitWithError("status", fmt.Errorf("invalid health response (status %d): %w", resp.StatusCode, err))
+	}
+	healthy := health.Status != server.HealthUnhealthy
+
+	if jsonOutput {
+		data := map[string]interface{}{
+			"running":       true,
+			"dashboard_url": dashboardURL,
+			"health":        health,
+		}
+		if !healthy {
+			cmd.WriteJSON(os.Stdout, "status", data, fmt.Errorf("proxy is %s", health.Status))
+			os.Exit(1)
+		}
+		emitJSON("status", data)
+		return
+	}
+
+	fmt.Print(formatHealthReport(health))
+	if !healthy {
 		os.Exit(1)
 	}
+	if check {
+		return
+	}
 
-	// Open browser directly
+	// Open browser directly; the dashboard keeps the token in a cookie
+	openURL := dashboardURL
+	if token != "" {
+		openURL = strings.TrimSuffix(dashboardURL, "/") + "/?token=" + url.QueryEscape(token)
+	}
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
 	case "darwin":
-		cmd = exec.Command("open", dashboardURL)
+		cmd = exec.Command("open", openURL)
 	case "linux":
-		cmd = exec.Command("xdg-open", dashboardURL)
+		cmd = exec.Command("xdg-open", openURL)
 	case "windows":
-		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", dashboardURL)
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", openURL)
 	default:
 		fmt.Printf("Dashboard: %s\n", dashboardURL)
 		return
@@ -304,80 +505,294 @@ func handleStatusCommand() {
 	}
 }
 
-func convertCLIArgsToServerConfig(args cmd.CLIArgs) server.Config {
-	var origins []string
-	if args.Origins != "" {
-		origins = []string{args.Origins}
+func formatHealthReport(h server.HealthReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Proxy:       %s (v%s, up %s)\n", h.Status, strings.TrimPrefix(h.Version, "v"), (time.Duration(h.UptimeSeconds) * time.Second).String())
+	fmt.Fprintf(&b, "Database:    %s\n", describeHealthCheck(h.Database))
+	fmt.Fprintf(&b, "Rules store: %s\n", describeHealthCheck(h.RulesStore))
+	if h.Backends != nil {
+		fmt.Fprintf(&b, "Backends:    %d/%d ready", h.Backends.Ready, h.Backends.Total)
+		if len(h.Backends.Quarantined) > 0 {
+			fmt.Fprintf(&b, " (quarantined after crash-looping: %s)", strings.Join(h.Backends.Quarantined, ", "))
+		}
+		b.WriteString("\n")
+	}
+	if h.Clients != nil {
+		if len(h.Clients.Bypasses) == 0 {
+			fmt.Fprintf(&b, "Clients:     ok (%d client configs checked)\n", len(h.Clients.Configs))
+		} else {
+			fmt.Fprintf(&b, "Clients:     ⚠️  %d server(s) bypass the proxy\n", len(h.Clients.Bypasses))
+			for _, bypass := range h.Clients.Bypasses {
+				fmt.Fprintf(&b, "  - %s\n", bypass)
+			}
+			fmt.Fprintf(&b, "  Run '%s' to route them through the proxy again.\n", h.Clients.Remedy)
+		}
+	}
+	if h.Supervisor != nil {
+		if h.Supervisor.Crashes == 0 || h.Supervisor.LastCrash == nil {
+			fmt.Fprintf(&b, "Supervisor:  no crashes\n")
+		} else {
+			fmt.Fprintf(&b, "Supervisor:  restarted after %d crash(es), last at %s\n", h.Supervisor.Crashes, h.Supervisor.LastCrash.Local().Format(time.DateTime))
+			if h.Supervisor.LastDump != "" {
+				fmt.Fprintf(&b, "  Crash dump: %s\n", h.Supervisor.LastDump)
+			}
+		}
+	}
+	if h.Host != nil {
+		vpn := "off VPN"
+		if h.Host.VPN {
+			vpn = "on VPN (" + strings.Join(h.Host.VPNInterfaces, ", ") + ")"
+		}
+		fmt.Fprintf(&b, "Host:        %s@%s (%s), %s", h.Host.User, h.Host.Hostname, h.Host.OS, vpn)
+		if h.Host.SSID != "" {
+			fmt.Fprintf(&b, ", Wi-Fi %s", h.Host.SSID)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func describeHealthCheck(c server.HealthCheck) string {
+	if c.Error != "" {
+		return c.Status + " (" + c.Error + ")"
+	}
+	return c.Status
+}
+
+// jsonOutput is set by the global -json flag or a subcommand's -json flag.
+var jsonOutput bool
+
+// addJSONFlag registers -json on a subcommand, defaulting to the global setting.
+func addJSONFlag(fs *cmd.FlagSet) {
+	fs.BoolVar(&jsonOutput, "json", "", jsonOutput, "Emit machine-readable JSON")
+}
+
+// emitJSON writes a successful JSON envelope to stdout.
+func emitJSON(command string, data interface{}) {
+	cmd.WriteJSON(os.Stdout, command, data, nil)
+}
+
+// exitWithError reports err (as a JSON envelope in -json mode) and exits 1.
+func exitWithError(command string, err error) {
+	if jsonOutput {
+		cmd.WriteJSON(os.Stdout, command, nil, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
 	}
+	os.Exit(1)
+}
 
+// redirectStdout sends stdout to stderr until the returned function is called.
+func redirectStdout() func() {
+	orig := os.Stdout
+	os.Stdout = os.Stderr
+	return func() { os.Stdout = orig }
+}
+
+// pingInterval maps -ping-interval 0 (disabled) to the negative value server.Config uses for it.
+func pingInterval(d time.Duration) time.Duration {
+	if d == 0 {
+		return -1
+	}
+	return d
+}
+
+func convertCLIArgsToServerConfig(args cmd.CLIArgs) server.Config {
 	return server.Config{
-		ListenAddr:     args.ListenAddr,
-		Mode:           args.Mode,
-		LogLevel:       args.LogLevel,
-		DBPath:         args.DBPath,
-		ConfigPath:     args.ConfigPath,
-		AllowedOrigins: origins,
+		ListenAddr:         args.ListenAddr,
+		Mode:               args.Mode,
+		LogLevel:           args.LogLevel,
+		DBPath:             args.DBPath,
+		ConfigPath:         args.ConfigPath,
+		AllowedOrigins:     splitList(args.Origins),
+		AllowedHosts:       splitList(args.AllowedHosts),
+		RecordPath:         args.Record,
+		MaxMessageSize:     args.MaxMessageSize,
+		Workers:            args.Workers,
+		SessionConcurrency: args.SessionConcurrency,
+		QueueSize:          args.QueueSize,
+		PingInterval:       pingInterval(args.PingInterval),
+		TLS: server.TLSConfig{
+			CertFile:           args.TLSCert,
+			KeyFile:            args.TLSKey,
+			ClientCAFile:       args.TLSClientCA,
+			ClientFingerprints: splitList(args.TLSClientFingerprints),
+		},
+		DisableCompression:   args.NoCompression,
+		IdleTimeout:          args.IdleTimeout,
+		H2C:                  args.H2C,
+		TenantsPath:          args.Tenants,
+		DashboardAdminToken:  args.DashboardAdminToken,
+		DashboardViewerToken: args.DashboardViewerToken,
+		Management: server.ManagementConfig{
+			ListenAddr: args.ManageListen,
+			Token:      args.ManageToken,
+			TLS: server.TLSConfig{
+				CertFile:           args.ManageTLSCert,
+				KeyFile:            args.ManageTLSKey,
+				ClientCAFile:       args.ManageTLSClientCA,
+				ClientFingerprints: splitList(args.ManageTLSClientFingerprints),
+			},
+		},
+		PolicyPlugins: splitList(args.PolicyPlugins),
+		OPAURL:        args.OPAURL,
+
+		AuditAnchorPath:     args.AuditAnchor,
+		AuditAnchorInterval: args.AuditAnchorInterval,
+		Retention:           args.Retention,
+		DemoServer:          args.WithDemoServer,
+		Chaos:               args.Chaos,
+		TraceSample:         args.TraceSample,
+		TracePersist:        args.TracePersist,
+		TraceRetention:      args.TraceRetention,
 	}
 }
 
-func handleBackupCommand() {
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func handleSelfUpdateCommand(args []string) {
+	fs := cmd.NewFlagSet("self-update", "[-check] [-force] [-insecure-skip-signature] [-json]", "Download and install the latest release")
+	var checkOnly, force, skipSignature bool
+	fs.BoolVar(&checkOnly, "check", "", false, "Only report whether a newer release exists")
+	fs.BoolVar(&force, "force", "", false, "Reinstall even if already up to date")
+	fs.BoolVar(&skipSignature, "insecure-skip-signature", cmd.InsecureSkipSignatureEnv, false, "Install a release whose checksums aren't signed by the release key (INSECURE)")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	updater, err := cmd.NewUpdaterFromEnv()
+	if err != nil {
+		exitWithError("self-update", err)
+	}
+	if skipSignature {
+		updater.InsecureSkipSignature = true
+	}
+	if updater.InsecureSkipSignature {
+		fmt.Fprintln(os.Stderr, "WARNING: the release signature is not checked; anyone who can change the release can replace this binary")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	rel, err := updater.LatestRelease(ctx)
+	if err != nil {
+		exitWithError("self-update", err)
+	}
+
+	available := cmd.IsNewerVersion(rel.TagName, proxy.Version)
+	result := map[string]interface{}{
+		"current":   proxy.Version,
+		"latest":    rel.TagName,
+		"available": available,
+		"installed": false,
+	}
+
+	if checkOnly || (!available && !force) {
+		if jsonOutput {
+			emitJSON("self-update", result)
+		} else if available {
+			fmt.Printf("Update available: %s (running %s)\n", rel.TagName, proxy.Version)
+		} else {
+			fmt.Printf("Already up to date (%s)\n", proxy.Version)
+		}
+		return
+	}
+
+	if !jsonOutput {
+		fmt.Printf("Downloading %s...\n", rel.TagName)
+	}
+	binary, err := updater.DownloadBinary(ctx, rel)
+	if err != nil {
+		exitWithError("self-update", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		exitWithError("self-update", fmt.Errorf("failed to locate running binary: %w", err))
+	}
+	if err := cmd.ReplaceExecutable(exe, binary); err != nil {
+		exitWithError("self-update", err)
+	}
+
+	result["installed"] = true
+	if jsonOutput {
+		emitJSON("self-update", result)
+		return
+	}
+	fmt.Printf("✓ Updated %s to %s\n", exe, rel.TagName)
+	fmt.Println("Restart Claude Code to use the new version.")
+}
+
+func handleBackupCommand(args []string) {
+	fs := cmd.NewFlagSet("backup", "[-json]", "Backup MCP configurations to ~/.armour/backup.json")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	if jsonOutput {
+		// CreateBackup reports progress on stdout; keep it off the JSON stream
+		restore := redirectStdout()
+		err := cmd.CreateBackup()
+		restore()
+		if err != nil {
+			exitWithError("backup", fmt.Errorf("backup failed: %w", err))
+		}
+		emitJSON("backup", map[string]interface{}{"backup_path": cmd.BackupPath()})
+		return
+	}
+
 	if err := cmd.CreateBackup(); err != nil {
 		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func handleRecoverCommand() {
+func handleRecoverCommand(args []string) {
+	fs := cmd.NewFlagSet("recover", "[-json]", "Restore MCP configurations from ~/.armour/backup.json")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	if jsonOutput {
+		restore := redirectStdout()
+		err := cmd.RestoreBackup()
+		restore()
+		if err != nil {
+			exitWithError("recover", fmt.Errorf("recovery failed: %w", err))
+		}
+		emitJSON("recover", map[string]interface{}{"backup_path": cmd.BackupPath()})
+		return
+	}
+
 	if err := cmd.RestoreBackup(); err != nil {
 		fmt.Fprintf(os.Stderr, "recovery failed: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func handleServeCommand() {
-	// Parse serve-specific flags
-	port := 8084
-	dbPath := ""
-	apiKey := os.Getenv("ANTHROPIC_API_KEY")
-	logLevel := "info"
-
-	for i := 2; i < len(os.Args); i++ {
-		switch os.Args[i] {
-		case "-port":
-			if i+1 < len(os.Args) {
-				fmt.Sscanf(os.Args[i+1], "%d", &port)
-				i++
-			}
-		case "-db":
-			if i+1 < len(os.Args) {
-				dbPath = os.Args[i+1]
-				i++
-			}
-		case "-api-key":
-			if i+1 < len(os.Args) {
-				apiKey = os.Args[i+1]
-				i++
-			}
-		case "-log-level":
-			if i+1 < len(os.Args) {
-				logLevel = os.Args[i+1]
-				i++
-			}
-		}
-	}
-
-	// Default DB path
-	if dbPath == "" {
-		homeDir, _ := os.UserHomeDir()
-		dbPath = homeDir + "/.armour/rules.db"
-	}
+// serveFlagSet defines the rules server flags on a new flag set bound to
+// config. The log level defaults to the proxy-wide one; origins and hosts are
+// the proxy-wide flags.
+func serveFlagSet(config *server.RulesServerConfig, globals cmd.CLIArgs) *cmd.FlagSet {
+	fs := cmd.NewFlagSet("serve", "[FLAGS]", "Start the rules server for instant policy enforcement")
+	fs.IntVar(&config.Port, "port", "ARMOUR_RULES_PORT", 8084, "Rules server port")
+	fs.StringVar(&config.DBPath, "db", "ARMOUR_RULES_DB", server.DefaultRulesDBPath(), "Rules database path")
+	fs.StringVar(&config.APIKey, "api-key", "ANTHROPIC_API_KEY", "", "API key for semantic matching")
+	fs.StringVar(&config.LogLevel, "log-level", "ARMOUR_LOG_LEVEL", globals.LogLevel, "Log level: debug, info, warn, error")
+	config.AllowedOrigins = splitList(globals.Origins)
+	config.AllowedHosts = splitList(globals.AllowedHosts)
+	return fs
+}
 
-	config := server.RulesServerConfig{
-		Port:     port,
-		DBPath:   dbPath,
-		APIKey:   apiKey,
-		LogLevel: logLevel,
-	}
+func handleServeCommand(args []string, globals cmd.CLIArgs) {
+	var config server.RulesServerConfig
+	serveFlagSet(&config, globals).MustParse(args)
 
 	srv, err := server.NewRulesServer(config)
 	if err != nil {
@@ -402,9 +817,264 @@ func handleServeCommand() {
 	srv.Stop()
 }
 
+func handleLogsCommand(args []string) {
+	fs := cmd.NewFlagSet("logs", "[FLAGS]", "Tail proxy trace events from the running proxy")
+	var dashboardURL, token, tool, backend, since string
+	var blockedOnly, errorsOnly, follow bool
+	var interval time.Duration
+	fs.StringVar(&dashboardURL, "dashboard", "ARMOUR_DASHBOARD_URL", "http://127.0.0.1:13337", "Dashboard URL of the running proxy")
+	fs.StringVar(&token, "token", "ARMOUR_DASHBOARD_TOKEN", "", "Dashboard token, if the dashboard requires one")
+	fs.StringVar(&tool, "tool", "", "", "Only show events mentioning this tool")
+	fs.StringVar(&backend, "backend", "", "", "Only show events for this backend")
+	fs.BoolVar(&blockedOnly, "blocked-only", "", false, "Only show blocklist events")
+	fs.BoolVar(&errorsOnly, "errors-only", "", false, "Only show events of failed steps")
+	fs.StringVar(&since, "since", "", "", "First show events persisted since a date or age such as 2h (needs -trace-persist)")
+	fs.BoolVar(&follow, "follow", "", true, "Keep polling for new events")
+	fs.DurationVar(&interval, "interval", "", time.Second, "Polling interval")
+	fs.MustParse(args)
+
+	filter := cmd.TraceFilter{Tool: tool, Backend: backend, BlockedOnly: blockedOnly, ErrorsOnly: errorsOnly}
+	if since != "" {
+		events, err := cmd.FetchStoredTraceEvents(dashboardURL, token, since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "logs: %v\n", err)
+			os.Exit(1)
+		}
+		for _, ev := range events {
+			if filter.Matches(ev) {
+				fmt.Println(cmd.FormatTraceEvent(ev))
+			}
+			if ev.Time.After(filter.Since) {
+				filter.Since = ev.Time
+			}
+		}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		events, err := cmd.FetchTraceEvents(dashboardURL, token)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "logs: %v\n", err)
+			os.Exit(1)
+		}
+		for _, ev := range events {
+			if filter.Matches(ev) {
+				fmt.Println(cmd.FormatTraceEvent(ev))
+			}
+			if ev.Time.After(filter.Since) {
+				filter.Since = ev.Time
+			}
+		}
+
+		if !follow {
+			return
+		}
+		select {
+		case <-sigChan:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func handleAuditCommand(args []string) {
+	if len(args) < 1 || (args[0] != "tail" && args[0] != "verify" && args[0] != "purge") {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy audit tail|verify|purge [FLAGS]\nRun 'mcp-proxy audit <command> -help' for flags.")
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "verify":
+		handleAuditVerify(args[1:])
+		return
+	case "purge":
+		handleAuditPurge(args[1:])
+		return
+	}
+
+	fs := cmd.NewFlagSet("audit tail", "[FLAGS]", "Tail audit log entries from the running proxy or a database")
+	var dbPath, dashboardURL, token, tool, backend, requestID, repo string
+	var blockedOnly, follow, showQuiet bool
+	var lines int
+	var interval time.Duration
+	fs.StringVar(&dbPath, "db", "ARMOUR_DB", "", "Read directly from this SQLite database instead of the running proxy")
+	fs.StringVar(&dashboardURL, "dashboard", "ARMOUR_DASHBOARD_URL", "http://127.0.0.1:13337", "Dashboard URL of the running proxy")
+	fs.StringVar(&token, "token", "ARMOUR_DASHBOARD_TOKEN", "", "Dashboard token, if the dashboard requires one")
+	fs.StringVar(&tool, "tool", "", "", "Only show entries for tools containing this name")
+	fs.StringVar(&backend, "backend", "", "", "Only show entries for this backend")
+	fs.BoolVar(&blockedOnly, "blocked-only", "", false, "Only show blocked calls")
+	fs.StringVar(&requestID, "request-id", "", "", "Only show entries for the call with this request ID")
+	fs.StringVar(&repo, "repo", "", "", "Only show entries of sessions in git repositories containing this name")
+	fs.BoolVar(&showQuiet, "quiet", "", false, "Show each allowed call of a quiet tool instead of collapsing runs of them")
+	fs.IntVar(&lines, "n", "", 20, "Number of existing entries to show first")
+	fs.BoolVar(&follow, "follow", "", true, "Keep polling for new entries")
+	fs.DurationVar(&interval, "interval", "", time.Second, "Polling interval")
+	fs.MustParse(args[1:])
+
+	filter := server.AuditFilter{Tool: tool, Backend: backend, BlockedOnly: blockedOnly, RequestID: requestID, Repo: repo, Limit: lines}
+
+	fetch := func(f server.AuditFilter) ([]server.AuditEntry, error) {
+		return server.FetchAuditLog(dashboardURL, token, f)
+	}
+	if dbPath != "" {
+		db, err := sql.Open("sqlite", "file:"+dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "audit: failed to open database: %v\n", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		fetch = func(f server.AuditFilter) ([]server.AuditEntry, error) {
+			return server.QueryAuditLog(db, f)
+		}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		entries, err := fetch(filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "audit: %v\n", err)
+			os.Exit(1)
+		}
+		for _, line := range server.FormatAuditEntries(entries, !showQuiet) {
+			fmt.Println(line)
+		}
+		for _, e := range entries {
+			if e.ID > filter.AfterID {
+				filter.AfterID = e.ID
+			}
+		}
+		// After the initial backlog, fetch everything new on each poll
+		filter.Limit = 1000
+
+		if !follow {
+			return
+		}
+		select {
+		case <-sigChan:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// handleAuditVerify checks the audit log's hash chain and exits non-zero if
+// it is broken.
+func handleAuditVerify(args []string) {
+	fs := cmd.NewFlagSet("audit verify", "[FLAGS]", "Check the audit log's hash chain for modified, removed or reordered entries")
+	var dbPath, anchorPath string
+	fs.StringVar(&dbPath, "db", "ARMOUR_DB", "", "SQLite database holding the audit log")
+	fs.StringVar(&anchorPath, "anchor", "ARMOUR_AUDIT_ANCHOR", "", "Also check the log against the anchors in this file (see -audit-anchor)")
+	fs.MustParse(args)
+	if dbPath == "" {
+		fmt.Fprintln(os.Stderr, "audit verify: -db is required")
+		os.Exit(2)
+	}
+
+	var anchors []server.AuditAnchor
+	if anchorPath != "" {
+		var err error
+		if anchors, err = server.ReadAuditAnchors(anchorPath); err != nil {
+			exitWithError("audit verify", err)
+		}
+	}
+	db, err := sql.Open("sqlite", "file:"+dbPath)
+	if err != nil {
+		exitWithError("audit verify", fmt.Errorf("failed to open database: %w", err))
+	}
+	defer db.Close()
+	v, err := server.VerifyAuditLog(db, anchors)
+	if err != nil {
+		exitWithError("audit verify", err)
+	}
+
+	if jsonOutput {
+		emitJSON("audit verify", map[string]interface{}{
+			"intact":    v.OK(),
+			"entries":   v.Entries,
+			"unchained": v.Unchained,
+			"anchors":   v.Anchors,
+			"purged":    v.Purged,
+			"head":      v.Head,
+			"problems":  v.Problems,
+		})
+	} else {
+		fmt.Printf("Checked %d entries", v.Entries)
+		if len(anchors) > 0 {
+			fmt.Printf(" against %d of %d anchors", v.Anchors, len(anchors))
+		}
+		fmt.Println()
+		if v.Unchained > 0 {
+			fmt.Printf("%d older entries predate hash chaining and were not checked\n", v.Unchained)
+		}
+		if v.Purged > 0 {
+			fmt.Printf("Entries through %d were purged; the chain is checked from there\n", v.Purged)
+		}
+		if v.Head != nil {
+			fmt.Printf("Head: entry %d, %s\n", v.Head.ID, v.Head.Hash)
+		}
+		for _, p := range v.Problems {
+			fmt.Printf("  ✗ %s\n", p)
+		}
+		if v.OK() {
+			fmt.Println("✓ The audit log is intact")
+		}
+	}
+	if !v.OK() {
+		os.Exit(1)
+	}
+}
+
+// handleAuditPurge deletes audit entries and traces older than -before,
+// through the running proxy's dashboard or directly in a database.
+func handleAuditPurge(args []string) {
+	fs := cmd.NewFlagSet("audit purge", "-before DATE|AGE [FLAGS]", "Delete audit entries and traces recorded before a date or age, e.g. 2026-01-31 or 30d")
+	var before, dbPath, dashboardURL, token string
+	fs.StringVar(&before, "before", "", "", "Purge entries before this date (2006-01-02 or RFC 3339) or older than this age (e.g. 30d)")
+	fs.StringVar(&dbPath, "db", "ARMOUR_DB", "", "Purge this SQLite database directly instead of asking the running proxy")
+	fs.StringVar(&dashboardURL, "dashboard", "ARMOUR_DASHBOARD_URL", "http://127.0.0.1:13337", "Dashboard URL of the running proxy")
+	fs.StringVar(&token, "token", "ARMOUR_DASHBOARD_TOKEN", "", "Dashboard token, if the dashboard requires one")
+	fs.MustParse(args)
+	if before == "" {
+		fmt.Fprintln(os.Stderr, "audit purge: -before is required")
+		os.Exit(2)
+	}
+	cutoff, err := server.ParsePurgeBefore(before, time.Now())
+	if err != nil {
+		exitWithError("audit purge", err)
+	}
+
+	var result *server.PurgeResult
+	if dbPath != "" {
+		db, err := sql.Open("sqlite", "file:"+dbPath)
+		if err != nil {
+			exitWithError("audit purge", fmt.Errorf("failed to open database: %w", err))
+		}
+		defer db.Close()
+		result, err = server.PurgeAuditLog(db, cutoff)
+	} else {
+		result, err = server.RequestAuditPurge(dashboardURL, token, cutoff)
+	}
+	if err != nil {
+		exitWithError("audit purge", err)
+	}
+
+	if jsonOutput {
+		emitJSON("audit purge", result)
+		return
+	}
+	fmt.Printf("Purged %d audit entries before %s\n", result.Entries, result.Before.Local().Format(time.RFC3339))
+	if dbPath == "" {
+		fmt.Printf("Dropped %d trace events\n", result.Traces)
+	}
+	fmt.Printf("Database: %s -> %s (%s reclaimed)\n", formatBytes(result.SizeBefore), formatBytes(result.SizeAfter), formatBytes(result.Reclaimed))
+}
+
 func printHelp() {
 	fmt.Print(`
-MCP Go Proxy v1.0.16
+MCP Go Proxy
 
 USAGE:
   mcp-proxy [FLAGS] [COMMAND]
@@ -413,33 +1083,176 @@ COMMANDS:
   detect        Detect existing MCP servers in standard locations
   up            Auto-discover and start MCP servers in current project
   serve         Start the rules server for instant policy enforcement
+  logs          Tail proxy trace events from the running proxy
+  audit tail    Tail audit log entries (from the running proxy or -db)
+  audit verify  Check the audit log's hash chain (and -anchor file) for tampering
+  audit purge   Delete audit entries and traces older than -before (see -retention)
+  rules         Manage rules: list, add, rm, enable, disable, test
+  policy        Policy-as-code: apply, diff, export or simulate armour.policy.yaml
+  replay        Re-run a session recorded with -record and report changed responses
+  analyze       Report which tool calls of a Claude Code transcript the current
+                rules would have blocked, without running them
+  status        Show proxy health (-check for container healthchecks)
+  offline       Turn offline mode on or off, or show it (blocks http/sse servers
+                and URL arguments; ARMOUR_OFFLINE=1 starts the proxy offline)
+  doctor        Snapshot the running proxy's memory, goroutines and subprocesses
+                (-out DIR also saves heap and goroutine profiles)
+  bench         Measure per-call overhead at 0-1000 rules (-baseline FILE flags regressions)
+  loadtest      Drive an HTTP-mode proxy with synthetic tools/call traffic (-rps, -duration)
+                and report throughput, latency percentiles and memory
+  supervise     Run the proxy and restart it with backoff when it crashes
+                (mcp-proxy supervise -- -mode stdio ...; crash dumps in ~/.armour/crashes)
+  config show   Show every option's value and where it came from
   backup        Backup MCP configurations
   recover       Restore MCP configurations from backup
+  service       Install the rules server + HTTP proxy as systemd/launchd user services
+  self-update   Install the latest release (verified against its checksums)
   version       Print version
   help          Print this help message
 
 FLAGS:
-  -mode STRING              Proxy mode: http or stdio (default: http)
-  -config STRING            Path to servers.json configuration file
-  -listen STRING            HTTP listen address (default: :8080)
-  -log-level STRING         Log level: debug, info, warn, error (default: info)
-  -db STRING                SQLite database path (default: in-memory)
-  -origins STRING           Comma-separated allowed CORS origins
-  -policy STRING            Default policy mode: strict, moderate, permissive
+  -mode STRING              Proxy mode: http or stdio (default: http) [$ARMOUR_MODE]
+  -config STRING            Path to servers.json configuration file [$ARMOUR_CONFIG]
+  -listen STRING            HTTP listen address (default: :8080) [$ARMOUR_LISTEN]
+  -log-level STRING         Log level: debug, info, warn, error (default: info) [$ARMOUR_LOG_LEVEL]
+  -db STRING                SQLite database path (default: in-memory) [$ARMOUR_DB]
+  -origins STRING           Comma-separated browser origins allowed besides localhost;
+                            * allows any [$ARMOUR_ORIGINS]
+  -allowed-hosts STRING     Extra Host headers accepted by servers bound to localhost
+                            (DNS-rebinding protection); * disables [$ARMOUR_ALLOWED_HOSTS]
+  -json                     Emit a JSON envelope from subcommands [$ARMOUR_JSON]
+  -no-update-check          Don't check for newer releases at startup [$ARMOUR_NO_UPDATE_CHECK]
+  -record FILE              Record the stdio session (client and backend JSON-RPC,
+                            secrets redacted) to a JSONL file [$ARMOUR_RECORD]
+  -max-message-size SIZE    Largest JSON-RPC message accepted from clients; larger
+                            ones get a "Request too large" error (default: 10MB)
+                            [$ARMOUR_MAX_MESSAGE_SIZE]
+  -workers N                Requests handled at once (default: 16) [$ARMOUR_WORKERS]
+  -session-concurrency N    Requests handled at once per session (default: 8)
+                            [$ARMOUR_SESSION_CONCURRENCY]
+  -queue-size N             Requests allowed to wait for a worker; more get a
+                            "Server busy" error (default: 256) [$ARMOUR_QUEUE_SIZE]
+  -ping-interval DURATION   How often to ping stdio-mode backends; after 3 missed pings
+                            a backend's requests fail fast until it answers again.
+                            0 disables (default: 30s) [$ARMOUR_PING_INTERVAL]
+  -tls-cert FILE            Serve HTTP mode over TLS with this certificate [$ARMOUR_TLS_CERT]
+  -tls-key FILE             Private key for -tls-cert [$ARMOUR_TLS_KEY]
+  -tls-client-ca FILE       Require client certs signed by this CA (mTLS) [$ARMOUR_TLS_CLIENT_CA]
+  -tls-client-fingerprints  Comma-separated SHA-256 fingerprints of allowed client
+                            certs, as printed by 'openssl x509 -noout -fingerprint
+                            -sha256' [$ARMOUR_TLS_CLIENT_FINGERPRINTS]
+  -no-compression           Don't compress HTTP-mode responses [$ARMOUR_NO_COMPRESSION]
+  -idle-timeout DURATION    How long idle keep-alive connections to the HTTP listener
+                            stay open (default: 2m) [$ARMOUR_IDLE_TIMEOUT]
+  -h2c                      Also serve HTTP/2 without TLS to clients that speak it
+                            directly; over TLS it is negotiated anyway [$ARMOUR_H2C]
+  -tenants FILE             Serve several teams from one HTTP proxy: a JSON file mapping
+                            tenants to their own servers.json and policy file. Requests
+                            pick a tenant with X-Armour-Tenant or /tenants/<name>/...
+                            [$ARMOUR_TENANTS]
+  -manage-listen ADDR       Serve the management API for a fleet controller (stdio
+                            mode); needs -manage-token, and TLS unless on localhost
+                            [$ARMOUR_MANAGE_LISTEN]
+  -manage-token TOKEN       Bearer token the controller sends [$ARMOUR_MANAGE_TOKEN]
+  -manage-tls-cert FILE     TLS certificate for the management API [$ARMOUR_MANAGE_TLS_CERT]
+  -manage-tls-key FILE      Private key for -manage-tls-cert [$ARMOUR_MANAGE_TLS_KEY]
+  -manage-tls-client-ca FILE
+                            Require controller certs signed by this CA
+                            [$ARMOUR_MANAGE_TLS_CLIENT_CA]
+  -manage-tls-client-fingerprints
+                            Comma-separated SHA-256 fingerprints of allowed controller
+                            certs [$ARMOUR_MANAGE_TLS_CLIENT_FINGERPRINTS]
+  -policy-plugins LIST      Comma-separated executables or .wasm modules asked to allow,
+                            block or ask about every tool call after the built-in policy
+                            [$ARMOUR_POLICY_PLUGINS]
+  -opa-url URL              Check every tool call against this Open Policy Agent decision,
+                            e.g. http://127.0.0.1:8181/v1/data/armour/decision
+                            [$ARMOUR_OPA_URL]
+  -audit-anchor FILE        Append the head of the audit log's hash chain to this file
+                            every -audit-anchor-interval and on exit, for
+                            'mcp-proxy audit verify -anchor' [$ARMOUR_AUDIT_ANCHOR]
+  -audit-anchor-interval DURATION
+                            How often to write the audit anchor (default: 1h)
+                            [$ARMOUR_AUDIT_ANCHOR_INTERVAL]
+  -retention DURATION      Purge audit entries and traces older than this, e.g. 30d;
+                            see 'mcp-proxy audit purge' (default: keep forever)
+                            [$ARMOUR_RETENTION]
+  -with-demo-server         Also serve a built-in server named demo with greet, echo,
+                            slow and fail tools, to try rules, stats and the dashboard
+                            without configuring a server [$ARMOUR_WITH_DEMO_SERVER]
+  -chaos                    Allow latency, errors and dropped notifications to be injected
+                            into backends through /api/v1/debug/chaos, for testing timeouts
+                            and health displays; never in production (stdio mode)
+                            [$ARMOUR_CHAOS]
+  -trace-sample STRING      Trace events to keep: always, errors, or 1/N for one in N;
+                            failures are kept by every setting (default: always)
+                            [$ARMOUR_TRACE_SAMPLE]
+  -trace-persist            Also write kept trace events to the database, for
+                            'mcp-proxy logs -since' after they leave the in-memory
+                            buffer [$ARMOUR_TRACE_PERSIST]
+  -trace-retention DURATION Purge persisted trace events older than this (default: 7d)
+                            [$ARMOUR_TRACE_RETENTION]
+
+  Global flags may precede any command. Run 'mcp-proxy COMMAND -help'
+  for command-specific flags.
+
+  Options not given as flags or ARMOUR_* variables are read from
+  ~/.armour/config.yaml, keyed by flag name; a command's options go under
+  its name (serve: {port: 9084}). 'mcp-proxy config show' lists the result.
+
+ENVIRONMENT:
+  ANTHROPIC_API_KEY              API key for semantic rule matching
+  ARMOUR_CONFIG_FILE             Config file (default: ~/.armour/config.yaml)
+  ARMOUR_RULES_URL               Rules server URL (default: http://127.0.0.1:8084 if running)
+  ARMOUR_BLOCKLIST_SOURCES       Comma-separated community rule files or URLs
+  ARMOUR_ORG_POLICY_URL          HTTPS URL of a signed organization policy bundle
+  ARMOUR_ORG_POLICY_KEY          Base64 ed25519 public key used to verify the bundle
+  ARMOUR_ORG_POLICY_INTERVAL     Org policy refresh interval (default: 15m)
+  ARMOUR_MAX_CONTENT_BYTES       Max decoded size of an image/audio/blob tool result item (default: 5MiB, 0 = no limit)
+  ARMOUR_VALIDATE_ARGS           Check tool arguments against inputSchema (default: true)
+  ARMOUR_SEMANTIC_HISTORY        Earlier calls per session shown to semantic rules (default: 5, 0 disables)
+  ARMOUR_SEMANTIC_HISTORY_ARGS   Include (truncated, redacted) arguments of earlier calls (default: true)
+  ARMOUR_PLUGIN_WATCH_INTERVAL   How often to rescan ~/.claude/plugins (default: 5s, 0 disables)
+  ARMOUR_UPDATE_URL              Release API URL used by self-update (default: GitHub latest release)
+  ARMOUR_UPDATE_KEY              Base64 ed25519 key self-update checks release checksums against
+                                 (default: the Armour release key)
+  ARMOUR_UPDATE_INSECURE_SKIP_SIGNATURE
+                                 Install releases without checking their signature (INSECURE)
 
 EXAMPLES:
-  # Run as HTTP proxy on port 8080
+  # Run as HTTP proxy on port 8080; each server is served on /mcp/<name>
+  # (or its "path" in servers.json) with the blocklist and trust policy applied
   mcp-proxy -mode http -config servers.json
 
+  # Only accept approved agents (mutual TLS with a fingerprint allowlist)
+  mcp-proxy -mode http -config servers.json -tls-cert server.pem -tls-key server.key \
+    -tls-client-fingerprints 3F:A2:...,9C:01:...
+
   # Run as stdio MCP server
   mcp-proxy -mode stdio -config servers.json
 
+  # Record a session, then check it against the current policy with mock backends
+  mcp-proxy -mode stdio -config servers.json -record session.jsonl
+  mcp-proxy replay -mock session.jsonl
+
+  # Tune rules against a past Claude Code session
+  mcp-proxy analyze ~/.claude/projects/-home-me-app/3f2a9c1e.jsonl
+
   # Detect existing MCP servers
   mcp-proxy detect
 
   # Auto-discover servers in current project
   mcp-proxy up
 
+  # Machine-readable output ({"schema_version":1,"command":...,"ok":...,"data":...})
+  mcp-proxy -json detect
+
+  # Block destructive git pushes from a script
+  mcp-proxy rules add -name no-force-push -tools Bash -pattern "push --force"
+
+  # Follow blocked tool calls for a single backend
+  mcp-proxy audit tail -blocked-only -backend github
+
 For more information, visit: https://github.com/yourusername/mcp-go-proxy
 `)
 }
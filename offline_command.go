@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+const offlineUsage = `usage: mcp-proxy offline [on|off|status] [flags]
+
+Offline mode blocks calls to http/sse servers and calls whose arguments
+contain URLs of other hosts, so local tools keep working without a network.
+Set ARMOUR_OFFLINE=1 to start the proxy in offline mode.
+
+FLAGS:
+  -dashboard URL  Dashboard URL of the running proxy (default: http://127.0.0.1:13337) [$ARMOUR_DASHBOARD_URL]
+  -token TOKEN    Dashboard token, if the dashboard requires one [$ARMOUR_DASHBOARD_TOKEN]
+  -reason TEXT    Why offline mode is turned on, shown on the dashboard
+`
+
+func handleOfflineCommand(args []string) {
+	sub := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+	switch sub {
+	case "on", "off", "status":
+	default:
+		fmt.Fprint(os.Stderr, offlineUsage)
+		os.Exit(2)
+	}
+
+	fs := cmd.NewFlagSet("offline "+sub, "[FLAGS]", "")
+	var dashboardURL, token, reason string
+	fs.StringVar(&dashboardURL, "dashboard", "ARMOUR_DASHBOARD_URL", "http://127.0.0.1:13337", "Dashboard URL of the running proxy")
+	fs.StringVar(&token, "token", "ARMOUR_DASHBOARD_TOKEN", "", "Dashboard token, if the dashboard requires one")
+	fs.StringVar(&reason, "reason", "", "turned on from the command line", "Why offline mode is turned on")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	endpoint := strings.TrimSuffix(dashboardURL, "/") + "/api/v1/offline"
+	var resp *http.Response
+	var err error
+	if sub == "status" {
+		resp, err = cmd.DashboardGet(endpoint, token)
+	} else {
+		resp, err = cmd.DashboardPost(endpoint, token, server.OfflineState{Enabled: sub == "on", Reason: reason})
+	}
+	if err != nil {
+		exitWithError("offline", fmt.Errorf("proxy not running: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		exitWithError("offline", fmt.Errorf("dashboard returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+
+	var state server.OfflineState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		exitWithError("offline", fmt.Errorf("invalid response: %w", err))
+	}
+	if jsonOutput {
+		emitJSON("offline", state)
+		return
+	}
+	if !state.Enabled {
+		fmt.Println("Offline mode is off.")
+		return
+	}
+	fmt.Printf("Offline mode is on since %s", state.Since.Local().Format(time.DateTime))
+	if state.Reason != "" {
+		fmt.Printf(" (%s)", state.Reason)
+	}
+	fmt.Println(": calls to http/sse servers and with URL arguments are blocked.")
+}
@@ -0,0 +1,216 @@
+// Package armour embeds the Armour MCP proxy in another Go program: the HTTP
+// proxy that aggregates a set of MCP servers behind one handler, and the
+// policy engine that decides whether each MCP request may go ahead. It is the
+// supported API for embedding; the server and proxy packages it wraps may
+// change between releases.
+//
+//	p, err := armour.New(armour.Config{
+//		Servers: []armour.ServerEntry{{Name: "github", Transport: "http", URL: "https://api.githubcopilot.com/mcp/"}},
+//		DBPath:  "armour.db",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//	mux.Handle("/mcp/", p.Handler()) // the github server is at /mcp/github
+//
+//	// Or check requests the embedding program handles itself
+//	block, err := p.Policy().Check("github", sessionID, message)
+package armour
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/user/mcp-go-proxy/proxy"
+	"github.com/user/mcp-go-proxy/server"
+	_ "modernc.org/sqlite" // the audit log and rules are kept in SQLite
+)
+
+// Types shared with the proxy, so embedders only import this package.
+type (
+	// ServerEntry is an MCP server the proxy routes to, as in servers.json.
+	ServerEntry = proxy.ServerEntry
+	// Rule is a blocklist rule; see Rule.Permissions for which operations it denies.
+	Rule = server.BlocklistRule
+	// Permissions says which operations a rule applies to.
+	Permissions = server.Permissions
+	// BlockError says why the policy denied a request.
+	BlockError = server.BlockError
+	// TrustPolicy decides tool calls by how far a server is trusted.
+	TrustPolicy = server.TrustPolicy
+	// EgressPolicy restricts the hosts URL arguments may point at.
+	EgressPolicy = server.EgressPolicy
+	// DLPPolicy says what to do with sensitive data in tool call arguments.
+	DLPPolicy = server.DLPPolicy
+	// CedarPolicy holds Cedar policies tool calls are authorized against.
+	CedarPolicy = server.CedarPolicy
+	// AuthorizerPolicy is the external service rules with action "delegate" defer to.
+	AuthorizerPolicy = server.AuthorizerPolicy
+	// AuditPolicy sets which tool calls keep their arguments in the audit log.
+	AuditPolicy = server.AuditPolicy
+	// PolicyChecker is a custom check tool calls must pass after the built-in policy.
+	PolicyChecker = server.PolicyChecker
+	// PolicyCall is the tool call a PolicyChecker decides on.
+	PolicyCall = server.PolicyCall
+	// PolicyVerdict is a PolicyChecker's decision: allow, block or ask.
+	PolicyVerdict = server.PolicyVerdict
+)
+
+// RegisterPolicyChecker adds a policy checker to every proxy created
+// afterwards, including in the mcp-proxy binary if it is built with a
+// package that registers one in its init function.
+func RegisterPolicyChecker(c PolicyChecker) {
+	server.RegisterPolicyChecker(c)
+}
+
+// DefaultPermissions is what a new rule applies to: a "block" rule denies
+// tool calls, resource reads and prompts but not listing them, an "allow"
+// rule allows everything.
+func DefaultPermissions(action string) Permissions {
+	return server.DefaultPermissions(action)
+}
+
+// DefaultListenAddr is where ListenAndServe listens unless Config says otherwise.
+const DefaultListenAddr = "127.0.0.1:8080"
+
+// Config configures an embedded proxy.
+type Config struct {
+	Servers    []ServerEntry // served at their route path, /mcp/<name> by default
+	ListenAddr string        // for ListenAndServe (default DefaultListenAddr)
+	DBPath     string        // SQLite file for the audit log and rules; empty keeps them in memory
+	LogLevel   string        // debug, info, warn or error (default info)
+
+	// Browser origins and Host headers accepted besides localhost's
+	AllowedOrigins []string
+	AllowedHosts   []string
+
+	// Executables run as out-of-process policy checkers, as with -policy-plugins
+	PolicyPlugins []string
+	// Data API URL of an Open Policy Agent decision, as with -opa-url
+	OPAURL string
+}
+
+// Proxy is an embedded Armour proxy.
+type Proxy struct {
+	srv *server.Server
+}
+
+// New creates a proxy for the configured servers. Like the mcp-proxy
+// binary, it uses a rules server if ARMOUR_RULES_URL names one or one is
+// running on localhost:8084.
+func New(config Config) (*Proxy, error) {
+	if config.ListenAddr == "" {
+		config.ListenAddr = DefaultListenAddr
+	}
+	if config.LogLevel == "" {
+		config.LogLevel = "info"
+	}
+	srv, err := server.NewServer(server.Config{
+		Mode:           "http",
+		ListenAddr:     config.ListenAddr,
+		LogLevel:       config.LogLevel,
+		DBPath:         config.DBPath,
+		Registry:       &proxy.ServerRegistry{Servers: config.Servers},
+		AllowedOrigins: config.AllowedOrigins,
+		AllowedHosts:   config.AllowedHosts,
+		PolicyPlugins:  config.PolicyPlugins,
+		OPAURL:         config.OPAURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Proxy{srv: srv}, nil
+}
+
+// Handler returns the proxy's HTTP handler. Mount it at the root of the
+// request path, or strip any prefix first with http.StripPrefix.
+func (p *Proxy) Handler() http.Handler {
+	return p.srv.Handler()
+}
+
+// ListenAndServe serves the proxy on Config.ListenAddr until ctx ends.
+func (p *Proxy) ListenAndServe(ctx context.Context) error {
+	return p.srv.ListenAndServe(ctx)
+}
+
+// Policy returns the policy engine the proxy applies to every request.
+func (p *Proxy) Policy() *PolicyEngine {
+	return &PolicyEngine{srv: p.srv}
+}
+
+// Close releases the proxy's database.
+func (p *Proxy) Close() error {
+	return p.srv.Close()
+}
+
+// PolicyEngine decides whether MCP requests may go ahead: the kill switch,
+// blocklist rules, path sandboxes, egress, DLP, Cedar policies, policy
+// checkers and trust, in that order.
+type PolicyEngine struct {
+	srv *server.Server
+}
+
+// Check applies the policy to a JSON-RPC request (a single message, not a
+// batch) bound for the named server, and records it in the audit log. It
+// returns nil if the request may go ahead, otherwise why it was denied.
+// Tool names are namespaced as in the proxy, so rules for github:create_issue
+// apply to a tools/call of create_issue on the github server.
+func (e *PolicyEngine) Check(serverName, sessionID string, message []byte) (*BlockError, error) {
+	var req server.JSONRPCRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		return nil, fmt.Errorf("invalid JSON-RPC request: %w", err)
+	}
+	return e.srv.CheckRequest(serverName, sessionID, req)
+}
+
+// AddRule adds a blocklist rule, applied from the next request on.
+func (e *PolicyEngine) AddRule(rule Rule) error {
+	return e.srv.AddRule(&rule)
+}
+
+// SetTrustPolicy replaces the trust policy (default server.DefaultTrustPolicy).
+func (e *PolicyEngine) SetTrustPolicy(tp TrustPolicy) {
+	e.srv.SetTrustPolicy(tp)
+}
+
+// SetEgressPolicy sets the hosts URL arguments of tool calls may point at.
+func (e *PolicyEngine) SetEgressPolicy(ep EgressPolicy) {
+	e.srv.SetEgressPolicy(ep)
+}
+
+// SetDLPPolicy sets what happens to sensitive data in tool call arguments.
+func (e *PolicyEngine) SetDLPPolicy(dp DLPPolicy) {
+	e.srv.SetDLPPolicy(dp)
+}
+
+// SetCedarPolicy sets the Cedar policies tool calls are authorized against;
+// an empty policy turns the check off.
+func (e *PolicyEngine) SetCedarPolicy(cp CedarPolicy) error {
+	if err := cp.Validate(); err != nil {
+		return err
+	}
+	e.srv.SetCedarPolicy(cp)
+	return nil
+}
+
+// SetAuthorizerPolicy sets the external service rules with action "delegate"
+// hand their decisions to.
+func (e *PolicyEngine) SetAuthorizerPolicy(ap AuthorizerPolicy) error {
+	if err := ap.Validate(); err != nil {
+		return err
+	}
+	e.srv.SetAuthorizerPolicy(ap)
+	return nil
+}
+
+// SetAuditPolicy sets which tool calls keep their arguments in the audit log.
+func (e *PolicyEngine) SetAuditPolicy(ap AuditPolicy) error {
+	if err := ap.Validate(); err != nil {
+		return err
+	}
+	e.srv.SetAuditPolicy(ap)
+	return nil
+}
@@ -0,0 +1,72 @@
+package armour
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestEmbeddedProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"ok"}]}}`)
+	}))
+	defer backend.Close()
+
+	p, err := New(Config{
+		Servers:  []ServerEntry{{Name: "files", Transport: "http", URL: backend.URL}},
+		DBPath:   filepath.Join(t.TempDir(), "armour.db"),
+		LogLevel: "error",
+	})
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	defer p.Close()
+
+	rule := Rule{Pattern: "rm -rf", Action: "block", IsRegex: true, Enabled: true, Permissions: DefaultPermissions("block")}
+	if err := p.Policy().AddRule(rule); err != nil {
+		t.Fatalf("failed to add rule: %v", err)
+	}
+
+	blocked := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run","arguments":{"cmd":"rm -rf /"}}}`
+	allowed := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"run","arguments":{"cmd":"ls"}}}`
+
+	block, err := p.Policy().Check("files", "s1", []byte(blocked))
+	if err != nil || block == nil || block.Tool != "files:run" {
+		t.Errorf("expected files:run to be blocked, got %+v, %v", block, err)
+	}
+	if block, err := p.Policy().Check("files", "s1", []byte(allowed)); err != nil || block != nil {
+		t.Errorf("expected allowed call, got %+v, %v", block, err)
+	}
+	if _, err := p.Policy().Check("nope", "s1", []byte(allowed)); err == nil {
+		t.Error("expected error for an unknown server")
+	}
+
+	// The handler enforces the same policy in front of the backend
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+	post := func(body string) string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/mcp/files", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(proxy.HeaderSessionID, "s1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+	if got := post(blocked); !strings.Contains(got, `"error"`) {
+		t.Errorf("expected a JSON-RPC error, got %s", got)
+	}
+	if got := post(allowed); !strings.Contains(got, `"text":"ok"`) {
+		t.Errorf("expected the backend's response, got %s", got)
+	}
+}
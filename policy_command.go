@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/proxy"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+const policyUsage = `usage: mcp-proxy policy <command> [flags]
+
+COMMANDS:
+  apply     Reconcile the rules store to the policy file
+  diff      Show drift between the policy file and the rules store (exit 2 on drift)
+  export    Print the rules store as a policy file
+  simulate  Replay audited tool calls against the policy file and report the
+            calls it decides differently from the rules store (exit 2 on changes)
+
+FLAGS:
+  -f PATH   Policy file (default: armour.policy.yaml) [$ARMOUR_POLICY_FILE]
+  -db PATH  Rules database (default: ~/.armour/rules.db) [$ARMOUR_RULES_DB]
+
+Run 'mcp-proxy policy simulate -help' for its other flags.
+`
+
+func handlePolicyCommand(args []string) {
+	if len(args) < 1 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, policyUsage)
+		os.Exit(2)
+	}
+
+	sub := args[0]
+	switch sub {
+	case "apply", "diff", "export":
+	case "simulate":
+		handlePolicySimulate(args[1:])
+		return
+	default:
+		fmt.Fprint(os.Stderr, policyUsage)
+		os.Exit(2)
+	}
+
+	fs := cmd.NewFlagSet("policy "+sub, "[FLAGS]", "")
+	var file, dbPath string
+	fs.StringVar(&file, "f", "ARMOUR_POLICY_FILE", server.DefaultPolicyFile, "Policy file path")
+	fs.StringVar(&dbPath, "db", "ARMOUR_RULES_DB", server.DefaultRulesDBPath(), "Rules database path")
+	fs.MustParse(args[1:])
+
+	store, err := server.OpenRulesStore(dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "policy %s failed: %v\n", sub, err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	switch sub {
+	case "apply", "diff":
+		pf, err := server.LoadPolicyFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "policy %s failed: %v\n", sub, err)
+			os.Exit(1)
+		}
+
+		var diff *server.PolicyDiff
+		if sub == "apply" {
+			diff, err = server.ApplyPolicy(store, pf)
+		} else {
+			diff, err = server.DiffPolicy(store, pf)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "policy %s failed: %v\n", sub, err)
+			os.Exit(1)
+		}
+
+		fmt.Println(server.FormatPolicyDiff(diff))
+		if sub == "apply" && !diff.Empty() {
+			fmt.Printf("✓ Applied %s\n", file)
+		}
+		if sub == "diff" && !diff.Empty() {
+			store.Close()
+			os.Exit(2)
+		}
+	case "export":
+		pf, err := server.ExportPolicy(store)
+		if err == nil {
+			var data []byte
+			if data, err = pf.Marshal(); err == nil {
+				fmt.Print(string(data))
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "policy export failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// handlePolicySimulate replays the tool calls of an audit window against a
+// proposed policy file and the current rules (or -base), without running
+// them, and prints the calls whose decision changes.
+func handlePolicySimulate(args []string) {
+	fs := cmd.NewFlagSet("policy simulate", "[FLAGS]", "Report the audited calls a proposed policy file decides differently")
+	var file, base, dbPath, auditDB, dashboardURL, token, since string
+	var limit int
+	fs.StringVar(&file, "f", "ARMOUR_POLICY_FILE", server.DefaultPolicyFile, "Proposed policy file")
+	fs.StringVar(&base, "base", "", "", "Policy file to compare against instead of the rules store")
+	fs.StringVar(&dbPath, "db", "ARMOUR_RULES_DB", server.DefaultRulesDBPath(), "Rules database path")
+	fs.StringVar(&auditDB, "audit-db", "ARMOUR_DB", "", "Read the audit log from this SQLite database instead of the running proxy")
+	fs.StringVar(&dashboardURL, "dashboard", "ARMOUR_DASHBOARD_URL", "http://127.0.0.1:13337", "Dashboard URL of the running proxy")
+	fs.StringVar(&token, "token", "ARMOUR_DASHBOARD_TOKEN", "", "Dashboard token, if the dashboard requires one")
+	fs.StringVar(&since, "since", "", "7d", "Replay calls made after this date or within this age (e.g. 24h, 30d)")
+	fs.IntVar(&limit, "n", "", 1000, "Replay at most this many of the latest audit entries")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	after, err := server.ParsePurgeBefore(since, time.Now())
+	if err != nil {
+		exitWithError("policy simulate", err)
+	}
+	req := server.SimulateRequest{}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		exitWithError("policy simulate", fmt.Errorf("failed to read policy file: %w", err))
+	}
+	req.Policy = string(data)
+	if base != "" {
+		if data, err = os.ReadFile(base); err != nil {
+			exitWithError("policy simulate", fmt.Errorf("failed to read base policy file: %w", err))
+		}
+		req.Base = string(data)
+	}
+
+	var entries []server.AuditEntry
+	if auditDB != "" {
+		db, err := sql.Open("sqlite", "file:"+auditDB)
+		if err != nil {
+			exitWithError("policy simulate", err)
+		}
+		entries, err = server.QueryAuditLog(db, server.AuditFilter{Limit: limit})
+		db.Close()
+		if err != nil {
+			exitWithError("policy simulate", err)
+		}
+	} else if entries, err = server.FetchAuditLog(dashboardURL, token, server.AuditFilter{Limit: limit}); err != nil {
+		exitWithError("policy simulate", fmt.Errorf("%w (pass -audit-db to read a database)", err))
+	}
+	req.Entries = server.AuditSince(entries, after)
+
+	rs, err := server.NewRulesServer(server.RulesServerConfig{
+		DBPath: dbPath,
+		APIKey: os.Getenv("ANTHROPIC_API_KEY"),
+	})
+	if err != nil {
+		exitWithError("policy simulate", err)
+	}
+	sim, err := rs.Simulate(context.Background(), req)
+	if err != nil {
+		exitWithError("policy simulate", err)
+	}
+
+	if jsonOutput {
+		emitJSON("policy simulate", sim)
+	} else {
+		for _, c := range sim.Changes {
+			sign, reason := "~", c.To.Reason
+			switch c.Change {
+			case "newly_blocked":
+				sign = "+"
+			case "newly_allowed":
+				sign, reason = "-", "was: "+c.From.Reason
+			}
+			fmt.Printf("%s #%d %s %s: %s -> %s", sign, c.AuditID, c.Timestamp.Local().Format(time.DateTime), c.Tool, c.From.Decision, c.To.Decision)
+			if reason != "" && reason != "was: " {
+				fmt.Printf(" (%s)", reason)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("%d calls replayed: %d newly blocked, %d newly allowed, %d other changes\n",
+			sim.Calls, sim.NewlyBlocked, sim.NewlyAllowed, len(sim.Changes)-sim.NewlyBlocked-sim.NewlyAllowed)
+		if sim.WithoutArguments > 0 {
+			fmt.Printf("%d calls have no arguments in the audit log, so pattern rules can't match them (see the audit section's detail)\n", sim.WithoutArguments)
+		}
+	}
+	if len(sim.Changes) > 0 {
+		os.Exit(2)
+	}
+}
+
+// applyStoredPolicy honours the mode and server allowlist from the last
+// `policy apply`, if a rules store exists, and returns the sections the
+// servers enforce themselves. Trust falls back to the defaults if none was
+// applied.
+func applyStoredPolicy(registry *proxy.ServerRegistry, policyManager *server.PolicyManager) server.StoredPolicy {
+	defaults := server.StoredPolicy{Trust: server.DefaultTrustPolicy()}
+	dbPath := server.DefaultRulesDBPath()
+	if _, err := os.Stat(dbPath); err != nil {
+		return defaults
+	}
+
+	store, err := server.OpenRulesStore(dbPath)
+	if err != nil {
+		return defaults
+	}
+	defer store.Close()
+
+	sp, err := server.LoadStoredPolicy(store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring stored policy: %v\n", err)
+		return defaults
+	}
+	if sp.Mode != "" {
+		if err := policyManager.SetMode(sp.Mode); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring stored policy mode: %v\n", err)
+		}
+	}
+	policyManager.SetModeSchedule(sp.ModeSchedule)
+	if dropped := sp.FilterRegistry(registry); len(dropped) > 0 {
+		fmt.Fprintf(os.Stderr, "Policy allowlist skipped servers: %v\n", dropped)
+	}
+	if sp.Trust.IsZero() {
+		sp.Trust = defaults.Trust
+	}
+	return *sp
+}
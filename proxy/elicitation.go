@@ -148,15 +148,19 @@ func (al *AuditLog) GetLogsByServerID(serverID string) []AuditEntry {
 	return result
 }
 
+// SecurityManager holds the Origin and Host policy of an HTTP listener. See
+// origin.go for the checks and the CORS middleware.
 type SecurityManager struct {
 	mu             sync.RWMutex
 	allowedOrigins map[string]bool
+	allowedHosts   map[string]bool
 	localHostOnly  bool
 }
 
 func NewSecurityManager() *SecurityManager {
 	return &SecurityManager{
 		allowedOrigins: make(map[string]bool),
+		allowedHosts:   make(map[string]bool),
 		localHostOnly:  false,
 	}
 }
@@ -191,9 +195,14 @@ func (sm *SecurityManager) IsLocalHostOnly() bool {
 	return sm.localHostOnly
 }
 
+// ValidateOrigin accepts allowlisted origins, any origin if "*" is
+// allowlisted, and loopback origins when the manager is localhost-only.
 func (sm *SecurityManager) ValidateOrigin(origin string) error {
-	if !sm.IsOriginAllowed(origin) {
-		return fmt.Errorf("origin %s is not allowed", origin)
+	if sm.IsOriginAllowed(origin) || sm.IsOriginAllowed("*") {
+		return nil
 	}
-	return nil
+	if sm.IsLocalHostOnly() && isLoopbackOrigin(origin) {
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
 }
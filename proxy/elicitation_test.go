@@ -13,16 +13,14 @@ func TestElicitationBasic(t *testing.T) {
 	em := NewElicitationManager()
 
 	clientReq := NewInitRequest("TestClient", "1.0.16")
-	clientReq.Params.Capabilities.Elicitation = &ElicitationCapability{Enabled: true}
+	clientReq.Params.Capabilities.Elicitation = &ElicitationCapability{}
 
 	serverResp := InitResponseResult{
 		ServerInfo: ServerInfo{
 			Name:    "TestServer",
 			Version: "1.0.16",
 		},
-		Capabilities: Capabilities{
-			Elicitation: &ElicitationCapability{Enabled: true},
-		},
+		Capabilities:    Capabilities{},
 		ProtocolVersion: MCPProtocolVersion,
 	}
 
@@ -43,16 +41,14 @@ func TestElicitationBlocked(t *testing.T) {
 	em := NewElicitationManager()
 
 	clientReq := NewInitRequest("TestClient", "1.0.16")
-	clientReq.Params.Capabilities.Elicitation = &ElicitationCapability{Enabled: true}
+	clientReq.Params.Capabilities.Elicitation = &ElicitationCapability{}
 
 	serverResp := InitResponseResult{
 		ServerInfo: ServerInfo{
 			Name:    "TestServer",
 			Version: "1.0.16",
 		},
-		Capabilities: Capabilities{
-			Elicitation: &ElicitationCapability{Enabled: true},
-		},
+		Capabilities:    Capabilities{},
 		ProtocolVersion: MCPProtocolVersion,
 	}
 
@@ -73,16 +69,14 @@ func TestElicitationNotSupportedOnStdio(t *testing.T) {
 	em := NewElicitationManager()
 
 	clientReq := NewInitRequest("TestClient", "1.0.16")
-	clientReq.Params.Capabilities.Elicitation = &ElicitationCapability{Enabled: true}
+	clientReq.Params.Capabilities.Elicitation = &ElicitationCapability{}
 
 	serverResp := InitResponseResult{
 		ServerInfo: ServerInfo{
 			Name:    "TestServer",
 			Version: "1.0.16",
 		},
-		Capabilities: Capabilities{
-			Elicitation: &ElicitationCapability{Enabled: true},
-		},
+		Capabilities:    Capabilities{},
 		ProtocolVersion: MCPProtocolVersion,
 	}
 
@@ -214,16 +208,14 @@ func TestSpecCompliance(t *testing.T) {
 	proxy := NewProxy(db)
 
 	clientReq := NewInitRequest("TestClient", "1.0.16")
-	clientReq.Params.Capabilities.Sampling = &SamplingCapability{Tools: true}
+	clientReq.Params.Capabilities.Sampling = &SamplingCapability{}
 
 	serverResp := InitResponseResult{
 		ServerInfo: ServerInfo{
 			Name:    "TestServer",
 			Version: "1.0.16",
 		},
-		Capabilities: Capabilities{
-			Sampling: &SamplingCapability{Tools: true},
-		},
+		Capabilities:    Capabilities{},
 		ProtocolVersion: MCPProtocolVersion,
 	}
 
@@ -240,15 +232,15 @@ func TestSpecCompliance(t *testing.T) {
 	}
 }
 
-func TestCapabilityIntersectionRegressions(t *testing.T) {
+func TestCapabilityNegotiationRegressions(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
 	proxy := NewProxy(db)
 
+	// Each half comes from its owner: sampling from the client, tools from the server
 	clientReq := NewInitRequest("TestClient", "1.0.16")
-	clientReq.Params.Capabilities.Sampling = &SamplingCapability{Tools: true}
-	clientReq.Params.Capabilities.ListChanged = true
+	clientReq.Params.Capabilities.Tools = &ToolsCapability{ListChanged: true}
 
 	serverResp := InitResponseResult{
 		ServerInfo: ServerInfo{
@@ -256,8 +248,8 @@ func TestCapabilityIntersectionRegressions(t *testing.T) {
 			Version: "1.0.16",
 		},
 		Capabilities: Capabilities{
-			Sampling:    &SamplingCapability{Tools: false},
-			ListChanged: false,
+			Sampling: &SamplingCapability{},
+			Tools:    &ToolsCapability{},
 		},
 		ProtocolVersion: MCPProtocolVersion,
 	}
@@ -265,10 +257,10 @@ func TestCapabilityIntersectionRegressions(t *testing.T) {
 	proxy.Initialize(clientReq, serverResp)
 
 	if proxy.CanSample() {
-		t.Errorf("expected sampling to be disabled due to server capability")
+		t.Errorf("expected sampling to be disabled without client capability")
 	}
 
-	if proxy.HasListChanged() {
-		t.Errorf("expected listChanged to be disabled due to server capability")
+	if err := proxy.ValidateCapability("tools.listChanged"); err == nil {
+		t.Errorf("expected tools.listChanged to be disabled due to server capability")
 	}
 }
@@ -26,7 +26,8 @@ func WithForwarderTracer(tracer *TraceRecorder) ForwarderOption {
 func NewForwarder(opts ...ForwarderOption) *Forwarder {
 	f := &Forwarder{
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Transport: BackendHTTPTransport,
+			Timeout:   30 * time.Second,
 		},
 	}
 	for _, opt := range opts {
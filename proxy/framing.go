@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Framing is how JSON-RPC messages are delimited on a byte stream.
+type Framing int
+
+const (
+	// FramingNewline is newline-delimited JSON, one message per line (MCP stdio).
+	FramingNewline Framing = iota
+	// FramingContentLength is LSP-style "Content-Length: N" headers, a blank
+	// line, then exactly N bytes of JSON.
+	FramingContentLength
+)
+
+// DefaultMaxMessageSize is the largest message accepted by default, from
+// stdio clients, HTTP clients and stdio backends alike.
+const DefaultMaxMessageSize = 10 << 20
+
+// ErrMessageTooLarge is returned for a message over the reader's size limit.
+// The message is skipped, so the next ReadMessage continues with the one after it.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// MessageReader reads JSON-RPC messages framed either by newlines or by
+// Content-Length headers. The framing is detected per message, so a client
+// may use either.
+type MessageReader struct {
+	r       *bufio.Reader
+	maxSize int
+	framing Framing
+}
+
+// NewMessageReader reads messages of at most maxSize bytes from r
+// (DefaultMaxMessageSize if maxSize <= 0).
+func NewMessageReader(r io.Reader, maxSize int) *MessageReader {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	return &MessageReader{r: bufio.NewReaderSize(r, 64*1024), maxSize: maxSize}
+}
+
+// Framing returns the framing of the last message read, so replies can use the same.
+func (m *MessageReader) Framing() Framing {
+	return m.framing
+}
+
+// ReadMessage returns the next message, skipping blank lines between
+// messages. It returns io.EOF when the stream ends.
+func (m *MessageReader) ReadMessage() ([]byte, error) {
+	for {
+		line, err := m.readLine()
+		if err != nil && !(err == io.EOF && len(line) > 0) {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !isContentLengthHeader(line) {
+			m.framing = FramingNewline
+			return line, nil
+		}
+		m.framing = FramingContentLength
+		return m.readFramedBody(line)
+	}
+}
+
+// readFramedBody reads the remaining headers after the Content-Length line and then the body.
+func (m *MessageReader) readFramedBody(header []byte) ([]byte, error) {
+	_, value, _ := strings.Cut(string(header), ":")
+	length, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header: %q", header)
+	}
+
+	// Other headers (e.g. Content-Type) are ignored up to the blank line.
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			return nil, fmt.Errorf("unexpected end of headers: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			break
+		}
+	}
+
+	if length > m.maxSize {
+		if _, err := io.CopyN(io.Discard, m.r, int64(length)); err != nil {
+			return nil, err
+		}
+		return nil, ErrMessageTooLarge
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(m.r, body); err != nil {
+		return nil, fmt.Errorf("truncated message body: %w", err)
+	}
+	return body, nil
+}
+
+// readLine reads up to and including the next newline. A line longer than
+// the size limit is read to its end and discarded.
+func (m *MessageReader) readLine() ([]byte, error) {
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := m.r.ReadSlice('\n')
+		if !tooLarge {
+			if len(line)+len(bytes.TrimRight(chunk, "\r\n")) > m.maxSize {
+				tooLarge, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLarge {
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			return nil, ErrMessageTooLarge
+		}
+		return line, err
+	}
+}
+
+func isContentLengthHeader(line []byte) bool {
+	const prefix = "content-length:"
+	return len(line) > len(prefix) && strings.EqualFold(string(line[:len(prefix)]), prefix)
+}
+
+// WriteMessage writes msg to w with the given framing.
+func WriteMessage(w io.Writer, framing Framing, msg []byte) error {
+	if framing == FramingContentLength {
+		if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(msg)); err != nil {
+			return err
+		}
+		_, err := w.Write(msg)
+		return err
+	}
+	buf := make([]byte, 0, len(msg)+1)
+	buf = append(append(buf, msg...), '\n')
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMessageReaderFramings(t *testing.T) {
+	input := "{\"id\":1}\n" +
+		"\r\n" +
+		"Content-Length: 8\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n{\"id\":2}" +
+		"{\"id\":3}\r\n" +
+		"{\"id\":4}"
+	reader := NewMessageReader(strings.NewReader(input), 0)
+
+	want := []struct {
+		msg     string
+		framing Framing
+	}{
+		{`{"id":1}`, FramingNewline},
+		{`{"id":2}`, FramingContentLength},
+		{`{"id":3}`, FramingNewline},
+		{`{"id":4}`, FramingNewline},
+	}
+	for _, w := range want {
+		msg, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatalf("unexpected error reading %s: %v", w.msg, err)
+		}
+		if string(msg) != w.msg || reader.Framing() != w.framing {
+			t.Errorf("expected %s (framing %d), got %s (framing %d)", w.msg, w.framing, msg, reader.Framing())
+		}
+	}
+	if _, err := reader.ReadMessage(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestMessageReaderSkipsOversizedMessages(t *testing.T) {
+	big := strings.Repeat("x", 300)
+	input := big + "\n{\"id\":1}\nContent-Length: 300\r\n\r\n" + big + "{\"id\":2}\n"
+	reader := NewMessageReader(strings.NewReader(input), 100)
+
+	for _, want := range []string{"", `{"id":1}`, "", `{"id":2}`} {
+		msg, err := reader.ReadMessage()
+		if want == "" {
+			if err != ErrMessageTooLarge {
+				t.Fatalf("expected ErrMessageTooLarge, got %v (%s)", err, msg)
+			}
+			continue
+		}
+		if err != nil || string(msg) != want {
+			t.Fatalf("expected %s after skipping, got %s (%v)", want, msg, err)
+		}
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	var buf bytes.Buffer
+	WriteMessage(&buf, FramingNewline, []byte(`{"id":1}`))
+	WriteMessage(&buf, FramingContentLength, []byte(`{"id":2}`))
+	if got, want := buf.String(), "{\"id\":1}\nContent-Length: 8\r\n\r\n{\"id\":2}"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewSecurityManagerForListener returns a SecurityManager for a server
+// listening on listenAddr. Servers bound to a loopback address are
+// localhost-only: they accept loopback origins and reject requests whose Host
+// header isn't a loopback name, which defeats DNS rebinding.
+func NewSecurityManagerForListener(listenAddr string, origins, hosts []string) *SecurityManager {
+	sm := NewSecurityManager()
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		host = listenAddr
+	}
+	sm.SetLocalHostOnly(isLoopbackHost(host))
+	for _, origin := range origins {
+		sm.AddAllowedOrigin(strings.TrimSuffix(origin, "/"))
+	}
+	for _, h := range hosts {
+		sm.AddAllowedHost(h)
+	}
+	return sm
+}
+
+// AddAllowedHost accepts an additional Host header (e.g. a reverse-proxy
+// hostname) on a localhost-only server. "*" disables the Host check.
+func (sm *SecurityManager) AddAllowedHost(host string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.allowedHosts[strings.ToLower(host)] = true
+}
+
+// ValidateHost checks a request's Host header. Only localhost-only managers
+// restrict it.
+func (sm *SecurityManager) ValidateHost(hostport string) error {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if !sm.localHostOnly || sm.allowedHosts["*"] {
+		return nil
+	}
+	host := strings.ToLower(hostport)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if isLoopbackHost(host) || sm.allowedHosts[host] || sm.allowedHosts[strings.ToLower(hostport)] {
+		return nil
+	}
+	return fmt.Errorf("host %s is not allowed", hostport)
+}
+
+// Middleware enforces the Host and Origin policy and answers CORS preflights.
+// Requests without an Origin header (MCP clients, hooks, curl) are not
+// browser cross-origin requests and only get the Host check.
+func (sm *SecurityManager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := sm.ValidateHost(r.Host); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := sm.ValidateOrigin(origin); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{"Content-Type", "Last-Event-ID", HeaderSessionID, HeaderServerID, HeaderProtocolVersion}, ", "))
+		w.Header().Set("Access-Control-Expose-Headers", HeaderSessionID+", "+HeaderProtocolVersion)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopbackOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return isLoopbackHost(u.Hostname())
+}
+
+func isLoopbackHost(host string) bool {
+	host = strings.Trim(strings.ToLower(host), "[]")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
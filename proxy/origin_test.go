@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalhostOnlyOrigins(t *testing.T) {
+	sm := NewSecurityManagerForListener("127.0.0.1:13337", []string{"https://app.example.com/"}, nil)
+
+	for _, origin := range []string{"http://localhost:13337", "http://127.0.0.1:3000", "http://[::1]", "https://app.example.com"} {
+		if err := sm.ValidateOrigin(origin); err != nil {
+			t.Errorf("expected %s to be allowed: %v", origin, err)
+		}
+	}
+	for _, origin := range []string{"https://evil.com", "http://localhost.evil.com", "null", "file://"} {
+		if err := sm.ValidateOrigin(origin); err == nil {
+			t.Errorf("expected %s to be rejected", origin)
+		}
+	}
+}
+
+func TestValidateHost(t *testing.T) {
+	sm := NewSecurityManagerForListener("127.0.0.1:8084", nil, []string{"armour.internal"})
+
+	for _, host := range []string{"127.0.0.1:8084", "localhost:8084", "[::1]:8084", "localhost", "armour.internal:8084"} {
+		if err := sm.ValidateHost(host); err != nil {
+			t.Errorf("expected host %s to be allowed: %v", host, err)
+		}
+	}
+	// A rebinding attack carries the attacker's hostname in Host
+	if err := sm.ValidateHost("evil.com:8084"); err == nil {
+		t.Error("expected evil.com to be rejected")
+	}
+
+	public := NewSecurityManagerForListener(":8080", nil, nil)
+	if public.IsLocalHostOnly() {
+		t.Error("expected a server listening on all interfaces not to be localhost-only")
+	}
+	if err := public.ValidateHost("proxy.example.com"); err != nil {
+		t.Errorf("expected any host on a public listener: %v", err)
+	}
+	if err := public.ValidateOrigin("http://localhost:3000"); err == nil {
+		t.Error("expected localhost origins to need allowlisting on a public listener")
+	}
+
+	sm.AddAllowedHost("*")
+	if err := sm.ValidateHost("anything.example.com"); err != nil {
+		t.Errorf("expected * to disable the host check: %v", err)
+	}
+}
+
+func TestSecurityMiddleware(t *testing.T) {
+	sm := NewSecurityManagerForListener("127.0.0.1:8084", []string{"https://app.example.com"}, nil)
+	handler := sm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		host   string
+		origin string
+		want   int
+		cors   string
+	}{
+		{"no origin", http.MethodGet, "127.0.0.1:8084", "", http.StatusOK, ""},
+		{"allowed origin", http.MethodPost, "127.0.0.1:8084", "https://app.example.com", http.StatusOK, "https://app.example.com"},
+		{"preflight", http.MethodOptions, "localhost:8084", "http://localhost:3000", http.StatusNoContent, "http://localhost:3000"},
+		{"foreign origin", http.MethodPost, "127.0.0.1:8084", "https://evil.com", http.StatusForbidden, ""},
+		{"rebound host", http.MethodGet, "evil.com:8084", "http://evil.com:8084", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/rules", nil)
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.cors {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.cors, got)
+			}
+		})
+	}
+}
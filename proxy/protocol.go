@@ -19,12 +19,36 @@ func ToBoolean(v interface{}) bool {
 }
 
 const (
-	MCPProtocolVersion    = "2024-11-05"
+	// MCPProtocolVersion is the newest protocol version the proxy speaks.
+	MCPProtocolVersion    = "2025-06-18"
 	HeaderProtocolVersion = "MCP-Protocol-Version"
 	HeaderSessionID       = "MCP-Session-Id"
 	HeaderServerID        = "MCP-Server-Id"
 )
 
+// SupportedProtocolVersions lists the protocol versions the proxy accepts, newest first.
+var SupportedProtocolVersions = []string{MCPProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// IsSupportedProtocolVersion reports whether version is in SupportedProtocolVersions.
+func IsSupportedProtocolVersion(version string) bool {
+	for _, v := range SupportedProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// NegotiateProtocolVersion returns the version to answer an initialize
+// request with: the requested version if supported, otherwise the newest
+// one. It is then up to the client whether it can speak that version.
+func NegotiateProtocolVersion(requested string) string {
+	if IsSupportedProtocolVersion(requested) {
+		return requested
+	}
+	return MCPProtocolVersion
+}
+
 type InitRequest struct {
 	JSONRPC string            `json:"jsonrpc"`
 	ID      interface{}       `json:"id,omitempty"`
@@ -60,24 +84,36 @@ type ServerInfo struct {
 	Version string `json:"version"`
 }
 
+// Capabilities are the capabilities exchanged in initialize, as defined by
+// MCP 2025-06-18. Clients offer roots, sampling and elicitation; servers
+// offer tools, resources, prompts, logging and completions. A nil field
+// means the capability is not supported.
 type Capabilities struct {
+	Experimental map[string]interface{} `json:"experimental,omitempty"`
+
+	// Client capabilities
+	Roots       *RootsCapability       `json:"roots,omitempty"`
 	Sampling    *SamplingCapability    `json:"sampling,omitempty"`
 	Elicitation *ElicitationCapability `json:"elicitation,omitempty"`
+
+	// Server capabilities
 	Tools       *ToolsCapability       `json:"tools,omitempty"`
 	Resources   *ResourcesCapability   `json:"resources,omitempty"`
 	Prompts     *PromptsCapability     `json:"prompts,omitempty"`
-	ListChanged bool                   `json:"listChanged,omitempty"`
-	Subscribe   bool                   `json:"subscribe,omitempty"`
-	Logging     interface{}            `json:"logging,omitempty"` // Can be bool or object
+	Logging     *LoggingCapability     `json:"logging,omitempty"`
+	Completions *CompletionsCapability `json:"completions,omitempty"`
 }
 
-type SamplingCapability struct {
-	Tools bool `json:"tools,omitempty"`
+// RootsCapability means the client can list filesystem roots.
+type RootsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
 }
 
-type ElicitationCapability struct {
-	Enabled bool `json:"enabled,omitempty"`
-}
+// SamplingCapability means the client can sample from an LLM on the server's behalf.
+type SamplingCapability struct{}
+
+// ElicitationCapability means the client can ask the user for input on the server's behalf.
+type ElicitationCapability struct{}
 
 // ToolsCapability represents the tools capability with list change support.
 type ToolsCapability struct {
@@ -95,6 +131,15 @@ type PromptsCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+// LoggingCapability means the server sends log messages and accepts logging/setLevel.
+type LoggingCapability struct{}
+
+// CompletionsCapability means the server answers completion/complete.
+type CompletionsCapability struct{}
+
+// NewInitRequest builds the initialize request the proxy sends to a backend.
+// It offers no client capabilities: the proxy does not relay roots,
+// sampling or elicitation requests from backends to its own client.
 func NewInitRequest(clientName, clientVersion string) InitRequest {
 	return InitRequest{
 		JSONRPC: "2.0",
@@ -105,71 +150,45 @@ func NewInitRequest(clientName, clientVersion string) InitRequest {
 				Name:    clientName,
 				Version: clientVersion,
 			},
-			Capabilities: Capabilities{
-				Sampling: &SamplingCapability{
-					Tools: false,
-				},
-				Tools: &ToolsCapability{
-					ListChanged: false,
-				},
-				ListChanged: false,
-				Subscribe:   false,
-				Logging:     false,
-			},
+			Capabilities:    Capabilities{},
 			ProtocolVersion: MCPProtocolVersion,
 		},
 	}
 }
 
+// ValidateProtocolVersion checks that both sides of a connection use a
+// supported protocol version. They need not match: each hop through the
+// proxy negotiates its own.
 func ValidateProtocolVersion(clientVersion, serverVersion string) error {
 	// Both versions must be present
 	if clientVersion == "" || serverVersion == "" {
 		return fmt.Errorf("empty protocol version: client=%s, server=%s", clientVersion, serverVersion)
 	}
-
-	// Allow newer/older protocol versions to keep compatibility with clients that advance the version.
-	return nil
-}
-
-func IntersectCapabilities(client, server Capabilities) Capabilities {
-	// Intersect capabilities: only advertise what BOTH client and server support
-	result := Capabilities{}
-
-	// Sampling: both must support it
-	if client.Sampling != nil && server.Sampling != nil {
-		result.Sampling = &SamplingCapability{
-			Tools: client.Sampling.Tools && server.Sampling.Tools,
-		}
-	}
-
-	// Elicitation: both must support it
-	if client.Elicitation != nil && server.Elicitation != nil {
-		result.Elicitation = &ElicitationCapability{
-			Enabled: client.Elicitation.Enabled && server.Elicitation.Enabled,
-		}
+	if !IsSupportedProtocolVersion(clientVersion) {
+		return fmt.Errorf("unsupported client protocol version %s (supported: %v)", clientVersion, SupportedProtocolVersions)
 	}
-
-	// Tools: both must support it
-	if client.Tools != nil && server.Tools != nil {
-		result.Tools = &ToolsCapability{
-			ListChanged: client.Tools.ListChanged && server.Tools.ListChanged,
-		}
+	if !IsSupportedProtocolVersion(serverVersion) {
+		return fmt.Errorf("unsupported server protocol version %s (supported: %v)", serverVersion, SupportedProtocolVersions)
 	}
+	return nil
+}
 
-	// ListChanged: both must support it
-	result.ListChanged = ToBoolean(client.ListChanged) && ToBoolean(server.ListChanged)
-
-	// Subscribe: both must support it
-	result.Subscribe = ToBoolean(client.Subscribe) && ToBoolean(server.Subscribe)
-
-	// Logging: both must support it
-	clientLogging := ToBoolean(client.Logging)
-	serverLogging := ToBoolean(server.Logging)
-	if clientLogging && serverLogging {
-		result.Logging = true
+// NegotiateCapabilities returns the capabilities in effect for a session:
+// the client capabilities the client offered and the server capabilities
+// the server offered. In MCP each side declares only its own half, so this
+// keeps each half from the side that owns it and drops anything declared by
+// the wrong side.
+func NegotiateCapabilities(client, server Capabilities) Capabilities {
+	return Capabilities{
+		Roots:       client.Roots,
+		Sampling:    client.Sampling,
+		Elicitation: client.Elicitation,
+		Tools:       server.Tools,
+		Resources:   server.Resources,
+		Prompts:     server.Prompts,
+		Logging:     server.Logging,
+		Completions: server.Completions,
 	}
-
-	return result
 }
 
 type Notification struct {
@@ -11,7 +11,7 @@ type Proxy struct {
 	mu                 sync.RWMutex
 	serverCapabilities map[string]Capabilities
 	clientCapabilities Capabilities
-	intersectedCaps    Capabilities
+	negotiatedCaps     Capabilities
 	protocolVersion    string
 	initialized        bool
 	sessionID          string
@@ -35,8 +35,8 @@ func (p *Proxy) Initialize(req InitRequest, serverResp InitResponseResult) error
 
 	p.clientCapabilities = req.Params.Capabilities
 	p.serverCapabilities[serverResp.ServerInfo.Name] = serverResp.Capabilities
-	p.intersectedCaps = IntersectCapabilities(req.Params.Capabilities, serverResp.Capabilities)
-	p.protocolVersion = req.Params.ProtocolVersion
+	p.negotiatedCaps = NegotiateCapabilities(req.Params.Capabilities, serverResp.Capabilities)
+	p.protocolVersion = serverResp.ProtocolVersion
 	p.initialized = true
 
 	return nil
@@ -48,10 +48,10 @@ func (p *Proxy) IsInitialized() bool {
 	return p.initialized
 }
 
-func (p *Proxy) GetIntersectedCapabilities() Capabilities {
+func (p *Proxy) GetNegotiatedCapabilities() Capabilities {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return p.intersectedCaps
+	return p.negotiatedCaps
 }
 
 func (p *Proxy) GetProtocolVersion() string {
@@ -60,66 +60,69 @@ func (p *Proxy) GetProtocolVersion() string {
 	return p.protocolVersion
 }
 
+// CanSample reports whether the client accepts sampling/createMessage.
 func (p *Proxy) CanSample() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return p.intersectedCaps.Sampling != nil && p.intersectedCaps.Sampling.Tools
+	return p.negotiatedCaps.Sampling != nil
 }
 
+// CanElicit reports whether the client accepts elicitation/create.
 func (p *Proxy) CanElicit() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return p.intersectedCaps.Elicitation != nil && p.intersectedCaps.Elicitation.Enabled
+	return p.negotiatedCaps.Elicitation != nil
 }
 
 func (p *Proxy) CanSubscribe() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return p.intersectedCaps.Subscribe
-}
-
-func (p *Proxy) HasListChanged() bool {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.intersectedCaps.ListChanged
+	return p.negotiatedCaps.Resources != nil && p.negotiatedCaps.Resources.Subscribe
 }
 
 func (p *Proxy) HasLogging() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return ToBoolean(p.intersectedCaps.Logging)
+	return p.negotiatedCaps.Logging != nil
 }
 
 func (p *Proxy) ValidateCapability(capability string) error {
-	caps := p.GetIntersectedCapabilities()
+	caps := p.GetNegotiatedCapabilities()
 
+	var ok bool
 	switch capability {
+	case "roots":
+		ok = caps.Roots != nil
+	case "roots.listChanged":
+		ok = caps.Roots != nil && caps.Roots.ListChanged
 	case "sampling":
-		if caps.Sampling == nil || !caps.Sampling.Tools {
-			return fmt.Errorf("sampling capability not available")
-		}
-	case "sampling.tools":
-		if caps.Sampling == nil || !caps.Sampling.Tools {
-			return fmt.Errorf("sampling.tools capability not available")
-		}
+		ok = caps.Sampling != nil
 	case "elicitation":
-		if caps.Elicitation == nil || !caps.Elicitation.Enabled {
-			return fmt.Errorf("elicitation capability not available")
-		}
+		ok = caps.Elicitation != nil
+	case "tools":
+		ok = caps.Tools != nil
+	case "tools.listChanged":
+		ok = caps.Tools != nil && caps.Tools.ListChanged
+	case "resources":
+		ok = caps.Resources != nil
 	case "resources.subscribe":
-		if !caps.Subscribe {
-			return fmt.Errorf("resources.subscribe capability not available")
-		}
+		ok = caps.Resources != nil && caps.Resources.Subscribe
 	case "resources.listChanged":
-		if !caps.ListChanged {
-			return fmt.Errorf("resources.listChanged capability not available")
-		}
+		ok = caps.Resources != nil && caps.Resources.ListChanged
+	case "prompts":
+		ok = caps.Prompts != nil
+	case "prompts.listChanged":
+		ok = caps.Prompts != nil && caps.Prompts.ListChanged
 	case "logging":
-		if !ToBoolean(caps.Logging) {
-			return fmt.Errorf("logging capability not available")
-		}
+		ok = caps.Logging != nil
+	case "completions":
+		ok = caps.Completions != nil
+	default:
+		return fmt.Errorf("unknown capability %s", capability)
+	}
+	if !ok {
+		return fmt.Errorf("%s capability not available", capability)
 	}
-
 	return nil
 }
 
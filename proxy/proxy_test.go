@@ -20,15 +20,8 @@ func TestInitVersionSuccess(t *testing.T) {
 			Version: "1.0.16",
 		},
 		Capabilities: Capabilities{
-			Sampling: &SamplingCapability{
-				Tools: true,
-			},
-			Elicitation: &ElicitationCapability{
-				Enabled: true,
-			},
-			ListChanged: true,
-			Subscribe:   true,
-			Logging:     false,
+			Tools:     &ToolsCapability{ListChanged: true},
+			Resources: &ResourcesCapability{Subscribe: true},
 		},
 		ProtocolVersion: MCPProtocolVersion,
 	}
@@ -57,6 +50,8 @@ func TestInitVersionMismatch(t *testing.T) {
 		{"client version mismatch", "2024-01-01", MCPProtocolVersion, true},
 		{"server version mismatch", MCPProtocolVersion, "2024-01-01", true},
 		{"both versions mismatch", "2024-01-01", "2024-06-01", true},
+		{"server on older supported version", MCPProtocolVersion, "2024-11-05", false},
+		{"empty version", "", MCPProtocolVersion, true},
 	}
 
 	for _, tt := range tests {
@@ -96,65 +91,54 @@ func TestInitVersionMismatch(t *testing.T) {
 	}
 }
 
-func TestCapabilityIntersection(t *testing.T) {
+func TestCapabilityNegotiation(t *testing.T) {
 	tests := []struct {
-		name                string
-		clientCaps          Capabilities
-		serverCaps          Capabilities
-		expectedSampling    *SamplingCapability
-		expectedElicit      *ElicitationCapability
-		expectedListChanged bool
-		expectedSubscribe   bool
+		name              string
+		clientCaps        Capabilities
+		serverCaps        Capabilities
+		expectedSampling  bool
+		expectedElicit    bool
+		expectedTools     bool
+		expectedSubscribe bool
 	}{
 		{
-			name: "both have all capabilities",
+			name: "each side declares its own half",
 			clientCaps: Capabilities{
-				Sampling:    &SamplingCapability{Tools: true},
-				Elicitation: &ElicitationCapability{Enabled: true},
-				ListChanged: true,
-				Subscribe:   true,
+				Sampling:    &SamplingCapability{},
+				Elicitation: &ElicitationCapability{},
 			},
 			serverCaps: Capabilities{
-				Sampling:    &SamplingCapability{Tools: true},
-				Elicitation: &ElicitationCapability{Enabled: true},
-				ListChanged: true,
-				Subscribe:   true,
+				Tools:     &ToolsCapability{ListChanged: true},
+				Resources: &ResourcesCapability{Subscribe: true},
 			},
-			expectedSampling:    &SamplingCapability{Tools: true},
-			expectedElicit:      &ElicitationCapability{Enabled: true},
-			expectedListChanged: true,
-			expectedSubscribe:   true,
+			expectedSampling:  true,
+			expectedElicit:    true,
+			expectedTools:     true,
+			expectedSubscribe: true,
 		},
 		{
 			name: "client missing sampling",
 			clientCaps: Capabilities{
-				Elicitation: &ElicitationCapability{Enabled: true},
+				Elicitation: &ElicitationCapability{},
 			},
-			serverCaps: Capabilities{
-				Sampling:    &SamplingCapability{Tools: true},
-				Elicitation: &ElicitationCapability{Enabled: true},
-			},
-			expectedSampling: nil,
-			expectedElicit:   &ElicitationCapability{Enabled: true},
+			serverCaps:     Capabilities{},
+			expectedElicit: true,
 		},
 		{
-			name: "server missing elicitation",
-			clientCaps: Capabilities{
-				Elicitation: &ElicitationCapability{Enabled: true},
+			name:       "client capabilities declared by server are ignored",
+			clientCaps: Capabilities{},
+			serverCaps: Capabilities{
+				Sampling:    &SamplingCapability{},
+				Elicitation: &ElicitationCapability{},
 			},
-			serverCaps:       Capabilities{},
-			expectedSampling: nil,
-			expectedElicit:   nil,
 		},
 		{
-			name: "sampling.tools mismatch",
+			name: "server capabilities declared by client are ignored",
 			clientCaps: Capabilities{
-				Sampling: &SamplingCapability{Tools: true},
-			},
-			serverCaps: Capabilities{
-				Sampling: &SamplingCapability{Tools: false},
+				Tools:     &ToolsCapability{},
+				Resources: &ResourcesCapability{Subscribe: true},
 			},
-			expectedSampling: &SamplingCapability{Tools: false},
+			serverCaps: Capabilities{},
 		},
 	}
 
@@ -180,23 +164,19 @@ func TestCapabilityIntersection(t *testing.T) {
 				t.Fatalf("initialize failed: %v", err)
 			}
 
-			caps := p.GetIntersectedCapabilities()
+			caps := p.GetNegotiatedCapabilities()
 
-			if (caps.Sampling == nil) != (tt.expectedSampling == nil) {
-				t.Errorf("sampling nil mismatch: got %v, expected %v", caps.Sampling == nil, tt.expectedSampling == nil)
+			if (caps.Sampling != nil) != tt.expectedSampling {
+				t.Errorf("sampling: got %v, expected %v", caps.Sampling != nil, tt.expectedSampling)
 			}
-			if caps.Sampling != nil && tt.expectedSampling != nil {
-				if caps.Sampling.Tools != tt.expectedSampling.Tools {
-					t.Errorf("sampling.tools: got %v, expected %v", caps.Sampling.Tools, tt.expectedSampling.Tools)
-				}
+			if (caps.Elicitation != nil) != tt.expectedElicit {
+				t.Errorf("elicitation: got %v, expected %v", caps.Elicitation != nil, tt.expectedElicit)
 			}
-
-			if caps.ListChanged != tt.expectedListChanged {
-				t.Errorf("listChanged: got %v, expected %v", caps.ListChanged, tt.expectedListChanged)
+			if (caps.Tools != nil) != tt.expectedTools {
+				t.Errorf("tools: got %v, expected %v", caps.Tools != nil, tt.expectedTools)
 			}
-
-			if caps.Subscribe != tt.expectedSubscribe {
-				t.Errorf("subscribe: got %v, expected %v", caps.Subscribe, tt.expectedSubscribe)
+			if p.CanSubscribe() != tt.expectedSubscribe {
+				t.Errorf("subscribe: got %v, expected %v", p.CanSubscribe(), tt.expectedSubscribe)
 			}
 		})
 	}
@@ -206,55 +186,69 @@ func TestCapabilityValidation(t *testing.T) {
 	tests := []struct {
 		name       string
 		capability string
-		caps       Capabilities
+		clientCaps Capabilities
+		serverCaps Capabilities
 		shouldFail bool
 	}{
 		{
 			name:       "sampling allowed",
 			capability: "sampling",
-			caps: Capabilities{
-				Sampling: &SamplingCapability{Tools: true},
-			},
+			clientCaps: Capabilities{Sampling: &SamplingCapability{}},
 			shouldFail: false,
 		},
 		{
-			name:       "sampling denied when not in intersection",
+			name:       "sampling denied when client lacks it",
 			capability: "sampling",
-			caps:       Capabilities{},
-			shouldFail: true,
-		},
-		{
-			name:       "sampling.tools denied when tools=false",
-			capability: "sampling.tools",
-			caps: Capabilities{
-				Sampling: &SamplingCapability{Tools: false},
-			},
+			serverCaps: Capabilities{Sampling: &SamplingCapability{}},
 			shouldFail: true,
 		},
 		{
 			name:       "elicitation allowed",
 			capability: "elicitation",
-			caps: Capabilities{
-				Elicitation: &ElicitationCapability{Enabled: true},
-			},
+			clientCaps: Capabilities{Elicitation: &ElicitationCapability{}},
 			shouldFail: false,
 		},
+		{
+			name:       "roots.listChanged denied when listChanged=false",
+			capability: "roots.listChanged",
+			clientCaps: Capabilities{Roots: &RootsCapability{}},
+			shouldFail: true,
+		},
 		{
 			name:       "resources.subscribe allowed",
 			capability: "resources.subscribe",
-			caps: Capabilities{
-				Subscribe: true,
-			},
+			serverCaps: Capabilities{Resources: &ResourcesCapability{Subscribe: true}},
 			shouldFail: false,
 		},
 		{
-			name:       "resources.listChanged allowed",
+			name:       "resources.listChanged denied when only subscribe",
 			capability: "resources.listChanged",
-			caps: Capabilities{
-				ListChanged: true,
-			},
+			serverCaps: Capabilities{Resources: &ResourcesCapability{Subscribe: true}},
+			shouldFail: true,
+		},
+		{
+			name:       "prompts.listChanged allowed",
+			capability: "prompts.listChanged",
+			serverCaps: Capabilities{Prompts: &PromptsCapability{ListChanged: true}},
+			shouldFail: false,
+		},
+		{
+			name:       "logging allowed",
+			capability: "logging",
+			serverCaps: Capabilities{Logging: &LoggingCapability{}},
 			shouldFail: false,
 		},
+		{
+			name:       "completions denied",
+			capability: "completions",
+			shouldFail: true,
+		},
+		{
+			name:       "unknown capability",
+			capability: "sampling.tools",
+			clientCaps: Capabilities{Sampling: &SamplingCapability{}},
+			shouldFail: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -264,14 +258,14 @@ func TestCapabilityValidation(t *testing.T) {
 
 			p := NewProxy(db)
 			clientReq := NewInitRequest("TestClient", "1.0.16")
-			clientReq.Params.Capabilities = tt.caps
+			clientReq.Params.Capabilities = tt.clientCaps
 
 			serverResp := InitResponseResult{
 				ServerInfo: ServerInfo{
 					Name:    "TestServer",
 					Version: "1.0.16",
 				},
-				Capabilities:    tt.caps,
+				Capabilities:    tt.serverCaps,
 				ProtocolVersion: MCPProtocolVersion,
 			}
 
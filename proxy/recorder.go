@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Directions of recorded messages, relative to the proxy.
+const (
+	DirClientToProxy  = "client->proxy"
+	DirProxyToClient  = "proxy->client"
+	DirProxyToBackend = "proxy->backend"
+	DirBackendToProxy = "backend->proxy"
+)
+
+// redactedValue replaces secrets in recorded messages.
+const redactedValue = "[REDACTED]"
+
+// RecordedMessage is one line of a session recording.
+type RecordedMessage struct {
+	Seq       int64           `json:"seq"`
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"direction"`
+	Server    string          `json:"server,omitempty"` // backend name for proxy<->backend traffic
+	Message   json.RawMessage `json:"message"`
+}
+
+// SessionRecorder appends every JSON-RPC message the proxy sends or receives
+// to a JSONL file, in order, with secrets redacted. A nil recorder is a no-op.
+type SessionRecorder struct {
+	out    io.Writer
+	closer io.Closer
+	seq    int64
+	mu     sync.Mutex
+}
+
+// NewSessionRecorder records to w.
+func NewSessionRecorder(w io.Writer) *SessionRecorder {
+	return &SessionRecorder{out: w}
+}
+
+// OpenSessionRecorder creates (or truncates) a recording file readable only by the user.
+func OpenSessionRecorder(path string) (*SessionRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session recording: %w", err)
+	}
+	return &SessionRecorder{out: f, closer: f}, nil
+}
+
+// Record appends a message. Errors are ignored so recording never disturbs traffic.
+func (r *SessionRecorder) Record(direction, server string, msg []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	line, err := json.Marshal(RecordedMessage{
+		Seq:       r.seq,
+		Time:      time.Now(),
+		Direction: direction,
+		Server:    server,
+		Message:   RedactMessage(msg),
+	})
+	if err != nil {
+		return
+	}
+	r.out.Write(append(line, '\n'))
+}
+
+// Close closes the recording file.
+func (r *SessionRecorder) Close() error {
+	if r == nil || r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// LoadRecording reads a session recording.
+func LoadRecording(path string) ([]RecordedMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	var messages []RecordedMessage
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var m RecordedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		messages = append(messages, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return messages, nil
+}
+
+// sensitiveKeys are substrings of object keys whose string values are redacted.
+var sensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "apikey", "api_key", "api-key",
+	"authorization", "cookie", "credential", "private_key", "privatekey",
+}
+
+// secretPatterns match well-known credential formats anywhere in a string.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{20,}`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{20,}`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]{8,}`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// RedactMessage masks the values of secret-looking keys and credential
+// patterns in a JSON message. Messages that aren't valid JSON are stored as
+// a redacted JSON string.
+func RedactMessage(msg []byte) json.RawMessage {
+	var v interface{}
+	if err := json.Unmarshal(msg, &v); err != nil {
+		data, _ := json.Marshal(redactString(string(msg)))
+		return data
+	}
+	data, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return json.RawMessage(`null`)
+	}
+	return data
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if _, isString := child.(string); isString && isSensitiveKey(k) {
+				v[k] = redactedValue
+				continue
+			}
+			v[k] = redactValue(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(child)
+		}
+		return v
+	case string:
+		return redactString(v)
+	}
+	return v
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactString(s string) string {
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, redactedValue)
+	}
+	return s
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 type ServerEntry struct {
@@ -15,6 +17,215 @@ type ServerEntry struct {
 	Args      []string          `json:"args,omitempty"`
 	Env       map[string]string `json:"env,omitempty"`
 	Headers   map[string]string `json:"headers,omitempty"`
+	// Pool is the number of sessions opened to an http server, so that many
+	// requests can be in flight at once (default 1).
+	Pool int `json:"pool,omitempty"`
+	// Path is the URL path the server is exposed on in HTTP mode (default /mcp/<name>).
+	Path string `json:"path,omitempty"`
+	// Fixture is the YAML file a "mock" transport server answers from.
+	Fixture string `json:"fixture,omitempty"`
+	// Tags group servers (e.g. prod, filesystem) so rules can target them as tag:<name>.
+	Tags []string `json:"tags,omitempty"`
+	// AllowedRoots sandboxes the server's file arguments to these directories (absolute or ~/).
+	AllowedRoots []string `json:"allowed_roots,omitempty"`
+	// Restart says when a stdio server's subprocess is restarted after it exits.
+	Restart *RestartPolicy `json:"restart,omitempty"`
+	// Retry retries idempotent requests that fail on the way to the server.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Hedge sends slow idempotent requests to an http server's replica too.
+	Hedge *HedgePolicy `json:"hedge,omitempty"`
+	// Limits caps the CPU, memory and lifetime of a stdio server's subprocess.
+	Limits *ResourceLimits `json:"limits,omitempty"`
+	// Cwd is the working directory of a stdio server's subprocess (absolute or ~/).
+	Cwd string `json:"cwd,omitempty"`
+	// User runs a stdio server's subprocess as another user, by name or uid (unix only).
+	User string `json:"user,omitempty"`
+}
+
+// ResourceLimits caps a stdio server's subprocess. Memory and CPU are
+// enforced with cgroups on Linux (rlimits if cgroups can't be used), job
+// objects on Windows and rlimits on macOS, as far as the platform allows.
+// A process that outlives MaxLifetime is stopped; the restart policy then
+// decides whether it comes back.
+type ResourceLimits struct {
+	MemoryMB    int    `json:"memory_mb,omitempty"`
+	CPUPercent  int    `json:"cpu_percent,omitempty"` // of one core; 200 is two cores
+	MaxLifetime string `json:"max_lifetime,omitempty"`
+}
+
+// Validate checks that the limits are positive and the lifetime parses.
+func (l *ResourceLimits) Validate() error {
+	if l.MemoryMB < 0 || l.CPUPercent < 0 {
+		return fmt.Errorf("resource limits must not be negative")
+	}
+	if l.MaxLifetime != "" {
+		if d, err := time.ParseDuration(l.MaxLifetime); err != nil || d <= 0 {
+			return fmt.Errorf("invalid max_lifetime %q", l.MaxLifetime)
+		}
+	}
+	return nil
+}
+
+// Lifetime returns MaxLifetime, or 0 if unset.
+func (l *ResourceLimits) Lifetime() time.Duration {
+	if l == nil {
+		return 0
+	}
+	d, _ := time.ParseDuration(l.MaxLifetime)
+	return d
+}
+
+// Restart modes of a stdio server.
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// RestartPolicy restarts a stdio server's subprocess when it exits. Restarts
+// are delayed by Backoff, doubled for each restart in a row; after MaxRetries
+// restarts in a row the server is quarantined and left stopped.
+type RestartPolicy struct {
+	Mode       string `json:"mode,omitempty"`        // never, on-failure (default) or always
+	MaxRetries int    `json:"max_retries,omitempty"` // default 5
+	Backoff    string `json:"backoff,omitempty"`     // delay before the first restart, default 1s
+}
+
+// Validate checks the mode and backoff.
+func (p *RestartPolicy) Validate() error {
+	switch p.Mode {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("invalid restart mode %q (want never, on-failure or always)", p.Mode)
+	}
+	if p.MaxRetries < 0 {
+		return fmt.Errorf("restart max_retries must not be negative")
+	}
+	if p.Backoff != "" {
+		if d, err := time.ParseDuration(p.Backoff); err != nil || d < 0 {
+			return fmt.Errorf("invalid restart backoff %q", p.Backoff)
+		}
+	}
+	return nil
+}
+
+// RetryPolicy retries requests to a server that fail with a network error or
+// one of the On statuses. Only idempotent requests are retried: list, read
+// and get methods, tools named like list_*, read_* or get_*, and the tools
+// in IdempotentTools. Retries are delayed by Backoff, doubled for each retry.
+type RetryPolicy struct {
+	Attempts        int      `json:"attempts,omitempty"`         // retries after the first try, default 2
+	Backoff         string   `json:"backoff,omitempty"`          // delay before the first retry, default 200ms
+	On              []int    `json:"on,omitempty"`               // HTTP statuses retried, default 502, 503 and 504
+	IdempotentTools []string `json:"idempotent_tools,omitempty"` // more tools safe to retry; a trailing or leading * matches
+}
+
+// Default retry settings.
+const (
+	DefaultRetryAttempts = 2
+	DefaultRetryBackoff  = 200 * time.Millisecond
+)
+
+// DefaultRetryStatuses are the HTTP statuses retried when On is unset.
+var DefaultRetryStatuses = []int{502, 503, 504}
+
+// Validate checks the attempts, backoff and statuses.
+func (p *RetryPolicy) Validate() error {
+	if p.Attempts < 0 || p.Attempts > 10 {
+		return fmt.Errorf("retry attempts must be between 0 and 10")
+	}
+	if p.Backoff != "" {
+		if d, err := time.ParseDuration(p.Backoff); err != nil || d < 0 {
+			return fmt.Errorf("invalid retry backoff %q", p.Backoff)
+		}
+	}
+	for _, code := range p.On {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("invalid retry status %d (want 400-599)", code)
+		}
+	}
+	for _, tool := range p.IdempotentTools {
+		if tool == "" {
+			return fmt.Errorf("retry idempotent_tools: empty tool name")
+		}
+	}
+	return nil
+}
+
+// MaxAttempts returns the number of retries after the first try.
+func (p *RetryPolicy) MaxAttempts() int {
+	if p.Attempts == 0 {
+		return DefaultRetryAttempts
+	}
+	return p.Attempts
+}
+
+// Delay returns the backoff before the first retry.
+func (p *RetryPolicy) Delay() time.Duration {
+	if d, err := time.ParseDuration(p.Backoff); err == nil {
+		return d
+	}
+	return DefaultRetryBackoff
+}
+
+// Statuses returns the HTTP statuses that are retried.
+func (p *RetryPolicy) Statuses() []int {
+	if len(p.On) == 0 {
+		return DefaultRetryStatuses
+	}
+	return p.On
+}
+
+// HedgePolicy sends an idempotent request (see RetryPolicy) to a replica of
+// an http server as well when the server hasn't answered it within After, and
+// takes whichever response comes first.
+type HedgePolicy struct {
+	URL   string `json:"url"`             // the replica's MCP endpoint
+	After string `json:"after,omitempty"` // latency before hedging, default 500ms
+}
+
+// DefaultHedgeAfter is the latency before a request is hedged.
+const DefaultHedgeAfter = 500 * time.Millisecond
+
+// Validate checks the replica URL and threshold.
+func (p *HedgePolicy) Validate() error {
+	if !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+		return fmt.Errorf("hedge url %q must be an http(s) URL", p.URL)
+	}
+	if p.After != "" {
+		if d, err := time.ParseDuration(p.After); err != nil || d <= 0 {
+			return fmt.Errorf("invalid hedge after %q", p.After)
+		}
+	}
+	return nil
+}
+
+// Threshold returns the latency before a request is hedged.
+func (p *HedgePolicy) Threshold() time.Duration {
+	if d, err := time.ParseDuration(p.After); err == nil {
+		return d
+	}
+	return DefaultHedgeAfter
+}
+
+// MaxPool caps the sessions opened to one http server.
+const MaxPool = 64
+
+// PoolSize returns the number of sessions to open to the server: Pool for
+// http servers, otherwise 1.
+func (e *ServerEntry) PoolSize() int {
+	if e.Transport != "http" || e.Pool < 1 {
+		return 1
+	}
+	return e.Pool
+}
+
+// RoutePath returns the URL path the server is exposed on in HTTP mode.
+func (e *ServerEntry) RoutePath() string {
+	if e.Path != "" {
+		return e.Path
+	}
+	return "/mcp/" + e.Name
 }
 
 type ServerRegistry struct {
@@ -43,12 +254,18 @@ func LoadServerRegistry(configPath string) (*ServerRegistry, error) {
 	return &registry, nil
 }
 
+// Validate checks a registry built in code as LoadServerRegistry checks a file.
+func (r *ServerRegistry) Validate() error {
+	return validateRegistry(r)
+}
+
 func validateRegistry(registry *ServerRegistry) error {
 	// Allow empty server list during initial setup - user will configure via /proxy-setup
 	if len(registry.Servers) == 0 {
 		return nil
 	}
 
+	paths := make(map[string]string)
 	for i, s := range registry.Servers {
 		if s.Name == "" {
 			return fmt.Errorf("server %d missing name", i)
@@ -62,6 +279,62 @@ func validateRegistry(registry *ServerRegistry) error {
 		if s.Transport == "stdio" && s.Command == "" {
 			return fmt.Errorf("server %s (stdio) missing command", s.Name)
 		}
+		if s.Transport == "mock" && s.Fixture == "" {
+			return fmt.Errorf("server %s (mock) missing fixture", s.Name)
+		}
+		if s.Pool < 0 || s.Pool > MaxPool {
+			return fmt.Errorf("server %s pool must be between 1 and %d", s.Name, MaxPool)
+		}
+		if s.Pool > 1 && s.Transport != "http" {
+			return fmt.Errorf("server %s: pool is only supported for http servers", s.Name)
+		}
+		if s.Path != "" && (!strings.HasPrefix(s.Path, "/") || strings.HasSuffix(s.Path, "/")) {
+			return fmt.Errorf("server %s path must start with / and not end with /: %q", s.Name, s.Path)
+		}
+		for _, tag := range s.Tags {
+			if tag == "" || strings.ContainsAny(tag, ", \t\n") {
+				return fmt.Errorf("server %s has invalid tag %q: tags may not be empty or contain commas or spaces", s.Name, tag)
+			}
+		}
+		for _, root := range s.AllowedRoots {
+			if !filepath.IsAbs(root) && root != "~" && !strings.HasPrefix(root, "~/") {
+				return fmt.Errorf("server %s allowed root %q must be an absolute path", s.Name, root)
+			}
+		}
+		if s.Cwd != "" && !filepath.IsAbs(s.Cwd) && s.Cwd != "~" && !strings.HasPrefix(s.Cwd, "~/") {
+			return fmt.Errorf("server %s cwd %q must be an absolute path", s.Name, s.Cwd)
+		}
+		if s.User != "" && strings.TrimSpace(s.User) != s.User {
+			return fmt.Errorf("server %s has invalid user %q", s.Name, s.User)
+		}
+		if s.Restart != nil {
+			if err := s.Restart.Validate(); err != nil {
+				return fmt.Errorf("server %s: %w", s.Name, err)
+			}
+		}
+		if s.Retry != nil {
+			if err := s.Retry.Validate(); err != nil {
+				return fmt.Errorf("server %s: %w", s.Name, err)
+			}
+		}
+		if s.Hedge != nil {
+			if s.Transport != "http" {
+				return fmt.Errorf("server %s: hedge is only supported for http servers", s.Name)
+			}
+			if err := s.Hedge.Validate(); err != nil {
+				return fmt.Errorf("server %s: %w", s.Name, err)
+			}
+		}
+		if s.Limits != nil {
+			if err := s.Limits.Validate(); err != nil {
+				return fmt.Errorf("server %s: %w", s.Name, err)
+			}
+		}
+		route := s.RoutePath()
+		if other, ok := paths[route]; ok {
+			return fmt.Errorf("servers %s and %s share path %s", other, s.Name, route)
+		}
+		paths[route] = s.Name
 	}
 
 	return nil
@@ -108,3 +381,14 @@ func (r *ServerRegistry) GetServer(id string) *ServerEntry {
 
 	return nil
 }
+
+// GetServerByPath returns the server exposed on the given HTTP path, or nil.
+func (r *ServerRegistry) GetServerByPath(path string) *ServerEntry {
+	path = strings.TrimSuffix(path, "/")
+	for i := range r.Servers {
+		if r.Servers[i].RoutePath() == path {
+			return &r.Servers[i]
+		}
+	}
+	return nil
+}
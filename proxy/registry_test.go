@@ -128,6 +128,137 @@ func TestLoadServerRegistry_StdioMissingCommand(t *testing.T) {
 	}
 }
 
+func TestLoadServerRegistry_InvalidTag(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+
+	config := `{
+  "servers": [
+    {"name": "db", "transport": "http", "url": "http://localhost:8082", "tags": ["prod,network"]}
+  ]
+}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := LoadServerRegistry(configPath)
+	if err == nil {
+		t.Fatal("expected error for tag containing a comma")
+	}
+}
+
+func TestLoadServerRegistry_InvalidRestart(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+
+	config := `{
+  "servers": [
+    {"name": "fs", "transport": "stdio", "command": "fs-mcp", "restart": {"mode": "sometimes"}}
+  ]
+}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := LoadServerRegistry(configPath)
+	if err == nil {
+		t.Fatal("expected error for unknown restart mode")
+	}
+}
+
+func TestLoadServerRegistry_InvalidLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+
+	config := `{
+  "servers": [
+    {"name": "fs", "transport": "stdio", "command": "fs-mcp", "limits": {"memory_mb": 512, "max_lifetime": "forever"}}
+  ]
+}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := LoadServerRegistry(configPath)
+	if err == nil {
+		t.Fatal("expected error for unparseable max_lifetime")
+	}
+}
+
+func TestLoadServerRegistry_InvalidPool(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+
+	for _, server := range []string{
+		`{"name": "api", "transport": "http", "url": "http://localhost:9000", "pool": 1000}`,
+		`{"name": "fs", "transport": "stdio", "command": "fs-mcp", "pool": 4}`,
+	} {
+		if err := os.WriteFile(configPath, []byte(`{"servers": [`+server+`]}`), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if _, err := LoadServerRegistry(configPath); err == nil {
+			t.Errorf("expected error for %s", server)
+		}
+	}
+}
+
+func TestLoadServerRegistry_InvalidRetry(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+
+	for _, retry := range []string{
+		`{"attempts": -1}`,
+		`{"backoff": "soon"}`,
+		`{"on": [200]}`,
+		`{"idempotent_tools": [""]}`,
+	} {
+		config := `{"servers": [{"name": "api", "transport": "http", "url": "http://localhost:9000", "retry": ` + retry + `}]}`
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if _, err := LoadServerRegistry(configPath); err == nil {
+			t.Errorf("expected error for %s", retry)
+		}
+	}
+}
+
+func TestLoadServerRegistry_InvalidHedge(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+
+	for _, server := range []string{
+		`{"name": "api", "transport": "http", "url": "http://localhost:9000", "hedge": {"url": "localhost:9001"}}`,
+		`{"name": "api", "transport": "http", "url": "http://localhost:9000", "hedge": {"url": "http://localhost:9001", "after": "0s"}}`,
+		`{"name": "fs", "transport": "stdio", "command": "fs-mcp", "hedge": {"url": "http://localhost:9001"}}`,
+	} {
+		if err := os.WriteFile(configPath, []byte(`{"servers": [`+server+`]}`), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if _, err := LoadServerRegistry(configPath); err == nil {
+			t.Errorf("expected error for %s", server)
+		}
+	}
+}
+
+func TestLoadServerRegistry_RelativeCwd(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+
+	config := `{
+  "servers": [
+    {"name": "fs", "transport": "stdio", "command": "fs-mcp", "cwd": "scratch"}
+  ]
+}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := LoadServerRegistry(configPath)
+	if err == nil {
+		t.Fatal("expected error for relative cwd")
+	}
+}
+
 func TestGetServer_SingleServer(t *testing.T) {
 	registry := &ServerRegistry{
 		Servers: []ServerEntry{
@@ -173,3 +304,50 @@ func TestGetServer_NotFound(t *testing.T) {
 		t.Fatal("expected nil for missing server")
 	}
 }
+
+func TestGetServerByPath(t *testing.T) {
+	registry := &ServerRegistry{
+		Servers: []ServerEntry{
+			{Name: "github", Transport: "http", URL: "http://localhost:8082"},
+			{Name: "database", Transport: "http", URL: "http://localhost:8083", Path: "/mcp/db"},
+		},
+	}
+
+	tests := map[string]string{
+		"/mcp/github":   "github",
+		"/mcp/github/":  "github",
+		"/mcp/db":       "database",
+		"/mcp/database": "",
+		"/other":        "",
+	}
+	for path, want := range tests {
+		server := registry.GetServerByPath(path)
+		got := ""
+		if server != nil {
+			got = server.Name
+		}
+		if got != want {
+			t.Errorf("GetServerByPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestLoadServerRegistry_DuplicatePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+
+	config := `{
+  "servers": [
+    {"name": "db", "transport": "http", "url": "http://localhost:8082"},
+    {"name": "database", "transport": "http", "url": "http://localhost:8083", "path": "/mcp/db"}
+  ]
+}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := LoadServerRegistry(configPath)
+	if err == nil {
+		t.Fatal("expected error for duplicate path")
+	}
+}
@@ -20,11 +20,7 @@ func TestSamplingBlockedWhenCapabilityAbsent(t *testing.T) {
 			Name:    "TestServer",
 			Version: "1.0.16",
 		},
-		Capabilities: Capabilities{
-			Sampling: &SamplingCapability{
-				Tools: false,
-			},
-		},
+		Capabilities:    Capabilities{},
 		ProtocolVersion: MCPProtocolVersion,
 	}
 
@@ -72,16 +68,14 @@ func TestSamplingDisabledOnStdio(t *testing.T) {
 	guard.DisableOnTransport("stdio")
 
 	clientReq := NewInitRequest("TestClient", "1.0.16")
-	clientReq.Params.Capabilities.Sampling = &SamplingCapability{Tools: true}
+	clientReq.Params.Capabilities.Sampling = &SamplingCapability{}
 
 	serverResp := InitResponseResult{
 		ServerInfo: ServerInfo{
 			Name:    "TestServer",
 			Version: "1.0.16",
 		},
-		Capabilities: Capabilities{
-			Sampling: &SamplingCapability{Tools: true},
-		},
+		Capabilities:    Capabilities{},
 		ProtocolVersion: MCPProtocolVersion,
 	}
 
@@ -181,7 +175,7 @@ func TestToolUseToolResultBalance(t *testing.T) {
 	}
 }
 
-func TestSamplingToolsOnlyBothSidesDeclared(t *testing.T) {
+func TestSamplingOnlyWhenClientDeclares(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
@@ -192,21 +186,17 @@ func TestSamplingToolsOnlyBothSidesDeclared(t *testing.T) {
 		expectedAllowed bool
 	}{
 		{
-			name:            "both declare sampling.tools",
-			clientSampling:  &SamplingCapability{Tools: true},
-			serverSampling:  &SamplingCapability{Tools: true},
+			name:            "client declares sampling",
+			clientSampling:  &SamplingCapability{},
 			expectedAllowed: true,
 		},
 		{
-			name:            "client declares, server doesn't",
-			clientSampling:  &SamplingCapability{Tools: true},
-			serverSampling:  &SamplingCapability{Tools: false},
+			name:            "server declares, client doesn't",
+			serverSampling:  &SamplingCapability{},
 			expectedAllowed: false,
 		},
 		{
-			name:            "server declares, client doesn't",
-			clientSampling:  &SamplingCapability{Tools: false},
-			serverSampling:  &SamplingCapability{Tools: true},
+			name:            "neither declares",
 			expectedAllowed: false,
 		},
 	}
@@ -248,16 +238,14 @@ func TestSamplingRequestValidation(t *testing.T) {
 	guard := NewSamplingGuard()
 
 	clientReq := NewInitRequest("TestClient", "1.0.16")
-	clientReq.Params.Capabilities.Sampling = &SamplingCapability{Tools: true}
+	clientReq.Params.Capabilities.Sampling = &SamplingCapability{}
 
 	serverResp := InitResponseResult{
 		ServerInfo: ServerInfo{
 			Name:    "TestServer",
 			Version: "1.0.16",
 		},
-		Capabilities: Capabilities{
-			Sampling: &SamplingCapability{Tools: true},
-		},
+		Capabilities:    Capabilities{},
 		ProtocolVersion: MCPProtocolVersion,
 	}
 
@@ -1,6 +1,9 @@
 package proxy
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -8,19 +11,59 @@ import (
 // TraceEvent captures a high-level step in the proxy pipeline for observability.
 type TraceEvent struct {
 	Time       time.Time `json:"time"`
-	Stage      string    `json:"stage"`      // discovery, blocklist, translate, forward, response
-	Server     string    `json:"server"`     // backend/server name when applicable
-	Method     string    `json:"method"`     // MCP method or HTTP verb
-	Transport  string    `json:"transport"`  // http, stdio, sse, docker, etc.
-	Detail     string    `json:"detail"`     // freeform description
-	Attachment string    `json:"attachment"` // optional extra info (e.g., URI)
+	Stage      string    `json:"stage"`                // discovery, blocklist, translate, forward, response
+	Server     string    `json:"server"`               // backend/server name when applicable
+	Method     string    `json:"method"`               // MCP method or HTTP verb
+	Transport  string    `json:"transport"`            // http, stdio, sse, docker, etc.
+	Detail     string    `json:"detail"`               // freeform description
+	Attachment string    `json:"attachment"`           // optional extra info (e.g., URI)
+	Error      bool      `json:"error,omitempty"`      // the step failed
+	RequestID  string    `json:"request_id,omitempty"` // proxy-wide ID of the client call the step belongs to
+}
+
+// TraceSampling selects the trace events a recorder keeps: all of them
+// ("always"), only failures ("errors"), or one in N ("1/N"). Failures are
+// kept by every sampling, since they are what an investigation looks for.
+type TraceSampling struct {
+	ErrorsOnly bool
+	OneIn      int // keep every Nth event; 0 or 1 keeps all
+}
+
+// ParseTraceSampling parses "always", "errors" or "1/N".
+func ParseTraceSampling(s string) (TraceSampling, error) {
+	switch s = strings.TrimSpace(s); s {
+	case "", "always":
+		return TraceSampling{}, nil
+	case "errors":
+		return TraceSampling{ErrorsOnly: true}, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "1/"); ok {
+		if n, err := strconv.Atoi(rest); err == nil && n > 0 {
+			return TraceSampling{OneIn: n}, nil
+		}
+	}
+	return TraceSampling{}, fmt.Errorf("invalid trace sampling %q: want always, errors or 1/N", s)
+}
+
+// String returns the sampling in the form ParseTraceSampling accepts.
+func (ts TraceSampling) String() string {
+	switch {
+	case ts.ErrorsOnly:
+		return "errors"
+	case ts.OneIn > 1:
+		return fmt.Sprintf("1/%d", ts.OneIn)
+	}
+	return "always"
 }
 
 // TraceRecorder stores a bounded set of recent trace events.
 type TraceRecorder struct {
-	limit int
-	mu    sync.RWMutex
-	buf   []TraceEvent
+	limit    int
+	mu       sync.RWMutex
+	buf      []TraceEvent
+	sampling TraceSampling
+	seen     uint64           // events offered to a 1/N sampling
+	sink     func(TraceEvent) // also receives every kept event, e.g. to persist it
 }
 
 // NewTraceRecorder creates a trace recorder with a fixed buffer size.
@@ -31,16 +74,62 @@ func NewTraceRecorder(limit int) *TraceRecorder {
 	return &TraceRecorder{limit: limit}
 }
 
-// Add records a new trace event.
-func (tr *TraceRecorder) Add(event TraceEvent) {
+// SetSampling changes which events Add keeps.
+func (tr *TraceRecorder) SetSampling(sampling TraceSampling) {
 	tr.mu.Lock()
 	defer tr.mu.Unlock()
+	tr.sampling = sampling
+	tr.seen = 0
+}
+
+// Sampling returns which events Add keeps.
+func (tr *TraceRecorder) Sampling() TraceSampling {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	return tr.sampling
+}
 
+// SetSink passes every event kept from now on to sink as well, after it is
+// buffered. sink must not block.
+func (tr *TraceRecorder) SetSink(sink func(TraceEvent)) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.sink = sink
+}
+
+// Add records a new trace event, unless the sampling drops it.
+func (tr *TraceRecorder) Add(event TraceEvent) {
+	tr.mu.Lock()
+	if !tr.keep(event) {
+		tr.mu.Unlock()
+		return
+	}
 	event.Time = time.Now()
 	tr.buf = append(tr.buf, event)
 	if len(tr.buf) > tr.limit {
 		tr.buf = tr.buf[len(tr.buf)-tr.limit:]
 	}
+	sink := tr.sink
+	tr.mu.Unlock()
+
+	if sink != nil {
+		sink(event)
+	}
+}
+
+// keep applies the sampling to an event. The caller holds tr.mu.
+func (tr *TraceRecorder) keep(event TraceEvent) bool {
+	if event.Error {
+		return true
+	}
+	if tr.sampling.ErrorsOnly {
+		return false
+	}
+	if tr.sampling.OneIn > 1 {
+		tr.seen++
+		return tr.seen%uint64(tr.sampling.OneIn) == 1
+	}
+	return true
 }
 
 // List returns a copy of the current trace buffer in chronological order.
@@ -52,3 +141,23 @@ func (tr *TraceRecorder) List() []TraceEvent {
 	copy(out, tr.buf)
 	return out
 }
+
+// Usage returns how many events the buffer holds and how many it can hold.
+func (tr *TraceRecorder) Usage() (events, limit int) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	return len(tr.buf), tr.limit
+}
+
+// Prune drops events recorded before cutoff and returns how many were dropped.
+func (tr *TraceRecorder) Prune(cutoff time.Time) int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	n := 0
+	for n < len(tr.buf) && tr.buf[n].Time.Before(cutoff) {
+		n++
+	}
+	tr.buf = append([]TraceEvent(nil), tr.buf[n:]...)
+	return n
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import "testing"
+
+func TestTraceSampling(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want TraceSampling
+	}{
+		{"", TraceSampling{}},
+		{"always", TraceSampling{}},
+		{"errors", TraceSampling{ErrorsOnly: true}},
+		{"1/10", TraceSampling{OneIn: 10}},
+	} {
+		got, err := ParseTraceSampling(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseTraceSampling(%q) = %+v, %v; want %+v", tc.in, got, err, tc.want)
+		}
+	}
+	for _, in := range []string{"sometimes", "1/0", "1/x", "2/3"} {
+		if _, err := ParseTraceSampling(in); err == nil {
+			t.Errorf("expected %q to be rejected", in)
+		}
+	}
+
+	tr := NewTraceRecorder(100)
+	var sunk []TraceEvent
+	tr.SetSink(func(ev TraceEvent) { sunk = append(sunk, ev) })
+	tr.SetSampling(TraceSampling{OneIn: 3})
+	for i := 0; i < 9; i++ {
+		tr.Add(TraceEvent{Stage: "response"})
+	}
+	if events, _ := tr.Usage(); events != 3 || len(sunk) != 3 {
+		t.Errorf("expected 1 in 3 of 9 events kept and sunk, got %d and %d", events, len(sunk))
+	}
+
+	tr.SetSampling(TraceSampling{ErrorsOnly: true})
+	tr.Add(TraceEvent{Stage: "response"})
+	tr.Add(TraceEvent{Stage: "response", Error: true})
+	events := tr.List()
+	if len(events) != 4 || !events[3].Error {
+		t.Errorf("expected only the failure to be kept, got %+v", events)
+	}
+	if got := tr.Sampling().String(); got != "errors" {
+		t.Errorf("expected sampling errors, got %s", got)
+	}
+}
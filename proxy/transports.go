@@ -21,6 +21,21 @@ const (
 	sseHTTPClientTimeout = 30 * time.Second
 )
 
+// BackendHTTPTransport carries the proxy's requests to http and sse
+// backends. It keeps enough idle connections per backend for pooled
+// sessions, negotiates HTTP/2 where the backend supports it and asks for
+// gzip-compressed responses, which it decompresses transparently.
+var BackendHTTPTransport http.RoundTripper = newBackendHTTPTransport()
+
+func newBackendHTTPTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 256
+	t.MaxIdleConnsPerHost = MaxPool
+	t.IdleConnTimeout = 90 * time.Second
+	t.ForceAttemptHTTP2 = true
+	return t
+}
+
 type Transport interface {
 	SendMessage(msg []byte) error
 	ReceiveMessage() ([]byte, error)
@@ -28,6 +43,13 @@ type Transport interface {
 	SupportsServerToClient() bool
 }
 
+// VersionedTransport is implemented by transports that send the protocol
+// version in an MCP-Protocol-Version header. After initialize it must carry
+// the version the backend negotiated, not the proxy's newest.
+type VersionedTransport interface {
+	SetProtocolVersion(version string)
+}
+
 type SSETransport struct {
 	client       *http.Client
 	url          string
@@ -42,6 +64,7 @@ type SSETransport struct {
 	httpResp     *http.Response
 	scanner      *bufio.Scanner
 	headers      map[string]string // For custom headers (e.g., API keys)
+	version      string            // Negotiated protocol version, sent as a header
 	lastResponse []byte            // For storing POST response
 	responseReady bool              // Whether lastResponse is ready to read
 	ctx          context.Context   // Context for cancellation and timeouts
@@ -67,13 +90,15 @@ func NewSSETransportWithContext(ctx context.Context, url string) *SSETransport {
 
 	return &SSETransport{
 		client: &http.Client{
-			Timeout: sseHTTPClientTimeout,
+			Transport: BackendHTTPTransport,
+			Timeout:   sseHTTPClientTimeout,
 		},
 		url:         url,
 		sessionID:   sessionID,
 		eventQueue:  make(chan string, sseEventQueueBuffer),
 		receivedIDs: make(map[int]bool),
 		headers:     make(map[string]string),
+		version:     MCPProtocolVersion,
 		ctx:         transportCtx,
 		cancel:      cancel,
 	}
@@ -85,6 +110,12 @@ func (s *SSETransport) SetHeaders(headers map[string]string) {
 	s.headers = headers
 }
 
+func (s *SSETransport) SetProtocolVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
 func (s *SSETransport) Connect() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -95,7 +126,7 @@ func (s *SSETransport) Connect() error {
 	}
 
 	// Set MCP protocol headers
-	req.Header.Set(HeaderProtocolVersion, MCPProtocolVersion)
+	req.Header.Set(HeaderProtocolVersion, s.version)
 	req.Header.Set(HeaderSessionID, s.sessionID)
 	// Some servers require clients to accept both JSON and SSE content types
 	req.Header.Set("Accept", "application/json, text/event-stream, */*")
@@ -187,6 +218,7 @@ func (s *SSETransport) SendMessage(msg []byte) error {
 	}
 	sessionID := s.sessionID
 	headers := s.headers
+	version := s.version
 	s.mu.Unlock()
 
 	// Per MCP spec: POST request to send JSON-RPC messages
@@ -197,7 +229,7 @@ func (s *SSETransport) SendMessage(msg []byte) error {
 	}
 
 	// Set MCP protocol headers per spec
-	req.Header.Set(HeaderProtocolVersion, MCPProtocolVersion)
+	req.Header.Set(HeaderProtocolVersion, version)
 	if sessionID != "" {
 		req.Header.Set(HeaderSessionID, sessionID)
 	}
@@ -219,9 +251,9 @@ func (s *SSETransport) SendMessage(msg []byte) error {
 	if resp.StatusCode >= 400 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		if resp.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("HTTP error %d: session not found, must re-initialize: %s", resp.StatusCode, string(bodyBytes))
+			return &HTTPStatusError{StatusCode: resp.StatusCode, Message: "session not found, must re-initialize: " + string(bodyBytes)}
 		}
-		return fmt.Errorf("HTTP error %d: %s", resp.StatusCode, string(bodyBytes))
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Message: string(bodyBytes)}
 	}
 
 	// Store response body for ReceiveMessage() - always set responseReady when we have a response
@@ -303,18 +335,18 @@ func (s *SSETransport) HasDuplicates() bool {
 }
 
 type StdioTransport struct {
-	reader  io.Reader
-	writer  io.Writer
-	scanner *bufio.Scanner
-	mu      sync.Mutex
-	closed  bool
+	reader   io.Reader
+	writer   io.Writer
+	messages *MessageReader
+	mu       sync.Mutex
+	closed   bool
 }
 
 func NewStdioTransport(reader io.Reader, writer io.Writer) *StdioTransport {
 	return &StdioTransport{
-		reader:  reader,
-		writer:  writer,
-		scanner: bufio.NewScanner(reader),
+		reader:   reader,
+		writer:   writer,
+		messages: NewMessageReader(reader, DefaultMaxMessageSize),
 	}
 }
 
@@ -331,15 +363,7 @@ func (s *StdioTransport) SendMessage(msg []byte) error {
 }
 
 func (s *StdioTransport) ReceiveMessage() ([]byte, error) {
-	if s.scanner.Scan() {
-		return s.scanner.Bytes(), nil
-	}
-
-	if err := s.scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return nil, io.EOF
+	return s.messages.ReadMessage()
 }
 
 func (s *StdioTransport) Close() error {
@@ -354,11 +378,23 @@ func (s *StdioTransport) SupportsServerToClient() bool {
 	return false
 }
 
+// HTTPStatusError is returned when an http server answers a message with an
+// error status.
+type HTTPStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP error %d: %s", e.StatusCode, e.Message)
+}
+
 type HTTPTransport struct {
 	url             string
 	sessionID       string // Set by server in initialize response header
 	client          *http.Client
 	headers         map[string]string
+	version         string // Negotiated protocol version, sent as a header
 	mu              sync.Mutex
 	closed          bool
 	lastResponse    []byte
@@ -376,9 +412,11 @@ func NewHTTPTransport(url string) *HTTPTransport {
 		url:       url,
 		sessionID: "", // Will be set from server's initialize response header
 		client: &http.Client{
-			Timeout: sseHTTPClientTimeout,
+			Transport: BackendHTTPTransport,
+			Timeout:   sseHTTPClientTimeout,
 		},
 		headers: make(map[string]string),
+		version: MCPProtocolVersion,
 	}
 }
 
@@ -394,6 +432,12 @@ func (h *HTTPTransport) SetSessionID(sessionID string) {
 	h.sessionID = sessionID
 }
 
+func (h *HTTPTransport) SetProtocolVersion(version string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.version = version
+}
+
 func (h *HTTPTransport) SendMessage(msg []byte) error {
 	h.mu.Lock()
 	if h.closed {
@@ -402,6 +446,7 @@ func (h *HTTPTransport) SendMessage(msg []byte) error {
 	}
 	headers := h.headers
 	sessionID := h.sessionID
+	version := h.version
 	h.mu.Unlock()
 
 	req, err := http.NewRequest("POST", h.url, strings.NewReader(string(msg)))
@@ -410,7 +455,7 @@ func (h *HTTPTransport) SendMessage(msg []byte) error {
 	}
 
 	// Set MCP protocol headers per spec
-	req.Header.Set(HeaderProtocolVersion, MCPProtocolVersion)
+	req.Header.Set(HeaderProtocolVersion, version)
 	if sessionID != "" {
 		req.Header.Set(HeaderSessionID, sessionID)
 	}
@@ -431,9 +476,9 @@ func (h *HTTPTransport) SendMessage(msg []byte) error {
 	if resp.StatusCode >= 400 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		if resp.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("HTTP error %d: session not found, must re-initialize: %s", resp.StatusCode, string(bodyBytes))
+			return &HTTPStatusError{StatusCode: resp.StatusCode, Message: "session not found, must re-initialize: " + string(bodyBytes)}
 		}
-		return fmt.Errorf("HTTP error %d: %s", resp.StatusCode, string(bodyBytes))
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Message: string(bodyBytes)}
 	}
 
 	// Read response body - always store it for ReceiveMessage() to return
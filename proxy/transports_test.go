@@ -145,6 +145,13 @@ func TestHTTPProtocolVersionHeader(t *testing.T) {
 	if receivedHeader != MCPProtocolVersion {
 		t.Errorf("expected protocol version header %s, got %s", MCPProtocolVersion, receivedHeader)
 	}
+
+	// After initialize the header carries the backend's negotiated version
+	transport.SetProtocolVersion("2024-11-05")
+	transport.SendMessage([]byte(`{"jsonrpc":"2.0"}`))
+	if receivedHeader != "2024-11-05" {
+		t.Errorf("expected negotiated protocol version header, got %s", receivedHeader)
+	}
 }
 
 func TestSSESupportsServerToClient(t *testing.T) {
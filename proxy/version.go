@@ -0,0 +1,20 @@
+package proxy
+
+import "time"
+
+// Version is the proxy build version. Release builds override it with
+// -ldflags "-X github.com/user/mcp-go-proxy/proxy.Version=v1.2.3".
+var Version = "1.0.16"
+
+// startTime records when the process started, for uptime reporting.
+var startTime = time.Now()
+
+// StartTime returns when the process started.
+func StartTime() time.Time {
+	return startTime
+}
+
+// Uptime returns how long the process has been running.
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Protocol versions that introduced features a client on an older version
+// would not understand. Versions are dates, so they compare as strings.
+const (
+	// Audio content
+	protocolVersionAudio = "2025-03-26"
+	// structuredContent, outputSchema and resource_link content
+	protocolVersionStructured = "2025-06-18"
+)
+
+// SupportsAudio reports whether a client on version understands audio content.
+func SupportsAudio(version string) bool {
+	return version >= protocolVersionAudio
+}
+
+// SupportsStructuredOutput reports whether a client on version understands
+// outputSchema, structuredContent and resource_link content.
+func SupportsStructuredOutput(version string) bool {
+	return version >= protocolVersionStructured
+}
+
+// VersionAdapter translates messages between a client and a backend that
+// negotiated different protocol versions with the proxy. Results from a
+// newer backend are rewritten into forms the older client understands; an
+// older backend sends nothing a newer client lacks, so its results pass
+// through unchanged.
+type VersionAdapter struct {
+	ClientVersion  string
+	BackendVersion string
+}
+
+// NewVersionAdapter returns the adapter for one client/backend pair. An
+// empty backend version (a backend that didn't say) is treated as the
+// oldest supported one.
+func NewVersionAdapter(clientVersion, backendVersion string) VersionAdapter {
+	if backendVersion == "" {
+		backendVersion = SupportedProtocolVersions[len(SupportedProtocolVersions)-1]
+	}
+	return VersionAdapter{ClientVersion: clientVersion, BackendVersion: backendVersion}
+}
+
+// Passthrough reports whether messages need no translation.
+func (a VersionAdapter) Passthrough() bool {
+	return a.ClientVersion >= a.BackendVersion
+}
+
+// AdaptToolResult rewrites a tools/call result for the client. An older
+// client loses structuredContent, so it gets the same data as JSON text
+// unless the result already carries text.
+func (a VersionAdapter) AdaptToolResult(result map[string]interface{}) map[string]interface{} {
+	if a.Passthrough() {
+		return result
+	}
+	adapted := copyMap(result)
+	content, _ := result["content"].([]interface{})
+	adapted["content"] = a.adaptContentList(content)
+
+	if structured, ok := result["structuredContent"]; ok && !SupportsStructuredOutput(a.ClientVersion) {
+		delete(adapted, "structuredContent")
+		if !hasTextContent(content) {
+			data, _ := json.Marshal(structured)
+			adapted["content"] = append(adapted["content"].([]interface{}), map[string]interface{}{"type": "text", "text": string(data)})
+		}
+	}
+	return adapted
+}
+
+// AdaptPromptResult rewrites a prompts/get result for the client.
+func (a VersionAdapter) AdaptPromptResult(result map[string]interface{}) map[string]interface{} {
+	if a.Passthrough() {
+		return result
+	}
+	messages, ok := result["messages"].([]interface{})
+	if !ok {
+		return result
+	}
+	adapted := copyMap(result)
+	adaptedMessages := make([]interface{}, len(messages))
+	for i, m := range messages {
+		msg, ok := m.(map[string]interface{})
+		if !ok {
+			adaptedMessages[i] = m
+			continue
+		}
+		if content, ok := msg["content"].(map[string]interface{}); ok {
+			msg = copyMap(msg)
+			msg["content"] = a.AdaptContent(content)
+		}
+		adaptedMessages[i] = msg
+	}
+	adapted["messages"] = adaptedMessages
+	return adapted
+}
+
+// AdaptContent rewrites one content item the client can't display as text
+// describing it, so the model still learns it was there.
+func (a VersionAdapter) AdaptContent(item map[string]interface{}) map[string]interface{} {
+	if a.Passthrough() {
+		return item
+	}
+	switch item["type"] {
+	case "resource_link":
+		if SupportsStructuredOutput(a.ClientVersion) {
+			return item
+		}
+		uri, _ := item["uri"].(string)
+		text := uri
+		if name, _ := item["name"].(string); name != "" {
+			text = fmt.Sprintf("%s: %s", name, uri)
+		}
+		return map[string]interface{}{"type": "text", "text": text}
+	case "audio":
+		if SupportsAudio(a.ClientVersion) {
+			return item
+		}
+		mimeType, _ := item["mimeType"].(string)
+		return map[string]interface{}{"type": "text", "text": fmt.Sprintf("[%s audio omitted: client does not support audio content]", mimeType)}
+	}
+	return item
+}
+
+func (a VersionAdapter) adaptContentList(content []interface{}) []interface{} {
+	adapted := make([]interface{}, len(content))
+	for i, c := range content {
+		if item, ok := c.(map[string]interface{}); ok {
+			adapted[i] = a.AdaptContent(item)
+		} else {
+			adapted[i] = c
+		}
+	}
+	return adapted
+}
+
+func hasTextContent(content []interface{}) bool {
+	for _, c := range content {
+		if item, ok := c.(map[string]interface{}); ok && item["type"] == "text" {
+			return true
+		}
+	}
+	return false
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestVersionAdapterPairs(t *testing.T) {
+	// A result using every content feature any supported version added
+	newResult := func() map[string]interface{} {
+		return map[string]interface{}{
+			"content": []interface{}{
+				map[string]interface{}{"type": "audio", "data": "AAAA", "mimeType": "audio/wav"},
+				map[string]interface{}{"type": "resource_link", "uri": "file:///report.md", "name": "report"},
+				map[string]interface{}{"type": "image", "data": "AAAA", "mimeType": "image/png"},
+			},
+			"structuredContent": map[string]interface{}{"temperature": 21.5},
+			"isError":           false,
+		}
+	}
+
+	for _, client := range SupportedProtocolVersions {
+		for _, backend := range SupportedProtocolVersions {
+			t.Run(client+"/"+backend, func(t *testing.T) {
+				a := NewVersionAdapter(client, backend)
+				result := a.AdaptToolResult(newResult())
+				data, _ := json.Marshal(result)
+
+				if client >= backend {
+					if !a.Passthrough() {
+						t.Fatalf("expected passthrough")
+					}
+					if _, ok := result["structuredContent"]; !ok {
+						t.Errorf("expected result unchanged, got %s", data)
+					}
+					return
+				}
+
+				content := result["content"].([]interface{})
+				types := make([]string, len(content))
+				for i, c := range content {
+					types[i] = c.(map[string]interface{})["type"].(string)
+				}
+				joined := strings.Join(types, ",")
+
+				_, structured := result["structuredContent"]
+				if structured != SupportsStructuredOutput(client) {
+					t.Errorf("structuredContent present=%v for client %s", structured, client)
+				}
+				if strings.Contains(joined, "resource_link") != SupportsStructuredOutput(client) {
+					t.Errorf("unexpected resource_link handling for client %s: %s", client, joined)
+				}
+				if strings.Contains(joined, "audio") != SupportsAudio(client) {
+					t.Errorf("unexpected audio handling for client %s: %s", client, joined)
+				}
+				if !strings.Contains(joined, "image") {
+					t.Errorf("expected image content to pass through, got %s", joined)
+				}
+				if !SupportsStructuredOutput(client) && !strings.Contains(string(data), "report: file:///report.md") {
+					t.Errorf("expected resource link as text, got %s", data)
+				}
+			})
+		}
+	}
+}
+
+func TestVersionAdapterStructuredFallback(t *testing.T) {
+	a := NewVersionAdapter("2024-11-05", MCPProtocolVersion)
+
+	result := a.AdaptToolResult(map[string]interface{}{
+		"content":           []interface{}{},
+		"structuredContent": map[string]interface{}{"ok": true},
+	})
+	content := result["content"].([]interface{})
+	if len(content) != 1 || content[0].(map[string]interface{})["text"] != `{"ok":true}` {
+		t.Errorf("expected structuredContent as JSON text, got %v", content)
+	}
+
+	// Existing text is the fallback already; don't duplicate it
+	result = a.AdaptToolResult(map[string]interface{}{
+		"content":           []interface{}{map[string]interface{}{"type": "text", "text": "ok"}},
+		"structuredContent": map[string]interface{}{"ok": true},
+	})
+	if content := result["content"].([]interface{}); len(content) != 1 {
+		t.Errorf("expected existing text only, got %v", content)
+	}
+}
+
+func TestVersionAdapterPromptResult(t *testing.T) {
+	a := NewVersionAdapter("2024-11-05", "2025-03-26")
+	original := map[string]interface{}{
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": map[string]interface{}{"type": "audio", "data": "AAAA", "mimeType": "audio/mpeg"}},
+			map[string]interface{}{"role": "user", "content": map[string]interface{}{"type": "text", "text": "hi"}},
+		},
+	}
+
+	result := a.AdaptPromptResult(original)
+	messages := result["messages"].([]interface{})
+	first := messages[0].(map[string]interface{})["content"].(map[string]interface{})
+	if first["type"] != "text" || !strings.Contains(first["text"].(string), "audio/mpeg") {
+		t.Errorf("expected audio described as text, got %v", first)
+	}
+	if original["messages"].([]interface{})[0].(map[string]interface{})["content"].(map[string]interface{})["type"] != "audio" {
+		t.Error("expected the original result to be left untouched")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/proxy"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+// handleReplayCommand re-drives a session recorded with -record through the
+// proxy's current policy and reports responses that changed.
+func handleReplayCommand(args []string, globals cmd.CLIArgs) {
+	fs := cmd.NewFlagSet("replay", "[FLAGS] SESSION.jsonl", "Replay a recorded session and report responses that differ")
+	var mock bool
+	var timeout time.Duration
+	fs.BoolVar(&mock, "mock", "", false, "Answer backend requests from the recording instead of starting backends")
+	fs.DurationVar(&timeout, "timeout", "", 10*time.Second, "How long to wait for backends to initialize")
+	addJSONFlag(fs)
+	fs.MustParse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: mcp-proxy replay [FLAGS] SESSION.jsonl")
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	messages, err := proxy.LoadRecording(path)
+	if err != nil {
+		exitWithError("replay", err)
+	}
+
+	var registry *proxy.ServerRegistry
+	if mock {
+		registry = server.MockRegistry(messages)
+	} else if registry, err = proxy.LoadServerRegistry(globals.ConfigPath); err != nil {
+		exitWithError("replay", fmt.Errorf("failed to load server config: %w", err))
+	}
+
+	// Replay against the policy the proxy would run with today
+	statsTracker := server.NewStatsTracker()
+	policyManager := server.NewPolicyManager(statsTracker)
+	stored := applyStoredPolicy(registry, policyManager)
+
+	config := convertCLIArgsToServerConfig(globals)
+	config.DBPath = ""
+	srv, err := server.NewStdioServer(config, registry, statsTracker, policyManager, os.Getenv("ANTHROPIC_API_KEY"), nil)
+	if err != nil {
+		exitWithError("replay", err)
+	}
+	defer srv.Close()
+	srv.SetTrustPolicy(stored.Trust)
+	srv.SetCostPolicy(stored.Costs)
+	srv.SetEgressPolicy(stored.Egress)
+	srv.SetDLPPolicy(stored.DLP)
+	srv.SetDecoyPolicy(stored.Decoys)
+	srv.SetToolClasses(stored.Classes)
+	srv.SetCedarPolicy(stored.Cedar)
+	srv.SetAuthorizerPolicy(stored.Authorizer)
+	srv.SetAuditPolicy(stored.Audit)
+	srv.SetRewritePolicy(stored.Rewrites)
+	srv.SetGuardPrompts(stored.GuardPrompts)
+	srv.SetPreviewPolicy(stored.Previews)
+	srv.SetUndoPolicy(stored.Undo)
+
+	report, err := srv.Replay(context.Background(), messages, server.ReplayOptions{Mock: mock, InitTimeout: timeout})
+	if err != nil {
+		exitWithError("replay", err)
+	}
+
+	if jsonOutput {
+		emitJSON("replay", report)
+	} else {
+		for _, d := range report.Diffs {
+			fmt.Printf("~ seq %d %s (id %v)\n", d.Seq, d.Method, d.ID)
+			fmt.Printf("  recorded: %s\n", orNone(string(d.Recorded)))
+			fmt.Printf("  replayed: %s\n", d.Replayed)
+		}
+		fmt.Printf("%d/%d responses match the recording\n", report.Matched, report.Requests)
+	}
+	if len(report.Diffs) > 0 {
+		srv.Close()
+		os.Exit(2)
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/server"
+)
+
+const rulesUsage = `usage: mcp-proxy rules <command> [flags]
+
+COMMANDS:
+  list                      List all rules
+  add -name NAME [flags]    Add a rule
+  rm ID                     Delete a rule
+  enable ID                 Enable a rule
+  disable ID                Disable a rule
+  test -tool NAME -content TEXT
+                            Show which rule (if any) would decide a call
+  sync [-direction DIR]     Sync block-all rules with the deny and ask lists of
+                            Claude's settings.json (off, to_claude, from_claude
+                            or both; stored for the rules server)
+
+Every command accepts -db PATH (default: ~/.armour/rules.db, env ARMOUR_RULES_DB)
+and -help for its flags.
+`
+
+func handleRulesCommand(args []string) {
+	if len(args) < 1 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, rulesUsage)
+		os.Exit(2)
+	}
+
+	sub, subArgs := args[0], args[1:]
+	fs := cmd.NewFlagSet("rules "+sub, "[FLAGS]", "")
+	var dbPath string
+	fs.StringVar(&dbPath, "db", "ARMOUR_RULES_DB", server.DefaultRulesDBPath(), "Rules database path")
+	addJSONFlag(fs)
+
+	var err error
+	switch sub {
+	case "list", "ls":
+		fs.MustParse(subArgs)
+		err = withRulesStore(dbPath, rulesList)
+	case "add":
+		rule := server.Rule{}
+		fs.StringVar(&rule.Name, "name", "", "", "Rule name (required)")
+		fs.StringVar(&rule.Pattern, "pattern", "", "", "Literal or regex pattern to match against call content")
+		fs.StringVar(&rule.Topics, "topics", "", "", "Comma-separated topics for semantic matching")
+		fs.StringVar(&rule.Tools, "tools", "", "*", "Comma-separated tool names (supports prefix*/*suffix wildcards)")
+		fs.StringVar(&rule.Scope, "scope", "", "all", "Scope: native, mcp, or all")
+		fs.StringVar(&rule.Action, "action", "", "block", "Action: block, allow or delegate")
+		fs.BoolVar(&rule.IsRegex, "regex", "", false, "Treat pattern as a regular expression")
+		fs.BoolVar(&rule.IsSemantic, "semantic", "", false, "Match topics semantically via the Claude API")
+		fs.BoolVar(&rule.BlockAll, "block-all", "", false, "Match every call to the listed tools")
+		fs.MustParse(subArgs)
+		err = withRulesStore(dbPath, func(store *server.RulesStore) error {
+			return rulesAdd(store, &rule)
+		})
+	case "rm", "delete":
+		fs.MustParse(subArgs)
+		err = withRuleID(fs, dbPath, func(store *server.RulesStore, id int) error {
+			if err := store.Delete(id); err != nil {
+				return err
+			}
+			if jsonOutput {
+				emitJSON("rules rm", map[string]int{"id": id})
+				return nil
+			}
+			fmt.Printf("✓ Deleted rule %d\n", id)
+			return nil
+		})
+	case "enable", "disable":
+		fs.MustParse(subArgs)
+		enabled := sub == "enable"
+		err = withRuleID(fs, dbPath, func(store *server.RulesStore, id int) error {
+			if err := store.SetEnabled(id, enabled); err != nil {
+				return err
+			}
+			if jsonOutput {
+				emitJSON("rules "+sub, map[string]interface{}{"id": id, "enabled": enabled})
+				return nil
+			}
+			fmt.Printf("✓ Rule %d %sd\n", id, sub)
+			return nil
+		})
+	case "test":
+		req := server.CheckRequest{}
+		fs.StringVar(&req.Tool, "tool", "", "", "Tool name to test")
+		fs.StringVar(&req.Method, "method", "", "tools/call", "MCP method")
+		fs.StringVar(&req.Content, "content", "", "", "Call content (arguments) to test")
+		fs.StringVar(&req.Scope, "scope", "", "all", "Scope: native, mcp, or all")
+		fs.MustParse(subArgs)
+		err = rulesTest(dbPath, req)
+	case "sync":
+		var direction, settingsPath string
+		fs.StringVar(&direction, "direction", "", "", "Sync direction to store and use (default: the stored one, initially off)")
+		fs.StringVar(&settingsPath, "settings", "", server.ClaudeSettingsPath(), "Claude settings.json to sync with")
+		fs.MustParse(subArgs)
+		err = withRulesStore(dbPath, func(store *server.RulesStore) error {
+			return rulesSync(store, settingsPath, direction)
+		})
+	default:
+		fmt.Fprint(os.Stderr, rulesUsage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		exitWithError("rules "+sub, err)
+	}
+}
+
+func withRulesStore(dbPath string, fn func(*server.RulesStore) error) error {
+	store, err := server.OpenRulesStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return fn(store)
+}
+
+func withRuleID(fs *cmd.FlagSet, dbPath string, fn func(*server.RulesStore, int) error) error {
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected a single rule ID")
+	}
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid rule ID %q", fs.Arg(0))
+	}
+	return withRulesStore(dbPath, func(store *server.RulesStore) error {
+		return fn(store, id)
+	})
+}
+
+func rulesList(store *server.RulesStore) error {
+	rules, err := store.List()
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		if rules == nil {
+			rules = []server.Rule{}
+		}
+		emitJSON("rules list", map[string]interface{}{"rules": rules})
+		return nil
+	}
+	if len(rules) == 0 {
+		fmt.Println("No rules defined.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tACTION\tTOOLS\tSCOPE\tMATCH\tENABLED")
+	for _, r := range rules {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%t\n", r.ID, r.Name, r.Action, r.Tools, r.Scope, describeRuleMatch(r), r.Enabled)
+	}
+	return tw.Flush()
+}
+
+func rulesAdd(store *server.RulesStore, rule *server.Rule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	if rule.Action != "block" && rule.Action != "allow" && rule.Action != server.DelegateAction {
+		return fmt.Errorf("-action must be block, allow or delegate")
+	}
+	if rule.Pattern == "" && rule.Topics == "" && !rule.BlockAll {
+		return fmt.Errorf("one of -pattern, -topics or -block-all is required")
+	}
+	if rule.IsSemantic && rule.Topics == "" {
+		rule.Topics = rule.Pattern
+	}
+
+	if err := store.Create(rule); err != nil {
+		return err
+	}
+	if jsonOutput {
+		emitJSON("rules add", rule)
+		return nil
+	}
+	fmt.Printf("✓ Added rule %d (%s)\n", rule.ID, rule.Name)
+	return nil
+}
+
+func rulesTest(dbPath string, req server.CheckRequest) error {
+	rs, err := server.NewRulesServer(server.RulesServerConfig{
+		DBPath: dbPath,
+		APIKey: os.Getenv("ANTHROPIC_API_KEY"),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	resp := rs.Evaluate(ctx, req)
+	if jsonOutput {
+		emitJSON("rules test", resp)
+		return nil
+	}
+	fmt.Printf("Decision: %s\n", resp.Decision)
+	if resp.Reason != "" {
+		fmt.Printf("Reason:   %s\n", resp.Reason)
+	}
+	if resp.RuleID != 0 {
+		fmt.Printf("Rule ID:  %d\n", resp.RuleID)
+	}
+	return nil
+}
+
+func rulesSync(store *server.RulesStore, settingsPath, direction string) error {
+	if direction != "" {
+		if err := store.SetPermissionSyncDirection(direction); err != nil {
+			return err
+		}
+	}
+	report, err := store.SyncPermissions(settingsPath, "")
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		emitJSON("rules sync", report)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENTRY\tARMOUR\tSETTINGS.JSON\tSTATUS\tREASON")
+	for _, item := range report.Items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", item.Entry, orNone(item.Armour), orNone(item.Claude), item.Status, item.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if report.Direction == server.SyncOff {
+		fmt.Println("\nSync is off; run with -direction both (or to_claude, from_claude) to sync.")
+		return nil
+	}
+	fmt.Printf("\n✓ Synced %s: %d settings.json entries and %d rules changed\n", report.SettingsPath, report.ClaudeChanges, report.ArmourChanges)
+	return nil
+}
+
+func describeRuleMatch(r server.Rule) string {
+	switch {
+	case r.BlockAll:
+		return "all calls"
+	case r.IsSemantic:
+		return "topics: " + r.Topics
+	case r.IsRegex:
+		return "regex: " + r.Pattern
+	default:
+		return "contains: " + r.Pattern
+	}
+}
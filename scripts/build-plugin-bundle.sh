@@ -5,6 +5,7 @@ ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
 OUT_DIR="${ROOT_DIR}/dist"
 GOOS="${GOOS:-$(go env GOOS)}"
 GOARCH="${GOARCH:-$(go env GOARCH)}"
+VERSION="${VERSION:-$(jq -r '.version' "${ROOT_DIR}/.claude-plugin/plugin.json" 2>/dev/null || echo dev)}"
 ASSET_NAME="armour-plugin-${GOOS}-${GOARCH}.tar.gz"
 
 WORK_DIR="$(mktemp -d)"
@@ -14,7 +15,7 @@ mkdir -p "${PLUGIN_DIR}/.claude-plugin"
 mkdir -p "${OUT_DIR}"
 
 echo "Building armour binary for ${GOOS}/${GOARCH}..."
-(cd "${ROOT_DIR}" && GOOS="${GOOS}" GOARCH="${GOARCH}" go build -o "${PLUGIN_DIR}/armour" ./)
+(cd "${ROOT_DIR}" && GOOS="${GOOS}" GOARCH="${GOARCH}" go build -ldflags "-X github.com/user/mcp-go-proxy/proxy.Version=${VERSION}" -o "${PLUGIN_DIR}/armour" ./)
 
 cp "${ROOT_DIR}/.claude-plugin/plugin.json" "${PLUGIN_DIR}/.claude-plugin/plugin.json"
 cp "${ROOT_DIR}/.claude-plugin/marketplace.json" "${PLUGIN_DIR}/.claude-plugin/marketplace.json"
@@ -56,10 +56,13 @@ fi
 # The rules server returns {"allowed": true/false, "reason": "..."}
 ALLOWED=$(echo "$RESPONSE" | python3 -c "import sys, json; data=json.load(sys.stdin); print('true' if data.get('allowed', True) else 'false')" 2>/dev/null)
 REASON=$(echo "$RESPONSE" | python3 -c "import sys, json; data=json.load(sys.stdin); print(data.get('reason', ''))" 2>/dev/null)
+DECISION=$(echo "$RESPONSE" | python3 -c "import sys, json; data=json.load(sys.stdin); print(data.get('decision', ''))" 2>/dev/null)
 
 if [ "$ALLOWED" = "true" ]; then
     output_response "allow"
+elif [ "$DECISION" = "ask" ]; then
+    # Rules with the ask action let the user confirm the call
+    output_response "ask" "$REASON"
 else
-    # For blocked content, use "deny" to block or "ask" to prompt user
     output_response "deny" "$REASON"
 fi
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BlockExplanation is what proxy:explain-block tells the agent about the
+// last call the proxy refused in this session, so it can change its plan or
+// tell the user instead of retrying the same call.
+type BlockExplanation struct {
+	BlockError
+	BlockedAt  time.Time `json:"blocked_at"`
+	Suggestion string    `json:"suggestion"`
+}
+
+// agentHealth is the result of proxy:health.
+type agentHealth struct {
+	HealthReport
+	KillSwitch *KillSwitchState `json:"kill_switch,omitempty"` // only while engaged
+	Offline    *OfflineState    `json:"offline,omitempty"`     // only while on
+}
+
+// rememberBlock keeps block for proxy:explain-block.
+func (s *StdioServer) rememberBlock(block *BlockError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastBlock = &BlockExplanation{BlockError: *block, BlockedAt: time.Now(), Suggestion: blockSuggestion(block)}
+}
+
+// handleProxyExplainBlock explains the last blocked call of the session.
+func (s *StdioServer) handleProxyExplainBlock(id interface{}) interface{} {
+	s.mu.RLock()
+	explanation := s.lastBlock
+	s.mu.RUnlock()
+	if explanation == nil {
+		return s.makeResult(id, map[string]interface{}{
+			"content":           []interface{}{textContent("No call has been blocked in this session.")},
+			"structuredContent": map[string]interface{}{"blocked": false},
+		})
+	}
+	return s.makeResult(id, map[string]interface{}{
+		"content":           []interface{}{textContent(formatBlockExplanation(explanation))},
+		"structuredContent": explanation,
+	})
+}
+
+// handleProxyHealth reports the proxy's and the backends' health, so the
+// agent can tell a broken backend from a failing call.
+func (s *StdioServer) handleProxyHealth(ctx context.Context, id interface{}) interface{} {
+	rulesURL := ""
+	if s.blocklist != nil {
+		rulesURL = s.blocklist.RulesServerURL()
+	}
+	health := agentHealth{HealthReport: BuildHealthReport(ctx, s.db, s.backendManager, rulesURL)}
+	// The agent has no use for who and where the user is
+	health.Host = nil
+	if state := s.killSwitch.State(); state.Engaged {
+		health.KillSwitch = &state
+	}
+	if state := s.offline.State(); state.Enabled {
+		health.Offline = &state
+	}
+	return s.makeResult(id, map[string]interface{}{
+		"content":           []interface{}{textContent(formatAgentHealth(health))},
+		"structuredContent": health,
+	})
+}
+
+// blockSuggestion tells the agent what to do about a block instead of
+// calling the same tool again.
+func blockSuggestion(block *BlockError) string {
+	var suggestion string
+	switch block.BlockedBy {
+	case BlockedByRule:
+		suggestion = "A rule forbids this call; retrying it will be blocked again. Use another tool or approach, or ask the user to allow it."
+	case BlockedByTrust:
+		suggestion = "This server's trust tier does not allow the call. Ask the user to trust the server or use a tool of another server."
+	case BlockedByBudget:
+		suggestion = "A spend budget is used up. Wait for it to reset, use cheaper tools, or ask the user to raise the budget."
+	case BlockedByBatch:
+		suggestion = "Another request in the same batch was blocked. Send this request on its own."
+	case BlockedByKillSwitch:
+		suggestion = "The kill switch stops all tool calls. Stop and tell the user; only they can release it."
+	case BlockedByOffline:
+		suggestion = "Offline mode keeps tool calls off the network. Use local tools and files, or ask the user to turn offline mode off."
+	case BlockedBySandbox:
+		suggestion = "A path argument is outside the server's allowed roots. Use paths inside the project."
+	case BlockedByEgress:
+		suggestion = "A URL argument points at a host the egress policy forbids. Use another host or ask the user to allow it."
+	case BlockedByDLP:
+		suggestion = "The arguments contain sensitive data that may not be sent to a remote server. Remove it and call again."
+	case BlockedByDecoy:
+		suggestion = "This tool must never be called. Do not call it again and tell the user what led you to it."
+	case BlockedByPlugin, BlockedByCedar:
+		suggestion = "An organization policy denies this call. Tell the user the reason instead of retrying."
+	default:
+		suggestion = "Do not retry the same call. Tell the user why it was blocked."
+	}
+	if block.ApprovalURL != "" {
+		suggestion += " The user can approve it once at " + block.ApprovalURL + "."
+	}
+	return suggestion
+}
+
+func formatBlockExplanation(e *BlockExplanation) string {
+	var b strings.Builder
+	subject := e.Tool
+	if subject == "" {
+		subject = e.Operation
+	}
+	fmt.Fprintf(&b, "%s was blocked %s ago by %s", subject, time.Since(e.BlockedAt).Round(time.Second), e.BlockedBy)
+	if e.RuleID != 0 {
+		fmt.Fprintf(&b, " (rule %d", e.RuleID)
+		if e.Action != "" {
+			fmt.Fprintf(&b, ", action %s", e.Action)
+		}
+		b.WriteString(")")
+	}
+	fmt.Fprintf(&b, ".\nReason: %s\n", e.Reason)
+	if e.Pattern != "" {
+		fmt.Fprintf(&b, "Pattern: %s\n", e.Pattern)
+	}
+	fmt.Fprintf(&b, "Suggestion: %s", e.Suggestion)
+	return b.String()
+}
+
+func formatAgentHealth(h agentHealth) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Proxy %s.", h.Status)
+	if h.Backends != nil {
+		fmt.Fprintf(&b, " %d of %d backends ready.", h.Backends.Ready, h.Backends.Total)
+		for _, backend := range h.Backends.Backends {
+			if backend.Status != HealthOK {
+				fmt.Fprintf(&b, " %s is %s (%d missed pings).", backend.Name, backend.Status, backend.MissedPings)
+			}
+		}
+		if len(h.Backends.Quarantined) > 0 {
+			fmt.Fprintf(&b, " Stopped after crashing repeatedly: %s.", strings.Join(h.Backends.Quarantined, ", "))
+		}
+	}
+	if h.RulesStore.Status == HealthUnhealthy {
+		fmt.Fprintf(&b, " The rules server is unreachable: %s.", h.RulesStore.Error)
+	}
+	if h.KillSwitch != nil {
+		b.WriteString(" The kill switch is engaged; every tool call is blocked")
+		if h.KillSwitch.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", h.KillSwitch.Reason)
+		}
+		b.WriteString(".")
+	}
+	if h.Offline != nil {
+		b.WriteString(" Offline mode is on; calls to network servers or with URL arguments are blocked.")
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestAgentTools(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: echo
+    result:
+      content: [{type: text, text: hi}]
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "echo", Transport: "mock", Fixture: fixture},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	call := func(id int, name string) JSONRPCResponse {
+		return srv.handleRequest(ctx, JSONRPCRequest{ID: id, Method: "tools/call", Params: json.RawMessage(`{"name":"` + name + `","arguments":{}}`)}).(JSONRPCResponse)
+	}
+	text := func(resp JSONRPCResponse) string {
+		result, ok := resp.Result.(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected a tool result, got %+v", resp)
+		}
+		return result["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	}
+
+	if got := text(call(2, "proxy:explain-block")); !strings.Contains(got, "No call has been blocked") {
+		t.Errorf("expected nothing to explain yet, got %q", got)
+	}
+
+	if err := srv.GetKillSwitch().Set(KillSwitchState{Engaged: true, Reason: "incident"}); err != nil {
+		t.Fatalf("failed to engage: %v", err)
+	}
+	if resp := call(3, "echo:echo"); resp.Error == nil || resp.Error.Code != ErrCodeDenied {
+		t.Fatalf("expected kill switch denial, got %+v", resp)
+	}
+	resp := call(4, "proxy:explain-block")
+	explanation, ok := resp.Result.(map[string]interface{})["structuredContent"].(*BlockExplanation)
+	if !ok || explanation.Tool != "echo:echo" || explanation.BlockedBy != BlockedByKillSwitch || explanation.Suggestion == "" {
+		t.Errorf("expected the kill switch block explained, got %+v", resp.Result)
+	}
+	if got := text(resp); !strings.Contains(got, "echo:echo was blocked") || !strings.Contains(got, "Suggestion:") {
+		t.Errorf("unexpected explanation text %q", got)
+	}
+
+	resp = call(5, "proxy:health")
+	health, ok := resp.Result.(map[string]interface{})["structuredContent"].(agentHealth)
+	if !ok || health.Backends == nil || health.Backends.Total != 1 || health.KillSwitch == nil || health.Host != nil {
+		t.Errorf("expected backend health and the engaged kill switch, got %+v", resp.Result)
+	}
+	if got := text(resp); !strings.Contains(got, "1 of 1 backends ready") || !strings.Contains(got, "kill switch is engaged") {
+		t.Errorf("unexpected health text %q", got)
+	}
+}
+
+func TestBlockSuggestion(t *testing.T) {
+	block := ruleBlock(&BlocklistCheckResult{
+		DeniedOperation: "tools_call",
+		MatchedRule:     &BlocklistRule{ID: 7, Pattern: "delete_.*", Action: "block"},
+	}, "github:delete_repo", DashboardURL)
+	if block.Pattern != "delete_.*" {
+		t.Errorf("expected the rule's pattern in the block, got %+v", block)
+	}
+	block.ApprovalURL = DashboardURL + "/?approval=abc"
+	if got := blockSuggestion(block); !strings.Contains(got, "A rule forbids this call") || !strings.Contains(got, block.ApprovalURL) {
+		t.Errorf("expected a rule suggestion with the approval link, got %q", got)
+	}
+}
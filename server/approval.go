@@ -0,0 +1,287 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// approvalTTL is how long an approval link in a block error stays valid.
+const approvalTTL = 15 * time.Minute
+
+// Decisions an admin can take on a blocked call from the dashboard.
+const (
+	ApproveOnce      = "once"      // let the call through once more, with the same arguments
+	ApproveException = "exception" // let it through with the same arguments until the proxy restarts
+	ApproveDeny      = "deny"      // keep it blocked and drop it from the queue
+)
+
+// Approval is a blocked call the dashboard can approve through its link.
+type Approval struct {
+	Token   string      `json:"token"`
+	Block   *BlockError `json:"block"`
+	Created time.Time   `json:"created"`
+	Expires time.Time   `json:"expires"`
+
+	// Arguments of a blocked tools/call, with sensitive data redacted
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Risks     []ApprovalRisk         `json:"risks,omitempty"`
+
+	// What the call would change, for tools with a preview; added once known
+	Preview *CallPreview `json:"preview,omitempty"`
+
+	call string // hash of the unredacted arguments the grant is limited to
+}
+
+// ApprovalRisk is an argument the reviewer should look at before approving.
+// Field is a dotted path like the ones in FieldError.
+type ApprovalRisk struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ApprovalStore hands out one-time approval links for blocked calls and
+// remembers what the admin granted. Grants live in memory, like approvals
+// given through elicitation.
+type ApprovalStore struct {
+	mu         sync.Mutex
+	pending    map[string]*Approval
+	once       map[string]int
+	exceptions map[string]bool
+	watchers   map[chan struct{}]bool
+	now        func() time.Time
+}
+
+// NewApprovalStore creates an empty approval store.
+func NewApprovalStore() *ApprovalStore {
+	return &ApprovalStore{
+		pending:    make(map[string]*Approval),
+		once:       make(map[string]int),
+		exceptions: make(map[string]bool),
+		watchers:   make(map[chan struct{}]bool),
+		now:        time.Now,
+	}
+}
+
+// grantKey identifies what a grant lets through: the same kind of block of
+// the same operation on the same tool (and, for rules, by the same rule),
+// with the arguments whose hash is call.
+func grantKey(block *BlockError, call string) string {
+	return fmt.Sprintf("%s|%d|%s|%s|%s", block.BlockedBy, block.RuleID, block.Operation, block.Tool, call)
+}
+
+// callHash returns a hash of the canonical JSON of args, or "" for a call
+// without arguments. encoding/json sorts map keys, so equal arguments hash
+// the same whatever order they arrived in.
+func callHash(args map[string]interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Request registers block as awaiting a decision and returns its approval.
+func (as *ApprovalStore) Request(block *BlockError) *Approval {
+	return as.RequestCall(block, nil)
+}
+
+// RequestCall registers a blocked tools/call as awaiting a decision, with
+// its arguments for the reviewer to preview. A grant only covers calls with
+// the same arguments.
+func (as *ApprovalStore) RequestCall(block *BlockError, args map[string]interface{}) *Approval {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	now := as.now()
+	for token, a := range as.pending {
+		if now.After(a.Expires) {
+			delete(as.pending, token)
+		}
+	}
+
+	blockCopy := *block
+	approval := &Approval{
+		Token:   hex.EncodeToString(b),
+		Block:   &blockCopy,
+		Created: now,
+		Expires: now.Add(approvalTTL),
+		call:    callHash(args),
+	}
+	if len(args) > 0 {
+		findings, redacted := ScanDLP(redactAllDLP, args)
+		approval.Arguments = redacted
+		approval.Risks = approvalRisks(redacted, findings)
+	}
+	as.pending[approval.Token] = approval
+	as.notify()
+	return approval
+}
+
+// List returns the approvals awaiting a decision, oldest first.
+func (as *ApprovalStore) List() []*Approval {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	now := as.now()
+	approvals := make([]*Approval, 0, len(as.pending))
+	for _, a := range as.pending {
+		if !now.After(a.Expires) {
+			approvals = append(approvals, a)
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].Created.Before(approvals[j].Created)
+	})
+	return approvals
+}
+
+// Watch returns a channel that receives when an approval is requested or
+// decided, and a function to stop watching. Changes that happen while the
+// previous one wasn't received yet are coalesced.
+func (as *ApprovalStore) Watch() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	as.mu.Lock()
+	as.watchers[ch] = true
+	as.mu.Unlock()
+	return ch, func() {
+		as.mu.Lock()
+		delete(as.watchers, ch)
+		as.mu.Unlock()
+	}
+}
+
+// notify wakes the watchers. Callers hold mu.
+func (as *ApprovalStore) notify() {
+	for ch := range as.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Get returns the pending approval for token, if it exists and hasn't expired.
+func (as *ApprovalStore) Get(token string) (*Approval, bool) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	a, ok := as.pending[token]
+	if !ok || as.now().After(a.Expires) {
+		return nil, false
+	}
+	return a, true
+}
+
+// SetPreview attaches the predicted changes of a call to its approval, if
+// it is still pending. The approval is replaced, not changed, since List's
+// callers may be reading it.
+func (as *ApprovalStore) SetPreview(token string, preview *CallPreview) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	a, ok := as.pending[token]
+	if !ok {
+		return
+	}
+	updated := *a
+	updated.Preview = preview
+	as.pending[token] = &updated
+	as.notify()
+}
+
+// Decide applies an admin decision to the approval for token. Each link
+// works once.
+func (as *ApprovalStore) Decide(token, decision string) (*Approval, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	a, ok := as.pending[token]
+	if !ok || as.now().After(a.Expires) {
+		return nil, fmt.Errorf("approval not found or expired")
+	}
+
+	key := grantKey(a.Block, a.call)
+	switch decision {
+	case ApproveOnce:
+		as.once[key]++
+	case ApproveException:
+		as.exceptions[key] = true
+	case ApproveDeny:
+	default:
+		return nil, fmt.Errorf("unknown decision %q: must be %s, %s or %s", decision, ApproveOnce, ApproveException, ApproveDeny)
+	}
+	delete(as.pending, token)
+	as.notify()
+	return a, nil
+}
+
+// Granted reports whether an admin let block's call with args through,
+// using up a one-time grant if that is what allows it.
+func (as *ApprovalStore) Granted(block *BlockError, args map[string]interface{}) bool {
+	if as == nil {
+		return false
+	}
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	key := grantKey(block, callHash(args))
+	if as.exceptions[key] {
+		return true
+	}
+	if as.once[key] > 0 {
+		as.once[key]--
+		return true
+	}
+	return false
+}
+
+// approvalRisks points out the arguments that make a call risky: shell
+// scripts with dangerous patterns, SQL that writes or changes the schema,
+// and sensitive data (already redacted in args).
+func approvalRisks(args map[string]interface{}, findings []DLPFinding) []ApprovalRisk {
+	var risks []ApprovalRisk
+	for _, key := range shellArgKeys {
+		script, ok := args[key].(string)
+		if !ok || script == "" {
+			continue
+		}
+		if found := ShellFindings(ParseShell(script)); len(found) > 0 {
+			risks = append(risks, ApprovalRisk{Field: key, Reason: "shell: " + strings.Join(found, ", ")})
+		}
+		break
+	}
+	if key, text := sqlArgument(args); key != "" {
+		var classes []string
+		for _, st := range ParseSQL(text) {
+			class := st.Class
+			if st.Unbounded() {
+				class = "unbounded " + class
+			}
+			if st.Class != SQLSelect && st.Class != SQLOther && !slices.Contains(classes, class) {
+				classes = append(classes, class)
+			}
+		}
+		if len(classes) > 0 {
+			risks = append(risks, ApprovalRisk{Field: key, Reason: "sql: " + strings.Join(classes, ", ")})
+		}
+	}
+	for _, f := range findings {
+		risks = append(risks, ApprovalRisk{Field: f.Field, Reason: f.Class + " (redacted)"})
+	}
+	return risks
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestApprovalStoreDecisions tests one-time links, approve-once and exceptions
+func TestApprovalStoreDecisions(t *testing.T) {
+	store := NewApprovalStore()
+	block := &BlockError{BlockedBy: BlockedByRule, RuleID: 7, Operation: "tools_call", Tool: "github:run"}
+
+	if store.Granted(block, nil) {
+		t.Fatal("expected nothing granted before a decision")
+	}
+
+	approval := store.Request(block)
+	if got, ok := store.Get(approval.Token); !ok || got.Block.Tool != "github:run" {
+		t.Fatalf("expected pending approval, got %+v", got)
+	}
+	if _, err := store.Decide(approval.Token, "forever"); err == nil {
+		t.Error("expected unknown decision to be rejected")
+	}
+	if _, err := store.Decide(approval.Token, ApproveOnce); err != nil {
+		t.Fatalf("decide failed: %v", err)
+	}
+	if _, err := store.Decide(approval.Token, ApproveOnce); err == nil {
+		t.Error("expected the link to work only once")
+	}
+
+	other := *block
+	other.RuleID = 8
+	if store.Granted(&other, nil) {
+		t.Error("expected the grant to cover only the rule that blocked the call")
+	}
+	if !store.Granted(block, nil) || store.Granted(block, nil) {
+		t.Error("expected approve once to let exactly one call through")
+	}
+
+	approval = store.Request(block)
+	if _, err := store.Decide(approval.Token, ApproveException); err != nil {
+		t.Fatalf("decide failed: %v", err)
+	}
+	if !store.Granted(block, nil) || !store.Granted(block, nil) {
+		t.Error("expected an exception to keep letting the call through")
+	}
+
+	// Links expire
+	approval = store.Request(block)
+	store.now = func() time.Time { return time.Now().Add(approvalTTL + time.Minute) }
+	if _, ok := store.Get(approval.Token); ok {
+		t.Error("expected the approval to have expired")
+	}
+	if _, err := store.Decide(approval.Token, ApproveOnce); err == nil {
+		t.Error("expected an expired link to be rejected")
+	}
+}
+
+// TestApprovalGrantCoversSameArguments tests that a grant only lets through
+// the call with the arguments the reviewer saw
+func TestApprovalGrantCoversSameArguments(t *testing.T) {
+	store := NewApprovalStore()
+	block := &BlockError{BlockedBy: BlockedByRule, RuleID: 3, Operation: "tools_call", Tool: "db:query"}
+	args := map[string]interface{}{"sql": "SELECT 1", "limit": 5}
+
+	approval := store.RequestCall(block, args)
+	if _, err := store.Decide(approval.Token, ApproveException); err != nil {
+		t.Fatalf("decide failed: %v", err)
+	}
+	if store.Granted(block, map[string]interface{}{"sql": "DROP TABLE users", "limit": 5}) {
+		t.Error("expected the grant not to cover other arguments")
+	}
+	if store.Granted(block, nil) {
+		t.Error("expected the grant not to cover a call without arguments")
+	}
+	// Key order doesn't matter
+	if !store.Granted(block, map[string]interface{}{"limit": 5, "sql": "SELECT 1"}) {
+		t.Error("expected the grant to cover the same arguments")
+	}
+}
+
+// TestApprovalQueue tests the list of pending calls, with redacted
+// arguments and risks, and that watchers hear about changes
+func TestApprovalQueue(t *testing.T) {
+	store := NewApprovalStore()
+	changes, stop := store.Watch()
+	defer stop()
+
+	first := store.Request(&BlockError{BlockedBy: BlockedByTrust, Tool: "plug:echo"})
+	store.now = func() time.Time { return time.Now().Add(time.Second) }
+	second := store.RequestCall(&BlockError{BlockedBy: BlockedByRule, Action: "ask", Tool: "db:query"}, map[string]interface{}{
+		"sql":   "DELETE FROM users",
+		"note":  "card 4111 1111 1111 1111",
+		"limit": 5,
+	})
+	select {
+	case <-changes:
+	default:
+		t.Error("expected watchers notified of new approvals")
+	}
+
+	queue := store.List()
+	if len(queue) != 2 || queue[0].Token != first.Token || queue[1].Token != second.Token {
+		t.Fatalf("expected both approvals oldest first, got %+v", queue)
+	}
+	if note := second.Arguments["note"]; note != "card [REDACTED:credit_card]" {
+		t.Errorf("expected sensitive data redacted in the preview, got %v", note)
+	}
+	risks := map[string]string{}
+	for _, risk := range second.Risks {
+		risks[risk.Field] = risk.Reason
+	}
+	if risks["sql"] != "sql: unbounded delete" || risks["note"] == "" || len(risks) != 2 {
+		t.Errorf("expected the SQL and card number flagged, got %+v", second.Risks)
+	}
+
+	if _, err := store.Decide(second.Token, ApproveDeny); err != nil {
+		t.Fatalf("deny failed: %v", err)
+	}
+	<-changes
+	if store.Granted(second.Block, nil) || len(store.List()) != 1 {
+		t.Error("expected a denied call dropped from the queue without a grant")
+	}
+}
+
+// TestBlockedCallApprovedFromLink tests that a denied call carries a link
+// that, once approved, lets the call through a single time
+func TestBlockedCallApprovedFromLink(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: echo
+    result:
+      content: [{type: text, text: hi}]
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "plug", Transport: "mock", Fixture: fixture},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+	srv.SetTrustPolicy(TrustPolicy{Explicit: TrustAllow, Config: TrustAllow, Plugin: TrustDeny, Servers: map[string]TrustTier{"plug": TrustPlugin}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	call := func(id int) JSONRPCResponse {
+		return srv.handleRequest(ctx, JSONRPCRequest{ID: id, Method: "tools/call", Params: json.RawMessage(`{"name":"plug:echo","arguments":{}}`)}).(JSONRPCResponse)
+	}
+
+	resp := call(2)
+	if resp.Error == nil || resp.Error.Code != ErrCodeDenied {
+		t.Fatalf("expected trust denial, got %+v", resp)
+	}
+	block := resp.Error.Data.(*BlockError)
+	prefix := DashboardURL + "/?approval="
+	if !strings.HasPrefix(block.ApprovalURL, prefix) {
+		t.Fatalf("expected an approval link, got %q", block.ApprovalURL)
+	}
+	if _, err := srv.GetApprovals().Decide(strings.TrimPrefix(block.ApprovalURL, prefix), ApproveOnce); err != nil {
+		t.Fatalf("decide failed: %v", err)
+	}
+
+	if resp := call(3); resp.Error != nil {
+		t.Errorf("expected the approved call to go through, got %+v", resp.Error)
+	}
+	if resp := call(4); resp.Error == nil {
+		t.Error("expected the next call to be denied again")
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/url"
+	"strings"
+)
+
+// armourScheme prefixes the resource URIs the proxy shows clients:
+// armour://servername/original-uri.
+const armourScheme = "armour://"
+
+// armourURI namespaces a backend's resource URI (or URI template) under the
+// server that owns it.
+//
+// The original URI becomes the path of the armour:// URI. Characters that
+// would end that path or make it ambiguous are percent-encoded: "?" and "#",
+// which would split off a query or fragment of the armour:// URI itself, "%"
+// so encoded characters in the original survive the round trip, and spaces
+// and control characters. Slashes, colons and braces are kept, so
+// armour://fs/file:///{path} still reads like the original and blocklist
+// patterns written against it keep matching.
+func armourURI(serverName, uri string) string {
+	return armourScheme + serverName + "/" + escapeArmourPath(uri, false)
+}
+
+// armourTemplateURI is armourURI for a resource's uriTemplate. RFC 6570
+// expressions are kept as they are, operators included, so
+// https://example.com/search{?q,lang} still expands to a query string the
+// original server understands.
+func armourTemplateURI(serverName, template string) string {
+	return armourScheme + serverName + "/" + escapeArmourPath(template, true)
+}
+
+func escapeArmourPath(uri string, template bool) string {
+	var b strings.Builder
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		if template && c == '{' {
+			if end := strings.IndexByte(uri[i:], '}'); end >= 0 {
+				b.WriteString(uri[i : i+end+1])
+				i += end
+				continue
+			}
+		}
+		switch {
+		case c == '%' || c == '?' || c == '#' || c <= ' ' || c == 0x7f:
+			b.WriteByte('%')
+			b.WriteByte("0123456789ABCDEF"[c>>4])
+			b.WriteByte("0123456789ABCDEF"[c&15])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// parseArmourURI splits an armour://servername/original-uri into the server
+// and the original URI, undoing armourURI's escaping. Server names never
+// contain a slash, so the first one ends the name. URIs from before the
+// escaping, which can't be unescaped, are returned as they are.
+func parseArmourURI(uri string) (serverName, originalURI string) {
+	remainder, ok := strings.CutPrefix(uri, armourScheme)
+	if !ok {
+		return "", ""
+	}
+	serverName, path, found := strings.Cut(remainder, "/")
+	if !found {
+		return serverName, ""
+	}
+	if original, err := url.PathUnescape(path); err == nil {
+		return serverName, original
+	}
+	return serverName, path
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestArmourURIRoundTrip(t *testing.T) {
+	tests := []struct {
+		uri      string
+		want     string // the armour:// URI shown to clients
+		template bool   // a uriTemplate, whose expressions are kept as they are
+	}{
+		{"file:///home/user/notes.txt", "armour://fs/file:///home/user/notes.txt", false},
+		{"https://api.example.com/v1/items?page=2&sort=name#top", "armour://fs/https://api.example.com/v1/items%3Fpage=2&sort=name%23top", false},
+		{"git://github.com/org/repo.git", "armour://fs/git://github.com/org/repo.git", false},
+		{"file:///{path}", "armour://fs/file:///{path}", true},
+		{"https://example.com/search{?q,lang}", "armour://fs/https://example.com/search{?q,lang}", true},
+		{"https://example.com/{#section}?v=1", "armour://fs/https://example.com/{#section}%3Fv=1", true},
+		{"file:///my%20docs/a%2Fb.txt", "armour://fs/file:///my%2520docs/a%252Fb.txt", false},
+		{"file:///with space/tab\t", "armour://fs/file:///with%20space/tab%09", false},
+		{"postgres://db/users", "armour://fs/postgres://db/users", false},
+		{"notes", "armour://fs/notes", false},
+		{"", "armour://fs/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got := armourURI("fs", tt.uri)
+			if tt.template {
+				got = armourTemplateURI("fs", tt.uri)
+			}
+			if got != tt.want {
+				t.Errorf("armourURI(%q) = %q, want %q", tt.uri, got, tt.want)
+			}
+			server, original := parseArmourURI(got)
+			if server != "fs" || original != tt.uri {
+				t.Errorf("parseArmourURI(%q) = %q, %q; want fs, %q", got, server, original, tt.uri)
+			}
+			// Clients parsing the armour:// URI see it as a single path;
+			// a template only becomes a URI once expanded
+			if u, err := url.Parse(got); err == nil && !tt.template && (u.RawQuery != "" || u.Fragment != "" || u.Host != "fs") {
+				t.Errorf("%q parses with host %q, query %q and fragment %q", got, u.Host, u.RawQuery, u.Fragment)
+			}
+		})
+	}
+}
+
+func TestParseArmourURI(t *testing.T) {
+	tests := []struct {
+		uri, server, original string
+	}{
+		{"armour://fs", "fs", ""},
+		{"armour://fs/file:///bad%zz", "fs", "file:///bad%zz"}, // from before escaping
+		{"file:///etc/passwd", "", ""},
+		{"armour://", "", ""},
+	}
+	for _, tt := range tests {
+		server, original := parseArmourURI(tt.uri)
+		if server != tt.server || original != tt.original {
+			t.Errorf("parseArmourURI(%q) = %q, %q; want %q, %q", tt.uri, server, original, tt.server, tt.original)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+)
+
+// AuditEntry is a single row of the audit_log table as exposed to the CLI and dashboard.
+type AuditEntry struct {
+	ID              int64     `json:"id"`
+	Timestamp       time.Time `json:"timestamp"`
+	ServerID        string    `json:"server_id"`
+	Method          string    `json:"method"`
+	ToolName        string    `json:"tool_name"`
+	SessionID       string    `json:"session_id,omitempty"`
+	Transport       string    `json:"transport,omitempty"`
+	Blocked         bool      `json:"blocked"`
+	BlockReason     string    `json:"block_reason,omitempty"`
+	MatchedPattern  string    `json:"matched_pattern,omitempty"`
+	DeniedOperation string    `json:"denied_operation,omitempty"`
+	RuleAction      string    `json:"rule_action,omitempty"`
+	StatementClass  string    `json:"statement_class,omitempty"` // SQL statement classes of database tool calls
+	Tenant          string    `json:"tenant,omitempty"`          // HTTP-mode tenant the call was made for
+	Arguments       string    `json:"arguments,omitempty"`       // redacted JSON arguments, if the audit policy keeps them
+	RequestID       string    `json:"request_id,omitempty"`      // proxy-wide ID of the client call, shared with logs and traces
+	Quiet           bool      `json:"quiet,omitempty"`           // an allowed call of a quiet tool (see AuditPolicy.Quiet)
+	Repo            string    `json:"repo,omitempty"`            // git repository of a stdio session, e.g. github.com/acme/api
+	Branch          string    `json:"branch,omitempty"`          // its checked-out branch
+	Rewrites        string    `json:"rewrites,omitempty"`        // changes the rewrites section made to the arguments, separated by "; "
+	PrevHash        string    `json:"prev_hash,omitempty"`       // hash of the entry before this one
+	Hash            string    `json:"hash,omitempty"`            // chains the entry to PrevHash (see VerifyAuditLog)
+}
+
+// AuditFilter narrows down audit log queries.
+type AuditFilter struct {
+	Tool        string // substring match on tool name
+	Backend     string // exact match on server_id
+	BlockedOnly bool
+	Reason      string // exact match on block_reason, e.g. "decoy_called"
+	Session     string // exact match on session_id
+	Tenant      string // exact match on tenant
+	RequestID   string // exact match on request_id
+	Repo        string // substring match on repo
+	Query       string // substring match on tool name, server, block reason, matched pattern, request ID or repo
+	HideQuiet   bool   // leave out allowed calls of quiet tools
+	AfterID     int64  // only return entries with id > AfterID
+	Oldest      bool   // keep the first Limit entries rather than the last, to page through the log
+	Limit       int
+}
+
+// auditColumns are added to audit_log after creation because older schemas
+// (e.g. the stdio schema) do not carry them.
+var auditColumns = []string{
+	"tool_name TEXT",
+	"blocked INTEGER DEFAULT 0",
+	"block_reason TEXT",
+	"matched_pattern TEXT",
+	"denied_operation TEXT",
+	"rule_action TEXT",
+	"statement_class TEXT",
+	"tenant TEXT",
+	"arguments TEXT",
+	"request_id TEXT",
+	"quiet INTEGER DEFAULT 0",
+	"repo TEXT",
+	"branch TEXT",
+	"rewrites TEXT",
+	"prev_hash TEXT",
+	"entry_hash TEXT",
+}
+
+// ensureAuditSchema makes sure audit_log exists with all columns used by the CLI.
+func ensureAuditSchema(db *sql.DB) error {
+	if err := initDB(db); err != nil {
+		return fmt.Errorf("failed to ensure audit schema: %w", err)
+	}
+	for _, col := range auditColumns {
+		// Ignore "duplicate column" errors; the column already exists.
+		_, _ = db.Exec("ALTER TABLE audit_log ADD COLUMN " + col)
+	}
+	return nil
+}
+
+// RecordAuditEntry appends an entry to the audit log, chained to the entry
+// before it by hash.
+func RecordAuditEntry(db *sql.DB, entry AuditEntry) error {
+	if db == nil {
+		return nil
+	}
+	if err := ensureAuditSchema(db); err != nil {
+		return err
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	blocked, quiet := 0, 0
+	if entry.Blocked {
+		blocked = 1
+	}
+	if entry.Quiet {
+		quiet = 1
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	defer tx.Rollback()
+	if entry.PrevHash, err = lastAuditHash(tx); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	entry.Hash = auditHash(entry.PrevHash, entry)
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_log (
+			server_id, method, capability, tool_name, session_id, transport,
+			blocked, block_reason, matched_pattern, denied_operation, rule_action,
+			statement_class, tenant, arguments, request_id, quiet, repo, branch, rewrites,
+			timestamp, prev_hash, entry_hash
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ServerID, entry.Method, entry.Method, entry.ToolName, entry.SessionID, entry.Transport,
+		blocked, entry.BlockReason, entry.MatchedPattern, entry.DeniedOperation, entry.RuleAction,
+		entry.StatementClass, entry.Tenant, entry.Arguments, entry.RequestID, quiet, entry.Repo, entry.Branch, entry.Rewrites,
+		entry.Timestamp, entry.PrevHash, entry.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// QueryAuditLog returns audit entries matching the filter in ascending id order.
+// When no AfterID is given, the most recent Limit entries are returned.
+func QueryAuditLog(db *sql.DB, filter AuditFilter) ([]AuditEntry, error) {
+	if err := ensureAuditSchema(db); err != nil {
+		return nil, err
+	}
+
+	var where []string
+	var args []interface{}
+	if filter.AfterID > 0 {
+		where = append(where, "id > ?")
+		args = append(args, filter.AfterID)
+	}
+	if filter.Tool != "" {
+		where = append(where, "tool_name LIKE ?")
+		args = append(args, "%"+filter.Tool+"%")
+	}
+	if filter.Backend != "" {
+		where = append(where, "server_id = ?")
+		args = append(args, filter.Backend)
+	}
+	if filter.BlockedOnly {
+		where = append(where, "blocked = 1")
+	}
+	if filter.Reason != "" {
+		where = append(where, "block_reason = ?")
+		args = append(args, filter.Reason)
+	}
+	if filter.Session != "" {
+		where = append(where, "session_id = ?")
+		args = append(args, filter.Session)
+	}
+	if filter.Tenant != "" {
+		where = append(where, "tenant = ?")
+		args = append(args, filter.Tenant)
+	}
+	if filter.RequestID != "" {
+		where = append(where, "request_id = ?")
+		args = append(args, filter.RequestID)
+	}
+	if filter.Repo != "" {
+		where = append(where, "repo LIKE ?")
+		args = append(args, "%"+filter.Repo+"%")
+	}
+	if filter.HideQuiet {
+		where = append(where, "COALESCE(quiet, 0) = 0")
+	}
+	if filter.Query != "" {
+		like := "%" + filter.Query + "%"
+		where = append(where, "(tool_name LIKE ? OR server_id LIKE ? OR block_reason LIKE ? OR matched_pattern LIKE ? OR request_id LIKE ? OR repo LIKE ?)")
+		args = append(args, like, like, like, like, like, like)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, timestamp, COALESCE(server_id, ''), COALESCE(method, ''), COALESCE(tool_name, ''),
+		       COALESCE(session_id, ''), COALESCE(transport, ''), COALESCE(blocked, 0),
+		       COALESCE(block_reason, ''), COALESCE(matched_pattern, ''),
+		       COALESCE(denied_operation, ''), COALESCE(rule_action, ''), COALESCE(statement_class, ''),
+		       COALESCE(tenant, ''), COALESCE(arguments, ''), COALESCE(request_id, ''), COALESCE(quiet, 0),
+		       COALESCE(repo, ''), COALESCE(branch, ''), COALESCE(rewrites, ''),
+		       COALESCE(prev_hash, ''), COALESCE(entry_hash, '')
+		FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Newest first so LIMIT keeps the tail; reversed below.
+	order := "DESC"
+	if filter.Oldest {
+		order = "ASC"
+	}
+	query += " ORDER BY id " + order + " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var blocked, quiet int
+		if err := rows.Scan(
+			&e.ID, &e.Timestamp, &e.ServerID, &e.Method, &e.ToolName,
+			&e.SessionID, &e.Transport, &blocked,
+			&e.BlockReason, &e.MatchedPattern, &e.DeniedOperation, &e.RuleAction, &e.StatementClass,
+			&e.Tenant, &e.Arguments, &e.RequestID, &quiet, &e.Repo, &e.Branch, &e.Rewrites, &e.PrevHash, &e.Hash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.Blocked = blocked == 1
+		e.Quiet = quiet == 1
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+
+	if !filter.Oldest {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+	return entries, nil
+}
+
+// FetchAuditLog queries a running dashboard's /api/v1/audit endpoint,
+// sending token if the dashboard requires one.
+func FetchAuditLog(baseURL, token string, filter AuditFilter) ([]AuditEntry, error) {
+	params := url.Values{}
+	if filter.Tool != "" {
+		params.Set("tool", filter.Tool)
+	}
+	if filter.Backend != "" {
+		params.Set("backend", filter.Backend)
+	}
+	if filter.BlockedOnly {
+		params.Set("blocked", "true")
+	}
+	if filter.Reason != "" {
+		params.Set("reason", filter.Reason)
+	}
+	if filter.Session != "" {
+		params.Set("session", filter.Session)
+	}
+	if filter.Tenant != "" {
+		params.Set("tenant", filter.Tenant)
+	}
+	if filter.RequestID != "" {
+		params.Set("request_id", filter.RequestID)
+	}
+	if filter.Repo != "" {
+		params.Set("repo", filter.Repo)
+	}
+	if filter.HideQuiet {
+		params.Set("quiet", "false")
+	}
+	if filter.AfterID > 0 {
+		params.Set("after", strconv.FormatInt(filter.AfterID, 10))
+	}
+	if filter.Limit > 0 {
+		params.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	resp, err := cmd.DashboardGet(strings.TrimSuffix(baseURL, "/")+"/api/v1/audit?"+params.Encode(), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach dashboard: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dashboard returned status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Entries []AuditEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode audit response: %w", err)
+	}
+	return payload.Entries, nil
+}
+
+// ParseAuditFilter builds an AuditFilter from /api/v1/audit query parameters.
+func ParseAuditFilter(q url.Values) AuditFilter {
+	filter := AuditFilter{
+		Tool:        q.Get("tool"),
+		Backend:     q.Get("backend"),
+		BlockedOnly: q.Get("blocked") == "true" || q.Get("blocked") == "1",
+		Reason:      q.Get("reason"),
+		Session:     q.Get("session"),
+		Tenant:      q.Get("tenant"),
+		RequestID:   q.Get("request_id"),
+		Repo:        q.Get("repo"),
+		HideQuiet:   q.Get("quiet") == "false" || q.Get("quiet") == "0",
+		Query:       q.Get("q"),
+	}
+	if after, err := strconv.ParseInt(q.Get("after"), 10, 64); err == nil {
+		filter.AfterID = after
+	}
+	if limit, err := strconv.Atoi(q.Get("limit")); err == nil {
+		filter.Limit = limit
+	}
+	return filter
+}
+
+// FormatAuditEntry renders an entry as a single terminal line.
+func FormatAuditEntry(e AuditEntry) string {
+	status := "ALLOW"
+	if e.Blocked {
+		status = "BLOCK"
+	}
+
+	name := e.ToolName
+	if name == "" {
+		name = e.Method
+	}
+
+	line := fmt.Sprintf("%s  %-5s  %-20s %s", e.Timestamp.Local().Format("15:04:05"), status, e.ServerID, name)
+	if e.StatementClass != "" {
+		line += "  [sql:" + e.StatementClass + "]"
+	}
+	if e.Blocked {
+		reason := e.BlockReason
+		if e.MatchedPattern != "" {
+			reason += " (" + e.MatchedPattern + ")"
+		}
+		line += "  " + reason
+	}
+	if e.Rewrites != "" {
+		line += "  rewritten: " + e.Rewrites
+	}
+	if e.Repo != "" {
+		line += "  " + e.Repo
+		if e.Branch != "" {
+			line += "@" + e.Branch
+		}
+	}
+	if e.RequestID != "" {
+		line += "  " + e.RequestID
+	}
+	return line
+}
+
+// FormatAuditEntries renders entries as FormatAuditEntry does, except that
+// with collapseQuiet each run of quiet entries becomes one line counting the
+// calls per tool, e.g. "12:00:05  QUIET  12 calls: TodoWrite ×10, fs:list ×2".
+func FormatAuditEntries(entries []AuditEntry, collapseQuiet bool) []string {
+	var lines []string
+	var run []AuditEntry
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		counts := make(map[string]int)
+		var tools []string
+		for _, e := range run {
+			if counts[e.ToolName] == 0 {
+				tools = append(tools, e.ToolName)
+			}
+			counts[e.ToolName]++
+		}
+		parts := make([]string, len(tools))
+		for i, tool := range tools {
+			parts[i] = fmt.Sprintf("%s ×%d", tool, counts[tool])
+		}
+		last := run[len(run)-1]
+		lines = append(lines, fmt.Sprintf("%s  %-5s  %d calls: %s", last.Timestamp.Local().Format("15:04:05"), "QUIET", len(run), strings.Join(parts, ", ")))
+		run = run[:0]
+	}
+	for _, e := range entries {
+		if collapseQuiet && e.Quiet {
+			run = append(run, e)
+			continue
+		}
+		flush()
+		lines = append(lines, FormatAuditEntry(e))
+	}
+	flush()
+	return lines
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAuditAnchorInterval is how often the head of the audit log is
+// written to the anchor file.
+const DefaultAuditAnchorInterval = time.Hour
+
+// auditMu serializes appends within the process so two entries never chain
+// to the same predecessor.
+var auditMu sync.Mutex
+
+// auditHash chains an entry to its predecessor: the SHA-256 of the previous
+// entry's hash and the entry's fields. The ID is left out; the chain itself
+// fixes the order.
+func auditHash(prev string, e AuditEntry) string {
+	fields := []string{
+		prev,
+		e.Timestamp.UTC().Format(time.RFC3339Nano),
+		e.ServerID,
+		e.Method,
+		e.ToolName,
+		e.SessionID,
+		e.Transport,
+		strconv.FormatBool(e.Blocked),
+		e.BlockReason,
+		e.MatchedPattern,
+		e.DeniedOperation,
+		e.RuleAction,
+		e.StatementClass,
+		e.Tenant,
+	}
+	quiet := ""
+	if e.Quiet {
+		quiet = "quiet"
+	}
+	// Fields added later are hashed up to the last one present, so entries
+	// without them keep their hashes
+	optional := []string{e.Arguments, e.RequestID, quiet, e.Repo, e.Branch, e.Rewrites}
+	n := len(optional)
+	for n > 0 && optional[n-1] == "" {
+		n--
+	}
+	fields = append(fields, optional[:n]...)
+	sum := sha256.New()
+	for _, f := range fields {
+		// Length-prefixed so no two field lists hash alike
+		fmt.Fprintf(sum, "%d:%s", len(f), f)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// lastAuditHash returns the hash of the newest audit entry, or "" if the log
+// is empty or the entry predates hash chaining.
+func lastAuditHash(tx *sql.Tx) (string, error) {
+	var hash string
+	err := tx.QueryRow(`SELECT COALESCE(entry_hash, '') FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+// AuditAnchor records the head of the audit log at a point in time. Kept
+// outside the database (and ideally copied off the machine), anchors show
+// whether entries were later removed from the end of the log or rewritten.
+type AuditAnchor struct {
+	ID   int64     `json:"id"`
+	Hash string    `json:"hash"`
+	Time time.Time `json:"time"`
+}
+
+// AuditHead returns the newest chained audit entry as an anchor, or nil if
+// the log has none.
+func AuditHead(db *sql.DB) (*AuditAnchor, error) {
+	if err := ensureAuditSchema(db); err != nil {
+		return nil, err
+	}
+	var a AuditAnchor
+	err := db.QueryRow(`SELECT id, entry_hash FROM audit_log WHERE COALESCE(entry_hash, '') != '' ORDER BY id DESC LIMIT 1`).Scan(&a.ID, &a.Hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log head: %w", err)
+	}
+	a.Time = time.Now().UTC()
+	return &a, nil
+}
+
+// AppendAuditAnchor appends an anchor to the anchor file as a JSON line.
+func AppendAuditAnchor(path string, a AuditAnchor) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit anchor file: %w", err)
+	}
+	defer f.Close()
+	line, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit anchor: %w", err)
+	}
+	return f.Sync()
+}
+
+// ReadAuditAnchors reads the anchors in an anchor file, oldest first.
+func ReadAuditAnchors(path string) ([]AuditAnchor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit anchor file: %w", err)
+	}
+	defer f.Close()
+
+	var anchors []AuditAnchor
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var a AuditAnchor
+		if err := json.Unmarshal([]byte(line), &a); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid anchor: %w", path, n, err)
+		}
+		anchors = append(anchors, a)
+	}
+	return anchors, scanner.Err()
+}
+
+// RunAuditAnchors appends the head of the audit log to the anchor file every
+// interval until ctx is done, skipping the write when no entry was added.
+func RunAuditAnchors(ctx context.Context, db *sql.DB, path string, interval time.Duration, logger Logger) {
+	if interval <= 0 {
+		interval = DefaultAuditAnchorInterval
+	}
+	var last int64
+	anchor := func() {
+		head, err := AuditHead(db)
+		if err != nil {
+			logger.Warn("audit anchor: %v", err)
+			return
+		}
+		if head == nil || head.ID == last {
+			return
+		}
+		if err := AppendAuditAnchor(path, *head); err != nil {
+			logger.Warn("audit anchor: %v", err)
+			return
+		}
+		last = head.ID
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Anchor what the session wrote before exiting
+			anchor()
+			return
+		case <-ticker.C:
+			anchor()
+		}
+	}
+}
+
+// startAuditAnchors runs RunAuditAnchors for a proxy in the background. The
+// returned function stops it after a final anchor, before the database closes.
+func startAuditAnchors(ctx context.Context, db *sql.DB, config Config, logger Logger) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunAuditAnchors(ctx, db, config.AuditAnchorPath, config.AuditAnchorInterval, logger)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// AuditVerification is the outcome of checking the audit log's hash chain.
+type AuditVerification struct {
+	Entries   int   // chained entries checked
+	Unchained int   // entries written before hash chaining, which can't be checked
+	Anchors   int   // anchors that matched
+	Purged    int64 // the newest entry removed by a purge; the chain resumes after it
+	Head      *AuditAnchor
+	Problems  []string // each break in the chain; empty if the log is intact
+}
+
+// OK reports whether the chain and all anchors check out.
+func (v *AuditVerification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// VerifyAuditLog recomputes the hash chain of the audit log and checks it
+// against anchors. A modified entry fails its own hash, a removed or reordered
+// one breaks the link of the entry after it, and removed entries at the end
+// of the log leave an anchor without its entry. Entries removed by
+// PurgeAuditLog are accounted for by its record of the purge.
+func VerifyAuditLog(db *sql.DB, anchors []AuditAnchor) (*AuditVerification, error) {
+	if err := ensureAuditSchema(db); err != nil {
+		return nil, err
+	}
+	purged, prev, err := auditChainStart(db)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]AuditAnchor, len(anchors))
+	for _, a := range anchors {
+		if a.ID > purged {
+			byID[a.ID] = a
+		}
+	}
+
+	v := &AuditVerification{Purged: purged}
+	chained := prev != ""
+	filter := AuditFilter{Oldest: true, Limit: 1000}
+	for {
+		entries, err := QueryAuditLog(db, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			filter.AfterID = e.ID
+			if e.Hash == "" {
+				if chained {
+					v.Problems = append(v.Problems, fmt.Sprintf("entry %d has no hash: it was written outside the proxy", e.ID))
+				} else {
+					v.Unchained++
+				}
+				continue
+			}
+			chained = true
+			v.Entries++
+			if e.PrevHash != prev {
+				v.Problems = append(v.Problems, fmt.Sprintf("entry %d does not follow the entry before it: entries were removed or reordered", e.ID))
+			}
+			if auditHash(e.PrevHash, e) != e.Hash {
+				v.Problems = append(v.Problems, fmt.Sprintf("entry %d was modified", e.ID))
+			}
+			if a, ok := byID[e.ID]; ok {
+				if a.Hash != e.Hash {
+					v.Problems = append(v.Problems, fmt.Sprintf("entry %d does not match the anchor of %s", e.ID, a.Time.Format(time.RFC3339)))
+				} else {
+					v.Anchors++
+				}
+				delete(byID, e.ID)
+			}
+			prev = e.Hash
+			v.Head = &AuditAnchor{ID: e.ID, Hash: e.Hash, Time: e.Timestamp}
+		}
+		if len(entries) < filter.Limit {
+			break
+		}
+	}
+
+	for _, a := range anchors {
+		if _, missing := byID[a.ID]; missing {
+			v.Problems = append(v.Problems, fmt.Sprintf("entry %d, anchored at %s, is missing: the log was truncated", a.ID, a.Time.Format(time.RFC3339)))
+			delete(byID, a.ID)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openChainTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := initDBSchema(db); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	return db
+}
+
+func recordChainTestEntries(t *testing.T, db *sql.DB, n int) {
+	for i := 0; i < n; i++ {
+		e := AuditEntry{ServerID: "github", Method: "tools/call", ToolName: "github:create_issue", SessionID: "s1", Transport: "stdio"}
+		if i%2 == 1 {
+			e.Blocked, e.BlockReason, e.MatchedPattern = true, "blocklist_match", "delete_.*"
+		}
+		if err := RecordAuditEntry(db, e); err != nil {
+			t.Fatalf("Failed to record entry: %v", err)
+		}
+	}
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	db := openChainTestDB(t)
+	// An entry from before hash chaining
+	if _, err := db.Exec(`INSERT INTO audit_log (server_id, method) VALUES ('fs', 'tools/call')`); err != nil {
+		t.Fatalf("Failed to insert legacy entry: %v", err)
+	}
+	recordChainTestEntries(t, db, 5)
+
+	v, err := VerifyAuditLog(db, nil)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if !v.OK() || v.Entries != 5 || v.Unchained != 1 || v.Head == nil || v.Head.ID != 6 {
+		t.Fatalf("Expected an intact chain of 5 entries, got %+v", v)
+	}
+
+	tests := []struct {
+		name    string
+		tamper  string
+		problem string
+	}{
+		{"modified", `UPDATE audit_log SET blocked = 0 WHERE id = 3`, "entry 3 was modified"},
+		{"removed", `DELETE FROM audit_log WHERE id = 4`, "entry 5 does not follow"},
+		{"inserted", `INSERT INTO audit_log (server_id, method) VALUES ('fs', 'tools/call')`, "entry 7 has no hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openChainTestDB(t)
+			db.Exec(`INSERT INTO audit_log (server_id, method) VALUES ('fs', 'tools/call')`)
+			recordChainTestEntries(t, db, 5)
+			if _, err := db.Exec(tt.tamper); err != nil {
+				t.Fatalf("Failed to tamper: %v", err)
+			}
+			v, err := VerifyAuditLog(db, nil)
+			if err != nil {
+				t.Fatalf("Failed to verify: %v", err)
+			}
+			if v.OK() || !strings.Contains(strings.Join(v.Problems, "\n"), tt.problem) {
+				t.Errorf("Expected %q, got %v", tt.problem, v.Problems)
+			}
+		})
+	}
+}
+
+func TestAuditAnchors(t *testing.T) {
+	db := openChainTestDB(t)
+	anchorPath := filepath.Join(t.TempDir(), "audit.anchor")
+	recordChainTestEntries(t, db, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := startAuditAnchors(ctx, db, Config{AuditAnchorPath: anchorPath, AuditAnchorInterval: time.Hour}, &noOpLogger{})
+	cancel()
+	stop() // writes the final anchor
+
+	anchors, err := ReadAuditAnchors(anchorPath)
+	if err != nil {
+		t.Fatalf("Failed to read anchors: %v", err)
+	}
+	if len(anchors) != 1 || anchors[0].ID != 3 {
+		t.Fatalf("Expected one anchor at entry 3, got %+v", anchors)
+	}
+	if v, err := VerifyAuditLog(db, anchors); err != nil || !v.OK() || v.Anchors != 1 {
+		t.Fatalf("Expected the anchor to match, got %+v, %v", v, err)
+	}
+
+	// Removing entries from the end keeps the chain valid, but not the anchor
+	if _, err := db.Exec(`DELETE FROM audit_log WHERE id >= 2`); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+	v, err := VerifyAuditLog(db, anchors)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if v.OK() || !strings.Contains(strings.Join(v.Problems, "\n"), "entry 3, anchored at") {
+		t.Errorf("Expected the truncation to be reported, got %v", v.Problems)
+	}
+}
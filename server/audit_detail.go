@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// settingAuditPolicy persists the applied audit section in the rules store.
+const settingAuditPolicy = "audit_policy"
+
+// AuditDetail is how much of a tool call the audit log keeps.
+type AuditDetail string
+
+const (
+	AuditMetadata    AuditDetail = "metadata"      // tool, decision and reason only
+	AuditArgsOnBlock AuditDetail = "args-on-block" // also the arguments of blocked calls
+	AuditFull        AuditDetail = "full"          // also the arguments of allowed calls
+)
+
+var auditDetails = []AuditDetail{AuditMetadata, AuditArgsOnBlock, AuditFull}
+
+// maxAuditArguments caps the arguments kept per entry, so one large file
+// write doesn't bloat the database.
+const maxAuditArguments = 16 << 10
+
+// AuditPolicy sets which tool calls keep their arguments in the audit log.
+// It is the `audit:` section of a policy file; detail applies to every
+// tool, and classes override it per tool class (see ClassifyTool):
+//
+//	audit:
+//	  detail: args-on-block
+//	  classes:
+//	    exec: full
+//	    read: metadata
+//	  quiet: [TodoWrite, "github:list_*"]
+//
+// Arguments are redacted of sensitive data (see ScanDLP) before they are
+// stored. Without the section only metadata is kept.
+//
+// Quiet tools are noisy ones whose allowed calls are of little interest.
+// Their calls are enforced and audited as usual, but allowed calls are
+// marked quiet in the audit log, collapsed by `audit tail`, and left out of
+// the headline stats. Blocked calls of quiet tools are never quiet.
+type AuditPolicy struct {
+	Detail  AuditDetail            `yaml:"detail,omitempty" json:"detail,omitempty"`
+	Classes map[string]AuditDetail `yaml:"classes,omitempty" json:"classes,omitempty"`
+	Quiet   []string               `yaml:"quiet,omitempty" json:"quiet,omitempty"` // tool names; * matches a prefix or suffix
+}
+
+// IsZero reports whether the section is empty.
+func (ap AuditPolicy) IsZero() bool {
+	return ap.Detail == "" && len(ap.Classes) == 0 && len(ap.Quiet) == 0
+}
+
+// IsQuiet reports whether a tool is one of the quiet tools.
+func (ap AuditPolicy) IsQuiet(toolName string) bool {
+	for _, pattern := range ap.Quiet {
+		if matchWildcard(toolName, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the detail levels and tool classes.
+func (ap AuditPolicy) Validate() error {
+	if ap.Detail != "" && !slices.Contains(auditDetails, ap.Detail) {
+		return fmt.Errorf("audit.detail: invalid level %q (want one of %s)", ap.Detail, auditDetailNames())
+	}
+	for class, detail := range ap.Classes {
+		if !slices.Contains(toolClasses, class) {
+			return fmt.Errorf("audit.classes: invalid tool class %q (want one of %s)", class, strings.Join(toolClasses, ", "))
+		}
+		if !slices.Contains(auditDetails, detail) {
+			return fmt.Errorf("audit.classes.%s: invalid level %q (want one of %s)", class, detail, auditDetailNames())
+		}
+	}
+	for i, pattern := range ap.Quiet {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("audit.quiet[%d]: tool name is empty", i)
+		}
+	}
+	return nil
+}
+
+func auditDetailNames() string {
+	names := make([]string, len(auditDetails))
+	for i, d := range auditDetails {
+		names[i] = string(d)
+	}
+	return strings.Join(names, ", ")
+}
+
+// String renders the policy compactly for diffs, e.g.
+// "args-on-block exec=full quiet=TodoWrite".
+func (ap AuditPolicy) String() string {
+	if ap.IsZero() {
+		return ""
+	}
+	parts := []string{string(ap.detail(""))}
+	for class, detail := range ap.Classes {
+		parts = append(parts, class+"="+string(detail))
+	}
+	sort.Strings(parts[1:])
+	if len(ap.Quiet) > 0 {
+		parts = append(parts, "quiet="+strings.Join(ap.Quiet, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// detail returns the level for a tool class.
+func (ap AuditPolicy) detail(class string) AuditDetail {
+	if d, ok := ap.Classes[class]; ok {
+		return d
+	}
+	if ap.Detail != "" {
+		return ap.Detail
+	}
+	return AuditMetadata
+}
+
+// Arguments returns the arguments to keep for a call to a tool of class,
+// as redacted JSON, or "" if the policy keeps metadata only.
+func (ap AuditPolicy) Arguments(class string, blocked bool, args map[string]interface{}) string {
+	switch ap.detail(class) {
+	case AuditFull:
+	case AuditArgsOnBlock:
+		if !blocked {
+			return ""
+		}
+	default:
+		return ""
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	_, redacted := ScanDLP(redactAllDLP, args)
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		return ""
+	}
+	if len(data) <= maxAuditArguments {
+		return string(data)
+	}
+	cut := maxAuditArguments
+	for cut > 0 && !utf8.RuneStart(data[cut]) {
+		cut--
+	}
+	return string(data[:cut]) + "…[truncated]"
+}
+
+func encodeAuditPolicy(ap AuditPolicy) (string, error) {
+	if ap.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(ap)
+	return string(data), err
+}
+
+func decodeAuditPolicy(raw string) (AuditPolicy, error) {
+	var ap AuditPolicy
+	if raw == "" {
+		return ap, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &ap); err != nil {
+		return ap, fmt.Errorf("invalid stored audit policy: %w", err)
+	}
+	return ap, nil
+}
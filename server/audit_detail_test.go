@@ -0,0 +1,160 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestAuditPolicyArguments(t *testing.T) {
+	policy := AuditPolicy{
+		Detail:  AuditArgsOnBlock,
+		Classes: map[string]AuditDetail{ToolClassExec: AuditFull, ToolClassDelete: AuditMetadata},
+	}
+	args := map[string]interface{}{"path": "/tmp/x", "card": "4111 1111 1111 1111"}
+
+	tests := []struct {
+		name    string
+		policy  AuditPolicy
+		class   string
+		blocked bool
+		want    string
+	}{
+		{"no policy", AuditPolicy{}, ToolClassWrite, true, ""},
+		{"allowed read", policy, ToolClassRead, false, ""},
+		{"blocked read", policy, ToolClassRead, true, `{"card":"[REDACTED:credit_card]","path":"/tmp/x"}`},
+		{"allowed exec", policy, ToolClassExec, false, `{"card":"[REDACTED:credit_card]","path":"/tmp/x"}`},
+		{"blocked delete", policy, ToolClassDelete, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Arguments(tt.class, tt.blocked, args); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	large := map[string]interface{}{"content": strings.Repeat("é", maxAuditArguments)}
+	got := AuditPolicy{Detail: AuditFull}.Arguments(ToolClassWrite, false, large)
+	if len(got) > maxAuditArguments+len("…[truncated]") || !strings.HasSuffix(got, "…[truncated]") {
+		t.Errorf("Expected large arguments to be truncated, got %d bytes", len(got))
+	}
+}
+
+func TestAuditPolicyValidate(t *testing.T) {
+	for _, content := range []string{
+		"audit:\n  detail: everything\n",
+		"audit:\n  classes:\n    network: full\n",
+		"audit:\n  classes:\n    exec: verbose\n",
+		"audit:\n  quiet: [\"\"]\n",
+	} {
+		if _, err := ParsePolicyFile([]byte(content)); err == nil {
+			t.Errorf("Expected %q to be rejected", content)
+		}
+	}
+	pf, err := ParsePolicyFile([]byte("audit:\n  detail: args-on-block\n  classes:\n    exec: full\n  quiet: [TodoWrite, \"github:list_*\"]\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse policy file: %v", err)
+	}
+	if got := pf.Audit.String(); got != "args-on-block exec=full quiet=TodoWrite,github:list_*" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+}
+
+func TestCheckRequestAuditArguments(t *testing.T) {
+	s, err := NewServer(Config{
+		Mode:     "http",
+		LogLevel: "error",
+		DBPath:   filepath.Join(t.TempDir(), "armour.db"),
+		Registry: &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "github", Transport: "http", URL: "http://127.0.0.1:1"}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer s.Close()
+	s.checkers = []PolicyChecker{funcChecker{"reviewer", func(call PolicyCall) (PolicyVerdict, error) {
+		if call.Tool == "github:delete_repo" {
+			return PolicyVerdict{Decision: PolicyBlock, Reason: "no deletes"}, nil
+		}
+		return PolicyVerdict{Decision: PolicyAllow}, nil
+	}}}
+	s.SetAuditPolicy(AuditPolicy{Detail: AuditArgsOnBlock})
+
+	for _, tool := range []string{"list_repos", "delete_repo"} {
+		req := JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: []byte(`{"name":"` + tool + `","arguments":{"repo":"armour"}}`)}
+		if _, err := s.CheckRequest("github", "s1", req); err != nil {
+			t.Fatalf("check failed: %v", err)
+		}
+	}
+
+	entries, err := QueryAuditLog(s.db, AuditFilter{})
+	if err != nil {
+		t.Fatalf("failed to query audit log: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Arguments != "" || entries[1].Arguments != `{"repo":"armour"}` {
+		t.Fatalf("expected arguments only for the blocked call, got %+v", entries)
+	}
+	if v, err := VerifyAuditLog(s.db, nil); err != nil || !v.OK() {
+		t.Errorf("expected entries with arguments to verify, got %+v, %v", v, err)
+	}
+}
+
+func TestQuietTools(t *testing.T) {
+	s, err := NewServer(Config{
+		Mode:     "http",
+		LogLevel: "error",
+		DBPath:   filepath.Join(t.TempDir(), "armour.db"),
+		Registry: &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "github", Transport: "http", URL: "http://127.0.0.1:1"}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer s.Close()
+	s.checkers = []PolicyChecker{funcChecker{"reviewer", func(call PolicyCall) (PolicyVerdict, error) {
+		if call.Arguments["repo"] == "prod" {
+			return PolicyVerdict{Decision: PolicyBlock, Reason: "not prod"}, nil
+		}
+		return PolicyVerdict{Decision: PolicyAllow}, nil
+	}}}
+	s.SetAuditPolicy(AuditPolicy{Quiet: []string{"github:list_*"}})
+
+	for _, call := range []string{
+		`{"name":"list_repos","arguments":{"repo":"armour"}}`,
+		`{"name":"list_issues","arguments":{"repo":"armour"}}`,
+		`{"name":"list_repos","arguments":{"repo":"prod"}}`, // blocked, so never quiet
+		`{"name":"create_issue","arguments":{"repo":"armour"}}`,
+	} {
+		req := JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: []byte(call)}
+		if _, err := s.CheckRequest("github", "s1", req); err != nil {
+			t.Fatalf("check failed: %v", err)
+		}
+	}
+
+	entries, err := QueryAuditLog(s.db, AuditFilter{})
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("expected quiet calls audited too, got %+v (err=%v)", entries, err)
+	}
+	if !entries[0].Quiet || !entries[1].Quiet || entries[2].Quiet || entries[3].Quiet {
+		t.Errorf("expected only the allowed list calls quiet, got %+v", entries)
+	}
+	if shown, _ := QueryAuditLog(s.db, AuditFilter{HideQuiet: true}); len(shown) != 2 {
+		t.Errorf("expected quiet entries hidden, got %+v", shown)
+	}
+	lines := FormatAuditEntries(entries, true)
+	if len(lines) != 3 || !strings.Contains(lines[0], "QUIET  2 calls: github:list_repos ×1, github:list_issues ×1") {
+		t.Errorf("expected the quiet run collapsed into one line, got %q", lines)
+	}
+	if v, err := VerifyAuditLog(s.db, nil); err != nil || !v.OK() {
+		t.Errorf("expected quiet entries to verify, got %+v, %v", v, err)
+	}
+
+	stats := s.statsTracker.GetStats()
+	if stats.AllowedCallsTotal != 1 || stats.BlockedCallsTotal != 1 || stats.QuietCallsTotal != 2 {
+		t.Errorf("expected quiet calls apart from the headline counts, got %+v", stats)
+	}
+	if hits := s.statsTracker.ToolHits()["github:list_repos"]; hits.Allowed != 1 || hits.Blocked != 1 {
+		t.Errorf("expected quiet calls still counted per tool, got %+v", hits)
+	}
+}
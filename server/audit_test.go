@@ -0,0 +1,81 @@
+package server
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// TestAuditLogFilters tests recording and filtering audit entries
+func TestAuditLogFilters(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:memdb_audit?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Start from the stdio schema, which lacks the blocklist columns
+	if err := initDBSchema(db); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+
+	entries := []AuditEntry{
+		{ServerID: "github", Method: "tools/call", ToolName: "github:create_issue"},
+		{ServerID: "github", Method: "tools/call", ToolName: "github:delete_repo", Blocked: true, BlockReason: "blocklist_match", MatchedPattern: "delete_.*"},
+		{ServerID: "fs", Method: "tools/call", ToolName: "fs:read_file"},
+	}
+	for _, e := range entries {
+		if err := RecordAuditEntry(db, e); err != nil {
+			t.Fatalf("Failed to record entry: %v", err)
+		}
+	}
+
+	all, err := QueryAuditLog(db, AuditFilter{})
+	if err != nil {
+		t.Fatalf("Failed to query audit log: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(all))
+	}
+	if all[0].ToolName != "github:create_issue" {
+		t.Errorf("Expected ascending order, first entry was %s", all[0].ToolName)
+	}
+
+	blocked, err := QueryAuditLog(db, AuditFilter{BlockedOnly: true})
+	if err != nil {
+		t.Fatalf("Failed to query blocked entries: %v", err)
+	}
+	if len(blocked) != 1 || blocked[0].MatchedPattern != "delete_.*" {
+		t.Errorf("Expected only the delete_repo entry, got %+v", blocked)
+	}
+
+	byBackend, _ := QueryAuditLog(db, AuditFilter{Backend: "fs"})
+	if len(byBackend) != 1 {
+		t.Errorf("Expected 1 fs entry, got %d", len(byBackend))
+	}
+
+	byTool, _ := QueryAuditLog(db, AuditFilter{Tool: "create"})
+	if len(byTool) != 1 {
+		t.Errorf("Expected 1 create entry, got %d", len(byTool))
+	}
+
+	byQuery, _ := QueryAuditLog(db, AuditFilter{Query: "delete_"})
+	if len(byQuery) != 1 || byQuery[0].ToolName != "github:delete_repo" {
+		t.Errorf("Expected the delete_repo entry by pattern, got %+v", byQuery)
+	}
+	byQuery, _ = QueryAuditLog(db, AuditFilter{Query: "github"})
+	if len(byQuery) != 2 {
+		t.Errorf("Expected 2 github entries, got %d", len(byQuery))
+	}
+
+	newer, _ := QueryAuditLog(db, AuditFilter{AfterID: all[1].ID})
+	if len(newer) != 1 || newer[0].ToolName != "fs:read_file" {
+		t.Errorf("Expected only entries after id %d, got %+v", all[1].ID, newer)
+	}
+
+	tail, _ := QueryAuditLog(db, AuditFilter{Limit: 2})
+	if len(tail) != 2 || tail[1].ToolName != "fs:read_file" {
+		t.Errorf("Expected the last 2 entries, got %+v", tail)
+	}
+}
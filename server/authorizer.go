@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// settingAuthorizer persists the applied authorizer section in the rules store.
+const settingAuthorizer = "authorizer"
+
+// DelegateAction is the rule action that hands the decision on a matching
+// call to the authorizer.
+const DelegateAction = "delegate"
+
+// DefaultAuthorizerTimeout is how long the authorizer may take to answer
+// before on_error applies.
+const DefaultAuthorizerTimeout = 2 * time.Second
+
+// AuthorizerPolicy is the `authorizer:` section of a policy file: an external
+// HTTP service that decides the calls rules with action "delegate" match, so
+// an organization can keep its authorization decisions in one place.
+//
+//	authorizer:
+//	  url: https://authz.example.com/armour
+//	  timeout: 2s
+//	  on_error: allow # fail open; the default is block
+//
+// The authorizer receives an AuthorizerRequest as JSON and answers with a
+// PolicyVerdict: {"decision": "allow"|"block"|"ask", "reason": "..."}.
+type AuthorizerPolicy struct {
+	URL     string `yaml:"url,omitempty" json:"url,omitempty"`
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`   // Go duration; DefaultAuthorizerTimeout if empty
+	OnError string `yaml:"on_error,omitempty" json:"on_error,omitempty"` // block (default) or allow
+}
+
+// AuthorizerRequest is the body posted to the authorizer.
+type AuthorizerRequest struct {
+	Rule    AuthorizerRule `json:"rule"`
+	Method  string         `json:"method"` // e.g. tools/call
+	Call    PolicyCall     `json:"call"`
+	Content string         `json:"content,omitempty"` // the text the rule matched, from the rules server
+}
+
+// AuthorizerRule identifies the delegate rule that matched.
+type AuthorizerRule struct {
+	ID   int64  `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// IsZero reports whether no authorizer is configured.
+func (ap AuthorizerPolicy) IsZero() bool {
+	return ap.URL == "" && ap.Timeout == "" && ap.OnError == ""
+}
+
+// Validate checks the URL, timeout and error mode.
+func (ap AuthorizerPolicy) Validate() error {
+	if ap.IsZero() {
+		return nil
+	}
+	u, err := url.Parse(ap.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("authorizer.url: invalid URL %q: expected http(s)://host/path", ap.URL)
+	}
+	if ap.Timeout != "" {
+		if d, err := time.ParseDuration(ap.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("authorizer.timeout: invalid duration %q", ap.Timeout)
+		}
+	}
+	switch ap.OnError {
+	case "", PolicyAllow, PolicyBlock:
+	default:
+		return fmt.Errorf("authorizer.on_error: invalid value %q (expected allow or block)", ap.OnError)
+	}
+	return nil
+}
+
+// String summarizes the section on one line for diffs.
+func (ap AuthorizerPolicy) String() string {
+	if ap.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s (timeout %s, on error %s)", ap.URL, ap.timeout(), ap.onError())
+}
+
+func (ap AuthorizerPolicy) timeout() time.Duration {
+	if d, err := time.ParseDuration(ap.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultAuthorizerTimeout
+}
+
+func (ap AuthorizerPolicy) onError() string {
+	if ap.OnError == PolicyAllow {
+		return PolicyAllow
+	}
+	return PolicyBlock
+}
+
+func encodeAuthorizerPolicy(ap AuthorizerPolicy) (string, error) {
+	if ap.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(ap)
+	return string(data), err
+}
+
+func decodeAuthorizerPolicy(raw string) (AuthorizerPolicy, error) {
+	var ap AuthorizerPolicy
+	if raw == "" {
+		return ap, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &ap); err != nil {
+		return ap, fmt.Errorf("invalid stored authorizer policy: %w", err)
+	}
+	return ap, nil
+}
+
+// authorize asks the authorizer to decide a call. Without an authorizer the
+// call is blocked; when the authorizer fails, times out or answers with an
+// unknown decision, on_error decides.
+func (ap AuthorizerPolicy) authorize(ctx context.Context, req AuthorizerRequest) PolicyVerdict {
+	if ap.URL == "" {
+		return PolicyVerdict{Decision: PolicyBlock, Reason: "the rule delegates to an authorizer but none is configured"}
+	}
+	verdict, err := ap.post(ctx, req)
+	if err != nil {
+		return PolicyVerdict{Decision: ap.onError(), Reason: fmt.Sprintf("authorizer failed: %v", err)}
+	}
+	switch verdict.Decision {
+	case PolicyAllow, PolicyBlock, PolicyAsk:
+		return verdict
+	}
+	return PolicyVerdict{Decision: ap.onError(), Reason: fmt.Sprintf("authorizer returned unknown decision %q", verdict.Decision)}
+}
+
+func (ap AuthorizerPolicy) post(ctx context.Context, areq AuthorizerRequest) (PolicyVerdict, error) {
+	body, err := json.Marshal(areq)
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, ap.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ap.URL, bytes.NewReader(body))
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return PolicyVerdict{}, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var verdict PolicyVerdict
+	if err := json.Unmarshal(data, &verdict); err != nil {
+		return PolicyVerdict{}, fmt.Errorf("invalid response: %w", err)
+	}
+	return verdict, nil
+}
+
+// delegate resolves a blocklist result whose matching rule has action
+// "delegate" through the authorizer; other results are returned unchanged.
+// An "ask" verdict leaves the call held for approval like an ask rule.
+func (ap AuthorizerPolicy) delegate(ctx context.Context, result *BlocklistCheckResult, method string, call PolicyCall) *BlocklistCheckResult {
+	if result == nil || result.Allowed || result.MatchedRule == nil || result.MatchedRule.Action != DelegateAction {
+		return result
+	}
+	rule := *result.MatchedRule
+	verdict := ap.authorize(ctx, AuthorizerRequest{
+		Rule:   AuthorizerRule{ID: rule.ID, Name: rule.Description},
+		Method: method,
+		Call:   call,
+	})
+	if verdict.Decision == PolicyAllow {
+		return &BlocklistCheckResult{Allowed: true}
+	}
+	rule.Action = verdict.Decision
+	return &BlocklistCheckResult{
+		DeniedOperation: result.DeniedOperation,
+		MatchedRule:     &rule,
+		Error: &MCPError{
+			Code:    ErrCodeDenied,
+			Message: delegateReason(verdict),
+		},
+	}
+}
+
+// delegateReason is the reason shown for a call the authorizer didn't allow.
+func delegateReason(verdict PolicyVerdict) string {
+	if verdict.Reason == "" {
+		return "denied by authorizer"
+	}
+	return "authorizer: " + verdict.Reason
+}
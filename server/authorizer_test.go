@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAuthorizer answers with a decision per tool; "slow" tools time out.
+func newTestAuthorizer(t *testing.T) *httptest.Server {
+	decisions := map[string]string{
+		"github:merge_pr":  `{"decision":"allow"}`,
+		"github:delete":    `{"decision":"block","reason":"deletes need a change ticket"}`,
+		"github:deploy":    `{"decision":"ask","reason":"deploys need a second pair of eyes"}`,
+		"github:confused":  `{"decision":"maybe"}`,
+		"Bash":             `{"decision":"block","reason":"no shell on Fridays"}`,
+		"github:error":     ``,
+		"github:slow_call": `{"decision":"allow"}`,
+	}
+	authz := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req AuthorizerRequest
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &req); err != nil || req.Rule.ID == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.Call.Tool == "github:slow_call" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if decisions[req.Call.Tool] == "" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, decisions[req.Call.Tool])
+	}))
+	t.Cleanup(authz.Close)
+	return authz
+}
+
+func TestAuthorizerDelegate(t *testing.T) {
+	authz := newTestAuthorizer(t)
+	closed := AuthorizerPolicy{URL: authz.URL, Timeout: "50ms"}
+	open := AuthorizerPolicy{URL: authz.URL, Timeout: "50ms", OnError: "allow"}
+
+	tests := []struct {
+		name       string
+		authorizer AuthorizerPolicy
+		tool       string
+		action     string // "" when the call is allowed
+	}{
+		{"allow", closed, "github:merge_pr", ""},
+		{"block", closed, "github:delete", "block"},
+		{"ask", closed, "github:deploy", "ask"},
+		{"unknown decision fails closed", closed, "github:confused", "block"},
+		{"error fails closed", closed, "github:error", "block"},
+		{"timeout fails closed", closed, "github:slow_call", "block"},
+		{"error fails open", open, "github:error", ""},
+		{"timeout fails open", open, "github:slow_call", ""},
+		{"no authorizer", AuthorizerPolicy{}, "github:merge_pr", "block"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched := &BlocklistCheckResult{
+				DeniedOperation: "tools_call",
+				MatchedRule:     &BlocklistRule{ID: 7, Description: "github writes", Action: DelegateAction},
+			}
+			result := tt.authorizer.delegate(context.Background(), matched, "tools/call", PolicyCall{Server: "github", Tool: tt.tool, Transport: "stdio"})
+			if result.Allowed != (tt.action == "") {
+				t.Fatalf("Expected allowed=%v, got %+v", tt.action == "", result)
+			}
+			if tt.action != "" && (result.MatchedRule.Action != tt.action || result.MatchedRule.ID != 7) {
+				t.Errorf("Expected rule 7 with action %s, got %+v", tt.action, result.MatchedRule)
+			}
+		})
+	}
+
+	block := ruleBlock(closed.delegate(context.Background(), &BlocklistCheckResult{
+		DeniedOperation: "tools_call",
+		MatchedRule:     &BlocklistRule{ID: 7, Action: DelegateAction},
+	}, "tools/call", PolicyCall{Server: "github", Tool: "github:delete"}), "github:delete", "")
+	if block.Reason != "authorizer: deletes need a change ticket" || block.Action != "block" {
+		t.Errorf("Unexpected block %+v", block)
+	}
+
+	// Results of other rules don't reach the authorizer
+	blocked := &BlocklistCheckResult{MatchedRule: &BlocklistRule{ID: 3, Action: "block"}}
+	if got := closed.delegate(context.Background(), blocked, "tools/call", PolicyCall{Tool: "github:merge_pr"}); got != blocked {
+		t.Errorf("Expected a block rule's result unchanged, got %+v", got)
+	}
+}
+
+func TestRulesServerDelegate(t *testing.T) {
+	authz := newTestAuthorizer(t)
+	dir := t.TempDir()
+	rs, err := NewRulesServer(RulesServerConfig{DBPath: filepath.Join(dir, "rules.db")})
+	if err != nil {
+		t.Fatalf("Failed to create rules server: %v", err)
+	}
+	defer rs.db.Close()
+
+	pf, err := ParsePolicyFile([]byte(`
+authorizer:
+  url: ` + authz.URL + `
+  timeout: 1s
+rules:
+  - name: central-shell
+    tools: Bash
+    block_all: true
+    action: delegate
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse policy file: %v", err)
+	}
+	diff, err := ApplyPolicy(rs.store, pf)
+	if err != nil {
+		t.Fatalf("Failed to apply policy: %v", err)
+	}
+	if !strings.Contains(FormatPolicyDiff(diff), "~ authorizer: \"\" -> \""+authz.URL+" (timeout 1s, on error block)\"") {
+		t.Errorf("Expected authorizer change in the diff, got %q", FormatPolicyDiff(diff))
+	}
+
+	resp := rs.Evaluate(context.Background(), CheckRequest{Tool: "Bash", Scope: "native", Content: `{"command":"ls"}`})
+	if resp.Allowed || resp.Decision != "block" || resp.Reason != "authorizer: no shell on Fridays" {
+		t.Errorf("Expected the authorizer to block the call, got %+v", resp)
+	}
+
+	exported, err := ExportPolicy(rs.store)
+	if err != nil {
+		t.Fatalf("Failed to export policy: %v", err)
+	}
+	if exported.Authorizer != pf.Authorizer {
+		t.Errorf("Expected exported authorizer %+v, got %+v", pf.Authorizer, exported.Authorizer)
+	}
+}
+
+func TestAuthorizerValidate(t *testing.T) {
+	for _, content := range []string{
+		"authorizer:\n  url: authz.example.com\n",
+		"authorizer:\n  url: https://authz.example.com\n  timeout: soon\n",
+		"authorizer:\n  url: https://authz.example.com\n  on_error: ask\n",
+		"rules:\n  - name: central\n    block_all: true\n    action: delegate\n",
+	} {
+		if _, err := ParsePolicyFile([]byte(content)); err == nil {
+			t.Errorf("Expected %q to be rejected", content)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// hedgedExchange is exchange for an idempotent request: if the backend hasn't
+// answered within the hedge threshold, the request goes to its replica too
+// and the first successful response is returned. The replica is skipped
+// while its session is busy with an earlier hedge.
+func (bc *BackendConnection) hedgedExchange(ctx context.Context, transport proxy.Transport, reqBytes []byte, release func()) ([]byte, error) {
+	bc.mu.RLock()
+	replica := bc.replica
+	bc.mu.RUnlock()
+	if replica == nil {
+		return bc.exchange(ctx, transport, reqBytes, release)
+	}
+
+	// The slower request is abandoned once one succeeds
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type response struct {
+		data []byte
+		err  error
+	}
+	results := make(chan response, 2)
+	send := func(transport proxy.Transport, release func()) {
+		data, err := bc.exchange(ctx, transport, reqBytes, release)
+		results <- response{data, err}
+	}
+	go send(transport, release)
+
+	timer := time.NewTimer(bc.config.Hedge.Threshold())
+	defer timer.Stop()
+	pending := 1
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			select {
+			case replicaTransport := <-replica:
+				bc.logger.Debug("backend %s: no response within %v, hedging to its replica", bc.config.Name, bc.config.Hedge.Threshold())
+				pending++
+				go send(replicaTransport, func() { replica <- replicaTransport })
+			default:
+			}
+		case resp := <-results:
+			pending--
+			if resp.err == nil {
+				return resp.data, nil
+			}
+			if firstErr == nil {
+				firstErr = resp.err
+			}
+			// Both failed, or the backend failed before the hedge fired
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
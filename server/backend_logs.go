@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+const (
+	// backendLogLines is how many stderr lines are kept per backend.
+	backendLogLines = 200
+	// backendLogTailLines are quoted in initialization failure errors.
+	backendLogTailLines = 10
+	// backendLogLineBytes caps a single line; the rest is dropped.
+	backendLogLineBytes = 4096
+)
+
+// LogBuffer keeps the last lines written to it, so a stdio backend's stderr
+// can be shown on the dashboard instead of being lost. It is safe for
+// concurrent use.
+type LogBuffer struct {
+	mu      sync.Mutex
+	size    int
+	lines   []string
+	partial []byte       // unterminated last line
+	onLine  func(string) // called for each complete line, e.g. to log it
+}
+
+// NewLogBuffer keeps the last size lines.
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{size: size}
+}
+
+// Write splits p into lines; an unterminated line is completed by later writes.
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	var complete []string
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		complete = append(complete, logLine(data[:i]))
+		data = data[i+1:]
+	}
+	if len(data) > backendLogLineBytes {
+		data = data[:backendLogLineBytes]
+	}
+	b.partial = append([]byte(nil), data...)
+	b.lines = append(b.lines, complete...)
+	if len(b.lines) > b.size {
+		b.lines = b.lines[len(b.lines)-b.size:]
+	}
+	onLine := b.onLine
+	b.mu.Unlock()
+
+	if onLine != nil {
+		for _, line := range complete {
+			onLine(line)
+		}
+	}
+	return len(p), nil
+}
+
+// logLine trims a carriage return and caps the line length.
+func logLine(line []byte) string {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) > backendLogLineBytes {
+		line = line[:backendLogLineBytes]
+	}
+	return string(line)
+}
+
+// Lines returns the last n lines (all kept lines if n <= 0), oldest first,
+// including an unterminated last line.
+func (b *LogBuffer) Lines(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lines := append([]string(nil), b.lines...)
+	if len(b.partial) > 0 {
+		lines = append(lines, logLine(b.partial))
+	}
+	if n <= 0 || n > b.size {
+		n = b.size
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// stderrTail renders the last stderr lines of a backend for an error
+// message, or "" if it wrote none.
+func stderrTail(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return "; last stderr lines:\n  " + strings.Join(lines, "\n  ")
+}
+
+// backendLog returns the stderr buffer of a backend, creating it on first
+// use. The buffer outlives restarts, so output from a crash stays visible.
+func (bm *BackendManager) backendLog(name string) *LogBuffer {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.logs == nil {
+		bm.logs = make(map[string]*LogBuffer)
+	}
+	buf, ok := bm.logs[name]
+	if !ok {
+		buf = NewLogBuffer(backendLogLines)
+		buf.onLine = func(line string) {
+			bm.logger.Debug("[%s stderr] %s", name, line)
+		}
+		bm.logs[name] = buf
+	}
+	return buf
+}
+
+// BackendLogs returns the last n stderr lines of a stdio backend (all kept
+// lines if n <= 0). Backends that never wrote to stderr have none.
+func (bm *BackendManager) BackendLogs(name string, n int) []string {
+	bm.mu.RLock()
+	buf := bm.logs[name]
+	bm.mu.RUnlock()
+	if buf == nil {
+		return []string{}
+	}
+	return buf.Lines(n)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestLogBuffer tests that the buffer keeps the last lines and joins lines
+// split across writes
+func TestLogBuffer(t *testing.T) {
+	buf := NewLogBuffer(3)
+	fmt.Fprint(buf, "starting\r\nlistening on std")
+	fmt.Fprint(buf, "io\n")
+	if got := buf.Lines(0); strings.Join(got, "|") != "starting|listening on stdio" {
+		t.Errorf("expected two joined lines, got %q", got)
+	}
+	fmt.Fprint(buf, "a\nb\nc\npartial")
+	if got := buf.Lines(0); strings.Join(got, "|") != "b|c|partial" {
+		t.Errorf("expected the last lines and the unterminated one, got %q", got)
+	}
+	if got := buf.Lines(1); len(got) != 1 || got[0] != "partial" {
+		t.Errorf("expected the last line, got %q", got)
+	}
+	fmt.Fprintln(buf, strings.Repeat("x", backendLogLineBytes+10))
+	if got := buf.Lines(1); len(got[0]) != backendLogLineBytes {
+		t.Errorf("expected the line capped at %d bytes, got %d", backendLogLineBytes, len(got[0]))
+	}
+}
+
+// TestBackendInitFailureIncludesStderr tests that a stdio backend that dies
+// on startup reports what it wrote to stderr
+func TestBackendInitFailureIncludesStderr(t *testing.T) {
+	entry := proxy.ServerEntry{
+		Name:      "broken",
+		Transport: "stdio",
+		Command:   "sh",
+		Args:      []string{"-c", "echo 'Error: GITHUB_TOKEN is not set' >&2; exit 1"},
+	}
+	bm := NewBackendManager(&proxy.ServerRegistry{Servers: []proxy.ServerEntry{entry}}, proxy.NewLogger("error"), NewToolRegistry(), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := bm.initializeBackend(ctx, &entry)
+	if err == nil || !strings.Contains(err.Error(), "GITHUB_TOKEN is not set") {
+		t.Fatalf("expected the stderr line in the error, got %v", err)
+	}
+	if lines := bm.BackendLogs("broken", 0); len(lines) != 1 || lines[0] != "Error: GITHUB_TOKEN is not set" {
+		t.Errorf("expected the stderr line kept, got %q", lines)
+	}
+	if lines := bm.BackendLogs("unknown", 0); len(lines) != 0 {
+		t.Errorf("expected no lines for an unknown backend, got %q", lines)
+	}
+}
@@ -602,8 +602,12 @@ func (bm *BackendManager) discoverAndMergePluginServers() {
 	// Add discovered config servers that aren't already in registry
 	for _, srv := range configServers {
 		if !existingNames[srv.Name] {
+			if !bm.admitDiscovered(srv, TrustConfig) {
+				continue
+			}
 			bm.registry.Servers = append(bm.registry.Servers, srv)
 			existingNames[srv.Name] = true
+			bm.configServers[srv.Name] = true
 			bm.logger.Debug("added discovered server from config: %s (%s)", srv.Name, srv.Transport)
 		}
 	}
@@ -623,9 +627,13 @@ func (bm *BackendManager) discoverAndMergePluginServers() {
 				bm.logger.Debug("updated existing server %s (same host as %s)", existing.Name, srv.Name)
 				continue
 			}
+			if !bm.admitDiscovered(srv, TrustPlugin) {
+				continue
+			}
 			bm.registry.Servers = append(bm.registry.Servers, srv)
 			existingNames[srv.Name] = true
 			existingIndex[srv.Name] = len(bm.registry.Servers) - 1
+			bm.pluginServers[srv.Name] = true
 			bm.logger.Debug("added discovered server from plugin: %s (%s)", srv.Name, srv.Transport)
 			continue
 		}
@@ -664,17 +672,33 @@ type BackendConnection struct {
 	config       *proxy.ServerEntry
 	transport    proxy.Transport
 	initialized  bool
+	process      *exec.Cmd      // stdio subprocess, if any
+	limiter      processLimiter // resource limits of the subprocess
 	Capabilities *proxy.Capabilities
+	version      string // protocol version the backend answered initialize with
 	tools        []Tool
 	mu           sync.RWMutex
 	logger       *proxy.Logger
+	recorder     *proxy.SessionRecorder
+	pool         []proxy.Transport    // extra sessions of a pooled http backend (see ServerEntry.Pool)
+	idle         chan proxy.Transport // sessions with no request in flight, so responses can't be mismatched
+	replica      chan proxy.Transport // the idle session to a hedged backend's replica, if open
+	replicaConn  proxy.Transport
+	notify       func(method string, params json.RawMessage) // backend notifications, if anyone listens
+	chaos        func() (ChaosFault, bool)                   // the fault injected into the backend, if chaos testing is on
+
+	// Keepalive state, guarded by mu
+	lastPong    time.Time
+	pingLatency time.Duration
+	missedPings int
 }
 
 // Tool represents an MCP tool with its metadata.
 type Tool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description,omitempty"`
-	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	InputSchema  map[string]interface{} `json:"inputSchema,omitempty"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
 }
 
 // BackendManager manages connections to multiple backend MCP servers.
@@ -684,9 +708,25 @@ type BackendManager struct {
 	connections        map[string]*BackendConnection
 	mu                 sync.RWMutex
 	toolRegistry       *ToolRegistry
+	prompts            *PromptRegistry
 	initializationDone chan struct{}
 	initializationOnce sync.Once
 	trace              *proxy.TraceRecorder
+	pluginServers      map[string]bool          // registry entries added by plugin discovery
+	configServers      map[string]bool          // registry entries added from ~/.claude.json
+	gate               *DiscoveryGate           // holds discovered servers back until approved; nil admits them
+	pendingServers     map[string]pendingServer // discovered servers held back by gate
+	provenance         *ProvenanceStore         // where started backends came from; nil records nothing
+	binaryPins         BinaryPins               // binary hashes stdio servers must match to start
+	packages           *PackageLocker           // locks npx/uvx packages; nil runs what the registry resolves
+	recorder           *proxy.SessionRecorder
+	dialMock           func(entry *proxy.ServerEntry) (proxy.Transport, error)
+	isolated           bool                  // no discovery or persisted tool list (session replay)
+	logs               map[string]*LogBuffer // stderr of stdio backends, by server name
+	restarts           map[string]*BackendRestarts
+	runCtx             context.Context // lifetime of the backends, from Initialize
+	notify             NotificationHandler
+	chaos              map[string]ChaosFault // injected faults by server; nil unless enabled (see EnableChaos)
 }
 
 const backendInitTimeout = 8 * time.Second
@@ -698,11 +738,38 @@ func NewBackendManager(registry *proxy.ServerRegistry, logger *proxy.Logger, too
 		logger:             logger,
 		connections:        make(map[string]*BackendConnection),
 		toolRegistry:       toolRegistry,
+		prompts:            NewPromptRegistry(),
 		initializationDone: make(chan struct{}),
 		trace:              trace,
+		pluginServers:      make(map[string]bool),
+		configServers:      make(map[string]bool),
+		pendingServers:     make(map[string]pendingServer),
 	}
 }
 
+// Prompts returns the names backend prompts are listed under.
+func (bm *BackendManager) Prompts() *PromptRegistry {
+	return bm.prompts
+}
+
+// SetRecorder records all traffic to and from backends initialized afterwards.
+func (bm *BackendManager) SetRecorder(recorder *proxy.SessionRecorder) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.recorder = recorder
+}
+
+// SetMockDialer connects "mock" transport servers through dial instead of
+// their fixtures. It also turns off plugin and ~/.claude.json discovery and persisting the
+// discovered tool list, so only the given registry is used and nothing on
+// disk changes. Session replay uses it to stand in for real backends.
+func (bm *BackendManager) SetMockDialer(dial func(entry *proxy.ServerEntry) (proxy.Transport, error)) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.dialMock = dial
+	bm.isolated = true
+}
+
 // Initialize attempts to initialize all configured backend servers.
 // First, it discovers MCP servers from installed Claude Code plugins (Approach A support).
 // Then, it initializes all servers (configured + discovered).
@@ -713,8 +780,11 @@ func (bm *BackendManager) Initialize(ctx context.Context) error {
 	})
 
 	bm.mu.Lock()
+	bm.runCtx = ctx
 	// First, discover and merge plugin MCP servers with configured servers
-	bm.discoverAndMergePluginServers()
+	if !bm.isolated {
+		bm.discoverAndMergePluginServers()
+	}
 
 	if bm.registry == nil || len(bm.registry.Servers) == 0 {
 		bm.mu.Unlock()
@@ -809,6 +879,12 @@ func (bm *BackendManager) WaitForAnyBackend(ctx context.Context, timeout time.Du
 func (bm *BackendManager) initializeBackend(ctx context.Context, serverEntry *proxy.ServerEntry) error {
 	expandServerEntry(serverEntry)
 	bm.logger.Debug("initializing backend: %s (%s)", serverEntry.Name, serverEntry.Transport)
+	if err := bm.lockPackage(ctx, serverEntry); err != nil {
+		return err
+	}
+	if err := bm.checkProvenance(serverEntry); err != nil {
+		return err
+	}
 	if bm.trace != nil {
 		bm.trace.Add(proxy.TraceEvent{
 			Stage:     "discovery",
@@ -821,15 +897,32 @@ func (bm *BackendManager) initializeBackend(ctx context.Context, serverEntry *pr
 
 	// Create transport based on server configuration
 	var transport proxy.Transport
+	var process *exec.Cmd
+	var limiter processLimiter = noLimits{}
 
 	switch serverEntry.Transport {
 	case "stdio":
 		// Spawn subprocess for stdio server
 		bm.logger.Info("spawning stdio subprocess for %s: %s %v", serverEntry.Name, serverEntry.Command, serverEntry.Args)
-		cmd := exec.CommandContext(ctx, serverEntry.Command, serverEntry.Args...)
+		// Not bound to ctx, which only covers initialization; superviseProcess
+		// stops the process when the backends' lifetime ends
+		cmd := exec.Command(serverEntry.Command, serverEntry.Args...)
+
+		if serverEntry.Cwd != "" {
+			dir := expandHome(serverEntry.Cwd)
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("working directory %s of %s is not a directory", dir, serverEntry.Name)
+			}
+			cmd.Dir = dir
+		}
 
-		// Set environment variables
+		// Set environment variables; the server's own env wins over the user's HOME
 		cmd.Env = append([]string{}, os.Environ()...)
+		if serverEntry.User != "" {
+			if err := runAsUser(cmd, serverEntry.User); err != nil {
+				return fmt.Errorf("cannot run %s as user %s: %v", serverEntry.Name, serverEntry.User, err)
+			}
+		}
 		for k, v := range serverEntry.Env {
 			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
 		}
@@ -845,28 +938,30 @@ func (bm *BackendManager) initializeBackend(ctx context.Context, serverEntry *pr
 			return fmt.Errorf("failed to get stdout pipe: %v", err)
 		}
 
+		// stdout carries the protocol; stderr is kept for the dashboard
+		cmd.Stderr = bm.backendLog(serverEntry.Name)
+		cmd.WaitDelay = time.Second
+
+		limiter = newProcessLimiter(serverEntry.Name, serverEntry.Limits)
+		limiter.prepare(cmd)
+
 		// Start the process
 		if err := cmd.Start(); err != nil {
 			bm.logger.Error("failed to start stdio subprocess %s: %v", serverEntry.Name, err)
 			return fmt.Errorf("failed to start subprocess: %v", err)
 		}
+		if err := limiter.apply(cmd.Process); err != nil {
+			bm.logger.Warn("resource limits of %s not fully enforced: %v", serverEntry.Name, err)
+		}
 
 		// Create stdio transport
 		transport = proxy.NewStdioTransport(stdout, stdin)
+		process = cmd
 
 		bm.logger.Info("started stdio subprocess for %s (PID: %d)", serverEntry.Name, cmd.Process.Pid)
 
 	case "http":
-		// Create HTTP transport for this server
-		// Generate session ID for the request; server will confirm in response header
-		sessionID := generateSessionID()
-		httpTransport := proxy.NewHTTPTransport(serverEntry.URL)
-		// Manually set the initial session ID so it's sent on all requests including initialize
-		httpTransport.SetSessionID(sessionID)
-		if serverEntry.Headers != nil {
-			httpTransport.SetHeaders(serverEntry.Headers)
-		}
-		transport = httpTransport
+		transport = newHTTPBackendTransport(serverEntry)
 
 	case "sse":
 		// Create SSE transport for this server
@@ -877,20 +972,60 @@ func (bm *BackendManager) initializeBackend(ctx context.Context, serverEntry *pr
 		// Note: Don't call Connect() yet - SSE connection is established after initialize
 		transport = sseTransport
 
+	case "mock":
+		bm.mu.RLock()
+		dial := bm.dialMock
+		bm.mu.RUnlock()
+		if dial != nil {
+			mock, err := dial(serverEntry)
+			if err != nil {
+				return err
+			}
+			transport = mock
+			break
+		}
+		fixture, err := LoadMockFixture(serverEntry.Fixture)
+		if err != nil {
+			return err
+		}
+		transport = newMockTransport(fixture)
+
 	default:
 		return fmt.Errorf("unsupported transport: %s", serverEntry.Transport)
 	}
 
 	// Initialize connection
+	bm.mu.RLock()
+	recorder := bm.recorder
+	notify := bm.notify
+	bm.mu.RUnlock()
 	conn := &BackendConnection{
 		config:      serverEntry,
 		transport:   transport,
+		process:     process,
+		limiter:     limiter,
 		logger:      bm.logger,
+		recorder:    recorder,
 		initialized: false,
+		idle:        idleSessions(serverEntry.PoolSize(), transport),
+	}
+	name := serverEntry.Name
+	if notify != nil {
+		conn.notify = func(method string, params json.RawMessage) { notify(name, method, params) }
+	}
+	if bm.ChaosEnabled() {
+		conn.chaos = func() (ChaosFault, bool) { return bm.chaosFault(name) }
 	}
 
 	// Send initialize request to backend
 	if err := conn.initialize(ctx); err != nil {
+		if process != nil {
+			// Wait for the process so all of its stderr is captured
+			process.Process.Kill()
+			process.Wait()
+			limiter.release()
+			err = fmt.Errorf("%v%s", err, stderrTail(bm.BackendLogs(serverEntry.Name, backendLogTailLines)))
+		}
 		if bm.trace != nil {
 			bm.trace.Add(proxy.TraceEvent{
 				Stage:     "translate",
@@ -898,10 +1033,12 @@ func (bm *BackendManager) initializeBackend(ctx context.Context, serverEntry *pr
 				Method:    "initialize",
 				Transport: serverEntry.Transport,
 				Detail:    fmt.Sprintf("init failed: %v", err),
+				Error:     true,
 			})
 		}
 		return fmt.Errorf("backend initialization failed: %v", err)
 	}
+	conn.openPool(ctx)
 
 	// Get tools from backend
 	if err := conn.getTools(ctx); err != nil {
@@ -915,8 +1052,13 @@ func (bm *BackendManager) initializeBackend(ctx context.Context, serverEntry *pr
 	}
 
 	// Persist discovered tools to file for dashboard access
-	if err := bm.toolRegistry.SaveToFile(); err != nil {
-		bm.logger.Debug("failed to persist discovered tools: %v", err)
+	bm.mu.RLock()
+	isolated := bm.isolated
+	bm.mu.RUnlock()
+	if !isolated {
+		if err := bm.toolRegistry.SaveToFile(); err != nil {
+			bm.logger.Debug("failed to persist discovered tools: %v", err)
+		}
 	}
 
 	if bm.trace != nil {
@@ -932,8 +1074,15 @@ func (bm *BackendManager) initializeBackend(ctx context.Context, serverEntry *pr
 	// Store connection
 	bm.mu.Lock()
 	bm.connections[serverEntry.Name] = conn
+	lifetime := bm.runCtx
 	bm.mu.Unlock()
 
+	if process != nil {
+		if lifetime == nil {
+			lifetime = context.Background()
+		}
+		go bm.superviseProcess(lifetime, *serverEntry, conn, process)
+	}
 	return nil
 }
 
@@ -951,8 +1100,42 @@ func (bm *BackendManager) GetInitializedBackends() []*BackendConnection {
 	return backends
 }
 
-// CallTool sends a tool call request to a backend server.
-func (bm *BackendManager) CallTool(ctx context.Context, backendID string, toolName string, arguments json.RawMessage) (interface{}, error) {
+// Summary returns the number of initialized backends answering pings and the number configured.
+func (bm *BackendManager) Summary() (ready, total int) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	for _, conn := range bm.connections {
+		if conn.initialized && !conn.circuitOpen() {
+			ready++
+		}
+	}
+	if bm.registry != nil {
+		total = len(bm.registry.Servers)
+	}
+	if ready > total {
+		total = ready
+	}
+	return ready, total
+}
+
+// Subprocesses counts the stdio backends whose subprocess is running.
+func (bm *BackendManager) Subprocesses() int {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	n := 0
+	for _, conn := range bm.connections {
+		if conn.process != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// getConnection returns the backend's connection, or an error if it isn't
+// connected or its circuit is open after missed pings.
+func (bm *BackendManager) getConnection(backendID string) (*BackendConnection, error) {
 	bm.mu.RLock()
 	conn, exists := bm.connections[backendID]
 	bm.mu.RUnlock()
@@ -960,6 +1143,32 @@ func (bm *BackendManager) CallTool(ctx context.Context, backendID string, toolNa
 	if !exists {
 		return nil, fmt.Errorf("backend not found: %s", backendID)
 	}
+	if conn.circuitOpen() {
+		return nil, fmt.Errorf("backend %s is unavailable: no response to the last %d pings", backendID, maxMissedPings)
+	}
+	return conn, nil
+}
+
+// ProtocolVersion returns the protocol version a backend negotiated, or ""
+// if it isn't connected or didn't say.
+func (bm *BackendManager) ProtocolVersion(backendID string) string {
+	bm.mu.RLock()
+	conn, exists := bm.connections[backendID]
+	bm.mu.RUnlock()
+	if !exists {
+		return ""
+	}
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	return conn.version
+}
+
+// CallTool sends a tool call request to a backend server.
+func (bm *BackendManager) CallTool(ctx context.Context, backendID string, toolName string, arguments json.RawMessage) (interface{}, error) {
+	conn, err := bm.getConnection(backendID)
+	if err != nil {
+		return nil, err
+	}
 
 	if bm.trace != nil {
 		bm.trace.Add(proxy.TraceEvent{
@@ -968,6 +1177,7 @@ func (bm *BackendManager) CallTool(ctx context.Context, backendID string, toolNa
 			Method:    "tools/call",
 			Transport: conn.config.Transport,
 			Detail:    fmt.Sprintf("calling %s", toolName),
+			RequestID: RequestIDFrom(ctx),
 		})
 	}
 
@@ -983,18 +1193,70 @@ func (bm *BackendManager) CallTool(ctx context.Context, backendID string, toolNa
 			Method:    "tools/call",
 			Transport: conn.config.Transport,
 			Detail:    fmt.Sprintf("completed %s", status),
+			Error:     err != nil,
+			RequestID: RequestIDFrom(ctx),
 		})
 	}
 
 	return result, err
 }
 
+// newHTTPBackendTransport creates a session with an http backend.
+func newHTTPBackendTransport(serverEntry *proxy.ServerEntry) *proxy.HTTPTransport {
+	// Generate session ID for the request; server will confirm in response header
+	sessionID := generateSessionID()
+	httpTransport := proxy.NewHTTPTransport(serverEntry.URL)
+	// Manually set the initial session ID so it's sent on all requests including initialize
+	httpTransport.SetSessionID(sessionID)
+	if serverEntry.Headers != nil {
+		httpTransport.SetHeaders(serverEntry.Headers)
+	}
+	return httpTransport
+}
+
+// idleSessions returns the queue of idle sessions of a connection holding up
+// to size sessions, starting with transports.
+func idleSessions(size int, transports ...proxy.Transport) chan proxy.Transport {
+	idle := make(chan proxy.Transport, size)
+	for _, transport := range transports {
+		idle <- transport
+	}
+	return idle
+}
+
 // BackendConnection methods
 
+// initResponse is a backend's answer to initialize.
+type initResponse struct {
+	Result struct {
+		Capabilities proxy.Capabilities `json:"capabilities"`
+		ServerInfo   struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+		ProtocolVersion string `json:"protocolVersion"`
+	} `json:"result"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func parseInitResponse(respBytes []byte) (*initResponse, error) {
+	var initResp initResponse
+	if err := json.Unmarshal(respBytes, &initResp); err != nil {
+		return nil, fmt.Errorf("failed to parse initialize response: %v", err)
+	}
+	if initResp.Error != nil {
+		return nil, fmt.Errorf("backend returned error: %s", initResp.Error.Message)
+	}
+	return &initResp, nil
+}
+
 // initialize sends an initialize request to the backend server.
 func (bc *BackendConnection) initialize(ctx context.Context) error {
 	// Build initialize request
-	initReq := proxy.NewInitRequest("mcp-go-proxy", "1.0.16")
+	initReq := proxy.NewInitRequest("mcp-go-proxy", proxy.Version)
 
 	// Send request to backend and get response
 	respBytes, err := bc.sendRequest(ctx, initReq)
@@ -1002,32 +1264,13 @@ func (bc *BackendConnection) initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to send initialize request: %v", err)
 	}
 
-	// Parse response
-	var initResp struct {
-		Result struct {
-			Capabilities proxy.Capabilities `json:"capabilities"`
-			ServerInfo   struct {
-				Name    string `json:"name"`
-				Version string `json:"version"`
-			} `json:"serverInfo"`
-			ProtocolVersion string `json:"protocolVersion"`
-		} `json:"result"`
-		Error *struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-
-	if err := json.Unmarshal(respBytes, &initResp); err != nil {
-		return fmt.Errorf("failed to parse initialize response: %v", err)
-	}
-
-	if initResp.Error != nil {
-		return fmt.Errorf("backend returned error: %s", initResp.Error.Message)
+	initResp, err := parseInitResponse(respBytes)
+	if err != nil {
+		return err
 	}
 
-	// Validate protocol version
-	if err := proxy.ValidateProtocolVersion(initResp.Result.ProtocolVersion, proxy.MCPProtocolVersion); err != nil {
+	// The backend may answer with an older version than we asked for
+	if err := proxy.ValidateProtocolVersion(proxy.MCPProtocolVersion, initResp.Result.ProtocolVersion); err != nil {
 		bc.logger.Warn("protocol version mismatch: %v", err)
 		// Continue anyway - some servers might not enforce this strictly
 	}
@@ -1035,10 +1278,15 @@ func (bc *BackendConnection) initialize(ctx context.Context) error {
 	// Set state under lock, but run SSE connect afterward to avoid double unlock
 	bc.mu.Lock()
 	bc.Capabilities = &initResp.Result.Capabilities
+	bc.version = initResp.Result.ProtocolVersion
 	bc.initialized = true
 	transport := bc.transport
 	bc.mu.Unlock()
 
+	if vt, ok := transport.(proxy.VersionedTransport); ok && initResp.Result.ProtocolVersion != "" {
+		vt.SetProtocolVersion(initResp.Result.ProtocolVersion)
+	}
+
 	bc.logger.Debug("backend initialized: %s v%s", initResp.Result.ServerInfo.Name, initResp.Result.ServerInfo.Version)
 
 	// For SSE transport, establish the event stream after initialization
@@ -1051,49 +1299,188 @@ func (bc *BackendConnection) initialize(ctx context.Context) error {
 	return nil
 }
 
-// getTools retrieves the list of available tools from the backend.
-func (bc *BackendConnection) getTools(ctx context.Context) error {
-	bc.mu.Lock()
-	defer bc.mu.Unlock()
+// openPool opens the extra sessions of a pooled http backend, each with its
+// own initialize handshake, so that many requests can be in flight at once.
+// Sessions that fail to open are left out of the pool. A hedged backend also
+// gets a session to its replica.
+func (bc *BackendConnection) openPool(ctx context.Context) {
+	size := bc.config.PoolSize()
+	for i := 1; i < size; i++ {
+		transport, err := bc.openSession(ctx, bc.config)
+		if err != nil {
+			bc.logger.Warn("failed to open session %d of %d to %s: %v", i+1, size, bc.config.Name, err)
+			continue
+		}
 
-	if !bc.initialized {
-		return fmt.Errorf("backend not initialized")
+		bc.mu.Lock()
+		bc.pool = append(bc.pool, transport)
+		bc.mu.Unlock()
+		bc.idle <- transport
+	}
+	if size > 1 {
+		bc.logger.Info("opened %d session(s) to %s", 1+len(bc.pool), bc.config.Name)
 	}
 
-	// Build tools/list request
-	toolsListReq := JSONRPCRequest{
+	if bc.config.Hedge != nil {
+		replica := *bc.config
+		replica.URL = bc.config.Hedge.URL
+		transport, err := bc.openSession(ctx, &replica)
+		if err != nil {
+			bc.logger.Warn("failed to open session to the replica of %s, not hedging: %v", bc.config.Name, err)
+			return
+		}
+		bc.mu.Lock()
+		bc.replicaConn = transport
+		bc.replica = idleSessions(1, transport)
+		bc.mu.Unlock()
+	}
+}
+
+// openSession opens an initialized session to an http backend.
+func (bc *BackendConnection) openSession(ctx context.Context, entry *proxy.ServerEntry) (*proxy.HTTPTransport, error) {
+	transport := newHTTPBackendTransport(entry)
+	reqBytes, _ := json.Marshal(proxy.NewInitRequest("mcp-go-proxy", proxy.Version))
+	respBytes, err := bc.exchange(ctx, transport, reqBytes, func() {})
+	if err == nil {
+		var initResp *initResponse
+		if initResp, err = parseInitResponse(respBytes); err == nil && initResp.Result.ProtocolVersion != "" {
+			transport.SetProtocolVersion(initResp.Result.ProtocolVersion)
+		}
+	}
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	return transport, nil
+}
+
+// closeTransports closes the backend's sessions.
+func (bc *BackendConnection) closeTransports() {
+	bc.mu.RLock()
+	transports := append([]proxy.Transport{bc.transport, bc.replicaConn}, bc.pool...)
+	bc.mu.RUnlock()
+	for _, transport := range transports {
+		if transport != nil {
+			transport.Close()
+		}
+	}
+}
+
+// maxListPages bounds how many pages are read from one backend in one go, in
+// case it keeps handing out cursors.
+const maxListPages = 100
+
+// listParams returns list request params for cursor (empty for the first page).
+func listParams(cursor string) json.RawMessage {
+	if cursor == "" {
+		return json.RawMessage(`{}`)
+	}
+	data, _ := json.Marshal(map[string]string{"cursor": cursor})
+	return data
+}
+
+// listPage calls a paginated list method and returns the items under key
+// and the cursor of the next page, empty on the last page.
+func (bc *BackendConnection) listPage(ctx context.Context, method, key, cursor string) ([]interface{}, string, error) {
+	req := JSONRPCRequest{
 		JSONRPC: "2.0",
 		ID:      1,
-		Method:  "tools/list",
-		Params:  json.RawMessage(`{}`),
+		Method:  method,
+		Params:  listParams(cursor),
 	}
 
-	// Send request
-	respBytes, err := bc.sendRequestLocked(ctx, toolsListReq)
+	respBytes, err := bc.sendRequest(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to get tools: %v", err)
+		return nil, "", err
 	}
 
-	// Parse response
-	var toolsResp struct {
-		Result struct {
-			Tools []Tool `json:"tools"`
-		} `json:"result"`
-		Error *struct {
-			Code    int    `json:"code"`
+	var resp struct {
+		Result map[string]json.RawMessage `json:"result"`
+		Error  *struct {
 			Message string `json:"message"`
 		} `json:"error"`
 	}
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s response: %w", method, err)
+	}
+	if resp.Error != nil {
+		return nil, "", fmt.Errorf("backend error: %s", resp.Error.Message)
+	}
 
-	if err := json.Unmarshal(respBytes, &toolsResp); err != nil {
-		return fmt.Errorf("failed to parse tools/list response for %s: %v", bc.config.Name, err)
+	var items []interface{}
+	if raw, ok := resp.Result[key]; ok {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, "", fmt.Errorf("failed to parse %s response: %w", method, err)
+		}
 	}
+	var next string
+	if raw, ok := resp.Result["nextCursor"]; ok {
+		json.Unmarshal(raw, &next)
+	}
+	if next == cursor {
+		next = "" // a backend repeating its cursor would loop forever
+	}
+	return items, next, nil
+}
+
+// getTools retrieves the list of available tools from the backend.
+func (bc *BackendConnection) getTools(ctx context.Context) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if !bc.initialized {
+		return fmt.Errorf("backend not initialized")
+	}
+
+	// The tool registry needs every tool, so read all pages
+	var tools []Tool
+	cursor := ""
+	for page := 0; ; page++ {
+		if page == maxListPages {
+			bc.logger.Warn("backend %s returned more than %d pages of tools, ignoring the rest", bc.config.Name, maxListPages)
+			break
+		}
+		toolsListReq := JSONRPCRequest{
+			JSONRPC: "2.0",
+			ID:      1,
+			Method:  "tools/list",
+			Params:  listParams(cursor),
+		}
+
+		// Send request
+		respBytes, err := bc.sendRequestLocked(ctx, toolsListReq)
+		if err != nil {
+			return fmt.Errorf("failed to get tools: %v", err)
+		}
 
-	if toolsResp.Error != nil {
-		return fmt.Errorf("backend %s returned error: %s", bc.config.Name, toolsResp.Error.Message)
+		// Parse response
+		var toolsResp struct {
+			Result struct {
+				Tools      []Tool `json:"tools"`
+				NextCursor string `json:"nextCursor"`
+			} `json:"result"`
+			Error *struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+
+		if err := json.Unmarshal(respBytes, &toolsResp); err != nil {
+			return fmt.Errorf("failed to parse tools/list response for %s: %v", bc.config.Name, err)
+		}
+
+		if toolsResp.Error != nil {
+			return fmt.Errorf("backend %s returned error: %s", bc.config.Name, toolsResp.Error.Message)
+		}
+
+		tools = append(tools, toolsResp.Result.Tools...)
+		if toolsResp.Result.NextCursor == "" || toolsResp.Result.NextCursor == cursor {
+			break
+		}
+		cursor = toolsResp.Result.NextCursor
 	}
 
-	bc.tools = toolsResp.Result.Tools
+	bc.tools = tools
 	bc.logger.Info("backend %s registered %d tool(s)", bc.config.Name, len(bc.tools))
 	return nil
 }
@@ -1149,13 +1536,14 @@ func (bc *BackendConnection) callTool(ctx context.Context, toolName string, argu
 	return toolResp.Result, nil
 }
 
-// sendRequest sends a request to the backend. MUST be called WITHOUT the lock held.
+// sendRequest sends a request to the backend on one of its idle sessions.
+// MUST be called WITHOUT the lock held.
 func (bc *BackendConnection) sendRequest(ctx context.Context, req interface{}) ([]byte, error) {
 	bc.mu.RLock()
-	transport := bc.transport
+	idle := bc.idle
 	bc.mu.RUnlock()
 
-	if transport == nil {
+	if idle == nil {
 		return nil, fmt.Errorf("transport not initialized")
 	}
 
@@ -1165,15 +1553,59 @@ func (bc *BackendConnection) sendRequest(ctx context.Context, req interface{}) (
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	// Add newline for JSON-RPC line protocol
-	reqWithNewline := append(reqBytes, '\n')
+	var retry *proxy.RetryPolicy
+	hedge := bc.idempotent(reqBytes)
+	if hedge {
+		retry = bc.config.Retry
+	}
+	for attempt := 0; ; attempt++ {
+		// Wait for a session's previous request to be answered; a timed-out
+		// request keeps its session until its response arrives so it can't be
+		// read as ours
+		var transport proxy.Transport
+		select {
+		case transport = <-idle:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("request cancelled: %v", ctx.Err())
+		}
+		release := func() { idle <- transport }
+		var respBytes []byte
+		var err error
+		if hedge {
+			respBytes, err = bc.hedgedExchange(ctx, transport, reqBytes, release)
+		} else {
+			respBytes, err = bc.exchange(ctx, transport, reqBytes, release)
+		}
+		if err == nil || retry == nil || attempt >= retry.MaxAttempts() || !retryable(err, retry) {
+			return respBytes, err
+		}
+		if err := bc.waitRetry(ctx, retry, attempt, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// exchange sends a request on a session and waits for its response. release
+// is called once the session is free again, which may be after ctx ends.
+func (bc *BackendConnection) exchange(ctx context.Context, transport proxy.Transport, reqBytes []byte, release func()) ([]byte, error) {
+	if err := bc.injectChaos(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	// Add newline for JSON-RPC line protocol. Hedged sends share reqBytes,
+	// so frame a copy rather than appending into its backing array.
+	reqWithNewline := make([]byte, 0, len(reqBytes)+1)
+	reqWithNewline = append(append(reqWithNewline, reqBytes...), '\n')
 
 	bc.logger.Debug("sending request to backend: %s", string(reqBytes))
+	bc.recorder.Record(proxy.DirProxyToBackend, bc.config.Name, reqBytes)
 
 	// Send request
 	if err := transport.SendMessage(reqWithNewline); err != nil {
+		release()
 		bc.logger.Error("failed to send request: %v", err)
-		return nil, fmt.Errorf("failed to send request: %v", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
 	bc.logger.Debug("request sent, waiting for response")
@@ -1186,8 +1618,22 @@ func (bc *BackendConnection) sendRequest(ctx context.Context, req interface{}) (
 	respCh := make(chan response, 1)
 
 	go func() {
-		respBytes, err := transport.ReceiveMessage()
-		respCh <- response{respBytes, err}
+		defer release()
+		for {
+			respBytes, err := transport.ReceiveMessage()
+			if err == nil {
+				// Notifications may arrive ahead of the response
+				if method, params, ok := parseNotification(respBytes); ok {
+					bc.recorder.Record(proxy.DirBackendToProxy, bc.config.Name, respBytes)
+					if bc.notify != nil && !bc.dropNotification() {
+						bc.notify(method, params)
+					}
+					continue
+				}
+			}
+			respCh <- response{respBytes, err}
+			return
+		}
 	}()
 
 	// Wait for response with timeout
@@ -1198,6 +1644,7 @@ func (bc *BackendConnection) sendRequest(ctx context.Context, req interface{}) (
 			return nil, fmt.Errorf("failed to receive response: %v", resp.err)
 		}
 		bc.logger.Debug("received response from backend: %s", string(resp.data))
+		bc.recorder.Record(proxy.DirBackendToProxy, bc.config.Name, resp.data)
 		return resp.data, nil
 
 	case <-ctx.Done():
@@ -1226,56 +1673,21 @@ func generateSessionID() string {
 	return sessionID
 }
 
-// ListResources calls resources/list on a backend and returns the list of resources
-func (bm *BackendManager) ListResources(ctx context.Context, backendID string) ([]interface{}, error) {
-	bm.mu.RLock()
-	conn, exists := bm.connections[backendID]
-	bm.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("backend not found: %s", backendID)
-	}
-
-	req := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  "resources/list",
-		"params":  map[string]interface{}{},
-	}
-
-	respBytes, err := conn.sendRequest(ctx, req)
+// ListResources returns one page of a backend's resources, starting at
+// cursor (empty for the first), and the cursor of the next page if any.
+func (bm *BackendManager) ListResources(ctx context.Context, backendID, cursor string) ([]interface{}, string, error) {
+	conn, err := bm.getConnection(backendID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	var resp struct {
-		Result struct {
-			Resources []interface{} `json:"resources"`
-		} `json:"result"`
-		Error *struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-
-	if err := json.Unmarshal(respBytes, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse resources/list response: %w", err)
-	}
-
-	if resp.Error != nil {
-		return nil, fmt.Errorf("backend error: %s", resp.Error.Message)
-	}
-
-	return resp.Result.Resources, nil
+	return conn.listPage(ctx, "resources/list", "resources", cursor)
 }
 
 // ReadResource calls resources/read on a backend
 func (bm *BackendManager) ReadResource(ctx context.Context, backendID, uri string) (interface{}, error) {
-	bm.mu.RLock()
-	conn, exists := bm.connections[backendID]
-	bm.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("backend not found: %s", backendID)
+	conn, err := bm.getConnection(backendID)
+	if err != nil {
+		return nil, err
 	}
 
 	req := map[string]interface{}{
@@ -1312,56 +1724,20 @@ func (bm *BackendManager) ReadResource(ctx context.Context, backendID, uri strin
 	return resp.Result.Contents, nil
 }
 
-// ListResourceTemplates calls resources/templates/list on a backend
-func (bm *BackendManager) ListResourceTemplates(ctx context.Context, backendID string) ([]interface{}, error) {
-	bm.mu.RLock()
-	conn, exists := bm.connections[backendID]
-	bm.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("backend not found: %s", backendID)
-	}
-
-	req := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  "resources/templates/list",
-		"params":  map[string]interface{}{},
-	}
-
-	respBytes, err := conn.sendRequest(ctx, req)
+// ListResourceTemplates returns one page of a backend's resource templates.
+func (bm *BackendManager) ListResourceTemplates(ctx context.Context, backendID, cursor string) ([]interface{}, string, error) {
+	conn, err := bm.getConnection(backendID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	var resp struct {
-		Result struct {
-			ResourceTemplates []interface{} `json:"resourceTemplates"`
-		} `json:"result"`
-		Error *struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-
-	if err := json.Unmarshal(respBytes, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse resources/templates/list response: %w", err)
-	}
-
-	if resp.Error != nil {
-		return nil, fmt.Errorf("backend error: %s", resp.Error.Message)
-	}
-
-	return resp.Result.ResourceTemplates, nil
+	return conn.listPage(ctx, "resources/templates/list", "resourceTemplates", cursor)
 }
 
 // SubscribeToResource calls resources/subscribe on a backend
 func (bm *BackendManager) SubscribeToResource(ctx context.Context, backendID, uri string) error {
-	bm.mu.RLock()
-	conn, exists := bm.connections[backendID]
-	bm.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("backend not found: %s", backendID)
+	conn, err := bm.getConnection(backendID)
+	if err != nil {
+		return err
 	}
 
 	req := map[string]interface{}{
@@ -1397,12 +1773,9 @@ func (bm *BackendManager) SubscribeToResource(ctx context.Context, backendID, ur
 
 // UnsubscribeFromResource calls resources/unsubscribe on a backend
 func (bm *BackendManager) UnsubscribeFromResource(ctx context.Context, backendID, uri string) error {
-	bm.mu.RLock()
-	conn, exists := bm.connections[backendID]
-	bm.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("backend not found: %s", backendID)
+	conn, err := bm.getConnection(backendID)
+	if err != nil {
+		return err
 	}
 
 	req := map[string]interface{}{
@@ -1436,56 +1809,20 @@ func (bm *BackendManager) UnsubscribeFromResource(ctx context.Context, backendID
 	return nil
 }
 
-// ListPrompts calls prompts/list on a backend
-func (bm *BackendManager) ListPrompts(ctx context.Context, backendID string) ([]interface{}, error) {
-	bm.mu.RLock()
-	conn, exists := bm.connections[backendID]
-	bm.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("backend not found: %s", backendID)
-	}
-
-	req := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  "prompts/list",
-		"params":  map[string]interface{}{},
-	}
-
-	respBytes, err := conn.sendRequest(ctx, req)
+// ListPrompts returns one page of a backend's prompts.
+func (bm *BackendManager) ListPrompts(ctx context.Context, backendID, cursor string) ([]interface{}, string, error) {
+	conn, err := bm.getConnection(backendID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	var resp struct {
-		Result struct {
-			Prompts []interface{} `json:"prompts"`
-		} `json:"result"`
-		Error *struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-
-	if err := json.Unmarshal(respBytes, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse prompts/list response: %w", err)
-	}
-
-	if resp.Error != nil {
-		return nil, fmt.Errorf("backend error: %s", resp.Error.Message)
-	}
-
-	return resp.Result.Prompts, nil
+	return conn.listPage(ctx, "prompts/list", "prompts", cursor)
 }
 
 // GetPrompt calls prompts/get on a backend
 func (bm *BackendManager) GetPrompt(ctx context.Context, backendID, name string, arguments map[string]interface{}) (interface{}, error) {
-	bm.mu.RLock()
-	conn, exists := bm.connections[backendID]
-	bm.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("backend not found: %s", backendID)
+	conn, err := bm.getConnection(backendID)
+	if err != nil {
+		return nil, err
 	}
 
 	req := map[string]interface{}{
@@ -1521,46 +1858,49 @@ func (bm *BackendManager) GetPrompt(ctx context.Context, backendID, name string,
 	return resp.Result, nil
 }
 
-// GetCompletion calls completion/complete on a backend
-func (bm *BackendManager) GetCompletion(ctx context.Context, backendID, ref string, argument, metadata interface{}) (interface{}, error) {
-	bm.mu.RLock()
-	conn, exists := bm.connections[backendID]
-	bm.mu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("backend not found: %s", backendID)
+// GetCompletion calls completion/complete on a backend. ref must already be
+// in the backend's own namespace.
+func (bm *BackendManager) GetCompletion(ctx context.Context, backendID string, ref CompletionRef, argument, completionContext interface{}) (Completion, error) {
+	conn, err := bm.getConnection(backendID)
+	if err != nil {
+		return Completion{}, err
 	}
 
+	params := map[string]interface{}{
+		"ref":      ref,
+		"argument": argument,
+	}
+	if completionContext != nil {
+		params["context"] = completionContext
+	}
 	req := map[string]interface{}{
 		"jsonrpc": "2.0",
 		"id":      1,
 		"method":  "completion/complete",
-		"params": map[string]interface{}{
-			"ref":      ref,
-			"argument": argument,
-			"_meta":    metadata,
-		},
+		"params":  params,
 	}
 
 	respBytes, err := conn.sendRequest(ctx, req)
 	if err != nil {
-		return nil, err
+		return Completion{}, err
 	}
 
 	var resp struct {
-		Result interface{} `json:"result"`
-		Error  *struct {
+		Result struct {
+			Completion Completion `json:"completion"`
+		} `json:"result"`
+		Error *struct {
 			Message string `json:"message"`
 		} `json:"error"`
 	}
 
 	if err := json.Unmarshal(respBytes, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse completion/complete response: %w", err)
+		return Completion{}, fmt.Errorf("failed to parse completion/complete response: %w", err)
 	}
 
 	if resp.Error != nil {
-		return nil, fmt.Errorf("backend error: %s", resp.Error.Message)
+		return Completion{}, fmt.Errorf("backend error: %s", resp.Error.Message)
 	}
 
-	return resp.Result, nil
+	return resp.Result.Completion, nil
 }
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestHTTPBackendPool tests that a pooled http backend gets a session per
+// pool slot, each initialized, and that calls are spread across them
+func TestHTTPBackendPool(t *testing.T) {
+	var mu sync.Mutex
+	initialized := map[string]bool{}
+	inflight, peak := 0, 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		session := r.Header.Get(proxy.HeaderSessionID)
+
+		var result interface{} = map[string]interface{}{}
+		switch req.Method {
+		case "initialize":
+			mu.Lock()
+			initialized[session] = true
+			mu.Unlock()
+			result = map[string]interface{}{"protocolVersion": proxy.MCPProtocolVersion, "capabilities": map[string]interface{}{}}
+		case "tools/list":
+			result = map[string]interface{}{"tools": []interface{}{}}
+		case "tools/call":
+			mu.Lock()
+			if !initialized[session] {
+				t.Errorf("call on uninitialized session %q", session)
+			}
+			inflight++
+			if inflight > peak {
+				peak = inflight
+			}
+			mu.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			inflight--
+			mu.Unlock()
+			result = map[string]interface{}{"content": []interface{}{}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer upstream.Close()
+
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "remote", Transport: "http", URL: upstream.URL, Pool: 3}}}
+	bm := NewBackendManager(registry, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	bm.isolated = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := bm.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	mu.Lock()
+	sessions := len(initialized)
+	mu.Unlock()
+	if sessions != 3 {
+		t.Fatalf("expected 3 initialized sessions, got %d", sessions)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := bm.CallTool(ctx, "remote", "slow", json.RawMessage(`{}`)); err != nil {
+				t.Errorf("call failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak != 3 {
+		t.Errorf("expected 3 calls in flight at once, got %d", peak)
+	}
+}
+
+// TestHTTPBackendRetry tests that idempotent requests are retried on a
+// transient upstream error and mutating calls are not
+func TestHTTPBackendRetry(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     interface{} `json:"id"`
+			Method string      `json:"method"`
+			Params struct {
+				Name string `json:"name"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result interface{} = map[string]interface{}{}
+		switch req.Method {
+		case "initialize":
+			result = map[string]interface{}{"protocolVersion": proxy.MCPProtocolVersion, "capabilities": map[string]interface{}{}}
+		case "tools/list":
+			result = map[string]interface{}{"tools": []interface{}{}}
+		case "tools/call":
+			// Every tool fails its first call
+			mu.Lock()
+			attempts[req.Params.Name]++
+			first := attempts[req.Params.Name] == 1
+			mu.Unlock()
+			if first {
+				http.Error(w, "upstream restarting", http.StatusServiceUnavailable)
+				return
+			}
+			result = map[string]interface{}{"content": []interface{}{}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer upstream.Close()
+
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{
+		Name: "remote", Transport: "http", URL: upstream.URL,
+		Retry: &proxy.RetryPolicy{Backoff: "1ms", IdempotentTools: []string{"search*"}},
+	}}}
+	bm := NewBackendManager(registry, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	bm.isolated = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := bm.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	for _, tool := range []string{"list_issues", "searchCode"} {
+		if _, err := bm.CallTool(ctx, "remote", tool, json.RawMessage(`{}`)); err != nil {
+			t.Errorf("expected %s to be retried, got %v", tool, err)
+		}
+	}
+	for _, tool := range []string{"create_issue", "get_or_create_user"} {
+		if _, err := bm.CallTool(ctx, "remote", tool, json.RawMessage(`{}`)); err == nil {
+			t.Errorf("expected %s to fail without a retry", tool)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]int{"list_issues": 2, "searchCode": 2, "create_issue": 1, "get_or_create_user": 1}
+	for tool, n := range want {
+		if attempts[tool] != n {
+			t.Errorf("expected %d attempt(s) of %s, got %d", n, tool, attempts[tool])
+		}
+	}
+}
+
+// TestHTTPBackendHedge tests that a slow read-only call is hedged to the
+// replica and a mutating call is not
+func TestHTTPBackendHedge(t *testing.T) {
+	var mu sync.Mutex
+	replicaCalls := map[string]int{}
+	upstream := func(name string, delay time.Duration) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				ID     interface{} `json:"id"`
+				Method string      `json:"method"`
+				Params struct {
+					Name string `json:"name"`
+				} `json:"params"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+
+			var result interface{} = map[string]interface{}{}
+			switch req.Method {
+			case "initialize":
+				result = map[string]interface{}{"protocolVersion": proxy.MCPProtocolVersion, "capabilities": map[string]interface{}{}}
+			case "tools/list":
+				result = map[string]interface{}{"tools": []interface{}{}}
+			case "tools/call":
+				if name == "replica" {
+					mu.Lock()
+					replicaCalls[req.Params.Name]++
+					mu.Unlock()
+				}
+				time.Sleep(delay)
+				result = map[string]interface{}{"served_by": name}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		}))
+	}
+	primary, replica := upstream("primary", 300*time.Millisecond), upstream("replica", 0)
+	defer primary.Close()
+	defer replica.Close()
+
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{
+		Name: "remote", Transport: "http", URL: primary.URL,
+		Hedge: &proxy.HedgePolicy{URL: replica.URL, After: "20ms"},
+	}}}
+	bm := NewBackendManager(registry, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	bm.isolated = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := bm.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	servedBy := func(tool string) string {
+		result, err := bm.CallTool(ctx, "remote", tool, json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatalf("call to %s failed: %v", tool, err)
+		}
+		m, _ := result.(map[string]interface{})
+		return fmt.Sprint(m["served_by"])
+	}
+
+	if got := servedBy("list_issues"); got != "replica" {
+		t.Errorf("expected the slow read to be answered by the replica, got %s", got)
+	}
+	if got := servedBy("create_issue"); got != "primary" {
+		t.Errorf("expected the write to be answered by the primary, got %s", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if replicaCalls["create_issue"] != 0 {
+		t.Errorf("expected the write not to reach the replica, got %d call(s)", replicaCalls["create_issue"])
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// NotificationHandler receives a notification a backend sent the proxy.
+type NotificationHandler func(backendID, method string, params json.RawMessage)
+
+// SetNotificationHandler passes notifications from backends initialized
+// afterwards to handle. Backends have no stream of their own to the proxy:
+// a notification is read when it arrives ahead of the response to a request,
+// keepalive pings included, so it reaches handle by the next request or ping
+// at the latest.
+func (bm *BackendManager) SetNotificationHandler(handle NotificationHandler) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.notify = handle
+}
+
+// parseNotification returns the method and params of a JSON-RPC
+// notification; ok is false for requests and responses.
+func parseNotification(data []byte) (method string, params json.RawMessage, ok bool) {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Method == "" || msg.ID != nil {
+		return "", nil, false
+	}
+	return msg.Method, msg.Params, true
+}
+
+// BackendCapabilities returns the capabilities a backend answered initialize
+// with, or nil if it isn't initialized.
+func (bm *BackendManager) BackendCapabilities(backendID string) *proxy.Capabilities {
+	conn, err := bm.getConnection(backendID)
+	if err != nil {
+		return nil
+	}
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if !conn.initialized {
+		return nil
+	}
+	return conn.Capabilities
+}
+
+// anyBackend reports whether an initialized backend has a capability.
+func (bm *BackendManager) anyBackend(has func(*proxy.Capabilities) bool) bool {
+	bm.mu.RLock()
+	conns := make([]*BackendConnection, 0, len(bm.connections))
+	for _, conn := range bm.connections {
+		conns = append(conns, conn)
+	}
+	bm.mu.RUnlock()
+
+	for _, conn := range conns {
+		conn.mu.RLock()
+		ok := conn.initialized && has(conn.Capabilities)
+		conn.mu.RUnlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func supportsSubscribe(caps *proxy.Capabilities) bool {
+	return caps != nil && caps.Resources != nil && caps.Resources.Subscribe
+}
+
+func supportsPromptsListChanged(caps *proxy.Capabilities) bool {
+	return caps != nil && caps.Prompts != nil && caps.Prompts.ListChanged
+}
+
+// relayNotification passes a backend's notification on to the client if the
+// proxy advertised the capability it belongs to: resource updates for
+// subscribed resources, under their armour:// URI, and prompt list changes.
+// Anything else is dropped; the proxy announces tool list changes itself.
+func (s *StdioServer) relayNotification(backendID, method string, params json.RawMessage) {
+	s.mu.RLock()
+	caps := s.serverCaps
+	s.mu.RUnlock()
+
+	switch method {
+	case "notifications/resources/updated":
+		if !supportsSubscribe(caps) {
+			return
+		}
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			s.logger.Warn("invalid resources/updated notification from %s: %v", backendID, err)
+			return
+		}
+		uri := armourURI(backendID, p.URI)
+		s.mu.RLock()
+		subscribed := s.subscriptions[uri]
+		s.mu.RUnlock()
+		if !subscribed {
+			return
+		}
+		if err := s.sendNotification(method, map[string]string{"uri": uri}); err != nil {
+			s.logger.Warn("failed to relay %s: %v", method, err)
+		}
+	case "notifications/prompts/list_changed":
+		if !supportsPromptsListChanged(caps) {
+			return
+		}
+		if err := s.sendNotification(method, nil); err != nil {
+			s.logger.Warn("failed to relay %s: %v", method, err)
+		}
+	default:
+		s.logger.Debug("dropping %s from backend %s", method, backendID)
+	}
+}
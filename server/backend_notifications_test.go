@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// startCapabilityServer initializes a stdio server over mock backends built
+// from the given fixtures and returns its initialize result.
+func startCapabilityServer(t *testing.T, ctx context.Context, fixtures map[string]string) (*StdioServer, *bytes.Buffer, proxy.Capabilities) {
+	t.Helper()
+	dir := t.TempDir()
+	registry := &proxy.ServerRegistry{}
+	for name, content := range fixtures {
+		path := filepath.Join(dir, name+".yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+		registry.Servers = append(registry.Servers, proxy.ServerEntry{Name: name, Transport: "mock", Fixture: path})
+	}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	srv.backendManager.isolated = true
+	var out bytes.Buffer
+	srv.out = &out
+
+	resp, ok := srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)}).(JSONRPCResponse)
+	if !ok || resp.Error != nil {
+		t.Fatalf("expected initialize to succeed, got %+v", resp)
+	}
+	data, _ := json.Marshal(resp.Result)
+	var result struct {
+		Capabilities proxy.Capabilities `json:"capabilities"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("failed to parse initialize result: %v", err)
+	}
+	return srv, &out, result.Capabilities
+}
+
+func TestCapabilitiesReflectBackends(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	plain := "resources:\n  - uri: file:///notes.txt\n    text: hello\n"
+
+	srv, _, caps := startCapabilityServer(t, ctx, map[string]string{"fs": plain})
+	if caps.Resources == nil || caps.Resources.Subscribe || caps.Prompts == nil || caps.Prompts.ListChanged {
+		t.Errorf("expected neither subscribe nor prompts listChanged without backend support, got %+v %+v", caps.Resources, caps.Prompts)
+	}
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "resources/subscribe", Params: json.RawMessage(`{"uri":"armour://fs/file:///notes.txt"}`)}).(JSONRPCResponse)
+	if resp.Error == nil || resp.Error.Code != -32601 {
+		t.Errorf("expected subscribe to be refused, got %+v", resp)
+	}
+
+	srv, _, caps = startCapabilityServer(t, ctx, map[string]string{
+		"fs":    plain,
+		"notes": "subscribe: true\nlistChanged: true\n" + plain,
+	})
+	if caps.Resources == nil || !caps.Resources.Subscribe || caps.Prompts == nil || !caps.Prompts.ListChanged {
+		t.Errorf("expected subscribe and prompts listChanged from the notes backend, got %+v %+v", caps.Resources, caps.Prompts)
+	}
+	resp = srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "resources/subscribe", Params: json.RawMessage(`{"uri":"armour://notes/file:///notes.txt"}`)}).(JSONRPCResponse)
+	if resp.Error != nil {
+		t.Errorf("expected subscribe to a supporting backend to succeed, got %+v", resp.Error)
+	}
+	resp = srv.handleRequest(ctx, JSONRPCRequest{ID: 4, Method: "resources/subscribe", Params: json.RawMessage(`{"uri":"armour://fs/file:///notes.txt"}`)}).(JSONRPCResponse)
+	if resp.Error == nil || resp.Error.Code != -32601 {
+		t.Fatalf("expected a capability error, got %+v", resp.Error)
+	}
+	if data, _ := resp.Error.Data.(map[string]interface{}); !strings.Contains(fmt.Sprint(data["detail"]), "backend fs does not support") {
+		t.Errorf("expected a capability error naming the backend, got %+v", resp.Error)
+	}
+}
+
+func TestRelayBackendNotifications(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv, out, _ := startCapabilityServer(t, ctx, map[string]string{
+		"notes": "subscribe: true\nlistChanged: true\nresources:\n  - uri: file:///a.txt\n    text: a\n  - uri: file:///b.txt\n    text: b\n",
+	})
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "resources/subscribe", Params: json.RawMessage(`{"uri":"armour://notes/file:///a.txt"}`)}).(JSONRPCResponse)
+	if resp.Error != nil {
+		t.Fatalf("expected subscribe to succeed, got %+v", resp.Error)
+	}
+
+	// The backend sends notifications ahead of its next response
+	conn, err := srv.backendManager.getConnection("notes")
+	if err != nil {
+		t.Fatalf("backend not connected: %v", err)
+	}
+	pending := conn.transport.(*mockTransport).pending
+	pending <- []byte(`{"jsonrpc":"2.0","method":"notifications/resources/updated","params":{"uri":"file:///b.txt"}}`)
+	pending <- []byte(`{"jsonrpc":"2.0","method":"notifications/resources/updated","params":{"uri":"file:///a.txt"}}`)
+	pending <- []byte(`{"jsonrpc":"2.0","method":"notifications/prompts/list_changed"}`)
+	pending <- []byte(`{"jsonrpc":"2.0","method":"notifications/message","params":{"level":"info","data":"hi"}}`)
+	resp = srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "resources/read", Params: json.RawMessage(`{"uri":"armour://notes/file:///a.txt"}`)}).(JSONRPCResponse)
+	if resp.Error != nil {
+		t.Fatalf("expected the read to get its own response, got %+v", resp.Error)
+	}
+
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		`{"jsonrpc":"2.0","method":"notifications/resources/updated","params":{"uri":"armour://notes/file:///a.txt"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/prompts/list_changed"}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected only the subscribed update and the prompt list change, got:\n%s", out.String())
+	}
+}
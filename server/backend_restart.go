@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+const (
+	defaultRestartRetries = 5
+	defaultRestartBackoff = time.Second
+	maxRestartBackoff     = time.Minute
+	// restartStableAfter is how long a restarted process must run for its
+	// restarts to stop counting as in a row.
+	restartStableAfter = 5 * time.Minute
+)
+
+// BackendRestarts is a stdio backend's restart history as shown in /api/servers.
+type BackendRestarts struct {
+	Restarts    int       `json:"restarts"` // since the proxy started
+	InARow      int       `json:"in_a_row"` // without the process running stably in between
+	LastExit    string    `json:"last_exit,omitempty"`
+	LastRestart time.Time `json:"last_restart,omitempty"`
+	Quarantined bool      `json:"quarantined"` // crash-looping; no longer restarted
+	// LimitExceeded is the resource limit the last exit was due to: memory or max_lifetime
+	LimitExceeded string `json:"limit_exceeded,omitempty"`
+}
+
+// restartPolicy returns a server's restart settings with defaults applied.
+func restartPolicy(entry *proxy.ServerEntry) (mode string, maxRetries int, backoff time.Duration) {
+	mode, maxRetries, backoff = proxy.RestartOnFailure, defaultRestartRetries, defaultRestartBackoff
+	if p := entry.Restart; p != nil {
+		if p.Mode != "" {
+			mode = p.Mode
+		}
+		if p.MaxRetries > 0 {
+			maxRetries = p.MaxRetries
+		}
+		if d, err := time.ParseDuration(p.Backoff); err == nil && p.Backoff != "" {
+			backoff = d
+		}
+	}
+	return mode, maxRetries, backoff
+}
+
+// restartDelay doubles backoff for each restart in a row, up to maxRestartBackoff.
+func restartDelay(backoff time.Duration, inARow int) time.Duration {
+	delay := backoff
+	for i := 0; i < inARow && delay < maxRestartBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRestartBackoff)
+}
+
+// superviseProcess waits for a stdio backend's subprocess to exit, stopping
+// it when it outlives its max_lifetime, and restarts it according to the
+// server's restart policy. A process stopped by RemoveBackend or by ctx
+// ending is not restarted.
+func (bm *BackendManager) superviseProcess(ctx context.Context, entry proxy.ServerEntry, conn *BackendConnection, process *exec.Cmd) {
+	started := time.Now()
+	exited := make(chan error, 1)
+	go func() { exited <- process.Wait() }()
+
+	var lifetime <-chan time.Time
+	if d := entry.Limits.Lifetime(); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		lifetime = timer.C
+	}
+
+	var err error
+	var limit string
+	select {
+	case err = <-exited:
+		limit = conn.limiter.exceeded()
+	case <-lifetime:
+		limit = LimitLifetime
+		process.Process.Kill()
+		err = <-exited
+	case <-ctx.Done():
+		process.Process.Kill()
+		<-exited
+		conn.limiter.release()
+		return
+	}
+	conn.limiter.release()
+
+	bm.mu.Lock()
+	current := bm.connections[entry.Name] == conn
+	if current {
+		delete(bm.connections, entry.Name)
+	}
+	bm.mu.Unlock()
+	if !current {
+		return // removed on purpose
+	}
+	conn.closeTransports()
+	if bm.toolRegistry != nil {
+		bm.toolRegistry.ClearBackendTools(entry.Name)
+	}
+
+	exit := "exited cleanly"
+	if err != nil {
+		exit = err.Error()
+	}
+	if limit != "" {
+		exit = "stopped for exceeding its " + limit + " limit (" + exit + ")"
+		bm.logger.Error("backend %s %s", entry.Name, exit)
+	} else {
+		bm.logger.Warn("backend %s %s%s", entry.Name, exit, stderrTail(bm.BackendLogs(entry.Name, backendLogTailLines)))
+	}
+
+	mode, _, _ := restartPolicy(&entry)
+	if mode == proxy.RestartNever || (mode == proxy.RestartOnFailure && err == nil) {
+		bm.recordExit(entry.Name, exit, limit, time.Since(started))
+		return
+	}
+	bm.restartBackend(ctx, entry, exit, limit, time.Since(started))
+}
+
+// recordExit notes why a backend's process exited and returns its restart
+// state. A process that ran stably or for its whole max_lifetime resets
+// the count of restarts in a row.
+func (bm *BackendManager) recordExit(name, exit, limit string, ran time.Duration) *BackendRestarts {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.restarts == nil {
+		bm.restarts = make(map[string]*BackendRestarts)
+	}
+	state, ok := bm.restarts[name]
+	if !ok {
+		state = &BackendRestarts{}
+		bm.restarts[name] = state
+	}
+	state.LastExit = exit
+	state.LimitExceeded = limit
+	if ran >= restartStableAfter || limit == LimitLifetime {
+		state.InARow = 0
+	}
+	return state
+}
+
+// restartBackend restarts an exited backend after its backoff, retrying
+// failed starts, until it runs again or is quarantined.
+func (bm *BackendManager) restartBackend(ctx context.Context, entry proxy.ServerEntry, exit, limit string, ran time.Duration) {
+	_, maxRetries, backoff := restartPolicy(&entry)
+	state := bm.recordExit(entry.Name, exit, limit, ran)
+	for {
+		bm.mu.Lock()
+		if state.InARow >= maxRetries {
+			state.Quarantined = true
+			bm.mu.Unlock()
+			bm.logger.Error("backend %s quarantined after %d restarts in a row; fix it and restart the proxy", entry.Name, maxRetries)
+			return
+		}
+		delay := restartDelay(backoff, state.InARow)
+		state.InARow++
+		state.Restarts++
+		attempt := state.InARow
+		bm.mu.Unlock()
+
+		bm.logger.Info("restarting backend %s in %s (restart %d of %d in a row)", entry.Name, delay, attempt, maxRetries)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		bm.mu.Lock()
+		state.LastRestart = time.Now()
+		bm.mu.Unlock()
+
+		retry := entry
+		initCtx, cancel := context.WithTimeout(ctx, backendInitTimeout)
+		err := bm.initializeBackend(initCtx, &retry)
+		cancel()
+		if err == nil {
+			return
+		}
+		bm.logger.Error("failed to restart backend %s: %v", entry.Name, err)
+		bm.mu.Lock()
+		state.LastExit = err.Error()
+		bm.mu.Unlock()
+	}
+}
+
+// RestartStatus returns the restart history of every backend that exited, by name.
+func (bm *BackendManager) RestartStatus() map[string]BackendRestarts {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+	status := make(map[string]BackendRestarts, len(bm.restarts))
+	for name, state := range bm.restarts {
+		status[name] = *state
+	}
+	return status
+}
+
+// Quarantined returns the backends that were given up on, sorted.
+func (bm *BackendManager) Quarantined() []string {
+	var names []string
+	for name, state := range bm.RestartStatus() {
+		if state.Quarantined {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
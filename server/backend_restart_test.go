@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// crashingServer answers initialize and tools/list, then exits with status 1.
+var crashingServer = []string{"-c", `for i in 1 2; do read line; echo '{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{},"tools":[]}}'; done; exit 1`}
+
+// TestRestartPolicyDefaults tests the defaults and the doubling backoff
+func TestRestartPolicyDefaults(t *testing.T) {
+	mode, retries, backoff := restartPolicy(&proxy.ServerEntry{})
+	if mode != proxy.RestartOnFailure || retries != defaultRestartRetries || backoff != defaultRestartBackoff {
+		t.Errorf("unexpected defaults %s/%d/%s", mode, retries, backoff)
+	}
+	mode, retries, backoff = restartPolicy(&proxy.ServerEntry{Restart: &proxy.RestartPolicy{Mode: proxy.RestartAlways, MaxRetries: 2, Backoff: "250ms"}})
+	if mode != proxy.RestartAlways || retries != 2 || backoff != 250*time.Millisecond {
+		t.Errorf("unexpected policy %s/%d/%s", mode, retries, backoff)
+	}
+	for inARow, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if got := restartDelay(time.Second, inARow); got != want {
+			t.Errorf("restart %d: expected %s, got %s", inARow, want, got)
+		}
+	}
+	if got := restartDelay(time.Second, 20); got != maxRestartBackoff {
+		t.Errorf("expected the delay capped at %s, got %s", maxRestartBackoff, got)
+	}
+}
+
+// TestCrashLoopingBackendQuarantined tests that a backend exiting after
+// every start is restarted up to max_retries and then left stopped
+func TestCrashLoopingBackendQuarantined(t *testing.T) {
+	entry := proxy.ServerEntry{
+		Name:      "flaky",
+		Transport: "stdio",
+		Command:   "sh",
+		Args:      crashingServer,
+		Restart:   &proxy.RestartPolicy{MaxRetries: 2, Backoff: "10ms"},
+	}
+	bm := NewBackendManager(&proxy.ServerRegistry{Servers: []proxy.ServerEntry{entry}}, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	bm.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := bm.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	for len(bm.Quarantined()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected the backend quarantined, got %+v", bm.RestartStatus())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	status := bm.RestartStatus()["flaky"]
+	if status.Restarts != 2 || !status.Quarantined || status.LastExit != "exit status 1" {
+		t.Errorf("expected 2 restarts before quarantine, got %+v", status)
+	}
+	if _, err := bm.getConnection("flaky"); err == nil {
+		t.Error("expected a quarantined backend to be disconnected")
+	}
+}
+
+// TestRestartNever tests that a backend with restart mode never stays stopped
+func TestRestartNever(t *testing.T) {
+	entry := proxy.ServerEntry{
+		Name:      "once",
+		Transport: "stdio",
+		Command:   "sh",
+		Args:      crashingServer,
+		Restart:   &proxy.RestartPolicy{Mode: proxy.RestartNever},
+	}
+	bm := NewBackendManager(&proxy.ServerRegistry{Servers: []proxy.ServerEntry{entry}}, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	bm.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := bm.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+
+	for bm.RestartStatus()["once"].LastExit == "" {
+		select {
+		case <-ctx.Done():
+			t.Fatal("expected the backend's exit recorded")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if status := bm.RestartStatus()["once"]; status.Restarts != 0 || status.Quarantined {
+		t.Errorf("expected no restarts, got %+v", status)
+	}
+	if _, err := bm.getConnection("once"); err == nil {
+		t.Error("expected the exited backend disconnected")
+	}
+}
+
+// TestMaxLifetimeStopsBackend tests that a backend outliving its
+// max_lifetime is stopped and flagged
+func TestMaxLifetimeStopsBackend(t *testing.T) {
+	entry := proxy.ServerEntry{
+		Name:      "runaway",
+		Transport: "stdio",
+		Command:   "sh",
+		Args:      []string{"-c", `while read line; do echo '{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{},"tools":[]}}'; done`},
+		Restart:   &proxy.RestartPolicy{Mode: proxy.RestartNever},
+		Limits:    &proxy.ResourceLimits{MaxLifetime: "200ms"},
+	}
+	bm := NewBackendManager(&proxy.ServerRegistry{Servers: []proxy.ServerEntry{entry}}, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	bm.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := bm.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	if _, err := bm.getConnection("runaway"); err != nil {
+		t.Fatalf("expected the backend running before its lifetime ends: %v", err)
+	}
+
+	for bm.RestartStatus()["runaway"].LastExit == "" {
+		select {
+		case <-ctx.Done():
+			t.Fatal("expected the backend stopped at its max lifetime")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if status := bm.RestartStatus()["runaway"]; status.LimitExceeded != LimitLifetime {
+		t.Errorf("expected the backend flagged for its lifetime, got %+v", status)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// idempotentMethods are the methods that may be retried or hedged. tools/call
+// may be only for idempotent tools.
+var idempotentMethods = []string{
+	"ping", "tools/list", "resources/list", "resources/read", "resources/templates/list",
+	"prompts/list", "prompts/get", "completion/complete",
+}
+
+// Verbs a tool name starts with when it only reads, e.g. list_issues or getFile.
+var idempotentToolWords = []string{"list", "read", "get"}
+
+// idempotent reports whether a request to the backend is safe to send more
+// than once. Mutating calls never are, since a request that failed on the
+// way back may still have been carried out.
+func (bc *BackendConnection) idempotent(reqBytes []byte) bool {
+	if bc.config == nil || (bc.config.Retry == nil && bc.config.Hedge == nil) {
+		return false
+	}
+	var req struct {
+		Method string `json:"method"`
+		Params struct {
+			Name string `json:"name"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(reqBytes, &req); err != nil {
+		return false
+	}
+	if slices.Contains(idempotentMethods, req.Method) {
+		return true
+	}
+	return req.Method == "tools/call" && idempotentTool(bc.config.Retry, req.Params.Name)
+}
+
+// idempotentTool reports whether a backend tool is safe to repeat: it is
+// opted in by the retry policy, or its name starts with a read verb and says
+// nothing else that would change state (get_or_create_user is not).
+func idempotentTool(policy *proxy.RetryPolicy, name string) bool {
+	if policy != nil {
+		for _, pattern := range policy.IdempotentTools {
+			if matchWildcard(name, pattern) {
+				return true
+			}
+		}
+	}
+	words := toolNameWords(name)
+	return len(words) > 0 && slices.Contains(idempotentToolWords, words[0]) && ClassifyTool(name, nil) == ToolClassRead
+}
+
+// retryable reports whether a request failed in a way worth retrying: the
+// backend could not be reached, or answered with one of the policy's statuses.
+func retryable(err error, policy *proxy.RetryPolicy) bool {
+	var statusErr *proxy.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(policy.Statuses(), statusErr.StatusCode)
+	}
+	var netErr net.Error // including the *url.Error of a failed HTTP round trip
+	return errors.As(err, &netErr)
+}
+
+// waitRetry waits out the backoff before retry attempt+1, doubled for each
+// earlier retry. It returns an error if ctx ends first.
+func (bc *BackendConnection) waitRetry(ctx context.Context, policy *proxy.RetryPolicy, attempt int, cause error) error {
+	delay := policy.Delay() << attempt
+	bc.logger.Warn("backend %s: retrying in %v (%d/%d) after: %v", bc.config.Name, delay, attempt+1, policy.MaxAttempts(), cause)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("request cancelled: %v", ctx.Err())
+	}
+}
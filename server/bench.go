@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// BenchRuleCounts are the blocklist sizes the hot path is measured at by
+// default, to show how per-call overhead scales with the number of rules.
+var BenchRuleCounts = []int{0, 10, 100, 1000}
+
+// BenchResult is one benchmark measured at one rule count.
+type BenchResult struct {
+	Name        string `json:"name"`
+	Rules       int    `json:"rules"`
+	Iterations  int    `json:"iterations"`
+	NsPerOp     int64  `json:"ns_per_op"`
+	AllocsPerOp int64  `json:"allocs_per_op"`
+	BytesPerOp  int64  `json:"bytes_per_op"`
+}
+
+// benchCase is one stage of a tools/call, measured against a proxy with a
+// given number of rules. prepare sets the stage up and returns one run of
+// it; taking a testing.TB lets tests run the stage without a benchmark.
+type benchCase struct {
+	name    string
+	prepare func(tb testing.TB, p *benchProxy) func(i int)
+}
+
+// run measures the stage over b.N runs.
+func (c benchCase) run(b *testing.B, p *benchProxy) {
+	op := c.prepare(b, p)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		op(i)
+	}
+}
+
+// benchCases are the stages of the tools/call hot path, from the full call
+// down to its parts. backend is the call without the proxy's checks, so the
+// difference from call is what the proxy adds.
+var benchCases = []benchCase{
+	{"call", benchToolCall},
+	{"backend", benchBackendCall},
+	{"blocklist", benchBlocklistCheck},
+	{"lookup", benchToolLookup},
+	{"marshal", benchMarshalResponse},
+}
+
+// benchTool is the demo server tool every benchmark calls.
+const benchTool = DemoServerName + ":echo"
+
+var benchArgs = json.RawMessage(`{"message":"the quick brown fox jumps over the lazy dog"}`)
+
+// benchProxy is a stdio server in front of the built-in demo server, with
+// rules that never match so every check runs to the end of the list.
+type benchProxy struct {
+	srv *StdioServer
+	dir string
+}
+
+// newBenchProxy starts a proxy with a throwaway database holding rules
+// blocklist rules.
+func newBenchProxy(rules int) (*benchProxy, error) {
+	dir, err := os.MkdirTemp("", "armour-bench")
+	if err != nil {
+		return nil, err
+	}
+	registry := &proxy.ServerRegistry{}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(dir, "armour.db"), DemoServer: true}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	p := &benchProxy{srv: srv, dir: dir}
+	srv.backendManager.isolated = true
+
+	for i := 0; i < rules; i++ {
+		rule := &BlocklistRule{
+			Pattern:     fmt.Sprintf(`bench-rule-%d-[0-9a-f]{8}`, i),
+			Action:      "block",
+			IsRegex:     true,
+			Permissions: DefaultPermissions("block"),
+			Enabled:     true,
+		}
+		if err := CreateBlocklistRule(srv.db, rule); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to create rule: %w", err)
+		}
+	}
+	// Measure the local rule cache, not a rules server that happens to be up
+	srv.blocklist.SetRulesServerURL("")
+	if err := srv.blocklist.RefreshRulesCache(); err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 0, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+	if _, err := srv.toolRegistry.GetTool(benchTool); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("demo server did not start: %w", err)
+	}
+	return p, nil
+}
+
+// Close stops the proxy and removes its database.
+func (p *benchProxy) Close() {
+	p.srv.Close()
+	os.RemoveAll(p.dir)
+}
+
+// benchToolCall is a whole tools/call as a client sends it.
+func benchToolCall(tb testing.TB, p *benchProxy) func(i int) {
+	ctx := context.Background()
+	params := json.RawMessage(`{"name":"` + benchTool + `","arguments":` + string(benchArgs) + `}`)
+	return func(i int) {
+		resp, ok := p.srv.handleRequest(ctx, JSONRPCRequest{ID: i, Method: "tools/call", Params: params}).(JSONRPCResponse)
+		if !ok || resp.Error != nil {
+			tb.Fatalf("tools/call failed: %+v", resp.Error)
+		}
+	}
+}
+
+// benchBackendCall sends the call straight to the backend.
+func benchBackendCall(tb testing.TB, p *benchProxy) func(i int) {
+	ctx := context.Background()
+	return func(int) {
+		if _, err := p.srv.backendManager.CallTool(ctx, DemoServerName, "echo", benchArgs); err != nil {
+			tb.Fatalf("backend call failed: %v", err)
+		}
+	}
+}
+
+// benchBlocklistCheck checks the call's arguments against every rule.
+func benchBlocklistCheck(tb testing.TB, p *benchProxy) func(i int) {
+	var args map[string]interface{}
+	json.Unmarshal(benchArgs, &args)
+	return func(int) {
+		result, err := p.srv.blocklist.Check("tools/call", benchTool, args)
+		if err != nil || !result.Allowed {
+			tb.Fatalf("expected the call to be allowed, got %+v %v", result, err)
+		}
+	}
+}
+
+// benchToolLookup finds the backend of the namespaced tool.
+func benchToolLookup(tb testing.TB, p *benchProxy) func(i int) {
+	return func(int) {
+		if _, err := p.srv.toolRegistry.GetTool(benchTool); err != nil {
+			tb.Fatalf("lookup failed: %v", err)
+		}
+	}
+}
+
+// benchMarshalResponse encodes a tools/call response for the client.
+func benchMarshalResponse(tb testing.TB, p *benchProxy) func(i int) {
+	result, err := p.srv.backendManager.CallTool(context.Background(), DemoServerName, "echo", benchArgs)
+	if err != nil {
+		tb.Fatalf("backend call failed: %v", err)
+	}
+	resp := JSONRPCResponse{JSONRPC: "2.0", ID: 1, Result: result}
+	return func(int) {
+		if _, err := json.Marshal(resp); err != nil {
+			tb.Fatalf("marshal failed: %v", err)
+		}
+	}
+}
+
+// RunBenchmarks measures every stage of the tools/call hot path at each rule
+// count, for the bench command. Results are in rule count order.
+func RunBenchmarks(ruleCounts []int) ([]BenchResult, error) {
+	var results []BenchResult
+	for _, rules := range ruleCounts {
+		if rules < 0 {
+			return nil, fmt.Errorf("invalid rule count %d", rules)
+		}
+		p, err := newBenchProxy(rules)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range benchCases {
+			r := testing.Benchmark(func(b *testing.B) { c.run(b, p) })
+			if r.N == 0 { // testing.Benchmark reports a failed run as empty
+				p.Close()
+				return nil, fmt.Errorf("benchmark %s with %d rules failed", c.name, rules)
+			}
+			results = append(results, BenchResult{
+				Name:        c.name,
+				Rules:       rules,
+				Iterations:  r.N,
+				NsPerOp:     r.NsPerOp(),
+				AllocsPerOp: r.AllocsPerOp(),
+				BytesPerOp:  r.AllocedBytesPerOp(),
+			})
+		}
+		p.Close()
+	}
+	return results, nil
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+)
+
+// runBenchCase measures one stage of tools/call at every rule count, as
+// sub-benchmarks named rules=N, so go test -bench shows how it scales.
+func runBenchCase(b *testing.B, name string) {
+	for _, c := range benchCases {
+		if c.name != name {
+			continue
+		}
+		for _, rules := range BenchRuleCounts {
+			b.Run(fmt.Sprintf("rules=%d", rules), func(b *testing.B) {
+				p, err := newBenchProxy(rules)
+				if err != nil {
+					b.Fatalf("failed to start proxy: %v", err)
+				}
+				defer p.Close()
+				c.run(b, p)
+			})
+		}
+		return
+	}
+	b.Fatalf("no benchmark named %s", name)
+}
+
+func BenchmarkToolCall(b *testing.B)        { runBenchCase(b, "call") }
+func BenchmarkBackendCall(b *testing.B)     { runBenchCase(b, "backend") }
+func BenchmarkBlocklistCheck(b *testing.B)  { runBenchCase(b, "blocklist") }
+func BenchmarkToolLookup(b *testing.B)      { runBenchCase(b, "lookup") }
+func BenchmarkMarshalResponse(b *testing.B) { runBenchCase(b, "marshal") }
+
+// TestBenchProxy checks the proxy the benchmarks measure is set up as they
+// expect, without spending the seconds a benchmark run takes.
+func TestBenchProxy(t *testing.T) {
+	p, err := newBenchProxy(10)
+	if err != nil {
+		t.Fatalf("failed to start proxy: %v", err)
+	}
+	defer p.Close()
+	rules, err := GetEnabledBlocklistRules(p.srv.db)
+	if err != nil || len(rules) != 10 {
+		t.Fatalf("expected 10 rules, got %d (%v)", len(rules), err)
+	}
+	for _, c := range benchCases {
+		t.Run(c.name, func(t *testing.T) {
+			op := c.prepare(t, p)
+			for i := 0; i < 3; i++ {
+				op(i)
+			}
+		})
+	}
+	if _, err := RunBenchmarks([]int{-1}); err == nil {
+		t.Error("expected a negative rule count to be rejected")
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+// ErrCodeDenied is the JSON-RPC error code of requests the proxy refuses on
+// policy grounds. Its data is always a BlockError, so clients and hooks can
+// tell a block from a backend failure (-32603) without parsing messages.
+const ErrCodeDenied = -32001
+
+// DashboardURL is where the stdio-mode dashboard serves rules and audit logs.
+const DashboardURL = "http://localhost:13337"
+
+// What blocked a request, in BlockError.BlockedBy.
+const (
+	BlockedByRule   = "rule"   // a blocklist rule
+	BlockedByTrust  = "trust"  // the trust tier of the server
+	BlockedByBudget = "budget" // a spend budget
+	BlockedByBatch  = "batch"  // another request in the same batch was blocked
+
+	BlockedByKillSwitch = "killswitch" // the kill switch is engaged
+	BlockedByOffline    = "offline"    // offline mode keeps the call off the network
+	BlockedBySandbox    = "sandbox"    // a file argument is outside the server's allowed roots
+	BlockedByEgress     = "egress"     // a URL argument points at a host the egress policy forbids
+	BlockedByDLP        = "dlp"        // an argument holds sensitive data bound for a remote backend
+	BlockedByDecoy      = "decoy"      // the tool is a honeypot no legitimate workflow calls
+	BlockedByPlugin     = "plugin"     // a policy checker (see PolicyChecker) denied the call
+	BlockedByCedar      = "cedar"      // the cedar policies of the policy file deny the call
+)
+
+// BlockError is the machine-readable data of an "Operation denied" error.
+type BlockError struct {
+	BlockedBy string `json:"blocked_by"`
+	RuleID    int64  `json:"rule_id,omitempty"`
+	Action    string `json:"action,omitempty"`    // what the policy said: block, deny, ask, ...
+	Operation string `json:"operation,omitempty"` // the denied operation, e.g. tools_call
+	Tool      string `json:"tool,omitempty"`      // namespaced tool, prompt or resource
+	Pattern   string `json:"pattern,omitempty"`   // what the rule matched, for rule blocks
+	Reason    string `json:"reason"`              // human-readable explanation
+	Appeal    string `json:"appeal,omitempty"`    // where the user can review or change the policy
+
+	// One-time dashboard link to approve this call once or make an exception
+	ApprovalURL string `json:"approval_url,omitempty"`
+
+	// The proxy-wide ID of the denied request, to look it up in the audit log
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (e *BlockError) Error() string {
+	return e.Reason
+}
+
+// ruleBlock describes a blocklist denial of name.
+func ruleBlock(result *BlocklistCheckResult, name, appeal string) *BlockError {
+	e := &BlockError{
+		BlockedBy: BlockedByRule,
+		Operation: result.DeniedOperation,
+		Tool:      name,
+		Reason:    "denied by blocklist",
+		Appeal:    appeal,
+	}
+	if result.Error != nil && result.Error.Message != "" {
+		e.Reason = result.Error.Message
+	}
+	if result.MatchedRule != nil {
+		e.RuleID = result.MatchedRule.ID
+		e.Action = result.MatchedRule.Action
+		e.Pattern = result.MatchedRule.Pattern
+	}
+	return e
+}
+
+// deniedError builds the JSON-RPC error for a blocked request.
+func deniedError(block *BlockError) *JSONRPCError {
+	return &JSONRPCError{
+		Code:    ErrCodeDenied,
+		Message: "Operation denied",
+		Data:    block,
+	}
+}
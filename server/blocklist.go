@@ -30,7 +30,7 @@ type BlocklistRule struct {
 	ID          int64       `json:"id"`
 	Pattern     string      `json:"pattern"`
 	Description string      `json:"description,omitempty"`
-	Action      string      `json:"action"` // block, allow
+	Action      string      `json:"action"` // block, allow or delegate
 	IsRegex     bool        `json:"is_regex"`
 	IsSemantic  bool        `json:"is_semantic"`
 	Tools       string      `json:"tools"` // comma-separated tool names
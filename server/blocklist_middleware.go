@@ -8,7 +8,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -34,7 +36,10 @@ type BlocklistMiddleware struct {
 	logger         Logger
 	rulesServerURL string // URL of external rules server (for instant updates)
 	communityRules []BlocklistRule
+	orgPolicy      *OrgPolicySync
 	tracer         *proxy.TraceRecorder
+	registry       *proxy.ServerRegistry // server tags for tag:<name> rules
+	history        *CallHistory          // earlier calls shown to semantic rules
 }
 
 // Logger interface for logging
@@ -59,11 +64,12 @@ func NewBlocklistMiddleware(db *sql.DB, apiKey string, stats *StatsTracker, logg
 		logger = &noOpLogger{}
 	}
 	bm := &BlocklistMiddleware{
-		db:     db,
-		apiKey: apiKey,
-		stats:  stats,
-		logger: logger,
-		tracer: tracer,
+		db:      db,
+		apiKey:  apiKey,
+		stats:   stats,
+		logger:  logger,
+		tracer:  tracer,
+		history: callHistoryFromEnv(),
 	}
 	bm.communityRules = loadCommunityRules(defaultCommunitySources(), logger)
 	if len(bm.communityRules) > 0 {
@@ -72,22 +78,156 @@ func NewBlocklistMiddleware(db *sql.DB, apiKey string, stats *StatsTracker, logg
 	return bm
 }
 
+// newProxyBlocklist creates the blocklist middleware shared by the stdio and
+// HTTP proxies: it prefers the rules server (ARMOUR_RULES_URL, or one detected
+// on :8084) and attaches the organization policy layer if configured.
+func newProxyBlocklist(db *sql.DB, apiKey string, stats *StatsTracker, logger *proxy.Logger, tracer *proxy.TraceRecorder, registry *proxy.ServerRegistry) *BlocklistMiddleware {
+	blocklist := NewBlocklistMiddleware(db, apiKey, stats, logger, tracer)
+	blocklist.SetRegistry(registry)
+
+	// Configure rules server URL if available (for instant rule updates)
+	if rulesURL := os.Getenv("ARMOUR_RULES_URL"); rulesURL != "" {
+		blocklist.SetRulesServerURL(rulesURL)
+		logger.Info("using rules server at %s for instant policy enforcement", rulesURL)
+	} else {
+		// Default to localhost:8084 if server is running
+		defaultRulesURL := "http://127.0.0.1:8084"
+		if CheckRulesServer(8084) {
+			blocklist.SetRulesServerURL(defaultRulesURL)
+			logger.Info("detected rules server at %s", defaultRulesURL)
+		}
+	}
+
+	// Organization policy layer (read-only, synced from a signed remote bundle)
+	orgPolicy, err := NewOrgPolicySyncFromEnv(logger)
+	if err != nil {
+		logger.Warn("org policy sync disabled: %v", err)
+	} else if orgPolicy != nil {
+		blocklist.SetOrgPolicy(orgPolicy)
+	}
+
+	return blocklist
+}
+
 // SetRulesServerURL sets the URL for an external rules server
 // When set, Check() will query this server instead of using local cache
 func (bm *BlocklistMiddleware) SetRulesServerURL(url string) {
 	bm.rulesServerURL = url
 }
 
+// RulesServerURL returns the external rules server URL, or "" if none is used.
+func (bm *BlocklistMiddleware) RulesServerURL() string {
+	return bm.rulesServerURL
+}
+
+// SetOrgPolicy attaches an organization rule layer, evaluated after local and community rules.
+func (bm *BlocklistMiddleware) SetOrgPolicy(org *OrgPolicySync) {
+	bm.orgPolicy = org
+}
+
+// GetOrgPolicy returns the organization rule layer, or nil if none is configured.
+func (bm *BlocklistMiddleware) GetOrgPolicy() *OrgPolicySync {
+	return bm.orgPolicy
+}
+
+// SetRegistry sets the servers whose tags rules can target.
+func (bm *BlocklistMiddleware) SetRegistry(registry *proxy.ServerRegistry) {
+	bm.registry = registry
+}
+
+// SetCallHistory sets how many earlier calls semantic rules see; nil turns
+// the history off.
+func (bm *BlocklistMiddleware) SetCallHistory(history *CallHistory) {
+	bm.history = history
+}
+
+// withRegistry returns a blocklist with bm's rules sources that resolves tags
+// against another registry, for a tenant of a multi-tenant proxy. Call
+// history is not shared across tenants.
+func (bm *BlocklistMiddleware) withRegistry(registry *proxy.ServerRegistry) *BlocklistMiddleware {
+	return &BlocklistMiddleware{
+		db:             bm.db,
+		apiKey:         bm.apiKey,
+		stats:          bm.stats,
+		logger:         bm.logger,
+		rulesServerURL: bm.rulesServerURL,
+		communityRules: bm.communityRules,
+		orgPolicy:      bm.orgPolicy,
+		tracer:         bm.tracer,
+		registry:       registry,
+		history:        callHistoryFromEnv(),
+	}
+}
+
+// serverTags returns the tags of the server providing a namespaced tool,
+// prompt (server:name) or resource (armour://server/uri).
+func (bm *BlocklistMiddleware) serverTags(name string) []string {
+	if bm.registry == nil {
+		return nil
+	}
+	server, _ := parseArmourURI(name)
+	if server == "" {
+		server, _ = parseNamespacedName(name)
+	}
+	if server == "" {
+		return nil
+	}
+	if entry := bm.registry.GetServer(server); entry != nil {
+		return entry.Tags
+	}
+	return nil
+}
+
+// RulesForTool returns the local, community and organization rules that
+// name a tool or a tag of its server. Rules for every tool are left out.
+func (bm *BlocklistMiddleware) RulesForTool(toolName string) []BlocklistRule {
+	rules, err := bm.getRules()
+	if err != nil {
+		return nil
+	}
+	rules = append(slices.Clone(rules), bm.communityRules...)
+	if bm.orgPolicy != nil {
+		rules = append(rules, bm.orgPolicy.Rules()...)
+	}
+	tags := bm.serverTags(toolName)
+	var matching []BlocklistRule
+	for _, rule := range rules {
+		if rule.Tools != "" && rule.Tools != "*" && RuleAppliesToTool(&rule, toolName, tags) {
+			matching = append(matching, rule)
+		}
+	}
+	return matching
+}
+
 // Check validates if a requested operation on a tool is allowed
 func (bm *BlocklistMiddleware) Check(method string, toolName string, args map[string]interface{}) (*BlocklistCheckResult, error) {
+	return bm.CheckSession("", method, toolName, args)
+}
+
+// CheckSession is Check for a call in a session: semantic rules see the
+// session's earlier tool calls, and tool calls are added to its history.
+func (bm *BlocklistMiddleware) CheckSession(sessionID, method, toolName string, args map[string]interface{}) (*BlocklistCheckResult, error) {
+	history := bm.history.Recent(sessionID)
+	result, err := bm.check(method, toolName, args, history)
+	if method == "tools/call" {
+		bm.history.Record(sessionID, toolName, args)
+	}
+	return result, err
+}
+
+func (bm *BlocklistMiddleware) check(method, toolName string, args map[string]interface{}, history []string) (*BlocklistCheckResult, error) {
 	// Extract content from arguments for pattern matching
 	content := bm.extractContent(method, toolName, args)
 
 	// If rules server is configured, query it first (instant updates)
 	if bm.rulesServerURL != "" {
-		result, err := bm.queryRulesServer(toolName, method, content)
+		result, err := bm.queryRulesServer(toolName, method, content, history)
 		if err == nil {
-			return result, nil
+			if !result.Allowed || bm.orgPolicy == nil {
+				return result, nil
+			}
+			// Local rules allowed the call; the organization layer still applies
+			return bm.checkRules(content, toolName, method, bm.orgPolicy.Rules(), history), nil
 		}
 		// Rules server unavailable, fall back to local check
 		bm.logger.Warn("rules server query failed, using local cache: %v", err)
@@ -105,22 +245,33 @@ func (bm *BlocklistMiddleware) Check(method string, toolName string, args map[st
 		rules = append(rules, bm.communityRules...)
 	}
 
+	// Organization rules sit below local rules
+	if bm.orgPolicy != nil {
+		rules = append(rules, bm.orgPolicy.Rules()...)
+	}
+
+	return bm.checkRules(content, toolName, method, rules, history), nil
+}
+
+// checkRules evaluates regex then semantic rules in order and returns the
+// first denial. history is the session's earlier calls, for semantic rules.
+func (bm *BlocklistMiddleware) checkRules(content, toolName, method string, rules []BlocklistRule, history []string) *BlocklistCheckResult {
 	// Check regex rules first (fast)
 	if result := bm.checkRegexRules(content, toolName, method, rules); result != nil {
-		return result, nil
+		return result
 	}
 
 	// Check semantic rules (slow, uses API)
-	if result := bm.checkSemanticRules(content, toolName, method, rules); result != nil {
-		return result, nil
+	if result := bm.checkSemanticRules(content, toolName, method, rules, history); result != nil {
+		return result
 	}
 
 	// No rules matched - allowed
-	return &BlocklistCheckResult{Allowed: true}, nil
+	return &BlocklistCheckResult{Allowed: true}
 }
 
 // queryRulesServer queries the external rules server for a check
-func (bm *BlocklistMiddleware) queryRulesServer(toolName, method, content string) (*BlocklistCheckResult, error) {
+func (bm *BlocklistMiddleware) queryRulesServer(toolName, method, content string, history []string) (*BlocklistCheckResult, error) {
 	client := &http.Client{Timeout: rulesServerTimeout}
 
 	url := fmt.Sprintf("%s/api/check?tool=%s&method=%s&content=%s&scope=mcp",
@@ -129,6 +280,12 @@ func (bm *BlocklistMiddleware) queryRulesServer(toolName, method, content string
 		urlEncode(method),
 		urlEncode(content),
 	)
+	if tags := bm.serverTags(toolName); len(tags) > 0 {
+		url += "&tags=" + urlEncode(strings.Join(tags, ","))
+	}
+	if len(history) > 0 {
+		url += "&history=" + urlEncode(strings.Join(history, "\n"))
+	}
 
 	resp, err := client.Get(url)
 	if err != nil {
@@ -157,10 +314,13 @@ func (bm *BlocklistMiddleware) queryRulesServer(toolName, method, content string
 
 	if !checkResp.Allowed {
 		result.Error = &MCPError{
-			Code:    -32001,
+			Code:    ErrCodeDenied,
 			Message: checkResp.Reason,
 		}
 		result.DeniedOperation = "tools_call"
+		if checkResp.RuleID != 0 {
+			result.MatchedRule = &BlocklistRule{ID: int64(checkResp.RuleID), Action: checkResp.Decision}
+		}
 	}
 
 	return result, nil
@@ -213,6 +373,7 @@ func (bm *BlocklistMiddleware) RefreshRulesCache() error {
 
 // checkRegexRules checks if any regex rules match the content
 func (bm *BlocklistMiddleware) checkRegexRules(content string, toolName string, method string, rules []BlocklistRule) *BlocklistCheckResult {
+	tags := bm.serverTags(toolName)
 	for _, rule := range rules {
 		// Skip non-regex rules
 		if !rule.IsRegex {
@@ -220,7 +381,7 @@ func (bm *BlocklistMiddleware) checkRegexRules(content string, toolName string,
 		}
 
 		// Check if rule applies to this tool
-		if !RuleAppliesToTool(&rule, toolName) {
+		if !RuleAppliesToTool(&rule, toolName, tags) {
 			continue
 		}
 
@@ -241,7 +402,8 @@ func (bm *BlocklistMiddleware) checkRegexRules(content string, toolName string,
 				bm.logger.Info("rule %d blocking %s on %s (action=%s)",
 					rule.ID, deniedOp, toolName, rule.Action)
 
-				if bm.stats != nil {
+				// Delegated calls are counted once the authorizer has decided
+				if bm.stats != nil && rule.Action != DelegateAction {
 					bm.stats.RecordBlockedCall(toolName, fmt.Sprintf("regex_rule_%d:%s", rule.ID, rule.Pattern))
 				}
 				if bm.tracer != nil {
@@ -271,11 +433,12 @@ func (bm *BlocklistMiddleware) checkRegexRules(content string, toolName string,
 }
 
 // checkSemanticRules checks if any semantic rules match the content using Claude API
-func (bm *BlocklistMiddleware) checkSemanticRules(content string, toolName string, method string, rules []BlocklistRule) *BlocklistCheckResult {
+func (bm *BlocklistMiddleware) checkSemanticRules(content string, toolName string, method string, rules []BlocklistRule, history []string) *BlocklistCheckResult {
 	// Filter semantic rules
+	tags := bm.serverTags(toolName)
 	var semanticRules []BlocklistRule
 	for _, rule := range rules {
-		if rule.IsSemantic && RuleAppliesToTool(&rule, toolName) {
+		if rule.IsSemantic && RuleAppliesToTool(&rule, toolName, tags) {
 			semanticRules = append(semanticRules, rule)
 		}
 	}
@@ -291,7 +454,7 @@ func (bm *BlocklistMiddleware) checkSemanticRules(content string, toolName strin
 	}
 
 	// Call Claude API for semantic matching
-	matched, matchedTopic := bm.callClaudeAPI(topics, content)
+	matched, matchedTopic := bm.callClaudeAPI(topics, content, history)
 	if matched {
 		bm.logger.Debug("semantic rule matched: topic=%s, tool=%s", matchedTopic, toolName)
 
@@ -321,7 +484,7 @@ func (bm *BlocklistMiddleware) checkSemanticRules(content string, toolName strin
 				bm.logger.Info("semantic rule %d blocking %s on %s (topic=%s)",
 					matchedRule.ID, deniedOp, toolName, matchedTopic)
 
-				if bm.stats != nil {
+				if bm.stats != nil && matchedRule.Action != DelegateAction {
 					bm.stats.RecordBlockedCall(toolName, fmt.Sprintf("semantic_rule_%d:%s", matchedRule.ID, matchedTopic))
 				}
 				if bm.tracer != nil {
@@ -410,6 +573,24 @@ func (bm *BlocklistMiddleware) extractContent(method string, toolName string, ar
 		content.WriteString(text)
 	}
 
+	// Bash-like tools: let rules see the commands that would actually run
+	if script := shellScript(args); script != "" {
+		content.WriteString(" ")
+		content.WriteString(script)
+		content.WriteString("\n")
+		content.WriteString(shellContent(script))
+	}
+
+	// Database tools: let rules match the statement classes
+	if key, sql := sqlArgument(args); sql != "" {
+		if key != "query" {
+			content.WriteString(" ")
+			content.WriteString(sql)
+		}
+		content.WriteString("\n")
+		content.WriteString(sqlContent(sql))
+	}
+
 	return content.String()
 }
 
@@ -445,8 +626,9 @@ type SemanticCheckResponse struct {
 	Topic   string `json:"topic"`
 }
 
-// callClaudeAPI calls the Claude API to check if content matches any blocked topics
-func (bm *BlocklistMiddleware) callClaudeAPI(topics []string, content string) (matched bool, topic string) {
+// callClaudeAPI calls the Claude API to check if content, in the context of
+// the session's earlier calls, matches any blocked topics
+func (bm *BlocklistMiddleware) callClaudeAPI(topics []string, content string, history []string) (matched bool, topic string) {
 	if bm.apiKey == "" {
 		return false, ""
 	}
@@ -460,9 +642,9 @@ func (bm *BlocklistMiddleware) callClaudeAPI(topics []string, content string) (m
 	prompt := fmt.Sprintf(`Analyze if this query relates to any of these blocked topics: %s
 
 Query: %s
-
+%s
 Respond with ONLY valid JSON: {"blocked": true/false, "topic": "matched topic or null"}`,
-		topicsStr, content)
+		topicsStr, content, historyPrompt(history))
 
 	payload := map[string]interface{}{
 		"model":      "claude-3-5-haiku-20241022",
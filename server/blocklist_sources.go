@@ -44,25 +44,30 @@ func loadCommunityRules(sources []string, logger Logger) []BlocklistRule {
 		}
 
 		for _, cr := range parsed {
-			action := strings.ToLower(cr.Action)
-			if action == "" {
-				action = "block"
-			}
-			rules = append(rules, BlocklistRule{
-				Pattern:     cr.Pattern,
-				Description: fallbackString(cr.Description, "community rule"),
-				Action:      action,
-				IsRegex:     cr.IsRegex || cr.BlockAll,
-				Tools:       strings.TrimSpace(cr.Tools),
-				Permissions: DefaultPermissions(action),
-				Enabled:     true,
-			})
+			rules = append(rules, cr.toBlocklistRule("community rule"))
 		}
 	}
 
 	return rules
 }
 
+// toBlocklistRule converts a lightweight rule definition into an enabled BlocklistRule.
+func (cr communityRule) toBlocklistRule(defaultDescription string) BlocklistRule {
+	action := strings.ToLower(cr.Action)
+	if action == "" {
+		action = "block"
+	}
+	return BlocklistRule{
+		Pattern:     cr.Pattern,
+		Description: fallbackString(cr.Description, defaultDescription),
+		Action:      action,
+		IsRegex:     cr.IsRegex || cr.BlockAll,
+		Tools:       strings.TrimSpace(cr.Tools),
+		Permissions: DefaultPermissions(action),
+		Enabled:     true,
+	}
+}
+
 func fetchSource(src string) ([]byte, error) {
 	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
 		client := &http.Client{Timeout: 3 * time.Second}
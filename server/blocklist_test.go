@@ -2,8 +2,11 @@ package server
 
 import (
 	"database/sql"
+	"path/filepath"
 	"testing"
 
+	"github.com/user/mcp-go-proxy/proxy"
+
 	_ "modernc.org/sqlite"
 )
 
@@ -137,29 +140,85 @@ func TestRuleAppliesToTool(t *testing.T) {
 	tests := []struct {
 		tools    string
 		toolName string
+		tags     []string
 		applies  bool
 		desc     string
 	}{
-		{"", "any_tool", true, "Empty tools applies to all"},
-		{"*", "any_tool", true, "Wildcard applies to all"},
-		{"tool1, tool2", "tool1", true, "Exact match"},
-		{"tool1, tool2", "tool3", false, "No match"},
-		{"*delete", "rm_delete", true, "Suffix match"},
-		{"*delete", "delete", true, "Exact suffix match"},
-		{"*delete", "rm_remove", false, "No suffix match"},
+		{"", "any_tool", nil, true, "Empty tools applies to all"},
+		{"*", "any_tool", nil, true, "Wildcard applies to all"},
+		{"tool1, tool2", "tool1", nil, true, "Exact match"},
+		{"tool1, tool2", "tool3", nil, false, "No match"},
+		{"*delete", "rm_delete", nil, true, "Suffix match"},
+		{"*delete", "delete", nil, true, "Exact suffix match"},
+		{"*delete", "rm_remove", nil, false, "No suffix match"},
+		{"tag:prod", "db:query", []string{"network", "prod"}, true, "Tag match"},
+		{"tag:prod", "db:query", []string{"staging"}, false, "No tag match"},
+		{"tag:prod", "db:query", nil, false, "Untagged server"},
+		{"tag:prod, *delete", "fs:file_delete", nil, true, "Tag and tool mixed"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
 			rule := &BlocklistRule{Tools: tt.tools}
-			result := RuleAppliesToTool(rule, tt.toolName)
+			result := RuleAppliesToTool(rule, tt.toolName, tt.tags)
 			if result != tt.applies {
-				t.Fatalf("Expected %v, got %v for tools=%q, tool=%q", tt.applies, result, tt.tools, tt.toolName)
+				t.Fatalf("Expected %v, got %v for tools=%q, tool=%q, tags=%v", tt.applies, result, tt.tools, tt.toolName, tt.tags)
 			}
 		})
 	}
 }
 
+// TestTagRulesTargetServers tests that one tag rule covers every tool, prompt
+// and resource of the servers carrying the tag
+func TestTagRulesTargetServers(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tags.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := initDB(db); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+
+	rule := &BlocklistRule{
+		Pattern:     ".*",
+		Description: "No writes on prod",
+		Action:      "block",
+		IsRegex:     true,
+		Tools:       "tag:prod",
+		Enabled:     true,
+		Permissions: DefaultPermissions("block"),
+	}
+	if err := CreateBlocklistRule(db, rule); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	bm := NewBlocklistMiddleware(db, "", nil, nil, nil)
+	bm.SetRegistry(&proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "db", Tags: []string{"prod", "network"}},
+		{Name: "scratch", Tags: []string{"experimental"}},
+	}})
+
+	for _, tc := range []struct {
+		method, name string
+		allowed      bool
+	}{
+		{"tools/call", "db:query", false},
+		{"prompts/get", "db:summarize", false},
+		{"resources/read", "armour://db/tables", false},
+		{"tools/call", "scratch:query", true},
+		{"tools/list", "", true},
+	} {
+		result, err := bm.Check(tc.method, tc.name, map[string]interface{}{"sql": "DELETE FROM users"})
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if result.Allowed != tc.allowed {
+			t.Errorf("%s %s: expected allowed=%v, got %+v", tc.method, tc.name, tc.allowed, result)
+		}
+	}
+}
+
 // TestMigration tests the migration functions
 func TestMigration(t *testing.T) {
 	// Create in-memory database
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// defaultCallHistorySize is how many earlier calls semantic rules see.
+	defaultCallHistorySize = 5
+	// callHistoryArgBytes caps the arguments kept per call.
+	callHistoryArgBytes = 200
+	// maxCallHistorySessions bounds memory in HTTP mode; the oldest
+	// session's history is dropped first.
+	maxCallHistorySessions = 256
+)
+
+// CallHistory keeps the last few tool calls of each session, so semantic
+// rules can judge a call together with what came before it ("block
+// exfiltration after reading secrets"). Arguments are truncated and
+// sensitive data in them is redacted before it is kept.
+type CallHistory struct {
+	mu       sync.Mutex
+	size     int  // calls kept per session; 0 disables the history
+	args     bool // keep arguments, not just tool names
+	sessions map[string][]string
+	order    []string // sessions, oldest first
+}
+
+// NewCallHistory keeps size calls per session, with or without arguments.
+func NewCallHistory(size int, args bool) *CallHistory {
+	return &CallHistory{size: size, args: args, sessions: make(map[string][]string)}
+}
+
+// callHistoryFromEnv reads ARMOUR_SEMANTIC_HISTORY (calls kept, 0 disables)
+// and ARMOUR_SEMANTIC_HISTORY_ARGS (false keeps tool names only).
+func callHistoryFromEnv() *CallHistory {
+	size := defaultCallHistorySize
+	if v := os.Getenv("ARMOUR_SEMANTIC_HISTORY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			size = n
+		}
+	}
+	args := true
+	switch strings.ToLower(os.Getenv("ARMOUR_SEMANTIC_HISTORY_ARGS")) {
+	case "0", "false", "off", "no":
+		args = false
+	}
+	return NewCallHistory(size, args)
+}
+
+// Record adds a tool call to a session's history.
+func (h *CallHistory) Record(sessionID, toolName string, args map[string]interface{}) {
+	if h == nil || h.size == 0 {
+		return
+	}
+	call := toolName
+	if h.args && len(args) > 0 {
+		call += " " + historyArgs(args)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	calls, exists := h.sessions[sessionID]
+	if !exists {
+		h.order = append(h.order, sessionID)
+		if len(h.order) > maxCallHistorySessions {
+			delete(h.sessions, h.order[0])
+			h.order = h.order[1:]
+		}
+	}
+	calls = append(calls, call)
+	if len(calls) > h.size {
+		calls = calls[len(calls)-h.size:]
+	}
+	h.sessions[sessionID] = calls
+}
+
+// Recent returns a session's earlier calls, oldest first.
+func (h *CallHistory) Recent(sessionID string) []string {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.sessions[sessionID]...)
+}
+
+// historyArgs renders arguments for the history: sensitive data redacted,
+// then cut to callHistoryArgBytes.
+func historyArgs(args map[string]interface{}) string {
+	_, redacted := ScanDLP(redactAllDLP, args)
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		return ""
+	}
+	text := string(data)
+	if len(text) <= callHistoryArgBytes {
+		return text
+	}
+	cut := callHistoryArgBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+// historyPrompt renders earlier calls for a semantic check, or "" if there
+// are none.
+func historyPrompt(history []string) string {
+	if len(history) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\nEarlier tool calls in this session, oldest first:\n")
+	for i, call := range history {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, call)
+	}
+	b.WriteString("Judge the query together with these calls: a sequence, such as reading secrets and then sending data out, can relate to a blocked topic even if no single call does.\n")
+	return b.String()
+}
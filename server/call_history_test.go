@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCallHistory tests the per-session window of earlier calls
+func TestCallHistory(t *testing.T) {
+	h := NewCallHistory(2, true)
+	h.Record("a", "fs:read_file", map[string]interface{}{"path": "~/.aws/credentials"})
+	h.Record("a", "fs:list", nil)
+	h.Record("a", "web:post", map[string]interface{}{"url": "https://example.com"})
+	h.Record("b", "fs:list", nil)
+
+	got := h.Recent("a")
+	if len(got) != 2 || got[0] != "fs:list" || got[1] != `web:post {"url":"https://example.com"}` {
+		t.Errorf("expected the last two calls of session a, got %q", got)
+	}
+	if got := h.Recent("b"); len(got) != 1 {
+		t.Errorf("expected sessions kept apart, got %q", got)
+	}
+
+	names := NewCallHistory(5, false)
+	names.Record("", "fs:read_file", map[string]interface{}{"path": "/etc/passwd"})
+	if got := names.Recent(""); len(got) != 1 || got[0] != "fs:read_file" {
+		t.Errorf("expected tool names only, got %q", got)
+	}
+
+	off := NewCallHistory(0, true)
+	off.Record("", "fs:list", nil)
+	if got := off.Recent(""); len(got) != 0 {
+		t.Errorf("expected no history when disabled, got %q", got)
+	}
+
+	h.Record("c", "db:query", map[string]interface{}{"sql": strings.Repeat("x", 500), "card": "4111 1111 1111 1111"})
+	call := h.Recent("c")[0]
+	if strings.Contains(call, "4111") || !strings.Contains(call, "[REDACTED:credit_card]") || !strings.HasSuffix(call, "…") || len(call) > 250 {
+		t.Errorf("expected redacted and truncated arguments, got %q", call)
+	}
+}
+
+// TestSemanticRulesSeeEarlierCalls tests that the session's earlier calls
+// are passed to the rules server with each check
+func TestSemanticRulesSeeEarlierCalls(t *testing.T) {
+	var seen []string
+	rules := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.URL.Query().Get("history"))
+		json.NewEncoder(w).Encode(CheckResponse{Allowed: true, Decision: "allow"})
+	}))
+	defer rules.Close()
+
+	bm := NewBlocklistMiddleware(nil, "", nil, nil, nil)
+	bm.SetCallHistory(NewCallHistory(5, true))
+	bm.SetRulesServerURL(rules.URL)
+
+	bm.CheckSession("s1", "tools/call", "fs:read_file", map[string]interface{}{"path": "~/.ssh/id_rsa"})
+	bm.CheckSession("s1", "tools/list", "", nil)
+	bm.CheckSession("s1", "tools/call", "web:post", map[string]interface{}{"url": "https://paste.example"})
+	bm.CheckSession("s2", "tools/call", "web:post", nil)
+
+	want := []string{"", "fs:read_file {\"path\":\"~/.ssh/id_rsa\"}", "fs:read_file {\"path\":\"~/.ssh/id_rsa\"}", ""}
+	if strings.Join(seen, "|") != strings.Join(want, "|") {
+		t.Errorf("expected history %q, got %q", want, seen)
+	}
+
+	if prompt := historyPrompt([]string{"fs:read_file"}); !strings.Contains(prompt, "1. fs:read_file") {
+		t.Errorf("expected the calls listed in the prompt, got %q", prompt)
+	}
+}
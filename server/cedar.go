@@ -0,0 +1,666 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// settingCedarPolicy persists the applied cedar section in the rules store.
+const settingCedarPolicy = "cedar_policy"
+
+// CedarPolicy is the `cedar:` section of a policy file: Cedar policies every
+// tool call is authorized against, an alternative to Rego for teams that
+// already use Cedar.
+//
+//	cedar:
+//	  policies: |
+//	    permit(principal, action, resource);
+//
+//	    @id("no-prod-deletes")
+//	    @reason("deleting in prod needs a change ticket")
+//	    forbid(principal, action == Action::"call_tool", resource in Server::"prod-db")
+//	    when { resource.name like "delete*" };
+//
+// A call is authorized with principal Session::"<session id>", action
+// Action::"call_tool" and resource Tool::"<server>:<tool>", which is in
+// Server::"<server>". Sessions have a transport attribute, tools have name
+// and server attributes, and the context holds the call's arguments,
+// transport, host (see HostContext) and, for stdio sessions started in a git
+// repository, repo (see RepoContext). As in Cedar, a call is denied
+// unless a permit policy matches, any matching forbid policy wins, and a
+// policy whose condition fails to evaluate (e.g. reads a missing argument)
+// is ignored.
+type CedarPolicy struct {
+	Policies string `yaml:"policies,omitempty" json:"policies,omitempty"`
+}
+
+// IsZero reports whether the policy sets nothing.
+func (cp CedarPolicy) IsZero() bool {
+	return strings.TrimSpace(cp.Policies) == ""
+}
+
+// Validate checks that the policies parse.
+func (cp CedarPolicy) Validate() error {
+	if _, err := parseCedarPolicies(cp.Policies); err != nil {
+		return fmt.Errorf("cedar.policies: %w", err)
+	}
+	return nil
+}
+
+// String summarizes the policies on one line for diffs.
+func (cp CedarPolicy) String() string {
+	if cp.IsZero() {
+		return ""
+	}
+	policies, _ := parseCedarPolicies(cp.Policies)
+	sum := sha256.Sum256([]byte(cp.Policies))
+	return fmt.Sprintf("%d policies, sha256 %s", len(policies), hex.EncodeToString(sum[:4]))
+}
+
+func encodeCedarPolicy(cp CedarPolicy) (string, error) {
+	if cp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(cp)
+	return string(data), err
+}
+
+func decodeCedarPolicy(raw string) (CedarPolicy, error) {
+	var cp CedarPolicy
+	if raw == "" {
+		return cp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cp); err != nil {
+		return cp, fmt.Errorf("invalid stored cedar policy: %w", err)
+	}
+	return cp, nil
+}
+
+// cedarPolicySet is a compiled cedar section. A nil set allows everything.
+type cedarPolicySet struct {
+	policies []*cedarPolicyAST
+	err      error // the policies don't parse, so every call is denied
+}
+
+// compile parses the policies, or returns nil if there are none.
+func (cp CedarPolicy) compile() *cedarPolicySet {
+	if cp.IsZero() {
+		return nil
+	}
+	policies, err := parseCedarPolicies(cp.Policies)
+	return &cedarPolicySet{policies: policies, err: err}
+}
+
+// cedarDecision is the outcome of authorizing a call.
+type cedarDecision struct {
+	Allowed bool
+	Policy  string   // the forbid policy that denied the call; empty if no permit matched
+	Reason  string   // its @reason annotation
+	Errors  []string // policies ignored because their conditions failed to evaluate
+}
+
+// authorize decides a tool call.
+func (ps *cedarPolicySet) authorize(call PolicyCall) cedarDecision {
+	if ps == nil {
+		return cedarDecision{Allowed: true}
+	}
+	if ps.err != nil {
+		return cedarDecision{Reason: fmt.Sprintf("invalid cedar policies: %v", ps.err)}
+	}
+
+	env := newCedarEnv(call)
+	var decision cedarDecision
+	permitted := false
+	for _, policy := range ps.policies {
+		ok, err := env.satisfies(policy)
+		if err != nil {
+			decision.Errors = append(decision.Errors, fmt.Sprintf("%s: %v", policy.id, err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if policy.forbid {
+			if decision.Policy == "" {
+				decision.Policy = policy.id
+				decision.Reason = policy.annotations["reason"]
+			}
+			continue
+		}
+		permitted = true
+	}
+	decision.Allowed = permitted && decision.Policy == ""
+	return decision
+}
+
+// cedarBlock describes a call the cedar policies deny.
+func cedarBlock(toolName string, d cedarDecision) *BlockError {
+	reason := d.Reason
+	switch {
+	case d.Policy != "" && reason == "":
+		reason = fmt.Sprintf("forbidden by cedar policy %s", d.Policy)
+	case d.Policy != "":
+		reason = fmt.Sprintf("%s (cedar policy %s)", reason, d.Policy)
+	case reason == "":
+		reason = "no cedar policy permits the call"
+	}
+	return &BlockError{
+		BlockedBy: BlockedByCedar,
+		Action:    "forbid",
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    reason,
+		Appeal:    "change the cedar section of the policy file to permit this call",
+	}
+}
+
+// cedarValue is a Cedar value: bool, int64, string, cedarEntity, cedarSet
+// or cedarRecord.
+type cedarValue interface{}
+
+type (
+	cedarEntity struct{ Type, ID string }
+	cedarSet    []cedarValue
+	cedarRecord map[string]cedarValue
+)
+
+func (e cedarEntity) String() string {
+	return fmt.Sprintf("%s::%q", e.Type, e.ID)
+}
+
+// cedarEntityData is an entity's attributes and the entities it is in.
+type cedarEntityData struct {
+	attrs   cedarRecord
+	parents []cedarEntity
+}
+
+// cedarEnv is the request a policy is evaluated against.
+type cedarEnv struct {
+	principal, action, resource cedarEntity
+	context                     cedarRecord
+	entities                    map[cedarEntity]cedarEntityData
+}
+
+// cedarCallAction is the action of every tool call.
+var cedarCallAction = cedarEntity{Type: "Action", ID: "call_tool"}
+
+func newCedarEnv(call PolicyCall) *cedarEnv {
+	_, toolName := parseNamespacedName(call.Tool)
+	session := cedarEntity{Type: "Session", ID: call.SessionID}
+	tool := cedarEntity{Type: "Tool", ID: call.Tool}
+	server := cedarEntity{Type: "Server", ID: call.Server}
+	context := cedarRecord{
+		"arguments": cedarFromJSON(call.Arguments),
+		"transport": call.Transport,
+	}
+	if call.Host != nil {
+		context["host"] = call.Host.cedarRecord()
+	}
+	if call.Repo != nil {
+		context["repo"] = call.Repo.cedarRecord()
+	}
+	return &cedarEnv{
+		principal: session,
+		action:    cedarCallAction,
+		resource:  tool,
+		context:   context,
+		entities: map[cedarEntity]cedarEntityData{
+			session: {attrs: cedarRecord{"transport": call.Transport}},
+			tool:    {attrs: cedarRecord{"name": toolName, "server": call.Server}, parents: []cedarEntity{server}},
+			server:  {attrs: cedarRecord{"name": call.Server}},
+		},
+	}
+}
+
+// cedarFromJSON converts tool call arguments to Cedar values. Cedar has no
+// floats or nulls, so fractional numbers become strings and nulls are left
+// out, which makes `has` false for them.
+func cedarFromJSON(v interface{}) cedarValue {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		rec := make(cedarRecord, len(v))
+		for k, item := range v {
+			if item != nil {
+				rec[k] = cedarFromJSON(item)
+			}
+		}
+		return rec
+	case []interface{}:
+		set := make(cedarSet, 0, len(v))
+		for _, item := range v {
+			if item != nil {
+				set = append(set, cedarFromJSON(item))
+			}
+		}
+		return set
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return int64(v)
+		}
+		return fmt.Sprint(v)
+	case int:
+		return int64(v)
+	}
+	return v
+}
+
+// satisfies reports whether a policy's scope and conditions match.
+func (env *cedarEnv) satisfies(policy *cedarPolicyAST) (bool, error) {
+	if !env.inScope(policy.principal, env.principal) || !env.inScope(policy.action, env.action) || !env.inScope(policy.resource, env.resource) {
+		return false, nil
+	}
+	for _, cond := range policy.conditions {
+		v, err := cond.expr.eval(env)
+		if err != nil {
+			return false, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return false, fmt.Errorf("condition is %s, not a boolean", cedarTypeName(v))
+		}
+		if b == cond.unless {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (env *cedarEnv) inScope(s cedarScope, e cedarEntity) bool {
+	switch s.op {
+	case "==":
+		return e == s.entities[0]
+	case "in":
+		for _, parent := range s.entities {
+			if env.descendantOf(e, parent) {
+				return true
+			}
+		}
+		return false
+	case "is":
+		return e.Type == s.isType && (s.inEntity == nil || env.descendantOf(e, *s.inEntity))
+	}
+	return true
+}
+
+// descendantOf implements Cedar's in: e is ancestor or ancestor is one of
+// the entities e is in, transitively.
+func (env *cedarEnv) descendantOf(e, ancestor cedarEntity) bool {
+	if e == ancestor {
+		return true
+	}
+	for _, parent := range env.entities[e].parents {
+		if env.descendantOf(parent, ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+func cedarTypeName(v cedarValue) string {
+	switch v.(type) {
+	case bool:
+		return "a boolean"
+	case int64:
+		return "a long"
+	case string:
+		return "a string"
+	case cedarEntity:
+		return "an entity"
+	case cedarSet:
+		return "a set"
+	case cedarRecord:
+		return "a record"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func cedarEqual(a, b cedarValue) bool {
+	switch a := a.(type) {
+	case cedarSet:
+		b, ok := b.(cedarSet)
+		if !ok {
+			return false
+		}
+		return cedarSubset(a, b) && cedarSubset(b, a)
+	case cedarRecord:
+		b, ok := b.(cedarRecord)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !cedarEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func cedarContains(set cedarSet, v cedarValue) bool {
+	for _, item := range set {
+		if cedarEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func cedarSubset(a, b cedarSet) bool {
+	for _, item := range a {
+		if !cedarContains(b, item) {
+			return false
+		}
+	}
+	return true
+}
+
+func cedarLikeMatch(s string, pattern []likeElem) bool {
+	if len(pattern) == 0 {
+		return s == ""
+	}
+	if !pattern[0].wildcard {
+		rest, ok := strings.CutPrefix(s, pattern[0].text)
+		return ok && cedarLikeMatch(rest, pattern[1:])
+	}
+	for i := 0; i <= len(s); i++ {
+		if cedarLikeMatch(s[i:], pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *cedarLiteral) eval(env *cedarEnv) (cedarValue, error) {
+	return e.value, nil
+}
+
+func (e *cedarVar) eval(env *cedarEnv) (cedarValue, error) {
+	switch e.name {
+	case "principal":
+		return env.principal, nil
+	case "action":
+		return env.action, nil
+	case "resource":
+		return env.resource, nil
+	}
+	return env.context, nil
+}
+
+func (e *cedarSetExpr) eval(env *cedarEnv) (cedarValue, error) {
+	set := make(cedarSet, len(e.items))
+	for i, item := range e.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		set[i] = v
+	}
+	return set, nil
+}
+
+func (e *cedarRecExpr) eval(env *cedarEnv) (cedarValue, error) {
+	rec := make(cedarRecord, len(e.fields))
+	for name, field := range e.fields {
+		v, err := field.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		rec[name] = v
+	}
+	return rec, nil
+}
+
+// attrs returns the attributes of a record or entity.
+func (env *cedarEnv) attrs(v cedarValue) (cedarRecord, error) {
+	switch v := v.(type) {
+	case cedarRecord:
+		return v, nil
+	case cedarEntity:
+		data, ok := env.entities[v]
+		if !ok {
+			return nil, fmt.Errorf("entity %s does not exist", v)
+		}
+		return data.attrs, nil
+	}
+	return nil, fmt.Errorf("%s has no attributes", cedarTypeName(v))
+}
+
+func (e *cedarAttr) eval(env *cedarEnv) (cedarValue, error) {
+	target, err := e.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := env.attrs(target)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := attrs[e.name]
+	if !ok {
+		return nil, fmt.Errorf("attribute %q does not exist", e.name)
+	}
+	return v, nil
+}
+
+func (e *cedarHas) eval(env *cedarEnv) (cedarValue, error) {
+	target, err := e.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if entity, ok := target.(cedarEntity); ok {
+		_, ok := env.entities[entity].attrs[e.name]
+		return ok, nil
+	}
+	attrs, err := env.attrs(target)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := attrs[e.name]
+	return ok, nil
+}
+
+func (e *cedarLike) eval(env *cedarEnv) (cedarValue, error) {
+	target, err := e.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := target.(string)
+	if !ok {
+		return nil, fmt.Errorf("like needs a string, got %s", cedarTypeName(target))
+	}
+	return cedarLikeMatch(s, e.pattern), nil
+}
+
+func (e *cedarIs) eval(env *cedarEnv) (cedarValue, error) {
+	target, err := e.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	entity, ok := target.(cedarEntity)
+	if !ok {
+		return nil, fmt.Errorf("is needs an entity, got %s", cedarTypeName(target))
+	}
+	if entity.Type != e.typ {
+		return false, nil
+	}
+	if e.in == nil {
+		return true, nil
+	}
+	return (&cedarBinary{"in", &cedarLiteral{entity}, e.in}).eval(env)
+}
+
+func (e *cedarUnary) eval(env *cedarEnv) (cedarValue, error) {
+	v, err := e.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "!" {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("! needs a boolean, got %s", cedarTypeName(v))
+		}
+		return !b, nil
+	}
+	n, ok := v.(int64)
+	if !ok || n == math.MinInt64 {
+		return nil, fmt.Errorf("cannot negate %s", cedarTypeName(v))
+	}
+	return -n, nil
+}
+
+func (e *cedarBinary) eval(env *cedarEnv) (cedarValue, error) {
+	left, err := e.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || short-circuit
+	if e.op == "&&" || e.op == "||" {
+		b, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs booleans, got %s", e.op, cedarTypeName(left))
+		}
+		if b == (e.op == "||") {
+			return b, nil
+		}
+		right, err := e.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := right.(bool); !ok {
+			return nil, fmt.Errorf("%s needs booleans, got %s", e.op, cedarTypeName(right))
+		}
+		return right, nil
+	}
+
+	right, err := e.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "==":
+		return cedarEqual(left, right), nil
+	case "!=":
+		return !cedarEqual(left, right), nil
+	case "in":
+		entity, ok := left.(cedarEntity)
+		if !ok {
+			return nil, fmt.Errorf("in needs an entity on the left, got %s", cedarTypeName(left))
+		}
+		switch right := right.(type) {
+		case cedarEntity:
+			return env.descendantOf(entity, right), nil
+		case cedarSet:
+			for _, item := range right {
+				parent, ok := item.(cedarEntity)
+				if !ok {
+					return nil, fmt.Errorf("in needs a set of entities, got %s in it", cedarTypeName(item))
+				}
+				if env.descendantOf(entity, parent) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return nil, fmt.Errorf("in needs an entity or set on the right, got %s", cedarTypeName(right))
+	}
+
+	a, ok1 := left.(int64)
+	b, ok2 := right.(int64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s needs longs, got %s and %s", e.op, cedarTypeName(left), cedarTypeName(right))
+	}
+	switch e.op {
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	case "+":
+		if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+			return nil, fmt.Errorf("overflow in %d + %d", a, b)
+		}
+		return a + b, nil
+	case "-":
+		if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+			return nil, fmt.Errorf("overflow in %d - %d", a, b)
+		}
+		return a - b, nil
+	case "*":
+		if a != 0 && ((a*b)/a != b || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64)) {
+			return nil, fmt.Errorf("overflow in %d * %d", a, b)
+		}
+		return a * b, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", e.op)
+}
+
+func (e *cedarCall) eval(env *cedarEnv) (cedarValue, error) {
+	target, err := e.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	set, ok := target.(cedarSet)
+	if !ok {
+		return nil, fmt.Errorf("%s needs a set, got %s", e.method, cedarTypeName(target))
+	}
+	args := make([]cedarValue, len(e.args))
+	for i, arg := range e.args {
+		if args[i], err = arg.eval(env); err != nil {
+			return nil, err
+		}
+	}
+
+	switch e.method {
+	case "isEmpty":
+		if len(args) == 0 {
+			return len(set) == 0, nil
+		}
+	case "contains":
+		if len(args) == 1 {
+			return cedarContains(set, args[0]), nil
+		}
+	case "containsAll", "containsAny":
+		if len(args) != 1 {
+			break
+		}
+		other, ok := args[0].(cedarSet)
+		if !ok {
+			return nil, fmt.Errorf("%s needs a set argument, got %s", e.method, cedarTypeName(args[0]))
+		}
+		if e.method == "containsAll" {
+			return cedarSubset(other, set), nil
+		}
+		for _, item := range other {
+			if cedarContains(set, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return nil, fmt.Errorf("unknown method %s", e.method)
+	}
+	return nil, fmt.Errorf("wrong number of arguments to %s", e.method)
+}
+
+func (e *cedarIf) eval(env *cedarEnv) (cedarValue, error) {
+	cond, err := e.cond.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := cond.(bool)
+	if !ok {
+		return nil, fmt.Errorf("if needs a boolean, got %s", cedarTypeName(cond))
+	}
+	if b {
+		return e.then.eval(env)
+	}
+	return e.els.eval(env)
+}
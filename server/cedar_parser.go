@@ -0,0 +1,772 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file parses the Cedar policy language (https://docs.cedarpolicy.com)
+// without templates or extension types (ip, decimal): policies with
+// annotations, scopes, and when/unless conditions over the expression
+// language.
+
+// cedarPolicyAST is one parsed permit or forbid policy.
+type cedarPolicyAST struct {
+	id          string
+	annotations map[string]string
+	forbid      bool
+	principal   cedarScope
+	action      cedarScope
+	resource    cedarScope
+	conditions  []cedarCondition
+}
+
+// cedarScope constrains the principal, action or resource of a request.
+type cedarScope struct {
+	op       string        // "" (any), "==", "in" or "is"
+	entities []cedarEntity // one, or several for action in [...]
+	isType   string        // for "is"
+	inEntity *cedarEntity  // for "is ... in ..."
+}
+
+type cedarCondition struct {
+	unless bool
+	expr   cedarExpr
+}
+
+// cedarExpr is a node of a condition's expression tree.
+type cedarExpr interface {
+	eval(env *cedarEnv) (cedarValue, error)
+}
+
+type (
+	cedarLiteral struct{ value cedarValue }
+	cedarVar     struct{ name string } // principal, action, resource or context
+	cedarSetExpr struct{ items []cedarExpr }
+	cedarRecExpr struct{ fields map[string]cedarExpr }
+	cedarAttr    struct {
+		target cedarExpr
+		name   string
+	}
+	cedarHas struct {
+		target cedarExpr
+		name   string
+	}
+	cedarLike struct {
+		target  cedarExpr
+		pattern []likeElem
+	}
+	cedarIs struct {
+		target cedarExpr
+		typ    string
+		in     cedarExpr // optional
+	}
+	cedarUnary struct {
+		op      string
+		operand cedarExpr
+	}
+	cedarBinary struct {
+		op          string
+		left, right cedarExpr
+	}
+	cedarCall struct {
+		target cedarExpr
+		method string
+		args   []cedarExpr
+	}
+	cedarIf struct{ cond, then, els cedarExpr }
+)
+
+// likeElem is a piece of a like pattern: literal text or a * wildcard.
+type likeElem struct {
+	wildcard bool
+	text     string
+}
+
+type cedarTokenKind int
+
+const (
+	tokEOF cedarTokenKind = iota
+	tokIdent
+	tokString
+	tokInt
+	tokPunct
+)
+
+type cedarToken struct {
+	kind cedarTokenKind
+	text string // identifier, punctuation, digits, or a string's raw contents
+	line int
+}
+
+// lexCedar splits policy text into tokens.
+func lexCedar(src string) ([]cedarToken, error) {
+	var tokens []cedarToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				if j < len(src) && src[j] == '\n' {
+					line++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			tokens = append(tokens, cedarToken{tokString, src[i+1 : j], line})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			tokens = append(tokens, cedarToken{tokInt, src[i:j], line})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, cedarToken{tokIdent, src[i:j], line})
+			i = j
+		default:
+			punct := ""
+			for _, p := range []string{"::", "==", "!=", "<=", ">=", "&&", "||"} {
+				if strings.HasPrefix(src[i:], p) {
+					punct = p
+					break
+				}
+			}
+			if punct == "" {
+				if !strings.ContainsRune("()[]{},;.:@<>!+-*", rune(c)) {
+					return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+				}
+				punct = string(c)
+			}
+			tokens = append(tokens, cedarToken{tokPunct, punct, line})
+			i += len(punct)
+		}
+	}
+	return append(tokens, cedarToken{tokEOF, "", line}), nil
+}
+
+// unescapeCedar decodes the escapes in a string literal's raw contents.
+func unescapeCedar(raw string) (string, error) {
+	if !strings.Contains(raw, `\`) {
+		return raw, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' || i+1 == len(raw) {
+			b.WriteByte(raw[i])
+			continue
+		}
+		i++
+		switch raw[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		case '\\', '"', '\'', '*':
+			b.WriteByte(raw[i])
+		default:
+			return "", fmt.Errorf(`invalid escape \%c`, raw[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// parseLikePattern splits a like pattern on its unescaped * wildcards.
+func parseLikePattern(raw string) ([]likeElem, error) {
+	var elems []likeElem
+	var text strings.Builder
+	flush := func() error {
+		if text.Len() > 0 {
+			s, err := unescapeCedar(text.String())
+			if err != nil {
+				return err
+			}
+			elems = append(elems, likeElem{text: s})
+			text.Reset()
+		}
+		return nil
+	}
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == '\\' && i+1 < len(raw) && raw[i+1] == '*':
+			text.WriteByte('*')
+			i++
+		case raw[i] == '\\' && i+1 < len(raw):
+			text.WriteString(raw[i : i+2])
+			i++
+		case raw[i] == '*':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			elems = append(elems, likeElem{wildcard: true})
+		default:
+			text.WriteByte(raw[i])
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+type cedarParser struct {
+	tokens []cedarToken
+	pos    int
+}
+
+// parseCedarPolicies parses a policy set. Policies without an @id
+// annotation are named policy0, policy1, ... in order, as the Cedar CLI does.
+func parseCedarPolicies(src string) ([]*cedarPolicyAST, error) {
+	tokens, err := lexCedar(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &cedarParser{tokens: tokens}
+	var policies []*cedarPolicyAST
+	ids := make(map[string]bool)
+	for p.peek().kind != tokEOF {
+		policy, err := p.policy()
+		if err != nil {
+			return nil, err
+		}
+		policy.id = policy.annotations["id"]
+		if policy.id == "" {
+			policy.id = fmt.Sprintf("policy%d", len(policies))
+		}
+		if ids[policy.id] {
+			return nil, fmt.Errorf("duplicate policy id %q", policy.id)
+		}
+		ids[policy.id] = true
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (p *cedarParser) peek() cedarToken {
+	return p.tokens[p.pos]
+}
+
+func (p *cedarParser) next() cedarToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given punctuation or keyword.
+func (p *cedarParser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokPunct || t.kind == tokIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *cedarParser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q", text)
+	}
+	return nil
+}
+
+func (p *cedarParser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	found := t.text
+	if t.kind == tokEOF {
+		found = "end of input"
+	}
+	return fmt.Errorf("line %d: %s, found %q", t.line, fmt.Sprintf(format, args...), found)
+}
+
+func (p *cedarParser) policy() (*cedarPolicyAST, error) {
+	policy := &cedarPolicyAST{annotations: make(map[string]string)}
+	for p.accept("@") {
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, p.errorf("expected an annotation name")
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		value := p.next()
+		if value.kind != tokString {
+			return nil, p.errorf("expected a string")
+		}
+		s, err := unescapeCedar(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", value.line, err)
+		}
+		policy.annotations[name.text] = s
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case p.accept("permit"):
+	case p.accept("forbid"):
+		policy.forbid = true
+	default:
+		return nil, p.errorf("expected permit or forbid")
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var err error
+	if policy.principal, err = p.scope("principal"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	if policy.action, err = p.scope("action"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	if policy.resource, err = p.scope("resource"); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	for {
+		var unless bool
+		switch {
+		case p.accept("when"):
+		case p.accept("unless"):
+			unless = true
+		default:
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+			return policy, nil
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		expr, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		policy.conditions = append(policy.conditions, cedarCondition{unless: unless, expr: expr})
+	}
+}
+
+func (p *cedarParser) scope(variable string) (cedarScope, error) {
+	var s cedarScope
+	if err := p.expect(variable); err != nil {
+		return s, err
+	}
+	switch {
+	case p.accept("=="):
+		e, err := p.entity()
+		if err != nil {
+			return s, err
+		}
+		s.op, s.entities = "==", []cedarEntity{e}
+	case p.accept("in"):
+		s.op = "in"
+		if variable == "action" && p.accept("[") {
+			for !p.accept("]") {
+				e, err := p.entity()
+				if err != nil {
+					return s, err
+				}
+				s.entities = append(s.entities, e)
+				if !p.accept(",") {
+					if err := p.expect("]"); err != nil {
+						return s, err
+					}
+					break
+				}
+			}
+			return s, nil
+		}
+		e, err := p.entity()
+		if err != nil {
+			return s, err
+		}
+		s.entities = []cedarEntity{e}
+	case variable != "action" && p.accept("is"):
+		typ, err := p.typeName()
+		if err != nil {
+			return s, err
+		}
+		s.op, s.isType = "is", typ
+		if p.accept("in") {
+			e, err := p.entity()
+			if err != nil {
+				return s, err
+			}
+			s.inEntity = &e
+		}
+	}
+	return s, nil
+}
+
+// typeName parses a possibly namespaced entity type, e.g. Tool or Armour::Tool.
+func (p *cedarParser) typeName() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", p.errorf("expected an entity type")
+	}
+	name := t.text
+	for p.peek().kind == tokPunct && p.peek().text == "::" && p.tokens[p.pos+1].kind == tokIdent {
+		p.pos++
+		name += "::" + p.next().text
+	}
+	return name, nil
+}
+
+// entity parses an entity reference, e.g. Server::"github".
+func (p *cedarParser) entity() (cedarEntity, error) {
+	typ, err := p.typeName()
+	if err != nil {
+		return cedarEntity{}, err
+	}
+	if err := p.expect("::"); err != nil {
+		return cedarEntity{}, err
+	}
+	id := p.next()
+	if id.kind != tokString {
+		return cedarEntity{}, p.errorf("expected an entity id string")
+	}
+	s, err := unescapeCedar(id.text)
+	if err != nil {
+		return cedarEntity{}, fmt.Errorf("line %d: %w", id.line, err)
+	}
+	return cedarEntity{Type: typ, ID: s}, nil
+}
+
+func (p *cedarParser) expr() (cedarExpr, error) {
+	if p.accept("if") {
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		then, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("else"); err != nil {
+			return nil, err
+		}
+		els, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &cedarIf{cond, then, els}, nil
+	}
+	return p.or()
+}
+
+func (p *cedarParser) or() (cedarExpr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &cedarBinary{"||", left, right}
+	}
+	return left, nil
+}
+
+func (p *cedarParser) and() (cedarExpr, error) {
+	left, err := p.relation()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.relation()
+		if err != nil {
+			return nil, err
+		}
+		left = &cedarBinary{"&&", left, right}
+	}
+	return left, nil
+}
+
+func (p *cedarParser) relation() (cedarExpr, error) {
+	left, err := p.add()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if p.accept(op) {
+			right, err := p.add()
+			if err != nil {
+				return nil, err
+			}
+			return &cedarBinary{op, left, right}, nil
+		}
+	}
+	switch {
+	case p.accept("has"):
+		t := p.next()
+		if t.kind == tokString {
+			s, err := unescapeCedar(t.text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", t.line, err)
+			}
+			return &cedarHas{left, s}, nil
+		}
+		if t.kind != tokIdent {
+			return nil, p.errorf("expected an attribute name after has")
+		}
+		return &cedarHas{left, t.text}, nil
+	case p.accept("like"):
+		t := p.next()
+		if t.kind != tokString {
+			return nil, p.errorf("expected a pattern string after like")
+		}
+		pattern, err := parseLikePattern(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", t.line, err)
+		}
+		return &cedarLike{left, pattern}, nil
+	case p.accept("is"):
+		typ, err := p.typeName()
+		if err != nil {
+			return nil, err
+		}
+		is := &cedarIs{target: left, typ: typ}
+		if p.accept("in") {
+			if is.in, err = p.add(); err != nil {
+				return nil, err
+			}
+		}
+		return is, nil
+	}
+	return left, nil
+}
+
+func (p *cedarParser) add() (cedarExpr, error) {
+	left, err := p.mult()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek().text
+		if p.peek().kind != tokPunct || (op != "+" && op != "-") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.mult()
+		if err != nil {
+			return nil, err
+		}
+		left = &cedarBinary{op, left, right}
+	}
+}
+
+func (p *cedarParser) mult() (cedarExpr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("*") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &cedarBinary{"*", left, right}
+	}
+	return left, nil
+}
+
+func (p *cedarParser) unary() (cedarExpr, error) {
+	for _, op := range []string{"!", "-"} {
+		if p.accept(op) {
+			if op == "-" && p.peek().kind == tokInt {
+				n, err := strconv.ParseInt("-"+p.next().text, 10, 64)
+				if err != nil {
+					return nil, p.errorf("integer out of range")
+				}
+				return p.member(&cedarLiteral{n})
+			}
+			operand, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return &cedarUnary{op, operand}, nil
+		}
+	}
+	primary, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	return p.member(primary)
+}
+
+// member parses attribute accesses and method calls after a primary.
+func (p *cedarParser) member(target cedarExpr) (cedarExpr, error) {
+	for {
+		switch {
+		case p.accept("."):
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, p.errorf("expected an attribute or method name")
+			}
+			if !p.accept("(") {
+				target = &cedarAttr{target, name.text}
+				continue
+			}
+			call := &cedarCall{target: target, method: name.text}
+			for !p.accept(")") {
+				arg, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+				if !p.accept(",") {
+					if err := p.expect(")"); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+			target = call
+		case p.peek().kind == tokPunct && p.peek().text == "[" && p.tokens[p.pos+1].kind == tokString:
+			p.pos++
+			s, err := unescapeCedar(p.next().text)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			target = &cedarAttr{target, s}
+		default:
+			return target, nil
+		}
+	}
+}
+
+func (p *cedarParser) primary() (cedarExpr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokInt:
+		p.pos++
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: integer %s out of range", t.line, t.text)
+		}
+		return &cedarLiteral{n}, nil
+	case tokString:
+		p.pos++
+		s, err := unescapeCedar(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", t.line, err)
+		}
+		return &cedarLiteral{s}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			p.pos++
+			return &cedarLiteral{t.text == "true"}, nil
+		case "principal", "action", "resource", "context":
+			p.pos++
+			return &cedarVar{t.text}, nil
+		}
+		e, err := p.entity()
+		if err != nil {
+			return nil, err
+		}
+		return &cedarLiteral{e}, nil
+	}
+
+	switch {
+	case p.accept("("):
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case p.accept("["):
+		set := &cedarSetExpr{}
+		for !p.accept("]") {
+			item, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			set.items = append(set.items, item)
+			if !p.accept(",") {
+				if err := p.expect("]"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		return set, nil
+	case p.accept("{"):
+		rec := &cedarRecExpr{fields: make(map[string]cedarExpr)}
+		for !p.accept("}") {
+			key := p.next()
+			if key.kind != tokIdent && key.kind != tokString {
+				return nil, p.errorf("expected a record field name")
+			}
+			name, err := unescapeCedar(key.text)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			rec.fields[name] = value
+			if !p.accept(",") {
+				if err := p.expect("}"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		return rec, nil
+	}
+	return nil, p.errorf("expected an expression")
+}
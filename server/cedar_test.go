@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCedarAuthorize(t *testing.T) {
+	policies := `
+// Everything is allowed unless forbidden below
+permit(principal, action, resource);
+
+@id("no-prod-deletes")
+@reason("deleting in prod needs a change ticket")
+forbid(principal, action == Action::"call_tool", resource in Server::"prod-db")
+when { resource.name like "delete*" };
+
+@id("main-is-protected")
+forbid(principal, action, resource is Tool)
+when { context.arguments has branch && context.arguments.branch == "main" }
+unless { context.arguments has force_approved && context.arguments.force_approved };
+
+@id("big-batches")
+forbid(principal, action in [Action::"call_tool"], resource == Tool::"db:insert")
+when { context.arguments.rows.containsAny(["admin", "root"]) || context.arguments.count * 2 > 1000 };
+
+@id("http-only-reads")
+forbid(principal, action, resource)
+when { principal.transport == "http" && !(resource.name like "get_*") };
+`
+	cp := CedarPolicy{Policies: policies}
+	if err := cp.Validate(); err != nil {
+		t.Fatalf("Expected valid policies, got %v", err)
+	}
+	set := cp.compile()
+
+	tests := []struct {
+		name      string
+		server    string
+		tool      string
+		args      map[string]interface{}
+		transport string
+		policy    string // the forbid policy expected to deny; "" to allow
+		errors    int
+	}{
+		{"permitted", "github", "list_repos", nil, "stdio", "", 0},
+		{"forbidden in server", "prod-db", "delete_rows", nil, "stdio", "no-prod-deletes", 0},
+		{"other server", "dev-db", "delete_rows", nil, "stdio", "", 0},
+		{"argument condition", "github", "push", map[string]interface{}{"branch": "main"}, "stdio", "main-is-protected", 0},
+		{"unless", "github", "push", map[string]interface{}{"branch": "main", "force_approved": true}, "stdio", "", 0},
+		{"set method", "db", "insert", map[string]interface{}{"rows": []interface{}{"alice", "root"}, "count": 1}, "stdio", "big-batches", 0},
+		{"arithmetic", "db", "insert", map[string]interface{}{"rows": []interface{}{}, "count": 600}, "stdio", "big-batches", 0},
+		{"missing argument is an error", "db", "insert", map[string]interface{}{"count": 1}, "stdio", "", 1},
+		{"principal attribute", "github", "create_issue", nil, "http", "http-only-reads", 0},
+		{"principal attribute allows", "github", "get_issue", nil, "http", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := set.authorize(PolicyCall{Server: tt.server, Tool: tt.server + ":" + tt.tool, Arguments: tt.args, SessionID: "s1", Transport: tt.transport})
+			if d.Allowed != (tt.policy == "") || d.Policy != tt.policy {
+				t.Errorf("Expected policy %q to decide, got %+v", tt.policy, d)
+			}
+			if len(d.Errors) != tt.errors {
+				t.Errorf("Expected %d evaluation errors, got %v", tt.errors, d.Errors)
+			}
+		})
+	}
+
+	block := cedarBlock("prod-db:delete_rows", set.authorize(PolicyCall{Server: "prod-db", Tool: "prod-db:delete_rows"}))
+	if block.BlockedBy != BlockedByCedar || block.Reason != "deleting in prod needs a change ticket (cedar policy no-prod-deletes)" {
+		t.Errorf("Unexpected block %+v", block)
+	}
+}
+
+func TestCedarDefaultDeny(t *testing.T) {
+	set := CedarPolicy{Policies: `permit(principal, action, resource in Server::"github");`}.compile()
+	if d := set.authorize(PolicyCall{Server: "github", Tool: "github:list_repos"}); !d.Allowed {
+		t.Errorf("Expected github to be permitted, got %+v", d)
+	}
+	d := set.authorize(PolicyCall{Server: "slack", Tool: "slack:post"})
+	if d.Allowed || d.Policy != "" {
+		t.Errorf("Expected calls no policy permits to be denied, got %+v", d)
+	}
+	if block := cedarBlock("slack:post", d); block.Reason != "no cedar policy permits the call" {
+		t.Errorf("Unexpected reason %q", block.Reason)
+	}
+
+	var none *cedarPolicySet
+	if d := none.authorize(PolicyCall{Tool: "slack:post"}); !d.Allowed {
+		t.Error("Expected no cedar section to allow everything")
+	}
+}
+
+func TestCedarParseErrors(t *testing.T) {
+	for _, policies := range []string{
+		`permit(principal, action, resource)`,
+		`allow(principal, action, resource);`,
+		`permit(principal, action, resource) when { context.x == };`,
+		`permit(principal == "alice", action, resource);`,
+		`permit(principal, action, resource) when { "unterminated };`,
+		"@id(\"a\") permit(principal, action, resource);\n@id(\"a\") forbid(principal, action, resource);",
+	} {
+		if err := (CedarPolicy{Policies: policies}).Validate(); err == nil {
+			t.Errorf("Expected %q to be rejected", policies)
+		}
+	}
+}
+
+func TestCedarHostContext(t *testing.T) {
+	set := CedarPolicy{Policies: `
+permit(principal, action, resource);
+
+@id("prod-db-on-vpn")
+@reason("production databases need the VPN")
+forbid(principal, action, resource in Server::"prod-db")
+unless { context has host && context.host.vpn };
+
+@id("no-cafe-wifi")
+forbid(principal, action, resource)
+when { context.host has ssid && context.host.ssid like "Cafe*" };
+`}.compile()
+
+	offVPN := &HostContext{Hostname: "laptop", User: "dev", OS: "linux"}
+	onVPN := &HostContext{Hostname: "laptop", User: "dev", OS: "linux", VPN: true, VPNInterfaces: []string{"wg0"}}
+	if d := set.authorize(PolicyCall{Server: "prod-db", Tool: "prod-db:query", Host: offVPN}); d.Allowed || d.Policy != "prod-db-on-vpn" {
+		t.Errorf("expected prod-db denied off VPN, got %+v", d)
+	}
+	if d := set.authorize(PolicyCall{Server: "prod-db", Tool: "prod-db:query", Host: onVPN}); !d.Allowed {
+		t.Errorf("expected prod-db allowed on VPN, got %+v", d)
+	}
+	onVPN.SSID = "Cafe Central"
+	if d := set.authorize(PolicyCall{Server: "github", Tool: "github:list_repos", Host: onVPN}); d.Allowed || d.Policy != "no-cafe-wifi" {
+		t.Errorf("expected calls denied on cafe Wi-Fi, got %+v", d)
+	}
+
+	// The proxy's own host context is collected once and shown in health
+	hc := CurrentHostContext()
+	if hc == nil || hc.OS == "" || hc.CollectedAt.IsZero() || CurrentHostContext() != hc {
+		t.Errorf("expected the host context collected and cached, got %+v", hc)
+	}
+	if report := BuildHealthReport(context.Background(), nil, nil, ""); report.Host != hc {
+		t.Errorf("expected the host context in the health report, got %+v", report.Host)
+	}
+}
+
+func TestCedarLike(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{`delete*`, "delete_repo", true},
+		{`delete*`, "undelete", false},
+		{`*_repo`, "delete_repo", true},
+		{`a*b*c`, "axxbyyc", true},
+		{`a*b*c`, "axxbyy", false},
+		{`star\*`, "star*", true},
+		{`star\*`, "stars", false},
+		{``, "", true},
+	}
+	for _, tt := range tests {
+		pattern, err := parseLikePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.pattern, err)
+		}
+		if got := cedarLikeMatch(tt.s, pattern); got != tt.match {
+			t.Errorf("%q like %q: expected %v", tt.s, tt.pattern, tt.match)
+		}
+	}
+}
+
+// TestPolicyFileCedarRoundTrip tests that the cedar section is stored and exported
+func TestPolicyFileCedarRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, DefaultPolicyFile)
+	content := `
+cedar:
+  policies: |
+    permit(principal, action, resource);
+    forbid(principal, action, resource in Server::"prod-db");
+`
+	if err := os.WriteFile(policyPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+	pf, err := LoadPolicyFile(policyPath)
+	if err != nil {
+		t.Fatalf("Failed to load policy file: %v", err)
+	}
+
+	rs, err := NewRulesServer(RulesServerConfig{DBPath: filepath.Join(dir, "rules.db")})
+	if err != nil {
+		t.Fatalf("Failed to create rules server: %v", err)
+	}
+	defer rs.db.Close()
+
+	diff, err := ApplyPolicy(rs.store, pf)
+	if err != nil {
+		t.Fatalf("Failed to apply policy: %v", err)
+	}
+	if !strings.Contains(FormatPolicyDiff(diff), `~ cedar: "" -> "2 policies`) {
+		t.Errorf("Expected cedar change in the diff, got %q", FormatPolicyDiff(diff))
+	}
+
+	sp, err := LoadStoredPolicy(rs.store)
+	if err != nil {
+		t.Fatalf("Failed to load stored policy: %v", err)
+	}
+	if sp.Cedar.Policies != pf.Cedar.Policies {
+		t.Errorf("Expected stored cedar policies %q, got %q", pf.Cedar.Policies, sp.Cedar.Policies)
+	}
+	exported, err := ExportPolicy(rs.store)
+	if err != nil {
+		t.Fatalf("Failed to export policy: %v", err)
+	}
+	if exported.Cedar.Policies != pf.Cedar.Policies {
+		t.Errorf("Expected exported cedar policies %q, got %q", pf.Cedar.Policies, exported.Cedar.Policies)
+	}
+	if diff, err := DiffPolicy(rs.store, pf); err != nil || !diff.Empty() {
+		t.Errorf("Expected no drift after apply, got %v, %v", diff, err)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// ChaosFault is artificial trouble injected into a backend's requests for
+// testing timeouts, retries, circuit breakers and health displays. Every
+// request, keepalive pings included, waits LatencyMs before it is sent, then
+// fails with ErrChaosFault with probability ErrorRate. Notifications the
+// backend sends are dropped with probability DropRate.
+type ChaosFault struct {
+	Server    string  `json:"server"`
+	LatencyMs int64   `json:"latency_ms"`
+	ErrorRate float64 `json:"error_rate"`
+	DropRate  float64 `json:"drop_rate"`
+}
+
+// ErrChaosFault is the failure a chaos fault injects.
+var ErrChaosFault = errors.New("chaos: injected failure")
+
+// ErrChaosDisabled is returned when faults are injected into a proxy that
+// wasn't started with chaos testing enabled.
+var ErrChaosDisabled = errors.New("chaos testing is disabled; start the proxy with -chaos")
+
+// Validate checks that rates are probabilities and latency isn't negative.
+func (f ChaosFault) Validate() error {
+	if f.Server == "" {
+		return fmt.Errorf("server is required")
+	}
+	if f.LatencyMs < 0 {
+		return fmt.Errorf("latency_ms must not be negative")
+	}
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return fmt.Errorf("error_rate must be between 0 and 1")
+	}
+	if f.DropRate < 0 || f.DropRate > 1 {
+		return fmt.Errorf("drop_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// EnableChaos allows faults to be injected with SetChaosFault. It is off by
+// default so a production proxy can't be slowed down through the API.
+func (bm *BackendManager) EnableChaos() {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.chaos == nil {
+		bm.chaos = make(map[string]ChaosFault)
+	}
+}
+
+// ChaosEnabled reports whether faults may be injected.
+func (bm *BackendManager) ChaosEnabled() bool {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+	return bm.chaos != nil
+}
+
+// SetChaosFault injects a fault into a backend's requests, replacing any
+// earlier one. It applies to the backend's connection now and after restarts.
+func (bm *BackendManager) SetChaosFault(fault ChaosFault) error {
+	if err := fault.Validate(); err != nil {
+		return err
+	}
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.chaos == nil {
+		return ErrChaosDisabled
+	}
+	bm.chaos[fault.Server] = fault
+	return nil
+}
+
+// ClearChaosFault removes a backend's fault.
+func (bm *BackendManager) ClearChaosFault(server string) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	delete(bm.chaos, server)
+}
+
+// ChaosFaults returns the injected faults sorted by server.
+func (bm *BackendManager) ChaosFaults() []ChaosFault {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+	faults := make([]ChaosFault, 0, len(bm.chaos))
+	for _, fault := range bm.chaos {
+		faults = append(faults, fault)
+	}
+	sort.Slice(faults, func(i, j int) bool { return faults[i].Server < faults[j].Server })
+	return faults
+}
+
+// chaosFault returns the fault injected into a backend, if any.
+func (bm *BackendManager) chaosFault(server string) (ChaosFault, bool) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+	fault, ok := bm.chaos[server]
+	return fault, ok
+}
+
+// injectChaos delays or fails a request to the backend as its fault says.
+func (bc *BackendConnection) injectChaos(ctx context.Context) error {
+	if bc.chaos == nil {
+		return nil
+	}
+	fault, ok := bc.chaos()
+	if !ok {
+		return nil
+	}
+	if fault.LatencyMs > 0 {
+		timer := time.NewTimer(time.Duration(fault.LatencyMs) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return fmt.Errorf("request cancelled: %v", ctx.Err())
+		}
+	}
+	if fault.ErrorRate > 0 && rand.Float64() < fault.ErrorRate {
+		return ErrChaosFault
+	}
+	return nil
+}
+
+// dropNotification reports whether the backend's fault drops a notification.
+func (bc *BackendConnection) dropNotification() bool {
+	if bc.chaos == nil {
+		return false
+	}
+	fault, ok := bc.chaos()
+	return ok && fault.DropRate > 0 && rand.Float64() < fault.DropRate
+}
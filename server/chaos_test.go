@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestChaosFaults(t *testing.T) {
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "flaky"}}}
+	bm := NewBackendManager(registry, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	if err := bm.SetChaosFault(ChaosFault{Server: "flaky", ErrorRate: 1}); !errors.Is(err, ErrChaosDisabled) {
+		t.Fatalf("expected faults to need chaos enabled, got %v", err)
+	}
+	bm.EnableChaos()
+	for _, fault := range []ChaosFault{{}, {Server: "flaky", LatencyMs: -1}, {Server: "flaky", ErrorRate: 1.5}, {Server: "flaky", DropRate: -0.1}} {
+		if err := bm.SetChaosFault(fault); err == nil {
+			t.Errorf("expected %+v to be rejected", fault)
+		}
+	}
+
+	transport := newMockTransport(&MockFixture{})
+	var relayed []string
+	conn := &BackendConnection{
+		config:      &registry.Servers[0],
+		transport:   transport,
+		logger:      bm.logger,
+		initialized: true,
+		idle:        idleSessions(1, transport),
+		chaos:       func() (ChaosFault, bool) { return bm.chaosFault("flaky") },
+		notify:      func(method string, params json.RawMessage) { relayed = append(relayed, method) },
+	}
+	bm.connections["flaky"] = conn
+	ctx := context.Background()
+
+	if err := bm.SetChaosFault(ChaosFault{Server: "flaky", LatencyMs: 50}); err != nil {
+		t.Fatalf("failed to inject latency: %v", err)
+	}
+	start := time.Now()
+	if err := conn.ping(ctx); err != nil {
+		t.Fatalf("expected a slow ping to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected the ping to take at least 50ms, took %v", elapsed)
+	}
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := conn.ping(short); err == nil {
+		t.Error("expected a ping with a shorter deadline than the latency to fail")
+	}
+
+	if err := bm.SetChaosFault(ChaosFault{Server: "flaky", ErrorRate: 1, DropRate: 1}); err != nil {
+		t.Fatalf("failed to inject errors: %v", err)
+	}
+	for i := 0; i < maxMissedPings; i++ {
+		if err := conn.ping(ctx); !errors.Is(err, ErrChaosFault) {
+			t.Fatalf("expected an injected failure, got %v", err)
+		}
+	}
+	if health := bm.BackendHealth(); len(health) != 1 || health[0].Status != HealthUnhealthy {
+		t.Errorf("expected the backend to be marked unhealthy, got %+v", health)
+	}
+	if faults := bm.ChaosFaults(); len(faults) != 1 || faults[0].ErrorRate != 1 {
+		t.Errorf("unexpected faults %+v", faults)
+	}
+
+	// Dropped notifications never reach the handler, but the response does
+	bm.ClearChaosFault("flaky")
+	if err := conn.ping(ctx); err != nil {
+		t.Fatalf("expected pings to succeed once cleared, got %v", err)
+	}
+	if err := bm.SetChaosFault(ChaosFault{Server: "flaky", DropRate: 1}); err != nil {
+		t.Fatalf("failed to inject drops: %v", err)
+	}
+	transport.pending <- []byte(`{"jsonrpc":"2.0","method":"notifications/prompts/list_changed"}`)
+	if err := conn.ping(ctx); err != nil {
+		t.Fatalf("expected the ping to succeed, got %v", err)
+	}
+	bm.ClearChaosFault("flaky")
+	transport.pending <- []byte(`{"jsonrpc":"2.0","method":"notifications/prompts/list_changed"}`)
+	if err := conn.ping(ctx); err != nil {
+		t.Fatalf("expected the ping to succeed, got %v", err)
+	}
+	if len(relayed) != 1 {
+		t.Errorf("expected only the notification sent after clearing to be relayed, got %v", relayed)
+	}
+}
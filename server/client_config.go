@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// ReMigrateCommand routes servers a client runs directly back through the proxy.
+const ReMigrateCommand = "mcp-proxy migrate"
+
+// ClientBypass is an MCP server a client config runs directly instead of
+// through the proxy, typically one an agent or installer silently re-added.
+type ClientBypass struct {
+	Client     string `json:"client"` // claude-code, claude-desktop or cursor
+	ConfigPath string `json:"config_path"`
+	Project    string `json:"project,omitempty"` // for per-project entries in ~/.claude.json
+	Server     string `json:"server"`
+	Registered bool   `json:"registered"` // the same server is registered in the proxy too
+}
+
+func (b ClientBypass) String() string {
+	where := b.ConfigPath
+	if b.Project != "" {
+		where += " [" + b.Project + "]"
+	}
+	s := fmt.Sprintf("%s: %s runs %q directly, bypassing the proxy", b.Client, where, b.Server)
+	if b.Registered {
+		s += " (it is also registered in the proxy)"
+	}
+	return s
+}
+
+// ClientConfigReport is the result of checking MCP client configs on startup.
+type ClientConfigReport struct {
+	CheckedAt time.Time      `json:"checked_at"`
+	Configs   []string       `json:"configs"` // client configs found and read
+	Bypasses  []ClientBypass `json:"bypasses,omitempty"`
+	Remedy    string         `json:"remedy,omitempty"`
+}
+
+// clientConfig is a config file an MCP client reads its servers from.
+type clientConfig struct {
+	client string
+	path   string
+}
+
+// clientConfigPaths lists the configs of Claude Code, Claude Desktop and
+// Cursor, user-level and for projectDir.
+func clientConfigPaths(homeDir, projectDir string) []clientConfig {
+	configs := []clientConfig{
+		{"claude-code", filepath.Join(homeDir, ".claude.json")},
+		{"claude-code", filepath.Join(homeDir, ".claude", ".mcp.json")},
+		{"cursor", filepath.Join(homeDir, ".cursor", "mcp.json")},
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		configs = append(configs, clientConfig{"claude-desktop", filepath.Join(homeDir, "Library", "Application Support", "Claude", "claude_desktop_config.json")})
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			configs = append(configs, clientConfig{"claude-desktop", filepath.Join(appData, "Claude", "claude_desktop_config.json")})
+		}
+	default:
+		configs = append(configs, clientConfig{"claude-desktop", filepath.Join(homeDir, ".config", "Claude", "claude_desktop_config.json")})
+	}
+	if projectDir != "" && projectDir != homeDir {
+		configs = append(configs,
+			clientConfig{"claude-code", filepath.Join(projectDir, ".mcp.json")},
+			clientConfig{"cursor", filepath.Join(projectDir, ".cursor", "mcp.json")},
+		)
+	}
+	return configs
+}
+
+// clientServerEntry is a server entry in a client config.
+type clientServerEntry struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	URL     string            `json:"url"`
+	Env     map[string]string `json:"env"`
+}
+
+// IsProxyServer reports whether a client config entry runs the proxy itself,
+// recognized the way detection skips it: by name, by command, or by the
+// MCP_PROXY_CONFIG variable migrate sets.
+func IsProxyServer(name, command string, env map[string]string) bool {
+	name = strings.ToLower(name)
+	base := strings.ToLower(filepath.Base(command))
+	for _, marker := range []string{"mcp-proxy", "mcp-go-proxy", "armour", "sentinel"} {
+		if strings.Contains(name, marker) || strings.Contains(base, marker) {
+			return true
+		}
+	}
+	_, ok := env["MCP_PROXY_CONFIG"]
+	return ok
+}
+
+// CheckClientConfigs reads the MCP client configs under homeDir and
+// projectDir and reports every server that a client runs directly rather
+// than through the proxy. Missing or unreadable configs are skipped.
+func CheckClientConfigs(homeDir, projectDir string, registry *proxy.ServerRegistry) *ClientConfigReport {
+	report := &ClientConfigReport{CheckedAt: time.Now().UTC(), Configs: []string{}}
+	for _, cc := range clientConfigPaths(homeDir, projectDir) {
+		data, err := os.ReadFile(cc.path)
+		if err != nil {
+			continue
+		}
+		var config struct {
+			MCPServers map[string]json.RawMessage `json:"mcpServers"`
+			Projects   map[string]struct {
+				MCPServers map[string]json.RawMessage `json:"mcpServers"`
+			} `json:"projects"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			continue
+		}
+		report.Configs = append(report.Configs, cc.path)
+
+		report.Bypasses = append(report.Bypasses, directServers(cc, "", config.MCPServers, registry)...)
+		for project, p := range config.Projects {
+			report.Bypasses = append(report.Bypasses, directServers(cc, project, p.MCPServers, registry)...)
+		}
+	}
+	sort.SliceStable(report.Bypasses, func(i, j int) bool {
+		a, b := report.Bypasses[i], report.Bypasses[j]
+		if a.ConfigPath != b.ConfigPath {
+			return a.ConfigPath < b.ConfigPath
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.Server < b.Server
+	})
+	if len(report.Bypasses) > 0 {
+		report.Remedy = ReMigrateCommand
+	}
+	return report
+}
+
+// directServers returns the entries of one mcpServers map that don't run the proxy.
+func directServers(cc clientConfig, project string, servers map[string]json.RawMessage, registry *proxy.ServerRegistry) []ClientBypass {
+	var bypasses []ClientBypass
+	for name, raw := range servers {
+		var entry clientServerEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			continue
+		}
+		if IsProxyServer(name, entry.Command, entry.Env) {
+			continue
+		}
+		bypasses = append(bypasses, ClientBypass{
+			Client:     cc.client,
+			ConfigPath: cc.path,
+			Project:    project,
+			Server:     name,
+			Registered: registeredInProxy(name, entry, registry),
+		})
+	}
+	return bypasses
+}
+
+// registeredInProxy reports whether the registry has a server by the same
+// name, URL or command line.
+func registeredInProxy(name string, entry clientServerEntry, registry *proxy.ServerRegistry) bool {
+	if registry == nil {
+		return false
+	}
+	for _, s := range registry.Servers {
+		switch {
+		case s.Name == name:
+			return true
+		case entry.URL != "" && s.URL == entry.URL:
+			return true
+		case entry.Command != "" && s.Command == entry.Command && strings.Join(s.Args, " ") == strings.Join(entry.Args, " "):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func writeJSONFile(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	data, _ := json.Marshal(v)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// TestCheckClientConfigs tests that servers a client runs directly are
+// reported, and the proxy's own entries are not
+func TestCheckClientConfigs(t *testing.T) {
+	home := t.TempDir()
+	project := filepath.Join(home, "src", "app")
+	proxyEntry := map[string]interface{}{
+		"command": "/usr/local/bin/mcp-proxy",
+		"args":    []string{"-mode", "stdio"},
+		"env":     map[string]string{"MCP_PROXY_CONFIG": "servers.json"},
+	}
+	writeJSONFile(t, filepath.Join(home, ".claude.json"), map[string]interface{}{
+		"projects": map[string]interface{}{
+			project: map[string]interface{}{"mcpServers": map[string]interface{}{
+				"sentinel": proxyEntry,
+				"github":   map[string]interface{}{"command": "npx", "args": []string{"-y", "@modelcontextprotocol/server-github"}},
+			}},
+		},
+	})
+	writeJSONFile(t, filepath.Join(home, ".cursor", "mcp.json"), map[string]interface{}{
+		"mcpServers": map[string]interface{}{"docs": map[string]interface{}{"url": "https://docs.example.com/mcp"}},
+	})
+	writeJSONFile(t, filepath.Join(project, ".mcp.json"), map[string]interface{}{
+		"mcpServers": map[string]interface{}{"armour": proxyEntry},
+	})
+
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "gh", Transport: "stdio", Command: "npx", Args: []string{"-y", "@modelcontextprotocol/server-github"}},
+	}}
+	report := CheckClientConfigs(home, project, registry)
+	if len(report.Configs) != 3 {
+		t.Errorf("expected 3 configs read, got %v", report.Configs)
+	}
+	if len(report.Bypasses) != 2 || report.Remedy != ReMigrateCommand {
+		t.Fatalf("expected 2 bypasses with a remedy, got %+v", report)
+	}
+	github, docs := report.Bypasses[0], report.Bypasses[1]
+	if github.Server != "github" || github.Client != "claude-code" || github.Project != project || !github.Registered {
+		t.Errorf("expected the re-added github server matched to the registry, got %+v", github)
+	}
+	if docs.Server != "docs" || docs.Client != "cursor" || docs.Registered {
+		t.Errorf("expected the unregistered cursor server, got %+v", docs)
+	}
+
+	var health HealthReport
+	health.Status = HealthOK
+	health.SetClients(report)
+	if health.Status != HealthDegraded {
+		t.Errorf("expected bypasses to degrade health, got %s", health.Status)
+	}
+	if clean := CheckClientConfigs(t.TempDir(), "", registry); len(clean.Bypasses) != 0 || clean.Remedy != "" {
+		t.Errorf("expected no bypasses without client configs, got %+v", clean)
+	}
+}
+
+// TestMigrateKeepsRegisteredServers tests that re-running a migration for
+// a re-added server keeps the servers registered before and the proxy entry
+func TestMigrateKeepsRegisteredServers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	cm, err := NewConfigMigrator()
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+	writeJSONFile(t, filepath.Join(home, ".claude.json"), map[string]interface{}{"projects": map[string]interface{}{}})
+
+	if _, err := cm.MigrateWithServers([]DetectedServer{{Name: "fs", Type: "stdio", Command: "fs-server"}}, "moderate"); err != nil {
+		t.Fatalf("first migration failed: %v", err)
+	}
+	if _, err := cm.MigrateWithServers([]DetectedServer{{Name: "github", Type: "stdio", Command: "npx"}}, "moderate"); err != nil {
+		t.Fatalf("re-migration failed: %v", err)
+	}
+
+	registry, err := proxy.LoadServerRegistry(cm.proxyConfigPath)
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+	if len(registry.Servers) != 2 || registry.Servers[0].Name != "github" || registry.Servers[1].Name != "fs" {
+		t.Errorf("expected github added next to fs, got %+v", registry.Servers)
+	}
+	if report := CheckClientConfigs(home, "", registry); len(report.Bypasses) != 0 {
+		t.Errorf("expected clients to route through the proxy after migrating, got %+v", report.Bypasses)
+	}
+	data, _ := os.ReadFile(filepath.Join(home, ".claude.json"))
+	var config struct {
+		Projects map[string]struct {
+			MCPServers map[string]interface{} `json:"mcpServers"`
+		} `json:"projects"`
+	}
+	json.Unmarshal(data, &config)
+	found := false
+	for _, p := range config.Projects {
+		_, ok := p.MCPServers["sentinel"]
+		found = found || ok
+	}
+	if !found {
+		t.Errorf("expected the proxy entry kept in ~/.claude.json, got %s", data)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// maxCompletionValues is the most values a completion result may carry.
+const maxCompletionValues = 100
+
+// CompletionRef is what a completion/complete request completes an argument
+// of: a prompt by name or a resource template by URI.
+type CompletionRef struct {
+	Type string `json:"type"` // ref/prompt or ref/resource
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// Completion is the completion object of a completion/complete result.
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+// validate checks the ref has the fields its type requires.
+func (r CompletionRef) validate() error {
+	switch r.Type {
+	case "ref/prompt":
+		if r.Name == "" {
+			return fmt.Errorf("ref/prompt requires a name")
+		}
+	case "ref/resource":
+		if r.URI == "" {
+			return fmt.Errorf("ref/resource requires a uri")
+		}
+	default:
+		return fmt.Errorf("unknown ref type %q: must be ref/prompt or ref/resource", r.Type)
+	}
+	return nil
+}
+
+// backendRef maps a ref from the client's namespace (servername:prompt,
+// armour://servername/uri) to the backend that owns it and the ref as that
+// backend knows it. The backend is empty if the ref isn't namespaced.
+func (r CompletionRef) backendRef() (string, CompletionRef) {
+	if r.Type == "ref/prompt" {
+		backend, name := parseNamespacedName(r.Name)
+		if backend == "" {
+			return "", r
+		}
+		r.Name = name
+		return backend, r
+	}
+	backend, uri := parseArmourURI(r.URI)
+	if backend == "" {
+		return "", r
+	}
+	r.URI = uri
+	return backend, r
+}
+
+// clamp enforces the spec's limit of 100 values per result.
+func (c Completion) clamp() Completion {
+	if c.Values == nil {
+		c.Values = []string{}
+	}
+	if len(c.Values) > maxCompletionValues {
+		if c.Total < len(c.Values) {
+			c.Total = len(c.Values)
+		}
+		c.Values = c.Values[:maxCompletionValues]
+		c.HasMore = true
+	}
+	return c
+}
+
+// mergeCompletions combines completions from several backends: values are
+// deduplicated in order, totals add up, and the result has more if any part
+// did or the merged values had to be cut.
+func mergeCompletions(completions []Completion) Completion {
+	merged := Completion{Values: []string{}}
+	seen := make(map[string]bool)
+	for _, c := range completions {
+		for _, v := range c.Values {
+			if !seen[v] {
+				seen[v] = true
+				merged.Values = append(merged.Values, v)
+			}
+		}
+		if c.Total > len(c.Values) {
+			merged.Total += c.Total
+		} else {
+			merged.Total += len(c.Values)
+		}
+		merged.HasMore = merged.HasMore || c.HasMore
+	}
+	if merged.Total <= len(merged.Values) && !merged.HasMore {
+		merged.Total = 0 // nothing beyond what's listed, so don't bother saying
+	}
+	return merged.clamp()
+}
+
+// completeAll asks every backend that advertises completions and merges the
+// answers. It is used for refs that don't name a backend.
+func (s *StdioServer) completeAll(ctx context.Context, ref CompletionRef, argument, completionContext interface{}) Completion {
+	backends := s.backendManager.GetInitializedBackends()
+	sort.Slice(backends, func(i, j int) bool { return backends[i].config.Name < backends[j].config.Name })
+
+	var completions []Completion
+	for _, backend := range backends {
+		if backend.Capabilities == nil || backend.Capabilities.Completions == nil {
+			continue
+		}
+		c, err := s.backendManager.GetCompletion(ctx, backend.config.Name, ref, argument, completionContext)
+		if err != nil {
+			s.logger.Debug("no completion from backend %s: %v", backend.config.Name, err)
+			continue
+		}
+		completions = append(completions, c)
+	}
+	return mergeCompletions(completions)
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestCompletionRefBackendRef(t *testing.T) {
+	tests := []struct {
+		ref     CompletionRef
+		backend string
+		mapped  CompletionRef
+	}{
+		{CompletionRef{Type: "ref/prompt", Name: "git:commit"}, "git", CompletionRef{Type: "ref/prompt", Name: "commit"}},
+		{CompletionRef{Type: "ref/resource", URI: "armour://fs/file:///{path}"}, "fs", CompletionRef{Type: "ref/resource", URI: "file:///{path}"}},
+		{CompletionRef{Type: "ref/prompt", Name: "commit"}, "", CompletionRef{Type: "ref/prompt", Name: "commit"}},
+		{CompletionRef{Type: "ref/resource", URI: "file:///{path}"}, "", CompletionRef{Type: "ref/resource", URI: "file:///{path}"}},
+	}
+	for _, tt := range tests {
+		backend, mapped := tt.ref.backendRef()
+		if backend != tt.backend || mapped != tt.mapped {
+			t.Errorf("%+v: expected %q %+v, got %q %+v", tt.ref, tt.backend, tt.mapped, backend, mapped)
+		}
+	}
+
+	for _, bad := range []CompletionRef{{Type: "ref/prompt"}, {Type: "ref/resource"}, {Type: "ref/tool", Name: "x"}} {
+		if bad.validate() == nil {
+			t.Errorf("expected %+v to be invalid", bad)
+		}
+	}
+}
+
+func TestMergeCompletions(t *testing.T) {
+	merged := mergeCompletions([]Completion{
+		{Values: []string{"main", "dev"}},
+		{Values: []string{"dev", "release"}, Total: 10, HasMore: true},
+	})
+	if fmt.Sprint(merged.Values) != "[main dev release]" || merged.Total != 12 || !merged.HasMore {
+		t.Errorf("unexpected merge: %+v", merged)
+	}
+
+	if merged := mergeCompletions(nil); merged.Values == nil || len(merged.Values) != 0 || merged.HasMore {
+		t.Errorf("expected empty completion, got %+v", merged)
+	}
+
+	var many []string
+	for i := 0; i < 150; i++ {
+		many = append(many, fmt.Sprintf("v%d", i))
+	}
+	merged = mergeCompletions([]Completion{{Values: many}})
+	if len(merged.Values) != maxCompletionValues || merged.Total != 150 || !merged.HasMore {
+		t.Errorf("expected values cut to %d with total 150, got %d values, %+v", maxCompletionValues, len(merged.Values), merged.Total)
+	}
+}
+
+func TestCompletionComplete(t *testing.T) {
+	dir := t.TempDir()
+	var servers []proxy.ServerEntry
+	for _, name := range []string{"git", "hg"} {
+		path := filepath.Join(dir, name+".yaml")
+		fixture := "prompts:\n  - name: commit\n    arguments: [{name: branch, values: [" + name + "-main, " + name + "-dev, other]}]\n"
+		if err := os.WriteFile(path, []byte(fixture), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+		servers = append(servers, proxy.ServerEntry{Name: name, Transport: "mock", Fixture: path})
+	}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, &proxy.ServerRegistry{Servers: servers}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	complete := func(params string) JSONRPCResponse {
+		t.Helper()
+		return srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "completion/complete", Params: json.RawMessage(params)}).(JSONRPCResponse)
+	}
+	values := func(resp JSONRPCResponse) string {
+		t.Helper()
+		if resp.Error != nil {
+			t.Fatalf("completion failed: %+v", resp.Error)
+		}
+		data, _ := json.Marshal(resp.Result)
+		var result struct {
+			Completion Completion `json:"completion"`
+		}
+		json.Unmarshal(data, &result)
+		return fmt.Sprint(result.Completion.Values)
+	}
+
+	// A namespaced prompt goes to its backend only
+	if got := values(complete(`{"ref":{"type":"ref/prompt","name":"git:commit"},"argument":{"name":"branch","value":"git"}}`)); got != "[git-main git-dev]" {
+		t.Errorf("expected git's values, got %s", got)
+	}
+	// A bare name asks every backend and merges, without duplicates
+	if got := values(complete(`{"ref":{"type":"ref/prompt","name":"commit"},"argument":{"name":"branch","value":""}}`)); got != "[git-main git-dev other hg-main hg-dev]" {
+		t.Errorf("expected merged values, got %s", got)
+	}
+
+	for _, bad := range []string{
+		`{"ref":"git:commit","argument":{"name":"branch","value":""}}`,
+		`{"ref":{"type":"ref/prompt","name":"git:commit"}}`,
+		`{"ref":{"type":"ref/tool","name":"git:commit"},"argument":{"name":"branch","value":""}}`,
+	} {
+		if resp := complete(bad); resp.Error == nil || resp.Error.Code != -32602 {
+			t.Errorf("expected invalid params for %s, got %+v", bad, resp)
+		}
+	}
+}
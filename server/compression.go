@@ -0,0 +1,182 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// minCompressSize is the smallest response body worth compressing; smaller
+// bodies are sent as they are.
+const minCompressSize = 1024
+
+// encoder is a pooled compressor for one Content-Encoding.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoders = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	"zstd": {New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}},
+}
+
+// compressResponses compresses response bodies with zstd or gzip for
+// clients that accept it. SSE streams and small bodies are left alone.
+func compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the coding for a response from an Accept-Encoding
+// header: the one the client weights highest, zstd on a tie, or "" if it
+// accepts neither.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, coding := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := encoders[name]; !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ || (q == bestQ && q > 0 && name == "zstd") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the first minCompressSize bytes of a body to
+// decide whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+	enc      encoder
+	decided  bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if !cw.compressible() {
+			cw.start(false)
+		} else {
+			cw.buf = append(cw.buf, p...)
+			if len(cw.buf) < minCompressSize {
+				return len(p), nil
+			}
+			return len(p), cw.start(true)
+		}
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// compressible reports whether the response may be compressed, going by the
+// headers the handler has set.
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	switch {
+	case cw.status == http.StatusNoContent || cw.status == http.StatusNotModified:
+		return false
+	case header.Get("Content-Encoding") != "":
+		return false
+	case strings.Contains(header.Get("Content-Type"), "text/event-stream"):
+		return false
+	}
+	return true
+}
+
+// start sends the headers, compressed or not, and any held-back body.
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+	if compress {
+		cw.Header().Set("Content-Encoding", cw.encoding)
+		cw.Header().Del("Content-Length")
+		cw.enc = encoders[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	if cw.enc != nil {
+		_, err := cw.enc.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush sends what has been written so far, so streamed responses keep
+// streaming.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.start(cw.compressible() && len(cw.buf) > 0)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close sends a body too small to compress, or finishes the compressed
+// stream.
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		cw.start(false)
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+		encoders[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
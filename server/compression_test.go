@@ -0,0 +1,97 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// TestCompressResponses tests that large bodies are compressed with zstd or
+// gzip for clients that accept it, and small bodies and SSE streams are not
+func TestCompressResponses(t *testing.T) {
+	large := strings.Repeat(`{"uri":"file:///notes.md","text":"lorem ipsum"}`, 100)
+	handler := compressResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, large[:len(large)/2])
+			io.WriteString(w, large[len(large)/2:])
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"ok":true}`)
+		case "/sse":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+large+"\n\n")
+		case "/empty":
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/large", "gzip, deflate")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzipped body, got headers %v", rec.Header())
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("failed to read gzip body: %v", err)
+	}
+	if body, _ := io.ReadAll(gz); string(body) != large {
+		t.Errorf("expected the original body after decompression, got %d bytes", len(body))
+	}
+
+	rec = get("/large", "gzip, zstd")
+	if rec.Header().Get("Content-Encoding") != "zstd" {
+		t.Fatalf("expected a zstd body, got headers %v", rec.Header())
+	}
+	zr, err := zstd.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("failed to read zstd body: %v", err)
+	}
+	defer zr.Close()
+	if body, _ := io.ReadAll(zr); string(body) != large {
+		t.Errorf("expected the original body after decompression, got %d bytes", len(body))
+	}
+
+	for acceptEncoding, want := range map[string]string{
+		"zstd":                 "zstd",
+		"gzip;q=1, zstd;q=1":   "zstd",
+		"zstd;q=0.5, gzip":     "gzip",
+		"zstd;q=0, gzip;q=0.1": "gzip",
+		"br, zstd;q=0.8":       "zstd",
+	} {
+		if got := get("/large", acceptEncoding).Header().Get("Content-Encoding"); got != want {
+			t.Errorf("Accept-Encoding %q: expected %s, got %q", acceptEncoding, want, got)
+		}
+	}
+
+	for _, tt := range []struct{ path, acceptEncoding string }{
+		{"/large", ""},
+		{"/large", "gzip;q=0"},
+		{"/large", "zstd;q=0, br"},
+		{"/small", "gzip"},
+		{"/sse", "gzip"},
+		{"/sse", "zstd"},
+	} {
+		if rec := get(tt.path, tt.acceptEncoding); rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() == 0 {
+			t.Errorf("%s with Accept-Encoding %q: expected an uncompressed body, got headers %v", tt.path, tt.acceptEncoding, rec.Header())
+		}
+	}
+	if rec := get("/empty", "gzip"); rec.Code != http.StatusAccepted || rec.Body.Len() != 0 {
+		t.Errorf("expected an empty 202, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the conformance transcripts")
+
+// TestConformanceTranscripts drives the stdio server with each client
+// transcript in testdata/conformance (*.jsonl, one message per line) and
+// compares what it writes back with the transcript's .golden file. The
+// servers of a transcript are one mock backend named "mock" answering from
+// fixture.yaml. Run with -update to accept a deliberate protocol change.
+//
+// Like a client awaiting results, the harness waits for the response to each
+// request before sending the next line, except for requests the transcript
+// later cancels with notifications/cancelled. Responses are put in the order
+// of their requests and notifications follow them, so concurrent handling
+// doesn't reorder output.
+func TestConformanceTranscripts(t *testing.T) {
+	transcripts, err := filepath.Glob(filepath.Join("testdata", "conformance", "*.jsonl"))
+	if err != nil || len(transcripts) == 0 {
+		t.Fatalf("no conformance transcripts found: %v", err)
+	}
+	for _, path := range transcripts {
+		name := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		t.Run(name, func(t *testing.T) {
+			got := runTranscript(t, path)
+			golden := strings.TrimSuffix(path, ".jsonl") + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatalf("failed to write golden file: %v", err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s (run with -update if the change is intended)\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// runTranscript feeds a transcript to a fresh stdio server and returns its
+// normalized output, one message per line.
+func runTranscript(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read transcript: %v", err)
+	}
+	var lines []string
+	order := make(map[string]int) // request ID -> position in the transcript
+	cancelled := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		lines = append(lines, line)
+		var msg struct {
+			ID     interface{} `json:"id"`
+			Method string      `json:"method"`
+			Params struct {
+				RequestID interface{} `json:"requestId"`
+			} `json:"params"`
+		}
+		if strings.HasPrefix(line, "[") {
+			var batch []json.RawMessage
+			json.Unmarshal([]byte(line), &batch)
+			for _, item := range batch {
+				if json.Unmarshal(item, &msg) == nil && msg.ID != nil {
+					order[idKey(msg.ID)] = len(order)
+				}
+			}
+			continue
+		}
+		if json.Unmarshal([]byte(line), &msg) != nil {
+			continue
+		}
+		if msg.ID != nil {
+			order[idKey(msg.ID)] = len(order)
+		}
+		if msg.Method == "notifications/cancelled" {
+			cancelled[idKey(msg.Params.RequestID)] = true
+		}
+	}
+
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "mock", Transport: "mock", Fixture: filepath.Join(filepath.Dir(path), "fixture.yaml")},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	clientR, clientW := io.Pipe()
+	serverR, serverW := io.Pipe()
+	srv.reader = proxy.NewMessageReader(clientR, srv.maxMessageSize)
+	srv.out = serverW
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run(ctx)
+		serverW.Close()
+	}()
+	out := make(chan []byte, 64)
+	go func() {
+		defer close(out)
+		reader := proxy.NewMessageReader(serverR, 0)
+		for {
+			msg, err := reader.ReadMessage()
+			if err != nil {
+				return
+			}
+			out <- append([]byte(nil), msg...)
+		}
+	}()
+
+	var received [][]byte
+	for _, line := range lines {
+		if _, err := io.WriteString(clientW, line+"\n"); err != nil {
+			t.Fatalf("failed to send %s: %v", line, err)
+		}
+		var msg struct {
+			ID     interface{} `json:"id"`
+			Method string      `json:"method"`
+		}
+		batch := strings.HasPrefix(line, "[")
+		if !batch && (json.Unmarshal([]byte(line), &msg) != nil || msg.ID == nil || cancelled[idKey(msg.ID)]) {
+			continue
+		}
+		for answered := false; !answered; {
+			select {
+			case m, ok := <-out:
+				if !ok {
+					t.Fatalf("server stopped before answering %s", line)
+				}
+				received = append(received, m)
+				answered = batch && m[0] == '[' || !batch && responseID(m) == idKey(msg.ID)
+			case <-time.After(10 * time.Second):
+				t.Fatalf("no response to %s", line)
+			}
+		}
+	}
+	clientW.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("expected clean EOF, got %v", err)
+	}
+	for m := range out {
+		received = append(received, m)
+	}
+	return normalizeTranscript(t, received, order)
+}
+
+// responseID returns the idKey of a response, or of the first response in a
+// batch, or "" for anything else.
+func responseID(msg []byte) string {
+	if msg[0] == '[' {
+		var batch []json.RawMessage
+		if json.Unmarshal(msg, &batch) != nil || len(batch) == 0 {
+			return ""
+		}
+		return responseID(batch[0])
+	}
+	var resp struct {
+		ID     interface{} `json:"id"`
+		Method string      `json:"method"`
+	}
+	if json.Unmarshal(msg, &resp) != nil || resp.ID == nil || resp.Method != "" {
+		return ""
+	}
+	return idKey(resp.ID)
+}
+
+// normalizeTranscript re-encodes messages with sorted keys, orders them and
+// replaces the proxy version, which changes with every release.
+// requestIDPattern matches the request IDs added to error data, which are
+// random.
+var requestIDPattern = regexp.MustCompile(`"request_id":"req-[0-9a-f]{16}"`)
+
+func normalizeTranscript(t *testing.T, messages [][]byte, order map[string]int) string {
+	t.Helper()
+	type line struct {
+		rank int
+		text string
+	}
+	var lines []line
+	for _, m := range messages {
+		var v interface{}
+		if err := json.Unmarshal(m, &v); err != nil {
+			t.Fatalf("server wrote invalid JSON %q: %v", m, err)
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		text := strings.ReplaceAll(strings.TrimSpace(buf.String()), `"version":"`+proxy.Version+`"`, `"version":"<version>"`)
+		text = requestIDPattern.ReplaceAllString(text, `"request_id":"<request-id>"`)
+		rank, ok := order[responseID(m)]
+		if !ok {
+			rank = len(order)
+		}
+		lines = append(lines, line{rank, text})
+	}
+	// Responses in request order, then everything else in arrival order
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].rank < lines[j].rank })
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
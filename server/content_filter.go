@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxContentBytes caps the decoded size of a single binary content item.
+const defaultMaxContentBytes = 5 << 20
+
+// ContentPolicy controls how binary tool result content is passed to the client.
+type ContentPolicy struct {
+	MaxBytes    int  // decoded bytes per image/audio/blob item; 0 means no limit
+	StripBinary bool // replace binary content with a text placeholder (strict policy)
+}
+
+// contentPolicyFromEnv reads ARMOUR_MAX_CONTENT_BYTES; strict strips binary content.
+func contentPolicyFromEnv(strict bool) ContentPolicy {
+	policy := ContentPolicy{MaxBytes: defaultMaxContentBytes, StripBinary: strict}
+	if v := os.Getenv("ARMOUR_MAX_CONTENT_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			policy.MaxBytes = n
+		}
+	}
+	return policy
+}
+
+// FilterContent applies the policy to the content items of a normalized tool
+// result in place. Binary items are size-limited and their MIME type is
+// checked against the actual bytes; items that fail are replaced with a text
+// notice. It returns the content type of each item as received, and the
+// notices for items that were replaced.
+func FilterContent(result map[string]interface{}, policy ContentPolicy) (types []string, notices []string) {
+	content, _ := result["content"].([]interface{})
+	for i, raw := range content {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		itemType, _ := item["type"].(string)
+		types = append(types, itemType)
+
+		var notice string
+		switch itemType {
+		case "image", "audio":
+			notice = filterBinary(item, itemType, "data", policy)
+		case "resource":
+			if resource, ok := item["resource"].(map[string]interface{}); ok && resource["blob"] != nil {
+				notice = filterBinary(resource, "resource", "blob", policy)
+			}
+		case "resource_link":
+			notice = checkResourceLink(item)
+		}
+
+		if notice != "" {
+			notices = append(notices, notice)
+			content[i] = map[string]interface{}{"type": "text", "text": "[armour: " + notice + "]"}
+		}
+	}
+	return types, notices
+}
+
+// filterBinary checks the base64 field of a binary item and fills in a missing
+// mimeType. It returns a notice if the item must be dropped.
+func filterBinary(item map[string]interface{}, kind, field string, policy ContentPolicy) string {
+	encoded, _ := item[field].(string)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		if data, err = base64.RawStdEncoding.DecodeString(encoded); err != nil {
+			return fmt.Sprintf("%s content dropped: invalid base64", kind)
+		}
+	}
+
+	declared, _ := item["mimeType"].(string)
+	if policy.StripBinary {
+		return fmt.Sprintf("%s content (%s, %d bytes) removed by strict policy", kind, orUnknown(declared), len(data))
+	}
+	if policy.MaxBytes > 0 && len(data) > policy.MaxBytes {
+		return fmt.Sprintf("%s content (%s, %d bytes) exceeds the %d byte limit", kind, orUnknown(declared), len(data), policy.MaxBytes)
+	}
+
+	sniffed := sniffMIMEType(data)
+	if declared == "" {
+		item["mimeType"] = sniffed
+		declared = sniffed
+	}
+	if !mimeConsistent(kind, declared, sniffed) {
+		return fmt.Sprintf("%s content dropped: declared %s but data looks like %s", kind, declared, sniffed)
+	}
+	return ""
+}
+
+// sniffMIMEType identifies data, mapping formats DetectContentType labels as
+// application/* to their audio type.
+func sniffMIMEType(data []byte) string {
+	sniffed := strings.SplitN(http.DetectContentType(data), ";", 2)[0]
+	if sniffed == "application/ogg" {
+		return "audio/ogg"
+	}
+	return sniffed
+}
+
+// mimeConsistent reports whether sniffed data agrees with the declared type.
+// Unrecognized data (application/octet-stream) is given the benefit of the doubt.
+func mimeConsistent(kind, declared, sniffed string) bool {
+	if sniffed == "application/octet-stream" {
+		if kind == "resource" {
+			return !strings.HasPrefix(declared, "text/")
+		}
+		return strings.HasPrefix(declared, kind+"/")
+	}
+	switch kind {
+	case "image", "audio":
+		if declared == "image/svg+xml" && (sniffed == "text/xml" || sniffed == "text/plain") {
+			return true
+		}
+		return strings.HasPrefix(sniffed, kind+"/") && strings.HasPrefix(declared, kind+"/")
+	default:
+		// Embedded blobs may be anything, but must not disguise their family
+		return strings.SplitN(declared, "/", 2)[0] == strings.SplitN(sniffed, "/", 2)[0] ||
+			strings.HasPrefix(declared, "application/")
+	}
+}
+
+// checkResourceLink rejects links the client shouldn't be asked to follow.
+func checkResourceLink(item map[string]interface{}) string {
+	uri, _ := item["uri"].(string)
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return fmt.Sprintf("resource_link dropped: invalid uri %q", uri)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "javascript", "data", "vbscript":
+		return fmt.Sprintf("resource_link dropped: %s: URIs are not allowed", u.Scheme)
+	}
+	return ""
+}
+
+func orUnknown(mimeType string) string {
+	if mimeType == "" {
+		return "unknown type"
+	}
+	return mimeType
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+func contentResult(items ...map[string]interface{}) map[string]interface{} {
+	content := make([]interface{}, len(items))
+	for i, item := range items {
+		content[i] = item
+	}
+	return map[string]interface{}{"content": content}
+}
+
+func TestFilterContent(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString(pngHeader)
+	html := base64.StdEncoding.EncodeToString([]byte("<html><script>alert(1)</script></html>"))
+
+	result := contentResult(
+		map[string]interface{}{"type": "text", "text": "hello"},
+		map[string]interface{}{"type": "image", "data": png},
+		map[string]interface{}{"type": "image", "data": html, "mimeType": "image/png"},
+		map[string]interface{}{"type": "audio", "data": "!!!", "mimeType": "audio/wav"},
+		map[string]interface{}{"type": "resource_link", "uri": "file:///tmp/report.pdf"},
+		map[string]interface{}{"type": "resource_link", "uri": "javascript:alert(1)"},
+	)
+
+	types, notices := FilterContent(result, ContentPolicy{MaxBytes: 1024})
+
+	if strings.Join(types, ",") != "text,image,image,audio,resource_link,resource_link" {
+		t.Errorf("unexpected types: %v", types)
+	}
+	if len(notices) != 3 {
+		t.Fatalf("expected 3 items dropped, got %v", notices)
+	}
+
+	content := result["content"].([]interface{})
+	if got := content[1].(map[string]interface{})["mimeType"]; got != "image/png" {
+		t.Errorf("expected sniffed mimeType image/png, got %v", got)
+	}
+	for _, i := range []int{2, 3, 5} {
+		item := content[i].(map[string]interface{})
+		if item["type"] != "text" || !strings.HasPrefix(item["text"].(string), "[armour: ") {
+			t.Errorf("expected item %d to be replaced with a notice, got %v", i, item)
+		}
+	}
+	if content[4].(map[string]interface{})["type"] != "resource_link" {
+		t.Error("expected file resource_link to pass")
+	}
+}
+
+func TestFilterContentLimitsAndStrictMode(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString(pngHeader)
+
+	result := contentResult(map[string]interface{}{"type": "image", "data": png, "mimeType": "image/png"})
+	if _, notices := FilterContent(result, ContentPolicy{MaxBytes: 8}); len(notices) != 1 || !strings.Contains(notices[0], "limit") {
+		t.Errorf("expected size limit notice, got %v", notices)
+	}
+
+	result = contentResult(
+		map[string]interface{}{"type": "image", "data": png, "mimeType": "image/png"},
+		map[string]interface{}{"type": "resource", "resource": map[string]interface{}{"uri": "file:///a.bin", "blob": png}},
+		map[string]interface{}{"type": "resource", "resource": map[string]interface{}{"uri": "file:///a.txt", "text": "ok"}},
+	)
+	_, notices := FilterContent(result, ContentPolicy{StripBinary: true})
+	if len(notices) != 2 {
+		t.Errorf("expected binary items stripped under strict policy, got %v", notices)
+	}
+}
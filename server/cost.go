@@ -0,0 +1,327 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settingCostPolicy persists the applied costs section in the rules store.
+const settingCostPolicy = "cost_policy"
+
+// BudgetAction is what happens to calls once a budget is exhausted.
+type BudgetAction string
+
+const (
+	BudgetAsk   BudgetAction = "ask" // confirm each further call with the user via elicitation
+	BudgetBlock BudgetAction = "block"
+)
+
+// CostPolicy weights tool calls and caps cumulative spend. It is the
+// `costs:` section of a policy file:
+//
+//	costs:
+//	  servers:
+//	    openai: 1
+//	  tools:
+//	    "search:web_*": 0.5
+//	  budgets:
+//	    - tools: "openai:*"
+//	      per_session: 50
+//	      per_day: 200
+//	      action: block
+//
+// A tool's weight is its entry in tools (exact name, else the longest
+// matching wildcard), else its server's entry, else zero.
+type CostPolicy struct {
+	Tools   map[string]float64 `yaml:"tools,omitempty" json:"tools,omitempty"`
+	Servers map[string]float64 `yaml:"servers,omitempty" json:"servers,omitempty"`
+	Budgets []Budget           `yaml:"budgets,omitempty" json:"budgets,omitempty"`
+}
+
+// Budget caps the spend of the tools matching a pattern. A zero limit is unlimited.
+type Budget struct {
+	Tools      string       `yaml:"tools,omitempty" json:"tools,omitempty"` // namespaced tool pattern; empty matches all
+	PerSession float64      `yaml:"per_session,omitempty" json:"per_session,omitempty"`
+	PerDay     float64      `yaml:"per_day,omitempty" json:"per_day,omitempty"`
+	Action     BudgetAction `yaml:"action,omitempty" json:"action,omitempty"` // defaults to ask
+}
+
+// IsZero reports whether the policy sets nothing.
+func (cp CostPolicy) IsZero() bool {
+	return len(cp.Tools) == 0 && len(cp.Servers) == 0 && len(cp.Budgets) == 0
+}
+
+// Validate checks weights, limits and actions.
+func (cp CostPolicy) Validate() error {
+	for name, w := range cp.Tools {
+		if w < 0 {
+			return fmt.Errorf("costs.tools.%s: weight must not be negative", name)
+		}
+	}
+	for name, w := range cp.Servers {
+		if w < 0 {
+			return fmt.Errorf("costs.servers.%s: weight must not be negative", name)
+		}
+	}
+	for i, b := range cp.Budgets {
+		if b.PerSession < 0 || b.PerDay < 0 {
+			return fmt.Errorf("costs.budgets[%d]: limits must not be negative", i)
+		}
+		if b.PerSession == 0 && b.PerDay == 0 {
+			return fmt.Errorf("costs.budgets[%d]: per_session or per_day is required", i)
+		}
+		switch b.Action {
+		case "", BudgetAsk, BudgetBlock:
+		default:
+			return fmt.Errorf("costs.budgets[%d]: invalid action %q", i, b.Action)
+		}
+	}
+	return nil
+}
+
+// Weight returns the cost of one call to a namespaced tool on a backend.
+func (cp CostPolicy) Weight(toolName, backendID string) float64 {
+	if w, ok := cp.Tools[toolName]; ok {
+		return w
+	}
+	best, weight := -1, 0.0
+	for pattern, w := range cp.Tools {
+		if len(pattern) > best && strings.Contains(pattern, "*") && matchWildcard(toolName, pattern) {
+			best, weight = len(pattern), w
+		}
+	}
+	if best >= 0 {
+		return weight
+	}
+	return cp.Servers[backendID]
+}
+
+// Matches reports whether the budget covers a namespaced tool.
+func (b Budget) Matches(toolName string) bool {
+	return b.Tools == "" || matchWildcard(toolName, b.Tools)
+}
+
+// Label identifies the budget in messages and audit entries.
+func (b Budget) Label() string {
+	if b.Tools == "" {
+		return "*"
+	}
+	return b.Tools
+}
+
+// EffectiveAction returns the budget's action, defaulting to ask.
+func (b Budget) EffectiveAction() BudgetAction {
+	if b.Action == "" {
+		return BudgetAsk
+	}
+	return b.Action
+}
+
+// String renders the policy compactly for diffs, e.g.
+// "openai=1 search:web_*=0.5 budget(openai:*,session=50,day=200,block)".
+func (cp CostPolicy) String() string {
+	if cp.IsZero() {
+		return ""
+	}
+	var parts []string
+	for _, weights := range []map[string]float64{cp.Servers, cp.Tools} {
+		names := make([]string, 0, len(weights))
+		for name := range weights {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			parts = append(parts, fmt.Sprintf("%s=%g", name, weights[name]))
+		}
+	}
+	for _, b := range cp.Budgets {
+		parts = append(parts, fmt.Sprintf("budget(%s,session=%g,day=%g,%s)", b.Label(), b.PerSession, b.PerDay, b.EffectiveAction()))
+	}
+	return strings.Join(parts, " ")
+}
+
+func encodeCostPolicy(cp CostPolicy) (string, error) {
+	if cp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(cp)
+	return string(data), err
+}
+
+func decodeCostPolicy(raw string) (CostPolicy, error) {
+	var cp CostPolicy
+	if raw == "" {
+		return cp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cp); err != nil {
+		return cp, fmt.Errorf("invalid stored cost policy: %w", err)
+	}
+	return cp, nil
+}
+
+// BudgetExceeded describes a budget a call would take past its limit.
+type BudgetExceeded struct {
+	Budget Budget
+	Period string // "session" or "day"
+	Spent  float64
+	Limit  float64
+}
+
+func (e *BudgetExceeded) Error() string {
+	return fmt.Sprintf("%s budget for %s exhausted (spent %g of %g)", e.Period, e.Budget.Label(), e.Spent, e.Limit)
+}
+
+// CostTracker accumulates spend per tool for the session and for the current
+// day. Daily totals are persisted to the database, if one is given, so they
+// carry across restarts when the proxy uses a file-backed database.
+type CostTracker struct {
+	db      *sql.DB
+	session map[string]float64
+	day     string
+	daily   map[string]float64
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewCostTracker creates a tracker, loading today's spend from db if non-nil.
+func NewCostTracker(db *sql.DB) (*CostTracker, error) {
+	ct := &CostTracker{
+		db:      db,
+		session: make(map[string]float64),
+		now:     time.Now,
+	}
+	if db != nil {
+		if _, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS cost_usage (
+				day TEXT NOT NULL,
+				tool TEXT NOT NULL,
+				spend REAL NOT NULL DEFAULT 0,
+				PRIMARY KEY (day, tool)
+			)
+		`); err != nil {
+			return nil, fmt.Errorf("failed to create cost_usage table: %w", err)
+		}
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if err := ct.rollover(); err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+// rollover resets the daily totals when the date changes. Callers hold mu.
+func (ct *CostTracker) rollover() error {
+	today := ct.now().Format("2006-01-02")
+	if today == ct.day {
+		return nil
+	}
+	ct.day = today
+	ct.daily = make(map[string]float64)
+	if ct.db == nil {
+		return nil
+	}
+
+	rows, err := ct.db.Query("SELECT tool, spend FROM cost_usage WHERE day = ?", today)
+	if err != nil {
+		return fmt.Errorf("failed to load spend: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tool string
+		var spend float64
+		if err := rows.Scan(&tool, &spend); err != nil {
+			return fmt.Errorf("failed to load spend: %w", err)
+		}
+		ct.daily[tool] = spend
+	}
+	return rows.Err()
+}
+
+// Check returns the first budget a call costing cost would take past its
+// limit, or nil if every matching budget has room.
+func (ct *CostTracker) Check(cp CostPolicy, toolName string, cost float64) *BudgetExceeded {
+	if cost <= 0 {
+		return nil
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if err := ct.rollover(); err != nil {
+		return nil
+	}
+
+	for _, b := range cp.Budgets {
+		if !b.Matches(toolName) {
+			continue
+		}
+		if b.PerSession > 0 {
+			if spent := sumMatching(ct.session, b); spent+cost > b.PerSession {
+				return &BudgetExceeded{Budget: b, Period: "session", Spent: spent, Limit: b.PerSession}
+			}
+		}
+		if b.PerDay > 0 {
+			if spent := sumMatching(ct.daily, b); spent+cost > b.PerDay {
+				return &BudgetExceeded{Budget: b, Period: "day", Spent: spent, Limit: b.PerDay}
+			}
+		}
+	}
+	return nil
+}
+
+// Record adds the cost of a call to the session and daily totals.
+func (ct *CostTracker) Record(toolName string, cost float64) error {
+	if cost <= 0 {
+		return nil
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if err := ct.rollover(); err != nil {
+		return err
+	}
+
+	ct.session[toolName] += cost
+	ct.daily[toolName] += cost
+	if ct.db == nil {
+		return nil
+	}
+	_, err := ct.db.Exec(`
+		INSERT INTO cost_usage (day, tool, spend) VALUES (?, ?, ?)
+		ON CONFLICT(day, tool) DO UPDATE SET spend = spend + excluded.spend
+	`, ct.day, toolName, cost)
+	if err != nil {
+		return fmt.Errorf("failed to record spend: %w", err)
+	}
+	return nil
+}
+
+// Spend returns per-tool spend for the session and for today.
+func (ct *CostTracker) Spend() (session, today map[string]float64) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.rollover()
+
+	session = make(map[string]float64, len(ct.session))
+	for k, v := range ct.session {
+		session[k] = v
+	}
+	today = make(map[string]float64, len(ct.daily))
+	for k, v := range ct.daily {
+		today[k] = v
+	}
+	return session, today
+}
+
+func sumMatching(spend map[string]float64, b Budget) float64 {
+	total := 0.0
+	for tool, v := range spend {
+		if b.Matches(tool) {
+			total += v
+		}
+	}
+	return total
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCostPolicyWeight tests exact, wildcard and per-server weights
+func TestCostPolicyWeight(t *testing.T) {
+	cp := CostPolicy{
+		Tools:   map[string]float64{"search:web_search": 3, "search:web_*": 0.5, "search:*": 0.1},
+		Servers: map[string]float64{"openai": 2},
+	}
+	cases := []struct {
+		tool, backend string
+		want          float64
+	}{
+		{"search:web_search", "search", 3},
+		{"search:web_fetch", "search", 0.5},
+		{"search:images", "search", 0.1},
+		{"openai:complete", "openai", 2},
+		{"github:list_issues", "github", 0},
+	}
+	for _, c := range cases {
+		if got := cp.Weight(c.tool, c.backend); got != c.want {
+			t.Errorf("Weight(%s) = %g, want %g", c.tool, got, c.want)
+		}
+	}
+
+	if err := (CostPolicy{Budgets: []Budget{{PerDay: 10, Action: "warn"}}}).Validate(); err == nil {
+		t.Error("Expected invalid budget action to fail validation")
+	}
+	if err := (CostPolicy{Budgets: []Budget{{Tools: "openai:*"}}}).Validate(); err == nil {
+		t.Error("Expected budget without limits to fail validation")
+	}
+	if err := (CostPolicy{Servers: map[string]float64{"openai": -1}}).Validate(); err == nil {
+		t.Error("Expected negative weight to fail validation")
+	}
+}
+
+// TestCostTrackerBudgets tests session and daily limits and daily rollover
+func TestCostTrackerBudgets(t *testing.T) {
+	ct, err := NewCostTracker(nil)
+	if err != nil {
+		t.Fatalf("Failed to create cost tracker: %v", err)
+	}
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	ct.now = func() time.Time { return day }
+
+	cp := CostPolicy{Budgets: []Budget{
+		{Tools: "openai:*", PerSession: 5, Action: BudgetBlock},
+		{PerDay: 4},
+	}}
+
+	if exceeded := ct.Check(cp, "openai:complete", 2); exceeded != nil {
+		t.Fatalf("Expected first call within budget, got %v", exceeded)
+	}
+	ct.Record("openai:complete", 2)
+	ct.Record("search:web", 1)
+
+	exceeded := ct.Check(cp, "search:web", 1)
+	if exceeded != nil {
+		t.Errorf("Expected call reaching the daily limit to be allowed, got %v", exceeded)
+	}
+	exceeded = ct.Check(cp, "search:web", 2)
+	if exceeded == nil || exceeded.Period != "day" || exceeded.Budget.EffectiveAction() != BudgetAsk {
+		t.Fatalf("Expected daily budget to be exceeded with ask, got %+v", exceeded)
+	}
+
+	// A new day resets the daily budget but not the session one
+	day = day.Add(24 * time.Hour)
+	if exceeded := ct.Check(cp, "search:web", 2); exceeded != nil {
+		t.Errorf("Expected daily budget to reset, got %v", exceeded)
+	}
+	ct.Record("openai:complete", 2)
+	exceeded = ct.Check(cp, "openai:complete", 2)
+	if exceeded == nil || exceeded.Period != "session" || exceeded.Spent != 4 {
+		t.Errorf("Expected session budget to be exceeded after spending 4, got %+v", exceeded)
+	}
+
+	if exceeded := ct.Check(cp, "github:list_issues", 0); exceeded != nil {
+		t.Errorf("Expected free calls to never exceed a budget, got %v", exceeded)
+	}
+}
+
+// TestCostTrackerPersistsDailySpend tests that daily spend survives a restart
+func TestCostTrackerPersistsDailySpend(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "proxy.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	first, err := NewCostTracker(db)
+	if err != nil {
+		t.Fatalf("Failed to create cost tracker: %v", err)
+	}
+	if err := first.Record("openai:complete", 1.5); err != nil {
+		t.Fatalf("Failed to record spend: %v", err)
+	}
+	first.Record("openai:complete", 1)
+
+	second, err := NewCostTracker(db)
+	if err != nil {
+		t.Fatalf("Failed to create cost tracker: %v", err)
+	}
+	session, today := second.Spend()
+	if len(session) != 0 {
+		t.Errorf("Expected a fresh session, got %v", session)
+	}
+	if today["openai:complete"] != 2.5 {
+		t.Errorf("Expected today's spend of 2.5 to be restored, got %v", today)
+	}
+}
+
+// TestPolicyFileCostsRoundTrip tests applying, loading and exporting the costs section
+func TestPolicyFileCostsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenRulesStore(filepath.Join(dir, "rules.db"))
+	if err != nil {
+		t.Fatalf("Failed to open rules store: %v", err)
+	}
+	defer store.Close()
+
+	policyPath := filepath.Join(dir, DefaultPolicyFile)
+	content := `
+costs:
+  servers:
+    openai: 1
+  tools:
+    "search:web_*": 0.5
+  budgets:
+    - tools: "openai:*"
+      per_day: 200
+      action: block
+`
+	if err := os.WriteFile(policyPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+	pf, err := LoadPolicyFile(policyPath)
+	if err != nil {
+		t.Fatalf("Failed to load policy file: %v", err)
+	}
+
+	diff, err := ApplyPolicy(store, pf)
+	if err != nil {
+		t.Fatalf("Failed to apply policy: %v", err)
+	}
+	if diff.Empty() {
+		t.Error("Expected costs change to show up in the diff")
+	}
+
+	sp, err := LoadStoredPolicy(store)
+	if err != nil {
+		t.Fatalf("Failed to load stored policy: %v", err)
+	}
+	if sp.Costs.Weight("search:web_fetch", "search") != 0.5 || len(sp.Costs.Budgets) != 1 || sp.Costs.Budgets[0].Action != BudgetBlock {
+		t.Errorf("Expected stored cost policy, got %+v", sp.Costs)
+	}
+
+	exported, err := ExportPolicy(store)
+	if err != nil {
+		t.Fatalf("Failed to export policy: %v", err)
+	}
+	if exported.Costs.String() != pf.Costs.String() {
+		t.Errorf("Expected exported costs %q, got %q", pf.Costs.String(), exported.Costs.String())
+	}
+
+	diff, err = DiffPolicy(store, pf)
+	if err != nil || !diff.Empty() {
+		t.Errorf("Expected no drift after apply, got %+v (err=%v)", diff, err)
+	}
+}
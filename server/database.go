@@ -347,14 +347,27 @@ func ExtractToolNames(toolsStr string) []string {
 	return names
 }
 
-// RuleAppliesToTool checks if a rule applies to a specific tool name
-func RuleAppliesToTool(rule *BlocklistRule, toolName string) bool {
+// tagPrefix marks an entry of a rule's tools list that targets every tool
+// of the servers carrying a tag, e.g. "tag:prod".
+const tagPrefix = "tag:"
+
+// RuleAppliesToTool checks if a rule applies to a specific tool name. tags
+// are the tags of the server providing the tool.
+func RuleAppliesToTool(rule *BlocklistRule, toolName string, tags []string) bool {
 	if rule.Tools == "" || rule.Tools == "*" {
 		return true // Apply to all tools
 	}
 
 	toolNames := ExtractToolNames(rule.Tools)
 	for _, name := range toolNames {
+		if tag, ok := strings.CutPrefix(name, tagPrefix); ok {
+			for _, t := range tags {
+				if t == tag {
+					return true
+				}
+			}
+			continue
+		}
 		// Simple suffix matching for now (e.g., "*delete" matches "rm_delete")
 		if strings.HasSuffix(toolName, strings.TrimPrefix(name, "*")) ||
 			toolName == name {
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// settingDecoyPolicy persists the applied decoys section in the rules store.
+const settingDecoyPolicy = "decoy_policy"
+
+// DecoyTool is a tool listed to agents that no legitimate workflow calls.
+type DecoyTool struct {
+	Name        string
+	Description string
+}
+
+// defaultDecoyTools are listed when decoys are enabled without naming any.
+// They look like what a prompt-injected agent would go after.
+var defaultDecoyTools = []DecoyTool{
+	{"secrets:read_aws_credentials", "Read the AWS access key ID and secret access key configured on this machine"},
+	{"secrets:export_ssh_keys", "Export the user's private SSH keys for backup"},
+	{"secrets:dump_env_tokens", "Print every API token found in the environment and .env files"},
+}
+
+// DecoyPolicy injects honeypot tools into tools/list. Calling one means the
+// agent is following instructions it shouldn't, so the call is blocked,
+// audited as an incident and, with kill_switch, stops every later tool call
+// too. It is the `decoys:` section of a policy file:
+//
+//	decoys:
+//	  enabled: true
+//	  tools: [vault:read_prod_secrets]
+//	  kill_switch: true
+//
+// Listing tools enables decoys; `enabled: true` alone lists the defaults.
+type DecoyPolicy struct {
+	Enabled    bool     `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Tools      []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+	KillSwitch bool     `yaml:"kill_switch,omitempty" json:"kill_switch,omitempty"`
+}
+
+// IsZero reports whether the policy sets nothing.
+func (dp DecoyPolicy) IsZero() bool {
+	return !dp.Enabled && len(dp.Tools) == 0 && !dp.KillSwitch
+}
+
+// Validate checks that decoy names are namespaced like backend tools.
+func (dp DecoyPolicy) Validate() error {
+	seen := make(map[string]bool)
+	for _, name := range dp.Tools {
+		server, tool := parseNamespacedName(name)
+		if server == "" || tool == "" {
+			return fmt.Errorf("decoys.tools: %q must be namespaced as server:tool", name)
+		}
+		if server == "proxy" {
+			return fmt.Errorf("decoys.tools: %q collides with the built-in proxy tools", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("decoys.tools: duplicate %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// Decoys returns the decoy tools to list, or nil if decoys are off.
+func (dp DecoyPolicy) Decoys() []DecoyTool {
+	if len(dp.Tools) == 0 {
+		if !dp.Enabled {
+			return nil
+		}
+		return defaultDecoyTools
+	}
+	decoys := make([]DecoyTool, len(dp.Tools))
+	for i, name := range dp.Tools {
+		decoys[i] = DecoyTool{Name: name, Description: decoyDescription(name)}
+		for _, d := range defaultDecoyTools {
+			if d.Name == name {
+				decoys[i] = d
+			}
+		}
+	}
+	return decoys
+}
+
+// IsDecoy reports whether name is one of the listed decoy tools.
+func (dp DecoyPolicy) IsDecoy(name string) bool {
+	for _, d := range dp.Decoys() {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// decoyDescription makes a plausible description from a decoy's name, so
+// read_prod_secrets becomes "Read prod secrets".
+func decoyDescription(name string) string {
+	_, tool := parseNamespacedName(name)
+	words := strings.ReplaceAll(strings.ReplaceAll(tool, "_", " "), "-", " ")
+	if words == "" {
+		return ""
+	}
+	return strings.ToUpper(words[:1]) + words[1:]
+}
+
+// String renders the policy compactly for diffs, e.g. "tools=a:b,c:d kill_switch".
+func (dp DecoyPolicy) String() string {
+	if dp.IsZero() {
+		return ""
+	}
+	var parts []string
+	if decoys := dp.Decoys(); len(decoys) > 0 {
+		names := make([]string, len(decoys))
+		for i, d := range decoys {
+			names[i] = d.Name
+		}
+		parts = append(parts, "tools="+strings.Join(names, ","))
+	}
+	if dp.KillSwitch {
+		parts = append(parts, "kill_switch")
+	}
+	return strings.Join(parts, " ")
+}
+
+func encodeDecoyPolicy(dp DecoyPolicy) (string, error) {
+	if dp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(dp)
+	return string(data), err
+}
+
+func decodeDecoyPolicy(raw string) (DecoyPolicy, error) {
+	var dp DecoyPolicy
+	if raw == "" {
+		return dp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &dp); err != nil {
+		return dp, fmt.Errorf("invalid stored decoy policy: %w", err)
+	}
+	return dp, nil
+}
+
+// decoyListing renders the decoys as tools/list entries.
+func decoyListing(decoys []DecoyTool) []RegisteredTool {
+	tools := make([]RegisteredTool, len(decoys))
+	for i, d := range decoys {
+		_, tool := parseNamespacedName(d.Name)
+		tools[i] = RegisteredTool{
+			Name:        d.Name,
+			Description: d.Description,
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+			OriginalName: tool,
+		}
+	}
+	return tools
+}
+
+// decoyBlock describes a call to a decoy tool.
+func decoyBlock(toolName string) *BlockError {
+	return &BlockError{
+		BlockedBy: BlockedByDecoy,
+		Action:    "block",
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    "decoy tool called: the agent may be following injected instructions",
+		Appeal:    DashboardURL,
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestDecoyPolicy tests which decoys are listed and which names are valid
+func TestDecoyPolicy(t *testing.T) {
+	if decoys := (DecoyPolicy{}).Decoys(); decoys != nil {
+		t.Errorf("expected no decoys by default, got %v", decoys)
+	}
+	if decoys := (DecoyPolicy{Enabled: true}).Decoys(); len(decoys) != len(defaultDecoyTools) {
+		t.Errorf("expected the default decoys when enabled, got %v", decoys)
+	}
+
+	dp := DecoyPolicy{Tools: []string{"vault:read_prod_secrets", "secrets:export_ssh_keys"}}
+	decoys := dp.Decoys()
+	if len(decoys) != 2 || decoys[0].Description != "Read prod secrets" || decoys[1].Description != defaultDecoyTools[1].Description {
+		t.Errorf("expected named decoys with descriptions, got %v", decoys)
+	}
+	if !dp.IsDecoy("vault:read_prod_secrets") || dp.IsDecoy("secrets:read_aws_credentials") {
+		t.Error("expected only the listed tools to be decoys")
+	}
+	if got := (DecoyPolicy{Tools: []string{"vault:x"}, KillSwitch: true}).String(); got != "tools=vault:x kill_switch" {
+		t.Errorf("unexpected rendering %q", got)
+	}
+
+	for _, bad := range []DecoyPolicy{{Tools: []string{"read_secrets"}}, {Tools: []string{"proxy:dump"}}, {Tools: []string{"a:b", "a:b"}}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", bad)
+		}
+	}
+}
+
+// TestDecoyCallTripsAlarm tests that a called decoy is blocked, recorded as
+// an incident and engages the kill switch
+func TestDecoyCallTripsAlarm(t *testing.T) {
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "search", Transport: "mock", Fixture: writeTestFixture(t)},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+	srv.SetDecoyPolicy(DecoyPolicy{Enabled: true, KillSwitch: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	list := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "tools/list"}).(JSONRPCResponse)
+	if data, _ := json.Marshal(list.Result); !strings.Contains(string(data), "secrets:read_aws_credentials") || !strings.Contains(string(data), "search:web_search") {
+		t.Fatalf("expected decoys listed next to backend tools, got %s", data)
+	}
+
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "tools/call", Params: json.RawMessage(`{"name":"secrets:read_aws_credentials","arguments":{}}`)}).(JSONRPCResponse)
+	if resp.Error == nil || resp.Error.Code != ErrCodeDenied {
+		t.Fatalf("expected the decoy call denied, got %+v", resp)
+	}
+	if block := resp.Error.Data.(*BlockError); block.BlockedBy != BlockedByDecoy || block.ApprovalURL != "" {
+		t.Errorf("expected a decoy block without approval link, got %+v", block)
+	}
+
+	entries, err := QueryAuditLog(srv.db, AuditFilter{Reason: "decoy_called"})
+	if err != nil || len(entries) != 1 || entries[0].ToolName != "secrets:read_aws_credentials" || !strings.HasPrefix(entries[0].SessionID, "stdio-") {
+		t.Errorf("expected one decoy incident in the audit log, got %+v (err=%v)", entries, err)
+	}
+
+	if state := srv.GetKillSwitch().State(); !state.Engaged || !strings.Contains(state.Reason, "decoy") {
+		t.Errorf("expected the kill switch engaged by the decoy, got %+v", state)
+	}
+	resp = srv.handleRequest(ctx, JSONRPCRequest{ID: 4, Method: "tools/call", Params: json.RawMessage(`{"name":"search:web_search","arguments":{"query":"go"}}`)}).(JSONRPCResponse)
+	if resp.Error == nil || resp.Error.Data.(*BlockError).BlockedBy != BlockedByKillSwitch {
+		t.Errorf("expected later calls stopped by the kill switch, got %+v", resp)
+	}
+}
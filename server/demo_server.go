@@ -0,0 +1,43 @@
+package server
+
+import (
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// DemoServerName is the name the built-in demo server is registered under.
+const DemoServerName = "demo"
+
+// DemoFixture stands in for a fixture file in a mock server entry to serve
+// the built-in demo fixture instead.
+const DemoFixture = "builtin:demo"
+
+// demoFixture is a small server for trying the proxy without configuring one:
+// every call goes through rules, stats and the dashboard like any other.
+func demoFixture() *MockFixture {
+	stringArg := func(name, description string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{name: map[string]interface{}{"type": "string", "description": description}},
+			"required":   []string{name},
+		}
+	}
+	return &MockFixture{Tools: []MockTool{
+		{Name: "greet", Description: "Greet someone by name", InputSchema: stringArg("name", "Who to greet"), Text: "Hello, {{name}}!"},
+		{Name: "echo", Description: "Return the message unchanged", InputSchema: stringArg("message", "Text to echo"), Text: "{{message}}"},
+		{Name: "slow", Description: "Answer after two seconds, to watch in-flight calls and timeouts", Text: "done", Delay: 2 * time.Second},
+		{Name: "fail", Description: "Always fail, to see how errors are reported", Error: "the demo fail tool always fails"},
+	}}
+}
+
+// AddDemoServer registers the built-in demo server, unless the registry
+// already has a server of that name.
+func AddDemoServer(registry *proxy.ServerRegistry) {
+	for _, srv := range registry.Servers {
+		if srv.Name == DemoServerName {
+			return
+		}
+	}
+	registry.Servers = append(registry.Servers, proxy.ServerEntry{Name: DemoServerName, Transport: "mock", Fixture: DemoFixture})
+}
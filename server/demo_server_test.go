@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestDemoServer(t *testing.T) {
+	registry := &proxy.ServerRegistry{}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db"), DemoServer: true}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+	if len(registry.Servers) != 1 || registry.Servers[0].Name != DemoServerName {
+		t.Fatalf("expected the demo server to be registered, got %+v", registry.Servers)
+	}
+	AddDemoServer(registry)
+	if len(registry.Servers) != 1 {
+		t.Errorf("expected the demo server to be registered once, got %+v", registry.Servers)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "tools/list"}).(JSONRPCResponse)
+	data, _ := json.Marshal(resp.Result)
+	for _, tool := range []string{"demo:greet", "demo:echo", "demo:slow", "demo:fail"} {
+		if !strings.Contains(string(data), `"`+tool+`"`) {
+			t.Errorf("expected %s in tools/list, got %s", tool, data)
+		}
+	}
+
+	call := func(ctx context.Context, tool, args string) JSONRPCResponse {
+		t.Helper()
+		return srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "tools/call", Params: json.RawMessage(`{"name":"` + tool + `","arguments":` + args + `}`)}).(JSONRPCResponse)
+	}
+	resp = call(ctx, "demo:greet", `{"name":"Ada"}`)
+	if data, _ := json.Marshal(resp.Result); !strings.Contains(string(data), "Hello, Ada!") {
+		t.Errorf("expected a greeting, got %s %+v", data, resp.Error)
+	}
+	resp = call(ctx, "demo:fail", `{}`)
+	if data, _ := json.Marshal(resp.Result); !strings.Contains(string(data), `"isError":true`) {
+		t.Errorf("expected an error result, got %s %+v", data, resp.Error)
+	}
+
+	// A slow call is abandoned when its request is
+	short, stop := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer stop()
+	start := time.Now()
+	resp = call(short, "demo:slow", `{}`)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the slow call to be abandoned, took %v", elapsed)
+	}
+	if data, _ := json.Marshal(resp.Result); strings.Contains(string(data), "done") {
+		t.Errorf("expected the slow call not to finish, got %s", data)
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// settingApproveDiscovered persists servers.approve_discovered in the rules store.
+const settingApproveDiscovered = "approve_discovered"
+
+// Status of a discovered server in the discovery gate.
+const (
+	DiscoveryPending  = "pending"
+	DiscoveryApproved = "approved"
+	DiscoveryRejected = "rejected"
+)
+
+// DiscoveredServer is a server found in ~/.claude.json or a plugin, as the
+// discovery gate knows it.
+type DiscoveredServer struct {
+	Name        string     `json:"name"`
+	Tier        TrustTier  `json:"tier"`   // config or plugin
+	Source      string     `json:"source"` // what would run: the command line or URL
+	Fingerprint string     `json:"fingerprint"`
+	Status      string     `json:"status"`
+	FirstSeen   time.Time  `json:"first_seen"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"`
+}
+
+// DiscoveryGate holds servers found by auto-discovery back until they are
+// approved on the dashboard, instead of proxying them as soon as they
+// appear. Approval is for what the server runs: if its command, arguments,
+// environment or URL change, it is pending again. Decisions are kept in the
+// proxy database, so each server is approved once.
+type DiscoveryGate struct {
+	db        *sql.DB
+	mu        sync.Mutex
+	onApprove func(DiscoveredServer)
+}
+
+// NewDiscoveryGate creates a discovery gate backed by db.
+func NewDiscoveryGate(db *sql.DB) (*DiscoveryGate, error) {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS discovered_servers (
+			name TEXT PRIMARY KEY,
+			tier TEXT NOT NULL,
+			source TEXT,
+			fingerprint TEXT NOT NULL,
+			status TEXT NOT NULL,
+			first_seen TIMESTAMP NOT NULL,
+			decided_at TIMESTAMP
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create discovered_servers table: %w", err)
+	}
+	return &DiscoveryGate{db: db}, nil
+}
+
+// OnApprove sets what happens when a server is approved, e.g. starting it.
+func (g *DiscoveryGate) OnApprove(fn func(DiscoveredServer)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onApprove = fn
+}
+
+// Admit reports whether a discovered server may be proxied. A server seen
+// for the first time, or whose fingerprint changed since it was approved,
+// is recorded as pending.
+func (g *DiscoveryGate) Admit(entry proxy.ServerEntry, tier TrustTier) (bool, error) {
+	fingerprint := serverFingerprint(entry)
+
+	var status, known string
+	err := g.db.QueryRow("SELECT status, fingerprint FROM discovered_servers WHERE name = ?", entry.Name).Scan(&status, &known)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = g.db.Exec(`
+			INSERT INTO discovered_servers (name, tier, source, fingerprint, status, first_seen) VALUES (?, ?, ?, ?, ?, ?)
+		`, entry.Name, string(tier), serverSource(entry), fingerprint, DiscoveryPending, time.Now())
+		if err != nil {
+			return false, fmt.Errorf("failed to record discovered server: %w", err)
+		}
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to look up discovered server: %w", err)
+	case known != fingerprint:
+		_, err = g.db.Exec(`
+			UPDATE discovered_servers SET tier = ?, source = ?, fingerprint = ?, status = ?, decided_at = NULL WHERE name = ?
+		`, string(tier), serverSource(entry), fingerprint, DiscoveryPending, entry.Name)
+		if err != nil {
+			return false, fmt.Errorf("failed to record discovered server: %w", err)
+		}
+		return false, nil
+	}
+	return status == DiscoveryApproved, nil
+}
+
+// List returns the discovered servers, pending ones first.
+func (g *DiscoveryGate) List() ([]DiscoveredServer, error) {
+	rows, err := g.db.Query(`
+		SELECT name, tier, COALESCE(source, ''), fingerprint, status, first_seen, decided_at FROM discovered_servers ORDER BY first_seen, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list discovered servers: %w", err)
+	}
+	defer rows.Close()
+
+	servers := []DiscoveredServer{}
+	for rows.Next() {
+		var srv DiscoveredServer
+		var tier string
+		var decided sql.NullTime
+		if err := rows.Scan(&srv.Name, &tier, &srv.Source, &srv.Fingerprint, &srv.Status, &srv.FirstSeen, &decided); err != nil {
+			return nil, err
+		}
+		srv.Tier = TrustTier(tier)
+		if decided.Valid {
+			srv.DecidedAt = &decided.Time
+		}
+		servers = append(servers, srv)
+	}
+	sort.SliceStable(servers, func(i, j int) bool {
+		return servers[i].Status == DiscoveryPending && servers[j].Status != DiscoveryPending
+	})
+	return servers, rows.Err()
+}
+
+// Decide approves or rejects a discovered server. Approving it runs the
+// OnApprove hook, so a running proxy starts it without a restart.
+func (g *DiscoveryGate) Decide(name string, approve bool) (*DiscoveredServer, error) {
+	status := DiscoveryRejected
+	if approve {
+		status = DiscoveryApproved
+	}
+	now := time.Now()
+	res, err := g.db.Exec("UPDATE discovered_servers SET status = ?, decided_at = ? WHERE name = ?", status, now, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decide on discovered server: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("discovered server %q not found", name)
+	}
+
+	servers, err := g.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, srv := range servers {
+		if srv.Name != name {
+			continue
+		}
+		g.mu.Lock()
+		onApprove := g.onApprove
+		g.mu.Unlock()
+		if approve && onApprove != nil {
+			onApprove(srv)
+		}
+		return &srv, nil
+	}
+	return nil, fmt.Errorf("discovered server %q not found", name)
+}
+
+// serverFingerprint identifies what a server entry runs or connects to.
+func serverFingerprint(entry proxy.ServerEntry) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", entry.Transport, entry.Command, entry.URL)
+	for _, arg := range entry.Args {
+		fmt.Fprintf(h, "arg=%s\x00", arg)
+	}
+	for _, key := range sortedKeys(entry.Env) {
+		fmt.Fprintf(h, "env=%s=%s\x00", key, entry.Env[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// serverSource describes what a server entry runs, for the reviewer.
+func serverSource(entry proxy.ServerEntry) string {
+	if entry.URL != "" {
+		return entry.URL
+	}
+	return strings.TrimSpace(entry.Command + " " + strings.Join(entry.Args, " "))
+}
+
+// SetDiscoveryGate holds newly discovered servers back until they are
+// approved. With nil, discovered servers are proxied at once.
+func (bm *BackendManager) SetDiscoveryGate(gate *DiscoveryGate) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.gate = gate
+}
+
+// admitDiscovered reports whether a discovered server may be added to the
+// registry, keeping it aside for approval if not. Callers hold mu.
+func (bm *BackendManager) admitDiscovered(entry proxy.ServerEntry, tier TrustTier) bool {
+	if bm.gate == nil {
+		return true
+	}
+	admitted, err := bm.gate.Admit(entry, tier)
+	if err != nil {
+		bm.logger.Warn("holding back discovered server %s: %v", entry.Name, err)
+		return false
+	}
+	_, held := bm.pendingServers[entry.Name]
+	if admitted {
+		delete(bm.pendingServers, entry.Name)
+		return true
+	}
+	bm.pendingServers[entry.Name] = pendingServer{entry: entry, tier: tier}
+	if !held {
+		bm.logger.Warn("discovered server %s (%s) is held back until it is approved on the dashboard", entry.Name, serverSource(entry))
+	}
+	return false
+}
+
+// pendingServer is a discovered server held back by the discovery gate.
+type pendingServer struct {
+	entry proxy.ServerEntry
+	tier  TrustTier
+}
+
+// AdmitPendingServer adds a server the discovery gate held back to the
+// registry and starts it, once it is approved. It reports false if the
+// server wasn't held back by this proxy.
+func (bm *BackendManager) AdmitPendingServer(name string) (bool, error) {
+	bm.mu.Lock()
+	pending, ok := bm.pendingServers[name]
+	if !ok || bm.registry == nil {
+		bm.mu.Unlock()
+		return false, nil
+	}
+	delete(bm.pendingServers, name)
+	bm.registry.Servers = append(bm.registry.Servers, pending.entry)
+	if pending.tier == TrustPlugin {
+		bm.pluginServers[name] = true
+	} else {
+		bm.configServers[name] = true
+	}
+	runCtx := bm.runCtx
+	bm.mu.Unlock()
+
+	if runCtx == nil {
+		runCtx = context.Background()
+	}
+	initCtx, cancel := context.WithTimeout(runCtx, backendInitTimeout)
+	defer cancel()
+	entry := pending.entry
+	if err := bm.initializeBackend(initCtx, &entry); err != nil {
+		return true, fmt.Errorf("failed to initialize approved server %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func encodeApproveDiscovered(on bool) string {
+	if on {
+		return "true"
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestDiscoveryGate(t *testing.T) {
+	gate, err := NewDiscoveryGate(openChainTestDB(t))
+	if err != nil {
+		t.Fatalf("failed to create gate: %v", err)
+	}
+	var approved []string
+	gate.OnApprove(func(srv DiscoveredServer) { approved = append(approved, srv.Name) })
+
+	entry := proxy.ServerEntry{Name: "notes", Transport: "stdio", Command: "npx", Args: []string{"notes-mcp"}}
+	for i := 0; i < 2; i++ {
+		if ok, err := gate.Admit(entry, TrustConfig); ok || err != nil {
+			t.Fatalf("expected a new server pending, got %v (err=%v)", ok, err)
+		}
+	}
+	servers, err := gate.List()
+	if err != nil || len(servers) != 1 || servers[0].Status != DiscoveryPending || servers[0].Source != "npx notes-mcp" || servers[0].Tier != TrustConfig {
+		t.Fatalf("expected one pending server, got %+v (err=%v)", servers, err)
+	}
+
+	if srv, err := gate.Decide("notes", true); err != nil || srv.Status != DiscoveryApproved || srv.DecidedAt == nil {
+		t.Fatalf("expected the server approved, got %+v (err=%v)", srv, err)
+	}
+	if len(approved) != 1 || approved[0] != "notes" {
+		t.Errorf("expected the approve hook to run, got %v", approved)
+	}
+	if ok, _ := gate.Admit(entry, TrustConfig); !ok {
+		t.Error("expected the approved server admitted")
+	}
+
+	// A changed command needs approval again
+	entry.Args = []string{"notes-mcp", "--evil"}
+	if ok, _ := gate.Admit(entry, TrustConfig); ok {
+		t.Error("expected a changed server pending again")
+	}
+	if srv, err := gate.Decide("notes", false); err != nil || srv.Status != DiscoveryRejected {
+		t.Fatalf("expected the server rejected, got %+v (err=%v)", srv, err)
+	}
+	if ok, _ := gate.Admit(entry, TrustConfig); ok {
+		t.Error("expected a rejected server held back")
+	}
+	if len(approved) != 1 {
+		t.Errorf("expected rejecting not to run the approve hook, got %v", approved)
+	}
+	if _, err := gate.Decide("unknown", true); err == nil {
+		t.Error("expected deciding on an unknown server to fail")
+	}
+}
+
+func TestPluginServersWaitForApproval(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	registry := &proxy.ServerRegistry{}
+	backends := NewBackendManager(registry, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	gate, err := NewDiscoveryGate(openChainTestDB(t))
+	if err != nil {
+		t.Fatalf("failed to create gate: %v", err)
+	}
+	backends.SetDiscoveryGate(gate)
+	gate.OnApprove(func(srv DiscoveredServer) { backends.AdmitPendingServer(srv.Name) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pluginDir := writePluginManifest(t, home, "demo", `{"name":"demo","mcpServers":{"demo-server":{"type":"http","url":"http://127.0.0.2:1/mcp"}}}`)
+	if added, _ := backends.SyncPluginServers(ctx); len(added) != 0 || registry.GetServer("demo-server") != nil {
+		t.Fatalf("expected the plugin server held back, got %v", added)
+	}
+	servers, _ := gate.List()
+	if len(servers) != 1 || servers[0].Name != "demo-server" || servers[0].Status != DiscoveryPending || servers[0].Tier != TrustPlugin {
+		t.Fatalf("expected the plugin server pending, got %+v", servers)
+	}
+
+	// Approving starts it without another sync
+	if _, err := gate.Decide("demo-server", true); err != nil {
+		t.Fatalf("failed to approve: %v", err)
+	}
+	if registry.GetServer("demo-server") == nil {
+		t.Fatal("expected the approved server in the registry")
+	}
+	if _, removed := backends.SyncPluginServers(ctx); len(removed) != 0 || registry.GetServer("demo-server") == nil {
+		t.Errorf("expected the approved server kept, removed %v", removed)
+	}
+
+	if err := os.RemoveAll(pluginDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, removed := backends.SyncPluginServers(ctx); len(removed) != 1 {
+		t.Errorf("expected the approved server removed with its plugin, got %v", removed)
+	}
+}
+
+func TestApproveDiscoveredPolicy(t *testing.T) {
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, DefaultPolicyFile)
+	os.WriteFile(policyPath, []byte("servers:\n  approve_discovered: true\n"), 0644)
+	pf, err := LoadPolicyFile(policyPath)
+	if err != nil {
+		t.Fatalf("failed to load policy file: %v", err)
+	}
+
+	rs, err := NewRulesServer(RulesServerConfig{DBPath: filepath.Join(dir, "rules.db")})
+	if err != nil {
+		t.Fatalf("failed to create rules server: %v", err)
+	}
+	defer rs.db.Close()
+
+	diff, err := ApplyPolicy(rs.store, pf)
+	if err != nil {
+		t.Fatalf("failed to apply policy: %v", err)
+	}
+	if !strings.Contains(FormatPolicyDiff(diff), "~ servers.approve_discovered: false -> true") {
+		t.Errorf("expected the setting in the diff, got %q", FormatPolicyDiff(diff))
+	}
+	if sp, err := LoadStoredPolicy(rs.store); err != nil || !sp.ApproveDiscovered {
+		t.Errorf("expected the setting stored, got %+v (err=%v)", sp, err)
+	}
+	if exported, err := ExportPolicy(rs.store); err != nil || !exported.Servers.ApproveDiscovered {
+		t.Errorf("expected the setting exported, got %+v (err=%v)", exported, err)
+	}
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// settingDLPPolicy persists the applied dlp section in the rules store.
+const settingDLPPolicy = "dlp_policy"
+
+// DLPAction is what happens to a tool call whose arguments hold a sensitive
+// data class on their way to a remote backend.
+type DLPAction string
+
+const (
+	DLPBlock  DLPAction = "block"  // refuse the call
+	DLPRedact DLPAction = "redact" // replace the data with [REDACTED:<class>] and forward
+	DLPWarn   DLPAction = "warn"   // forward unchanged and log a warning
+	DLPOff    DLPAction = "off"    // don't scan for the class
+)
+
+// Sensitive data classes found by ScanDLP.
+const (
+	DLPCreditCard = "credit_card"
+	DLPSSN        = "ssn"
+	DLPPrivateKey = "private_key"
+	DLPEnvFile    = "env_file"
+)
+
+// DLPPolicy sets the action for each sensitive data class in tool arguments
+// sent to remote (http and sse) backends. It is the `dlp:` section of a
+// policy file:
+//
+//	dlp:
+//	  credit_card: redact
+//	  ssn: block
+type DLPPolicy struct {
+	CreditCard DLPAction `yaml:"credit_card,omitempty" json:"credit_card,omitempty"`
+	SSN        DLPAction `yaml:"ssn,omitempty" json:"ssn,omitempty"`
+	PrivateKey DLPAction `yaml:"private_key,omitempty" json:"private_key,omitempty"`
+	EnvFile    DLPAction `yaml:"env_file,omitempty" json:"env_file,omitempty"`
+}
+
+// DefaultDLPPolicy blocks private keys and warns about the other classes.
+func DefaultDLPPolicy() DLPPolicy {
+	return DLPPolicy{
+		CreditCard: DLPWarn,
+		SSN:        DLPWarn,
+		PrivateKey: DLPBlock,
+		EnvFile:    DLPWarn,
+	}
+}
+
+// redactAllDLP redacts every class, for arguments shown outside the call:
+// the semantic history and approval previews.
+var redactAllDLP = DLPPolicy{CreditCard: DLPRedact, SSN: DLPRedact, PrivateKey: DLPRedact, EnvFile: DLPRedact}
+
+func (dp DLPPolicy) actions() map[string]DLPAction {
+	return map[string]DLPAction{
+		DLPCreditCard: dp.CreditCard,
+		DLPSSN:        dp.SSN,
+		DLPPrivateKey: dp.PrivateKey,
+		DLPEnvFile:    dp.EnvFile,
+	}
+}
+
+// IsZero reports whether the policy sets nothing.
+func (dp DLPPolicy) IsZero() bool {
+	return dp == DLPPolicy{}
+}
+
+// Validate checks the action of each class.
+func (dp DLPPolicy) Validate() error {
+	for class, action := range dp.actions() {
+		switch action {
+		case "", DLPBlock, DLPRedact, DLPWarn, DLPOff:
+		default:
+			return fmt.Errorf("dlp.%s: invalid action %q", class, action)
+		}
+	}
+	return nil
+}
+
+// Action returns the action for a class, falling back to the defaults.
+func (dp DLPPolicy) Action(class string) DLPAction {
+	if action := dp.actions()[class]; action != "" {
+		return action
+	}
+	return DefaultDLPPolicy().actions()[class]
+}
+
+// String renders the policy compactly for diffs, e.g. "credit_card=redact env_file=warn ...".
+func (dp DLPPolicy) String() string {
+	if dp.IsZero() {
+		return ""
+	}
+	var parts []string
+	for _, class := range dlpClasses {
+		parts = append(parts, class+"="+string(dp.Action(class)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func encodeDLPPolicy(dp DLPPolicy) (string, error) {
+	if dp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(dp)
+	return string(data), err
+}
+
+func decodeDLPPolicy(raw string) (DLPPolicy, error) {
+	var dp DLPPolicy
+	if raw == "" {
+		return dp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &dp); err != nil {
+		return dp, fmt.Errorf("invalid stored dlp policy: %w", err)
+	}
+	return dp, nil
+}
+
+var (
+	dlpClasses = []string{DLPCreditCard, DLPSSN, DLPPrivateKey, DLPEnvFile}
+
+	cardPattern       = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	ssnPattern        = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+	privateKeyPattern = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----(?s:.*?)(?:-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----|\z)`)
+	envLinePattern    = regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?([A-Z][A-Z0-9_]*)=(.*)$`)
+	envSecretHint     = regexp.MustCompile(`KEY|SECRET|TOKEN|PASSWORD|PASSWD|PWD|CREDENTIAL|DSN|DATABASE_URL|AUTH`)
+)
+
+// dlpMatch is the byte range of sensitive data in a string.
+type dlpMatch struct {
+	class      string
+	start, end int
+}
+
+// findSensitive returns the sensitive data in s, in order.
+func findSensitive(s string) []dlpMatch {
+	var matches []dlpMatch
+	for _, m := range cardPattern.FindAllStringIndex(s, -1) {
+		if luhnValid(s[m[0]:m[1]]) {
+			matches = append(matches, dlpMatch{DLPCreditCard, m[0], m[1]})
+		}
+	}
+	for _, m := range ssnPattern.FindAllStringSubmatchIndex(s, -1) {
+		area, group, serial := s[m[2]:m[3]], s[m[4]:m[5]], s[m[6]:m[7]]
+		if area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000" {
+			matches = append(matches, dlpMatch{DLPSSN, m[0], m[1]})
+		}
+	}
+	for _, m := range privateKeyPattern.FindAllStringIndex(s, -1) {
+		matches = append(matches, dlpMatch{DLPPrivateKey, m[0], m[1]})
+	}
+	// A .env file: several NAME=value lines, of which the secrets' values count
+	if lines := envLinePattern.FindAllStringSubmatchIndex(s, -1); len(lines) >= 2 {
+		for _, m := range lines {
+			if envSecretHint.MatchString(s[m[2]:m[3]]) && m[5] > m[4] {
+				matches = append(matches, dlpMatch{DLPEnvFile, m[4], m[5]})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+// luhnValid reports whether a digit string (with optional spaces or dashes)
+// passes the Luhn checksum used by card numbers.
+func luhnValid(number string) bool {
+	sum, n := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
+
+// DLPFinding is sensitive data found in a tool argument. Field is a dotted
+// path like the ones in FieldError.
+type DLPFinding struct {
+	Field  string    `json:"field"`
+	Class  string    `json:"class"`
+	Action DLPAction `json:"action"`
+}
+
+func (f DLPFinding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Field, f.Class, f.Action)
+}
+
+// ScanDLP looks for sensitive data in tool arguments. It returns what it
+// found, with the action the policy takes for each, and a copy of args with
+// the data of redacted classes replaced. Classes switched off aren't reported.
+func ScanDLP(dp DLPPolicy, args map[string]interface{}) ([]DLPFinding, map[string]interface{}) {
+	var findings []DLPFinding
+	redacted, _ := scanDLPValue(dp, "", args, &findings).(map[string]interface{})
+	return findings, redacted
+}
+
+func scanDLPValue(dp DLPPolicy, field string, value interface{}, findings *[]DLPFinding) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make(map[string]interface{}, len(v))
+		for _, k := range keys {
+			out[k] = scanDLPValue(dp, joinField(field, k), v[k], findings)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = scanDLPValue(dp, fmt.Sprintf("%s[%d]", field, i), item, findings)
+		}
+		return out
+	case string:
+		var b strings.Builder
+		last := 0
+		seen := make(map[string]bool)
+		for _, m := range findSensitive(v) {
+			action := dp.Action(m.class)
+			if action == DLPOff || m.start < last {
+				continue
+			}
+			if !seen[m.class] {
+				seen[m.class] = true
+				*findings = append(*findings, DLPFinding{Field: field, Class: m.class, Action: action})
+			}
+			if action == DLPRedact {
+				b.WriteString(v[last:m.start])
+				b.WriteString("[REDACTED:" + m.class + "]")
+				last = m.end
+			}
+		}
+		if last == 0 {
+			return v
+		}
+		b.WriteString(v[last:])
+		return b.String()
+	default:
+		return value
+	}
+}
+
+// dlpBlock describes a tools/call denied for sending sensitive data to a
+// remote backend, or returns nil if no finding blocks it.
+func dlpBlock(toolName string, findings []DLPFinding) *BlockError {
+	var parts []string
+	for _, f := range findings {
+		if f.Action == DLPBlock {
+			parts = append(parts, f.String())
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return &BlockError{
+		BlockedBy: BlockedByDLP,
+		Action:    "block",
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    "sensitive data in arguments: " + strings.Join(parts, "; "),
+		Appeal:    "change the dlp section of the policy file",
+	}
+}
+
+// formatDLPFindings renders findings for logs and audit entries.
+func formatDLPFindings(findings []DLPFinding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// IsRemote reports whether a backend is reached over the network (http or
+// sse), so its arguments leave the machine.
+func (bm *BackendManager) IsRemote(backendID string) bool {
+	bm.mu.RLock()
+	conn, exists := bm.connections[backendID]
+	bm.mu.RUnlock()
+	if !exists || conn.config == nil {
+		return false
+	}
+	return conn.config.Transport == "http" || conn.config.Transport == "sse"
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// settingEgressPolicy persists the applied egress section in the rules store.
+const settingEgressPolicy = "egress_policy"
+
+// EgressPolicy limits the hosts URL arguments of tool calls may point at. It
+// is the `egress:` section of a policy file:
+//
+//	egress:
+//	  allow: [github.com, "*.githubusercontent.com"]
+//	  deny: [10.0.0.0/8, corp.internal]
+//
+// Entries are domains (matching the domain and its subdomains), *.domain
+// (subdomains only), IP addresses or CIDR ranges. Deny wins over allow, and
+// a non-empty allow list blocks every other host. Link-local addresses and
+// cloud metadata endpoints are always blocked unless allow_link_local is set.
+// Hostnames are compared as written, not resolved.
+type EgressPolicy struct {
+	Allow          []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	Deny           []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+	AllowLinkLocal bool     `yaml:"allow_link_local,omitempty" json:"allow_link_local,omitempty"`
+}
+
+// metadataHosts are cloud metadata endpoints that aren't link-local addresses.
+var metadataHosts = []string{
+	"metadata.google.internal",
+	"metadata.goog",
+	"100.100.100.200", // Alibaba Cloud
+	"fd00:ec2::254",   // AWS over IPv6
+}
+
+// urlSchemes are the schemes of arguments treated as network destinations.
+var urlSchemes = map[string]bool{"http": true, "https": true, "ws": true, "wss": true, "ftp": true}
+
+// IsZero reports whether the policy sets nothing.
+func (ep EgressPolicy) IsZero() bool {
+	return len(ep.Allow) == 0 && len(ep.Deny) == 0 && !ep.AllowLinkLocal
+}
+
+// Validate checks that every entry is a domain, IP address or CIDR range.
+func (ep EgressPolicy) Validate() error {
+	for field, entries := range map[string][]string{"allow": ep.Allow, "deny": ep.Deny} {
+		for _, entry := range entries {
+			if strings.Contains(entry, "/") {
+				if _, _, err := net.ParseCIDR(entry); err != nil {
+					return fmt.Errorf("egress.%s: invalid CIDR %q", field, entry)
+				}
+				continue
+			}
+			if entry == "" || strings.Trim(strings.TrimPrefix(entry, "*."), ".") == "" || strings.ContainsAny(entry, " :?#@") && net.ParseIP(entry) == nil {
+				return fmt.Errorf("egress.%s: invalid host %q", field, entry)
+			}
+		}
+	}
+	return nil
+}
+
+// String renders the policy on one line for diffs.
+func (ep EgressPolicy) String() string {
+	if ep.IsZero() {
+		return ""
+	}
+	var parts []string
+	if len(ep.Allow) > 0 {
+		parts = append(parts, "allow="+strings.Join(ep.Allow, ","))
+	}
+	if len(ep.Deny) > 0 {
+		parts = append(parts, "deny="+strings.Join(ep.Deny, ","))
+	}
+	if ep.AllowLinkLocal {
+		parts = append(parts, "allow_link_local")
+	}
+	return strings.Join(parts, " ")
+}
+
+func encodeEgressPolicy(ep EgressPolicy) (string, error) {
+	if ep.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(ep)
+	return string(data), err
+}
+
+func decodeEgressPolicy(raw string) (EgressPolicy, error) {
+	var ep EgressPolicy
+	if raw == "" {
+		return ep, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &ep); err != nil {
+		return ep, fmt.Errorf("invalid stored egress policy: %w", err)
+	}
+	return ep, nil
+}
+
+// EgressViolation is a URL argument the egress policy forbids.
+type EgressViolation struct {
+	Field  string `json:"field"`
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+func (v EgressViolation) String() string {
+	return fmt.Sprintf("%s: %s %s", v.Field, v.URL, v.Reason)
+}
+
+// CheckEgress returns the URL arguments of a tool call the policy forbids.
+func CheckEgress(ep EgressPolicy, args map[string]interface{}) []EgressViolation {
+	var violations []EgressViolation
+	checkEgressValue(ep, "", args, &violations)
+	return violations
+}
+
+func checkEgressValue(ep EgressPolicy, field string, value interface{}, violations *[]EgressViolation) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			checkEgressValue(ep, joinField(field, k), v[k], violations)
+		}
+	case []interface{}:
+		for i, item := range v {
+			checkEgressValue(ep, fmt.Sprintf("%s[%d]", field, i), item, violations)
+		}
+	case string:
+		u, err := url.Parse(strings.TrimSpace(v))
+		if err != nil || !urlSchemes[strings.ToLower(u.Scheme)] || u.Host == "" {
+			return
+		}
+		if reason := ep.check(u.Hostname()); reason != "" {
+			*violations = append(*violations, EgressViolation{Field: field, URL: v, Reason: reason})
+		}
+	}
+}
+
+// check returns why host is forbidden, or "" if it is allowed.
+func (ep EgressPolicy) check(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ip := parseHostIP(host)
+
+	if !ep.AllowLinkLocal {
+		if ip != nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()) {
+			return "is a link-local address"
+		}
+		for _, m := range metadataHosts {
+			if host == m || (ip != nil && ip.Equal(net.ParseIP(m))) {
+				return "is a cloud metadata endpoint"
+			}
+		}
+	}
+	for _, entry := range ep.Deny {
+		if hostMatches(entry, host, ip) {
+			return "is denied by egress rule " + entry
+		}
+	}
+	if len(ep.Allow) == 0 {
+		return ""
+	}
+	for _, entry := range ep.Allow {
+		if hostMatches(entry, host, ip) {
+			return ""
+		}
+	}
+	return "is not in the egress allowlist"
+}
+
+// hostMatches reports whether a policy entry covers host (whose IP, if it is
+// one, is ip).
+func hostMatches(entry, host string, ip net.IP) bool {
+	entry = strings.TrimSuffix(strings.ToLower(entry), ".")
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		return err == nil && ip != nil && network.Contains(ip)
+	}
+	if entryIP := net.ParseIP(entry); entryIP != nil {
+		return ip != nil && entryIP.Equal(ip)
+	}
+	if ip != nil {
+		return false
+	}
+	if suffix, ok := strings.CutPrefix(entry, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == entry || strings.HasSuffix(host, "."+entry)
+}
+
+// parseHostIP parses a URL host as an IP address, including the forms
+// inet_aton accepts, which resolve to an IPv4 address but aren't written as
+// one: integer and hex (http://2852039166/, http://0xa9fea9fe/), octal parts
+// (http://0251.0376.0251.0376/) and fewer than four parts, the last filling
+// the remaining bytes (http://169.254.43518/).
+func parseHostIP(host string) net.IP {
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return ip
+	}
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+	var n uint64
+	for i, part := range parts {
+		v, ok := parseIPv4Part(part)
+		if !ok {
+			return nil
+		}
+		// Every part but the last is one byte
+		bits := 8
+		if i == len(parts)-1 {
+			bits = 8 * (4 - i)
+		}
+		if v >= 1<<bits {
+			return nil
+		}
+		n = n<<bits | v
+	}
+	return net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// parseIPv4Part parses one part of an inet_aton address: hex with 0x, octal
+// with a leading 0, decimal otherwise.
+func parseIPv4Part(part string) (uint64, bool) {
+	base := 10
+	switch {
+	case len(part) > 2 && (part[:2] == "0x" || part[:2] == "0X"):
+		base, part = 16, part[2:]
+	case len(part) > 1 && part[0] == '0':
+		base, part = 8, part[1:]
+	}
+	// With an explicit base ParseUint takes no sign, underscores or prefix
+	v, err := strconv.ParseUint(part, base, 32)
+	return v, err == nil
+}
+
+// egressBlock describes a tools/call denied by the egress policy.
+func egressBlock(toolName string, violations []EgressViolation) *BlockError {
+	parts := make([]string, len(violations))
+	for i, v := range violations {
+		parts[i] = v.String()
+	}
+	return &BlockError{
+		BlockedBy: BlockedByEgress,
+		Action:    "block",
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    strings.Join(parts, "; "),
+		Appeal:    "change the egress section of the policy file",
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCheckEgress tests which URL arguments the egress policy blocks
+func TestCheckEgress(t *testing.T) {
+	policy := EgressPolicy{
+		Allow: []string{"github.com", "*.githubusercontent.com", "203.0.113.0/24"},
+		Deny:  []string{"gist.github.com"},
+	}
+
+	tests := []struct {
+		desc    string
+		policy  EgressPolicy
+		url     string
+		blocked bool
+	}{
+		{"public host without policy", EgressPolicy{}, "https://example.com/page", false},
+		{"metadata IP", EgressPolicy{}, "http://169.254.169.254/latest/meta-data/", true},
+		{"metadata IP as integer", EgressPolicy{}, "http://2852039166/", true},
+		{"metadata IP as hex", EgressPolicy{}, "http://0xa9fea9fe/", true},
+		{"metadata IP in octal", EgressPolicy{}, "http://0251.0376.0251.0376/", true},
+		{"metadata IP in three parts", EgressPolicy{}, "http://169.254.43518/", true},
+		{"metadata IP in two parts", EgressPolicy{}, "http://169.16689662/", true},
+		{"metadata IP in mixed bases", EgressPolicy{}, "http://0xa9.0376.169.254/", true},
+		{"link-local with a leading zero", EgressPolicy{}, "http://0251.254.1.1/", true},
+		{"metadata hostname", EgressPolicy{}, "http://metadata.google.internal/computeMetadata/v1/", true},
+		{"IPv6 link-local", EgressPolicy{}, "http://[fe80::1]/", true},
+		{"link-local allowed explicitly", EgressPolicy{AllowLinkLocal: true}, "http://169.254.169.254/", false},
+		{"allowed domain", policy, "https://github.com/org/repo", false},
+		{"allowed subdomain", policy, "https://api.github.com/repos", false},
+		{"wildcard subdomain", policy, "https://raw.githubusercontent.com/a/b", false},
+		{"wildcard excludes apex", policy, "https://githubusercontent.com/", true},
+		{"suffix is not a subdomain", policy, "https://evilgithub.com/", true},
+		{"deny wins over allow", policy, "https://gist.github.com/x", true},
+		{"allowed CIDR", policy, "http://203.0.113.7:8080/", false},
+		{"allowed CIDR in octal", policy, "http://0313.0.0161.7/", false},
+		{"part too large", policy, "http://203.0.113.256/", true},
+		{"outside allowlist", policy, "https://example.com/", true},
+		{"trailing dot", EgressPolicy{Deny: []string{"example.com"}}, "https://example.com./", true},
+		{"not a URL", policy, "github.com is where the code lives", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			violations := CheckEgress(tt.policy, map[string]interface{}{"url": tt.url})
+			if (len(violations) > 0) != tt.blocked {
+				t.Errorf("expected blocked=%v for %s, got %v", tt.blocked, tt.url, violations)
+			}
+		})
+	}
+
+	nested := map[string]interface{}{"requests": []interface{}{map[string]interface{}{"endpoint": "http://169.254.169.254/"}}}
+	if v := CheckEgress(EgressPolicy{}, nested); len(v) != 1 || v[0].Field != "requests[0].endpoint" {
+		t.Errorf("expected the nested URL reported, got %v", v)
+	}
+
+	for _, bad := range []EgressPolicy{{Deny: []string{"10.0.0.0/33"}}, {Allow: []string{"*."}}, {Deny: []string{"http://x"}}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", bad)
+		}
+	}
+}
+
+// TestPolicyFileEgressRoundTrip tests that the egress section is stored,
+// exported and applied to native tool checks
+func TestPolicyFileEgressRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, DefaultPolicyFile)
+	content := `
+egress:
+  deny: [internal.example.com, 10.0.0.0/8]
+`
+	if err := os.WriteFile(policyPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+	pf, err := LoadPolicyFile(policyPath)
+	if err != nil {
+		t.Fatalf("Failed to load policy file: %v", err)
+	}
+
+	rs, err := NewRulesServer(RulesServerConfig{DBPath: filepath.Join(dir, "rules.db")})
+	if err != nil {
+		t.Fatalf("Failed to create rules server: %v", err)
+	}
+	defer rs.db.Close()
+
+	diff, err := ApplyPolicy(rs.store, pf)
+	if err != nil {
+		t.Fatalf("Failed to apply policy: %v", err)
+	}
+	if !strings.Contains(FormatPolicyDiff(diff), "~ egress:") {
+		t.Errorf("Expected egress change in the diff, got %q", FormatPolicyDiff(diff))
+	}
+
+	sp, err := LoadStoredPolicy(rs.store)
+	if err != nil {
+		t.Fatalf("Failed to load stored policy: %v", err)
+	}
+	if sp.Egress.String() != pf.Egress.String() {
+		t.Errorf("Expected stored egress %q, got %q", pf.Egress.String(), sp.Egress.String())
+	}
+	exported, err := ExportPolicy(rs.store)
+	if err != nil {
+		t.Fatalf("Failed to export policy: %v", err)
+	}
+	if exported.Egress.String() != pf.Egress.String() {
+		t.Errorf("Expected exported egress %q, got %q", pf.Egress.String(), exported.Egress.String())
+	}
+
+	ctx := context.Background()
+	resp := rs.Evaluate(ctx, CheckRequest{Tool: "WebFetch", Scope: "native", Content: `{"url":"http://10.1.2.3/admin","prompt":"summarise"}`})
+	if resp.Allowed || !strings.Contains(resp.Reason, "10.0.0.0/8") {
+		t.Errorf("Expected native WebFetch to a denied range blocked, got %+v", resp)
+	}
+	resp = rs.Evaluate(ctx, CheckRequest{Tool: "WebFetch", Scope: "native", Content: `{"url":"https://example.com/"}`})
+	if !resp.Allowed {
+		t.Errorf("Expected other hosts allowed, got %+v", resp)
+	}
+}
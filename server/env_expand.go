@@ -77,6 +77,9 @@ func expandServerEntry(entry *proxy.ServerEntry) {
 	if entry.URL != "" {
 		entry.URL = expand(entry.URL)
 	}
+	if entry.Cwd != "" {
+		entry.Cwd = expand(entry.Cwd)
+	}
 	for i, arg := range entry.Args {
 		entry.Args[i] = expand(arg)
 	}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// repoRefreshInterval is how long a detected repository is reused before
+// HEAD is read again, so a checkout mid-session shows up in policies.
+const repoRefreshInterval = 5 * time.Second
+
+// RepoContext identifies the git repository a stdio proxy was started in,
+// which is the project the agent works on. It is passed to policy checkers,
+// authorizers and Cedar policies (as context.repo) and recorded in audit
+// entries.
+type RepoContext struct {
+	Root   string `json:"root"`             // the working tree
+	Origin string `json:"origin,omitempty"` // the origin remote's URL, without credentials
+	Name   string `json:"name,omitempty"`   // host and path of origin, e.g. github.com/acme/api
+	Branch string `json:"branch,omitempty"` // checked-out branch; empty when HEAD is detached
+}
+
+// DetectRepo finds the git repository containing dir by reading .git
+// directly, without running git. It returns nil outside a repository.
+func DetectRepo(dir string) *RepoContext {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	for {
+		if gitDir, ok := resolveGitDir(filepath.Join(dir, ".git")); ok {
+			repo := &RepoContext{Root: dir, Branch: readGitBranch(gitDir)}
+			repo.Origin = readGitOrigin(gitCommonDir(gitDir))
+			repo.Name = repoName(repo.Origin)
+			return repo
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// resolveGitDir returns the git directory .git refers to: .git itself, or
+// the directory a worktree's or submodule's .git file points at.
+func resolveGitDir(dotGit string) (string, bool) {
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", false
+	}
+	if info.IsDir() {
+		return dotGit, true
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", false
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", false
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(dotGit), target)
+	}
+	return target, true
+}
+
+// gitCommonDir returns the directory holding the config of a worktree's
+// main repository, or gitDir itself.
+func gitCommonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return common
+}
+
+func readGitBranch(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	// A detached HEAD holds a commit instead of a ref
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// readGitOrigin returns the url of [remote "origin"] in the repository's
+// config, with any credentials removed.
+func readGitOrigin(gitDir string) string {
+	f, err := os.Open(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	inOrigin := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inOrigin = line == `[remote "origin"]`
+			continue
+		}
+		if !inOrigin {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && strings.TrimSpace(key) == "url" {
+			return stripURLCredentials(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// stripURLCredentials removes a user and password or token from a remote
+// URL, so they never reach policies or the audit log.
+func stripURLCredentials(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil || u.Host == "" {
+		return remote
+	}
+	u.User = nil
+	return u.String()
+}
+
+// repoName reduces a remote URL to host/path: https://github.com/acme/api.git
+// and git@github.com:acme/api.git both become github.com/acme/api.
+func repoName(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	name := origin
+	if u, err := url.Parse(origin); err == nil && u.Host != "" {
+		name = u.Host + u.Path
+	} else if userHost, path, ok := strings.Cut(origin, ":"); ok && !strings.Contains(userHost, "/") {
+		// scp-like syntax: [user@]host:path
+		if _, host, ok := strings.Cut(userHost, "@"); ok {
+			userHost = host
+		}
+		name = userHost + "/" + strings.TrimPrefix(path, "/")
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(name, "/"), ".git")
+}
+
+// repoTracker keeps the repository of a directory up to date.
+type repoTracker struct {
+	dir     string
+	mu      sync.Mutex
+	repo    *RepoContext
+	checked time.Time
+}
+
+func newRepoTracker(dir string) *repoTracker {
+	return &repoTracker{dir: dir}
+}
+
+// workingDir returns the process's working directory, which for a stdio
+// proxy is where the client started it, or "" if it can't be read.
+func workingDir() string {
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// Current returns the repository, read again at most every
+// repoRefreshInterval, or nil outside a repository.
+func (rt *repoTracker) Current() *RepoContext {
+	if rt == nil || rt.dir == "" {
+		return nil
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if time.Since(rt.checked) >= repoRefreshInterval {
+		rt.repo = DetectRepo(rt.dir)
+		rt.checked = time.Now()
+	}
+	return rt.repo
+}
+
+// cedarRecord returns the repository as Cedar's context.repo. Unknown
+// origin and branch are left out, so policies can test them with `has`.
+func (r *RepoContext) cedarRecord() cedarRecord {
+	rec := cedarRecord{"root": r.Root}
+	if r.Origin != "" {
+		rec["origin"] = r.Origin
+		rec["name"] = r.Name
+	}
+	if r.Branch != "" {
+		rec["branch"] = r.Branch
+	}
+	return rec
+}
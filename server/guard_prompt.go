@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// settingGuardPrompts persists the applied guard_prompts section in the rules store.
+const settingGuardPrompts = "guard_prompts"
+
+// guardPromptPrefix introduces the notes appended to a tool's description.
+const guardPromptPrefix = "\n\nPolicy: "
+
+// GuardPrompt adds policy reminders to the descriptions of risky tools in
+// tools/list, to steer the model without blocking anything:
+//
+//	guard_prompts:
+//	  - modes: [strict]
+//	    classes:
+//	      write: "Changes data; confirm with the user before calling {tool}."
+//	      delete: "Destructive; requires explicit user confirmation."
+//	    rules: true
+//	  - classes:
+//	      delete: "Destructive; requires explicit user confirmation."
+//
+// classes holds a note per tool class (see ToolClasses); {tool}, {server}
+// and {class} are filled in. With rules, the blocklist rules that name the
+// tool or its server's tags are summarized too.
+type GuardPrompt struct {
+	Modes   []PolicyMode      `yaml:"modes,omitempty" json:"modes,omitempty"` // policy modes it applies in; all if empty
+	Classes map[string]string `yaml:"classes,omitempty" json:"classes,omitempty"`
+	Rules   bool              `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// GuardPromptPolicy is the `guard_prompts:` section of a policy file, one
+// entry per policy mode or group of modes. The first entry for the current
+// mode applies.
+type GuardPromptPolicy []GuardPrompt
+
+// IsZero reports whether there are no entries.
+func (gp GuardPromptPolicy) IsZero() bool {
+	return len(gp) == 0
+}
+
+// Validate checks the modes and tool classes.
+func (gp GuardPromptPolicy) Validate() error {
+	for i, entry := range gp {
+		for _, mode := range entry.Modes {
+			switch mode {
+			case StrictMode, ModerateMode, PermissiveMode:
+			default:
+				return fmt.Errorf("guard_prompts[%d]: invalid mode %q", i, mode)
+			}
+		}
+		for class, note := range entry.Classes {
+			if !slices.Contains(toolClasses, class) {
+				return fmt.Errorf("guard_prompts[%d].classes: invalid tool class %q (want one of %s)", i, class, strings.Join(toolClasses, ", "))
+			}
+			if strings.TrimSpace(note) == "" {
+				return fmt.Errorf("guard_prompts[%d].classes.%s: note is empty", i, class)
+			}
+		}
+		if len(entry.Classes) == 0 && !entry.Rules {
+			return fmt.Errorf("guard_prompts[%d]: classes or rules is required", i)
+		}
+	}
+	return nil
+}
+
+// String renders the entries for diffs, e.g.
+// `strict:delete="Destructive."+rules | delete="Destructive."`.
+func (gp GuardPromptPolicy) String() string {
+	parts := make([]string, len(gp))
+	for i, entry := range gp {
+		var b strings.Builder
+		if len(entry.Modes) > 0 {
+			modes := make([]string, len(entry.Modes))
+			for j, mode := range entry.Modes {
+				modes[j] = string(mode)
+			}
+			b.WriteString(strings.Join(modes, ",") + ":")
+		}
+		for j, class := range sortedKeys(entry.Classes) {
+			if j > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "%s=%q", class, entry.Classes[class])
+		}
+		if entry.Rules {
+			b.WriteString("+rules")
+		}
+		parts[i] = b.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+// forMode returns the entry for a policy mode, or false if there is none.
+func (gp GuardPromptPolicy) forMode(mode PolicyMode) (GuardPrompt, bool) {
+	for _, entry := range gp {
+		if len(entry.Modes) == 0 || slices.Contains(entry.Modes, mode) {
+			return entry, true
+		}
+	}
+	return GuardPrompt{}, false
+}
+
+// Describe returns a tool's description with the notes for its class and
+// rules appended, or the description unchanged if there are none.
+func (gp GuardPromptPolicy) Describe(tool RegisteredTool, class string, mode PolicyMode, rules []BlocklistRule) string {
+	entry, ok := gp.forMode(mode)
+	if !ok {
+		return tool.Description
+	}
+	var notes []string
+	if note, ok := entry.Classes[class]; ok {
+		server, _ := parseNamespacedName(tool.Name)
+		notes = append(notes, strings.NewReplacer("{tool}", tool.Name, "{server}", server, "{class}", class).Replace(strings.TrimSpace(note)))
+	}
+	if entry.Rules {
+		for _, rule := range rules {
+			if note := ruleReminder(rule); note != "" {
+				notes = append(notes, note)
+			}
+		}
+	}
+	if len(notes) == 0 {
+		return tool.Description
+	}
+	return tool.Description + guardPromptPrefix + strings.Join(notes, " ")
+}
+
+// ruleReminder summarizes what a rule does to calls, e.g.
+// `Calls matching "push --force" are blocked (no force pushes).`
+func ruleReminder(rule BlocklistRule) string {
+	var effect string
+	switch rule.Action {
+	case "allow":
+		return ""
+	case "ask":
+		effect = "need the user's approval"
+	case DelegateAction:
+		effect = "are checked by an external authorizer"
+	default:
+		effect = "are blocked"
+	}
+	note := "Calls " + effect
+	switch {
+	case rule.Pattern == "" || rule.Pattern == ".*":
+	case rule.IsSemantic:
+		note = fmt.Sprintf("Calls about %q %s", rule.Pattern, effect)
+	default:
+		note = fmt.Sprintf("Calls matching %q %s", rule.Pattern, effect)
+	}
+	if rule.Description != "" {
+		note += " (" + rule.Description + ")"
+	}
+	return note + "."
+}
+
+// guardToolDescriptions appends the guard prompts of the current policy mode
+// to the descriptions of listed tools.
+func (s *StdioServer) guardToolDescriptions(tools []RegisteredTool) {
+	s.mu.RLock()
+	guardPrompts, classes := s.guardPrompts, s.toolClasses
+	s.mu.RUnlock()
+	if guardPrompts.IsZero() {
+		return
+	}
+	mode := ModerateMode
+	if s.policyManager != nil {
+		mode = s.policyManager.GetMode()
+	}
+	for i, tool := range tools {
+		var rules []BlocklistRule
+		if s.blocklist != nil {
+			rules = s.blocklist.RulesForTool(tool.Name)
+		}
+		tools[i].Description = guardPrompts.Describe(tool, classes.Classify(tool), mode, rules)
+	}
+}
+
+func encodeGuardPrompts(gp GuardPromptPolicy) (string, error) {
+	if gp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(gp)
+	return string(data), err
+}
+
+func decodeGuardPrompts(raw string) (GuardPromptPolicy, error) {
+	var gp GuardPromptPolicy
+	if raw == "" {
+		return gp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &gp); err != nil {
+		return gp, fmt.Errorf("invalid stored guard prompts: %w", err)
+	}
+	return gp, nil
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestGuardPrompts(t *testing.T) {
+	gp := GuardPromptPolicy{
+		{Modes: []PolicyMode{PermissiveMode}, Classes: map[string]string{ToolClassExec: "Runs on the user's machine."}},
+		{Classes: map[string]string{ToolClassDelete: "Destructive; {server} requires explicit user confirmation."}, Rules: true},
+	}
+	if err := gp.Validate(); err != nil {
+		t.Fatalf("expected valid guard prompts, got %v", err)
+	}
+	drop := RegisteredTool{Name: "db:drop_table", Description: "Drops a table"}
+	rules := []BlocklistRule{
+		{Pattern: "prod_.*", Action: "block", IsRegex: true, Description: "production tables"},
+		{Pattern: ".*", Action: "ask"},
+		{Pattern: "tmp_.*", Action: "allow"},
+	}
+
+	got := gp.Describe(drop, ToolClassDelete, ModerateMode, rules)
+	want := `Drops a table
+
+Policy: Destructive; db requires explicit user confirmation. Calls matching "prod_.*" are blocked (production tables). Calls need the user's approval.`
+	if got != want {
+		t.Errorf("got description\n%s\nwant\n%s", got, want)
+	}
+	// Permissive mode has its own entry, without notes for delete tools or rules
+	if got := gp.Describe(drop, ToolClassDelete, PermissiveMode, rules); got != drop.Description {
+		t.Errorf("expected the description unchanged in permissive mode, got %q", got)
+	}
+	if got := gp.Describe(RegisteredTool{Name: "db:query", Description: "Runs a query"}, ToolClassRead, ModerateMode, nil); got != "Runs a query" {
+		t.Errorf("expected read tools without rules unchanged, got %q", got)
+	}
+
+	for _, bad := range []GuardPromptPolicy{
+		{{Classes: map[string]string{"dangerous": "x"}}},
+		{{Classes: map[string]string{ToolClassDelete: " "}}},
+		{{Modes: []PolicyMode{"paranoid"}, Rules: true}},
+		{{}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestStdioGuardPromptsInToolsList(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: query
+    description: Runs a query
+  - name: drop_table
+    description: Drops a table
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "db", Transport: "mock", Fixture: fixture},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+	if err := CreateBlocklistRule(srv.db, &BlocklistRule{Pattern: "prod_", Tools: "db:drop_table", Action: "block", IsRegex: true, Enabled: true, Permissions: DefaultPermissions("block")}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	srv.blocklist.RefreshRulesCache()
+	srv.SetGuardPrompts(GuardPromptPolicy{{Classes: map[string]string{ToolClassDelete: "Destructive; requires explicit user confirmation."}, Rules: true}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "tools/list"}).(JSONRPCResponse)
+	tools := resp.Result.(map[string]interface{})["tools"].([]RegisteredTool)
+	descriptions := make(map[string]string)
+	for _, tool := range tools {
+		descriptions[tool.Name] = tool.Description
+	}
+	if got := descriptions["db:drop_table"]; !strings.Contains(got, "Policy: Destructive; requires explicit user confirmation. Calls matching \"prod_\" are blocked.") {
+		t.Errorf("expected guard prompts on drop_table, got %q", got)
+	}
+	if got := descriptions["db:query"]; got != "Runs a query" {
+		t.Errorf("expected query's description unchanged, got %q", got)
+	}
+	// The registry keeps the backend's own descriptions
+	if tool, _ := srv.toolRegistry.GetTool("db:drop_table"); tool.Description != "Drops a table" {
+		t.Errorf("expected the registered description unchanged, got %q", tool.Description)
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// Health statuses reported by /api/health. Degraded means the proxy still
+// serves traffic but an optional dependency (e.g. the rules server) is down.
+const (
+	HealthOK        = "ok"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+	HealthDisabled  = "disabled"
+)
+
+const healthCheckTimeout = 500 * time.Millisecond
+
+// HealthCheck is the result of probing a single dependency.
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BackendSummary counts initialized backends against configured ones.
+type BackendSummary struct {
+	Ready       int             `json:"ready"`
+	Total       int             `json:"total"`
+	Backends    []BackendHealth `json:"backends,omitempty"`    // keepalive state per connected backend
+	Quarantined []string        `json:"quarantined,omitempty"` // crash-looping backends no longer restarted
+}
+
+// SupervisorStatus is the crash history of a proxy run by `mcp-proxy supervise`.
+type SupervisorStatus struct {
+	Crashes   int        `json:"crashes"`
+	LastCrash *time.Time `json:"last_crash,omitempty"`
+	LastDump  string     `json:"last_dump,omitempty"` // crash dump of the last crash
+}
+
+// SupervisorFromEnv reads the crash history the supervisor passed down, or
+// returns nil when the proxy is not supervised.
+func SupervisorFromEnv() *SupervisorStatus {
+	crashes, err := strconv.Atoi(os.Getenv(cmd.SupervisorCrashesEnv))
+	if err != nil {
+		return nil
+	}
+	status := &SupervisorStatus{Crashes: crashes, LastDump: os.Getenv(cmd.SupervisorLastDumpEnv)}
+	if t, err := time.Parse(time.RFC3339, os.Getenv(cmd.SupervisorLastCrashEnv)); err == nil {
+		status.LastCrash = &t
+	}
+	return status
+}
+
+// HealthReport is the body of /api/health.
+type HealthReport struct {
+	Status        string              `json:"status"`
+	Version       string              `json:"version"`
+	StartedAt     time.Time           `json:"started_at"`
+	UptimeSeconds int64               `json:"uptime_seconds"`
+	Database      HealthCheck         `json:"database"`
+	RulesStore    HealthCheck         `json:"rules_store"`
+	Backends      *BackendSummary     `json:"backends,omitempty"`
+	Queue         *WorkQueueStats     `json:"queue,omitempty"`
+	Clients       *ClientConfigReport `json:"clients,omitempty"`
+	Supervisor    *SupervisorStatus   `json:"supervisor,omitempty"`
+	Host          *HostContext        `json:"host,omitempty"` // as policies see it
+}
+
+// HTTPStatus maps the overall status to the response code used by
+// container healthchecks: 503 when unhealthy, 200 otherwise.
+func (h HealthReport) HTTPStatus() int {
+	if h.Status == HealthUnhealthy {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// SetClients adds the client config check; a client bypassing the proxy
+// degrades an otherwise healthy report.
+func (h *HealthReport) SetClients(clients *ClientConfigReport) {
+	h.Clients = clients
+	if clients != nil && len(clients.Bypasses) > 0 && h.Status == HealthOK {
+		h.Status = HealthDegraded
+	}
+}
+
+// BuildHealthReport probes the database, the rules server at rulesURL (if
+// any) and the backend manager (if any) and summarizes them.
+func BuildHealthReport(ctx context.Context, db *sql.DB, backends *BackendManager, rulesURL string) HealthReport {
+	report := HealthReport{
+		Status:        HealthOK,
+		Version:       proxy.Version,
+		StartedAt:     proxy.StartTime().UTC(),
+		UptimeSeconds: int64(proxy.Uptime().Seconds()),
+		Database:      checkDatabase(ctx, db),
+		RulesStore:    checkRulesStore(ctx, rulesURL),
+		Supervisor:    SupervisorFromEnv(),
+		Host:          CurrentHostContext(),
+	}
+
+	if backends != nil {
+		ready, total := backends.Summary()
+		report.Backends = &BackendSummary{Ready: ready, Total: total, Backends: backends.BackendHealth(), Quarantined: backends.Quarantined()}
+	}
+
+	switch {
+	case report.Database.Status == HealthUnhealthy:
+		report.Status = HealthUnhealthy
+	case report.RulesStore.Status == HealthUnhealthy:
+		report.Status = HealthDegraded
+	case report.Backends != nil && report.Backends.Ready < report.Backends.Total:
+		report.Status = HealthDegraded
+	}
+	return report
+}
+
+func checkDatabase(ctx context.Context, db *sql.DB) HealthCheck {
+	if db == nil {
+		return HealthCheck{Status: HealthDisabled}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		return HealthCheck{Status: HealthUnhealthy, Error: err.Error()}
+	}
+	return HealthCheck{Status: HealthOK}
+}
+
+// checkRulesStore reports whether the external rules server is reachable.
+// Without a rules server the local rule cache is used, reported as disabled.
+func checkRulesStore(ctx context.Context, rulesURL string) HealthCheck {
+	if rulesURL == "" {
+		return HealthCheck{Status: HealthDisabled}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(rulesURL, "/")+"/api/health", nil)
+	if err != nil {
+		return HealthCheck{Status: HealthUnhealthy, Error: err.Error()}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return HealthCheck{Status: HealthUnhealthy, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return HealthCheck{Status: HealthUnhealthy, Error: fmt.Sprintf("rules server returned status %d", resp.StatusCode)}
+	}
+	return HealthCheck{Status: HealthOK}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestBuildHealthReport(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:memdb_health?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	rules := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer rules.Close()
+
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "a"}, {Name: "b"}}}
+	backends := NewBackendManager(registry, proxy.NewLogger("error"), nil, nil)
+	backends.connections["a"] = &BackendConnection{config: &registry.Servers[0], initialized: true}
+
+	report := BuildHealthReport(context.Background(), db, backends, rules.URL)
+	if report.Version != proxy.Version {
+		t.Errorf("expected version %s, got %s", proxy.Version, report.Version)
+	}
+	if report.Database.Status != HealthOK || report.RulesStore.Status != HealthOK {
+		t.Errorf("expected healthy dependencies, got db=%+v rules=%+v", report.Database, report.RulesStore)
+	}
+	if report.Backends == nil || report.Backends.Ready != 1 || report.Backends.Total != 2 {
+		t.Errorf("expected 1/2 backends ready, got %+v", report.Backends)
+	}
+	if report.Status != HealthDegraded {
+		t.Errorf("expected degraded with a backend down, got %s", report.Status)
+	}
+}
+
+func TestBuildHealthReportUnhealthy(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:memdb_health_closed?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	db.Close()
+
+	report := BuildHealthReport(context.Background(), db, nil, "")
+	if report.Status != HealthUnhealthy || report.HTTPStatus() != http.StatusServiceUnavailable {
+		t.Errorf("expected unhealthy/503 with closed db, got %s/%d", report.Status, report.HTTPStatus())
+	}
+	if report.RulesStore.Status != HealthDisabled {
+		t.Errorf("expected rules store disabled without URL, got %s", report.RulesStore.Status)
+	}
+}
+
+func TestSupervisorFromEnv(t *testing.T) {
+	t.Setenv(cmd.SupervisorCrashesEnv, "")
+	if status := SupervisorFromEnv(); status != nil {
+		t.Errorf("expected no supervisor status, got %+v", status)
+	}
+
+	t.Setenv(cmd.SupervisorCrashesEnv, "2")
+	t.Setenv(cmd.SupervisorLastCrashEnv, "2026-01-02T03:04:05Z")
+	t.Setenv(cmd.SupervisorLastDumpEnv, "/tmp/crash.log")
+	status := SupervisorFromEnv()
+	if status == nil || status.Crashes != 2 || status.LastCrash == nil || status.LastCrash.Hour() != 3 || status.LastDump != "/tmp/crash.log" {
+		t.Errorf("unexpected supervisor status %+v", status)
+	}
+}
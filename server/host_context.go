@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostContextTTL is how long collected host context is reused. VPNs and
+// networks change while the proxy runs, so it is collected again after that.
+const hostContextTTL = 30 * time.Second
+
+// hostContextTimeout bounds the commands run to read the Wi-Fi network.
+const hostContextTimeout = time.Second
+
+// defaultVPNInterfaces are the name prefixes of network interfaces VPN
+// clients create: OpenVPN and most others (tun, tap), WireGuard (wg),
+// Tailscale, ZeroTier (zt), NordVPN (nordlynx), IPsec and PPP tunnels,
+// GlobalProtect (gpd) and Cisco AnyConnect (cscotun). macOS creates utun
+// devices without a VPN, so they are left out. ARMOUR_VPN_INTERFACES
+// replaces the list.
+var defaultVPNInterfaces = []string{"tun", "tap", "wg", "tailscale", "zt", "nordlynx", "ipsec", "ppp", "gpd", "cscotun"}
+
+// HostContext describes the machine the proxy runs on, for policies such as
+// "block production database tools when off VPN". It is passed to policy
+// checkers, authorizers and Cedar policies (as context.host) with every call
+// and shown in /api/health.
+type HostContext struct {
+	Hostname      string    `json:"hostname"`
+	User          string    `json:"user"`
+	OS            string    `json:"os"`
+	VPN           bool      `json:"vpn"`                      // a VPN interface is up
+	VPNInterfaces []string  `json:"vpn_interfaces,omitempty"` // the VPN interfaces that are up
+	SSID          string    `json:"ssid,omitempty"`           // Wi-Fi network, if it can be read
+	CollectedAt   time.Time `json:"collected_at"`
+}
+
+// Collectors, replaced in tests.
+var (
+	hostInterfaces = net.Interfaces
+	hostSSID       = readSSID
+)
+
+var hostContextCache struct {
+	mu         sync.Mutex
+	context    *HostContext
+	refreshing bool
+}
+
+// CurrentHostContext returns the host context. Only the first call waits
+// for it to be collected; once it is older than hostContextTTL, callers get
+// it as is while it is collected again in the background.
+func CurrentHostContext() *HostContext {
+	hostContextCache.mu.Lock()
+	defer hostContextCache.mu.Unlock()
+	c := hostContextCache.context
+	if c == nil {
+		hostContextCache.context = collectHostContext()
+		return hostContextCache.context
+	}
+	if time.Since(c.CollectedAt) >= hostContextTTL && !hostContextCache.refreshing {
+		hostContextCache.refreshing = true
+		go func() {
+			fresh := collectHostContext()
+			hostContextCache.mu.Lock()
+			hostContextCache.context = fresh
+			hostContextCache.refreshing = false
+			hostContextCache.mu.Unlock()
+		}()
+	}
+	return c
+}
+
+func collectHostContext() *HostContext {
+	hc := &HostContext{OS: runtime.GOOS, CollectedAt: time.Now()}
+	hc.Hostname, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		hc.User = u.Username
+	} else {
+		hc.User = os.Getenv("USER")
+	}
+	hc.VPNInterfaces = vpnInterfaces()
+	hc.VPN = len(hc.VPNInterfaces) > 0
+	hc.SSID = hostSSID()
+	return hc
+}
+
+// vpnInterfaces returns the names of the VPN interfaces that are up and
+// have an address.
+func vpnInterfaces() []string {
+	prefixes := defaultVPNInterfaces
+	if v := os.Getenv("ARMOUR_VPN_INTERFACES"); v != "" {
+		prefixes = strings.Split(v, ",")
+	}
+	ifaces, err := hostInterfaces()
+	if err != nil {
+		return nil
+	}
+	var up []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		name := strings.ToLower(iface.Name)
+		for _, prefix := range prefixes {
+			if prefix = strings.ToLower(strings.TrimSpace(prefix)); prefix != "" && strings.HasPrefix(name, prefix) {
+				if addrs, err := iface.Addrs(); err == nil && len(addrs) > 0 {
+					up = append(up, iface.Name)
+				}
+				break
+			}
+		}
+	}
+	return up
+}
+
+// readSSID returns the Wi-Fi network the host is connected to, or "" if
+// there is none or the platform's tools can't tell.
+func readSSID() string {
+	ctx, cancel := context.WithTimeout(context.Background(), hostContextTimeout)
+	defer cancel()
+	switch runtime.GOOS {
+	case "linux":
+		out, err := exec.CommandContext(ctx, "iwgetid", "-r").Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	case "darwin":
+		out, err := exec.CommandContext(ctx, "networksetup", "-getairportnetwork", "en0").Output()
+		if err != nil {
+			return ""
+		}
+		// "Current Wi-Fi Network: <ssid>", or a message saying there is none
+		if _, ssid, ok := strings.Cut(strings.TrimSpace(string(out)), "Network: "); ok {
+			return ssid
+		}
+	case "windows":
+		out, err := exec.CommandContext(ctx, "netsh", "wlan", "show", "interfaces").Output()
+		if err != nil {
+			return ""
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if ok && strings.TrimSpace(key) == "SSID" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return ""
+}
+
+// cedarRecord returns the host context as Cedar's context.host. ssid is
+// left out when unknown, so policies can test it with `has`.
+func (hc *HostContext) cedarRecord() cedarRecord {
+	rec := cedarRecord{
+		"hostname": hc.Hostname,
+		"user":     hc.User,
+		"os":       hc.OS,
+		"vpn":      hc.VPN,
+	}
+	if hc.SSID != "" {
+		rec["ssid"] = hc.SSID
+	}
+	return rec
+}
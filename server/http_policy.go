@@ -0,0 +1,393 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// enforcePolicy applies the blocklist and trust policy to a JSON-RPC request
+// (or batch) bound for server. It returns nil if the request may be forwarded,
+// otherwise the JSON-RPC error response(s) to send back instead. A batch is
+// rejected as a whole if any request in it is denied.
+//
+// Names are namespaced as in stdio mode (github:create_issue,
+// armour://github/<uri>) so the same rules apply to both modes.
+func (s *Server) enforcePolicy(ctx context.Context, t *tenant, server *proxy.ServerEntry, sessionID string, body []byte) interface{} {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+
+	if body[0] == '[' {
+		var batch []JSONRPCRequest
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil // let the backend report malformed requests
+		}
+		denied := false
+		responses := make([]JSONRPCResponse, 0, len(batch))
+		for _, req := range batch {
+			if block := s.checkRequest(ctx, t, server, sessionID, req); block != nil {
+				denied = true
+				responses = append(responses, deniedResponse(req.ID, block))
+			} else if req.ID != nil {
+				responses = append(responses, deniedResponse(req.ID, &BlockError{
+					BlockedBy: BlockedByBatch,
+					Reason:    "batch rejected: another request in the batch was denied",
+					RequestID: RequestIDFrom(ctx),
+				}))
+			}
+		}
+		if !denied {
+			return nil
+		}
+		return responses
+	}
+
+	var req JSONRPCRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil
+	}
+	if block := s.checkRequest(ctx, t, server, sessionID, req); block != nil {
+		return deniedResponse(req.ID, block)
+	}
+	return nil
+}
+
+// CheckRequest applies the policy to a JSON-RPC request bound for the named
+// server as the proxy does before forwarding it, audit entry included. It
+// returns nil if the request may go ahead, otherwise why it was denied.
+func (s *Server) CheckRequest(serverName, sessionID string, req JSONRPCRequest) (*BlockError, error) {
+	t := s.defaultTenant()
+	entry := t.registry.GetServer(serverName)
+	if entry == nil {
+		return nil, fmt.Errorf("unknown server %q", serverName)
+	}
+	return s.checkRequest(WithRequestID(context.Background(), NewRequestID()), t, entry, sessionID, req), nil
+}
+
+// checkRequest returns nil if req is allowed, otherwise why it was denied.
+// The audit entry and the denial carry ctx's request ID.
+func (s *Server) checkRequest(ctx context.Context, t *tenant, server *proxy.ServerEntry, sessionID string, req JSONRPCRequest) *BlockError {
+	if req.Method == "initialize" {
+		var params proxy.InitRequestParams
+		json.Unmarshal(req.Params, &params)
+		s.statsTracker.SetSessionClient(sessionID, params.ClientInfo.Name)
+	}
+	block := s.checkRequestPolicy(ctx, t, server, sessionID, req)
+	if block != nil {
+		block.RequestID = RequestIDFrom(ctx)
+	}
+	return block
+}
+
+// checkRequestPolicy is checkRequest before the request ID is added.
+func (s *Server) checkRequestPolicy(ctx context.Context, t *tenant, server *proxy.ServerEntry, sessionID string, req JSONRPCRequest) *BlockError {
+	var name string
+	var args map[string]interface{}
+	switch req.Method {
+	case "tools/call", "prompts/get":
+		var params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		json.Unmarshal(req.Params, &params)
+		name = server.Name + ":" + params.Name
+		args = params.Arguments
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		json.Unmarshal(req.Params, &params)
+		name = armourURI(server.Name, params.URI)
+	case "tools/list", "resources/list", "prompts/list":
+	default:
+		return nil
+	}
+
+	if block := s.killSwitch.Check(req.Method, name); block != nil {
+		s.statsTracker.RecordBlockedCall(name, "killswitch")
+		s.recordAudit(AuditEntry{
+			ServerID:    server.Name,
+			Method:      req.Method,
+			ToolName:    name,
+			SessionID:   sessionID,
+			Transport:   "http",
+			Tenant:      t.name,
+			RequestID:   RequestIDFrom(ctx),
+			Blocked:     true,
+			BlockReason: "kill_switch",
+			RuleAction:  "block",
+		})
+		block.Appeal = "" // no dashboard in HTTP mode
+		return block
+	}
+
+	// Every HTTP backend is reached over the network, so offline mode blocks
+	// whatever would reach it
+	if req.Method == "tools/call" || req.Method == "resources/read" || req.Method == "prompts/get" {
+		if block := s.offline.Check(name, true, args); block != nil {
+			block.Operation = strings.ReplaceAll(req.Method, "/", "_")
+			s.statsTracker.RecordBlockedCall(name, "offline")
+			s.recordAudit(AuditEntry{
+				ServerID:       server.Name,
+				Method:         req.Method,
+				ToolName:       name,
+				SessionID:      sessionID,
+				Transport:      "http",
+				Tenant:         t.name,
+				RequestID:      RequestIDFrom(ctx),
+				Blocked:        true,
+				BlockReason:    "offline",
+				MatchedPattern: block.Reason,
+				RuleAction:     "block",
+			})
+			block.Appeal = ""
+			return block
+		}
+	}
+
+	entry := AuditEntry{
+		ServerID:  server.Name,
+		Method:    req.Method,
+		ToolName:  name,
+		SessionID: sessionID,
+		Transport: "http",
+		Tenant:    t.name,
+		RequestID: RequestIDFrom(ctx),
+	}
+	if req.Method == "tools/call" {
+		entry.StatementClass = SQLStatementClass(args)
+	}
+
+	if t.blocklist != nil {
+		result, err := t.blocklist.CheckSession(sessionID, req.Method, name, args)
+		if err != nil {
+			s.logger.Error("blocklist check failed: %v", err)
+		}
+		result = t.authorizer.delegate(ctx, result, req.Method, PolicyCall{
+			Server:    server.Name,
+			Tool:      name,
+			Arguments: args,
+			SessionID: sessionID,
+			Transport: "http",
+			Host:      CurrentHostContext(),
+		})
+		if !result.Allowed {
+			statName := name
+			if statName == "" {
+				statName = req.Method
+			}
+			s.statsTracker.RecordBlockedCall(statName, fmt.Sprintf("blocklist:%s", result.DeniedOperation))
+			entry.Blocked = true
+			entry.BlockReason = "blocklist_match"
+			entry.DeniedOperation = result.DeniedOperation
+			if result.MatchedRule != nil {
+				entry.MatchedPattern = result.MatchedRule.Pattern
+				entry.RuleAction = result.MatchedRule.Action
+			}
+			s.recordCallAudit(entry, args)
+			// No appeal link: HTTP mode doesn't run the dashboard
+			return ruleBlock(result, name, "")
+		}
+	}
+
+	if req.Method != "tools/call" {
+		return nil
+	}
+
+	if violations := CheckPaths(server.AllowedRoots, backendWorkingDir(server), args); len(violations) > 0 {
+		block := sandboxBlock(name, violations)
+		s.statsTracker.RecordBlockedCall(name, "sandbox")
+		entry.Blocked = true
+		entry.BlockReason = "path_outside_roots"
+		entry.MatchedPattern = block.Reason
+		entry.RuleAction = "block"
+		s.recordCallAudit(entry, args)
+		return block
+	}
+
+	tp, egress, dlp := t.trust, t.egress, t.dlp
+
+	if violations := CheckEgress(egress, args); len(violations) > 0 {
+		block := egressBlock(name, violations)
+		s.statsTracker.RecordBlockedCall(name, "egress")
+		entry.Blocked = true
+		entry.BlockReason = "egress_denied"
+		entry.MatchedPattern = block.Reason
+		entry.RuleAction = "block"
+		s.recordCallAudit(entry, args)
+		return block
+	}
+
+	// Every HTTP-mode backend is remote, so all of them get the DLP scan
+	if findings, _ := ScanDLP(dlp, args); len(findings) > 0 {
+		if block := dlpBlock(name, findings); block != nil {
+			s.statsTracker.RecordBlockedCall(name, "dlp")
+			entry.Blocked = true
+			entry.BlockReason = "sensitive_data"
+			entry.MatchedPattern = block.Reason
+			entry.RuleAction = "block"
+			s.recordCallAudit(entry, args)
+			return block
+		}
+		s.logger.Warn("sensitive data in %s arguments: %s", name, formatDLPFindings(findings))
+	}
+
+	authz := t.cedar.authorize(PolicyCall{
+		Server:    server.Name,
+		Tool:      name,
+		Arguments: args,
+		SessionID: sessionID,
+		Transport: "http",
+		Host:      CurrentHostContext(),
+	})
+	for _, e := range authz.Errors {
+		s.logger.Warn("cedar policy %s ignored for %s", e, name)
+	}
+	if !authz.Allowed {
+		block := cedarBlock(name, authz)
+		s.statsTracker.RecordBlockedCall(name, "cedar")
+		entry.Blocked = true
+		entry.BlockReason = "cedar_forbid"
+		entry.MatchedPattern = authz.Policy
+		entry.RuleAction = "forbid"
+		s.recordCallAudit(entry, args)
+		return block
+	}
+
+	checker, verdict := runPolicyCheckers(ctx, s.checkers, PolicyCall{
+		Server:    server.Name,
+		Tool:      name,
+		Arguments: args,
+		SessionID: sessionID,
+		Transport: "http",
+		Host:      CurrentHostContext(),
+	})
+	if verdict.Decision != PolicyAllow {
+		block := pluginBlock(checker, name, verdict)
+		if verdict.Decision == PolicyAsk {
+			block.Reason += " (approval is not available in HTTP mode)"
+		}
+		s.statsTracker.RecordBlockedCall(name, "plugin")
+		entry.Blocked = true
+		entry.BlockReason = "policy_plugin"
+		entry.MatchedPattern = pluginMatch(checker, verdict, block)
+		entry.RuleAction = verdict.Decision
+		s.recordCallAudit(entry, args)
+		return block
+	}
+
+	tier := tp.TierFor(server.Name, TrustExplicit)
+	decision := tp.Decision(tier)
+	if decision != TrustAllow {
+		s.statsTracker.RecordBlockedCall(name, "trust")
+		entry.Blocked = true
+		entry.BlockReason = "trust_" + string(tier)
+		entry.RuleAction = string(decision)
+		s.recordCallAudit(entry, args)
+		block := &BlockError{
+			BlockedBy: BlockedByTrust,
+			Action:    string(decision),
+			Operation: "tools_call",
+			Tool:      name,
+			Reason:    fmt.Sprintf("%s servers are denied by the trust policy (server %s)", tier, server.Name),
+			Appeal:    "set trust.servers." + server.Name + ": explicit in the policy file to trust this server",
+		}
+		if decision == TrustAsk {
+			block.Reason = fmt.Sprintf("%s servers require approval, which is not available in HTTP mode (server %s)", tier, server.Name)
+		}
+		return block
+	}
+
+	s.statsTracker.RecordAllowedCall(name)
+	s.recordCallAudit(entry, args)
+	return nil
+}
+
+// recordCallAudit records entry with the tools/call arguments, if the audit
+// policy keeps them for the tool's class.
+func (s *Server) recordCallAudit(entry AuditEntry, args map[string]interface{}) {
+	s.mu.RLock()
+	audit := s.audit
+	s.mu.RUnlock()
+	entry.Quiet = entry.Method == "tools/call" && !entry.Blocked && audit.IsQuiet(entry.ToolName)
+	if entry.Method == "tools/call" && !audit.IsZero() {
+		entry.Arguments = audit.Arguments(ClassifyTool(entry.ToolName, nil), entry.Blocked, args)
+	}
+	s.recordAudit(entry)
+}
+
+// recordAudit writes entry to the audit log and counts the call for its
+// session, unless it is quiet.
+func (s *Server) recordAudit(entry AuditEntry) {
+	if !entry.Quiet {
+		s.statsTracker.RecordSessionCall(entry.SessionID, entry.Blocked)
+	}
+	if err := RecordAuditEntry(s.db, entry); err != nil {
+		s.logger.Warn("failed to record audit entry: %v", err)
+	}
+}
+
+func deniedResponse(id interface{}, block *BlockError) JSONRPCResponse {
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   deniedError(block),
+	}
+}
+
+// redactRequest replaces the sensitive data the DLP policy redacts in the
+// arguments of a tools/call request (or of each call in a batch). Anything
+// else is returned unchanged.
+func (s *Server) redactRequest(t *tenant, body []byte) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return body
+		}
+		changed := false
+		for i, item := range batch {
+			if redacted := s.redactRequest(t, item); !bytes.Equal(redacted, item) {
+				batch[i] = redacted
+				changed = true
+			}
+		}
+		if !changed {
+			return body
+		}
+		data, err := json.Marshal(batch)
+		if err != nil {
+			return body
+		}
+		return data
+	}
+
+	// Decode generically so fields the proxy doesn't know survive
+	var msg map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	if err := dec.Decode(&msg); err != nil || msg["method"] != "tools/call" {
+		return body
+	}
+	params, _ := msg["params"].(map[string]interface{})
+	args, _ := params["arguments"].(map[string]interface{})
+
+	findings, redacted := ScanDLP(t.dlp, args)
+	for _, f := range findings {
+		if f.Action == DLPRedact {
+			params["arguments"] = redacted
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return body
+			}
+			return data
+		}
+	}
+	return body
+}
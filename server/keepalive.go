@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPingInterval is how often backends are pinged to check they are alive.
+const DefaultPingInterval = 30 * time.Second
+
+const (
+	pingTimeout = 5 * time.Second
+	// maxMissedPings consecutive missed pongs open a backend's circuit:
+	// requests fail fast until it answers a ping again.
+	maxMissedPings = 3
+)
+
+var pingSeq atomic.Int64
+
+// BackendHealth is a backend's keepalive state as shown on the dashboard.
+type BackendHealth struct {
+	Name        string    `json:"name"`
+	Status      string    `json:"status"` // ok, degraded (missed pings) or unhealthy (circuit open)
+	LastPong    time.Time `json:"last_pong,omitempty"`
+	LatencyMs   int64     `json:"latency_ms"`
+	MissedPings int       `json:"missed_pings"`
+}
+
+// ping sends an MCP ping and records the outcome. Any response counts as a
+// pong, even an error from a server that doesn't implement ping: it is alive.
+func (bc *BackendConnection) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	req := JSONRPCRequest{
+		JSONRPC: "2.0",
+		ID:      fmt.Sprintf("armour-ping-%d", pingSeq.Add(1)),
+		Method:  "ping",
+	}
+	start := time.Now()
+	respBytes, err := bc.sendRequest(ctx, req)
+	if err == nil && !json.Valid(respBytes) {
+		err = fmt.Errorf("invalid ping response")
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if err != nil {
+		bc.missedPings++
+		return err
+	}
+	bc.missedPings = 0
+	bc.lastPong = time.Now()
+	bc.pingLatency = bc.lastPong.Sub(start)
+	return nil
+}
+
+// circuitOpen reports whether the backend missed too many pings to be used.
+func (bc *BackendConnection) circuitOpen() bool {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.missedPings >= maxMissedPings
+}
+
+// Health returns the backend's keepalive state.
+func (bc *BackendConnection) Health() BackendHealth {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	h := BackendHealth{
+		Name:        bc.config.Name,
+		Status:      HealthOK,
+		LastPong:    bc.lastPong,
+		LatencyMs:   bc.pingLatency.Milliseconds(),
+		MissedPings: bc.missedPings,
+	}
+	switch {
+	case bc.missedPings >= maxMissedPings:
+		h.Status = HealthUnhealthy
+	case bc.missedPings > 0:
+		h.Status = HealthDegraded
+	}
+	return h
+}
+
+// StartKeepalive pings every connected backend each interval until ctx is
+// done. A non-positive interval disables keepalive.
+func (bm *BackendManager) StartKeepalive(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				bm.PingBackends(ctx)
+			}
+		}
+	}()
+}
+
+// PingBackends pings all connected backends concurrently and waits for the results.
+func (bm *BackendManager) PingBackends(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, conn := range bm.GetInitializedBackends() {
+		wg.Add(1)
+		go func(conn *BackendConnection) {
+			defer wg.Done()
+			wasOpen := conn.circuitOpen()
+			if err := conn.ping(ctx); err != nil {
+				bm.logger.Warn("backend %s missed ping: %v", conn.config.Name, err)
+				if !wasOpen && conn.circuitOpen() {
+					bm.logger.Error("backend %s unresponsive, failing its requests until it answers a ping", conn.config.Name)
+				}
+			} else if wasOpen {
+				bm.logger.Info("backend %s answering pings again", conn.config.Name)
+			}
+		}(conn)
+	}
+	wg.Wait()
+}
+
+// BackendHealth returns the keepalive state of every connected backend, by name.
+func (bm *BackendManager) BackendHealth() []BackendHealth {
+	var health []BackendHealth
+	for _, conn := range bm.GetInitializedBackends() {
+		health = append(health, conn.Health())
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Name < health[j].Name })
+	return health
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// stalledTransport accepts requests but never answers, like a hung backend.
+type stalledTransport struct{ closed chan struct{} }
+
+func (t *stalledTransport) SendMessage(msg []byte) error { return nil }
+func (t *stalledTransport) ReceiveMessage() ([]byte, error) {
+	<-t.closed
+	return nil, context.Canceled
+}
+func (t *stalledTransport) Close() error                 { close(t.closed); return nil }
+func (t *stalledTransport) SupportsServerToClient() bool { return false }
+
+func TestKeepaliveOpensCircuit(t *testing.T) {
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "live"}, {Name: "hung"}}}
+	bm := NewBackendManager(registry, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	stalled := &stalledTransport{closed: make(chan struct{})}
+	defer stalled.Close()
+	for i, transport := range []proxy.Transport{newMockTransport(&MockFixture{}), stalled} {
+		entry := &registry.Servers[i]
+		bm.connections[entry.Name] = &BackendConnection{
+			config:      entry,
+			transport:   transport,
+			logger:      bm.logger,
+			initialized: true,
+			idle:        idleSessions(1, transport),
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < maxMissedPings; i++ {
+		// Shorten the wait by cancelling pings to the hung backend
+		pingCtx, stop := context.WithTimeout(ctx, 20*time.Millisecond)
+		bm.PingBackends(pingCtx)
+		stop()
+	}
+
+	health := bm.BackendHealth()
+	if len(health) != 2 || health[0].Name != "hung" || health[1].Name != "live" {
+		t.Fatalf("expected health for both backends sorted by name, got %+v", health)
+	}
+	if health[0].Status != HealthUnhealthy || health[0].MissedPings != maxMissedPings {
+		t.Errorf("expected hung backend to be unhealthy, got %+v", health[0])
+	}
+	if health[1].Status != HealthOK || health[1].LastPong.IsZero() {
+		t.Errorf("expected live backend to be ok, got %+v", health[1])
+	}
+
+	if _, err := bm.CallTool(ctx, "hung", "anything", nil); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("expected open circuit to fail fast, got %v", err)
+	}
+	if ready, total := bm.Summary(); ready != 1 || total != 2 {
+		t.Errorf("expected 1/2 ready, got %d/%d", ready, total)
+	}
+}
+
+func TestStdioServerAnswersPing(t *testing.T) {
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+
+	resp := srv.handleRequest(context.Background(), JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "ping"})
+	if data, _ := json.Marshal(resp); string(data) != `{"jsonrpc":"2.0","id":1,"result":{}}` {
+		t.Errorf("expected empty ping result, got %s", data)
+	}
+}
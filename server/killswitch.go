@@ -0,0 +1,150 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// killSwitchRefresh is how long a proxy trusts its cached kill switch state
+// before rereading it, so a switch thrown from one dashboard reaches every
+// proxy sharing the database within a second.
+const killSwitchRefresh = time.Second
+
+// KillSwitchState is whether the kill switch is engaged and what it blocks.
+type KillSwitchState struct {
+	Engaged   bool      `json:"engaged"`
+	Resources bool      `json:"resources"` // also block resources/read
+	Reason    string    `json:"reason,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+}
+
+// KillSwitch blocks every tools/call, and optionally resources/read, until
+// it is released. The state is kept in the proxy database so it survives
+// restarts and applies to every session using the database.
+type KillSwitch struct {
+	db     *sql.DB
+	mu     sync.Mutex
+	state  KillSwitchState
+	loaded time.Time
+	now    func() time.Time
+}
+
+// NewKillSwitch creates a kill switch backed by db. A nil db keeps the state
+// in memory only.
+func NewKillSwitch(db *sql.DB) (*KillSwitch, error) {
+	k := &KillSwitch{db: db, now: time.Now}
+	if db != nil {
+		if _, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS kill_switch (
+				id INTEGER PRIMARY KEY CHECK (id = 1),
+				engaged INTEGER NOT NULL DEFAULT 0,
+				resources INTEGER NOT NULL DEFAULT 0,
+				reason TEXT,
+				since TIMESTAMP
+			)
+		`); err != nil {
+			return nil, fmt.Errorf("failed to create kill_switch table: %w", err)
+		}
+	}
+	if _, err := k.load(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// load rereads the state from the database if the cached copy is stale.
+func (k *KillSwitch) load() (KillSwitchState, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.db == nil || (!k.loaded.IsZero() && k.now().Sub(k.loaded) < killSwitchRefresh) {
+		return k.state, nil
+	}
+
+	var state KillSwitchState
+	var reason sql.NullString
+	var since sql.NullTime
+	err := k.db.QueryRow("SELECT engaged, resources, reason, since FROM kill_switch WHERE id = 1").
+		Scan(&state.Engaged, &state.Resources, &reason, &since)
+	if err != nil && err != sql.ErrNoRows {
+		return k.state, fmt.Errorf("failed to load kill switch: %w", err)
+	}
+	state.Reason = reason.String
+	state.Since = since.Time
+	k.state = state
+	k.loaded = k.now()
+	return state, nil
+}
+
+// State returns the current state. If the database can't be read the last
+// known state is kept.
+func (k *KillSwitch) State() KillSwitchState {
+	state, _ := k.load()
+	return state
+}
+
+// Set engages or releases the kill switch.
+func (k *KillSwitch) Set(state KillSwitchState) error {
+	if !state.Engaged {
+		state = KillSwitchState{}
+	} else if state.Since.IsZero() {
+		state.Since = k.now()
+	}
+
+	if k.db != nil {
+		_, err := k.db.Exec(`
+			INSERT INTO kill_switch (id, engaged, resources, reason, since) VALUES (1, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET engaged = excluded.engaged, resources = excluded.resources,
+				reason = excluded.reason, since = excluded.since
+		`, state.Engaged, state.Resources, state.Reason, state.Since)
+		if err != nil {
+			return fmt.Errorf("failed to save kill switch: %w", err)
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.state = state
+	k.loaded = k.now()
+	return nil
+}
+
+// Check returns why a request is blocked by the kill switch, or nil if it
+// isn't. name is the tool or resource the request is for.
+func (k *KillSwitch) Check(method, name string) *BlockError {
+	if k == nil {
+		return nil
+	}
+	state := k.State()
+	if !state.Engaged {
+		return nil
+	}
+
+	var operation string
+	switch method {
+	case "tools/call":
+		operation = "tools_call"
+	case "resources/read":
+		if !state.Resources {
+			return nil
+		}
+		operation = "resources_read"
+	default:
+		return nil
+	}
+
+	reason := "kill switch engaged: all tool calls are blocked"
+	if state.Reason != "" {
+		reason += " (" + state.Reason + ")"
+	}
+	return &BlockError{
+		BlockedBy: BlockedByKillSwitch,
+		Action:    "block",
+		Operation: operation,
+		Tool:      name,
+		Reason:    reason,
+		Appeal:    DashboardURL,
+	}
+}
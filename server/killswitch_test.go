@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestKillSwitchPersists tests that the switch survives a restart and reaches
+// other proxies sharing the database
+func TestKillSwitchPersists(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "proxy.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ks, err := NewKillSwitch(db)
+	if err != nil {
+		t.Fatalf("failed to create kill switch: %v", err)
+	}
+	other, err := NewKillSwitch(db)
+	if err != nil {
+		t.Fatalf("failed to create kill switch: %v", err)
+	}
+	if ks.Check("tools/call", "github:run") != nil {
+		t.Fatal("expected the switch to start released")
+	}
+
+	if err := ks.Set(KillSwitchState{Engaged: true, Reason: "runaway agent"}); err != nil {
+		t.Fatalf("failed to engage: %v", err)
+	}
+	block := ks.Check("tools/call", "github:run")
+	if block == nil || block.BlockedBy != BlockedByKillSwitch || block.Tool != "github:run" {
+		t.Fatalf("expected tool call blocked, got %+v", block)
+	}
+	if ks.Check("resources/read", "armour://fs/notes") != nil || ks.Check("tools/list", "") != nil {
+		t.Error("expected only tool calls blocked without the resources option")
+	}
+
+	// The other proxy picks the change up once its cached state is stale
+	other.now = func() time.Time { return time.Now().Add(killSwitchRefresh) }
+	if other.Check("tools/call", "github:run") == nil {
+		t.Error("expected the switch to reach other proxies")
+	}
+
+	restarted, err := NewKillSwitch(db)
+	if err != nil {
+		t.Fatalf("failed to create kill switch: %v", err)
+	}
+	state := restarted.State()
+	if !state.Engaged || state.Reason != "runaway agent" || state.Since.IsZero() {
+		t.Errorf("expected the engaged state to survive a restart, got %+v", state)
+	}
+
+	if err := restarted.Set(KillSwitchState{Engaged: true, Resources: true}); err != nil {
+		t.Fatalf("failed to engage: %v", err)
+	}
+	if restarted.Check("resources/read", "armour://fs/notes") == nil {
+		t.Error("expected resource reads blocked with the resources option")
+	}
+	if err := restarted.Set(KillSwitchState{}); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if restarted.Check("tools/call", "github:run") != nil {
+		t.Error("expected the released switch to allow calls")
+	}
+}
+
+// TestKillSwitchBlocksStdioCalls tests that an engaged switch stops tool
+// calls without offering an approval link
+func TestKillSwitchBlocksStdioCalls(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: echo
+    result:
+      content: [{type: text, text: hi}]
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "echo", Transport: "mock", Fixture: fixture},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	call := func(id int) JSONRPCResponse {
+		return srv.handleRequest(ctx, JSONRPCRequest{ID: id, Method: "tools/call", Params: json.RawMessage(`{"name":"echo:echo","arguments":{}}`)}).(JSONRPCResponse)
+	}
+
+	if err := srv.GetKillSwitch().Set(KillSwitchState{Engaged: true}); err != nil {
+		t.Fatalf("failed to engage: %v", err)
+	}
+	resp := call(2)
+	if resp.Error == nil || resp.Error.Code != ErrCodeDenied {
+		t.Fatalf("expected kill switch denial, got %+v", resp)
+	}
+	if block := resp.Error.Data.(*BlockError); block.BlockedBy != BlockedByKillSwitch || block.ApprovalURL != "" {
+		t.Errorf("expected a kill switch block without approval link, got %+v", block)
+	}
+
+	if err := srv.GetKillSwitch().Set(KillSwitchState{}); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if resp := call(3); resp.Error != nil {
+		t.Errorf("expected the call to go through once released, got %+v", resp.Error)
+	}
+}
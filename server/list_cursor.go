@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// listCursor is where an aggregated list continues: the backend to resume
+// with and that backend's own cursor. Clients see it as an opaque string.
+type listCursor struct {
+	Backend string `json:"b"`
+	Cursor  string `json:"c,omitempty"`
+}
+
+func (c listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseListCursor reads the cursor from list request params. No cursor
+// means the first page.
+func parseListCursor(params json.RawMessage) (*listCursor, error) {
+	var p struct {
+		Cursor string `json:"cursor"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+	}
+	if p.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Backend == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// aggregateList builds one page of a list aggregated across backends. It
+// reads backends in name order from where cursor left off and keeps going
+// until one says it has more, so backends that don't paginate all fit on the
+// first page. namespace rewrites each item for the client. The returned
+// cursor is empty on the last page.
+func (s *StdioServer) aggregateList(
+	ctx context.Context,
+	cursor *listCursor,
+	list func(ctx context.Context, backendID, cursor string) ([]interface{}, string, error),
+	namespace func(backendID string, item map[string]interface{}),
+) ([]interface{}, string) {
+	backends := s.backendManager.GetInitializedBackends()
+	sort.Slice(backends, func(i, j int) bool { return backends[i].config.Name < backends[j].config.Name })
+
+	items := []interface{}{}
+	for _, backend := range backends {
+		name := backend.config.Name
+		backendCursor := ""
+		if cursor != nil {
+			// Backends before the cursor were served already; if the cursor's
+			// backend went away we simply carry on with the next one
+			if name < cursor.Backend {
+				continue
+			}
+			if name == cursor.Backend {
+				backendCursor = cursor.Cursor
+			}
+		}
+
+		page, next, err := list(ctx, name, backendCursor)
+		if err != nil {
+			s.logger.Warn("failed to list from backend %s: %v", name, err)
+			continue
+		}
+		for _, item := range page {
+			if m, ok := item.(map[string]interface{}); ok {
+				namespace(name, m)
+			}
+			items = append(items, item)
+		}
+		if next != "" {
+			return items, listCursor{Backend: name, Cursor: next}.encode()
+		}
+	}
+	return items, ""
+}
+
+// listResult builds a list result, with nextCursor only if there are more pages.
+func listResult(key string, items []interface{}, nextCursor string) map[string]interface{} {
+	result := map[string]interface{}{key: items}
+	if nextCursor != "" {
+		result["nextCursor"] = nextCursor
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestAggregatedListPagination(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture := func(name, body string) string {
+		path := filepath.Join(dir, name+".yaml")
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+		return path
+	}
+	// "alpha" pages by two, "beta" doesn't paginate, "gamma" has nothing
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "beta", Transport: "mock", Fixture: writeFixture("beta", "prompts: [{name: b1}]\n")},
+		{Name: "alpha", Transport: "mock", Fixture: writeFixture("alpha", `
+pageSize: 2
+tools: [{name: t1}, {name: t2}, {name: t3}]
+prompts: [{name: a1}, {name: a2}, {name: a3}, {name: a4}, {name: a5}]
+`)},
+		{Name: "gamma", Transport: "mock", Fixture: writeFixture("gamma", "tools: []\n")},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	// Every page of tools lands in the registry
+	for _, name := range []string{"alpha:t1", "alpha:t2", "alpha:t3"} {
+		if _, err := srv.toolRegistry.GetTool(name); err != nil {
+			t.Errorf("expected %s from a later tools/list page: %v", name, err)
+		}
+	}
+
+	var names []string
+	var pages int
+	params := json.RawMessage(`{}`)
+	for {
+		resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "prompts/list", Params: params}).(JSONRPCResponse)
+		if resp.Error != nil {
+			t.Fatalf("prompts/list failed: %+v", resp.Error)
+		}
+		result := resp.Result.(map[string]interface{})
+		for _, p := range result["prompts"].([]interface{}) {
+			names = append(names, p.(map[string]interface{})["name"].(string))
+		}
+		pages++
+		next, _ := result["nextCursor"].(string)
+		if next == "" {
+			break
+		}
+		if pages > 10 {
+			t.Fatal("pagination did not terminate")
+		}
+		params, _ = json.Marshal(map[string]string{"cursor": next})
+	}
+
+	want := []string{"alpha:a1", "alpha:a2", "alpha:a3", "alpha:a4", "alpha:a5", "beta:b1"}
+	if len(names) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, names)
+		}
+	}
+	// alpha's three pages, the last of which also carries beta and gamma
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "resources/list", Params: json.RawMessage(`{"cursor":"not-a-cursor"}`)}).(JSONRPCResponse)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("expected invalid params for a bad cursor, got %+v", resp)
+	}
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// LoadTestConfig describes synthetic tools/call traffic for RunLoadTest.
+type LoadTestConfig struct {
+	URL         string          // MCP endpoint of one server, e.g. http://127.0.0.1:8080/mcp/demo
+	Tool        string          // tool to call, as the endpoint names it
+	Arguments   json.RawMessage // arguments of every call
+	RPS         int             // calls started per second
+	Duration    time.Duration   // how long to keep starting calls
+	Concurrency int             // calls in flight at most; ticks beyond it are dropped
+	Sessions    int             // MCP sessions the calls are spread over
+	// SampleMemory records this process's heap while the test runs, which
+	// is only the proxy's when it runs in-process (StartLoadTestProxy).
+	SampleMemory bool
+}
+
+// LoadTestLatency summarizes call latencies in milliseconds.
+type LoadTestLatency struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// LoadTestResult is what a load test measured. Dropped counts calls that
+// were due while Concurrency calls were still in flight, so a proxy that
+// can't keep up shows it instead of the generator silently slowing down.
+type LoadTestResult struct {
+	URL            string          `json:"url"`
+	TargetRPS      int             `json:"target_rps"`
+	Seconds        float64         `json:"seconds"`
+	Requests       int64           `json:"requests"`
+	Succeeded      int64           `json:"succeeded"`
+	Failed         int64           `json:"failed"`
+	Dropped        int64           `json:"dropped"`
+	Throughput     float64         `json:"throughput_rps"`
+	Latency        LoadTestLatency `json:"latency"`
+	HeapPeakBytes  uint64          `json:"heap_peak_bytes,omitempty"`
+	HeapEndBytes   uint64          `json:"heap_end_bytes,omitempty"`
+	GoroutinesPeak int             `json:"goroutines_peak,omitempty"`
+	Errors         map[string]int  `json:"errors,omitempty"` // failures by reason
+}
+
+// Validate fills in defaults and checks the rest.
+func (c *LoadTestConfig) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if c.Tool == "" {
+		return fmt.Errorf("tool is required")
+	}
+	if c.RPS <= 0 {
+		return fmt.Errorf("rps must be positive")
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 256
+	}
+	if c.Sessions <= 0 {
+		c.Sessions = 16
+	}
+	if len(c.Arguments) == 0 {
+		c.Arguments = json.RawMessage(`{}`)
+	}
+	return nil
+}
+
+// RunLoadTest starts cfg.RPS tools/call requests a second at cfg.URL for
+// cfg.Duration, open loop, and waits for the calls in flight to finish.
+// Each session is initialized first, as a client would.
+func RunLoadTest(ctx context.Context, cfg LoadTestConfig) (*LoadTestResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: cfg.Concurrency},
+	}
+	sessions := make([]string, cfg.Sessions)
+	for i := range sessions {
+		sessions[i] = fmt.Sprintf("loadtest-%d-%d", os.Getpid(), i)
+		initialize := `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"` + proxy.MCPProtocolVersion + `","clientInfo":{"name":"mcp-proxy-loadtest","version":"` + proxy.Version + `"}}}`
+		if err := postLoadTestCall(ctx, client, cfg.URL, sessions[i], []byte(initialize)); err != nil {
+			return nil, fmt.Errorf("failed to initialize session: %w", err)
+		}
+	}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errs      = make(map[string]int)
+		wg        sync.WaitGroup
+		requests  int64
+		dropped   int64
+		heapPeak  uint64
+		gorPeak   int
+	)
+	slots := make(chan struct{}, cfg.Concurrency)
+
+	sampleDone := make(chan struct{})
+	if cfg.SampleMemory {
+		go func() {
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			var ms runtime.MemStats
+			for {
+				runtime.ReadMemStats(&ms)
+				mu.Lock()
+				if ms.HeapAlloc > heapPeak {
+					heapPeak = ms.HeapAlloc
+				}
+				if n := runtime.NumGoroutine(); n > gorPeak {
+					gorPeak = n
+				}
+				mu.Unlock()
+				select {
+				case <-ticker.C:
+				case <-sampleDone:
+					return
+				}
+			}
+		}()
+	}
+
+	interval := time.Second / time.Duration(cfg.RPS)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	start := time.Now()
+	deadline := time.NewTimer(cfg.Duration)
+	defer deadline.Stop()
+loop:
+	for i := 1; ; i++ {
+		select {
+		case slots <- struct{}{}:
+			atomic.AddInt64(&requests, 1)
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q,"arguments":%s}}`, id, cfg.Tool, cfg.Arguments)
+				began := time.Now()
+				err := postLoadTestCall(ctx, client, cfg.URL, sessions[id%len(sessions)], []byte(body))
+				elapsed := time.Since(began)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs[err.Error()]++
+					return
+				}
+				latencies = append(latencies, elapsed)
+			}(i)
+		default:
+			atomic.AddInt64(&dropped, 1)
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(sampleDone)
+
+	result := &LoadTestResult{
+		URL:            cfg.URL,
+		TargetRPS:      cfg.RPS,
+		Seconds:        elapsed.Seconds(),
+		Requests:       requests,
+		Succeeded:      int64(len(latencies)),
+		Failed:         requests - int64(len(latencies)),
+		Dropped:        dropped,
+		Throughput:     float64(len(latencies)) / elapsed.Seconds(),
+		Latency:        summarizeLatencies(latencies),
+		HeapPeakBytes:  heapPeak,
+		GoroutinesPeak: gorPeak,
+	}
+	if len(errs) > 0 {
+		result.Errors = errs
+	}
+	if cfg.SampleMemory {
+		runtime.GC()
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		result.HeapEndBytes = ms.HeapAlloc
+	}
+	return result, nil
+}
+
+// postLoadTestCall sends one JSON-RPC request and fails on anything but a
+// successful response.
+func postLoadTestCall(ctx context.Context, client *http.Client, url, session string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set(proxy.HeaderSessionID, session)
+	req.Header.Set(proxy.HeaderProtocolVersion, proxy.MCPProtocolVersion)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var reply struct {
+		Error *JSONRPCError `json:"error"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return fmt.Errorf("invalid JSON response")
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("JSON-RPC error %d: %s", reply.Error.Code, reply.Error.Message)
+	}
+	return nil
+}
+
+// summarizeLatencies computes the mean, nearest-rank percentiles and maximum.
+func summarizeLatencies(latencies []time.Duration) LoadTestLatency {
+	if len(latencies) == 0 {
+		return LoadTestLatency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	rank := func(p float64) float64 {
+		i := int(p*float64(len(latencies))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(latencies) {
+			i = len(latencies) - 1
+		}
+		return ms(latencies[i])
+	}
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	return LoadTestLatency{
+		Mean: ms(total / time.Duration(len(latencies))),
+		P50:  rank(0.50),
+		P90:  rank(0.90),
+		P99:  rank(0.99),
+		Max:  ms(latencies[len(latencies)-1]),
+	}
+}
+
+// StartLoadTestProxy serves an HTTP-mode proxy in this process, in front of
+// the built-in demo server only, on a loopback port. config's other settings,
+// such as workers and policies, apply. With a DBPath the proxy writes to a
+// throwaway database file instead, so storage behaves as on disk without
+// touching the real database. It returns the demo server's endpoint and a
+// function that stops the proxy.
+func StartLoadTestProxy(config Config) (url string, stop func(), err error) {
+	dir, err := os.MkdirTemp("", "armour-loadtest")
+	if err != nil {
+		return "", nil, err
+	}
+	if config.DBPath != "" {
+		config.DBPath = filepath.Join(dir, "armour.db")
+	}
+	config.Registry = &proxy.ServerRegistry{}
+	config.ConfigPath = ""
+	config.TenantsPath = ""
+	config.DemoServer = true
+	config.TLS = TLSConfig{}
+	srv, err := NewServer(config)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		srv.Close()
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	httpServer := &http.Server{Handler: srv.Handler()}
+	go httpServer.Serve(listener)
+	stop = func() {
+		httpServer.Close()
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+	return "http://" + listener.Addr().String() + "/mcp/" + DemoServerName, stop, nil
+}
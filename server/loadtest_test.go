@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLoadTest(t *testing.T) {
+	url, stop, err := StartLoadTestProxy(Config{LogLevel: "error"})
+	if err != nil {
+		t.Fatalf("failed to start proxy: %v", err)
+	}
+	defer stop()
+
+	result, err := RunLoadTest(context.Background(), LoadTestConfig{
+		URL:          url,
+		Tool:         "echo",
+		Arguments:    json.RawMessage(`{"message":"hi"}`),
+		RPS:          100,
+		Duration:     300 * time.Millisecond,
+		SampleMemory: true,
+	})
+	if err != nil {
+		t.Fatalf("load test failed: %v", err)
+	}
+	if result.Requests < 20 || result.Succeeded != result.Requests || result.Dropped != 0 {
+		t.Errorf("expected every call to succeed, got %+v", result)
+	}
+	if result.Latency.P50 <= 0 || result.Latency.P99 < result.Latency.P50 || result.Latency.Max < result.Latency.P99 {
+		t.Errorf("unexpected latencies %+v", result.Latency)
+	}
+	if result.HeapPeakBytes == 0 {
+		t.Error("expected memory to be sampled")
+	}
+
+	// Calls the backend rejects are counted as failures, with their reason
+	result, err = RunLoadTest(context.Background(), LoadTestConfig{URL: url, Tool: "missing", RPS: 50, Duration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("load test failed: %v", err)
+	}
+	if result.Failed == 0 || result.Succeeded != 0 || len(result.Errors) != 1 {
+		t.Errorf("expected every call to fail, got %+v", result)
+	}
+
+	if _, err := RunLoadTest(context.Background(), LoadTestConfig{URL: url, Tool: "echo", Duration: time.Second}); err == nil {
+		t.Error("expected a load test without a rate to be rejected")
+	}
+}
+
+func TestSummarizeLatencies(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	got := summarizeLatencies(latencies)
+	want := LoadTestLatency{Mean: 50.5, P50: 50, P90: 90, P99: 99, Max: 100}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := summarizeLatencies(nil); got != (LoadTestLatency{}) {
+		t.Errorf("expected no latencies to summarize to zero, got %+v", got)
+	}
+}
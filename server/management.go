@@ -0,0 +1,436 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+const (
+	// manageMaxBody caps the size of a pushed policy bundle.
+	manageMaxBody = 4 << 20
+	// manageAuditLimit caps the audit entries returned in one batch.
+	manageAuditLimit = 1000
+	// manageMinToken is the shortest management token accepted.
+	manageMinToken = 16
+)
+
+// Settings kept in the rules store about this instance and its controller.
+const (
+	settingInstanceID   = "instance_id"
+	settingInstanceName = "instance_name"
+	settingController   = "controller"
+	settingRegisteredAt = "controller_registered_at"
+)
+
+// ManagementConfig configures the remote management listener a central
+// controller uses to manage a fleet of proxies.
+type ManagementConfig struct {
+	ListenAddr  string    // empty disables the listener
+	Token       string    // bearer token the controller must send
+	TLS         TLSConfig // required unless ListenAddr is a loopback address
+	RulesDBPath string    // store policies are applied to (default DefaultRulesDBPath)
+}
+
+// Validate checks the listener is authenticated, and encrypted when it can
+// be reached from other machines.
+func (c ManagementConfig) Validate() error {
+	if len(c.Token) < manageMinToken {
+		return fmt.Errorf("the management listener needs a token of at least %d characters", manageMinToken)
+	}
+	host, _, err := net.SplitHostPort(c.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("invalid management listen address %q: %w", c.ListenAddr, err)
+	}
+	if !isLoopbackHost(host) && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return fmt.Errorf("the management listener needs a TLS certificate and key unless it listens on localhost")
+	}
+	return nil
+}
+
+// InstanceInfo identifies a proxy to its controller.
+type InstanceInfo struct {
+	ID           string          `json:"id"`
+	Name         string          `json:"name,omitempty"`
+	Hostname     string          `json:"hostname"`
+	Version      string          `json:"version"`
+	Mode         PolicyMode      `json:"mode"`
+	Controller   string          `json:"controller,omitempty"`
+	RegisteredAt time.Time       `json:"registered_at,omitempty"`
+	KillSwitch   KillSwitchState `json:"kill_switch"`
+}
+
+// ManagementServer serves the management API: a controller registers the
+// instance, pushes policy bundles, pulls audit batches and engages the kill
+// switch. Every request needs the bearer token.
+type ManagementServer struct {
+	config     ManagementConfig
+	stdio      *StdioServer
+	store      *RulesStore
+	tlsConfig  *tls.Config
+	httpServer *http.Server
+	logger     *proxy.Logger
+}
+
+// NewManagementServer creates the management API for stdio.
+func NewManagementServer(config ManagementConfig, stdio *StdioServer) (*ManagementServer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := config.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+	if config.RulesDBPath == "" {
+		config.RulesDBPath = DefaultRulesDBPath()
+	}
+	store, err := OpenRulesStore(config.RulesDBPath)
+	if err != nil {
+		return nil, err
+	}
+	return &ManagementServer{
+		config:    config,
+		stdio:     stdio,
+		store:     store,
+		tlsConfig: tlsConfig,
+		logger:    stdio.logger,
+	}, nil
+}
+
+// Handler returns the management API with token authentication.
+func (ms *ManagementServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manage/v1/instance", ms.handleInstance)
+	mux.HandleFunc("/manage/v1/register", ms.handleRegister)
+	mux.HandleFunc("/manage/v1/policy", ms.handlePolicy)
+	mux.HandleFunc("/manage/v1/audit", ms.handleAudit)
+	mux.HandleFunc("/manage/v1/killswitch", ms.handleKillSwitch)
+	return ms.requireToken(mux)
+}
+
+// Start listens on the configured address.
+func (ms *ManagementServer) Start() error {
+	listener, err := net.Listen("tcp", ms.config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", ms.config.ListenAddr, err)
+	}
+	if ms.tlsConfig != nil {
+		listener = tls.NewListener(listener, ms.tlsConfig)
+	}
+	ms.httpServer = &http.Server{
+		Handler:      ms.Handler(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	ms.logger.Info("management API started on %s (tls: %t)", listener.Addr().String(), ms.tlsConfig != nil)
+	go func() {
+		if err := ms.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			ms.logger.Error("management API error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Stop shuts the listener down and closes the rules store.
+func (ms *ManagementServer) Stop() error {
+	var err error
+	if ms.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = ms.httpServer.Shutdown(ctx)
+	}
+	ms.store.Close()
+	return err
+}
+
+func (ms *ManagementServer) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(ms.config.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="armour-manage"`)
+			writeManageError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// instanceID returns the instance's ID, generating it on first use.
+func (ms *ManagementServer) instanceID() (string, error) {
+	id, err := ms.store.GetSetting(settingInstanceID)
+	if err != nil || id != "" {
+		return id, err
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id = hex.EncodeToString(buf)
+	return id, ms.store.SetSetting(settingInstanceID, id)
+}
+
+func (ms *ManagementServer) instanceInfo() (InstanceInfo, error) {
+	id, err := ms.instanceID()
+	if err != nil {
+		return InstanceInfo{}, err
+	}
+	info := InstanceInfo{ID: id, Version: proxy.Version, KillSwitch: ms.stdio.GetKillSwitch().State()}
+	info.Hostname, _ = os.Hostname()
+	if ms.stdio.policyManager != nil {
+		info.Mode = ms.stdio.policyManager.GetMode()
+	}
+	if info.Name, err = ms.store.GetSetting(settingInstanceName); err != nil {
+		return InstanceInfo{}, err
+	}
+	if info.Controller, err = ms.store.GetSetting(settingController); err != nil {
+		return InstanceInfo{}, err
+	}
+	registered, err := ms.store.GetSetting(settingRegisteredAt)
+	if err != nil {
+		return InstanceInfo{}, err
+	}
+	if registered != "" {
+		info.RegisteredAt, _ = time.Parse(time.RFC3339, registered)
+	}
+	return info, nil
+}
+
+// handleInstance returns the instance's identity and state.
+func (ms *ManagementServer) handleInstance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeManageError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	info, err := ms.instanceInfo()
+	if err != nil {
+		writeManageError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeManageJSON(w, info)
+}
+
+// handleRegister records the controller managing this instance and returns
+// the instance's identity.
+func (ms *ManagementServer) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeManageError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req struct {
+		Controller string `json:"controller"`
+		Name       string `json:"name"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, manageMaxBody)).Decode(&req); err != nil {
+		writeManageError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if req.Controller == "" {
+		writeManageError(w, http.StatusBadRequest, "controller is required")
+		return
+	}
+	for key, value := range map[string]string{
+		settingController:   req.Controller,
+		settingInstanceName: req.Name,
+		settingRegisteredAt: time.Now().UTC().Format(time.RFC3339),
+	} {
+		if err := ms.store.SetSetting(key, value); err != nil {
+			writeManageError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	ms.logger.Info("registered with controller %s", req.Controller)
+
+	info, err := ms.instanceInfo()
+	if err != nil {
+		writeManageError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeManageJSON(w, info)
+}
+
+// handlePolicy returns the applied policy as YAML (GET) or applies a pushed
+// policy file (PUT), reporting what changed. ?dry_run=true only reports it.
+func (ms *ManagementServer) handlePolicy(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		pf, err := ExportPolicy(ms.store)
+		if err != nil {
+			writeManageError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		data, err := pf.Marshal()
+		if err != nil {
+			writeManageError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(data)
+
+	case http.MethodPut:
+		data, err := io.ReadAll(io.LimitReader(r.Body, manageMaxBody+1))
+		if err != nil {
+			writeManageError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(data) > manageMaxBody {
+			writeManageError(w, http.StatusRequestEntityTooLarge, "policy file too large")
+			return
+		}
+		pf, err := ParsePolicyFile(data)
+		if err != nil {
+			writeManageError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		var diff *PolicyDiff
+		if dryRun {
+			diff, err = DiffPolicy(ms.store, pf)
+		} else {
+			diff, err = ApplyPolicy(ms.store, pf)
+		}
+		if err != nil {
+			writeManageError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !dryRun && !diff.Empty() {
+			if err := ms.reloadPolicy(); err != nil {
+				writeManageError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			ms.logger.Info("applied policy pushed by the controller")
+		}
+		writeManageJSON(w, map[string]interface{}{
+			"applied": !dryRun && !diff.Empty(),
+			"changed": !diff.Empty(),
+			"diff":    FormatPolicyDiff(diff),
+			// The server allowlist filters the registry at startup.
+			"restart_required": diff.AllowlistFrom != diff.AllowlistTo,
+		})
+
+	default:
+		writeManageError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// reloadPolicy hands the applied policy to the running proxy. Rules are read
+// from the store on every check and need no reload.
+func (ms *ManagementServer) reloadPolicy() error {
+	sp, err := LoadStoredPolicy(ms.store)
+	if err != nil {
+		return err
+	}
+	if ms.stdio.policyManager != nil {
+		if sp.Mode != "" {
+			if err := ms.stdio.policyManager.SetMode(sp.Mode); err != nil {
+				return err
+			}
+		}
+		ms.stdio.policyManager.SetModeSchedule(sp.ModeSchedule)
+	}
+	if sp.Trust.IsZero() {
+		sp.Trust = DefaultTrustPolicy()
+	}
+	ms.stdio.SetStoredPolicy(*sp)
+	return nil
+}
+
+// handleAudit returns audit entries after ?after= in ascending order, at most
+// ?limit= of them, with the cursor to pass for the next batch.
+func (ms *ManagementServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeManageError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	filter := AuditFilter{Oldest: true, Limit: manageAuditLimit}
+	if raw := query.Get("after"); raw != "" {
+		after, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || after < 0 {
+			writeManageError(w, http.StatusBadRequest, "after must be an entry ID")
+			return
+		}
+		filter.AfterID = after
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			writeManageError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		if limit < manageAuditLimit {
+			filter.Limit = limit
+		}
+	}
+
+	entries, err := QueryAuditLog(ms.stdio.GetDB(), filter)
+	if err != nil {
+		writeManageError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	next := filter.AfterID
+	if len(entries) > 0 {
+		next = entries[len(entries)-1].ID
+	}
+	writeManageJSON(w, map[string]interface{}{
+		"entries": entries,
+		"next":    next,
+		"more":    len(entries) == filter.Limit,
+	})
+}
+
+// handleKillSwitch returns (GET) or sets (POST) the kill switch.
+func (ms *ManagementServer) handleKillSwitch(w http.ResponseWriter, r *http.Request) {
+	ks := ms.stdio.GetKillSwitch()
+	switch r.Method {
+	case http.MethodGet:
+		writeManageJSON(w, ks.State())
+	case http.MethodPost:
+		var state KillSwitchState
+		if err := json.NewDecoder(io.LimitReader(r.Body, manageMaxBody)).Decode(&state); err != nil {
+			writeManageError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		state.Since = time.Time{}
+		if err := ks.Set(state); err != nil {
+			writeManageError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if state.Engaged {
+			ms.logger.Warn("kill switch engaged by the controller: %s", state.Reason)
+		} else {
+			ms.logger.Info("kill switch released by the controller")
+		}
+		writeManageJSON(w, ks.State())
+	default:
+		writeManageError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func writeManageJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeManageError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+const testManageToken = "controller-token-0123456789"
+
+// TestManagementConfigValidate tests that the listener needs a token, and
+// TLS beyond localhost
+func TestManagementConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ManagementConfig
+		wantErr bool
+	}{
+		{"loopback", ManagementConfig{ListenAddr: "127.0.0.1:9443", Token: testManageToken}, false},
+		{"short token", ManagementConfig{ListenAddr: "127.0.0.1:9443", Token: "secret"}, true},
+		{"remote without TLS", ManagementConfig{ListenAddr: ":9443", Token: testManageToken}, true},
+		{"remote with TLS", ManagementConfig{ListenAddr: ":9443", Token: testManageToken, TLS: TLSConfig{CertFile: "c.pem", KeyFile: "k.pem"}}, false},
+		{"bad address", ManagementConfig{ListenAddr: "9443", Token: testManageToken}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+// TestManagementAPI tests registering, pushing a policy, pulling audit
+// batches and the kill switch
+func TestManagementAPI(t *testing.T) {
+	dir := t.TempDir()
+	policyManager := NewPolicyManager(nil)
+	stdio, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(dir, "proxy.db")}, &proxy.ServerRegistry{}, NewStatsTracker(), policyManager, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer stdio.Close()
+
+	ms, err := NewManagementServer(ManagementConfig{
+		ListenAddr:  "127.0.0.1:0",
+		Token:       testManageToken,
+		RulesDBPath: filepath.Join(dir, "rules.db"),
+	}, stdio)
+	if err != nil {
+		t.Fatalf("failed to create management server: %v", err)
+	}
+	defer ms.Stop()
+	ts := httptest.NewServer(ms.Handler())
+	defer ts.Close()
+
+	do := func(method, path, token, body string) (int, []byte) {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, data
+	}
+
+	if code, _ := do("GET", "/manage/v1/instance", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", code)
+	}
+	if code, _ := do("GET", "/manage/v1/instance", "wrong-token-0123456789", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong token, got %d", code)
+	}
+
+	code, body := do("POST", "/manage/v1/register", testManageToken, `{"controller":"https://fleet.example.com","name":"laptop-42"}`)
+	var info InstanceInfo
+	if code != http.StatusOK || json.Unmarshal(body, &info) != nil {
+		t.Fatalf("register failed: %d %s", code, body)
+	}
+	if info.ID == "" || info.Name != "laptop-42" || info.Controller != "https://fleet.example.com" || info.RegisteredAt.IsZero() {
+		t.Errorf("unexpected instance info: %+v", info)
+	}
+	code, body = do("GET", "/manage/v1/instance", testManageToken, "")
+	var again InstanceInfo
+	if code != http.StatusOK || json.Unmarshal(body, &again) != nil || again.ID != info.ID {
+		t.Errorf("expected a stable instance ID, got %d %s", code, body)
+	}
+
+	policy := "mode: strict\nrules:\n  - name: no-force-push\n    tools: Bash\n    pattern: \"push --force\"\n"
+	code, body = do("PUT", "/manage/v1/policy?dry_run=true", testManageToken, policy)
+	if code != http.StatusOK || !strings.Contains(string(body), `"applied":false`) || !strings.Contains(string(body), `"changed":true`) {
+		t.Errorf("unexpected dry run: %d %s", code, body)
+	}
+	if policyManager.GetMode() == StrictMode {
+		t.Error("expected a dry run to leave the mode alone")
+	}
+	code, body = do("PUT", "/manage/v1/policy", testManageToken, policy)
+	if code != http.StatusOK || !strings.Contains(string(body), `"applied":true`) {
+		t.Errorf("unexpected apply: %d %s", code, body)
+	}
+	if policyManager.GetMode() != StrictMode {
+		t.Errorf("expected the pushed mode to apply live, got %s", policyManager.GetMode())
+	}
+	if code, _ := do("PUT", "/manage/v1/policy", testManageToken, "mode: reckless\n"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid policy, got %d", code)
+	}
+	if code, body := do("GET", "/manage/v1/policy", testManageToken, ""); code != http.StatusOK || !strings.Contains(string(body), "no-force-push") {
+		t.Errorf("expected the applied policy, got %d %s", code, body)
+	}
+
+	for _, tool := range []string{"a", "b", "c"} {
+		if err := RecordAuditEntry(stdio.GetDB(), AuditEntry{ServerID: "github", Method: "tools/call", ToolName: tool}); err != nil {
+			t.Fatalf("failed to record audit entry: %v", err)
+		}
+	}
+	var batch struct {
+		Entries []AuditEntry `json:"entries"`
+		Next    int64        `json:"next"`
+		More    bool         `json:"more"`
+	}
+	code, body = do("GET", "/manage/v1/audit?limit=2", testManageToken, "")
+	if code != http.StatusOK || json.Unmarshal(body, &batch) != nil {
+		t.Fatalf("audit failed: %d %s", code, body)
+	}
+	if len(batch.Entries) != 2 || batch.Entries[0].ToolName != "a" || !batch.More {
+		t.Errorf("expected the first two entries, got %s", body)
+	}
+	code, body = do("GET", "/manage/v1/audit?limit=2&after="+strconv.FormatInt(batch.Next, 10), testManageToken, "")
+	batch.Entries = nil
+	if code != http.StatusOK || json.Unmarshal(body, &batch) != nil || len(batch.Entries) != 1 || batch.Entries[0].ToolName != "c" || batch.More {
+		t.Errorf("expected the last entry, got %d %s", code, body)
+	}
+
+	code, body = do("POST", "/manage/v1/killswitch", testManageToken, `{"engaged":true,"reason":"incident 7"}`)
+	if code != http.StatusOK || !strings.Contains(string(body), `"engaged":true`) {
+		t.Errorf("unexpected kill switch response: %d %s", code, body)
+	}
+	if state := stdio.GetKillSwitch().State(); !state.Engaged || state.Reason != "incident 7" {
+		t.Errorf("expected the kill switch engaged, got %+v", state)
+	}
+}
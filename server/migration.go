@@ -94,6 +94,7 @@ func (cm *ConfigMigrator) MigrateWithServers(servers []DetectedServer, policyMod
 		}
 		registryServers[i] = entry
 	}
+	registryServers = append(registryServers, cm.previouslyRegistered(servers)...)
 
 	// Write proxy config with detected servers
 	proxyConfig := map[string]interface{}{
@@ -176,9 +177,9 @@ func (cm *ConfigMigrator) cleanClaudeGlobalConfig() error {
 	if projects, ok := config["projects"].(map[string]interface{}); ok {
 		for _, projVal := range projects {
 			if project, ok := projVal.(map[string]interface{}); ok {
-				if _, hasServers := project["mcpServers"]; hasServers {
-					// Clear the mcpServers map
-					project["mcpServers"] = make(map[string]interface{})
+				if servers, hasServers := project["mcpServers"].(map[string]interface{}); hasServers {
+					// Keep only the proxy entry
+					project["mcpServers"] = proxyEntriesOnly(servers)
 				}
 			}
 		}
@@ -197,6 +198,53 @@ func (cm *ConfigMigrator) cleanClaudeGlobalConfig() error {
 	return nil
 }
 
+// previouslyRegistered returns the servers of an existing proxy config that
+// were not detected again. After a first migration clients only list the
+// proxy, so re-running migrate for a server an agent re-added must keep the
+// rest.
+func (cm *ConfigMigrator) previouslyRegistered(detected []DetectedServer) []map[string]interface{} {
+	data, err := os.ReadFile(cm.proxyConfigPath)
+	if err != nil {
+		return nil
+	}
+	var existing struct {
+		Servers []map[string]interface{} `json:"servers"`
+	}
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return nil
+	}
+	names := make(map[string]bool, len(detected))
+	for _, srv := range detected {
+		names[srv.Name] = true
+	}
+	var kept []map[string]interface{}
+	for _, entry := range existing.Servers {
+		if name, _ := entry["name"].(string); !names[name] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// proxyEntriesOnly drops the servers of an mcpServers map that run directly.
+func proxyEntriesOnly(servers map[string]interface{}) map[string]interface{} {
+	kept := make(map[string]interface{})
+	for name, val := range servers {
+		entry, _ := val.(map[string]interface{})
+		command, _ := entry["command"].(string)
+		env := make(map[string]string)
+		if rawEnv, ok := entry["env"].(map[string]interface{}); ok {
+			for k, v := range rawEnv {
+				env[k], _ = v.(string)
+			}
+		}
+		if IsProxyServer(name, command, env) {
+			kept[name] = val
+		}
+	}
+	return kept
+}
+
 // backupFile copies a file to a backup location.
 func (cm *ConfigMigrator) backupFile(src, dst string) error {
 	source, err := os.Open(src)
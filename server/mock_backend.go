@@ -0,0 +1,414 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+	"gopkg.in/yaml.v3"
+)
+
+// MockFixture describes a fake MCP server for the "mock" transport. It
+// answers deterministically, so policies, namespacing and dashboard flows
+// can be tested without real servers:
+//
+//	tools:
+//	  - name: web_search
+//	    inputSchema: {type: object, properties: {query: {type: string}}}
+//	    text: "no results for {{query}}"
+//	  - name: drop_table
+//	    error: permission denied
+//	  - name: slow_query
+//	    delay: 2s
+//	    text: done
+//	resources:
+//	  - uri: file:///notes.txt
+//	    mimeType: text/plain
+//	    text: hello
+//	prompts:
+//	  - name: summarize
+//	    arguments: [{name: topic, required: true}]
+//	    text: "Summarize {{topic}}"
+//
+// {{name}} in a text is replaced with the call's argument of that name.
+// With pageSize set, lists are returned in pages of that many items. With
+// subscribe set, the server declares resources.subscribe and accepts
+// resources/subscribe; with listChanged set, it declares prompts.listChanged.
+type MockFixture struct {
+	Tools       []MockTool     `yaml:"tools"`
+	Resources   []MockResource `yaml:"resources"`
+	Prompts     []MockPrompt   `yaml:"prompts"`
+	PageSize    int            `yaml:"pageSize,omitempty"`
+	Subscribe   bool           `yaml:"subscribe,omitempty"`
+	ListChanged bool           `yaml:"listChanged,omitempty"`
+}
+
+// MockTool is a fake tool. Result, if set, is returned as the raw
+// CallToolResult; otherwise Error yields an isError result and Text a text one.
+// Calls are answered after Delay.
+type MockTool struct {
+	Name         string                 `yaml:"name"`
+	Description  string                 `yaml:"description,omitempty"`
+	InputSchema  map[string]interface{} `yaml:"inputSchema,omitempty"`
+	OutputSchema map[string]interface{} `yaml:"outputSchema,omitempty"`
+	Text         string                 `yaml:"text,omitempty"`
+	Error        string                 `yaml:"error,omitempty"`
+	Result       map[string]interface{} `yaml:"result,omitempty"`
+	Delay        time.Duration          `yaml:"delay,omitempty"`
+}
+
+// MockResource is a fake text resource.
+type MockResource struct {
+	URI         string `yaml:"uri"`
+	Name        string `yaml:"name,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	MimeType    string `yaml:"mimeType,omitempty"`
+	Text        string `yaml:"text"`
+}
+
+// MockPrompt is a fake prompt returning a single user message.
+type MockPrompt struct {
+	Name        string               `yaml:"name"`
+	Description string               `yaml:"description,omitempty"`
+	Arguments   []MockPromptArgument `yaml:"arguments,omitempty"`
+	Text        string               `yaml:"text"`
+}
+
+// MockPromptArgument declares a prompt argument.
+type MockPromptArgument struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty"`
+	// Values are offered by completion/complete, filtered by prefix
+	Values []string `yaml:"values,omitempty" json:"-"`
+}
+
+// LoadMockFixture reads and validates a fixture file. DemoFixture loads the
+// built-in demo fixture.
+func LoadMockFixture(path string) (*MockFixture, error) {
+	if path == DemoFixture {
+		return demoFixture(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock fixture: %w", err)
+	}
+	var fixture MockFixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse mock fixture %s: %w", path, err)
+	}
+	if err := fixture.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid mock fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Validate checks that tools, resources and prompts are named and unique.
+func (f *MockFixture) Validate() error {
+	seen := make(map[string]bool)
+	check := func(kind, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s without a name", kind)
+		}
+		if seen[kind+" "+name] {
+			return fmt.Errorf("duplicate %s %q", kind, name)
+		}
+		seen[kind+" "+name] = true
+		return nil
+	}
+	for _, t := range f.Tools {
+		if err := check("tool", t.Name); err != nil {
+			return err
+		}
+	}
+	for _, r := range f.Resources {
+		if err := check("resource", r.URI); err != nil {
+			return err
+		}
+	}
+	for _, p := range f.Prompts {
+		if err := check("prompt", p.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handle answers a JSON-RPC request, or a batch of them, the way a real
+// server with the fixture's capabilities would. It returns nil when there is
+// nothing to answer (notifications only).
+func (f *MockFixture) Handle(msg []byte) []byte {
+	if trimmed := strings.TrimSpace(string(msg)); strings.HasPrefix(trimmed, "[") {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(msg, &batch); err != nil {
+			return mockError(nil, -32700, "Parse error")
+		}
+		var replies []json.RawMessage
+		for _, item := range batch {
+			if reply := f.Handle(item); reply != nil {
+				replies = append(replies, reply)
+			}
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+		data, _ := json.Marshal(replies)
+		return data
+	}
+
+	var req JSONRPCRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return mockError(nil, -32700, "Parse error")
+	}
+	if req.ID == nil {
+		return nil
+	}
+
+	var params struct {
+		Name      string                 `json:"name"`
+		URI       string                 `json:"uri"`
+		Arguments map[string]interface{} `json:"arguments"`
+		Cursor    string                 `json:"cursor"`
+		Ref       CompletionRef          `json:"ref"`
+		Argument  struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"argument"`
+	}
+	json.Unmarshal(req.Params, &params)
+
+	var result interface{}
+	switch req.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"protocolVersion": proxy.MCPProtocolVersion,
+			"capabilities": map[string]interface{}{
+				"tools":       map[string]interface{}{},
+				"resources":   map[string]interface{}{"subscribe": f.Subscribe},
+				"prompts":     map[string]interface{}{"listChanged": f.ListChanged},
+				"completions": map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "armour-mock", "version": proxy.Version},
+		}
+	case "ping":
+		result = map[string]interface{}{}
+	case "tools/list":
+		tools := make([]interface{}, 0, len(f.Tools))
+		for _, t := range f.Tools {
+			schema := t.InputSchema
+			if schema == nil {
+				schema = map[string]interface{}{"type": "object"}
+			}
+			tool := map[string]interface{}{"name": t.Name, "inputSchema": schema}
+			if t.Description != "" {
+				tool["description"] = t.Description
+			}
+			if t.OutputSchema != nil {
+				tool["outputSchema"] = t.OutputSchema
+			}
+			tools = append(tools, tool)
+		}
+		if result = f.page("tools", tools, params.Cursor); result == nil {
+			return mockError(req.ID, -32602, "Invalid cursor")
+		}
+	case "tools/call":
+		tool := f.tool(params.Name)
+		if tool == nil {
+			return mockError(req.ID, -32602, "Unknown tool: "+params.Name)
+		}
+		time.Sleep(tool.Delay)
+		switch {
+		case tool.Result != nil:
+			result = tool.Result
+		case tool.Error != "":
+			result = map[string]interface{}{
+				"content": []interface{}{textContent(expandMockText(tool.Error, params.Arguments))},
+				"isError": true,
+			}
+		default:
+			result = map[string]interface{}{
+				"content": []interface{}{textContent(expandMockText(tool.Text, params.Arguments))},
+			}
+		}
+	case "resources/list":
+		resources := make([]interface{}, 0, len(f.Resources))
+		for _, r := range f.Resources {
+			resources = append(resources, map[string]interface{}{
+				"uri":         r.URI,
+				"name":        orDefault(r.Name, r.URI),
+				"description": r.Description,
+				"mimeType":    orDefault(r.MimeType, "text/plain"),
+			})
+		}
+		if result = f.page("resources", resources, params.Cursor); result == nil {
+			return mockError(req.ID, -32602, "Invalid cursor")
+		}
+	case "resources/templates/list":
+		result = f.page("resourceTemplates", []interface{}{}, params.Cursor)
+	case "resources/read":
+		for _, r := range f.Resources {
+			if r.URI == params.URI {
+				result = map[string]interface{}{"contents": []interface{}{map[string]interface{}{
+					"uri":      r.URI,
+					"mimeType": orDefault(r.MimeType, "text/plain"),
+					"text":     r.Text,
+				}}}
+			}
+		}
+		if result == nil {
+			return mockError(req.ID, -32002, "Resource not found: "+params.URI)
+		}
+	case "resources/subscribe", "resources/unsubscribe":
+		if !f.Subscribe {
+			return mockError(req.ID, -32601, "Method not found: "+req.Method)
+		}
+		result = map[string]interface{}{}
+	case "prompts/list":
+		prompts := make([]interface{}, 0, len(f.Prompts))
+		for _, p := range f.Prompts {
+			prompts = append(prompts, map[string]interface{}{
+				"name":        p.Name,
+				"description": p.Description,
+				"arguments":   p.Arguments,
+			})
+		}
+		if result = f.page("prompts", prompts, params.Cursor); result == nil {
+			return mockError(req.ID, -32602, "Invalid cursor")
+		}
+	case "prompts/get":
+		for _, p := range f.Prompts {
+			if p.Name == params.Name {
+				result = map[string]interface{}{
+					"description": p.Description,
+					"messages": []interface{}{map[string]interface{}{
+						"role":    "user",
+						"content": textContent(expandMockText(p.Text, params.Arguments)),
+					}},
+				}
+			}
+		}
+		if result == nil {
+			return mockError(req.ID, -32602, "Unknown prompt: "+params.Name)
+		}
+	case "completion/complete":
+		values := []string{}
+		for _, p := range f.Prompts {
+			if params.Ref.Type != "ref/prompt" || p.Name != params.Ref.Name {
+				continue
+			}
+			for _, arg := range p.Arguments {
+				if arg.Name != params.Argument.Name {
+					continue
+				}
+				for _, v := range arg.Values {
+					if strings.HasPrefix(v, params.Argument.Value) {
+						values = append(values, v)
+					}
+				}
+			}
+		}
+		result = map[string]interface{}{"completion": map[string]interface{}{"values": values}}
+	default:
+		return mockError(req.ID, -32601, "Method not found: "+req.Method)
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	return data
+}
+
+// page returns the page of items starting at cursor (an item offset), or nil
+// if the cursor is invalid.
+func (f *MockFixture) page(key string, items []interface{}, cursor string) map[string]interface{} {
+	if f.PageSize <= 0 {
+		return map[string]interface{}{key: items}
+	}
+	start := 0
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 0 || n > len(items) {
+			return nil
+		}
+		start = n
+	}
+	end := start + f.PageSize
+	if end >= len(items) {
+		return map[string]interface{}{key: items[start:]}
+	}
+	return map[string]interface{}{key: items[start:end], "nextCursor": strconv.Itoa(end)}
+}
+
+func (f *MockFixture) tool(name string) *MockTool {
+	for i := range f.Tools {
+		if f.Tools[i].Name == name {
+			return &f.Tools[i]
+		}
+	}
+	return nil
+}
+
+var mockPlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// expandMockText replaces {{name}} with the argument of that name.
+func expandMockText(text string, args map[string]interface{}) string {
+	return mockPlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		v, ok := args[mockPlaceholder.FindStringSubmatch(m)[1]]
+		if !ok {
+			return ""
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		data, _ := json.Marshal(v)
+		return string(data)
+	})
+}
+
+func mockError(id interface{}, code int, message string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   map[string]interface{}{"code": code, "message": message},
+	})
+	return data
+}
+
+func orDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// mockTransport connects a backend to a fixture in-process.
+type mockTransport struct {
+	fixture *MockFixture
+	pending chan []byte
+}
+
+func newMockTransport(fixture *MockFixture) *mockTransport {
+	return &mockTransport{fixture: fixture, pending: make(chan []byte, 16)}
+}
+
+// SendMessage answers in the background, so a slow tool can be abandoned
+// like a real server's.
+func (t *mockTransport) SendMessage(msg []byte) error {
+	go func() {
+		if reply := t.fixture.Handle(msg); reply != nil {
+			t.pending <- reply
+		}
+	}()
+	return nil
+}
+
+func (t *mockTransport) ReceiveMessage() ([]byte, error) {
+	return <-t.pending, nil
+}
+
+func (t *mockTransport) Close() error { return nil }
+
+func (t *mockTransport) SupportsServerToClient() bool { return false }
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+const testFixture = `
+tools:
+  - name: web_search
+    inputSchema: {type: object, properties: {query: {type: string}}}
+    text: "No results for {{query}}"
+  - name: delete_repo
+    error: "refusing to delete {{repo}}"
+resources:
+  - uri: file:///notes.txt
+    text: Meeting at 10am
+prompts:
+  - name: summarize
+    arguments: [{name: topic, required: true}]
+    text: "Summarize {{topic}}"
+`
+
+func writeTestFixture(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	if err := os.WriteFile(path, []byte(testFixture), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	return path
+}
+
+func TestMockFixtureHandle(t *testing.T) {
+	fixture, err := LoadMockFixture(writeTestFixture(t))
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+
+	call := func(msg string) map[string]interface{} {
+		t.Helper()
+		var resp map[string]interface{}
+		if err := json.Unmarshal(fixture.Handle([]byte(msg)), &resp); err != nil {
+			t.Fatalf("invalid response to %s: %v", msg, err)
+		}
+		return resp
+	}
+	result := func(resp map[string]interface{}) string {
+		data, _ := json.Marshal(resp["result"])
+		return string(data)
+	}
+
+	if got := result(call(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"web_search","arguments":{"query":"go"}}}`)); !strings.Contains(got, "No results for go") {
+		t.Errorf("expected templated tool text, got %s", got)
+	}
+	if got := result(call(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"delete_repo","arguments":{"repo":"armour"}}}`)); !strings.Contains(got, `"isError":true`) || !strings.Contains(got, "refusing to delete armour") {
+		t.Errorf("expected error result, got %s", got)
+	}
+	if got := result(call(`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"file:///notes.txt"}}`)); !strings.Contains(got, "Meeting at 10am") {
+		t.Errorf("expected resource text, got %s", got)
+	}
+	if got := result(call(`{"jsonrpc":"2.0","id":4,"method":"prompts/get","params":{"name":"summarize","arguments":{"topic":"MCP"}}}`)); !strings.Contains(got, "Summarize MCP") {
+		t.Errorf("expected prompt text, got %s", got)
+	}
+	if resp := call(`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`); resp["error"] == nil {
+		t.Errorf("expected error for unknown tool, got %v", resp)
+	}
+	if resp := call(`{"jsonrpc":"2.0","id":6,"method":"bogus"}`); resp["error"] == nil {
+		t.Errorf("expected method not found, got %v", resp)
+	}
+
+	if reply := fixture.Handle([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); reply != nil {
+		t.Errorf("expected no reply to a notification, got %s", reply)
+	}
+	var batch []map[string]interface{}
+	reply := fixture.Handle([]byte(`[{"jsonrpc":"2.0","id":7,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":8,"method":"tools/list"}]`))
+	if err := json.Unmarshal(reply, &batch); err != nil || len(batch) != 2 {
+		t.Errorf("expected two batch replies, got %s", reply)
+	}
+
+	if _, err := LoadMockFixture(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing fixture")
+	}
+	if err := (&MockFixture{Tools: []MockTool{{Name: "a"}, {Name: "a"}}}).Validate(); err == nil {
+		t.Error("expected duplicate tool names to fail validation")
+	}
+}
+
+func TestMockBackendInStdioMode(t *testing.T) {
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "search", Transport: "mock", Fixture: writeTestFixture(t)},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	if _, err := srv.toolRegistry.GetTool("search:web_search"); err != nil {
+		t.Fatalf("expected namespaced mock tool to be registered: %v", err)
+	}
+
+	resp, ok := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "tools/call", Params: json.RawMessage(`{"name":"search:web_search","arguments":{"query":"armour"}}`)}).(JSONRPCResponse)
+	if !ok || resp.Error != nil {
+		t.Fatalf("expected tool call to succeed, got %+v", resp)
+	}
+	if data, _ := json.Marshal(resp.Result); !strings.Contains(string(data), "No results for armour") {
+		t.Errorf("expected fixture result, got %s", data)
+	}
+}
+
+func TestMockBackendInHTTPMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+	configJSON := `{"servers": [{"name": "search", "transport": "mock", "fixture": "` + writeTestFixture(t) + `"}]}`
+	if err := os.WriteFile(configPath, []byte(configJSON), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("ARMOUR_RULES_URL", "")
+
+	server, err := NewServer(Config{ListenAddr: "127.0.0.1:0", ConfigPath: configPath, DBPath: filepath.Join(tmpDir, "proxy.db"), Mode: "http"})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer server.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp/search", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"web_search","arguments":{"query":"go"}}}`))
+	req.Host = "127.0.0.1"
+	req.Header.Set(proxy.HeaderSessionID, "mock-session")
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "No results for go") {
+		t.Errorf("expected fixture response, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OfflineEnv engages (true) or releases (false) offline mode when the proxy starts.
+const OfflineEnv = "ARMOUR_OFFLINE"
+
+// OfflineState is whether offline mode is on.
+type OfflineState struct {
+	Enabled bool      `json:"enabled"`
+	Reason  string    `json:"reason,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+}
+
+// OfflineSwitch keeps tool calls off the network while it is on: calls to
+// http and sse backends, and calls whose arguments contain URLs of other
+// hosts than this machine, are blocked, so purely local tools keep working
+// on a plane or in a secure environment. Like the kill switch, the state is
+// kept in the proxy database and reread at most once a second.
+type OfflineSwitch struct {
+	db     *sql.DB
+	mu     sync.Mutex
+	state  OfflineState
+	loaded time.Time
+	now    func() time.Time
+}
+
+// NewOfflineSwitch creates an offline switch backed by db. A nil db keeps
+// the state in memory only.
+func NewOfflineSwitch(db *sql.DB) (*OfflineSwitch, error) {
+	o := &OfflineSwitch{db: db, now: time.Now}
+	if db != nil {
+		if _, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS offline_mode (
+				id INTEGER PRIMARY KEY CHECK (id = 1),
+				enabled INTEGER NOT NULL DEFAULT 0,
+				reason TEXT,
+				since TIMESTAMP
+			)
+		`); err != nil {
+			return nil, fmt.Errorf("failed to create offline_mode table: %w", err)
+		}
+	}
+	if _, err := o.load(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// load rereads the state from the database if the cached copy is stale.
+func (o *OfflineSwitch) load() (OfflineState, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.db == nil || (!o.loaded.IsZero() && o.now().Sub(o.loaded) < killSwitchRefresh) {
+		return o.state, nil
+	}
+
+	var state OfflineState
+	var reason sql.NullString
+	var since sql.NullTime
+	err := o.db.QueryRow("SELECT enabled, reason, since FROM offline_mode WHERE id = 1").Scan(&state.Enabled, &reason, &since)
+	if err != nil && err != sql.ErrNoRows {
+		return o.state, fmt.Errorf("failed to load offline mode: %w", err)
+	}
+	state.Reason = reason.String
+	state.Since = since.Time
+	o.state = state
+	o.loaded = o.now()
+	return state, nil
+}
+
+// State returns the current state. If the database can't be read the last
+// known state is kept.
+func (o *OfflineSwitch) State() OfflineState {
+	state, _ := o.load()
+	return state
+}
+
+// Set turns offline mode on or off.
+func (o *OfflineSwitch) Set(state OfflineState) error {
+	if !state.Enabled {
+		state = OfflineState{}
+	} else if state.Since.IsZero() {
+		state.Since = o.now()
+	}
+
+	if o.db != nil {
+		_, err := o.db.Exec(`
+			INSERT INTO offline_mode (id, enabled, reason, since) VALUES (1, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET enabled = excluded.enabled, reason = excluded.reason, since = excluded.since
+		`, state.Enabled, state.Reason, state.Since)
+		if err != nil {
+			return fmt.Errorf("failed to save offline mode: %w", err)
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.state = state
+	o.loaded = o.now()
+	return nil
+}
+
+// ApplyEnv sets the state from ARMOUR_OFFLINE, if it is set.
+func (o *OfflineSwitch) ApplyEnv() error {
+	raw := os.Getenv(OfflineEnv)
+	if raw == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", OfflineEnv, raw, err)
+	}
+	if enabled == o.State().Enabled {
+		return nil
+	}
+	return o.Set(OfflineState{Enabled: enabled, Reason: OfflineEnv})
+}
+
+// Check returns why a tool call is blocked by offline mode, or nil if it
+// isn't. remote tells whether the tool's backend is an http or sse server.
+func (o *OfflineSwitch) Check(toolName string, remote bool, args map[string]interface{}) *BlockError {
+	if o == nil || !o.State().Enabled {
+		return nil
+	}
+	var reason string
+	if remote {
+		reason = "offline mode: the server is reached over the network"
+	} else if urls := networkURLs(args); len(urls) > 0 {
+		reason = "offline mode: the arguments contain URLs (" + strings.Join(urls, ", ") + ")"
+	} else {
+		return nil
+	}
+	return &BlockError{
+		BlockedBy: BlockedByOffline,
+		Action:    "block",
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    reason,
+		Appeal:    DashboardURL,
+	}
+}
+
+// networkURLs returns the URL arguments pointing at other hosts than this
+// machine, by field.
+func networkURLs(args map[string]interface{}) []string {
+	var urls []string
+	var walk func(field string, value interface{})
+	walk = func(field string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, k := range sortedKeys(v) {
+				walk(joinField(field, k), v[k])
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(fmt.Sprintf("%s[%d]", field, i), item)
+			}
+		case string:
+			u, err := url.Parse(strings.TrimSpace(v))
+			if err != nil || !urlSchemes[strings.ToLower(u.Scheme)] || u.Host == "" || isLoopbackHost(u.Hostname()) {
+				return
+			}
+			urls = append(urls, field+"="+u.Host)
+		}
+	}
+	walk("", args)
+	sort.Strings(urls)
+	return urls
+}
+
+// checkOffline blocks a tool call offline mode forbids, recording it.
+func (s *StdioServer) checkOffline(ctx context.Context, toolName, backendID string, args map[string]interface{}) *BlockError {
+	block := s.offline.Check(toolName, s.backendManager.IsRemote(backendID), args)
+	if block == nil {
+		return nil
+	}
+	s.statsTracker.RecordBlockedCall(toolName, "offline")
+	s.recordAudit(ctx, AuditEntry{
+		ServerID:       backendID,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		Blocked:        true,
+		BlockReason:    "offline",
+		MatchedPattern: block.Reason,
+		RuleAction:     "block",
+	})
+	return block
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestOfflineSwitch(t *testing.T) {
+	offline, err := NewOfflineSwitch(openChainTestDB(t))
+	if err != nil {
+		t.Fatalf("failed to create offline switch: %v", err)
+	}
+	fetch := map[string]interface{}{"url": "https://example.com/page"}
+	if block := offline.Check("fetch", true, fetch); block != nil {
+		t.Fatalf("expected no block while online, got %v", block)
+	}
+
+	if err := offline.Set(OfflineState{Enabled: true, Reason: "on a plane"}); err != nil {
+		t.Fatalf("failed to turn offline mode on: %v", err)
+	}
+	if state := offline.State(); !state.Enabled || state.Reason != "on a plane" || state.Since.IsZero() {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if block := offline.Check("search", true, nil); block == nil || block.BlockedBy != BlockedByOffline {
+		t.Errorf("expected a remote server blocked, got %v", block)
+	}
+	block := offline.Check("fetch", false, map[string]interface{}{"request": map[string]interface{}{"urls": []interface{}{"https://example.com/page"}}})
+	if block == nil || block.Reason != "offline mode: the arguments contain URLs (request.urls[0]=example.com)" {
+		t.Errorf("expected a URL argument blocked, got %v", block)
+	}
+	for _, args := range []map[string]interface{}{
+		{"path": "/tmp/notes.md"},
+		{"url": "http://localhost:8080/health"},
+		{"url": "http://127.0.0.1:3000"},
+	} {
+		if block := offline.Check("local", false, args); block != nil {
+			t.Errorf("expected %v allowed, got %v", args, block)
+		}
+	}
+
+	if err := offline.Set(OfflineState{}); err != nil {
+		t.Fatalf("failed to turn offline mode off: %v", err)
+	}
+	if block := offline.Check("fetch", true, fetch); block != nil || offline.State().Reason != "" {
+		t.Errorf("expected offline mode off, got %v", block)
+	}
+
+	t.Setenv(OfflineEnv, "1")
+	if err := offline.ApplyEnv(); err != nil || !offline.State().Enabled || offline.State().Reason != OfflineEnv {
+		t.Errorf("expected %s to turn offline mode on, got %+v (err=%v)", OfflineEnv, offline.State(), err)
+	}
+	t.Setenv(OfflineEnv, "maybe")
+	if err := offline.ApplyEnv(); err == nil {
+		t.Error("expected an invalid value to be rejected")
+	}
+}
+
+func TestOfflineBlocksHTTPRequests(t *testing.T) {
+	s, err := NewServer(Config{
+		Mode:     "http",
+		LogLevel: "error",
+		DBPath:   filepath.Join(t.TempDir(), "armour.db"),
+		Registry: &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "github", Transport: "http", URL: "http://127.0.0.1:1"}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer s.Close()
+
+	check := func(method, params string) *BlockError {
+		t.Helper()
+		block, err := s.CheckRequest("github", "s1", JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: []byte(params)})
+		if err != nil {
+			t.Fatalf("check failed: %v", err)
+		}
+		return block
+	}
+	call := `{"name":"list_repos","arguments":{}}`
+	if block := check("tools/call", call); block != nil {
+		t.Fatalf("expected the call allowed while online, got %+v", block)
+	}
+
+	if err := s.GetOfflineSwitch().Set(OfflineState{Enabled: true}); err != nil {
+		t.Fatalf("failed to turn offline mode on: %v", err)
+	}
+	for method, params := range map[string]string{
+		"tools/call":     call,
+		"resources/read": `{"uri":"repo://readme"}`,
+		"prompts/get":    `{"name":"review","arguments":{}}`,
+	} {
+		block := check(method, params)
+		if block == nil || block.BlockedBy != BlockedByOffline || block.Appeal != "" {
+			t.Errorf("expected %s blocked by offline mode without appeal link, got %+v", method, block)
+		}
+	}
+	if block := check("tools/list", `{}`); block != nil {
+		t.Errorf("expected listing allowed offline, got %+v", block)
+	}
+}
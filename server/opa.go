@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OPAPolicyChecker evaluates tool calls against Rego policies loaded into an
+// Open Policy Agent server, through its Data API: the PolicyCall is posted as
+// the input document and the decision is read from the result. The result is
+// either a boolean (true allows the call) or an object in PolicyVerdict form,
+// e.g. with opa run --server armour.rego:
+//
+//	package armour
+//
+//	default decision := {"decision": "allow"}
+//
+//	decision := {"decision": "block", "reason": "deletes need a change ticket", "rule": "no_deletes"} if {
+//		endswith(input.tool, ":delete_repo")
+//	}
+type OPAPolicyChecker struct {
+	url    string // e.g. http://127.0.0.1:8181/v1/data/armour/decision
+	client *http.Client
+}
+
+// NewOPAPolicyChecker creates a checker for the decision at a Data API URL.
+func NewOPAPolicyChecker(decisionURL string, timeout time.Duration) (*OPAPolicyChecker, error) {
+	u, err := url.Parse(decisionURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OPA decision URL %q: expected http(s)://host/v1/data/<path>", decisionURL)
+	}
+	return &OPAPolicyChecker{url: decisionURL, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (c *OPAPolicyChecker) Name() string {
+	return "opa"
+}
+
+func (c *OPAPolicyChecker) Check(ctx context.Context, call PolicyCall) (PolicyVerdict, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": call})
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return PolicyVerdict{}, fmt.Errorf("OPA returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var out struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return PolicyVerdict{}, fmt.Errorf("invalid OPA response: %w", err)
+	}
+	if len(out.Result) == 0 {
+		return PolicyVerdict{}, fmt.Errorf("decision is undefined; check the policy path in %s", c.url)
+	}
+	var allow bool
+	if err := json.Unmarshal(out.Result, &allow); err == nil {
+		if allow {
+			return PolicyVerdict{Decision: PolicyAllow}, nil
+		}
+		return PolicyVerdict{Decision: PolicyBlock, Reason: "denied by Rego policy"}, nil
+	}
+	var verdict PolicyVerdict
+	if err := json.Unmarshal(out.Result, &verdict); err != nil {
+		return PolicyVerdict{}, fmt.Errorf("decision must be a boolean or an object: %w", err)
+	}
+	return verdict, nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOPAPolicyChecker(t *testing.T) {
+	results := map[string]string{
+		"github:list_repos":  `{"result":true}`,
+		"github:fork_repo":   `{"result":false}`,
+		"github:delete_repo": `{"result":{"decision":"block","reason":"deletes need a change ticket","rule":"no_deletes"}}`,
+		"github:merge_pr":    `{"result":{"decision":"ask","reason":"merges need a second reviewer"}}`,
+		"github:undefined":   `{}`,
+	}
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/data/armour/decision" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var body struct {
+			Input PolicyCall `json:"input"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil || body.Input.Server != "github" {
+			http.Error(w, "bad input", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, results[body.Input.Tool])
+	}))
+	defer opa.Close()
+
+	c, err := NewOPAPolicyChecker(opa.URL+"/v1/data/armour/decision", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tool     string
+		decision string
+		rule     string
+	}{
+		{"github:list_repos", PolicyAllow, ""},
+		{"github:fork_repo", PolicyBlock, ""},
+		{"github:delete_repo", PolicyBlock, "no_deletes"},
+		{"github:merge_pr", PolicyAsk, ""},
+	}
+	for _, tt := range tests {
+		v, err := c.Check(context.Background(), PolicyCall{Server: "github", Tool: tt.tool, Transport: "http"})
+		if err != nil || v.Decision != tt.decision || v.Rule != tt.rule {
+			t.Errorf("%s: expected %s (rule %q), got %+v, %v", tt.tool, tt.decision, tt.rule, v, err)
+		}
+	}
+	if _, err := c.Check(context.Background(), PolicyCall{Server: "github", Tool: "github:undefined"}); err == nil || !strings.Contains(err.Error(), "undefined") {
+		t.Errorf("expected an undefined decision to fail, got %v", err)
+	}
+
+	wrongPath, _ := NewOPAPolicyChecker(opa.URL+"/v1/data/nope", time.Second)
+	if _, err := wrongPath.Check(context.Background(), PolicyCall{Server: "github", Tool: "github:list_repos"}); err == nil {
+		t.Error("expected an error for a 404 from OPA")
+	}
+
+	for _, bad := range []string{"", "localhost:8181", "ftp://opa/v1/data/x"} {
+		if _, err := NewOPAPolicyChecker(bad, time.Second); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestPluginMatch(t *testing.T) {
+	verdict := PolicyVerdict{Decision: PolicyBlock, Reason: "deletes need a change ticket", Rule: "no_deletes"}
+	block := pluginBlock("opa", "github:delete_repo", verdict)
+	if block.Reason != "opa: deletes need a change ticket (rule no_deletes)" {
+		t.Errorf("unexpected reason %q", block.Reason)
+	}
+	if got := pluginMatch("opa", verdict, block); got != "opa:no_deletes" {
+		t.Errorf("expected the rule ID in the audit log, got %q", got)
+	}
+	verdict.Rule = ""
+	block = pluginBlock("opa", "github:delete_repo", verdict)
+	if got := pluginMatch("opa", verdict, block); got != block.Reason {
+		t.Errorf("expected the reason without a rule ID, got %q", got)
+	}
+}
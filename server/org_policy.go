@@ -0,0 +1,310 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultOrgPolicyInterval is how often the organization bundle is refetched.
+	defaultOrgPolicyInterval = 15 * time.Minute
+	// orgPolicyMaxBytes caps the size of a downloaded bundle.
+	orgPolicyMaxBytes = 4 << 20
+)
+
+// OrgPolicyBundle is the signed envelope served at the organization policy URL.
+// Signature is an ed25519 signature over the raw (base64-decoded) payload bytes.
+type OrgPolicyBundle struct {
+	Payload   string `json:"payload"`   // base64-encoded OrgPolicyPayload JSON
+	Signature string `json:"signature"` // base64-encoded ed25519 signature
+}
+
+// OrgPolicyPayload is the signed content of an organization bundle.
+type OrgPolicyPayload struct {
+	Version  string          `json:"version"`
+	IssuedAt time.Time       `json:"issued_at"`
+	Rules    []communityRule `json:"rules"`
+}
+
+// OrgPolicyStatus reports the state of the organization policy layer.
+type OrgPolicyStatus struct {
+	Enabled     bool      `json:"enabled"`
+	URL         string    `json:"url,omitempty"`
+	Version     string    `json:"version,omitempty"`
+	RuleCount   int       `json:"rule_count"`
+	LastSync    time.Time `json:"last_sync,omitempty"`
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// OrgPolicySync periodically fetches a signed organization rule bundle and
+// exposes it as a read-only rule layer evaluated after local rules.
+type OrgPolicySync struct {
+	url       string
+	publicKey ed25519.PublicKey
+	interval  time.Duration
+	client    *http.Client
+	logger    Logger
+
+	// statePath keeps the last applied bundle across restarts
+	statePath string
+
+	mu          sync.RWMutex
+	rules       []BlocklistRule
+	status      OrgPolicyStatus
+	applied     orgPolicyState
+	stateLoaded bool
+}
+
+// orgPolicyState records the last bundle applied from a URL, so neither a
+// running nor a restarted proxy goes back to an older one.
+type orgPolicyState struct {
+	URL      string    `json:"url"`
+	Version  string    `json:"version"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+func orgPolicyStatePath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".armour", "org-policy.json")
+}
+
+// NewOrgPolicySync creates a syncer for the bundle at policyURL, verified with
+// the base64-encoded ed25519 public key.
+func NewOrgPolicySync(policyURL, publicKey string, interval time.Duration, logger Logger) (*OrgPolicySync, error) {
+	if logger == nil {
+		logger = &noOpLogger{}
+	}
+
+	u, err := url.Parse(policyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid org policy URL: %w", err)
+	}
+	if u.Scheme != "https" && !isLoopbackHost(u.Hostname()) {
+		return nil, fmt.Errorf("org policy URL must use https: %s", policyURL)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("org policy key must be a base64 ed25519 public key")
+	}
+
+	if interval <= 0 {
+		interval = defaultOrgPolicyInterval
+	}
+
+	return &OrgPolicySync{
+		url:       policyURL,
+		publicKey: ed25519.PublicKey(key),
+		interval:  interval,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		logger:    logger,
+		statePath: orgPolicyStatePath(),
+		status:    OrgPolicyStatus{Enabled: true, URL: policyURL},
+	}, nil
+}
+
+// NewOrgPolicySyncFromEnv configures org policy sync from ARMOUR_ORG_POLICY_URL,
+// ARMOUR_ORG_POLICY_KEY and ARMOUR_ORG_POLICY_INTERVAL. It returns nil when no URL is set.
+func NewOrgPolicySyncFromEnv(logger Logger) (*OrgPolicySync, error) {
+	policyURL := os.Getenv("ARMOUR_ORG_POLICY_URL")
+	if policyURL == "" {
+		return nil, nil
+	}
+
+	var interval time.Duration
+	if raw := os.Getenv("ARMOUR_ORG_POLICY_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ARMOUR_ORG_POLICY_INTERVAL: %w", err)
+		}
+		interval = d
+	}
+
+	return NewOrgPolicySync(policyURL, os.Getenv("ARMOUR_ORG_POLICY_KEY"), interval, logger)
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Start performs an initial sync and then refreshes on the configured interval until ctx is done.
+func (o *OrgPolicySync) Start(ctx context.Context) {
+	go func() {
+		if err := o.Sync(ctx); err != nil {
+			o.logger.Warn("org policy sync failed: %v", err)
+		}
+
+		ticker := time.NewTicker(o.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := o.Sync(ctx); err != nil {
+					o.logger.Warn("org policy sync failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Sync fetches and verifies the bundle once. On failure, or when the bundle
+// was issued before the one last applied, the previous rules are kept.
+func (o *OrgPolicySync) Sync(ctx context.Context) error {
+	payload, err := o.fetch(ctx)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.status.LastAttempt = time.Now()
+	if err == nil {
+		err = o.checkNewer(payload)
+	}
+	if err != nil {
+		o.status.LastError = err.Error()
+		return err
+	}
+
+	o.rules = orgRulesFromPayload(payload)
+	o.status.LastError = ""
+	o.status.LastSync = o.status.LastAttempt
+	o.status.Version = payload.Version
+	o.status.RuleCount = len(o.rules)
+	o.saveApplied(orgPolicyState{URL: o.url, Version: payload.Version, IssuedAt: payload.IssuedAt})
+	o.logger.Info("org policy %s synced: %d rule(s)", payload.Version, len(o.rules))
+	return nil
+}
+
+// checkNewer rejects a bundle issued before the last one applied, or at the
+// same time under another version, so replaying an old signed bundle can't
+// roll the rules back. Callers hold o.mu.
+func (o *OrgPolicySync) checkNewer(payload *OrgPolicyPayload) error {
+	if !o.stateLoaded {
+		o.stateLoaded = true
+		if data, err := os.ReadFile(o.statePath); err == nil {
+			json.Unmarshal(data, &o.applied)
+		}
+	}
+	last := o.applied
+	if last.URL != o.url {
+		return nil
+	}
+	if payload.IssuedAt.Before(last.IssuedAt) || (payload.IssuedAt.Equal(last.IssuedAt) && payload.Version != last.Version) {
+		return fmt.Errorf("bundle %s (issued %s) is not newer than the applied %s (issued %s)",
+			payload.Version, payload.IssuedAt.Format(time.RFC3339), last.Version, last.IssuedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// saveApplied records the bundle just applied. Callers hold o.mu.
+func (o *OrgPolicySync) saveApplied(state orgPolicyState) {
+	o.applied = state
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(o.statePath), 0755); err == nil {
+		err = os.WriteFile(o.statePath, data, 0644)
+	}
+	if err != nil {
+		o.logger.Warn("failed to record the applied org policy: %v", err)
+	}
+}
+
+func (o *OrgPolicySync) fetch(ctx context.Context) (*OrgPolicyPayload, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bundle: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bundle fetch returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, orgPolicyMaxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	return VerifyOrgPolicyBundle(data, o.publicKey)
+}
+
+// VerifyOrgPolicyBundle checks the bundle signature and decodes its payload.
+func VerifyOrgPolicyBundle(data []byte, publicKey ed25519.PublicKey) (*OrgPolicyPayload, error) {
+	var bundle OrgPolicyBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle JSON: %w", err)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(bundle.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle payload encoding: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(bundle.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, payload, sig) {
+		return nil, fmt.Errorf("bundle signature verification failed")
+	}
+
+	var parsed OrgPolicyPayload
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid bundle payload: %w", err)
+	}
+	return &parsed, nil
+}
+
+func orgRulesFromPayload(payload *OrgPolicyPayload) []BlocklistRule {
+	rules := make([]BlocklistRule, 0, len(payload.Rules))
+	for _, cr := range payload.Rules {
+		rule := cr.toBlocklistRule("organization rule")
+		rule.Description = "[org] " + rule.Description
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Rules returns the current organization rules. They are never written to the
+// local database, so they cannot be edited from the dashboard.
+func (o *OrgPolicySync) Rules() []BlocklistRule {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	out := make([]BlocklistRule, len(o.rules))
+	copy(out, o.rules)
+	return out
+}
+
+// Status returns a snapshot of the sync state.
+func (o *OrgPolicySync) Status() OrgPolicyStatus {
+	if o == nil {
+		return OrgPolicyStatus{}
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.status
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func signOrgBundle(t *testing.T, priv ed25519.PrivateKey, payload OrgPolicyPayload) []byte {
+	t.Helper()
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Failed to marshal payload: %v", err)
+	}
+	bundle, _ := json.Marshal(OrgPolicyBundle{
+		Payload:   base64.StdEncoding.EncodeToString(raw),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, raw)),
+	})
+	return bundle
+}
+
+// TestOrgPolicySync tests signed bundle verification and the org rule layer
+func TestOrgPolicySync(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	body := signOrgBundle(t, priv, OrgPolicyPayload{
+		Version: "2024.1",
+		Rules: []communityRule{
+			{Pattern: "DROP TABLE", Description: "no destructive SQL", IsRegex: true},
+		},
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	org, err := NewOrgPolicySync(srv.URL, base64.StdEncoding.EncodeToString(pub), 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to create org policy sync: %v", err)
+	}
+	org.statePath = filepath.Join(t.TempDir(), "org-policy.json")
+	if err := org.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	status := org.Status()
+	if status.Version != "2024.1" || status.RuleCount != 1 || status.LastSync.IsZero() {
+		t.Errorf("Unexpected status after sync: %+v", status)
+	}
+
+	db, err := sql.Open("sqlite", "file:memdb_orgpolicy?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	bm := NewBlocklistMiddleware(db, "", nil, nil, nil)
+	bm.communityRules = nil
+	bm.SetOrgPolicy(org)
+
+	result, _ := bm.Check("tools/call", "db:query", map[string]interface{}{"query": "DROP TABLE users"})
+	if result.Allowed {
+		t.Error("Expected org rule to block DROP TABLE")
+	}
+	result, _ = bm.Check("tools/call", "db:query", map[string]interface{}{"query": "SELECT 1"})
+	if !result.Allowed {
+		t.Error("Expected SELECT to be allowed")
+	}
+
+	// A bundle signed with a different key must be rejected, keeping the old rules
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	body = signOrgBundle(t, otherPriv, OrgPolicyPayload{Version: "evil"})
+	if err := org.Sync(context.Background()); err == nil {
+		t.Fatal("Expected signature verification to fail")
+	}
+	if status := org.Status(); status.Version != "2024.1" || status.LastError == "" || len(org.Rules()) != 1 {
+		t.Errorf("Expected previous rules to be kept after failed sync, got %+v", status)
+	}
+}
+
+// TestOrgPolicyRejectsRollback tests that a validly signed bundle issued
+// before the applied one is refused, also after a restart
+func TestOrgPolicyRejectsRollback(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	key := base64.StdEncoding.EncodeToString(pub)
+	issued := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	bundle := func(version string, issuedAt time.Time, pattern string) []byte {
+		return signOrgBundle(t, priv, OrgPolicyPayload{Version: version, IssuedAt: issuedAt, Rules: []communityRule{{Pattern: pattern}}})
+	}
+
+	body := bundle("2026.2", issued, "rm -rf")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+	statePath := filepath.Join(t.TempDir(), "org-policy.json")
+	newSync := func() *OrgPolicySync {
+		org, err := NewOrgPolicySync(srv.URL, key, 0, nil)
+		if err != nil {
+			t.Fatalf("Failed to create org policy sync: %v", err)
+		}
+		org.statePath = statePath
+		return org
+	}
+
+	org := newSync()
+	if err := org.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// The previous bundle, still validly signed, is replayed
+	body = bundle("2026.1", issued.Add(-24*time.Hour), "nothing")
+	if err := org.Sync(context.Background()); err == nil {
+		t.Error("Expected an older bundle to be rejected")
+	}
+	if status := org.Status(); status.Version != "2026.2" || org.Rules()[0].Pattern != "rm -rf" {
+		t.Errorf("Expected the applied bundle kept, got %+v", status)
+	}
+	body = bundle("2026.1b", issued, "nothing")
+	if err := org.Sync(context.Background()); err == nil {
+		t.Error("Expected another version issued at the same time to be rejected")
+	}
+
+	// A restarted proxy remembers the applied bundle
+	body = bundle("2026.1", issued.Add(-24*time.Hour), "nothing")
+	if err := newSync().Sync(context.Background()); err == nil {
+		t.Error("Expected an older bundle to be rejected after a restart")
+	}
+	body = bundle("2026.2", issued, "rm -rf")
+	if err := newSync().Sync(context.Background()); err != nil {
+		t.Errorf("Expected the applied bundle accepted again, got %v", err)
+	}
+	body = bundle("2026.3", issued.Add(time.Hour), "git push --force")
+	if err := newSync().Sync(context.Background()); err != nil {
+		t.Errorf("Expected a newer bundle accepted, got %v", err)
+	}
+}
+
+// TestOrgPolicyRequiresHTTPS tests that remote bundles must be fetched over TLS
+func TestOrgPolicyRequiresHTTPS(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	if _, err := NewOrgPolicySync("http://policies.example.com/bundle.json", key, 0, nil); err == nil {
+		t.Error("Expected plain http URL to be rejected")
+	}
+	if _, err := NewOrgPolicySync("https://policies.example.com/bundle.json", "not-a-key", 0, nil); err == nil {
+		t.Error("Expected invalid key to be rejected")
+	}
+}
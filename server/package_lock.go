@@ -0,0 +1,560 @@
+package server
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// settingLockPackages persists servers.lock_packages in the rules store.
+const settingLockPackages = "lock_packages"
+
+// packageResolveTimeout bounds a lookup in a package registry.
+const packageResolveTimeout = 10 * time.Second
+
+// packageDownloadTimeout bounds the download of a package's artifact.
+const packageDownloadTimeout = 2 * time.Minute
+
+// maxPackageSize caps the size of a downloaded package artifact.
+const maxPackageSize = 256 << 20
+
+// ErrPackageChanged is returned when the registry reports a different
+// artifact for a locked package version than when it was locked.
+var ErrPackageChanged = errors.New("package changed in the registry since it was locked")
+
+// Launchers whose packages are locked.
+const (
+	LauncherNPX = "npx"
+	LauncherUVX = "uvx"
+)
+
+// PackageLock is the package version a server launched through npx or uvx
+// is locked to, with the integrity the registry reported for it.
+type PackageLock struct {
+	Server     string    `json:"server"`
+	Launcher   string    `json:"launcher"`
+	Package    string    `json:"package"`
+	Version    string    `json:"version"`
+	Integrity  string    `json:"integrity"`
+	LockedAt   time.Time `json:"locked_at"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// PackageLocker locks servers launched with `npx -y package` or `uvx
+// package`, which fetch code at run time, to an exact package version. The
+// first launch resolves the version in the registry and records it with its
+// integrity hash; later launches are blocked if the registry now reports a
+// different artifact for it. Every launch downloads the artifact itself (the
+// npm tarball, or the PyPI universal wheel or source distribution), checks
+// it against the locked hash and has npx or uvx run that file, so what runs
+// is what was verified. The package's dependencies are still resolved by
+// npx or uvx.
+type PackageLocker struct {
+	db          *sql.DB
+	client      *http.Client
+	dir         string // verified artifacts, by hash
+	npmRegistry string
+	pypiIndex   string
+}
+
+// NewPackageLocker creates a package locker backed by db.
+func NewPackageLocker(db *sql.DB) (*PackageLocker, error) {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS package_locks (
+			server TEXT PRIMARY KEY,
+			launcher TEXT NOT NULL,
+			package TEXT NOT NULL,
+			version TEXT NOT NULL,
+			integrity TEXT NOT NULL,
+			locked_at TIMESTAMP NOT NULL,
+			verified_at TIMESTAMP NOT NULL
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create package_locks table: %w", err)
+	}
+	homeDir, _ := os.UserHomeDir()
+	return &PackageLocker{
+		db:          db,
+		client:      &http.Client{},
+		dir:         filepath.Join(homeDir, ".armour", "packages"),
+		npmRegistry: "https://registry.npmjs.org",
+		pypiIndex:   "https://pypi.org/pypi",
+	}, nil
+}
+
+// List returns the package locks, by server.
+func (pl *PackageLocker) List() ([]PackageLock, error) {
+	return pl.list("")
+}
+
+// Unlock forgets the lock of a server, so its next launch locks whatever
+// version the registry resolves then. Use it after an expected upgrade.
+func (pl *PackageLocker) Unlock(server string) error {
+	res, err := pl.db.Exec("DELETE FROM package_locks WHERE server = ?", server)
+	if err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", server, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no package lock for %s", server)
+	}
+	return nil
+}
+
+func (pl *PackageLocker) list(where string, args ...interface{}) ([]PackageLock, error) {
+	rows, err := pl.db.Query(`
+		SELECT server, launcher, package, version, integrity, locked_at, verified_at
+		FROM package_locks `+where+` ORDER BY server`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list package locks: %w", err)
+	}
+	defer rows.Close()
+
+	locks := []PackageLock{}
+	for rows.Next() {
+		var l PackageLock
+		if err := rows.Scan(&l.Server, &l.Launcher, &l.Package, &l.Version, &l.Integrity, &l.LockedAt, &l.VerifiedAt); err != nil {
+			return nil, err
+		}
+		locks = append(locks, l)
+	}
+	return locks, rows.Err()
+}
+
+// Lock resolves the package a server entry launches, checks it against the
+// server's lock (recording one on first launch), downloads and verifies its
+// artifact and rewrites the entry's arguments to run that artifact. Entries
+// that don't launch a package through npx or uvx are left alone.
+func (pl *PackageLocker) Lock(ctx context.Context, entry *proxy.ServerEntry) (*PackageLock, error) {
+	spec, ok := parseLauncherPackage(*entry)
+	if !ok {
+		return nil, nil
+	}
+	locks, err := pl.list("WHERE server = ?", entry.Name)
+	if err != nil {
+		return nil, err
+	}
+	var lock *PackageLock
+	if len(locks) == 1 && locks[0].Launcher == spec.launcher && locks[0].Package == spec.name &&
+		(spec.version == "" || spec.version == locks[0].Version) {
+		lock = &locks[0]
+	}
+
+	version := spec.version
+	if lock != nil {
+		version = lock.Version
+	}
+	release, err := pl.resolve(ctx, spec.launcher, spec.name, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s %s: %w", spec.launcher, spec.name, err)
+	}
+	resolved, integrity := release.version, release.integrity
+	if lock != nil && integrity != lock.Integrity {
+		return nil, fmt.Errorf("%w: %s@%s of %s was %s, now %s; unlock it if the change is expected",
+			ErrPackageChanged, lock.Package, lock.Version, entry.Name, lock.Integrity, integrity)
+	}
+	artifact, err := pl.download(ctx, release.artifact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s %s: %w", spec.launcher, spec.name, err)
+	}
+
+	now := time.Now()
+	if lock != nil {
+		lock.VerifiedAt = now
+		if _, err := pl.db.Exec("UPDATE package_locks SET verified_at = ? WHERE server = ?", now, entry.Name); err != nil {
+			return nil, fmt.Errorf("failed to record package verification: %w", err)
+		}
+	} else {
+		lock = &PackageLock{Server: entry.Name, Launcher: spec.launcher, Package: spec.name, Version: resolved,
+			Integrity: integrity, LockedAt: now, VerifiedAt: now}
+		_, err := pl.db.Exec(`
+			INSERT INTO package_locks (server, launcher, package, version, integrity, locked_at, verified_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(server) DO UPDATE SET launcher = excluded.launcher, package = excluded.package, version = excluded.version,
+				integrity = excluded.integrity, locked_at = excluded.locked_at, verified_at = excluded.verified_at
+		`, lock.Server, lock.Launcher, lock.Package, lock.Version, lock.Integrity, lock.LockedAt, lock.VerifiedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record package lock: %w", err)
+		}
+	}
+
+	entry.Args = spec.withArtifact(entry.Args, artifact)
+	return lock, nil
+}
+
+// launcherPackage is the package argument of an npx or uvx command line.
+type launcherPackage struct {
+	launcher string
+	arg      int // index of the package in the arguments
+	name     string
+	version  string // as requested; empty for the latest
+	from     bool   // uvx --from, which takes a requirement
+}
+
+// withArtifact returns args with the package replaced by the verified
+// artifact at path. uvx runs a command named after the package unless
+// --from says where it comes from, so the command is kept.
+func (p launcherPackage) withArtifact(args []string, path string) []string {
+	if p.launcher == LauncherUVX && !p.from {
+		rewritten := append([]string{}, args[:p.arg]...)
+		rewritten = append(rewritten, "--from", path, p.name)
+		return append(rewritten, args[p.arg+1:]...)
+	}
+	rewritten := append([]string{}, args...)
+	rewritten[p.arg] = path
+	return rewritten
+}
+
+// parseLauncherPackage finds the package an npx or uvx server entry runs.
+func parseLauncherPackage(entry proxy.ServerEntry) (launcherPackage, bool) {
+	launcher := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(entry.Command), ".cmd"), ".exe")
+	if entry.Transport != "stdio" || (launcher != LauncherNPX && launcher != LauncherUVX) {
+		return launcherPackage{}, false
+	}
+	// Flags taking a value, other than the one naming the package
+	valueFlags := map[string]bool{"--with": true, "--python": true, "-p": launcher == LauncherUVX,
+		"--index": true, "--index-url": true, "--registry": true, "--cache": true}
+	packageFlags := map[string]bool{"--from": launcher == LauncherUVX, "--package": launcher == LauncherNPX, "-p": launcher == LauncherNPX}
+
+	for i := 0; i < len(entry.Args); i++ {
+		arg := entry.Args[i]
+		if arg == "--" {
+			continue
+		}
+		if flag, _, ok := strings.Cut(arg, "="); ok && packageFlags[flag] {
+			return launcherPackage{}, false // not rewritten, to keep the flag's shape
+		}
+		if packageFlags[arg] && i+1 < len(entry.Args) {
+			p := splitPackageSpec(launcher, entry.Args[i+1])
+			p.arg, p.from = i+1, launcher == LauncherUVX
+			return p, p.name != ""
+		}
+		if valueFlags[arg] {
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		p := splitPackageSpec(launcher, arg)
+		p.arg = i
+		return p, p.name != ""
+	}
+	return launcherPackage{}, false
+}
+
+// splitPackageSpec splits `@scope/name@1.2.3`, `name==1.2.3` or `name@1.2.3`
+// into the package name and version. Local paths and URLs aren't packages.
+func splitPackageSpec(launcher, spec string) launcherPackage {
+	p := launcherPackage{launcher: launcher}
+	if spec == "" || strings.ContainsAny(spec, "/\\:") && !strings.HasPrefix(spec, "@") || strings.HasPrefix(spec, ".") {
+		return p
+	}
+	if launcher == LauncherUVX {
+		if name, version, ok := strings.Cut(spec, "=="); ok {
+			p.name, p.version = name, version
+			return p
+		}
+	}
+	if at := strings.LastIndex(spec, "@"); at > 0 {
+		p.name, p.version = spec[:at], spec[at+1:]
+	} else {
+		p.name = spec
+	}
+	if p.version != "" && (p.version[0] < '0' || p.version[0] > '9' || strings.ContainsAny(p.version, "<>=!~^*, |")) {
+		p.version = "" // a tag or a range, resolved like no version
+	}
+	return p
+}
+
+// packageRelease is a package version as its registry reports it.
+type packageRelease struct {
+	version   string
+	integrity string // what the lock records
+	artifact  packageArtifact
+}
+
+// packageArtifact is the file npx or uvx would download for a release.
+type packageArtifact struct {
+	url       string
+	filename  string
+	integrity string // of this file, as algorithm-digest
+}
+
+// resolve looks up the exact version, the integrity and the artifact of a
+// package in its registry; an empty version resolves the latest.
+func (pl *PackageLocker) resolve(ctx context.Context, launcher, name, version string) (packageRelease, error) {
+	if launcher == LauncherNPX {
+		if version == "" {
+			version = "latest"
+		}
+		var manifest struct {
+			Version string `json:"version"`
+			Dist    struct {
+				Integrity string `json:"integrity"`
+				Shasum    string `json:"shasum"`
+				Tarball   string `json:"tarball"`
+			} `json:"dist"`
+		}
+		if err := pl.getJSON(ctx, pl.npmRegistry+"/"+url.PathEscape(name)+"/"+url.PathEscape(version), &manifest); err != nil {
+			return packageRelease{}, err
+		}
+		integrity := manifest.Dist.Integrity
+		if integrity == "" && manifest.Dist.Shasum != "" {
+			integrity = "sha1-" + manifest.Dist.Shasum
+		}
+		if manifest.Version == "" || integrity == "" || manifest.Dist.Tarball == "" {
+			return packageRelease{}, fmt.Errorf("registry reported no version, integrity or tarball")
+		}
+		return packageRelease{
+			version:   manifest.Version,
+			integrity: integrity,
+			artifact:  packageArtifact{url: manifest.Dist.Tarball, filename: artifactFilename(manifest.Dist.Tarball), integrity: integrity},
+		}, nil
+	}
+
+	var release struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		URLs []struct {
+			Filename    string `json:"filename"`
+			URL         string `json:"url"`
+			PackageType string `json:"packagetype"`
+			Digests     struct {
+				SHA256 string `json:"sha256"`
+			} `json:"digests"`
+		} `json:"urls"`
+	}
+	path := pl.pypiIndex + "/" + url.PathEscape(name)
+	if version != "" {
+		path += "/" + url.PathEscape(version)
+	}
+	if err := pl.getJSON(ctx, path+"/json", &release); err != nil {
+		return packageRelease{}, err
+	}
+	var digests []string
+	var wheel, sdist packageArtifact
+	for _, u := range release.URLs {
+		if u.Digests.SHA256 == "" {
+			continue
+		}
+		digests = append(digests, u.Digests.SHA256)
+		file := packageArtifact{url: u.URL, filename: u.Filename, integrity: "sha256-" + u.Digests.SHA256}
+		switch {
+		case wheel.url == "" && strings.HasSuffix(u.Filename, "-none-any.whl"):
+			wheel = file
+		case sdist.url == "" && u.PackageType == "sdist":
+			sdist = file
+		}
+	}
+	if release.Info.Version == "" || len(digests) == 0 {
+		return packageRelease{}, fmt.Errorf("index reported no version or files")
+	}
+	sort.Strings(digests)
+	// A platform wheel may not be the one uvx picks, so only universal
+	// wheels and source distributions are run from a verified file
+	artifact := wheel
+	if artifact.url == "" {
+		artifact = sdist
+	}
+	if artifact.url == "" {
+		return packageRelease{}, fmt.Errorf("index reported no universal wheel or source distribution")
+	}
+	return packageRelease{version: release.Info.Version, integrity: "sha256-" + strings.Join(digests, ","), artifact: artifact}, nil
+}
+
+// download fetches an artifact, or finds it among those already verified,
+// and returns its path once its hash matches. A mismatch is reported as
+// ErrPackageChanged.
+func (pl *PackageLocker) download(ctx context.Context, artifact packageArtifact) (string, error) {
+	if artifact.filename == "" || artifact.filename == "." || strings.ContainsAny(artifact.filename, "/\\") {
+		return "", fmt.Errorf("invalid artifact name %q", artifact.filename)
+	}
+	key := sha256.Sum256([]byte(artifact.integrity))
+	dir := filepath.Join(pl.dir, hex.EncodeToString(key[:8]))
+	dest := filepath.Join(dir, artifact.filename)
+	if data, err := os.ReadFile(dest); err == nil && matchesIntegrity(data, artifact.integrity) {
+		return dest, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, packageDownloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, artifact.url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := pl.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", artifact.url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPackageSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxPackageSize {
+		return "", fmt.Errorf("%s is larger than %d bytes", artifact.url, maxPackageSize)
+	}
+	if !matchesIntegrity(data, artifact.integrity) {
+		return "", fmt.Errorf("%w: %s doesn't match %s", ErrPackageChanged, artifact.url, artifact.integrity)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to store package: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to store package: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to store package: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to store package: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", fmt.Errorf("failed to store package: %w", err)
+	}
+	return dest, nil
+}
+
+// artifactFilename is the file name at the end of an artifact URL.
+func artifactFilename(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return path.Base(u.Path)
+	}
+	return ""
+}
+
+// matchesIntegrity reports whether data has one of the digests in
+// integrity, a space-separated list of algorithm-digest pairs like npm's
+// (sha512-<base64>) or the hex digests of PyPI and npm's shasum.
+func matchesIntegrity(data []byte, integrity string) bool {
+	for _, field := range strings.Fields(integrity) {
+		alg, want, ok := strings.Cut(field, "-")
+		if !ok {
+			continue
+		}
+		var h hash.Hash
+		switch alg {
+		case "sha512":
+			h = sha512.New()
+		case "sha384":
+			h = sha512.New384()
+		case "sha256":
+			h = sha256.New()
+		case "sha1":
+			h = sha1.New()
+		default:
+			continue
+		}
+		h.Write(data)
+		sum := h.Sum(nil)
+		if want == base64.StdEncoding.EncodeToString(sum) || strings.EqualFold(want, hex.EncodeToString(sum)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pl *PackageLocker) getJSON(ctx context.Context, u string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, packageResolveTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := pl.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// SetPackageLocker locks npx and uvx packages of servers started
+// afterwards. With nil, they run whatever the registry resolves.
+func (bm *BackendManager) SetPackageLocker(locker *PackageLocker) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.packages = locker
+}
+
+// PackageLocks returns the package locks, or none if packages aren't locked.
+func (bm *BackendManager) PackageLocks() ([]PackageLock, error) {
+	bm.mu.RLock()
+	locker := bm.packages
+	bm.mu.RUnlock()
+	if locker == nil {
+		return []PackageLock{}, nil
+	}
+	return locker.List()
+}
+
+// UnlockPackage forgets the package lock of a server.
+func (bm *BackendManager) UnlockPackage(server string) error {
+	bm.mu.RLock()
+	locker := bm.packages
+	bm.mu.RUnlock()
+	if locker == nil {
+		return fmt.Errorf("packages are not locked")
+	}
+	return locker.Unlock(server)
+}
+
+// lockPackage pins the npx or uvx package a backend about to start runs.
+// A package that changed since it was locked blocks the start; a registry
+// that can't be reached doesn't, but leaves the package unverified.
+func (bm *BackendManager) lockPackage(ctx context.Context, entry *proxy.ServerEntry) error {
+	bm.mu.RLock()
+	locker := bm.packages
+	bm.mu.RUnlock()
+	if locker == nil {
+		return nil
+	}
+	before := strings.Join(entry.Args, " ")
+	lock, err := locker.Lock(ctx, entry)
+	switch {
+	case errors.Is(err, ErrPackageChanged):
+		return err
+	case err != nil:
+		bm.logger.Warn("running %s unverified: %v", entry.Name, err)
+	case lock != nil && strings.Join(entry.Args, " ") != before:
+		bm.logger.Info("running %s with %s locked to %s", entry.Name, lock.Package, lock.Version)
+	}
+	return nil
+}
+
+func encodeLockPackages(on bool) string {
+	if on {
+		return "true"
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestParseLauncherPackage(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		args    []string
+		arg     int
+		name    string
+		version string
+		run     []string // the arguments running the artifact /pkg
+	}{
+		{"npx", []string{"-y", "@modelcontextprotocol/server-filesystem", "/tmp"}, 1, "@modelcontextprotocol/server-filesystem", "", []string{"-y", "/pkg", "/tmp"}},
+		{"/usr/bin/npx", []string{"--yes", "notes-mcp@2.1.0"}, 1, "notes-mcp", "2.1.0", []string{"--yes", "/pkg"}},
+		{"npx", []string{"-y", "--package", "notes-mcp@latest", "notes"}, 2, "notes-mcp", "", []string{"-y", "--package", "/pkg", "notes"}},
+		{"uvx", []string{"--python", "3.12", "mcp-server-git", "--repository", "."}, 2, "mcp-server-git", "", []string{"--python", "3.12", "--from", "/pkg", "mcp-server-git", "--repository", "."}},
+		{"uvx", []string{"--from", "mcp-server-git==0.6.0", "mcp-server-git"}, 1, "mcp-server-git", "0.6.0", []string{"--from", "/pkg", "mcp-server-git"}},
+	} {
+		p, ok := parseLauncherPackage(proxy.ServerEntry{Transport: "stdio", Command: tc.command, Args: tc.args})
+		if !ok || p.arg != tc.arg || p.name != tc.name || p.version != tc.version || !reflect.DeepEqual(p.withArtifact(tc.args, "/pkg"), tc.run) {
+			t.Errorf("%s %v: got %+v (ok=%v)", tc.command, tc.args, p, ok)
+		}
+	}
+
+	for _, entry := range []proxy.ServerEntry{
+		{Transport: "stdio", Command: "node", Args: []string{"server.js"}},
+		{Transport: "stdio", Command: "npx", Args: []string{"-y", "./local-server"}},
+		{Transport: "stdio", Command: "uvx", Args: []string{"--from", "git+https://github.com/acme/mcp", "mcp"}},
+		{Transport: "http", Command: "npx", URL: "http://127.0.0.1:1/mcp"},
+	} {
+		if p, ok := parseLauncherPackage(entry); ok {
+			t.Errorf("%s %v: expected no package, got %+v", entry.Command, entry.Args, p)
+		}
+	}
+}
+
+// sri returns the npm integrity of data.
+func sri(data []byte) string {
+	sum := sha512.Sum512(data)
+	return "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestPackageLocker(t *testing.T) {
+	tarball := []byte("notes-mcp 1.4.2")
+	integrity := sri(tarball)
+	wheel := []byte("mcp_server_git 0.6.2")
+	wheelSum := sha256.Sum256(wheel)
+	wheelDigest := hex.EncodeToString(wheelSum[:])
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/npm/notes-mcp/latest", "/npm/notes-mcp/1.4.2":
+			w.Write([]byte(`{"version":"1.4.2","dist":{"integrity":"` + integrity + `","tarball":"` + registry.URL + `/npm/notes-mcp/-/notes-mcp-1.4.2.tgz"}}`))
+		case "/npm/notes-mcp/-/notes-mcp-1.4.2.tgz":
+			w.Write(tarball)
+		case "/pypi/mcp-server-git/json", "/pypi/mcp-server-git/0.6.2/json":
+			w.Write([]byte(`{"info":{"version":"0.6.2"},"urls":[
+				{"filename":"mcp_server_git-0.6.2-cp312-cp312-manylinux_2_17_x86_64.whl","url":"` + registry.URL + `/files/platform.whl","packagetype":"bdist_wheel","digests":{"sha256":"ff"}},
+				{"filename":"mcp_server_git-0.6.2-py3-none-any.whl","url":"` + registry.URL + `/files/mcp_server_git-0.6.2-py3-none-any.whl","packagetype":"bdist_wheel","digests":{"sha256":"` + wheelDigest + `"}}]}`))
+		case "/files/mcp_server_git-0.6.2-py3-none-any.whl":
+			w.Write(wheel)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+
+	locker, err := NewPackageLocker(openChainTestDB(t))
+	if err != nil {
+		t.Fatalf("failed to create locker: %v", err)
+	}
+	locker.npmRegistry, locker.pypiIndex, locker.dir = registry.URL+"/npm", registry.URL+"/pypi", t.TempDir()
+	ctx := context.Background()
+
+	// The first start locks the latest version and runs its verified tarball
+	entry := proxy.ServerEntry{Name: "notes", Transport: "stdio", Command: "npx", Args: []string{"-y", "notes-mcp", "--verbose"}}
+	lock, err := locker.Lock(ctx, &entry)
+	if err != nil || lock.Version != "1.4.2" || lock.Integrity != integrity {
+		t.Fatalf("expected notes-mcp locked, got %+v (err=%v)", lock, err)
+	}
+	if len(entry.Args) != 3 || filepath.Base(entry.Args[1]) != "notes-mcp-1.4.2.tgz" || entry.Args[2] != "--verbose" {
+		t.Fatalf("expected the tarball run, got %v", entry.Args)
+	}
+	if data, err := os.ReadFile(entry.Args[1]); err != nil || !bytes.Equal(data, tarball) {
+		t.Errorf("expected the verified tarball stored, got %q (err=%v)", data, err)
+	}
+
+	// Later starts run the locked version, as long as it is unchanged
+	entry.Args = []string{"-y", "notes-mcp", "--verbose"}
+	if _, err := locker.Lock(ctx, &entry); err != nil || filepath.Base(entry.Args[1]) != "notes-mcp-1.4.2.tgz" {
+		t.Fatalf("expected the locked version, got %v (err=%v)", entry.Args, err)
+	}
+
+	// A tarball that doesn't match what the registry reports is refused
+	tarball = []byte("notes-mcp 1.4.2 with a backdoor")
+	os.RemoveAll(locker.dir)
+	entry.Args = []string{"-y", "notes-mcp", "--verbose"}
+	if _, err := locker.Lock(ctx, &entry); !errors.Is(err, ErrPackageChanged) || entry.Args[1] != "notes-mcp" {
+		t.Fatalf("expected a tampered tarball refused, got %v (err=%v)", entry.Args, err)
+	}
+
+	// So is a package the registry now reports differently
+	integrity = sri(tarball)
+	if _, err := locker.Lock(ctx, &entry); !errors.Is(err, ErrPackageChanged) {
+		t.Fatalf("expected a changed package refused, got %v", err)
+	}
+
+	// Unlocking accepts the change
+	if err := locker.Unlock("notes"); err != nil {
+		t.Fatalf("failed to unlock: %v", err)
+	}
+	if lock, err := locker.Lock(ctx, &entry); err != nil || lock.Integrity != integrity {
+		t.Errorf("expected the package locked again, got %+v (err=%v)", lock, err)
+	}
+
+	// uvx runs the universal wheel
+	git := proxy.ServerEntry{Name: "git", Transport: "stdio", Command: "uvx", Args: []string{"mcp-server-git"}}
+	lock, err = locker.Lock(ctx, &git)
+	if err != nil || lock.Version != "0.6.2" || !strings.Contains(lock.Integrity, wheelDigest) {
+		t.Fatalf("expected mcp-server-git locked, got %+v (err=%v)", lock, err)
+	}
+	if len(git.Args) != 3 || git.Args[0] != "--from" || filepath.Base(git.Args[1]) != "mcp_server_git-0.6.2-py3-none-any.whl" || git.Args[2] != "mcp-server-git" {
+		t.Fatalf("expected the wheel run, got %v", git.Args)
+	}
+
+	missing := proxy.ServerEntry{Name: "missing", Transport: "stdio", Command: "npx", Args: []string{"-y", "missing-mcp"}}
+	if _, err := locker.Lock(ctx, &missing); err == nil || errors.Is(err, ErrPackageChanged) || missing.Args[1] != "missing-mcp" {
+		t.Errorf("expected an unresolvable package left alone, got %v (err=%v)", missing.Args, err)
+	}
+	if locks, _ := locker.List(); len(locks) != 2 || locks[0].Server != "git" || locks[1].Server != "notes" {
+		t.Errorf("unexpected locks %+v", locks)
+	}
+}
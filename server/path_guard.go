@@ -0,0 +1,243 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// pathKeyHints are substrings of argument names that hold paths even when
+// the value doesn't look like one (e.g. "notes.txt" in a "path" argument).
+var pathKeyHints = []string{"path", "file", "dir", "folder", "cwd", "root", "source", "destination", "src", "dst", "target"}
+
+// PathViolation is a tools/call argument that points outside a server's
+// allowed roots. Field is a dotted path like the ones in FieldError.
+type PathViolation struct {
+	Field string `json:"field"`
+	Path  string `json:"path"`
+}
+
+func (v PathViolation) String() string {
+	return fmt.Sprintf("%s: %s is outside the allowed roots", v.Field, v.Path)
+}
+
+// CheckPaths returns the arguments that reference paths outside roots. A
+// string argument counts as a path if its name suggests one or its value
+// looks like one (absolute, ~, ./, ../, file://). Relative paths resolve
+// against cwd, the backend's working directory, or the first root when it
+// isn't known; file:// URIs are percent-decoded, ../ segments are cleaned
+// before comparing, and symlinks on disk are followed, so none of them lead
+// out of the sandbox. With no roots every path is allowed.
+func CheckPaths(roots []string, cwd string, args map[string]interface{}) []PathViolation {
+	if len(roots) == 0 {
+		return nil
+	}
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		resolved = append(resolved, resolvePath(expandHome(root)))
+	}
+
+	var violations []PathViolation
+	checkPathValue(resolved, cwd, "", false, args, &violations)
+	return violations
+}
+
+func checkPathValue(roots []string, cwd, field string, pathKey bool, value interface{}, violations *[]PathViolation) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			checkPathValue(roots, cwd, joinField(field, k), isPathKey(k), v[k], violations)
+		}
+	case []interface{}:
+		for i, item := range v {
+			checkPathValue(roots, cwd, fmt.Sprintf("%s[%d]", field, i), pathKey, item, violations)
+		}
+	case string:
+		if !pathKey && !looksLikePath(v) {
+			return
+		}
+		if !withinRoots(roots, cwd, v) {
+			*violations = append(*violations, PathViolation{Field: field, Path: v})
+		}
+	}
+}
+
+func isPathKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, hint := range pathKeyHints {
+		if strings.Contains(key, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikePath reports whether a string argument is a path whatever its name.
+func looksLikePath(s string) bool {
+	if s == "" || strings.ContainsAny(s, "\n\r") {
+		return false
+	}
+	switch {
+	case s == "~" || s == "." || s == "..":
+		return true
+	case strings.HasPrefix(s, "file://"), strings.HasPrefix(s, "~/"):
+		return true
+	case strings.HasPrefix(s, "/") && !strings.Contains(s, " "):
+		return true
+	case strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../"):
+		return true
+	case strings.Contains(s, "/../") || strings.HasSuffix(s, "/.."):
+		return true
+	}
+	return false
+}
+
+// withinRoots reports whether p, resolved as the server would, is inside one
+// of the (already resolved) roots.
+func withinRoots(roots []string, cwd, p string) bool {
+	decoded, ok := fileURIPath(p)
+	if !ok || strings.ContainsRune(decoded, 0) {
+		return false
+	}
+	p = resolvePath(resolveArgumentPath(roots, cwd, decoded))
+
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath cleans p and follows symlinks in the longest part of it that
+// exists, so a link inside a root can't point outside it.
+func resolvePath(p string) string {
+	p = filepath.Clean(p)
+	rest := ""
+	for dir := p; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return p
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// resolveArgumentPath returns the file a path argument names, the way
+// CheckPaths reads it: file:// URIs are decoded, ~ is expanded, and relative
+// paths resolve against cwd or, when it isn't known, the first of roots.
+func resolveArgumentPath(roots []string, cwd, p string) string {
+	if path, ok := fileURIPath(p); ok {
+		p = path
+	}
+	p = expandHome(p)
+	if !filepath.IsAbs(p) {
+		if cwd != "" {
+			p = filepath.Join(cwd, p)
+		} else if len(roots) > 0 {
+			p = filepath.Join(expandHome(roots[0]), p)
+		}
+	}
+	return p
+}
+
+// fileURIPath returns the percent-decoded path of a file:// URI, so
+// %2e%2e can't hide a ../ from the containment check. Other values are
+// returned as they are. It fails for URIs that don't parse or name another
+// host.
+func fileURIPath(p string) (string, bool) {
+	if !strings.HasPrefix(p, "file://") {
+		return p, true
+	}
+	u, err := url.Parse(p)
+	if err != nil || (u.Host != "" && u.Host != "localhost") {
+		return "", false
+	}
+	return u.Path, true
+}
+
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~"))
+}
+
+// sandboxBlock describes a tools/call denied for referencing paths outside
+// a server's allowed roots.
+func sandboxBlock(toolName string, violations []PathViolation) *BlockError {
+	parts := make([]string, len(violations))
+	for i, v := range violations {
+		parts[i] = v.String()
+	}
+	return &BlockError{
+		BlockedBy: BlockedBySandbox,
+		Action:    "block",
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    strings.Join(parts, "; "),
+		Appeal:    "add the directory to allowed_roots of the server in servers.json",
+	}
+}
+
+// AllowedRoots returns the directories a backend's file arguments must stay
+// in, or nil if it isn't sandboxed.
+func (bm *BackendManager) AllowedRoots(backendID string) []string {
+	bm.mu.RLock()
+	conn, exists := bm.connections[backendID]
+	bm.mu.RUnlock()
+	if !exists || conn.config == nil {
+		return nil
+	}
+	return conn.config.AllowedRoots
+}
+
+// WorkingDir returns the directory a backend resolves relative paths
+// against, or "" if it isn't known.
+func (bm *BackendManager) WorkingDir(backendID string) string {
+	bm.mu.RLock()
+	conn, exists := bm.connections[backendID]
+	bm.mu.RUnlock()
+	if !exists {
+		return ""
+	}
+	return backendWorkingDir(conn.config)
+}
+
+// backendWorkingDir returns a server's cwd or, for a stdio server without
+// one, the proxy's own, which its process inherits. Servers that don't run
+// as a local process have none.
+func backendWorkingDir(entry *proxy.ServerEntry) string {
+	switch {
+	case entry == nil:
+		return ""
+	case entry.Cwd != "":
+		return expandHome(entry.Cwd)
+	case entry.Transport == "stdio":
+		if wd, err := os.Getwd(); err == nil {
+			return wd
+		}
+	}
+	return ""
+}
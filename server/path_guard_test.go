@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestCheckPaths tests which arguments escape the allowed roots
+func TestCheckPaths(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	tests := []struct {
+		desc    string
+		args    map[string]interface{}
+		blocked []string // fields expected to be reported
+	}{
+		{"absolute inside", map[string]interface{}{"path": filepath.Join(root, "docs/a.md")}, nil},
+		{"root itself", map[string]interface{}{"path": root}, nil},
+		{"relative inside", map[string]interface{}{"path": "docs/a.md"}, nil},
+		{"file URI inside", map[string]interface{}{"uri": "file://" + filepath.Join(root, "a.md")}, nil},
+		{"percent-encoded file URI inside", map[string]interface{}{"uri": "file://" + root + "/docs%2Fa%20b.md"}, nil},
+		{"percent-encoded traversal", map[string]interface{}{"uri": "file://" + root + "/%2e%2e/secret"}, []string{"uri"}},
+		{"percent-encoded NUL", map[string]interface{}{"uri": "file://" + root + "/a%00.md"}, []string{"uri"}},
+		{"file URI of another host", map[string]interface{}{"uri": "file://example.com" + root + "/a.md"}, []string{"uri"}},
+		{"absolute outside", map[string]interface{}{"path": "/etc/passwd"}, []string{"path"}},
+		{"traversal", map[string]interface{}{"path": "docs/../../secret"}, []string{"path"}},
+		{"traversal in absolute path", map[string]interface{}{"file": root + "/../x"}, []string{"file"}},
+		{"symlink out of the root", map[string]interface{}{"path": filepath.Join(root, "escape/key.pem")}, []string{"path"}},
+		{"home outside", map[string]interface{}{"dir": "~/.ssh"}, []string{"dir"}},
+		{"path-like value under any name", map[string]interface{}{"q": "/etc/shadow"}, []string{"q"}},
+		{"nested", map[string]interface{}{"edits": []interface{}{map[string]interface{}{"target": "../x"}}}, []string{"edits[0].target"}},
+		{"prefix of a root is not inside it", map[string]interface{}{"path": root + "-other/a"}, []string{"path"}},
+		{"plain text ignored", map[string]interface{}{"text": "see the ../ folder /tmp notes", "count": 3.0}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			violations := CheckPaths([]string{root}, "", tt.args)
+			var fields []string
+			for _, v := range violations {
+				fields = append(fields, v.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.blocked, ",") {
+				t.Errorf("expected violations %v, got %v", tt.blocked, violations)
+			}
+		})
+	}
+
+	if v := CheckPaths(nil, "", map[string]interface{}{"path": "/etc/passwd"}); v != nil {
+		t.Errorf("expected no sandbox without roots, got %v", v)
+	}
+	if v := CheckPaths([]string{root, "~/projects"}, "", map[string]interface{}{"path": "~/projects/app/main.go"}); v != nil {
+		t.Errorf("expected a path in the second root to be allowed, got %v", v)
+	}
+
+	// Relative paths resolve against the backend's working directory
+	if v := CheckPaths([]string{root}, filepath.Join(root, "docs"), map[string]interface{}{"path": "a.md"}); v != nil {
+		t.Errorf("expected a relative path under a cwd inside the root to be allowed, got %v", v)
+	}
+	if v := CheckPaths([]string{root}, filepath.Join(root, "docs"), map[string]interface{}{"path": "../../secret"}); len(v) != 1 {
+		t.Errorf("expected a relative path leaving the root from the cwd to be blocked, got %v", v)
+	}
+	if v := CheckPaths([]string{root}, outside, map[string]interface{}{"path": "docs/a.md"}); len(v) != 1 {
+		t.Errorf("expected a relative path under a cwd outside the root to be blocked, got %v", v)
+	}
+}
+
+// TestSandboxedBackendCall tests that a stdio backend with allowed roots only
+// gets calls whose paths stay inside them
+func TestSandboxedBackendCall(t *testing.T) {
+	root := t.TempDir()
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: read_file
+    result:
+      content: [{type: text, text: contents}]
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "fs", Transport: "mock", Fixture: fixture, AllowedRoots: []string{root}},
+		{Name: "elsewhere", Transport: "mock", Fixture: fixture, AllowedRoots: []string{root}, Cwd: t.TempDir()},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	callServer := func(id int, server, path string) JSONRPCResponse {
+		params, _ := json.Marshal(map[string]interface{}{"name": server + ":read_file", "arguments": map[string]interface{}{"path": path}})
+		return srv.handleRequest(ctx, JSONRPCRequest{ID: id, Method: "tools/call", Params: params}).(JSONRPCResponse)
+	}
+	call := func(id int, path string) JSONRPCResponse { return callServer(id, "fs", path) }
+
+	if resp := call(2, filepath.Join(root, "notes.md")); resp.Error != nil {
+		t.Errorf("expected a path inside the root to be allowed, got %+v", resp.Error)
+	}
+	resp := call(3, filepath.Join(root, "../../etc/passwd"))
+	if resp.Error == nil || resp.Error.Code != ErrCodeDenied {
+		t.Fatalf("expected traversal to be denied, got %+v", resp)
+	}
+	if block := resp.Error.Data.(*BlockError); block.BlockedBy != BlockedBySandbox || !strings.Contains(block.Reason, "path:") {
+		t.Errorf("expected a sandbox block naming the argument, got %+v", block)
+	}
+
+	// A relative path resolves against the server's cwd, not the root
+	if resp := callServer(4, "elsewhere", "notes.md"); resp.Error == nil || resp.Error.Code != ErrCodeDenied {
+		t.Errorf("expected a relative path from a cwd outside the root to be denied, got %+v", resp)
+	}
+}
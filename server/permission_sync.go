@@ -0,0 +1,520 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// settingPermissionSync persists the permission sync direction and the
+// entries both sides agreed on after the last sync.
+const settingPermissionSync = "permission_sync"
+
+// syncedRulePrefix names the rules the sync created from settings.json; only
+// these are changed or deleted when settings.json changes.
+const syncedRulePrefix = "settings.json: "
+
+// Directions the permission sync runs in. Off only reports the status.
+const (
+	SyncOff        = "off"
+	SyncToClaude   = "to_claude"
+	SyncFromClaude = "from_claude"
+	SyncBoth       = "both"
+)
+
+// Status of an entry in a PermissionSyncReport.
+const (
+	SyncStatusSynced      = "synced"
+	SyncStatusArmourOnly  = "armour_only"
+	SyncStatusClaudeOnly  = "claude_only"
+	SyncStatusDiffers     = "differs"
+	SyncStatusConflict    = "conflict"
+	SyncStatusUnsupported = "unsupported"
+)
+
+// ruleModes maps rule actions to the settings.json lists they compile to.
+// Allow is not synced: a native allow entry would become a block_all allow
+// rule, which skips every content check after it.
+var ruleModes = map[string]string{"block": "deny", "ask": "ask"}
+
+// PermissionSyncItem is one Claude Code permission entry, e.g. Bash or
+// mcp__github__delete_repo, and how it is set on both sides.
+type PermissionSyncItem struct {
+	Entry  string `json:"entry"`
+	Tool   string `json:"tool,omitempty"`   // the tool name Armour rules use
+	Armour string `json:"armour,omitempty"` // deny or ask
+	Claude string `json:"claude,omitempty"` // deny or ask
+	Status string `json:"status"`
+	RuleID int    `json:"rule_id,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PermissionSyncReport is the sync status shown on the dashboard.
+type PermissionSyncReport struct {
+	Direction     string               `json:"direction"`
+	SettingsPath  string               `json:"settings_path"`
+	LastSync      time.Time            `json:"last_sync,omitempty"`
+	Items         []PermissionSyncItem `json:"items"`
+	ClaudeChanges int                  `json:"claude_changes"` // made by this sync
+	ArmourChanges int                  `json:"armour_changes"`
+}
+
+// permissionSyncState is what settingPermissionSync stores.
+type permissionSyncState struct {
+	Direction string            `json:"direction"`
+	LastSync  time.Time         `json:"last_sync,omitempty"`
+	Synced    map[string]string `json:"synced,omitempty"` // entry -> mode both sides had
+}
+
+// ValidSyncDirection reports whether direction is one of the Sync* constants.
+func ValidSyncDirection(direction string) bool {
+	switch direction {
+	case SyncOff, SyncToClaude, SyncFromClaude, SyncBoth:
+		return true
+	}
+	return false
+}
+
+// ClaudeSettingsPath returns the path of Claude Code's user settings.json.
+func ClaudeSettingsPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".claude", "settings.json")
+}
+
+// NativePermissionEntry returns the settings.json permission entry for a
+// tool name as Armour rules write it: native tools keep their name and
+// server:tool becomes mcp__server__tool (server:* the whole server). Tags
+// and other wildcards have no native equivalent.
+func NativePermissionEntry(tool string) (string, bool) {
+	if strings.HasPrefix(strings.ToLower(tool), tagPrefix) {
+		return "", false
+	}
+	server, name, namespaced := strings.Cut(tool, ":")
+	if !namespaced {
+		if !isPermissionName(tool) {
+			return "", false
+		}
+		return tool, true
+	}
+	if !isPermissionName(server) {
+		return "", false
+	}
+	if name == "*" {
+		return "mcp__" + server, true
+	}
+	if !isPermissionName(name) {
+		return "", false
+	}
+	return "mcp__" + server + "__" + name, true
+}
+
+// ArmourToolName is the inverse of NativePermissionEntry. Entries with a
+// specifier such as Bash(rm:*) only match some calls and have no block_all
+// equivalent.
+func ArmourToolName(entry string) (string, bool) {
+	rest, ok := strings.CutPrefix(entry, "mcp__")
+	if !ok {
+		if !isPermissionName(entry) {
+			return "", false
+		}
+		return entry, true
+	}
+	server, name, _ := strings.Cut(rest, "__")
+	if !isPermissionName(server) {
+		return "", false
+	}
+	if name == "" || name == "*" {
+		return server + ":*", true
+	}
+	if !isPermissionName(name) {
+		return "", false
+	}
+	return server + ":" + name, true
+}
+
+// isPermissionName reports whether s is a plain tool or server name.
+func isPermissionName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// compiledEntry is a permission entry an Armour rule enforces.
+type compiledEntry struct {
+	mode string
+	rule Rule
+}
+
+// compileRules returns the permission entries the enabled rules enforce,
+// the first rule in evaluation order winning, and the rules that can't be
+// expressed natively.
+func compileRules(rules []Rule) (map[string]compiledEntry, []PermissionSyncItem) {
+	compiled := make(map[string]compiledEntry)
+	var unsupported []PermissionSyncItem
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		mode, ok := ruleModes[rule.Action]
+		if !ok {
+			continue
+		}
+		if !rule.BlockAll {
+			unsupported = append(unsupported, PermissionSyncItem{Entry: rule.Name, Status: SyncStatusUnsupported, RuleID: rule.ID,
+				Reason: "matches call content, which settings.json can't express; enforced by Armour only"})
+			continue
+		}
+		tools := strings.TrimSpace(rule.Tools)
+		if tools == "" || tools == "*" {
+			unsupported = append(unsupported, PermissionSyncItem{Entry: rule.Name, Status: SyncStatusUnsupported, RuleID: rule.ID,
+				Reason: "applies to every tool; list the tools to sync it"})
+			continue
+		}
+		for _, tool := range strings.Split(tools, ",") {
+			tool = strings.TrimSpace(tool)
+			entry, ok := NativePermissionEntry(tool)
+			if mcp := strings.HasPrefix(entry, "mcp__"); ok && (mcp && rule.Scope == "native" || !mcp && rule.Scope == "mcp") {
+				ok = false
+			}
+			if !ok {
+				unsupported = append(unsupported, PermissionSyncItem{Entry: tool, Tool: tool, Armour: mode, Status: SyncStatusUnsupported, RuleID: rule.ID,
+					Reason: "no settings.json equivalent for this tool pattern or scope"})
+				continue
+			}
+			if _, seen := compiled[entry]; !seen {
+				compiled[entry] = compiledEntry{mode: mode, rule: rule}
+			}
+		}
+	}
+	return compiled, unsupported
+}
+
+// readClaudePermissions reads settings.json and its deny and ask entries by
+// entry. A missing file has none.
+func readClaudePermissions(path string) (map[string]interface{}, map[string]string, error) {
+	settings := make(map[string]interface{})
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+	}
+
+	entries := make(map[string]string)
+	permissions, _ := settings["permissions"].(map[string]interface{})
+	for _, mode := range []string{"ask", "deny"} { // deny wins, as in Claude Code
+		list, _ := permissions[mode].([]interface{})
+		for _, item := range list {
+			if entry, ok := item.(string); ok {
+				entries[entry] = mode
+			}
+		}
+	}
+	return settings, entries, nil
+}
+
+// setClaudePermission moves entry to the deny or ask list, or removes it
+// from both if mode is "".
+func setClaudePermission(settings map[string]interface{}, entry, mode string) {
+	permissions, ok := settings["permissions"].(map[string]interface{})
+	if !ok {
+		permissions = make(map[string]interface{})
+		settings["permissions"] = permissions
+	}
+	for _, list := range []string{"ask", "deny"} {
+		items, _ := permissions[list].([]interface{})
+		kept := make([]interface{}, 0, len(items)+1)
+		for _, item := range items {
+			if item != entry {
+				kept = append(kept, item)
+			}
+		}
+		if list == mode {
+			kept = append(kept, entry)
+		}
+		if len(kept) > 0 {
+			permissions[list] = kept
+		} else {
+			delete(permissions, list)
+		}
+	}
+	if len(permissions) == 0 {
+		delete(settings, "permissions")
+	}
+}
+
+// writeClaudeSettings replaces settings.json atomically.
+func writeClaudeSettings(path string, settings map[string]interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".settings-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *RulesStore) loadPermissionSync() (permissionSyncState, error) {
+	state := permissionSyncState{Direction: SyncOff}
+	raw, err := s.GetSetting(settingPermissionSync)
+	if err != nil || raw == "" {
+		return state, err
+	}
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		return state, fmt.Errorf("invalid stored permission sync state: %w", err)
+	}
+	return state, nil
+}
+
+func (s *RulesStore) savePermissionSync(state permissionSyncState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.SetSetting(settingPermissionSync, string(data))
+}
+
+// SetPermissionSyncDirection stores the direction later syncs run in.
+func (s *RulesStore) SetPermissionSyncDirection(direction string) error {
+	if !ValidSyncDirection(direction) {
+		return fmt.Errorf("invalid sync direction %q (want off, to_claude, from_claude or both)", direction)
+	}
+	state, err := s.loadPermissionSync()
+	if err != nil {
+		return err
+	}
+	state.Direction = direction
+	return s.savePermissionSync(state)
+}
+
+// PermissionSyncStatus compares the rules with settingsPath without changing either.
+func (s *RulesStore) PermissionSyncStatus(settingsPath string) (*PermissionSyncReport, error) {
+	return s.syncPermissions(settingsPath, "", false)
+}
+
+// SyncPermissions keeps the deny and ask entries of settings.json and the
+// block_all block and ask rules in step, in the stored direction if
+// direction is "". to_claude and from_claude make one side match the
+// other; both applies each side's changes since the last sync and reports
+// entries changed on both as conflicts. Rules the sync didn't create are
+// never changed; their entries are reported as conflicts instead.
+func (s *RulesStore) SyncPermissions(settingsPath, direction string) (*PermissionSyncReport, error) {
+	return s.syncPermissions(settingsPath, direction, true)
+}
+
+func (s *RulesStore) syncPermissions(settingsPath, direction string, apply bool) (*PermissionSyncReport, error) {
+	state, err := s.loadPermissionSync()
+	if err != nil {
+		return nil, err
+	}
+	if direction == "" {
+		direction = state.Direction
+	}
+	if !ValidSyncDirection(direction) {
+		return nil, fmt.Errorf("invalid sync direction %q", direction)
+	}
+	rules, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	// List is newest first; the first rule in evaluation order wins
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	settings, claude, err := readClaudePermissions(settingsPath)
+	if err != nil {
+		return nil, err
+	}
+	armour, unsupported := compileRules(rules)
+	if state.Synced == nil {
+		state.Synced = make(map[string]string)
+	}
+
+	report := &PermissionSyncReport{Direction: direction, SettingsPath: settingsPath, LastSync: state.LastSync}
+	var entries []string
+	for _, m := range []map[string]string{claude, state.Synced} {
+		for entry := range m {
+			entries = append(entries, entry)
+		}
+	}
+	for entry := range armour {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	entries = slices.Compact(entries)
+
+	for _, entry := range entries {
+		a, c, base := armour[entry].mode, claude[entry], state.Synced[entry]
+		item := PermissionSyncItem{Entry: entry, Armour: a, Claude: c, RuleID: armour[entry].rule.ID}
+		item.Tool, _ = ArmourToolName(entry)
+		if a == "" && c == "" {
+			delete(state.Synced, entry)
+			continue
+		}
+		if a == c {
+			item.Status = SyncStatusSynced
+			state.Synced[entry] = a
+			report.Items = append(report.Items, item)
+			continue
+		}
+		if item.Tool == "" {
+			item.Status, item.Reason = SyncStatusUnsupported, "has a specifier settings.json matches natively; Armour can't block it as a whole tool"
+			report.Items = append(report.Items, item)
+			continue
+		}
+
+		want, outcome := syncedMode(direction, a, c, base)
+		switch {
+		case outcome == syncConflict:
+			item.Status, item.Reason = SyncStatusConflict, "changed in both Armour and settings.json since the last sync"
+		case outcome == syncApply && want != a && a != "" && !syncManaged(armour[entry].rule):
+			item.Status, item.Reason = SyncStatusConflict, fmt.Sprintf("rule %d (%s) was not created by the sync; change it in Armour", item.RuleID, armour[entry].rule.Name)
+		case outcome == syncSkip || !apply:
+			item.Status = unsyncedStatus(a, c)
+		default:
+			if want != c {
+				setClaudePermission(settings, entry, want)
+				report.ClaudeChanges++
+			}
+			if want != a {
+				ruleID, err := s.syncRule(entry, item.Tool, want, armour[entry])
+				if err != nil {
+					return nil, err
+				}
+				item.RuleID = ruleID
+				report.ArmourChanges++
+			}
+			item.Armour, item.Claude, item.Status = want, want, SyncStatusSynced
+			if want == "" {
+				delete(state.Synced, entry)
+				continue
+			}
+			state.Synced[entry] = want
+		}
+		report.Items = append(report.Items, item)
+	}
+	report.Items = append(report.Items, unsupported...)
+	if report.Items == nil {
+		report.Items = []PermissionSyncItem{}
+	}
+
+	if !apply || direction == SyncOff {
+		return report, nil
+	}
+	if report.ClaudeChanges > 0 {
+		if err := writeClaudeSettings(settingsPath, settings); err != nil {
+			return nil, err
+		}
+	}
+	state.LastSync = time.Now().UTC()
+	report.LastSync = state.LastSync
+	if err := s.savePermissionSync(state); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// What syncedMode decides for an entry.
+const (
+	syncApply = iota
+	syncSkip
+	syncConflict
+)
+
+// syncedMode decides what an entry that differs between Armour (a) and
+// settings.json (c) becomes, given what both had at the last sync (base).
+// One-way syncs copy changes and the removal of synced entries, but leave
+// entries the target side has on its own.
+func syncedMode(direction, a, c, base string) (string, int) {
+	switch direction {
+	case SyncOff:
+		return "", syncSkip
+	case SyncToClaude:
+		if a == "" && base == "" {
+			return "", syncSkip
+		}
+		return a, syncApply
+	case SyncFromClaude:
+		if c == "" && base == "" {
+			return "", syncSkip
+		}
+		return c, syncApply
+	}
+	switch base {
+	case a:
+		return c, syncApply
+	case c:
+		return a, syncApply
+	}
+	return "", syncConflict
+}
+
+// syncManaged reports whether the sync created rule and may change it.
+func syncManaged(rule Rule) bool {
+	return strings.HasPrefix(rule.Name, syncedRulePrefix) && !strings.Contains(rule.Tools, ",")
+}
+
+func unsyncedStatus(a, c string) string {
+	switch {
+	case c == "":
+		return SyncStatusArmourOnly
+	case a == "":
+		return SyncStatusClaudeOnly
+	}
+	return SyncStatusDiffers
+}
+
+// syncRule makes the rules enforce mode for tool: it updates or deletes the
+// sync-created rule behind current, or creates one. It returns the rule's ID.
+func (s *RulesStore) syncRule(entry, tool, mode string, current compiledEntry) (int, error) {
+	action := "block"
+	if mode == "ask" {
+		action = "ask"
+	}
+	if current.mode != "" {
+		rule := current.rule
+		if mode == "" {
+			return rule.ID, s.Delete(rule.ID)
+		}
+		rule.Action = action
+		return rule.ID, s.Update(&rule)
+	}
+
+	scope := "native"
+	if strings.HasPrefix(entry, "mcp__") {
+		scope = "mcp"
+	}
+	rule := &Rule{Name: syncedRulePrefix + entry, Tools: tool, Scope: scope, Action: action, BlockAll: true}
+	if err := s.Create(rule); err != nil {
+		return 0, err
+	}
+	return rule.ID, nil
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestNativePermissionEntry tests the mapping between Armour tool names and
+// settings.json permission entries
+func TestNativePermissionEntry(t *testing.T) {
+	for tool, want := range map[string]string{
+		"Bash":                "Bash",
+		"github:delete_repo":  "mcp__github__delete_repo",
+		"github:*":            "mcp__github",
+		"tag:prod":            "",
+		"*delete":             "",
+		"github:delete_*":     "",
+		"Bash(rm:*)":          "",
+		"web-search:fetch.v2": "mcp__web-search__fetch.v2",
+	} {
+		entry, ok := NativePermissionEntry(tool)
+		if entry != want || ok != (want != "") {
+			t.Errorf("%s: expected %q, got %q (%v)", tool, want, entry, ok)
+		}
+		if !ok {
+			continue
+		}
+		if back, ok := ArmourToolName(entry); !ok || back != tool {
+			t.Errorf("%s: expected %s to map back, got %q", tool, entry, back)
+		}
+	}
+	if _, ok := ArmourToolName("Bash(rm:*)"); ok {
+		t.Error("expected an entry with a specifier to have no tool name")
+	}
+}
+
+// syncFixture opens a rules store and writes settings.json with permissions.
+func syncFixture(t *testing.T, permissions map[string][]string) (*RulesStore, string) {
+	t.Helper()
+	store, err := OpenRulesStore(filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("failed to open rules store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	path := filepath.Join(t.TempDir(), "settings.json")
+	writeJSONFile(t, path, map[string]interface{}{"model": "opus", "permissions": permissions})
+	return store, path
+}
+
+// readSettingsPermissions returns the permission lists in settings.json.
+func readSettingsPermissions(t *testing.T, path string) map[string][]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read settings: %v", err)
+	}
+	var settings struct {
+		Model       string              `json:"model"`
+		Permissions map[string][]string `json:"permissions"`
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		t.Fatalf("invalid settings: %v", err)
+	}
+	if settings.Model != "opus" {
+		t.Errorf("expected other settings kept, got %s", data)
+	}
+	return settings.Permissions
+}
+
+func syncItem(report *PermissionSyncReport, entry string) PermissionSyncItem {
+	for _, item := range report.Items {
+		if item.Entry == entry {
+			return item
+		}
+	}
+	return PermissionSyncItem{}
+}
+
+// TestSyncPermissionsBoth tests that a two-way sync copies block_all rules
+// to settings.json and deny/ask entries to rules, then follows changes on
+// either side
+func TestSyncPermissionsBoth(t *testing.T) {
+	store, path := syncFixture(t, map[string][]string{
+		"allow": {"Read"},
+		"deny":  {"WebFetch", "Bash(rm:*)"},
+		"ask":   {"mcp__github__delete_repo"},
+	})
+	bash := &Rule{Name: "no-bash", Tools: "Bash", BlockAll: true}
+	content := &Rule{Name: "no-rm", Tools: "Bash", Pattern: "rm -rf"}
+	for _, rule := range []*Rule{bash, content} {
+		if err := store.Create(rule); err != nil {
+			t.Fatalf("failed to create rule: %v", err)
+		}
+	}
+
+	status, err := store.PermissionSyncStatus(path)
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	for entry, want := range map[string]string{
+		"Bash":                     SyncStatusArmourOnly,
+		"WebFetch":                 SyncStatusClaudeOnly,
+		"mcp__github__delete_repo": SyncStatusClaudeOnly,
+		"Bash(rm:*)":               SyncStatusUnsupported,
+		"no-rm":                    SyncStatusUnsupported,
+	} {
+		if got := syncItem(status, entry).Status; got != want {
+			t.Errorf("%s: expected %s, got %s", entry, want, got)
+		}
+	}
+	if status.Direction != SyncOff || readSettingsPermissions(t, path)["deny"][0] != "WebFetch" {
+		t.Error("expected the status to change nothing")
+	}
+
+	report, err := store.SyncPermissions(path, SyncBoth)
+	if err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if report.ClaudeChanges != 1 || report.ArmourChanges != 2 {
+		t.Errorf("expected 1 change to settings.json and 2 rules, got %+v", report)
+	}
+	permissions := readSettingsPermissions(t, path)
+	if !slices.Contains(permissions["deny"], "Bash") || !slices.Contains(permissions["allow"], "Read") {
+		t.Errorf("expected Bash denied and allow entries kept, got %v", permissions)
+	}
+	rules, _ := store.Enabled("mcp")
+	var github *Rule
+	for i := range rules {
+		if rules[i].Tools == "github:delete_repo" {
+			github = &rules[i]
+		}
+	}
+	if github == nil || github.Action != "ask" || !github.BlockAll || github.Scope != "mcp" {
+		t.Fatalf("expected an ask rule for github:delete_repo, got %+v", rules)
+	}
+
+	// Removed in settings.json: the synced rule goes; changed in Armour: settings.json follows
+	writeJSONFile(t, path, map[string]interface{}{"model": "opus", "permissions": map[string][]string{
+		"deny": {"Bash", "Bash(rm:*)"},
+		"ask":  {"mcp__github__delete_repo"},
+	}})
+	github.Action = "block"
+	if err := store.Update(github); err != nil {
+		t.Fatalf("failed to update rule: %v", err)
+	}
+	if _, err := store.SyncPermissions(path, SyncBoth); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	permissions = readSettingsPermissions(t, path)
+	if !slices.Contains(permissions["deny"], "mcp__github__delete_repo") || len(permissions["ask"]) != 0 {
+		t.Errorf("expected the rule change copied to settings.json, got %v", permissions)
+	}
+	rules, _ = store.Enabled("native")
+	for _, rule := range rules {
+		if rule.Tools == "WebFetch" {
+			t.Errorf("expected the WebFetch rule deleted, got %+v", rule)
+		}
+	}
+
+	// A rule the sync didn't create is not changed for settings.json
+	writeJSONFile(t, path, map[string]interface{}{"model": "opus", "permissions": map[string][]string{
+		"deny": {"mcp__github__delete_repo"},
+	}})
+	report, err = store.SyncPermissions(path, SyncBoth)
+	if err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if item := syncItem(report, "Bash"); item.Status != SyncStatusConflict || item.RuleID != bash.ID {
+		t.Errorf("expected a conflict for the hand-written Bash rule, got %+v", item)
+	}
+	if rule, err := store.Get(bash.ID); err != nil || !rule.Enabled {
+		t.Errorf("expected the hand-written rule kept, got %+v (err=%v)", rule, err)
+	}
+}
+
+// TestSyncPermissionsOneWay tests that a one-way sync leaves entries the
+// target side has on its own, and that the direction is stored
+func TestSyncPermissionsOneWay(t *testing.T) {
+	store, path := syncFixture(t, map[string][]string{"deny": {"WebFetch"}})
+	if err := store.Create(&Rule{Name: "no-shell", Tools: "Bash,github:*", BlockAll: true}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	if err := store.SetPermissionSyncDirection("sideways"); err == nil {
+		t.Error("expected an invalid direction rejected")
+	}
+	if err := store.SetPermissionSyncDirection(SyncToClaude); err != nil {
+		t.Fatalf("failed to set direction: %v", err)
+	}
+
+	report, err := store.SyncPermissions(path, "")
+	if err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if report.Direction != SyncToClaude || report.ArmourChanges != 0 || report.LastSync.IsZero() {
+		t.Errorf("expected a sync to settings.json only, got %+v", report)
+	}
+	deny := readSettingsPermissions(t, path)["deny"]
+	slices.Sort(deny)
+	if !slices.Equal(deny, []string{"Bash", "WebFetch", "mcp__github"}) {
+		t.Errorf("expected both tools denied and WebFetch kept, got %v", deny)
+	}
+	if item := syncItem(report, "WebFetch"); item.Status != SyncStatusClaudeOnly {
+		t.Errorf("expected WebFetch left in settings.json only, got %+v", item)
+	}
+}
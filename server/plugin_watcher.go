@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// defaultPluginWatchInterval is how often ~/.claude/plugins is rescanned.
+const defaultPluginWatchInterval = 5 * time.Second
+
+// PluginWatcher polls the Claude Code plugins directory and adds or removes
+// plugin-provided backends when plugins are installed or uninstalled.
+type PluginWatcher struct {
+	dir      string
+	interval time.Duration
+	backends *BackendManager
+	logger   *proxy.Logger
+	onChange func(added, removed []string)
+
+	fingerprint string
+	startOnce   sync.Once
+}
+
+// NewPluginWatcher creates a watcher for ~/.claude/plugins. The interval can be
+// overridden with ARMOUR_PLUGIN_WATCH_INTERVAL; "0" disables watching (nil).
+// onChange is called after backends were added or removed.
+func NewPluginWatcher(backends *BackendManager, logger *proxy.Logger, onChange func(added, removed []string)) *PluginWatcher {
+	interval := defaultPluginWatchInterval
+	if v := os.Getenv("ARMOUR_PLUGIN_WATCH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			logger.Warn("invalid ARMOUR_PLUGIN_WATCH_INTERVAL %q, using %s", v, interval)
+		} else if d <= 0 {
+			return nil
+		} else {
+			interval = d
+		}
+	}
+
+	homeDir := os.Getenv("HOME")
+	if homeDir == "" {
+		return nil
+	}
+
+	return &PluginWatcher{
+		dir:      filepath.Join(homeDir, ".claude", "plugins"),
+		interval: interval,
+		backends: backends,
+		logger:   logger,
+		onChange: onChange,
+	}
+}
+
+// Start records the current plugin state and polls for changes until ctx is done.
+// It is nil-safe and only starts once.
+func (w *PluginWatcher) Start(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	w.startOnce.Do(func() { w.start(ctx) })
+}
+
+func (w *PluginWatcher) start(ctx context.Context) {
+	w.fingerprint = pluginManifestFingerprint(w.dir)
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.Poll(ctx)
+			}
+		}
+	}()
+}
+
+// Poll rescans the plugins directory once and syncs backends if any plugin
+// manifest changed. Returns true if backends were added or removed.
+func (w *PluginWatcher) Poll(ctx context.Context) bool {
+	fingerprint := pluginManifestFingerprint(w.dir)
+	if fingerprint == w.fingerprint {
+		return false
+	}
+	w.fingerprint = fingerprint
+
+	added, removed := w.backends.SyncPluginServers(ctx)
+	if len(added) == 0 && len(removed) == 0 {
+		return false
+	}
+	w.logger.Info("plugin servers changed: added %v, removed %v", added, removed)
+	if w.onChange != nil {
+		w.onChange(added, removed)
+	}
+	return true
+}
+
+// pluginManifestFingerprint summarizes the plugin manifests under dir (path,
+// size and mtime) so installs, uninstalls and edits can be detected cheaply.
+func pluginManifestFingerprint(dir string) string {
+	var entries []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		inManifestDir := filepath.Base(filepath.Dir(path)) == ".claude-plugin"
+		if !(inManifestDir && (name == "plugin.json" || name == "marketplace.json")) && name != ".mcp.json" && name != "server.json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, path+"|"+strconv.FormatInt(info.Size(), 10)+"|"+strconv.FormatInt(info.ModTime().UnixNano(), 10))
+		return nil
+	})
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
+
+// SyncPluginServers re-runs plugin discovery, initializes servers from newly
+// installed plugins and tears down servers whose plugin was removed.
+// Explicitly configured servers are never removed.
+func (bm *BackendManager) SyncPluginServers(ctx context.Context) (added, removed []string) {
+	discovered := bm.autoDiscoverPluginMCPServers()
+
+	bm.mu.Lock()
+	if bm.registry == nil {
+		bm.mu.Unlock()
+		return nil, nil
+	}
+
+	present := make(map[string]bool, len(discovered))
+	for _, srv := range discovered {
+		present[srv.Name] = true
+	}
+
+	existing := make(map[string]bool, len(bm.registry.Servers))
+	for _, srv := range bm.registry.Servers {
+		existing[srv.Name] = true
+	}
+
+	var toInit []proxy.ServerEntry
+	for _, srv := range discovered {
+		if existing[srv.Name] || bm.hasServerForHost(srv.URL) || !bm.admitDiscovered(srv, TrustPlugin) {
+			continue
+		}
+		bm.registry.Servers = append(bm.registry.Servers, srv)
+		bm.pluginServers[srv.Name] = true
+		toInit = append(toInit, srv)
+	}
+
+	for name, pending := range bm.pendingServers {
+		if pending.tier == TrustPlugin && !present[name] {
+			delete(bm.pendingServers, name)
+		}
+	}
+	for name := range bm.pluginServers {
+		if present[name] {
+			continue
+		}
+		delete(bm.pluginServers, name)
+		for i, srv := range bm.registry.Servers {
+			if srv.Name == name {
+				bm.registry.Servers = append(bm.registry.Servers[:i], bm.registry.Servers[i+1:]...)
+				break
+			}
+		}
+		removed = append(removed, name)
+	}
+	bm.mu.Unlock()
+
+	for _, name := range removed {
+		bm.RemoveBackend(name)
+	}
+
+	for i := range toInit {
+		entry := toInit[i]
+		initCtx, cancel := context.WithTimeout(ctx, backendInitTimeout)
+		err := bm.initializeBackend(initCtx, &entry)
+		cancel()
+		if err != nil {
+			bm.logger.Error("failed to initialize plugin backend %s: %v", entry.Name, err)
+			continue
+		}
+		added = append(added, entry.Name)
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// hasServerForHost reports whether a registry entry already points at url's host.
+// Callers must hold bm.mu.
+func (bm *BackendManager) hasServerForHost(url string) bool {
+	if url == "" {
+		return false
+	}
+	for _, srv := range bm.registry.Servers {
+		if srv.URL != "" && sameHost(srv.URL, url) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveBackend closes a backend connection, stops its subprocess and drops its tools and prompts.
+func (bm *BackendManager) RemoveBackend(name string) {
+	bm.mu.Lock()
+	conn, ok := bm.connections[name]
+	delete(bm.connections, name)
+	bm.mu.Unlock()
+
+	if bm.toolRegistry != nil {
+		bm.toolRegistry.ClearBackendTools(name)
+		if err := bm.toolRegistry.SaveToFile(); err != nil {
+			bm.logger.Debug("failed to persist discovered tools: %v", err)
+		}
+	}
+	bm.prompts.ClearBackendPrompts(name)
+	if !ok {
+		return
+	}
+
+	conn.closeTransports()
+	// superviseProcess reaps the process and, finding the connection
+	// gone, doesn't restart it
+	if conn.process != nil && conn.process.Process != nil {
+		conn.process.Process.Kill()
+	}
+	bm.logger.Info("removed backend %s", name)
+}
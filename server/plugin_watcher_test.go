@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func writePluginManifest(t *testing.T, home, plugin, manifest string) string {
+	t.Helper()
+	dir := filepath.Join(home, ".claude", "plugins", plugin)
+	if err := os.MkdirAll(filepath.Join(dir, ".claude-plugin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".claude-plugin", "plugin.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPluginWatcherAddsAndRemovesServers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ARMOUR_PLUGIN_WATCH_INTERVAL", "")
+
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "configured", Transport: "http", URL: "http://127.0.0.1:1/configured"}}}
+	backends := NewBackendManager(registry, proxy.NewLogger("error"), NewToolRegistry(), nil)
+
+	var changes int
+	watcher := NewPluginWatcher(backends, proxy.NewLogger("error"), func(added, removed []string) { changes++ })
+	if watcher == nil {
+		t.Fatal("expected watcher")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher.Start(ctx)
+
+	if watcher.Poll(ctx) {
+		t.Error("expected no change before any plugin is installed")
+	}
+
+	// Install a plugin; its backend is unreachable so nothing initializes,
+	// but it must be tracked in the registry.
+	pluginDir := writePluginManifest(t, home, "demo", `{"name":"demo","mcpServers":{"demo-server":{"type":"http","url":"http://127.0.0.2:1/mcp"}}}`)
+	watcher.Poll(ctx)
+	if registry.GetServer("demo-server") == nil {
+		t.Fatal("expected plugin server to be added to the registry")
+	}
+
+	// Uninstall it
+	if err := os.RemoveAll(pluginDir); err != nil {
+		t.Fatal(err)
+	}
+	if !watcher.Poll(ctx) {
+		t.Error("expected a change when the plugin is removed")
+	}
+	if registry.GetServer("demo-server") != nil {
+		t.Error("expected plugin server to be removed from the registry")
+	}
+	if registry.GetServer("configured") == nil {
+		t.Error("configured server must never be removed")
+	}
+	if changes != 1 {
+		t.Errorf("expected 1 change notification, got %d", changes)
+	}
+}
+
+func TestNewPluginWatcherDisabled(t *testing.T) {
+	t.Setenv("ARMOUR_PLUGIN_WATCH_INTERVAL", "0")
+	if w := NewPluginWatcher(nil, proxy.NewLogger("error"), nil); w != nil {
+		t.Error("expected watcher to be disabled")
+	}
+	// Start must be nil-safe
+	var w *PluginWatcher
+	w.Start(context.Background())
+}
@@ -0,0 +1,1090 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/user/mcp-go-proxy/proxy"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPolicyFile is the file name looked up by `mcp-proxy policy` commands.
+const DefaultPolicyFile = "armour.policy.yaml"
+
+// Settings keys used to persist policy-file state in the rules store.
+const (
+	settingPolicyMode      = "policy_mode"
+	settingServerAllowlist = "server_allowlist"
+	settingModeSchedule    = "mode_schedule"
+)
+
+// PolicyFile is the declarative, version-controlled form of the rules store.
+//
+//	mode: moderate
+//	mode_schedule:
+//	  - mode: strict
+//	    schedule: "Mon-Fri 19:00-07:00; Sat,Sun"
+//	servers:
+//	  allow: [github, filesystem]
+//	  approve_discovered: true
+//	  lock_packages: true
+//	  pins:
+//	    filesystem: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	packs:
+//	  - ~/.armour/blocklists.d/secrets.json
+//	trust:
+//	  plugin: ask
+//	costs:
+//	  servers:
+//	    openai: 1
+//	  budgets:
+//	    - per_day: 100
+//	      action: block
+//	egress:
+//	  deny: [10.0.0.0/8]
+//	dlp:
+//	  credit_card: redact
+//	decoys:
+//	  enabled: true
+//	tool_classes:
+//	  github:create_issue: read
+//	cedar:
+//	  policies: |
+//	    permit(principal, action, resource);
+//	authorizer:
+//	  url: https://authz.example.com/armour
+//	audit:
+//	  detail: args-on-block
+//	rewrites:
+//	  - name: bounded-queries
+//	    tools: ["db:query"]
+//	    max: {limit: 100}
+//	guard_prompts:
+//	  - classes:
+//	      delete: "Destructive; requires explicit user confirmation."
+//	    rules: true
+//	previews:
+//	  - tools: ["fs:write_file"]
+//	undo:
+//	  classes: [write]
+//	rules:
+//	  - name: no-force-push
+//	    tools: Bash
+//	    pattern: "push --force"
+//	  - name: prod-read-only
+//	    tools: tag:prod
+//	    block_all: true
+//	  - name: deploys-in-office-hours
+//	    tools: deploy*
+//	    block_all: true
+//	    schedule: "Mon-Fri 18:00-09:00; Sat,Sun"
+type PolicyFile struct {
+	Mode         string            `yaml:"mode,omitempty"`
+	ModeSchedule ModeSchedule      `yaml:"mode_schedule,omitempty"`
+	Servers      PolicyServers     `yaml:"servers,omitempty"`
+	Trust        TrustPolicy       `yaml:"trust,omitempty"`
+	Costs        CostPolicy        `yaml:"costs,omitempty"`
+	Egress       EgressPolicy      `yaml:"egress,omitempty"`
+	DLP          DLPPolicy         `yaml:"dlp,omitempty"`
+	Decoys       DecoyPolicy       `yaml:"decoys,omitempty"`
+	Classes      ToolClasses       `yaml:"tool_classes,omitempty"`
+	Cedar        CedarPolicy       `yaml:"cedar,omitempty"`
+	Authorizer   AuthorizerPolicy  `yaml:"authorizer,omitempty"`
+	Audit        AuditPolicy       `yaml:"audit,omitempty"`
+	Rewrites     RewritePolicy     `yaml:"rewrites,omitempty"`
+	GuardPrompts GuardPromptPolicy `yaml:"guard_prompts,omitempty"`
+	Previews     PreviewPolicy     `yaml:"previews,omitempty"`
+	Undo         UndoPolicy        `yaml:"undo,omitempty"`
+	Packs        []string          `yaml:"packs,omitempty"`
+	Rules        []PolicyFileRule  `yaml:"rules,omitempty"`
+}
+
+// PolicyServers lists the backends the proxy is allowed to start.
+// An empty allowlist permits every registered server. With
+// ApproveDiscovered, servers found in ~/.claude.json or plugins are held
+// back until they are approved on the dashboard. Pins pins stdio servers to
+// the hash of their binary. LockPackages locks servers launched through npx
+// or uvx to the package version they first ran.
+type PolicyServers struct {
+	Allow             []string   `yaml:"allow,omitempty"`
+	ApproveDiscovered bool       `yaml:"approve_discovered,omitempty"`
+	Pins              BinaryPins `yaml:"pins,omitempty"`
+	LockPackages      bool       `yaml:"lock_packages,omitempty"`
+}
+
+// PolicyFileRule is a rule entry in a policy file. Name is the identity used
+// to reconcile against the rules store.
+type PolicyFileRule struct {
+	Name     string `yaml:"name"`
+	Pattern  string `yaml:"pattern,omitempty"`
+	Topics   string `yaml:"topics,omitempty"`
+	Tools    string `yaml:"tools,omitempty"`
+	Scope    string `yaml:"scope,omitempty"`
+	Action   string `yaml:"action,omitempty"`
+	Regex    bool   `yaml:"regex,omitempty"`
+	Semantic bool   `yaml:"semantic,omitempty"`
+	BlockAll bool   `yaml:"block_all,omitempty"`
+	Schedule string `yaml:"schedule,omitempty"`
+	Enabled  *bool  `yaml:"enabled,omitempty"`
+}
+
+// LoadPolicyFile reads and validates a policy file.
+func LoadPolicyFile(path string) (*PolicyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	pf, err := ParsePolicyFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pf, nil
+}
+
+// ParsePolicyFile parses and validates a policy file's YAML (or JSON).
+func ParsePolicyFile(data []byte) (*PolicyFile, error) {
+	var pf PolicyFile
+	if err := yaml.Unmarshal(data, &pf); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	if err := pf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file: %w", err)
+	}
+	return &pf, nil
+}
+
+// Marshal renders the policy file as YAML.
+func (pf *PolicyFile) Marshal() ([]byte, error) {
+	return yaml.Marshal(pf)
+}
+
+// Validate checks modes, actions and rule name uniqueness.
+func (pf *PolicyFile) Validate() error {
+	switch PolicyMode(pf.Mode) {
+	case "", StrictMode, ModerateMode, PermissiveMode:
+	default:
+		return fmt.Errorf("invalid mode %q", pf.Mode)
+	}
+	if err := pf.ModeSchedule.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Servers.Pins.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Trust.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Costs.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Egress.Validate(); err != nil {
+		return err
+	}
+	if err := pf.DLP.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Decoys.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Classes.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Cedar.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Authorizer.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Audit.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Rewrites.Validate(); err != nil {
+		return err
+	}
+	if err := pf.GuardPrompts.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Previews.Validate(); err != nil {
+		return err
+	}
+	if err := pf.Undo.Validate(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for i, r := range pf.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("duplicate rule name %q", r.Name)
+		}
+		seen[r.Name] = true
+		switch r.Action {
+		case "", "block", "allow":
+		case DelegateAction:
+			if pf.Authorizer.URL == "" {
+				return fmt.Errorf("rule %q: action delegate needs an authorizer url", r.Name)
+			}
+		default:
+			return fmt.Errorf("rule %q: invalid action %q", r.Name, r.Action)
+		}
+		if _, err := ParseSchedule(r.Schedule); err != nil {
+			return fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// DesiredRules expands the file's rules and packs into store rules with defaults applied.
+func (pf *PolicyFile) DesiredRules() ([]Rule, error) {
+	var rules []Rule
+	for _, r := range pf.Rules {
+		enabled := true
+		if r.Enabled != nil {
+			enabled = *r.Enabled
+		}
+		rules = append(rules, normalizeRule(Rule{
+			Name:       r.Name,
+			Pattern:    r.Pattern,
+			Topics:     r.Topics,
+			Tools:      r.Tools,
+			Scope:      r.Scope,
+			Action:     r.Action,
+			IsRegex:    r.Regex,
+			IsSemantic: r.Semantic,
+			BlockAll:   r.BlockAll,
+			Schedule:   r.Schedule,
+			Enabled:    enabled,
+		}))
+	}
+
+	for _, src := range pf.Packs {
+		packRules, err := loadPolicyPack(src)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, packRules...)
+	}
+	return rules, nil
+}
+
+// loadPolicyPack reads a community rule pack (same JSON format as
+// ARMOUR_BLOCKLIST_SOURCES) and names each rule after the pack.
+func loadPolicyPack(src string) ([]Rule, error) {
+	path := src
+	if strings.HasPrefix(path, "~/") {
+		homeDir, _ := os.UserHomeDir()
+		path = filepath.Join(homeDir, path[2:])
+	}
+
+	data, err := fetchSource(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rule pack %s: %w", src, err)
+	}
+
+	var parsed []communityRule
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("rule pack %s is not valid JSON: %w", src, err)
+	}
+
+	packName := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	var rules []Rule
+	for i, cr := range parsed {
+		rules = append(rules, normalizeRule(Rule{
+			Name:     fmt.Sprintf("pack:%s:%d", packName, i+1),
+			Pattern:  cr.Pattern,
+			Tools:    cr.Tools,
+			Action:   strings.ToLower(cr.Action),
+			IsRegex:  cr.IsRegex,
+			BlockAll: cr.BlockAll,
+			Enabled:  true,
+		}))
+	}
+	return rules, nil
+}
+
+// normalizeRule fills in the same defaults RulesStore.Create applies.
+func normalizeRule(r Rule) Rule {
+	if r.Tools == "" {
+		r.Tools = "*"
+	}
+	if r.Scope == "" {
+		r.Scope = "all"
+	}
+	if r.Action == "" {
+		r.Action = "block"
+	}
+	return r
+}
+
+func rulesEqual(a, b Rule) bool {
+	return a.Pattern == b.Pattern && a.Topics == b.Topics && a.Tools == b.Tools &&
+		a.Scope == b.Scope && a.Action == b.Action && a.IsRegex == b.IsRegex &&
+		a.IsSemantic == b.IsSemantic && a.BlockAll == b.BlockAll && a.Schedule == b.Schedule &&
+		a.Enabled == b.Enabled
+}
+
+// PolicyDiff describes the drift between a policy file and the rules store.
+type PolicyDiff struct {
+	ModeFrom, ModeTo                 string
+	ModeScheduleFrom, ModeScheduleTo ModeSchedule
+	AllowlistFrom, AllowlistTo       string
+	ApproveDiscoveredFrom            bool
+	ApproveDiscoveredTo              bool
+	PinsFrom, PinsTo                 BinaryPins
+	LockPackagesFrom                 bool
+	LockPackagesTo                   bool
+	TrustFrom, TrustTo               TrustPolicy
+	CostsFrom, CostsTo               CostPolicy
+	EgressFrom, EgressTo             EgressPolicy
+	DLPFrom, DLPTo                   DLPPolicy
+	DecoysFrom, DecoysTo             DecoyPolicy
+	ClassesFrom, ClassesTo           ToolClasses
+	CedarFrom, CedarTo               CedarPolicy
+	AuthorizerFrom                   AuthorizerPolicy
+	AuthorizerTo                     AuthorizerPolicy
+	AuditFrom, AuditTo               AuditPolicy
+	RewritesFrom, RewritesTo         RewritePolicy
+	GuardPromptsFrom                 GuardPromptPolicy
+	GuardPromptsTo                   GuardPromptPolicy
+	PreviewsFrom, PreviewsTo         PreviewPolicy
+	UndoFrom, UndoTo                 UndoPolicy
+	Added                            []Rule
+	Changed                          []Rule // desired state; ID refers to the stored rule
+	Removed                          []Rule
+}
+
+// Empty reports whether the store already matches the file.
+func (d *PolicyDiff) Empty() bool {
+	return d.ModeFrom == d.ModeTo && d.AllowlistFrom == d.AllowlistTo &&
+		d.ApproveDiscoveredFrom == d.ApproveDiscoveredTo &&
+		d.PinsFrom.String() == d.PinsTo.String() &&
+		d.LockPackagesFrom == d.LockPackagesTo &&
+		d.ModeScheduleFrom.String() == d.ModeScheduleTo.String() &&
+		d.TrustFrom.String() == d.TrustTo.String() &&
+		d.CostsFrom.String() == d.CostsTo.String() &&
+		d.EgressFrom.String() == d.EgressTo.String() &&
+		d.DLPFrom.String() == d.DLPTo.String() &&
+		d.DecoysFrom.String() == d.DecoysTo.String() &&
+		d.ClassesFrom.String() == d.ClassesTo.String() &&
+		d.CedarFrom.String() == d.CedarTo.String() &&
+		d.AuthorizerFrom.String() == d.AuthorizerTo.String() &&
+		d.AuditFrom.String() == d.AuditTo.String() &&
+		d.RewritesFrom.String() == d.RewritesTo.String() &&
+		d.GuardPromptsFrom.String() == d.GuardPromptsTo.String() &&
+		d.PreviewsFrom.String() == d.PreviewsTo.String() &&
+		d.UndoFrom.String() == d.UndoTo.String() &&
+		len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// DiffPolicy compares the policy file against the current contents of the store.
+func DiffPolicy(store *RulesStore, pf *PolicyFile) (*PolicyDiff, error) {
+	desired, err := pf.DesiredRules()
+	if err != nil {
+		return nil, err
+	}
+	current, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &PolicyDiff{
+		ModeTo:              pf.Mode,
+		ModeScheduleTo:      pf.ModeSchedule,
+		AllowlistTo:         strings.Join(pf.Servers.Allow, ","),
+		ApproveDiscoveredTo: pf.Servers.ApproveDiscovered,
+		PinsTo:              pf.Servers.Pins,
+		LockPackagesTo:      pf.Servers.LockPackages,
+		TrustTo:             pf.Trust,
+		CostsTo:             pf.Costs,
+		EgressTo:            pf.Egress,
+		DLPTo:               pf.DLP,
+		DecoysTo:            pf.Decoys,
+		ClassesTo:           pf.Classes,
+		CedarTo:             pf.Cedar,
+		AuthorizerTo:        pf.Authorizer,
+		AuditTo:             pf.Audit,
+		RewritesTo:          pf.Rewrites,
+		GuardPromptsTo:      pf.GuardPrompts,
+		PreviewsTo:          pf.Previews,
+		UndoTo:              pf.Undo,
+	}
+	if diff.ModeFrom, err = store.GetSetting(settingPolicyMode); err != nil {
+		return nil, err
+	}
+	if diff.AllowlistFrom, err = store.GetSetting(settingServerAllowlist); err != nil {
+		return nil, err
+	}
+	if diff.ApproveDiscoveredFrom, err = loadApproveDiscoveredSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.PinsFrom, err = loadBinaryPinsSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.LockPackagesFrom, err = loadLockPackagesSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.ModeScheduleFrom, err = loadModeScheduleSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.TrustFrom, err = loadTrustSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.CostsFrom, err = loadCostSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.EgressFrom, err = loadEgressSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.DLPFrom, err = loadDLPSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.DecoysFrom, err = loadDecoySetting(store); err != nil {
+		return nil, err
+	}
+	if diff.ClassesFrom, err = loadToolClassesSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.CedarFrom, err = loadCedarSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.AuthorizerFrom, err = loadAuthorizerSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.AuditFrom, err = loadAuditSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.RewritesFrom, err = loadRewriteSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.GuardPromptsFrom, err = loadGuardPromptsSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.PreviewsFrom, err = loadPreviewSetting(store); err != nil {
+		return nil, err
+	}
+	if diff.UndoFrom, err = loadUndoSetting(store); err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]Rule)
+	// List is newest first; iterate oldest first so the oldest duplicate wins.
+	for i := len(current) - 1; i >= 0; i-- {
+		r := current[i]
+		if _, dup := byName[r.Name]; dup {
+			diff.Removed = append(diff.Removed, r)
+			continue
+		}
+		byName[r.Name] = r
+	}
+
+	for _, want := range desired {
+		have, ok := byName[want.Name]
+		if !ok {
+			diff.Added = append(diff.Added, want)
+			continue
+		}
+		delete(byName, want.Name)
+		if !rulesEqual(have, want) {
+			want.ID = have.ID
+			diff.Changed = append(diff.Changed, want)
+		}
+	}
+	for _, r := range byName {
+		diff.Removed = append(diff.Removed, r)
+	}
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].ID < diff.Removed[j].ID })
+
+	return diff, nil
+}
+
+// ApplyPolicy reconciles the store to the policy file and returns what changed.
+// It applies all of the file or, if any step fails, none of it.
+func ApplyPolicy(store *RulesStore, pf *PolicyFile) (*PolicyDiff, error) {
+	var diff *PolicyDiff
+	err := store.inTx(func(tx *RulesStore) error {
+		var err error
+		diff, err = applyPolicy(tx, pf)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+func applyPolicy(store *RulesStore, pf *PolicyFile) (*PolicyDiff, error) {
+	diff, err := DiffPolicy(store, pf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.SetSetting(settingPolicyMode, diff.ModeTo); err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingServerAllowlist, diff.AllowlistTo); err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingApproveDiscovered, encodeApproveDiscovered(diff.ApproveDiscoveredTo)); err != nil {
+		return nil, err
+	}
+	pins, err := encodeBinaryPins(diff.PinsTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingBinaryPins, pins); err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingLockPackages, encodeLockPackages(diff.LockPackagesTo)); err != nil {
+		return nil, err
+	}
+	modeSched, err := encodeModeSchedule(diff.ModeScheduleTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingModeSchedule, modeSched); err != nil {
+		return nil, err
+	}
+	trust, err := encodeTrustPolicy(diff.TrustTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingTrustPolicy, trust); err != nil {
+		return nil, err
+	}
+	costs, err := encodeCostPolicy(diff.CostsTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingCostPolicy, costs); err != nil {
+		return nil, err
+	}
+	egress, err := encodeEgressPolicy(diff.EgressTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingEgressPolicy, egress); err != nil {
+		return nil, err
+	}
+	dlp, err := encodeDLPPolicy(diff.DLPTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingDLPPolicy, dlp); err != nil {
+		return nil, err
+	}
+	decoys, err := encodeDecoyPolicy(diff.DecoysTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingDecoyPolicy, decoys); err != nil {
+		return nil, err
+	}
+	classes, err := encodeToolClasses(diff.ClassesTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingToolClasses, classes); err != nil {
+		return nil, err
+	}
+	cedar, err := encodeCedarPolicy(diff.CedarTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingCedarPolicy, cedar); err != nil {
+		return nil, err
+	}
+	authorizer, err := encodeAuthorizerPolicy(diff.AuthorizerTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingAuthorizer, authorizer); err != nil {
+		return nil, err
+	}
+	audit, err := encodeAuditPolicy(diff.AuditTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingAuditPolicy, audit); err != nil {
+		return nil, err
+	}
+	rewrites, err := encodeRewritePolicy(diff.RewritesTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingRewritePolicy, rewrites); err != nil {
+		return nil, err
+	}
+	guardPrompts, err := encodeGuardPrompts(diff.GuardPromptsTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingGuardPrompts, guardPrompts); err != nil {
+		return nil, err
+	}
+	previews, err := encodePreviewPolicy(diff.PreviewsTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingPreviewPolicy, previews); err != nil {
+		return nil, err
+	}
+	undo, err := encodeUndoPolicy(diff.UndoTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSetting(settingUndoPolicy, undo); err != nil {
+		return nil, err
+	}
+	for _, r := range diff.Removed {
+		if err := store.Delete(r.ID); err != nil {
+			return nil, err
+		}
+	}
+	for i := range diff.Changed {
+		if err := store.Update(&diff.Changed[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i := range diff.Added {
+		r := &diff.Added[i]
+		enabled := r.Enabled
+		if err := store.Create(r); err != nil {
+			return nil, err
+		}
+		if !enabled {
+			if err := store.SetEnabled(r.ID, false); err != nil {
+				return nil, err
+			}
+			r.Enabled = false
+		}
+	}
+	return diff, nil
+}
+
+// ExportPolicy builds a policy file from the current contents of the store.
+func ExportPolicy(store *RulesStore) (*PolicyFile, error) {
+	pf := &PolicyFile{}
+	var err error
+	if pf.Mode, err = store.GetSetting(settingPolicyMode); err != nil {
+		return nil, err
+	}
+	if pf.ModeSchedule, err = loadModeScheduleSetting(store); err != nil {
+		return nil, err
+	}
+	allow, err := store.GetSetting(settingServerAllowlist)
+	if err != nil {
+		return nil, err
+	}
+	if allow != "" {
+		pf.Servers.Allow = strings.Split(allow, ",")
+	}
+	if pf.Servers.ApproveDiscovered, err = loadApproveDiscoveredSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Servers.Pins, err = loadBinaryPinsSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Servers.LockPackages, err = loadLockPackagesSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Trust, err = loadTrustSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Costs, err = loadCostSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Egress, err = loadEgressSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.DLP, err = loadDLPSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Decoys, err = loadDecoySetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Classes, err = loadToolClassesSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Cedar, err = loadCedarSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Authorizer, err = loadAuthorizerSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Audit, err = loadAuditSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Rewrites, err = loadRewriteSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.GuardPrompts, err = loadGuardPromptsSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Previews, err = loadPreviewSetting(store); err != nil {
+		return nil, err
+	}
+	if pf.Undo, err = loadUndoSetting(store); err != nil {
+		return nil, err
+	}
+
+	rules, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(rules) - 1; i >= 0; i-- {
+		r := rules[i]
+		entry := PolicyFileRule{
+			Name:     r.Name,
+			Pattern:  r.Pattern,
+			Topics:   r.Topics,
+			Regex:    r.IsRegex,
+			Semantic: r.IsSemantic,
+			BlockAll: r.BlockAll,
+			Schedule: r.Schedule,
+		}
+		if r.Tools != "*" {
+			entry.Tools = r.Tools
+		}
+		if r.Scope != "all" {
+			entry.Scope = r.Scope
+		}
+		if r.Action != "block" {
+			entry.Action = r.Action
+		}
+		if !r.Enabled {
+			disabled := false
+			entry.Enabled = &disabled
+		}
+		pf.Rules = append(pf.Rules, entry)
+	}
+	return pf, nil
+}
+
+// StoredPolicy is the subset of an applied policy file the proxy honours at startup.
+type StoredPolicy struct {
+	Mode              PolicyMode
+	ModeSchedule      ModeSchedule
+	AllowedServers    []string
+	ApproveDiscovered bool
+	BinaryPins        BinaryPins
+	LockPackages      bool
+	Trust             TrustPolicy
+	Costs             CostPolicy
+	Egress            EgressPolicy
+	DLP               DLPPolicy
+	Decoys            DecoyPolicy
+	Classes           ToolClasses
+	Cedar             CedarPolicy
+	Authorizer        AuthorizerPolicy
+	Audit             AuditPolicy
+	Rewrites          RewritePolicy
+	GuardPrompts      GuardPromptPolicy
+	Previews          PreviewPolicy
+	Undo              UndoPolicy
+}
+
+// LoadStoredPolicy reads the applied policy settings from the rules store.
+func LoadStoredPolicy(store *RulesStore) (*StoredPolicy, error) {
+	mode, err := store.GetSetting(settingPolicyMode)
+	if err != nil {
+		return nil, err
+	}
+	allow, err := store.GetSetting(settingServerAllowlist)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &StoredPolicy{Mode: PolicyMode(mode)}
+	if allow != "" {
+		sp.AllowedServers = strings.Split(allow, ",")
+	}
+	if sp.ApproveDiscovered, err = loadApproveDiscoveredSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.BinaryPins, err = loadBinaryPinsSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.LockPackages, err = loadLockPackagesSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.ModeSchedule, err = loadModeScheduleSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Trust, err = loadTrustSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Costs, err = loadCostSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Egress, err = loadEgressSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.DLP, err = loadDLPSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Decoys, err = loadDecoySetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Classes, err = loadToolClassesSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Cedar, err = loadCedarSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Authorizer, err = loadAuthorizerSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Audit, err = loadAuditSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Rewrites, err = loadRewriteSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.GuardPrompts, err = loadGuardPromptsSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Previews, err = loadPreviewSetting(store); err != nil {
+		return nil, err
+	}
+	if sp.Undo, err = loadUndoSetting(store); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+func loadModeScheduleSetting(store *RulesStore) (ModeSchedule, error) {
+	raw, err := store.GetSetting(settingModeSchedule)
+	if err != nil {
+		return nil, err
+	}
+	return decodeModeSchedule(raw)
+}
+
+func loadTrustSetting(store *RulesStore) (TrustPolicy, error) {
+	raw, err := store.GetSetting(settingTrustPolicy)
+	if err != nil {
+		return TrustPolicy{}, err
+	}
+	return decodeTrustPolicy(raw)
+}
+
+func loadCostSetting(store *RulesStore) (CostPolicy, error) {
+	raw, err := store.GetSetting(settingCostPolicy)
+	if err != nil {
+		return CostPolicy{}, err
+	}
+	return decodeCostPolicy(raw)
+}
+
+func loadEgressSetting(store *RulesStore) (EgressPolicy, error) {
+	raw, err := store.GetSetting(settingEgressPolicy)
+	if err != nil {
+		return EgressPolicy{}, err
+	}
+	return decodeEgressPolicy(raw)
+}
+
+func loadDLPSetting(store *RulesStore) (DLPPolicy, error) {
+	raw, err := store.GetSetting(settingDLPPolicy)
+	if err != nil {
+		return DLPPolicy{}, err
+	}
+	return decodeDLPPolicy(raw)
+}
+
+func loadDecoySetting(store *RulesStore) (DecoyPolicy, error) {
+	raw, err := store.GetSetting(settingDecoyPolicy)
+	if err != nil {
+		return DecoyPolicy{}, err
+	}
+	return decodeDecoyPolicy(raw)
+}
+
+func loadToolClassesSetting(store *RulesStore) (ToolClasses, error) {
+	raw, err := store.GetSetting(settingToolClasses)
+	if err != nil {
+		return nil, err
+	}
+	return decodeToolClasses(raw)
+}
+
+func loadCedarSetting(store *RulesStore) (CedarPolicy, error) {
+	raw, err := store.GetSetting(settingCedarPolicy)
+	if err != nil {
+		return CedarPolicy{}, err
+	}
+	return decodeCedarPolicy(raw)
+}
+
+func loadAuthorizerSetting(store *RulesStore) (AuthorizerPolicy, error) {
+	raw, err := store.GetSetting(settingAuthorizer)
+	if err != nil {
+		return AuthorizerPolicy{}, err
+	}
+	return decodeAuthorizerPolicy(raw)
+}
+
+func loadAuditSetting(store *RulesStore) (AuditPolicy, error) {
+	raw, err := store.GetSetting(settingAuditPolicy)
+	if err != nil {
+		return AuditPolicy{}, err
+	}
+	return decodeAuditPolicy(raw)
+}
+
+func loadRewriteSetting(store *RulesStore) (RewritePolicy, error) {
+	raw, err := store.GetSetting(settingRewritePolicy)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRewritePolicy(raw)
+}
+
+func loadGuardPromptsSetting(store *RulesStore) (GuardPromptPolicy, error) {
+	raw, err := store.GetSetting(settingGuardPrompts)
+	if err != nil {
+		return nil, err
+	}
+	return decodeGuardPrompts(raw)
+}
+
+func loadPreviewSetting(store *RulesStore) (PreviewPolicy, error) {
+	raw, err := store.GetSetting(settingPreviewPolicy)
+	if err != nil {
+		return nil, err
+	}
+	return decodePreviewPolicy(raw)
+}
+
+func loadUndoSetting(store *RulesStore) (UndoPolicy, error) {
+	raw, err := store.GetSetting(settingUndoPolicy)
+	if err != nil {
+		return UndoPolicy{}, err
+	}
+	return decodeUndoPolicy(raw)
+}
+
+func loadApproveDiscoveredSetting(store *RulesStore) (bool, error) {
+	raw, err := store.GetSetting(settingApproveDiscovered)
+	return raw == "true", err
+}
+
+func loadBinaryPinsSetting(store *RulesStore) (BinaryPins, error) {
+	raw, err := store.GetSetting(settingBinaryPins)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBinaryPins(raw)
+}
+
+func loadLockPackagesSetting(store *RulesStore) (bool, error) {
+	raw, err := store.GetSetting(settingLockPackages)
+	return raw == "true", err
+}
+
+// FormatPolicyDiff renders a diff in a `+`/`~`/`-` style for the terminal.
+func FormatPolicyDiff(d *PolicyDiff) string {
+	if d.Empty() {
+		return "No drift: rules store matches the policy file."
+	}
+
+	var b strings.Builder
+	if d.ModeFrom != d.ModeTo {
+		fmt.Fprintf(&b, "~ mode: %q -> %q\n", d.ModeFrom, d.ModeTo)
+	}
+	if from, to := d.ModeScheduleFrom.String(), d.ModeScheduleTo.String(); from != to {
+		fmt.Fprintf(&b, "~ mode_schedule: %q -> %q\n", from, to)
+	}
+	if d.AllowlistFrom != d.AllowlistTo {
+		fmt.Fprintf(&b, "~ servers.allow: %q -> %q\n", d.AllowlistFrom, d.AllowlistTo)
+	}
+	if d.ApproveDiscoveredFrom != d.ApproveDiscoveredTo {
+		fmt.Fprintf(&b, "~ servers.approve_discovered: %t -> %t\n", d.ApproveDiscoveredFrom, d.ApproveDiscoveredTo)
+	}
+	if from, to := d.PinsFrom.String(), d.PinsTo.String(); from != to {
+		fmt.Fprintf(&b, "~ servers.pins: %q -> %q\n", from, to)
+	}
+	if d.LockPackagesFrom != d.LockPackagesTo {
+		fmt.Fprintf(&b, "~ servers.lock_packages: %t -> %t\n", d.LockPackagesFrom, d.LockPackagesTo)
+	}
+	if from, to := d.TrustFrom.String(), d.TrustTo.String(); from != to {
+		fmt.Fprintf(&b, "~ trust: %q -> %q\n", from, to)
+	}
+	if from, to := d.CostsFrom.String(), d.CostsTo.String(); from != to {
+		fmt.Fprintf(&b, "~ costs: %q -> %q\n", from, to)
+	}
+	if from, to := d.EgressFrom.String(), d.EgressTo.String(); from != to {
+		fmt.Fprintf(&b, "~ egress: %q -> %q\n", from, to)
+	}
+	if from, to := d.DLPFrom.String(), d.DLPTo.String(); from != to {
+		fmt.Fprintf(&b, "~ dlp: %q -> %q\n", from, to)
+	}
+	if from, to := d.DecoysFrom.String(), d.DecoysTo.String(); from != to {
+		fmt.Fprintf(&b, "~ decoys: %q -> %q\n", from, to)
+	}
+	if from, to := d.ClassesFrom.String(), d.ClassesTo.String(); from != to {
+		fmt.Fprintf(&b, "~ tool_classes: %q -> %q\n", from, to)
+	}
+	if from, to := d.CedarFrom.String(), d.CedarTo.String(); from != to {
+		fmt.Fprintf(&b, "~ cedar: %q -> %q\n", from, to)
+	}
+	if from, to := d.AuthorizerFrom.String(), d.AuthorizerTo.String(); from != to {
+		fmt.Fprintf(&b, "~ authorizer: %q -> %q\n", from, to)
+	}
+	if from, to := d.AuditFrom.String(), d.AuditTo.String(); from != to {
+		fmt.Fprintf(&b, "~ audit: %q -> %q\n", from, to)
+	}
+	if from, to := d.RewritesFrom.String(), d.RewritesTo.String(); from != to {
+		fmt.Fprintf(&b, "~ rewrites: %q -> %q\n", from, to)
+	}
+	if from, to := d.GuardPromptsFrom.String(), d.GuardPromptsTo.String(); from != to {
+		fmt.Fprintf(&b, "~ guard_prompts: %q -> %q\n", from, to)
+	}
+	if from, to := d.PreviewsFrom.String(), d.PreviewsTo.String(); from != to {
+		fmt.Fprintf(&b, "~ previews: %q -> %q\n", from, to)
+	}
+	if from, to := d.UndoFrom.String(), d.UndoTo.String(); from != to {
+		fmt.Fprintf(&b, "~ undo: %q -> %q\n", from, to)
+	}
+	for _, r := range d.Added {
+		fmt.Fprintf(&b, "+ rule %s (%s %s)\n", r.Name, r.Action, r.Tools)
+	}
+	for _, r := range d.Changed {
+		fmt.Fprintf(&b, "~ rule %s (id %d)\n", r.Name, r.ID)
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(&b, "- rule %s (id %d)\n", r.Name, r.ID)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// FilterRegistry drops servers that are not on the allowlist and returns their names.
+func (sp *StoredPolicy) FilterRegistry(registry *proxy.ServerRegistry) []string {
+	if len(sp.AllowedServers) == 0 || registry == nil {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(sp.AllowedServers))
+	for _, name := range sp.AllowedServers {
+		allowed[strings.TrimSpace(name)] = true
+	}
+
+	var kept []proxy.ServerEntry
+	var dropped []string
+	for _, entry := range registry.Servers {
+		if allowed[entry.Name] {
+			kept = append(kept, entry)
+		} else {
+			dropped = append(dropped, entry.Name)
+		}
+	}
+	registry.Servers = kept
+	return dropped
+}
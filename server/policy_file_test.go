@@ -0,0 +1,155 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestApplyPolicyRollsBack tests that a policy file that fails to apply
+// part way through leaves the store as it was
+func TestApplyPolicyRollsBack(t *testing.T) {
+	store, err := OpenRulesStore(filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("Failed to open rules store: %v", err)
+	}
+	defer store.Close()
+	if err := store.Create(&Rule{Name: "ad-hoc", Pattern: "x"}); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	// Creating rules comes after the settings and the removals
+	if _, err := store.db.Exec(`CREATE TRIGGER fail_insert BEFORE INSERT ON rules BEGIN SELECT RAISE(ABORT, 'injected failure'); END`); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+	pf := &PolicyFile{
+		Mode:    "strict",
+		Servers: PolicyServers{Allow: []string{"github"}},
+		Rules:   []PolicyFileRule{{Name: "no-force-push", Tools: "Bash", Pattern: "push --force"}},
+	}
+	if _, err := ApplyPolicy(store, pf); err == nil {
+		t.Fatal("Expected the apply to fail")
+	}
+
+	if mode, _ := store.GetSetting(settingPolicyMode); mode != "" {
+		t.Errorf("Expected the mode left unset, got %q", mode)
+	}
+	if allow, _ := store.GetSetting(settingServerAllowlist); allow != "" {
+		t.Errorf("Expected the allowlist left unset, got %q", allow)
+	}
+	rules, err := store.List()
+	if err != nil || len(rules) != 1 || rules[0].Name != "ad-hoc" {
+		t.Errorf("Expected only the ad-hoc rule, got %+v (err=%v)", rules, err)
+	}
+}
+
+// TestPolicyFileApplyAndDiff tests reconciling the rules store to a policy file
+func TestPolicyFileApplyAndDiff(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenRulesStore(filepath.Join(dir, "rules.db"))
+	if err != nil {
+		t.Fatalf("Failed to open rules store: %v", err)
+	}
+	defer store.Close()
+
+	// A rule created through the UI that the file does not know about
+	if err := store.Create(&Rule{Name: "ad-hoc", Pattern: "x"}); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	policyPath := filepath.Join(dir, DefaultPolicyFile)
+	writePolicy := func(content string) *PolicyFile {
+		if err := os.WriteFile(policyPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write policy file: %v", err)
+		}
+		pf, err := LoadPolicyFile(policyPath)
+		if err != nil {
+			t.Fatalf("Failed to load policy file: %v", err)
+		}
+		return pf
+	}
+
+	pf := writePolicy(`
+mode: strict
+servers:
+  allow: [github]
+rules:
+  - name: no-force-push
+    tools: Bash
+    pattern: "push --force"
+`)
+
+	diff, err := ApplyPolicy(store, pf)
+	if err != nil {
+		t.Fatalf("Failed to apply policy: %v", err)
+	}
+	if len(diff.Added) != 1 || len(diff.Removed) != 1 || diff.ModeTo != "strict" {
+		t.Errorf("Unexpected diff on first apply: %+v", diff)
+	}
+
+	diff, err = DiffPolicy(store, pf)
+	if err != nil || !diff.Empty() {
+		t.Fatalf("Expected no drift after apply, got %+v (err=%v)", diff, err)
+	}
+
+	sp, err := LoadStoredPolicy(store)
+	if err != nil || sp.Mode != StrictMode || len(sp.AllowedServers) != 1 {
+		t.Errorf("Expected stored mode and allowlist, got %+v (err=%v)", sp, err)
+	}
+
+	pf = writePolicy(`
+mode: strict
+servers:
+  allow: [github]
+rules:
+  - name: no-force-push
+    tools: Bash
+    pattern: "push -f"
+`)
+	diff, _ = DiffPolicy(store, pf)
+	if len(diff.Changed) != 1 || len(diff.Added) != 0 || len(diff.Removed) != 0 {
+		t.Errorf("Expected one changed rule, got %+v", diff)
+	}
+
+	// Schedules on rules and modes are applied and exported
+	pf = writePolicy(`
+mode: strict
+mode_schedule:
+  - mode: permissive
+    schedule: "Mon-Fri 09:00-17:00"
+servers:
+  allow: [github]
+rules:
+  - name: no-force-push
+    tools: Bash
+    pattern: "push -f"
+    schedule: "Sat,Sun"
+`)
+	if _, err := ApplyPolicy(store, pf); err != nil {
+		t.Fatalf("Failed to apply policy: %v", err)
+	}
+	exported, err := ExportPolicy(store)
+	if err != nil || len(exported.ModeSchedule) != 1 || len(exported.Rules) != 1 || exported.Rules[0].Schedule != "Sat,Sun" {
+		t.Errorf("Expected schedules exported, got %+v (err=%v)", exported, err)
+	}
+	if sp, _ := LoadStoredPolicy(store); sp == nil || sp.ModeSchedule.String() != "permissive@Mon-Fri 09:00-17:00" {
+		t.Errorf("Expected the stored mode schedule, got %+v", sp)
+	}
+}
+
+// TestPolicyFileValidate tests rejection of malformed policy files
+func TestPolicyFileValidate(t *testing.T) {
+	cases := []PolicyFile{
+		{Mode: "paranoid"},
+		{Rules: []PolicyFileRule{{Pattern: "x"}}},
+		{Rules: []PolicyFileRule{{Name: "a"}, {Name: "a"}}},
+		{Rules: []PolicyFileRule{{Name: "a", Action: "ask"}}},
+		{Rules: []PolicyFileRule{{Name: "a", Schedule: "weekdays"}}},
+		{ModeSchedule: ModeSchedule{{Mode: StrictMode}}},
+	}
+	for i, pf := range cases {
+		if err := pf.Validate(); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
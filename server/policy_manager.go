@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/user/mcp-go-proxy/proxy"
 )
@@ -34,6 +35,7 @@ type PolicyManager struct {
 	blockedTools      map[string]bool      // Tools blocked by this policy
 	allowedOperations map[string]bool      // Allowed operations (if in restrictive mode)
 	stats             *StatsTracker        // Track blocked/allowed calls
+	schedule          ModeSchedule         // Modes that override mode at set times
 	mu                sync.RWMutex
 }
 
@@ -61,14 +63,43 @@ func (pm *PolicyManager) SetMode(mode PolicyMode) error {
 	}
 }
 
-// GetMode returns the current policy mode.
+// GetMode returns the policy mode in force: the scheduled mode while one
+// is active, otherwise the mode set with SetMode.
 func (pm *PolicyManager) GetMode() PolicyMode {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
+	if mode, ok := pm.schedule.ModeAt(time.Now()); ok {
+		return mode
+	}
 	return pm.mode
 }
 
+// GetBaseMode returns the mode set with SetMode, which applies outside the
+// scheduled modes.
+func (pm *PolicyManager) GetBaseMode() PolicyMode {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	return pm.mode
+}
+
+// SetModeSchedule sets the modes that override the base mode at set times.
+func (pm *PolicyManager) SetModeSchedule(schedule ModeSchedule) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	pm.schedule = schedule
+}
+
+// GetModeSchedule returns the scheduled modes.
+func (pm *PolicyManager) GetModeSchedule() ModeSchedule {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	return pm.schedule
+}
+
 // BlockTool adds a tool to the blocklist for this policy.
 // Tool names can use wildcards: "rm*" matches "rm_file", "rmdir", etc.
 func (pm *PolicyManager) BlockTool(toolPattern string) {
@@ -97,8 +128,8 @@ func (pm *PolicyManager) AllowOperation(operation string) {
 // ApplyToRequest validates and potentially modifies a request based on the current policy.
 // Returns an error if the policy blocks the request.
 func (pm *PolicyManager) ApplyToRequest(req JSONRPCRequest, backendID string) error {
+	mode := pm.GetMode()
 	pm.mu.RLock()
-	mode := pm.mode
 	blockedTools := pm.blockedTools
 	stats := pm.stats
 	pm.mu.RUnlock()
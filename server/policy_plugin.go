@@ -0,0 +1,267 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Decisions a policy checker returns.
+const (
+	PolicyAllow = "allow"
+	PolicyBlock = "block"
+	PolicyAsk   = "ask" // the user must approve the call
+)
+
+// DefaultPluginTimeout is how long an external policy checker may take to
+// answer before the call is blocked.
+const DefaultPluginTimeout = 2 * time.Second
+
+// PolicyCall is the tool call a policy checker decides on.
+type PolicyCall struct {
+	Server    string                 `json:"server"`
+	Tool      string                 `json:"tool"` // namespaced, e.g. github:create_issue
+	Arguments map[string]interface{} `json:"arguments"`
+	SessionID string                 `json:"session_id,omitempty"`
+	Transport string                 `json:"transport"` // stdio or http
+	Host      *HostContext           `json:"host,omitempty"`
+	Repo      *RepoContext           `json:"repo,omitempty"` // stdio sessions only
+}
+
+// PolicyVerdict is a policy checker's decision on a call.
+type PolicyVerdict struct {
+	Decision string `json:"decision"` // PolicyAllow, PolicyBlock or PolicyAsk
+	Reason   string `json:"reason,omitempty"`
+	Rule     string `json:"rule,omitempty"` // the checker's ID for the rule that decided, for the audit log
+}
+
+// PolicyChecker is an organization-specific check every tool call must pass
+// after the built-in ones. An error blocks the call.
+type PolicyChecker interface {
+	Name() string
+	Check(ctx context.Context, call PolicyCall) (PolicyVerdict, error)
+}
+
+var (
+	policyCheckersMu sync.RWMutex
+	policyCheckers   []PolicyChecker
+)
+
+// RegisterPolicyChecker adds a compiled-in policy checker to every proxy
+// created afterwards; call it from an init function.
+func RegisterPolicyChecker(c PolicyChecker) {
+	policyCheckersMu.Lock()
+	defer policyCheckersMu.Unlock()
+	policyCheckers = append(policyCheckers, c)
+}
+
+// newPolicyCheckers returns the registered checkers followed by those the
+// config asks for: one per external plugin command, then OPA.
+func newPolicyCheckers(config Config) ([]PolicyChecker, error) {
+	policyCheckersMu.RLock()
+	checkers := append([]PolicyChecker(nil), policyCheckers...)
+	policyCheckersMu.RUnlock()
+	for _, command := range config.PolicyPlugins {
+		if isWASMModule(command) {
+			wasm, err := NewWASMPolicyChecker(command, DefaultWASMMemoryLimit, DefaultWASMTimeout)
+			if err != nil {
+				closePolicyCheckers(checkers)
+				return nil, err
+			}
+			checkers = append(checkers, wasm)
+			continue
+		}
+		checkers = append(checkers, NewExternalPolicyChecker(command, DefaultPluginTimeout))
+	}
+	if config.OPAURL != "" {
+		opa, err := NewOPAPolicyChecker(config.OPAURL, DefaultPluginTimeout)
+		if err != nil {
+			closePolicyCheckers(checkers)
+			return nil, err
+		}
+		checkers = append(checkers, opa)
+	}
+	return checkers, nil
+}
+
+// closePolicyCheckers stops the external plugins and WASM modules among checkers.
+func closePolicyCheckers(checkers []PolicyChecker) {
+	for _, c := range checkers {
+		switch c := c.(type) {
+		case *ExternalPolicyChecker:
+			c.Close()
+		case *WASMPolicyChecker:
+			c.Close()
+		}
+	}
+}
+
+// runPolicyCheckers asks each checker in turn and returns the first verdict
+// that isn't allow, with the checker's name, or an allow verdict.
+func runPolicyCheckers(ctx context.Context, checkers []PolicyChecker, call PolicyCall) (string, PolicyVerdict) {
+	for _, c := range checkers {
+		verdict, err := c.Check(ctx, call)
+		if err != nil {
+			return c.Name(), PolicyVerdict{Decision: PolicyBlock, Reason: fmt.Sprintf("policy checker failed: %v", err)}
+		}
+		switch verdict.Decision {
+		case PolicyAllow:
+			continue
+		case PolicyBlock, PolicyAsk:
+			return c.Name(), verdict
+		default:
+			return c.Name(), PolicyVerdict{Decision: PolicyBlock, Reason: fmt.Sprintf("policy checker returned unknown decision %q", verdict.Decision)}
+		}
+	}
+	return "", PolicyVerdict{Decision: PolicyAllow}
+}
+
+// pluginBlock describes a call a policy checker blocked or wants approved.
+func pluginBlock(checker, toolName string, verdict PolicyVerdict) *BlockError {
+	reason := verdict.Reason
+	if reason == "" {
+		reason = "denied by policy checker"
+	}
+	if verdict.Rule != "" {
+		reason += " (rule " + verdict.Rule + ")"
+	}
+	return &BlockError{
+		BlockedBy: BlockedByPlugin,
+		Action:    verdict.Decision,
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    checker + ": " + reason,
+	}
+}
+
+// pluginMatch is what the audit log records as the match of a checker's
+// verdict: the checker's rule ID if it gave one, else the block reason.
+func pluginMatch(checker string, verdict PolicyVerdict, block *BlockError) string {
+	if verdict.Rule != "" {
+		return checker + ":" + verdict.Rule
+	}
+	return block.Reason
+}
+
+// ExternalPolicyChecker runs a policy checker as a separate process that
+// speaks JSON lines over stdio: for each call it reads
+//
+//	{"id":1,"call":{"server":"github","tool":"github:create_issue","arguments":{...},"transport":"stdio"}}
+//
+// and writes back {"id":1,"decision":"allow|block|ask","reason":"...","rule":"..."}. The
+// process is started on the first call and restarted if it exits.
+type ExternalPolicyChecker struct {
+	command string
+	timeout time.Duration
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan []byte // the plugin's stdout; closed when it exits
+	seq   int64
+}
+
+// NewExternalPolicyChecker creates a checker for a plugin executable.
+func NewExternalPolicyChecker(command string, timeout time.Duration) *ExternalPolicyChecker {
+	return &ExternalPolicyChecker{command: command, timeout: timeout}
+}
+
+func (c *ExternalPolicyChecker) Name() string {
+	return filepath.Base(c.command)
+}
+
+func (c *ExternalPolicyChecker) Check(ctx context.Context, call PolicyCall) (PolicyVerdict, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmd == nil {
+		if err := c.start(); err != nil {
+			return PolicyVerdict{}, err
+		}
+	}
+
+	c.seq++
+	line, _ := json.Marshal(map[string]interface{}{"id": c.seq, "call": call})
+	if _, err := c.stdin.Write(append(line, '\n')); err != nil {
+		c.stop()
+		return PolicyVerdict{}, fmt.Errorf("failed to write to plugin: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	for {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				c.stop()
+				return PolicyVerdict{}, fmt.Errorf("plugin exited")
+			}
+			var resp struct {
+				ID int64 `json:"id"`
+				PolicyVerdict
+			}
+			if err := json.Unmarshal(line, &resp); err != nil {
+				return PolicyVerdict{}, fmt.Errorf("invalid plugin response: %w", err)
+			}
+			if resp.ID != c.seq {
+				continue // the late answer to a call that timed out
+			}
+			return resp.PolicyVerdict, nil
+		case <-ctx.Done():
+			return PolicyVerdict{}, fmt.Errorf("no answer within %v", c.timeout)
+		}
+	}
+}
+
+func (c *ExternalPolicyChecker) start() error {
+	cmd := exec.Command(c.command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin: %w", err)
+	}
+
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+		cmd.Wait()
+	}()
+	c.cmd, c.stdin, c.lines = cmd, stdin, lines
+	return nil
+}
+
+// stop kills the plugin; the next call starts it again.
+func (c *ExternalPolicyChecker) stop() {
+	if c.cmd == nil {
+		return
+	}
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	for range c.lines {
+	}
+	c.cmd = nil
+}
+
+// Close stops the plugin process.
+func (c *ExternalPolicyChecker) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stop()
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// funcChecker is a compiled-in policy checker for tests.
+type funcChecker struct {
+	name  string
+	check func(PolicyCall) (PolicyVerdict, error)
+}
+
+func (c funcChecker) Name() string { return c.name }
+
+func (c funcChecker) Check(ctx context.Context, call PolicyCall) (PolicyVerdict, error) {
+	return c.check(call)
+}
+
+func TestRunPolicyCheckers(t *testing.T) {
+	allow := funcChecker{"allow", func(PolicyCall) (PolicyVerdict, error) {
+		return PolicyVerdict{Decision: PolicyAllow}, nil
+	}}
+	noDeletes := funcChecker{"no-deletes", func(call PolicyCall) (PolicyVerdict, error) {
+		if strings.HasSuffix(call.Tool, ":delete_repo") {
+			return PolicyVerdict{Decision: PolicyBlock, Reason: "deletes need a change ticket"}, nil
+		}
+		return PolicyVerdict{Decision: PolicyAllow}, nil
+	}}
+	broken := funcChecker{"broken", func(PolicyCall) (PolicyVerdict, error) {
+		return PolicyVerdict{}, errors.New("unreachable")
+	}}
+	confused := funcChecker{"confused", func(PolicyCall) (PolicyVerdict, error) {
+		return PolicyVerdict{Decision: "maybe"}, nil
+	}}
+
+	tests := []struct {
+		name     string
+		checkers []PolicyChecker
+		tool     string
+		checker  string
+		decision string
+	}{
+		{"no checkers", nil, "github:delete_repo", "", PolicyAllow},
+		{"all allow", []PolicyChecker{allow, noDeletes}, "github:list_repos", "", PolicyAllow},
+		{"first denial wins", []PolicyChecker{allow, noDeletes, broken}, "github:delete_repo", "no-deletes", PolicyBlock},
+		{"errors block", []PolicyChecker{allow, broken}, "github:list_repos", "broken", PolicyBlock},
+		{"unknown decisions block", []PolicyChecker{confused}, "github:list_repos", "confused", PolicyBlock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, verdict := runPolicyCheckers(context.Background(), tt.checkers, PolicyCall{Server: "github", Tool: tt.tool})
+			if checker != tt.checker || verdict.Decision != tt.decision {
+				t.Errorf("expected %q from %q, got %q from %q (%s)", tt.decision, tt.checker, verdict.Decision, checker, verdict.Reason)
+			}
+		})
+	}
+}
+
+func TestExternalPolicyChecker(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin script needs a POSIX shell")
+	}
+	// Requests are {"call":{...},"id":N}: the id is the last field
+	script := `#!/bin/sh
+while read -r line; do
+  id=$(echo "$line" | sed 's/.*"id":\([0-9]*\).*/\1/')
+  case "$line" in
+    *crash*) exit 1 ;;
+    *delete*) echo "{\"id\":$id,\"decision\":\"block\",\"reason\":\"no deletes\"}" ;;
+    *) echo "{\"id\":$id,\"decision\":\"allow\"}" ;;
+  esac
+done
+`
+	path := filepath.Join(t.TempDir(), "no-deletes")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := NewExternalPolicyChecker(path, 5*time.Second)
+	defer c.Close()
+
+	check := func(tool string) (PolicyVerdict, error) {
+		return c.Check(context.Background(), PolicyCall{Server: "github", Tool: tool, Transport: "stdio"})
+	}
+	if v, err := check("github:list_repos"); err != nil || v.Decision != PolicyAllow {
+		t.Errorf("expected allow, got %+v, %v", v, err)
+	}
+	if v, err := check("github:delete_repo"); err != nil || v.Decision != PolicyBlock || v.Reason != "no deletes" {
+		t.Errorf("expected block, got %+v, %v", v, err)
+	}
+	if _, err := check("github:crash"); err == nil {
+		t.Error("expected an error when the plugin exits")
+	}
+	// The plugin is restarted for the next call
+	if v, err := check("github:list_repos"); err != nil || v.Decision != PolicyAllow {
+		t.Errorf("expected allow after restart, got %+v, %v", v, err)
+	}
+}
+
+func TestCheckRequestPolicyPlugin(t *testing.T) {
+	s, err := NewServer(Config{
+		Mode:     "http",
+		LogLevel: "error",
+		DBPath:   filepath.Join(t.TempDir(), "armour.db"),
+		Registry: &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "github", Transport: "http", URL: "http://127.0.0.1:1"}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer s.Close()
+	s.checkers = []PolicyChecker{funcChecker{"reviewer", func(call PolicyCall) (PolicyVerdict, error) {
+		if call.Transport != "http" || call.SessionID != "s1" {
+			t.Errorf("unexpected call context %+v", call)
+		}
+		switch call.Tool {
+		case "github:delete_repo":
+			return PolicyVerdict{Decision: PolicyBlock, Reason: "no deletes"}, nil
+		case "github:merge_pr":
+			return PolicyVerdict{Decision: PolicyAsk, Reason: "merges need a second pair of eyes"}, nil
+		}
+		return PolicyVerdict{Decision: PolicyAllow}, nil
+	}}}
+
+	call := func(tool string) *BlockError {
+		t.Helper()
+		req := JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: []byte(`{"name":"` + tool + `","arguments":{}}`)}
+		block, err := s.CheckRequest("github", "s1", req)
+		if err != nil {
+			t.Fatalf("check failed: %v", err)
+		}
+		return block
+	}
+	if block := call("list_repos"); block != nil {
+		t.Errorf("expected list_repos to be allowed, got %+v", block)
+	}
+	if block := call("delete_repo"); block == nil || block.BlockedBy != BlockedByPlugin || block.Reason != "reviewer: no deletes" {
+		t.Errorf("expected a plugin block, got %+v", block)
+	}
+	block := call("merge_pr")
+	if block == nil || block.Action != PolicyAsk || !strings.Contains(block.Reason, "not available in HTTP mode") {
+		t.Errorf("expected ask to block in HTTP mode, got %+v", block)
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SimulateRequest asks how a proposed policy file would decide audited tool
+// calls compared to a base rule set.
+type SimulateRequest struct {
+	Policy  string       `json:"policy"`         // proposed policy file (YAML or JSON)
+	Base    string       `json:"base,omitempty"` // base policy file; the rules store if empty
+	Entries []AuditEntry `json:"entries"`        // audit entries to replay, oldest first
+}
+
+// PolicySimulation reports the calls a proposed rule set decides differently
+// from the base rule set, so a policy change can be reviewed like code.
+type PolicySimulation struct {
+	Calls            int                      `json:"calls"`
+	WithoutArguments int                      `json:"without_arguments"` // calls whose arguments the audit log didn't keep
+	NewlyBlocked     int                      `json:"newly_blocked"`     // allowed before, blocked or held for approval now
+	NewlyAllowed     int                      `json:"newly_allowed"`
+	Changes          []PolicySimulationChange `json:"changes"`
+}
+
+// PolicySimulationChange is an audited call whose decision changes.
+type PolicySimulationChange struct {
+	AuditID   int64         `json:"audit_id"`
+	Timestamp time.Time     `json:"timestamp"`
+	SessionID string        `json:"session_id,omitempty"`
+	Tool      string        `json:"tool"`
+	Arguments string        `json:"arguments,omitempty"`
+	Change    string        `json:"change"` // newly_blocked, newly_allowed or changed (block <-> ask)
+	From      CheckResponse `json:"from"`
+	To        CheckResponse `json:"to"`
+}
+
+// NewPolicySnapshot returns a rules server holding the rules and settings of
+// a policy file in memory, to evaluate calls against without touching the
+// rules store.
+func NewPolicySnapshot(pf *PolicyFile, apiKey string) (*RulesServer, error) {
+	db, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	store, err := NewRulesStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := ApplyPolicy(store, pf); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &RulesServer{db: db, store: store, apiKey: apiKey}, nil
+}
+
+// Simulate replays the tool calls of req.Entries against the base rule set
+// and the proposed policy file, without running them, and reports the
+// decisions that differ. Calls are checked in the mcp scope with the
+// arguments the audit log kept; content rules can't match calls whose
+// arguments weren't kept, and rules that target tags don't match.
+func (rs *RulesServer) Simulate(ctx context.Context, req SimulateRequest) (*PolicySimulation, error) {
+	pf, err := ParsePolicyFile([]byte(req.Policy))
+	if err != nil {
+		return nil, fmt.Errorf("proposed policy: %w", err)
+	}
+	proposed, err := NewPolicySnapshot(pf, rs.apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("proposed policy: %w", err)
+	}
+	defer proposed.db.Close()
+
+	base := rs
+	if req.Base != "" {
+		pf, err := ParsePolicyFile([]byte(req.Base))
+		if err != nil {
+			return nil, fmt.Errorf("base policy: %w", err)
+		}
+		if base, err = NewPolicySnapshot(pf, rs.apiKey); err != nil {
+			return nil, fmt.Errorf("base policy: %w", err)
+		}
+		defer base.db.Close()
+	}
+
+	sim := &PolicySimulation{Changes: []PolicySimulationChange{}}
+	history := callHistoryFromEnv()
+	for _, e := range req.Entries {
+		if e.Method != "tools/call" || e.ToolName == "" {
+			continue
+		}
+		var args map[string]interface{}
+		if e.Arguments == "" || json.Unmarshal([]byte(e.Arguments), &args) != nil {
+			sim.WithoutArguments++
+		}
+		check := CheckRequest{
+			Tool:    e.ToolName,
+			Method:  e.Method,
+			Content: e.Arguments,
+			Scope:   "mcp",
+			History: history.Recent(e.SessionID),
+		}
+		history.Record(e.SessionID, e.ToolName, args)
+		sim.Calls++
+
+		from, to := base.Evaluate(ctx, check), proposed.Evaluate(ctx, check)
+		if from.Decision == to.Decision {
+			continue
+		}
+		change := "changed"
+		switch {
+		case from.Allowed && !to.Allowed:
+			change = "newly_blocked"
+			sim.NewlyBlocked++
+		case !from.Allowed && to.Allowed:
+			change = "newly_allowed"
+			sim.NewlyAllowed++
+		}
+		sim.Changes = append(sim.Changes, PolicySimulationChange{
+			AuditID:   e.ID,
+			Timestamp: e.Timestamp,
+			SessionID: e.SessionID,
+			Tool:      e.ToolName,
+			Arguments: e.Arguments,
+			Change:    change,
+			From:      from,
+			To:        to,
+		})
+	}
+	return sim, nil
+}
+
+// AuditSince returns the entries made after since, keeping their order.
+func AuditSince(entries []AuditEntry, since time.Time) []AuditEntry {
+	window := []AuditEntry{}
+	for _, e := range entries {
+		if e.Timestamp.After(since) {
+			window = append(window, e)
+		}
+	}
+	return window
+}
+
+// handleSimulate handles policy simulation requests
+// POST /api/simulate with a SimulateRequest
+func (rs *RulesServer) handleSimulate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SimulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	sim, err := rs.Simulate(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(sim)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSimulatePolicy(t *testing.T) {
+	rs, err := NewRulesServer(RulesServerConfig{DBPath: filepath.Join(t.TempDir(), "rules.db")})
+	if err != nil {
+		t.Fatalf("failed to create rules server: %v", err)
+	}
+	defer rs.db.Close()
+	if err := rs.store.Create(&Rule{Name: "no-writes", Tools: "fs:write_file", BlockAll: true}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	now := time.Now()
+	entries := []AuditEntry{
+		{ID: 1, Timestamp: now.Add(-48 * time.Hour), Method: "tools/call", ToolName: "github:delete_repo", Arguments: `{"repo":"acme/old"}`},
+		{ID: 2, Timestamp: now.Add(-time.Hour), Method: "tools/call", ToolName: "github:delete_repo", Arguments: `{"repo":"acme/app"}`},
+		{ID: 3, Timestamp: now.Add(-time.Hour), Method: "tools/call", ToolName: "fs:write_file", Blocked: true},
+		{ID: 4, Timestamp: now.Add(-time.Hour), Method: "tools/list"},
+		{ID: 5, Timestamp: now, Method: "tools/call", ToolName: "github:create_issue", Arguments: `{"title":"rm -rf"}`},
+	}
+	window := AuditSince(entries, now.Add(-24*time.Hour))
+	if len(window) != 4 || window[0].ID != 2 {
+		t.Fatalf("unexpected window %+v", window)
+	}
+
+	sim, err := rs.Simulate(context.Background(), SimulateRequest{
+		Entries: window,
+		Policy: `
+rules:
+  - name: no-repo-deletes
+    tools: github:delete_repo
+    block_all: true
+  - name: no-rm
+    pattern: rm -rf
+`,
+	})
+	if err != nil {
+		t.Fatalf("failed to simulate: %v", err)
+	}
+	if sim.Calls != 3 || sim.WithoutArguments != 1 || sim.NewlyBlocked != 2 || sim.NewlyAllowed != 1 || len(sim.Changes) != 3 {
+		t.Fatalf("unexpected simulation %+v", sim)
+	}
+	for i, want := range []struct {
+		id     int64
+		change string
+		to     string
+	}{{2, "newly_blocked", "block"}, {3, "newly_allowed", "allow"}, {5, "newly_blocked", "block"}} {
+		if c := sim.Changes[i]; c.AuditID != want.id || c.Change != want.change || c.To.Decision != want.to {
+			t.Errorf("change %d: expected #%d %s to %s, got %+v", i, want.id, want.change, want.to, c)
+		}
+	}
+	if c := sim.Changes[1]; c.From.Reason != "Blocked by rule: no-writes" {
+		t.Errorf("expected the base rule in the change, got %+v", c.From)
+	}
+
+	// A base policy file replaces the rules store
+	sim, err = rs.Simulate(context.Background(), SimulateRequest{
+		Entries: window,
+		Base:    "rules:\n  - name: no-repo-deletes\n    tools: github:delete_repo\n    block_all: true\n",
+		Policy:  "rules: []\n",
+	})
+	if err != nil || len(sim.Changes) != 1 || sim.Changes[0].AuditID != 2 || sim.NewlyAllowed != 1 {
+		t.Errorf("expected only the repo deletion newly allowed, got %+v (err=%v)", sim, err)
+	}
+
+	if _, err := rs.Simulate(context.Background(), SimulateRequest{Policy: "mode: bogus\n"}); err == nil {
+		t.Error("expected an invalid policy file to be rejected")
+	}
+}
@@ -0,0 +1,340 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// settingPreviewPolicy persists the applied previews section in the rules store.
+const settingPreviewPolicy = "preview_policy"
+
+// previewTimeout bounds a dry-run call made to preview a blocked call.
+const previewTimeout = 10 * time.Second
+
+// maxDiffLines is the longest file, in lines, a diff preview is computed for.
+const maxDiffLines = 2000
+
+// How a preview predicted a call's changes.
+const (
+	PreviewDryRun = "dry_run" // the backend ran the call in its own dry-run mode
+	PreviewDiff   = "diff"    // Armour diffed the file on disk against the new content
+)
+
+// ToolPreview shows the reviewer of a blocked file-mutating call what it
+// would change before they approve it:
+//
+//	previews:
+//	  - tools: ["fs:edit_file"]
+//	    dry_run: {dryRun: true}
+//	  - tools: ["fs:write_file", "fs:create_file"]
+//
+// With dry_run, the call is made once more with these arguments set, for
+// backends that can report their changes without making them, and the result
+// is the preview. Without it, the file the call names is read from disk and
+// diffed against the content (or the oldText/newText edits) in its
+// arguments, which only works for local backends.
+type ToolPreview struct {
+	Tools  []string               `yaml:"tools" json:"tools"` // tool names; * matches a prefix or suffix
+	DryRun map[string]interface{} `yaml:"dry_run,omitempty" json:"dry_run,omitempty"`
+}
+
+// PreviewPolicy is the `previews:` section of a policy file. The first entry
+// naming a tool applies. Previews are made in stdio mode, when a call is held
+// for approval, and shown on the dashboard's approvals page.
+type PreviewPolicy []ToolPreview
+
+// CallPreview is the predicted effect of a call awaiting approval.
+type CallPreview struct {
+	Method  string `json:"method"`
+	Changes string `json:"changes,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// IsZero reports whether no tools are previewed.
+func (pp PreviewPolicy) IsZero() bool {
+	return len(pp) == 0
+}
+
+// Validate checks that every entry names tools.
+func (pp PreviewPolicy) Validate() error {
+	for i, p := range pp {
+		if len(p.Tools) == 0 {
+			return fmt.Errorf("previews[%d]: tools is required", i)
+		}
+		for _, tool := range p.Tools {
+			if strings.TrimSpace(tool) == "" {
+				return fmt.Errorf("previews[%d]: empty tool name", i)
+			}
+		}
+	}
+	return nil
+}
+
+// String renders the entries for diffs, e.g.
+// `fs:edit_file(dry_run:dryRun=true) fs:write_file(diff)`.
+func (pp PreviewPolicy) String() string {
+	parts := make([]string, len(pp))
+	for i, p := range pp {
+		method := PreviewDiff
+		if len(p.DryRun) > 0 {
+			fields := make([]string, 0, len(p.DryRun))
+			for _, arg := range sortedKeys(p.DryRun) {
+				fields = append(fields, arg+"="+describeArgument(p.DryRun[arg], true))
+			}
+			method = PreviewDryRun + ":" + strings.Join(fields, ",")
+		}
+		parts[i] = strings.Join(p.Tools, ",") + "(" + method + ")"
+	}
+	return strings.Join(parts, " ")
+}
+
+// forTool returns the entry for a tool, or false if it isn't previewed.
+func (pp PreviewPolicy) forTool(toolName string) (ToolPreview, bool) {
+	for _, p := range pp {
+		for _, pattern := range p.Tools {
+			if matchWildcard(toolName, pattern) {
+				return p, true
+			}
+		}
+	}
+	return ToolPreview{}, false
+}
+
+// dryRunArguments returns a copy of args with the dry-run arguments set.
+func (p ToolPreview) dryRunArguments(args map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(args)+len(p.DryRun))
+	for k, v := range args {
+		out[k] = v
+	}
+	for k, v := range p.DryRun {
+		out[k] = v
+	}
+	return out
+}
+
+// PreviewFileChanges predicts what a file-writing call does by diffing the
+// file its arguments name against the content they carry: a "content",
+// "contents" or "text" argument replacing the file, or "edits" of
+// oldText/newText replacements. Relative paths resolve against cwd or the
+// first of roots, as CheckPaths resolves them.
+func PreviewFileChanges(roots []string, cwd string, args map[string]interface{}) (string, error) {
+	var path string
+	for _, key := range sortedKeys(args) {
+		if s, ok := args[key].(string); ok && s != "" && isPathKey(key) {
+			path = s
+			break
+		}
+	}
+	if path == "" {
+		return "", fmt.Errorf("no file path in the arguments")
+	}
+	data, err := os.ReadFile(resolveArgumentPath(roots, cwd, path))
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	before := string(data)
+
+	var after string
+	if edits, ok := args["edits"].([]interface{}); ok {
+		after = before
+		for i, item := range edits {
+			edit, _ := item.(map[string]interface{})
+			oldText, _ := edit["oldText"].(string)
+			newText, _ := edit["newText"].(string)
+			if oldText == "" || !strings.Contains(after, oldText) {
+				return "", fmt.Errorf("edits[%d]: oldText not found in %s", i, path)
+			}
+			after = strings.Replace(after, oldText, newText, 1)
+		}
+	} else {
+		found := false
+		for _, key := range []string{"content", "contents", "text"} {
+			if s, ok := args[key].(string); ok {
+				after, found = s, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("no file content or edits in the arguments")
+		}
+	}
+
+	header := fmt.Sprintf("--- %s\n+++ %s\n", path, path)
+	if os.IsNotExist(err) {
+		header = fmt.Sprintf("--- /dev/null (new file)\n+++ %s\n", path)
+	}
+	return header + diffLines(before, after), nil
+}
+
+// diffLines renders a line diff of before and after, with two lines of
+// context around each change.
+func diffLines(before, after string) string {
+	if before == after {
+		return "(no changes)\n"
+	}
+	a, b := splitLines(before), splitLines(after)
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return fmt.Sprintf("(too large to diff: %d lines -> %d lines)\n", len(a), len(b))
+	}
+
+	// Longest common subsequence, from the end so the walk below is forward
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	const contextLines = 2
+	var out strings.Builder
+	last := -1
+	for k, line := range lines {
+		near := false
+		for d := max(0, k-contextLines); d <= min(len(lines)-1, k+contextLines); d++ {
+			if lines[d][0] != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if last >= 0 && k > last+1 {
+			out.WriteString("@@\n")
+		}
+		out.WriteString(line + "\n")
+		last = k
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// previewCall predicts the changes of a call held for approval and attaches
+// them to the approval once they are known, so the agent isn't kept waiting.
+func (s *StdioServer) previewCall(token, toolName string, args map[string]interface{}) {
+	s.mu.RLock()
+	previews := s.previews
+	s.mu.RUnlock()
+	p, ok := previews.forTool(toolName)
+	if !ok || s.toolRegistry == nil {
+		return
+	}
+	go func() {
+		preview := s.makePreview(p, toolName, args)
+		if preview.Error != "" {
+			s.logger.Warn("failed to preview %s: %s", toolName, preview.Error)
+		}
+		s.approvals.SetPreview(token, preview)
+	}()
+}
+
+func (s *StdioServer) makePreview(p ToolPreview, toolName string, args map[string]interface{}) *CallPreview {
+	backendID, err := s.toolRegistry.GetToolBackend(toolName)
+	if err != nil {
+		return &CallPreview{Method: PreviewDiff, Error: err.Error()}
+	}
+	if len(p.DryRun) == 0 {
+		preview := &CallPreview{Method: PreviewDiff}
+		if s.backendManager.IsRemote(backendID) {
+			preview.Error = "files of remote servers can't be diffed; configure dry_run"
+		} else if changes, err := PreviewFileChanges(s.backendManager.AllowedRoots(backendID), s.backendManager.WorkingDir(backendID), args); err != nil {
+			preview.Error = err.Error()
+		} else {
+			preview.Changes = redactPreview(changes)
+		}
+		return preview
+	}
+
+	preview := &CallPreview{Method: PreviewDryRun}
+	tool, err := s.toolRegistry.GetTool(toolName)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	data, err := json.Marshal(p.dryRunArguments(args))
+	if err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+	defer cancel()
+	response, err := s.backendManager.CallTool(ctx, backendID, tool.OriginalName, data)
+	if err != nil {
+		preview.Error = fmt.Sprintf("dry run failed: %v", err)
+		return preview
+	}
+	result := NormalizeToolResult(response)
+	var texts []string
+	for _, item := range result["content"].([]interface{}) {
+		if m, ok := item.(map[string]interface{}); ok && m["type"] == "text" {
+			text, _ := m["text"].(string)
+			texts = append(texts, text)
+		}
+	}
+	if result["isError"] == true {
+		preview.Error = "dry run failed: " + strings.Join(texts, "\n")
+		return preview
+	}
+	preview.Changes = redactPreview(strings.Join(texts, "\n"))
+	return preview
+}
+
+// redactPreview keeps sensitive data in a preview off the dashboard, like
+// the arguments shown next to it.
+func redactPreview(changes string) string {
+	_, redacted := ScanDLP(redactAllDLP, map[string]interface{}{"changes": changes})
+	text, _ := redacted["changes"].(string)
+	return text
+}
+
+func encodePreviewPolicy(pp PreviewPolicy) (string, error) {
+	if pp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(pp)
+	return string(data), err
+}
+
+func decodePreviewPolicy(raw string) (PreviewPolicy, error) {
+	var pp PreviewPolicy
+	if raw == "" {
+		return pp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &pp); err != nil {
+		return pp, fmt.Errorf("invalid stored preview policy: %w", err)
+	}
+	return pp, nil
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestPreviewFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte("name: app\nreplicas: 1\nimage: app:1.0\nport: 8080\nlog: info\ndebug: false\n"), 0644)
+
+	got, err := PreviewFileChanges(nil, "", map[string]interface{}{"path": path, "content": "name: app\nreplicas: 3\nimage: app:1.0\nport: 8080\nlog: info\ndebug: false\n"})
+	if err != nil {
+		t.Fatalf("preview failed: %v", err)
+	}
+	want := "--- " + path + "\n+++ " + path + "\n name: app\n-replicas: 1\n+replicas: 3\n image: app:1.0\n port: 8080\n"
+	if got != want {
+		t.Errorf("got diff\n%s\nwant\n%s", got, want)
+	}
+
+	// Edits apply to the file; relative paths resolve against the first root
+	got, err = PreviewFileChanges([]string{dir}, "", map[string]interface{}{
+		"path":  "config.yaml",
+		"edits": []interface{}{map[string]interface{}{"oldText": "debug: false", "newText": "debug: true"}},
+	})
+	if err != nil || !strings.Contains(got, "-debug: false\n+debug: true\n") || strings.Contains(got, "replicas") {
+		t.Errorf("unexpected edit diff %q (err=%v)", got, err)
+	}
+
+	got, err = PreviewFileChanges(nil, "", map[string]interface{}{"path": filepath.Join(dir, "new.txt"), "content": "hello\n"})
+	if err != nil || !strings.HasPrefix(got, "--- /dev/null (new file)\n") || !strings.HasSuffix(got, "+hello\n") {
+		t.Errorf("unexpected new file diff %q (err=%v)", got, err)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"content": "x"},
+		{"path": path},
+		{"path": path, "edits": []interface{}{map[string]interface{}{"oldText": "missing", "newText": "x"}}},
+	} {
+		if _, err := PreviewFileChanges(nil, "", args); err == nil {
+			t.Errorf("expected %v to fail", args)
+		}
+	}
+
+	if err := (PreviewPolicy{{DryRun: map[string]interface{}{"dryRun": true}}}).Validate(); err == nil {
+		t.Error("expected a preview without tools to be rejected")
+	}
+}
+
+func TestStdioPreviewsHeldCalls(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "notes.txt")
+	os.WriteFile(target, []byte("draft\n"), 0644)
+	fixture := filepath.Join(dir, "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: write_file
+    text: written
+  - name: move_file
+    text: "would move {{source}} (dry run: {{dryRun}})"
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "fs", Transport: "mock", Fixture: fixture},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(dir, "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+	if err := CreateBlocklistRule(srv.db, &BlocklistRule{Pattern: ".*", Tools: "fs:write_file,fs:move_file", Action: "ask", IsRegex: true, Enabled: true, Permissions: DefaultPermissions("ask")}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	srv.blocklist.RefreshRulesCache()
+	srv.SetPreviewPolicy(PreviewPolicy{
+		{Tools: []string{"fs:move_file"}, DryRun: map[string]interface{}{"dryRun": true}},
+		{Tools: []string{"fs:write*"}},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	previewOf := func(id int, params string) *CallPreview {
+		t.Helper()
+		resp := srv.handleRequest(ctx, JSONRPCRequest{ID: id, Method: "tools/call", Params: json.RawMessage(params)}).(JSONRPCResponse)
+		if resp.Error == nil || resp.Error.Code != ErrCodeDenied {
+			t.Fatalf("expected the call held for approval, got %+v", resp)
+		}
+		token := strings.TrimPrefix(resp.Error.Data.(*BlockError).ApprovalURL, DashboardURL+"/?approval=")
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if approval, ok := srv.GetApprovals().Get(token); ok && approval.Preview != nil {
+				return approval.Preview
+			}
+		}
+		t.Fatalf("no preview for call %d", id)
+		return nil
+	}
+
+	params, _ := json.Marshal(map[string]interface{}{"name": "fs:write_file", "arguments": map[string]interface{}{"path": target, "content": "final\n"}})
+	if p := previewOf(2, string(params)); p.Method != PreviewDiff || p.Error != "" || !strings.Contains(p.Changes, "-draft\n+final\n") {
+		t.Errorf("expected a diff preview, got %+v", p)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "draft\n" {
+		t.Errorf("expected the file untouched, got %q", data)
+	}
+
+	if p := previewOf(3, `{"name":"fs:move_file","arguments":{"source":"a.txt","destination":"b.txt"}}`); p.Method != PreviewDryRun || p.Changes != "would move a.txt (dry run: true)" {
+		t.Errorf("expected a dry-run preview, got %+v", p)
+	}
+}
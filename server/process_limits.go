@@ -0,0 +1,44 @@
+package server
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// Limits a backend process can be stopped for, as flagged in BackendRestarts.
+const (
+	LimitMemory   = "memory"
+	LimitLifetime = "max_lifetime"
+)
+
+// processLimiter confines a stdio backend's subprocess to its ResourceLimits
+// with whatever the platform offers.
+type processLimiter interface {
+	// prepare adjusts the command before it starts.
+	prepare(cmd *exec.Cmd)
+	// apply confines the started process. An error means a limit is not
+	// enforced; the process keeps running.
+	apply(process *os.Process) error
+	// exceeded names the limit the exited process hit, if the platform can tell.
+	exceeded() string
+	// release frees what apply set up, once the process exited.
+	release()
+}
+
+// newProcessLimiter returns the limiter for a backend's memory and CPU limits.
+func newProcessLimiter(name string, limits *proxy.ResourceLimits) processLimiter {
+	if limits == nil || (limits.MemoryMB == 0 && limits.CPUPercent == 0) {
+		return noLimits{}
+	}
+	return newPlatformLimiter(name, limits)
+}
+
+// noLimits leaves a process alone.
+type noLimits struct{}
+
+func (noLimits) prepare(*exec.Cmd)       {}
+func (noLimits) apply(*os.Process) error { return nil }
+func (noLimits) exceeded() string        { return "" }
+func (noLimits) release()                {}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// darwinLimiter sets rlimits through a shell wrapper, as macOS has no way to
+// set them on another process. The kernel enforces memory rlimits loosely
+// and has no CPU share limit, so this is best-effort.
+type darwinLimiter struct {
+	limits *proxy.ResourceLimits
+}
+
+func newPlatformLimiter(name string, limits *proxy.ResourceLimits) processLimiter {
+	return &darwinLimiter{limits: limits}
+}
+
+// prepare runs the command as `sh -c 'ulimit ...; exec "$0" "$@"' command args...`.
+func (l *darwinLimiter) prepare(cmd *exec.Cmd) {
+	if l.limits.MemoryMB == 0 || cmd.Err != nil {
+		return
+	}
+	kb := l.limits.MemoryMB << 10
+	script := fmt.Sprintf(`ulimit -d %d 2>/dev/null; ulimit -v %d 2>/dev/null; exec "$0" "$@"`, kb, kb)
+	cmd.Args = append([]string{"/bin/sh", "-c", script, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+}
+
+func (l *darwinLimiter) apply(*os.Process) error {
+	if l.limits.CPUPercent > 0 {
+		return fmt.Errorf("CPU limits are not supported on macOS")
+	}
+	return nil
+}
+
+func (l *darwinLimiter) exceeded() string { return "" }
+
+func (l *darwinLimiter) release() {}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// cgroupRoot is where the cgroup v2 hierarchy is mounted.
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupCPUPeriod is the cpu.max period in microseconds.
+const cgroupCPUPeriod = 100000
+
+// linuxLimiter puts a backend in its own cgroup next to the proxy's. Without
+// a writable cgroup v2 hierarchy it falls back to an rlimit on memory; CPU
+// is then not limited.
+type linuxLimiter struct {
+	name   string
+	limits *proxy.ResourceLimits
+	cgroup string // the backend's cgroup directory, if one was created
+}
+
+func newPlatformLimiter(name string, limits *proxy.ResourceLimits) processLimiter {
+	return &linuxLimiter{name: name, limits: limits}
+}
+
+func (l *linuxLimiter) prepare(*exec.Cmd) {}
+
+func (l *linuxLimiter) apply(process *os.Process) error {
+	dir, cgroupErr := createBackendCgroup(l.name, process.Pid, l.limits)
+	if cgroupErr == nil {
+		l.cgroup = dir
+		return nil
+	}
+
+	if l.limits.MemoryMB > 0 {
+		limit := uint64(l.limits.MemoryMB) << 20
+		if err := unix.Prlimit(process.Pid, unix.RLIMIT_DATA, &unix.Rlimit{Cur: limit, Max: limit}, nil); err != nil {
+			return fmt.Errorf("no cgroup (%v) and failed to set memory rlimit: %w", cgroupErr, err)
+		}
+	}
+	if l.limits.CPUPercent > 0 {
+		return fmt.Errorf("no cgroup (%v): CPU limit not enforced", cgroupErr)
+	}
+	return nil
+}
+
+// createBackendCgroup creates a cgroup for a backend process next to the
+// proxy's own, sets its limits and moves the process into it.
+func createBackendCgroup(name string, pid int, limits *proxy.ResourceLimits) (string, error) {
+	own, err := ownCgroup()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cgroupRoot, filepath.Dir(own), fmt.Sprintf("armour-%s-%d", name, pid))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", err
+	}
+
+	settings := [][2]string{}
+	if limits.MemoryMB > 0 {
+		settings = append(settings, [2]string{"memory.max", strconv.Itoa(limits.MemoryMB << 20)})
+	}
+	if limits.CPUPercent > 0 {
+		settings = append(settings, [2]string{"cpu.max", fmt.Sprintf("%d %d", limits.CPUPercent*cgroupCPUPeriod/100, cgroupCPUPeriod)})
+	}
+	settings = append(settings, [2]string{"cgroup.procs", strconv.Itoa(pid)})
+	for _, s := range settings {
+		if err := os.WriteFile(filepath.Join(dir, s[0]), []byte(s[1]), 0644); err != nil {
+			os.Remove(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// ownCgroup returns the proxy's cgroup v2 path from /proc/self/cgroup.
+func ownCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 not in use")
+}
+
+// exceeded reports a memory limit hit if the kernel OOM-killed in the cgroup.
+func (l *linuxLimiter) exceeded() string {
+	if l.cgroup == "" {
+		return ""
+	}
+	f, err := os.Open(filepath.Join(l.cgroup, "memory.events"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if count, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok && count != "0" {
+			return LimitMemory
+		}
+	}
+	return ""
+}
+
+func (l *linuxLimiter) release() {
+	if l.cgroup != "" {
+		os.Remove(l.cgroup)
+	}
+}
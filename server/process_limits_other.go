@@ -0,0 +1,30 @@
+//go:build !linux && !darwin && !windows
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// otherLimiter is used where memory and CPU limits aren't implemented; only
+// max_lifetime applies.
+type otherLimiter struct{}
+
+func newPlatformLimiter(name string, limits *proxy.ResourceLimits) processLimiter {
+	return otherLimiter{}
+}
+
+func (otherLimiter) prepare(*exec.Cmd) {}
+
+func (otherLimiter) apply(*os.Process) error {
+	return fmt.Errorf("memory and CPU limits are not supported on %s", runtime.GOOS)
+}
+
+func (otherLimiter) exceeded() string { return "" }
+
+func (otherLimiter) release() {}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// JOBOBJECT_CPU_RATE_CONTROL_INFORMATION, which x/sys/windows doesn't define.
+type jobCPURateControl struct {
+	ControlFlags uint32
+	CPURate      uint32 // in 1/100 percent of all processors
+}
+
+const (
+	jobCPURateControlEnable  = 0x1
+	jobCPURateControlHardCap = 0x4
+)
+
+// windowsLimiter puts a backend in a job object with memory and CPU rate limits.
+type windowsLimiter struct {
+	limits *proxy.ResourceLimits
+	job    windows.Handle
+}
+
+func newPlatformLimiter(name string, limits *proxy.ResourceLimits) processLimiter {
+	return &windowsLimiter{limits: limits}
+}
+
+func (l *windowsLimiter) prepare(*exec.Cmd) {}
+
+func (l *windowsLimiter) apply(process *os.Process) error {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create job object: %w", err)
+	}
+	l.job = job
+
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if l.limits.MemoryMB > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+		info.ProcessMemoryLimit = uintptr(l.limits.MemoryMB) << 20
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		return fmt.Errorf("failed to set memory limit: %w", err)
+	}
+	if l.limits.CPUPercent > 0 {
+		rate := jobCPURateControl{
+			ControlFlags: jobCPURateControlEnable | jobCPURateControlHardCap,
+			CPURate:      uint32(min(max(l.limits.CPUPercent*100/runtime.NumCPU(), 1), 10000)),
+		}
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectCpuRateControlInformation, uintptr(unsafe.Pointer(&rate)), uint32(unsafe.Sizeof(rate))); err != nil {
+			return fmt.Errorf("failed to set CPU limit: %w", err)
+		}
+	}
+
+	handle, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(process.Pid))
+	if err != nil {
+		return fmt.Errorf("failed to open process: %w", err)
+	}
+	defer windows.CloseHandle(handle)
+	if err := windows.AssignProcessToJobObject(job, handle); err != nil {
+		return fmt.Errorf("failed to assign process to job object: %w", err)
+	}
+	return nil
+}
+
+// exceeded reports a memory limit hit if the process peaked at its limit.
+func (l *windowsLimiter) exceeded() string {
+	if l.job == 0 || l.limits.MemoryMB == 0 {
+		return ""
+	}
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	if err := windows.QueryInformationJobObject(l.job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil); err != nil {
+		return ""
+	}
+	if info.PeakProcessMemoryUsed >= info.ProcessMemoryLimit {
+		return LimitMemory
+	}
+	return ""
+}
+
+func (l *windowsLimiter) release() {
+	if l.job != 0 {
+		windows.CloseHandle(l.job)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package server
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// runAsUser is unix only; Windows would need the user's credentials.
+func runAsUser(cmd *exec.Cmd, name string) error {
+	return fmt.Errorf("running backends as another user is not supported on %s", runtime.GOOS)
+}
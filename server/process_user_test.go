@@ -0,0 +1,59 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestBackendWorkingDirectoryAndUser tests that a stdio backend starts in
+// its cwd as its user, and that a missing cwd or unknown user fails it
+func TestBackendWorkingDirectoryAndUser(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	scratch, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve temp dir: %v", err)
+	}
+	entry := proxy.ServerEntry{
+		Name:      "scratch",
+		Transport: "stdio",
+		Command:   "sh",
+		Args:      []string{"-c", `pwd -P >&2; while read line; do echo '{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{},"tools":[]}}'; done`},
+		Cwd:       scratch,
+		User:      current.Username,
+	}
+	bm := NewBackendManager(&proxy.ServerRegistry{Servers: []proxy.ServerEntry{entry}}, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	bm.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bm.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize: %v", err)
+	}
+	for len(bm.BackendLogs("scratch", 0)) == 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if lines := bm.BackendLogs("scratch", 0); len(lines) != 1 || lines[0] != scratch {
+		t.Errorf("expected the backend started in %s, got %q", scratch, lines)
+	}
+
+	missing := entry
+	missing.Cwd = filepath.Join(scratch, "missing")
+	if err := bm.initializeBackend(ctx, &missing); err == nil {
+		t.Error("expected a missing working directory to fail the backend")
+	}
+	unknown := entry
+	unknown.User = "armour-no-such-user"
+	if err := bm.initializeBackend(ctx, &unknown); err == nil {
+		t.Error("expected an unknown user to fail the backend")
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PromptRegistry maps the namespaced prompt names clients see to the backend
+// that owns each prompt and the name it has there. Prompts are namespaced as
+// "{backendID}:{name}" like tools, but neither part is guaranteed to be free
+// of colons: backend "a" with prompt "b:c" and backend "a:b" with prompt "c"
+// would both be "a:b:c". The registry gives the prompt listed second a
+// distinct name ("a:b:c#2") and maps every name back to its owner, so
+// prompts/get never has to split a name to find the backend.
+type PromptRegistry struct {
+	prompts map[string]RegisteredPrompt // namespaced name -> prompt
+	names   map[promptKey]string        // backend and original name -> namespaced name
+	mu      sync.RWMutex
+}
+
+// RegisteredPrompt is a backend prompt under the name the proxy lists it as.
+type RegisteredPrompt struct {
+	Name         string `json:"name"`
+	BackendID    string `json:"backendId"`
+	OriginalName string `json:"originalName"`
+}
+
+type promptKey struct {
+	backendID, name string
+}
+
+// NewPromptRegistry creates an empty prompt registry.
+func NewPromptRegistry() *PromptRegistry {
+	return &PromptRegistry{
+		prompts: make(map[string]RegisteredPrompt),
+		names:   make(map[promptKey]string),
+	}
+}
+
+// Register returns the namespaced name of a backend's prompt, registering it
+// the first time it is seen. The name stays the same for the life of the
+// registry, however often the prompt is listed.
+func (pr *PromptRegistry) Register(backendID, name string) string {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	key := promptKey{backendID, name}
+	if namespaced, ok := pr.names[key]; ok {
+		return namespaced
+	}
+	base := fmt.Sprintf("%s:%s", backendID, name)
+	namespaced := base
+	for n := 2; ; n++ {
+		if _, taken := pr.prompts[namespaced]; !taken {
+			break
+		}
+		namespaced = fmt.Sprintf("%s#%d", base, n)
+	}
+	pr.prompts[namespaced] = RegisteredPrompt{Name: namespaced, BackendID: backendID, OriginalName: name}
+	pr.names[key] = namespaced
+	return namespaced
+}
+
+// Resolve returns the backend and original name of a namespaced prompt name.
+// ok is false if no listed prompt has the name.
+func (pr *PromptRegistry) Resolve(namespaced string) (backendID, name string, ok bool) {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	prompt, ok := pr.prompts[namespaced]
+	return prompt.BackendID, prompt.OriginalName, ok
+}
+
+// ClearBackendPrompts forgets the prompts of a backend, e.g. when it is removed.
+func (pr *PromptRegistry) ClearBackendPrompts(backendID string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	for key, namespaced := range pr.names {
+		if key.backendID == backendID {
+			delete(pr.names, key)
+			delete(pr.prompts, namespaced)
+		}
+	}
+}
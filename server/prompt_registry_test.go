@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestPromptRegistry(t *testing.T) {
+	pr := NewPromptRegistry()
+	if got := pr.Register("git", "commit"); got != "git:commit" {
+		t.Errorf("expected git:commit, got %s", got)
+	}
+	first := pr.Register("a", "b:c")
+	second := pr.Register("a:b", "c")
+	if first != "a:b:c" || second != "a:b:c#2" {
+		t.Fatalf("expected a:b:c and a:b:c#2, got %s and %s", first, second)
+	}
+	if again := pr.Register("a:b", "c"); again != second {
+		t.Errorf("expected a stable name, got %s", again)
+	}
+
+	for name, want := range map[string][2]string{
+		"a:b:c":      {"a", "b:c"},
+		"a:b:c#2":    {"a:b", "c"},
+		"git:commit": {"git", "commit"},
+	} {
+		backend, original, ok := pr.Resolve(name)
+		if !ok || backend != want[0] || original != want[1] {
+			t.Errorf("Resolve(%q) = %q, %q, %v; want %q, %q", name, backend, original, ok, want[0], want[1])
+		}
+	}
+	if _, _, ok := pr.Resolve("git:push"); ok {
+		t.Error("expected an unlisted prompt not to resolve")
+	}
+
+	pr.ClearBackendPrompts("a")
+	if _, _, ok := pr.Resolve("a:b:c"); ok {
+		t.Error("expected the cleared backend's prompt to be gone")
+	}
+	if _, _, ok := pr.Resolve("a:b:c#2"); !ok {
+		t.Error("expected other backends' prompts to stay")
+	}
+}
+
+func TestPromptNamesWithColons(t *testing.T) {
+	dir := t.TempDir()
+	fixture := func(name, prompt, text string) string {
+		path := filepath.Join(dir, name+".yaml")
+		content := "prompts:\n  - name: \"" + prompt + "\"\n    text: \"" + text + "\"\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+		return path
+	}
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "a", Transport: "mock", Fixture: fixture("a", "b:c", "from a")},
+		{Name: "a:b", Transport: "mock", Fixture: fixture("ab", "c", "from a:b")},
+		{Name: "git", Transport: "mock", Fixture: fixture("git", "review:pr", "from git")},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	get := func(name string) string {
+		t.Helper()
+		params, _ := json.Marshal(map[string]string{"name": name})
+		resp, ok := srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "prompts/get", Params: params}).(JSONRPCResponse)
+		if !ok || resp.Error != nil {
+			t.Fatalf("expected prompts/get %s to succeed, got %+v", name, resp)
+		}
+		data, _ := json.Marshal(resp.Result)
+		return string(data)
+	}
+
+	// Before prompts/list, names are split after the longest backend name
+	if got := get("git:review:pr"); !strings.Contains(got, "from git") {
+		t.Errorf("expected git's prompt, got %s", got)
+	}
+	if got := get("a:b:c"); !strings.Contains(got, "from a:b") {
+		t.Errorf("expected a:b's prompt before listing, got %s", got)
+	}
+
+	resp, ok := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "prompts/list"}).(JSONRPCResponse)
+	if !ok || resp.Error != nil {
+		t.Fatalf("expected prompts/list to succeed, got %+v", resp)
+	}
+	data, _ := json.Marshal(resp.Result)
+	for _, name := range []string{`"a:b:c"`, `"a:b:c#2"`, `"git:review:pr"`} {
+		if !strings.Contains(string(data), name) {
+			t.Errorf("expected %s in prompts/list, got %s", name, data)
+		}
+	}
+
+	// Listed names map back to the prompt they were listed for
+	if got := get("a:b:c"); !strings.Contains(got, "from a") || strings.Contains(got, "from a:b") {
+		t.Errorf("expected a's prompt, got %s", got)
+	}
+	if got := get("a:b:c#2"); !strings.Contains(got, "from a:b") {
+		t.Errorf("expected a:b's prompt, got %s", got)
+	}
+}
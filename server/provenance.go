@@ -0,0 +1,301 @@
+package server
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// settingBinaryPins persists servers.pins in the rules store.
+const settingBinaryPins = "binary_pins"
+
+// SourceMarketplace is the provenance source of servers from marketplace
+// plugins; the other sources are the trust tiers.
+const SourceMarketplace = "marketplace"
+
+// ServerProvenance is where a backend came from and what it runs.
+type ServerProvenance struct {
+	Name          string     `json:"name"`
+	Source        string     `json:"source"`                 // explicit, config, plugin or marketplace
+	InstallPath   string     `json:"install_path,omitempty"` // the plugin's directory
+	BinaryPath    string     `json:"binary_path,omitempty"`  // the stdio command, resolved
+	BinaryHash    string     `json:"binary_hash,omitempty"`  // sha256 of the binary
+	PreviousHash  string     `json:"previous_hash,omitempty"`
+	HashChangedAt *time.Time `json:"hash_changed_at,omitempty"`
+	Pinned        string     `json:"pinned,omitempty"` // the hash the binary is pinned to
+	FirstSeen     time.Time  `json:"first_seen"`
+	LastSeen      time.Time  `json:"last_seen"`
+}
+
+// BinaryPins pins stdio servers to the sha256 of their command's binary. It
+// is `servers.pins` in a policy file:
+//
+//	servers:
+//	  pins:
+//	    filesystem: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//
+// A pinned server whose binary hashes differently is not started. For
+// launchers like npx, the pin covers the launcher, not the package it runs.
+type BinaryPins map[string]string
+
+// IsZero reports whether no server is pinned.
+func (bp BinaryPins) IsZero() bool {
+	return len(bp) == 0
+}
+
+// Validate checks that every pin is a sha256 hex digest.
+func (bp BinaryPins) Validate() error {
+	for name, hash := range bp {
+		if len(hash) != sha256.Size*2 {
+			return fmt.Errorf("servers.pins.%s: expected a sha256 hex digest, got %q", name, hash)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return fmt.Errorf("servers.pins.%s: expected a sha256 hex digest, got %q", name, hash)
+		}
+	}
+	return nil
+}
+
+// String renders the pins for diffs, e.g. `filesystem=9f86d081…`.
+func (bp BinaryPins) String() string {
+	parts := make([]string, 0, len(bp))
+	for _, name := range sortedKeys(bp) {
+		parts = append(parts, name+"="+strings.ToLower(bp[name]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ProvenanceStore keeps the provenance of every backend the proxy started,
+// so a binary that changed since it was last seen stands out.
+type ProvenanceStore struct {
+	db *sql.DB
+}
+
+// NewProvenanceStore creates a provenance store backed by db.
+func NewProvenanceStore(db *sql.DB) (*ProvenanceStore, error) {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS server_provenance (
+			name TEXT PRIMARY KEY,
+			source TEXT NOT NULL,
+			install_path TEXT,
+			binary_path TEXT,
+			binary_hash TEXT,
+			previous_hash TEXT,
+			hash_changed_at TIMESTAMP,
+			first_seen TIMESTAMP NOT NULL,
+			last_seen TIMESTAMP NOT NULL
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create server_provenance table: %w", err)
+	}
+	return &ProvenanceStore{db: db}, nil
+}
+
+// Record stores the provenance of a backend being started and reports
+// whether its binary hash changed since it was last recorded.
+func (ps *ProvenanceStore) Record(p ServerProvenance) (*ServerProvenance, bool, error) {
+	known, err := ps.Get(p.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+	p.FirstSeen, p.LastSeen = now, now
+	changed := false
+	if known != nil {
+		p.FirstSeen = known.FirstSeen
+		p.PreviousHash, p.HashChangedAt = known.PreviousHash, known.HashChangedAt
+		if known.BinaryHash != "" && p.BinaryHash != "" && known.BinaryHash != p.BinaryHash {
+			p.PreviousHash, p.HashChangedAt = known.BinaryHash, &now
+			changed = true
+		}
+	}
+
+	var changedAt interface{}
+	if p.HashChangedAt != nil {
+		changedAt = *p.HashChangedAt
+	}
+	_, err = ps.db.Exec(`
+		INSERT INTO server_provenance (name, source, install_path, binary_path, binary_hash, previous_hash, hash_changed_at, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET source = excluded.source, install_path = excluded.install_path,
+			binary_path = excluded.binary_path, binary_hash = excluded.binary_hash, previous_hash = excluded.previous_hash,
+			hash_changed_at = excluded.hash_changed_at, last_seen = excluded.last_seen
+	`, p.Name, p.Source, p.InstallPath, p.BinaryPath, p.BinaryHash, p.PreviousHash, changedAt, p.FirstSeen, p.LastSeen)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record provenance of %s: %w", p.Name, err)
+	}
+	return &p, changed, nil
+}
+
+// Get returns the recorded provenance of a backend, or nil if it was never started.
+func (ps *ProvenanceStore) Get(name string) (*ServerProvenance, error) {
+	all, err := ps.list("WHERE name = ?", name)
+	if err != nil || len(all) == 0 {
+		return nil, err
+	}
+	return &all[0], nil
+}
+
+// List returns the recorded provenance of every backend, by name.
+func (ps *ProvenanceStore) List() ([]ServerProvenance, error) {
+	return ps.list("")
+}
+
+func (ps *ProvenanceStore) list(where string, args ...interface{}) ([]ServerProvenance, error) {
+	rows, err := ps.db.Query(`
+		SELECT name, source, COALESCE(install_path, ''), COALESCE(binary_path, ''), COALESCE(binary_hash, ''),
+			COALESCE(previous_hash, ''), hash_changed_at, first_seen, last_seen
+		FROM server_provenance `+where+` ORDER BY name`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list server provenance: %w", err)
+	}
+	defer rows.Close()
+
+	all := []ServerProvenance{}
+	for rows.Next() {
+		var p ServerProvenance
+		var changedAt sql.NullTime
+		if err := rows.Scan(&p.Name, &p.Source, &p.InstallPath, &p.BinaryPath, &p.BinaryHash,
+			&p.PreviousHash, &changedAt, &p.FirstSeen, &p.LastSeen); err != nil {
+			return nil, err
+		}
+		if changedAt.Valid {
+			p.HashChangedAt = &changedAt.Time
+		}
+		all = append(all, p)
+	}
+	return all, rows.Err()
+}
+
+// HashBinary resolves the command of a stdio server entry like exec does and
+// returns its path and sha256.
+func HashBinary(entry proxy.ServerEntry) (string, string, error) {
+	command := entry.Command
+	if entry.Cwd != "" && !filepath.IsAbs(command) && strings.ContainsRune(command, filepath.Separator) {
+		command = filepath.Join(expandHome(entry.Cwd), command)
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return path, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return path, "", err
+	}
+	return path, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SetProvenanceStore records the provenance of backends started afterwards.
+func (bm *BackendManager) SetProvenanceStore(store *ProvenanceStore) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.provenance = store
+}
+
+// SetBinaryPins sets the binary hashes stdio servers are pinned to.
+func (bm *BackendManager) SetBinaryPins(pins BinaryPins) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.binaryPins = pins
+}
+
+// Provenance returns the recorded provenance of every backend, with its pin.
+func (bm *BackendManager) Provenance() ([]ServerProvenance, error) {
+	bm.mu.RLock()
+	store, pins := bm.provenance, bm.binaryPins
+	bm.mu.RUnlock()
+	if store == nil {
+		return []ServerProvenance{}, nil
+	}
+	all, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range all {
+		all[i].Pinned = strings.ToLower(pins[all[i].Name])
+	}
+	return all, nil
+}
+
+// checkProvenance records where a backend about to start came from and
+// what binary it runs, warning when the binary changed since it last ran.
+// It refuses to start a pinned server whose binary doesn't match its pin.
+func (bm *BackendManager) checkProvenance(entry *proxy.ServerEntry) error {
+	bm.mu.RLock()
+	store, pin := bm.provenance, strings.ToLower(bm.binaryPins[entry.Name])
+	bm.mu.RUnlock()
+
+	p := ServerProvenance{Name: entry.Name, Source: string(bm.TrustTier(entry.Name))}
+	if p.Source == string(TrustPlugin) {
+		p.InstallPath = entry.Env["CLAUDE_PLUGIN_ROOT"]
+		if strings.Contains(filepath.ToSlash(p.InstallPath), "/plugins/marketplaces/") {
+			p.Source = SourceMarketplace
+		}
+	}
+	if entry.Transport == "stdio" {
+		path, hash, err := HashBinary(*entry)
+		if err != nil {
+			bm.logger.Debug("cannot hash the binary of %s: %v", entry.Name, err)
+		}
+		p.BinaryPath, p.BinaryHash = path, hash
+	}
+	if pin != "" && p.BinaryHash != pin {
+		if p.BinaryHash == "" {
+			return fmt.Errorf("%s is pinned to binary %s, but its binary can't be hashed", entry.Name, pin)
+		}
+		return fmt.Errorf("binary %s of %s has sha256 %s, but it is pinned to %s", p.BinaryPath, entry.Name, p.BinaryHash, pin)
+	}
+
+	if store == nil {
+		return nil
+	}
+	recorded, changed, err := store.Record(p)
+	if err != nil {
+		bm.logger.Warn("%v", err)
+		return nil
+	}
+	if changed {
+		bm.logger.Warn("binary %s of %s changed since it last ran: sha256 %s -> %s", recorded.BinaryPath, entry.Name, recorded.PreviousHash, recorded.BinaryHash)
+	}
+	return nil
+}
+
+func encodeBinaryPins(bp BinaryPins) (string, error) {
+	if bp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(bp)
+	return string(data), err
+}
+
+func decodeBinaryPins(raw string) (BinaryPins, error) {
+	var bp BinaryPins
+	if raw == "" {
+		return bp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &bp); err != nil {
+		return bp, fmt.Errorf("invalid stored binary pins: %w", err)
+	}
+	return bp, nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestServerProvenance(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "notes-mcp")
+	os.WriteFile(binary, []byte("#!/bin/sh\necho v1\n"), 0755)
+
+	store, err := NewProvenanceStore(openChainTestDB(t))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	registry := &proxy.ServerRegistry{}
+	backends := NewBackendManager(registry, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	backends.SetProvenanceStore(store)
+	backends.pluginServers["market"] = true
+
+	entry := proxy.ServerEntry{Name: "notes", Transport: "stdio", Command: "./notes-mcp", Cwd: dir}
+	if err := backends.checkProvenance(&entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, hash, err := HashBinary(entry)
+	if err != nil {
+		t.Fatalf("failed to hash: %v", err)
+	}
+	p, _ := store.Get("notes")
+	if p == nil || p.Source != string(TrustExplicit) || p.BinaryPath != binary || p.BinaryHash != hash || p.HashChangedAt != nil {
+		t.Fatalf("unexpected provenance %+v", p)
+	}
+
+	// The binary changes under the server
+	os.WriteFile(binary, []byte("#!/bin/sh\necho v2\n"), 0755)
+	backends.checkProvenance(&entry)
+	changed, _ := store.Get("notes")
+	if changed.PreviousHash != hash || changed.BinaryHash == hash || changed.HashChangedAt == nil || !changed.FirstSeen.Equal(p.FirstSeen) {
+		t.Errorf("expected the hash change recorded, got %+v", changed)
+	}
+
+	// A pinned server only starts with the pinned binary
+	backends.SetBinaryPins(BinaryPins{"notes": strings.ToUpper(hash)})
+	if err := backends.checkProvenance(&entry); err == nil || !strings.Contains(err.Error(), "pinned to "+hash) {
+		t.Errorf("expected the changed binary refused, got %v", err)
+	}
+	backends.SetBinaryPins(BinaryPins{"notes": changed.BinaryHash})
+	if err := backends.checkProvenance(&entry); err != nil {
+		t.Errorf("expected the pinned binary allowed, got %v", err)
+	}
+
+	plugin := proxy.ServerEntry{Name: "market", Transport: "http", URL: "http://127.0.0.1:1/mcp",
+		Env: map[string]string{"CLAUDE_PLUGIN_ROOT": filepath.Join(dir, ".claude", "plugins", "marketplaces", "acme", "market")}}
+	backends.checkProvenance(&plugin)
+	all, err := backends.Provenance()
+	if err != nil || len(all) != 2 || all[0].Name != "market" || all[0].Source != SourceMarketplace ||
+		all[0].InstallPath != plugin.Env["CLAUDE_PLUGIN_ROOT"] || all[0].BinaryHash != "" || all[1].Pinned != changed.BinaryHash {
+		t.Errorf("unexpected provenance %+v (err=%v)", all, err)
+	}
+
+	if err := (BinaryPins{"notes": "abc"}).Validate(); err == nil {
+		t.Error("expected a short pin to be rejected")
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// ReplayOptions controls how a recorded session is re-driven.
+type ReplayOptions struct {
+	// Mock answers backend requests from the recording instead of starting
+	// the configured backends.
+	Mock bool
+	// InitTimeout bounds the wait for backends after initialize.
+	InitTimeout time.Duration
+}
+
+// ReplayDiff is a client request whose response differs from the recording.
+type ReplayDiff struct {
+	Seq      int64           `json:"seq"`
+	Method   string          `json:"method"`
+	ID       interface{}     `json:"id"`
+	Recorded json.RawMessage `json:"recorded,omitempty"`
+	Replayed json.RawMessage `json:"replayed"`
+}
+
+// ReplayReport summarizes a replay.
+type ReplayReport struct {
+	Requests int          `json:"requests"`
+	Matched  int          `json:"matched"`
+	Diffs    []ReplayDiff `json:"diffs"`
+}
+
+// MockRegistry returns a registry with a "mock" entry for every backend
+// that appears in the recording, in order of first appearance.
+func MockRegistry(messages []proxy.RecordedMessage) *proxy.ServerRegistry {
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{}}
+	seen := make(map[string]bool)
+	for _, m := range messages {
+		if m.Server == "" || seen[m.Server] {
+			continue
+		}
+		seen[m.Server] = true
+		registry.Servers = append(registry.Servers, proxy.ServerEntry{Name: m.Server, Transport: "mock"})
+	}
+	return registry
+}
+
+// Replay feeds the client side of a recording through the proxy and compares
+// each response with the recorded one. Responses are compared after
+// redaction, since the recording only holds redacted messages.
+//
+// With opts.Mock the server should be created with a registry from
+// MockRegistry; otherwise its configured backends are re-driven.
+func (s *StdioServer) Replay(ctx context.Context, messages []proxy.RecordedMessage, opts ReplayOptions) (*ReplayReport, error) {
+	if opts.Mock {
+		s.backendManager.SetMockDialer(func(entry *proxy.ServerEntry) (proxy.Transport, error) {
+			return newReplayTransport(messages, entry.Name), nil
+		})
+	}
+	if opts.InitTimeout == 0 {
+		opts.InitTimeout = backendInitTimeout
+	}
+
+	// Answers to proxy-initiated requests (elicitation, sampling) are read
+	// back in recorded order; everything the proxy writes is discarded.
+	var answers bytes.Buffer
+	var requests []proxy.RecordedMessage
+	recorded := make(map[string]json.RawMessage)
+	for _, m := range messages {
+		var envelope struct {
+			ID     interface{} `json:"id"`
+			Method string      `json:"method"`
+		}
+		if err := json.Unmarshal(m.Message, &envelope); err != nil {
+			continue
+		}
+		switch m.Direction {
+		case proxy.DirClientToProxy:
+			if envelope.Method == "" {
+				answers.Write(m.Message)
+				answers.WriteByte('\n')
+			} else {
+				requests = append(requests, m)
+			}
+		case proxy.DirProxyToClient:
+			if envelope.Method == "" && envelope.ID != nil {
+				recorded[idKey(envelope.ID)] = m.Message
+			}
+		}
+	}
+	s.reader = proxy.NewMessageReader(&answers, 0)
+	s.out = io.Discard
+
+	report := &ReplayReport{}
+	for _, m := range requests {
+		var request JSONRPCRequest
+		if err := json.Unmarshal(m.Message, &request); err != nil {
+			return nil, fmt.Errorf("seq %d: %w", m.Seq, err)
+		}
+
+		response := s.handleRequest(ctx, request)
+		if request.Method == "initialize" {
+			s.backendManager.WaitForInitialization(ctx, opts.InitTimeout)
+		}
+		if request.ID == nil {
+			continue
+		}
+
+		report.Requests++
+		replayed := []byte("null")
+		if response != nil {
+			data, err := json.Marshal(response)
+			if err != nil {
+				return nil, fmt.Errorf("seq %d: %w", m.Seq, err)
+			}
+			replayed = proxy.RedactMessage(data)
+		}
+
+		want := recorded[idKey(request.ID)]
+		if jsonEqual(want, replayed) {
+			report.Matched++
+			continue
+		}
+		report.Diffs = append(report.Diffs, ReplayDiff{
+			Seq:      m.Seq,
+			Method:   request.Method,
+			ID:       request.ID,
+			Recorded: want,
+			Replayed: replayed,
+		})
+	}
+	return report, nil
+}
+
+// idKey maps a JSON-RPC id to a map key, so 1 and "1" stay distinct.
+func idKey(id interface{}) string {
+	data, _ := json.Marshal(id)
+	return string(data)
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// replayTransport stands in for a backend by answering each request with the
+// response recorded for the same method and params, falling back to the next
+// unused response for the method. Responses carry the id of the new request.
+type replayTransport struct {
+	exact    map[string][]*replayResponse
+	byMethod map[string][]*replayResponse
+	pending  chan json.RawMessage
+	mu       sync.Mutex
+}
+
+type replayResponse struct {
+	data json.RawMessage
+	used bool
+}
+
+func newReplayTransport(messages []proxy.RecordedMessage, server string) *replayTransport {
+	t := &replayTransport{
+		exact:    make(map[string][]*replayResponse),
+		byMethod: make(map[string][]*replayResponse),
+		pending:  make(chan json.RawMessage, 16),
+	}
+
+	var lastMethod, lastKey string
+	for _, m := range messages {
+		if m.Server != server {
+			continue
+		}
+		switch m.Direction {
+		case proxy.DirProxyToBackend:
+			lastMethod, lastKey = replayRequestKey(m.Message)
+		case proxy.DirBackendToProxy:
+			if lastMethod == "" {
+				continue
+			}
+			r := &replayResponse{data: m.Message}
+			t.exact[lastKey] = append(t.exact[lastKey], r)
+			t.byMethod[lastMethod] = append(t.byMethod[lastMethod], r)
+			lastMethod, lastKey = "", ""
+		}
+	}
+	return t
+}
+
+// replayRequestKey returns a request's method and a key identifying it by
+// method and params (key order is normalized by re-marshalling).
+func replayRequestKey(msg []byte) (method, key string) {
+	var req struct {
+		Method string      `json:"method"`
+		Params interface{} `json:"params"`
+	}
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return "", ""
+	}
+	params, _ := json.Marshal(req.Params)
+	return req.Method, req.Method + " " + string(params)
+}
+
+func (t *replayTransport) SendMessage(msg []byte) error {
+	var req struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	method, key := replayRequestKey(msg)
+
+	t.mu.Lock()
+	response := t.take(key, method)
+	t.mu.Unlock()
+
+	var reply map[string]interface{}
+	if response == nil || json.Unmarshal(response, &reply) != nil {
+		reply = map[string]interface{}{
+			"jsonrpc": "2.0",
+			"error": map[string]interface{}{
+				"code":    -32603,
+				"message": "no recorded response for " + method,
+			},
+		}
+	}
+	reply["id"] = req.ID
+	data, _ := json.Marshal(reply)
+	t.pending <- data
+	return nil
+}
+
+// take returns the first unused recorded response for the exact request,
+// else for its method, and marks it used.
+func (t *replayTransport) take(key, method string) json.RawMessage {
+	for _, queue := range [][]*replayResponse{t.exact[key], t.byMethod[method]} {
+		for _, r := range queue {
+			if !r.used {
+				r.used = true
+				return r.data
+			}
+		}
+	}
+	return nil
+}
+
+func (t *replayTransport) ReceiveMessage() ([]byte, error) {
+	return <-t.pending, nil
+}
+
+func (t *replayTransport) Close() error { return nil }
+
+func (t *replayTransport) SupportsServerToClient() bool { return false }
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// recordedEchoSession is a recording of a client listing and calling the
+// "say" tool of an "echo" backend.
+func recordedEchoSession() []proxy.RecordedMessage {
+	var messages []proxy.RecordedMessage
+	add := func(direction, server, msg string) {
+		messages = append(messages, proxy.RecordedMessage{
+			Seq:       int64(len(messages) + 1),
+			Direction: direction,
+			Server:    server,
+			Message:   json.RawMessage(msg),
+		})
+	}
+
+	add(proxy.DirClientToProxy, "", `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"`+proxy.MCPProtocolVersion+`","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	add(proxy.DirProxyToBackend, "echo", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	add(proxy.DirBackendToProxy, "echo", `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"`+proxy.MCPProtocolVersion+`","capabilities":{"tools":{}},"serverInfo":{"name":"echo","version":"1"}}}`)
+	add(proxy.DirProxyToBackend, "echo", `{"jsonrpc":"2.0","id":1,"method":"tools/list","params":{}}`)
+	add(proxy.DirBackendToProxy, "echo", `{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"say","inputSchema":{"type":"object"}}]}}`)
+	add(proxy.DirClientToProxy, "", `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	add(proxy.DirClientToProxy, "", `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo:say","arguments":{"text":"hi"}}}`)
+	add(proxy.DirProxyToBackend, "echo", `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"say","arguments":{"text":"hi"}}}`)
+	add(proxy.DirBackendToProxy, "echo", `{"jsonrpc":"2.0","id":2,"result":{"content":[{"type":"text","text":"hi"}]}}`)
+	add(proxy.DirProxyToClient, "", `{"jsonrpc":"2.0","id":2,"result":{"content":[{"type":"text","text":"hi"}]}}`)
+	return messages
+}
+
+func replayEchoSession(t *testing.T, messages []proxy.RecordedMessage, costs CostPolicy) *ReplayReport {
+	t.Helper()
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, MockRegistry(messages), NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.SetCostPolicy(costs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report, err := srv.Replay(ctx, messages, ReplayOptions{Mock: true, InitTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	return report
+}
+
+func TestReplayWithMockBackends(t *testing.T) {
+	messages := recordedEchoSession()
+
+	report := replayEchoSession(t, messages, CostPolicy{})
+	if report.Requests != 2 {
+		t.Fatalf("expected 2 replayed requests, got %d", report.Requests)
+	}
+	for _, d := range report.Diffs {
+		if d.Method != "initialize" {
+			t.Errorf("unexpected diff for %s: recorded %s, replayed %s", d.Method, d.Recorded, d.Replayed)
+		}
+	}
+	if report.Matched != 1 {
+		t.Errorf("expected the tools/call response to match the recording, got %+v", report)
+	}
+
+	// A budget added since the recording changes the outcome of the call
+	budget := CostPolicy{
+		Servers: map[string]float64{"echo": 1},
+		Budgets: []Budget{{PerSession: 0.5, Action: BudgetBlock}},
+	}
+	report = replayEchoSession(t, messages, budget)
+	var callDiff *ReplayDiff
+	for i := range report.Diffs {
+		if report.Diffs[i].Method == "tools/call" {
+			callDiff = &report.Diffs[i]
+		}
+	}
+	if callDiff == nil {
+		t.Fatalf("expected the budget to change the tools/call response, got %+v", report)
+	}
+	var replayed JSONRPCResponse
+	if err := json.Unmarshal(callDiff.Replayed, &replayed); err != nil || replayed.Error == nil || replayed.Error.Code != ErrCodeDenied {
+		t.Fatalf("expected a denied response, got %s", callDiff.Replayed)
+	}
+	if data, _ := replayed.Error.Data.(map[string]interface{}); data["blocked_by"] != BlockedByBudget || data["action"] != string(BudgetBlock) {
+		t.Errorf("expected budget block data, got %s", callDiff.Replayed)
+	}
+}
+
+func TestReplayTransportMatchesByParams(t *testing.T) {
+	messages := recordedEchoSession()
+	messages = append(messages,
+		proxy.RecordedMessage{Direction: proxy.DirProxyToBackend, Server: "echo", Message: json.RawMessage(`{"id":3,"method":"tools/call","params":{"name":"say","arguments":{"text":"bye"}}}`)},
+		proxy.RecordedMessage{Direction: proxy.DirBackendToProxy, Server: "echo", Message: json.RawMessage(`{"id":3,"result":{"content":[{"type":"text","text":"bye"}]}}`)},
+	)
+	transport := newReplayTransport(messages, "echo")
+
+	// Out of recorded order, each call still gets its own response
+	for _, text := range []string{"bye", "hi", "again"} {
+		req := `{"jsonrpc":"2.0","id":9,"method":"tools/call","params":{"arguments":{"text":"` + text + `"},"name":"say"}}`
+		if err := transport.SendMessage([]byte(req)); err != nil {
+			t.Fatalf("send failed: %v", err)
+		}
+		data, _ := transport.ReceiveMessage()
+		var resp struct {
+			ID     int                    `json:"id"`
+			Result map[string]interface{} `json:"result"`
+			Error  map[string]interface{} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if resp.ID != 9 {
+			t.Errorf("expected response id to be rewritten to 9, got %d", resp.ID)
+		}
+		switch text {
+		case "again":
+			if resp.Error == nil {
+				t.Errorf("expected an error once recorded responses are used up, got %s", data)
+			}
+		default:
+			content, _ := json.Marshal(resp.Result["content"])
+			if want := `[{"text":"` + text + `","type":"text"}]`; string(content) != want {
+				t.Errorf("expected %s, got %s", want, content)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// HeaderRequestID carries the request ID of an HTTP-mode call. A client may
+// send its own, which is kept if valid; the proxy always echoes the one it
+// used in the response.
+const HeaderRequestID = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// NewRequestID returns a fresh proxy-wide request ID such as
+// req-3f9c2a7b1e0d4c58.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "req-" + hex.EncodeToString(b)
+}
+
+// WithRequestID returns a context carrying a request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID carried by ctx, or "".
+func RequestIDFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ensureRequestID returns ctx with a request ID, generating one if it has none.
+func ensureRequestID(ctx context.Context) context.Context {
+	if RequestIDFrom(ctx) != "" {
+		return ctx
+	}
+	return WithRequestID(ctx, NewRequestID())
+}
+
+// validRequestID reports whether a client-supplied request ID may be used
+// as is: up to 64 letters, digits, dots, dashes and underscores, so it is
+// safe in logs and queries.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestIDData adds a request ID to a JSON-RPC error's data, so a
+// client reporting a failure can quote it. Data the proxy doesn't know the
+// shape of is left alone.
+func withRequestIDData(data interface{}, id string) interface{} {
+	switch d := data.(type) {
+	case nil:
+		return map[string]interface{}{"request_id": id}
+	case *BlockError:
+		d.RequestID = id
+		return d
+	case map[string]interface{}:
+		if _, ok := d["request_id"]; ok {
+			return d
+		}
+		withID := make(map[string]interface{}, len(d)+1)
+		for k, v := range d {
+			withID[k] = v
+		}
+		withID["request_id"] = id
+		return withID
+	case string:
+		return map[string]interface{}{"detail": d, "request_id": id}
+	}
+	return data
+}
+
+// requestLogger prefixes every message with the request it was logged for.
+type requestLogger struct {
+	Logger
+	prefix string
+}
+
+// loggerFor returns logger prefixing its messages with ctx's request ID, or
+// logger itself if ctx has none.
+func loggerFor(ctx context.Context, logger Logger) Logger {
+	id := RequestIDFrom(ctx)
+	if id == "" {
+		return logger
+	}
+	return &requestLogger{Logger: logger, prefix: "[" + id + "] "}
+}
+
+func (l *requestLogger) Debug(format string, args ...interface{}) {
+	l.Logger.Debug(l.prefix+format, args...)
+}
+
+func (l *requestLogger) Info(format string, args ...interface{}) {
+	l.Logger.Info(l.prefix+format, args...)
+}
+
+func (l *requestLogger) Warn(format string, args ...interface{}) {
+	l.Logger.Warn(l.prefix+format, args...)
+}
+
+func (l *requestLogger) Error(format string, args ...interface{}) {
+	l.Logger.Error(l.prefix+format, args...)
+}
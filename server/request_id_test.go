@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestRequestIDCorrelation(t *testing.T) {
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "search", Transport: "mock", Fixture: writeTestFixture(t)},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	// An allowed call: the audit entry and the backend's trace events share its ID
+	call := WithRequestID(ctx, "req-allowed")
+	resp := srv.handleRequest(call, JSONRPCRequest{ID: 2, Method: "tools/call", Params: json.RawMessage(`{"name":"search:web_search","arguments":{"query":"go"}}`)}).(JSONRPCResponse)
+	if resp.Error != nil {
+		t.Fatalf("expected the call to succeed, got %+v", resp.Error)
+	}
+	entries, err := QueryAuditLog(srv.db, AuditFilter{RequestID: "req-allowed"})
+	if err != nil || len(entries) != 1 || entries[0].ToolName != "search:web_search" {
+		t.Errorf("expected one audit entry for the request, got %+v (err=%v)", entries, err)
+	}
+	traced := 0
+	for _, ev := range srv.trace.List() {
+		if ev.RequestID == "req-allowed" {
+			traced++
+		}
+	}
+	if traced != 2 {
+		t.Errorf("expected the call's two backend trace events to carry its ID, got %d", traced)
+	}
+
+	// A denied call gets a fresh ID, returned to the client with the denial
+	srv.GetKillSwitch().Set(KillSwitchState{Engaged: true, Reason: "test"})
+	resp = srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "tools/call", Params: json.RawMessage(`{"name":"search:web_search","arguments":{"query":"go"}}`)}).(JSONRPCResponse)
+	if resp.Error == nil {
+		t.Fatalf("expected the call denied by the kill switch, got %+v", resp)
+	}
+	block, _ := resp.Error.Data.(*BlockError)
+	if block == nil || !strings.HasPrefix(block.RequestID, "req-") {
+		t.Fatalf("expected the denial to carry a request ID, got %+v", resp.Error.Data)
+	}
+	entries, err = QueryAuditLog(srv.db, AuditFilter{Query: block.RequestID})
+	if err != nil || len(entries) != 1 || entries[0].BlockReason != "kill_switch" {
+		t.Errorf("expected the denial found in the audit log by its ID, got %+v (err=%v)", entries, err)
+	}
+
+	// Errors with plain string data keep it as the detail
+	resp = srv.handleRequest(ctx, JSONRPCRequest{ID: 4, Method: "bogus/method"}).(JSONRPCResponse)
+	if data, _ := resp.Error.Data.(map[string]interface{}); data["detail"] != "bogus/method" || !strings.HasPrefix(data["request_id"].(string), "req-") {
+		t.Errorf("expected detail and request ID in the error data, got %+v", resp.Error.Data)
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"req-0123456789abcdef":  true,
+		"client.trace_42":       true,
+		"":                      false,
+		"has space":             false,
+		"line\nbreak":           false,
+		strings.Repeat("a", 65): false,
+	} {
+		if got := validRequestID(id); got != want {
+			t.Errorf("validRequestID(%q) = %v, want %v", id, got, want)
+		}
+	}
+	if id := NewRequestID(); !validRequestID(id) || len(id) != len("req-")+16 {
+		t.Errorf("expected a valid generated ID, got %q", id)
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// retentionInterval is how often entries past the retention period are purged.
+const retentionInterval = time.Hour
+
+// PurgeResult reports what a purge removed.
+type PurgeResult struct {
+	Before     time.Time `json:"before"`
+	Entries    int64     `json:"entries"`     // audit entries deleted
+	ThroughID  int64     `json:"through_id"`  // the newest entry deleted
+	Traces     int       `json:"traces"`      // trace events dropped, in memory and stored
+	SizeBefore int64     `json:"size_before"` // database size in bytes
+	SizeAfter  int64     `json:"size_after"`  // after VACUUM
+	Reclaimed  int64     `json:"reclaimed"`   // bytes freed on disk
+}
+
+// auditPurgesSchema records each purge, so the audit hash chain can resume
+// after the purged entries and an investigator can see what was removed.
+const auditPurgesSchema = `
+	CREATE TABLE IF NOT EXISTS audit_purges (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		before TIMESTAMP,
+		entries INTEGER,
+		through_id INTEGER,
+		through_hash TEXT,
+		purged_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+// auditChainStart returns the newest entry removed by a purge and its hash:
+// the chain of the remaining entries continues from it.
+func auditChainStart(db *sql.DB) (int64, string, error) {
+	if _, err := db.Exec(auditPurgesSchema); err != nil {
+		return 0, "", fmt.Errorf("failed to create audit_purges table: %w", err)
+	}
+	var id int64
+	var hash string
+	err := db.QueryRow(`SELECT through_id, COALESCE(through_hash, '') FROM audit_purges ORDER BY through_id DESC LIMIT 1`).Scan(&id, &hash)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	return id, hash, err
+}
+
+// PurgeAuditLog deletes the audit entries recorded before a time and compacts
+// the database. Entries are appended in time order, so the purge removes a
+// prefix of the log; the purge is recorded so VerifyAuditLog still checks
+// the entries that remain.
+func PurgeAuditLog(db *sql.DB, before time.Time) (*PurgeResult, error) {
+	if err := ensureAuditSchema(db); err != nil {
+		return nil, err
+	}
+	if _, _, err := auditChainStart(db); err != nil {
+		return nil, err
+	}
+	size := databaseSize(context.Background(), db)
+	result := &PurgeResult{Before: before, SizeBefore: size, SizeAfter: size}
+
+	// Timestamps are stored in more than one text format, so they are
+	// compared here rather than in SQL
+	rows, err := db.Query(`SELECT id, timestamp, COALESCE(entry_hash, '') FROM audit_log ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	var throughHash string
+	for rows.Next() {
+		var id int64
+		var ts time.Time
+		var hash string
+		if err := rows.Scan(&id, &ts, &hash); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		if !ts.Before(before) {
+			break
+		}
+		result.ThroughID, throughHash = id, hash
+		result.Entries++
+	}
+	rows.Close()
+	if result.Entries == 0 {
+		return result, nil
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM audit_log WHERE id <= ?`, result.ThroughID); err != nil {
+		return nil, fmt.Errorf("failed to purge audit log: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO audit_purges (before, entries, through_id, through_hash) VALUES (?, ?, ?, ?)`,
+		before.UTC(), result.Entries, result.ThroughID, throughHash); err != nil {
+		return nil, fmt.Errorf("failed to record audit purge: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to purge audit log: %w", err)
+	}
+
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return nil, fmt.Errorf("failed to compact database: %w", err)
+	}
+	result.SizeAfter = databaseSize(context.Background(), db)
+	result.Reclaimed = result.SizeBefore - result.SizeAfter
+	return result, nil
+}
+
+// Purge deletes audit entries and trace events older than before.
+func Purge(db *sql.DB, tracer *proxy.TraceRecorder, before time.Time) (*PurgeResult, error) {
+	result := &PurgeResult{Before: before}
+	if db != nil {
+		// Stored traces go first, so the audit purge's VACUUM reclaims them too
+		stored, err := PurgeStoredTraces(db, before)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = PurgeAuditLog(db, before); err != nil {
+			return nil, err
+		}
+		result.Traces = int(stored)
+	}
+	if tracer != nil {
+		result.Traces += tracer.Prune(before)
+	}
+	return result, nil
+}
+
+// RunRetention purges audit entries and trace events older than retention
+// every hour until ctx is done.
+func RunRetention(ctx context.Context, db *sql.DB, tracer *proxy.TraceRecorder, retention time.Duration, logger Logger) {
+	purge := func() {
+		result, err := Purge(db, tracer, time.Now().Add(-retention))
+		if err != nil {
+			logger.Warn("retention purge failed: %v", err)
+			return
+		}
+		if result.Entries > 0 || result.Traces > 0 {
+			logger.Info("retention purge: removed %d audit entries and %d trace events, reclaimed %d bytes",
+				result.Entries, result.Traces, result.Reclaimed)
+		}
+	}
+
+	purge()
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purge()
+		}
+	}
+}
+
+// startRetention runs RunRetention for a proxy in the background. The
+// returned function stops it, waiting for a purge in progress to finish.
+func startRetention(ctx context.Context, db *sql.DB, tracer *proxy.TraceRecorder, config Config, logger Logger) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunRetention(ctx, db, tracer, config.Retention, logger)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// ParsePurgeBefore parses the cutoff of a purge: a date (2006-01-02), an
+// RFC 3339 time, or an age such as 30d or 12h before now.
+func ParsePurgeBefore(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	age, err := ParseSeriesDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cutoff %q: want a date such as 2026-01-31 or an age such as 30d", s)
+	}
+	return now.Add(-age), nil
+}
+
+// RequestAuditPurge asks a running dashboard to purge entries older than
+// before, sending token if the dashboard requires one.
+func RequestAuditPurge(baseURL, token string, before time.Time) (*PurgeResult, error) {
+	endpoint := strings.TrimRight(baseURL, "/") + "/api/v1/audit/purge?before=" + url.QueryEscape(before.Format(time.RFC3339))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(nil))
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := (&http.Client{Timeout: 5 * time.Minute}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach dashboard at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dashboard returned %s", resp.Status)
+	}
+	var result PurgeResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid purge response: %w", err)
+	}
+	return &result, nil
+}
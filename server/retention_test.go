@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestPurgeAuditLog(t *testing.T) {
+	db := openChainTestDB(t)
+	now := time.Now()
+	for i, age := range []time.Duration{60, 45, 31, 10, 1} {
+		e := AuditEntry{ServerID: "github", Method: "tools/call", ToolName: "github:create_issue", Timestamp: now.Add(-age * 24 * time.Hour)}
+		if err := RecordAuditEntry(db, e); err != nil {
+			t.Fatalf("Failed to record entry %d: %v", i, err)
+		}
+	}
+	anchor, err := AuditHead(db)
+	if err != nil || anchor == nil {
+		t.Fatalf("Failed to read head: %v", err)
+	}
+	early := AuditAnchor{ID: 2, Hash: "purged"}
+
+	tracer := proxy.NewTraceRecorder(10)
+	tracer.Add(proxy.TraceEvent{Stage: "forward"})
+
+	result, err := Purge(db, tracer, now.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if result.Entries != 3 || result.ThroughID != 3 || result.Traces != 0 {
+		t.Fatalf("Expected 3 entries through 3 and no trace events purged, got %+v", result)
+	}
+	if result.SizeBefore <= 0 || result.SizeAfter <= 0 || result.Reclaimed != result.SizeBefore-result.SizeAfter {
+		t.Errorf("Expected database sizes, got %+v", result)
+	}
+	if n := tracer.Prune(time.Now().Add(time.Second)); n != 1 || len(tracer.List()) != 0 {
+		t.Errorf("Expected the trace event to be pruned, got %d", n)
+	}
+
+	// The remaining entries still verify, and anchors of purged entries are ignored
+	v, err := VerifyAuditLog(db, []AuditAnchor{early, *anchor})
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if !v.OK() || v.Entries != 2 || v.Purged != 3 || v.Anchors != 1 {
+		t.Fatalf("Expected an intact chain of 2 entries after the purge, got %+v", v)
+	}
+
+	// Removing more entries outside a purge is still caught
+	if _, err := db.Exec(`DELETE FROM audit_log WHERE id = 4`); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if v, err := VerifyAuditLog(db, nil); err != nil || v.OK() {
+		t.Errorf("Expected the deletion to be reported, got %+v, %v", v, err)
+	}
+
+	if result, err := PurgeAuditLog(db, now.Add(-30*24*time.Hour)); err != nil || result.Entries != 0 {
+		t.Errorf("Expected nothing left to purge, got %+v, %v", result, err)
+	}
+}
+
+func TestParsePurgeBefore(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"30d", now.Add(-30 * 24 * time.Hour)},
+		{"12h", now.Add(-12 * time.Hour)},
+		{"2026-03-01T00:00:00Z", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-03-01", time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := ParsePurgeBefore(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParsePurgeBefore(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "last month", "-5d"} {
+		if _, err := ParsePurgeBefore(in, now); err == nil {
+			t.Errorf("Expected %q to be rejected", in)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// settingRewritePolicy persists the applied rewrites section in the rules store.
+const settingRewritePolicy = "rewrite_policy"
+
+// ArgumentRewrite changes the arguments of matching tool calls instead of
+// allowing or blocking them as they are:
+//
+//	rewrites:
+//	  - name: dry-run-deploys
+//	    tools: [Bash, "ci:*"]
+//	    append: {command: --dry-run}
+//	  - name: bounded-queries
+//	    tools: ["db:query"]
+//	    max: {limit: 100}
+//	  - name: no-recursive-deletes
+//	    tools: ["fs:delete*"]
+//	    modes: [strict]
+//	    remove: [recursive]
+//
+// set replaces arguments with fixed values, max caps numeric arguments (and
+// adds them when missing), remove drops arguments, and append adds a flag to
+// a string argument, or an item to a list argument, unless it is there
+// already. Only top-level arguments are rewritten.
+type ArgumentRewrite struct {
+	Name   string                 `yaml:"name" json:"name"`
+	Tools  []string               `yaml:"tools" json:"tools"`                     // tool names; * matches a prefix or suffix
+	Modes  []PolicyMode           `yaml:"modes,omitempty" json:"modes,omitempty"` // policy modes it applies in; all if empty
+	Set    map[string]interface{} `yaml:"set,omitempty" json:"set,omitempty"`
+	Max    map[string]float64     `yaml:"max,omitempty" json:"max,omitempty"`
+	Remove []string               `yaml:"remove,omitempty" json:"remove,omitempty"`
+	Append map[string]string      `yaml:"append,omitempty" json:"append,omitempty"`
+}
+
+// RewritePolicy is the `rewrites:` section of a policy file. Every matching
+// rewrite applies, in order. Rewrites take effect in stdio mode, before the
+// call is checked against the sandbox, egress, DLP and later policies, and
+// each change is recorded in the call's audit entry.
+type RewritePolicy []ArgumentRewrite
+
+// IsZero reports whether there are no rewrites.
+func (rp RewritePolicy) IsZero() bool {
+	return len(rp) == 0
+}
+
+// Validate checks that every rewrite is named, targets tools and changes something.
+func (rp RewritePolicy) Validate() error {
+	seen := make(map[string]bool)
+	for i, r := range rp {
+		if r.Name == "" {
+			return fmt.Errorf("rewrites[%d]: name is required", i)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("rewrites: duplicate name %q", r.Name)
+		}
+		seen[r.Name] = true
+		if len(r.Tools) == 0 {
+			return fmt.Errorf("rewrites.%s: tools is required", r.Name)
+		}
+		for _, mode := range r.Modes {
+			switch mode {
+			case StrictMode, ModerateMode, PermissiveMode:
+			default:
+				return fmt.Errorf("rewrites.%s: invalid mode %q", r.Name, mode)
+			}
+		}
+		if len(r.Set) == 0 && len(r.Max) == 0 && len(r.Remove) == 0 && len(r.Append) == 0 {
+			return fmt.Errorf("rewrites.%s: set, max, remove or append is required", r.Name)
+		}
+		for arg, value := range r.Append {
+			if strings.TrimSpace(value) == "" {
+				return fmt.Errorf("rewrites.%s: append.%s is empty", r.Name, arg)
+			}
+		}
+	}
+	return nil
+}
+
+// String renders the rewrites compactly for diffs, e.g.
+// "bounded-queries(db:query max:limit=100) dry-run-deploys(Bash,ci:* append:command=--dry-run)".
+func (rp RewritePolicy) String() string {
+	parts := make([]string, len(rp))
+	for i, r := range rp {
+		fields := []string{strings.Join(r.Tools, ",")}
+		if len(r.Modes) > 0 {
+			modes := make([]string, len(r.Modes))
+			for j, mode := range r.Modes {
+				modes[j] = string(mode)
+			}
+			fields = append(fields, "modes:"+strings.Join(modes, ","))
+		}
+		for _, arg := range sortedKeys(r.Set) {
+			fields = append(fields, "set:"+arg+"="+describeArgument(r.Set[arg], true))
+		}
+		for _, arg := range sortedKeys(r.Max) {
+			fields = append(fields, fmt.Sprintf("max:%s=%v", arg, r.Max[arg]))
+		}
+		if len(r.Remove) > 0 {
+			fields = append(fields, "remove:"+strings.Join(r.Remove, ","))
+		}
+		for _, arg := range sortedKeys(r.Append) {
+			fields = append(fields, "append:"+arg+"="+r.Append[arg])
+		}
+		parts[i] = r.Name + "(" + strings.Join(fields, " ") + ")"
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r ArgumentRewrite) matches(toolName string, mode PolicyMode) bool {
+	if len(r.Modes) > 0 && !slices.Contains(r.Modes, mode) {
+		return false
+	}
+	for _, pattern := range r.Tools {
+		if matchWildcard(toolName, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply rewrites the arguments of a call to toolName in the given policy
+// mode. It returns the arguments to forward, a copy if anything changed, and
+// a description of each change, e.g. `bounded-queries: limit 500 -> 100`.
+func (rp RewritePolicy) Apply(toolName string, mode PolicyMode, args map[string]interface{}) (map[string]interface{}, []string) {
+	if rp.IsZero() {
+		return args, nil
+	}
+	rewritten := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		rewritten[k] = v
+	}
+	var changes []string
+	for _, r := range rp {
+		if !r.matches(toolName, mode) {
+			continue
+		}
+		for _, arg := range sortedKeys(r.Set) {
+			value := r.Set[arg]
+			if old, ok := rewritten[arg]; !ok || fmt.Sprint(old) != fmt.Sprint(value) {
+				changes = append(changes, fmt.Sprintf("%s: %s %s -> %s", r.Name, arg, describeArgument(old, ok), describeArgument(value, true)))
+				rewritten[arg] = value
+			}
+		}
+		for _, arg := range sortedKeys(r.Max) {
+			limit := r.Max[arg]
+			old, ok := rewritten[arg]
+			if n, isNumber := numericArgument(old); ok && isNumber && n <= limit {
+				continue
+			}
+			changes = append(changes, fmt.Sprintf("%s: %s %s -> %v", r.Name, arg, describeArgument(old, ok), limit))
+			rewritten[arg] = limit
+		}
+		for _, arg := range r.Remove {
+			if old, ok := rewritten[arg]; ok {
+				changes = append(changes, fmt.Sprintf("%s: removed %s (was %s)", r.Name, arg, describeArgument(old, true)))
+				delete(rewritten, arg)
+			}
+		}
+		for _, arg := range sortedKeys(r.Append) {
+			if value, ok := appendArgument(rewritten[arg], r.Append[arg]); ok {
+				changes = append(changes, fmt.Sprintf("%s: %s += %q", r.Name, arg, r.Append[arg]))
+				rewritten[arg] = value
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return args, nil
+	}
+	return rewritten, changes
+}
+
+// appendArgument adds flag to a string argument, separated by a space, or
+// as an item to a list argument. It reports false if flag is already there
+// or the argument is neither.
+func appendArgument(current interface{}, flag string) (interface{}, bool) {
+	switch v := current.(type) {
+	case string:
+		for _, field := range strings.Fields(v) {
+			if field == flag {
+				return v, false
+			}
+		}
+		if v == "" {
+			return flag, true
+		}
+		return v + " " + flag, true
+	case []interface{}:
+		for _, item := range v {
+			if item == flag {
+				return v, false
+			}
+		}
+		return append(slices.Clone(v), flag), true
+	}
+	return current, false
+}
+
+func numericArgument(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func describeArgument(v interface{}, present bool) string {
+	if !present {
+		return "(unset)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type rewritesKey struct{}
+
+// withRewrites returns a context carrying the changes made to a call's
+// arguments, so every audit entry of the call records them.
+func withRewrites(ctx context.Context, changes []string) context.Context {
+	return context.WithValue(ctx, rewritesKey{}, strings.Join(changes, "; "))
+}
+
+// rewritesFrom returns the changes carried by ctx, or "".
+func rewritesFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	changes, _ := ctx.Value(rewritesKey{}).(string)
+	return changes
+}
+
+func encodeRewritePolicy(rp RewritePolicy) (string, error) {
+	if rp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(rp)
+	return string(data), err
+}
+
+func decodeRewritePolicy(raw string) (RewritePolicy, error) {
+	var rp RewritePolicy
+	if raw == "" {
+		return rp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &rp); err != nil {
+		return rp, fmt.Errorf("invalid stored rewrite policy: %w", err)
+	}
+	return rp, nil
+}
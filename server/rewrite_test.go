@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRewritePolicy(t *testing.T) {
+	var pf PolicyFile
+	if err := yaml.Unmarshal([]byte(`
+rewrites:
+  - name: dry-run-deploys
+    tools: [Bash, "ci:*"]
+    append: {command: --dry-run, flags: --dry-run}
+  - name: bounded-queries
+    tools: ["db:query"]
+    max: {limit: 100}
+    set: {timeout_ms: 5000}
+  - name: no-recursive-deletes
+    tools: ["fs:delete*"]
+    modes: [strict]
+    remove: [recursive]
+`), &pf); err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	if err := pf.Validate(); err != nil {
+		t.Fatalf("expected a valid policy, got %v", err)
+	}
+	rp := pf.Rewrites
+
+	tests := []struct {
+		name    string
+		tool    string
+		mode    PolicyMode
+		args    map[string]interface{}
+		want    map[string]interface{}
+		changes int
+	}{
+		{"append to a command", "ci:deploy", ModerateMode,
+			map[string]interface{}{"command": "deploy prod", "flags": []interface{}{"-v"}},
+			map[string]interface{}{"command": "deploy prod --dry-run", "flags": []interface{}{"-v", "--dry-run"}}, 2},
+		{"flag already there", "Bash", ModerateMode,
+			map[string]interface{}{"command": "deploy --dry-run prod"},
+			map[string]interface{}{"command": "deploy --dry-run prod"}, 0},
+		{"cap a limit", "db:query", ModerateMode,
+			map[string]interface{}{"sql": "select 1", "limit": float64(500), "timeout_ms": float64(5000)},
+			map[string]interface{}{"sql": "select 1", "limit": float64(100), "timeout_ms": float64(5000)}, 1},
+		{"add a missing limit", "db:query", ModerateMode,
+			map[string]interface{}{"sql": "select 1"},
+			map[string]interface{}{"sql": "select 1", "limit": float64(100), "timeout_ms": 5000}, 2},
+		{"limit below the cap", "db:query", ModerateMode,
+			map[string]interface{}{"limit": float64(10), "timeout_ms": 5000},
+			map[string]interface{}{"limit": float64(10), "timeout_ms": 5000}, 0},
+		{"strip recursive in strict mode", "fs:delete_file", StrictMode,
+			map[string]interface{}{"path": "/tmp/x", "recursive": true},
+			map[string]interface{}{"path": "/tmp/x"}, 1},
+		{"keep recursive outside strict mode", "fs:delete_file", ModerateMode,
+			map[string]interface{}{"path": "/tmp/x", "recursive": true},
+			map[string]interface{}{"path": "/tmp/x", "recursive": true}, 0},
+		{"other tools untouched", "github:create_issue", StrictMode,
+			map[string]interface{}{"command": "x"},
+			map[string]interface{}{"command": "x"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, _ := json.Marshal(tt.args)
+			got, changes := rp.Apply(tt.tool, tt.mode, tt.args)
+			if !reflect.DeepEqual(got, tt.want) || len(changes) != tt.changes {
+				t.Errorf("got %v with changes %q, want %v with %d changes", got, changes, tt.want, tt.changes)
+			}
+			if after, _ := json.Marshal(tt.args); string(after) != string(original) {
+				t.Errorf("expected the original arguments left alone, got %s", after)
+			}
+		})
+	}
+
+	if _, changes := rp.Apply("db:query", ModerateMode, map[string]interface{}{"limit": float64(500), "timeout_ms": 5000}); len(changes) != 1 || changes[0] != "bounded-queries: limit 500 -> 100" {
+		t.Errorf("unexpected change description %q", changes)
+	}
+
+	for _, bad := range []RewritePolicy{
+		{{Tools: []string{"x"}, Remove: []string{"y"}}},
+		{{Name: "a", Remove: []string{"y"}}},
+		{{Name: "a", Tools: []string{"x"}}},
+		{{Name: "a", Tools: []string{"x"}, Modes: []PolicyMode{"paranoid"}, Remove: []string{"y"}}},
+		{{Name: "a", Tools: []string{"x"}, Remove: []string{"y"}}, {Name: "a", Tools: []string{"x"}, Remove: []string{"y"}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestStdioRewritesAreAudited(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: query
+    result:
+      content: [{type: text, text: ok}]
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "db", Transport: "mock", Fixture: fixture},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+	srv.SetRewritePolicy(RewritePolicy{{Name: "bounded-queries", Tools: []string{"db:query"}, Max: map[string]float64{"limit": 100}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "tools/call", Params: json.RawMessage(`{"name":"db:query","arguments":{"limit":5000}}`)}).(JSONRPCResponse)
+	if resp.Error != nil {
+		t.Fatalf("expected the rewritten call to go through, got %+v", resp.Error)
+	}
+	entries, err := QueryAuditLog(srv.db, AuditFilter{Tool: "db:query"})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one audit entry, got %+v (err=%v)", entries, err)
+	}
+	if entries[0].Rewrites != "bounded-queries: limit 5000 -> 100" {
+		t.Errorf("expected the rewrite in the audit entry, got %q", entries[0].Rewrites)
+	}
+	if line := FormatAuditEntry(entries[0]); !strings.Contains(line, "rewritten: bounded-queries") {
+		t.Errorf("expected the rewrite in the audit line, got %q", line)
+	}
+	if v, err := VerifyAuditLog(srv.db, nil); err != nil || !v.OK() {
+		t.Errorf("expected an intact chain, got %+v (err=%v)", v, err)
+	}
+}
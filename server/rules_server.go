@@ -16,17 +16,24 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
 )
 
 // RulesServer provides an HTTP API for instant rule checking
 // Both the MCP proxy and PreToolUse hooks query this server
 type RulesServer struct {
 	db         *sql.DB
+	store      *RulesStore
 	apiKey     string
 	httpServer *http.Server
 	port       int
 	logLevel   string
+	security   *proxy.SecurityManager
 	mu         sync.RWMutex
+
+	settingsPath string        // Claude's settings.json, for the permission sync
+	stopSync     chan struct{} // ends syncPermissionsLoop
 }
 
 // RulesServerConfig holds configuration for the rules server
@@ -35,6 +42,9 @@ type RulesServerConfig struct {
 	DBPath   string
 	APIKey   string // For semantic matching
 	LogLevel string
+	// Browser origins and Host headers accepted besides localhost
+	AllowedOrigins []string
+	AllowedHosts   []string
 }
 
 // NewRulesServer creates a new rules server instance
@@ -46,16 +56,21 @@ func NewRulesServer(config RulesServerConfig) (*RulesServer, error) {
 	}
 
 	// Initialize database schema
-	if err := initRulesDB(db); err != nil {
+	store, err := NewRulesStore(db)
+	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to initialize database: %w", err)
+		return nil, err
 	}
 
 	return &RulesServer{
 		db:       db,
+		store:    store,
 		apiKey:   config.APIKey,
 		port:     config.Port,
 		logLevel: config.LogLevel,
+		security: proxy.NewSecurityManagerForListener(fmt.Sprintf("127.0.0.1:%d", config.Port), config.AllowedOrigins, config.AllowedHosts),
+
+		settingsPath: ClaudeSettingsPath(),
 	}, nil
 }
 
@@ -73,6 +88,7 @@ func initRulesDB(db *sql.DB) error {
 		is_regex INTEGER DEFAULT 0,
 		is_semantic INTEGER DEFAULT 0,
 		block_all INTEGER DEFAULT 0,
+		schedule TEXT,
 		enabled INTEGER DEFAULT 1,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
@@ -80,6 +96,12 @@ func initRulesDB(db *sql.DB) error {
 
 	CREATE INDEX IF NOT EXISTS idx_rules_enabled ON rules(enabled);
 	CREATE INDEX IF NOT EXISTS idx_rules_scope ON rules(scope);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 	_, err := db.Exec(schema)
 	if err != nil {
@@ -88,6 +110,7 @@ func initRulesDB(db *sql.DB) error {
 
 	// Migration: add block_all column if it doesn't exist
 	_, _ = db.Exec("ALTER TABLE rules ADD COLUMN block_all INTEGER DEFAULT 0")
+	_, _ = db.Exec("ALTER TABLE rules ADD COLUMN schedule TEXT")
 
 	return nil
 }
@@ -102,9 +125,12 @@ func (rs *RulesServer) Start() error {
 	mux.HandleFunc("/api/rules/", rs.handleRuleByID)
 	mux.HandleFunc("/api/tools", rs.handleTools)
 	mux.HandleFunc("/api/health", rs.handleHealth)
+	mux.HandleFunc("/api/permission-sync", rs.handlePermissionSync)
+	mux.HandleFunc("/api/simulate", rs.handleSimulate)
 
-	// CORS middleware
-	handler := rs.corsMiddleware(mux)
+	// Origin/Host checks and CORS: the API is localhost-only, so a web page
+	// (including one using DNS rebinding) can't read or edit rules
+	handler := rs.security.Middleware(mux)
 
 	rs.httpServer = &http.Server{
 		Addr:         fmt.Sprintf("127.0.0.1:%d", rs.port),
@@ -126,11 +152,18 @@ func (rs *RulesServer) Start() error {
 		}
 	}()
 
+	rs.stopSync = make(chan struct{})
+	go rs.syncPermissionsLoop(rs.stopSync)
+
 	return nil
 }
 
 // Stop gracefully stops the server
 func (rs *RulesServer) Stop() error {
+	if rs.stopSync != nil {
+		close(rs.stopSync)
+		rs.stopSync = nil
+	}
 	if rs.httpServer != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -154,28 +187,14 @@ func (rs *RulesServer) logDebug(format string, args ...interface{}) {
 	}
 }
 
-// corsMiddleware adds CORS headers
-func (rs *RulesServer) corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
 // CheckRequest represents a rule check request
 type CheckRequest struct {
-	Tool    string `json:"tool"`
-	Method  string `json:"method"`
-	Content string `json:"content"`
-	Scope   string `json:"scope"` // "native", "mcp", or "all"
+	Tool    string   `json:"tool"`
+	Method  string   `json:"method"`
+	Content string   `json:"content"`
+	Scope   string   `json:"scope"`             // "native", "mcp", or "all"
+	Tags    []string `json:"tags,omitempty"`    // tags of the server providing the tool
+	History []string `json:"history,omitempty"` // earlier calls in the session, oldest first
 }
 
 // CheckResponse represents a rule check response
@@ -184,11 +203,11 @@ type CheckResponse struct {
 	Reason  string `json:"reason,omitempty"`
 	RuleID  int    `json:"rule_id,omitempty"`
 	// For hook compatibility
-	Decision string `json:"decision"` // "allow" or "block"
+	Decision string `json:"decision"` // "allow", "block" or "ask"
 }
 
 // handleCheck handles rule check requests
-// GET /api/check?tool=<name>&method=<method>&content=<text>&scope=<scope>
+// GET /api/check?tool=<name>&method=<method>&content=<text>&scope=<scope>&tags=<a,b>
 func (rs *RulesServer) handleCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -203,28 +222,53 @@ func (rs *RulesServer) handleCheck(w http.ResponseWriter, r *http.Request) {
 		Method:  query.Get("method"),
 		Content: query.Get("content"),
 		Scope:   query.Get("scope"),
+		Tags:    ExtractToolNames(query.Get("tags")),
+	}
+	for _, call := range strings.Split(query.Get("history"), "\n") {
+		if call != "" {
+			req.History = append(req.History, call)
+		}
 	}
 
+	json.NewEncoder(w).Encode(rs.Evaluate(r.Context(), req))
+}
+
+// Evaluate checks a request against the enabled rules and returns the decision.
+// The first matching rule wins; if nothing matches the call is allowed.
+func (rs *RulesServer) Evaluate(ctx context.Context, req CheckRequest) CheckResponse {
 	if req.Scope == "" {
 		req.Scope = "all"
 	}
 
 	// Get enabled rules
-	rules, err := rs.getEnabledRules(req.Scope)
+	rules, err := rs.store.Enabled(req.Scope)
 	if err != nil {
 		rs.logError("Failed to get rules: %v", err)
 		// Fail open - allow if we can't check
-		json.NewEncoder(w).Encode(CheckResponse{
+		return CheckResponse{
 			Allowed:  true,
 			Decision: "allow",
 			Reason:   "rule check failed, defaulting to allow",
-		})
-		return
+		}
+	}
+
+	// Native tools (WebFetch, Bash, ...) send their JSON tool input as content
+	var args map[string]interface{}
+	json.Unmarshal([]byte(req.Content), &args)
+	if block := rs.checkEgress(req.Tool, args); block != nil {
+		return *block
+	}
+	if script := shellScript(args); script != "" {
+		req.Content += "\n" + shellContent(script)
+	}
+	if _, sql := sqlArgument(args); sql != "" {
+		req.Content += "\n" + sqlContent(sql)
 	}
 
 	// Check each rule
+	now := time.Now()
 	for _, rule := range rules {
-		if !rs.ruleAppliesToTool(rule, req.Tool) {
+		if !rule.AppliesTo(req.Tool, req.Tags) || !rule.ScheduledAt(now) {
 			continue
 		}
 
@@ -251,39 +295,104 @@ func (rs *RulesServer) handleCheck(w http.ResponseWriter, r *http.Request) {
 
 		// Check semantic (if enabled and pattern didn't match)
 		if !matched && rule.IsSemantic && rule.Topics != "" {
-			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-			if rs.matchesSemantic(ctx, rule.Topics, req.Content) {
+			semCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			if rs.matchesSemantic(semCtx, rule.Topics, req.Content, req.History) {
 				matched = true
 			}
 			cancel()
 		}
 
 		if matched {
+			if rule.Action == DelegateAction {
+				return rs.delegate(ctx, rule, req, args)
+			}
 			if rule.Action == "block" {
-				json.NewEncoder(w).Encode(CheckResponse{
+				return CheckResponse{
 					Allowed:  false,
 					Decision: "block",
 					Reason:   fmt.Sprintf("Blocked by rule: %s", rule.Name),
 					RuleID:   rule.ID,
-				})
-				return
+				}
+			}
+			// action == "ask" holds the call until someone approves it: Claude
+			// Code prompts for native tools, the dashboard's approvals page
+			// lists MCP calls
+			if rule.Action == "ask" {
+				return CheckResponse{
+					Allowed:  false,
+					Decision: "ask",
+					Reason:   fmt.Sprintf("Approval required by rule: %s", rule.Name),
+					RuleID:   rule.ID,
+				}
 			}
 			// action == "allow" means whitelist - explicitly allow
-			json.NewEncoder(w).Encode(CheckResponse{
+			return CheckResponse{
 				Allowed:  true,
 				Decision: "allow",
 				Reason:   fmt.Sprintf("Allowed by rule: %s", rule.Name),
 				RuleID:   rule.ID,
-			})
-			return
+			}
 		}
 	}
 
 	// No rule matched - default allow
-	json.NewEncoder(w).Encode(CheckResponse{
+	return CheckResponse{
 		Allowed:  true,
 		Decision: "allow",
+	}
+}
+
+// delegate asks the stored authorizer to decide a call a "delegate" rule
+// matched. The call's transport is the check's scope: "native" for Claude
+// Code hooks, whose content is the tool input, or "mcp" for proxies, which
+// send only the matched content.
+func (rs *RulesServer) delegate(ctx context.Context, rule Rule, req CheckRequest, args map[string]interface{}) CheckResponse {
+	ap, err := loadAuthorizerSetting(rs.store)
+	if err != nil {
+		rs.logError("Failed to load authorizer: %v", err)
+	}
+	server, _ := parseNamespacedName(req.Tool)
+	verdict := ap.authorize(ctx, AuthorizerRequest{
+		Rule:    AuthorizerRule{ID: int64(rule.ID), Name: rule.Name},
+		Method:  req.Method,
+		Call:    PolicyCall{Server: server, Tool: req.Tool, Arguments: args, Transport: req.Scope, Host: CurrentHostContext()},
+		Content: req.Content,
 	})
+	if verdict.Decision == PolicyAllow {
+		return CheckResponse{
+			Allowed:  true,
+			Decision: "allow",
+			Reason:   fmt.Sprintf("Allowed by authorizer for rule: %s", rule.Name),
+			RuleID:   rule.ID,
+		}
+	}
+	return CheckResponse{
+		Allowed:  false,
+		Decision: verdict.Decision,
+		Reason:   delegateReason(verdict),
+		RuleID:   rule.ID,
+	}
+}
+
+// checkEgress applies the stored egress policy to the URLs in a tool input.
+// Content that wasn't a JSON object has no arguments to check.
+func (rs *RulesServer) checkEgress(tool string, args map[string]interface{}) *CheckResponse {
+	if args == nil {
+		return nil
+	}
+	ep, err := loadEgressSetting(rs.store)
+	if err != nil {
+		rs.logError("Failed to load egress policy: %v", err)
+	}
+	violations := CheckEgress(ep, args)
+	if len(violations) == 0 {
+		return nil
+	}
+	return &CheckResponse{
+		Allowed:  false,
+		Decision: "block",
+		Reason:   egressBlock(tool, violations).Reason,
+	}
 }
 
 // matchesRegex checks if content matches the regex pattern
@@ -296,8 +405,9 @@ func (rs *RulesServer) matchesRegex(pattern, content string) bool {
 	return matched
 }
 
-// matchesSemantic checks if content semantically matches the topics using Claude API
-func (rs *RulesServer) matchesSemantic(ctx context.Context, topics, content string) bool {
+// matchesSemantic checks if content, in the context of the session's earlier
+// calls, semantically matches the topics using Claude API
+func (rs *RulesServer) matchesSemantic(ctx context.Context, topics, content string, history []string) bool {
 	if rs.apiKey == "" || content == "" {
 		return false
 	}
@@ -307,8 +417,8 @@ func (rs *RulesServer) matchesSemantic(ctx context.Context, topics, content stri
 
 Content to analyze:
 %s
-
-Respond with only "YES" if the content relates to any blocked topic, or "NO" if it doesn't.`, topics, content)
+%s
+Respond with only "YES" if the content relates to any blocked topic, or "NO" if it doesn't.`, topics, content, historyPrompt(history))
 
 	reqBody := map[string]interface{}{
 		"model":      "claude-3-5-haiku-20241022",
@@ -369,48 +479,48 @@ type Rule struct {
 	IsRegex    bool      `json:"is_regex"`
 	IsSemantic bool      `json:"is_semantic"`
 	BlockAll   bool      `json:"block_all"`
+	Schedule   string    `json:"schedule,omitempty"` // when the rule is in force; see Schedule
 	Enabled    bool      `json:"enabled"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
-// getEnabledRules retrieves enabled rules filtered by scope
-func (rs *RulesServer) getEnabledRules(scope string) ([]Rule, error) {
-	query := `
-		SELECT id, name, pattern, topics, tools, scope, action,
-		       is_regex, is_semantic, COALESCE(block_all, 0), enabled, created_at, updated_at
-		FROM rules
-		WHERE enabled = 1 AND (scope = ? OR scope = 'all')
-		ORDER BY id
-	`
+// ScheduledAt reports whether the rule's schedule puts it in force at t.
+// A rule with an invalid schedule stays in force rather than silently lapsing.
+func (rule Rule) ScheduledAt(t time.Time) bool {
+	sched, err := ParseSchedule(rule.Schedule)
+	return err != nil || sched.Active(t)
+}
 
-	rows, err := rs.db.Query(query, scope)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var rules []Rule
-	for rows.Next() {
-		var rule Rule
-		var pattern, topics sql.NullString
-		err := rows.Scan(
-			&rule.ID, &rule.Name, &pattern, &topics, &rule.Tools,
-			&rule.Scope, &rule.Action, &rule.IsRegex, &rule.IsSemantic,
-			&rule.BlockAll, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
+// scheduledRule is a rule as the rules API shows it: with whether its
+// schedule has it in force now and when that next changes.
+type scheduledRule struct {
+	Rule
+	Active         bool       `json:"active"`
+	NextActivation *time.Time `json:"next_activation,omitempty"`
+	ActiveUntil    *time.Time `json:"active_until,omitempty"`
+}
+
+func withSchedule(rule Rule, now time.Time) scheduledRule {
+	sr := scheduledRule{Rule: rule, Active: true}
+	sched, err := ParseSchedule(rule.Schedule)
+	if err != nil || sched.IsZero() {
+		return sr
+	}
+	sr.Active = sched.Active(now)
+	if next := sched.NextChange(now); !next.IsZero() {
+		if sr.Active {
+			sr.ActiveUntil = &next
+		} else {
+			sr.NextActivation = &next
 		}
-		rule.Pattern = pattern.String
-		rule.Topics = topics.String
-		rules = append(rules, rule)
 	}
-	return rules, nil
+	return sr
 }
 
-// ruleAppliesToTool checks if a rule applies to the given tool
-func (rs *RulesServer) ruleAppliesToTool(rule Rule, toolName string) bool {
+// AppliesTo checks if the rule applies to the given tool, provided by a
+// server carrying tags
+func (rule Rule) AppliesTo(toolName string, tags []string) bool {
 	tools := strings.TrimSpace(rule.Tools)
 	if tools == "" || tools == "*" {
 		return true
@@ -419,6 +529,14 @@ func (rs *RulesServer) ruleAppliesToTool(rule Rule, toolName string) bool {
 	toolName = strings.ToLower(toolName)
 	for _, t := range strings.Split(tools, ",") {
 		t = strings.TrimSpace(strings.ToLower(t))
+		if tag, ok := strings.CutPrefix(t, tagPrefix); ok {
+			for _, serverTag := range tags {
+				if strings.ToLower(serverTag) == tag {
+					return true
+				}
+			}
+			continue
+		}
 		if t == toolName {
 			return true
 		}
@@ -473,37 +591,19 @@ func (rs *RulesServer) handleRuleByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (rs *RulesServer) listRules(w http.ResponseWriter, r *http.Request) {
-	query := `
-		SELECT id, name, pattern, topics, tools, scope, action,
-		       is_regex, is_semantic, COALESCE(block_all, 0), enabled, created_at, updated_at
-		FROM rules ORDER BY id DESC
-	`
-	rows, err := rs.db.Query(query)
+	rules, err := rs.store.List()
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var rules []Rule
-	for rows.Next() {
-		var rule Rule
-		var pattern, topics sql.NullString
-		err := rows.Scan(
-			&rule.ID, &rule.Name, &pattern, &topics, &rule.Tools,
-			&rule.Scope, &rule.Action, &rule.IsRegex, &rule.IsSemantic,
-			&rule.BlockAll, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt,
-		)
-		if err != nil {
-			continue
-		}
-		rule.Pattern = pattern.String
-		rule.Topics = topics.String
-		rules = append(rules, rule)
-	}
 
+	now := time.Now()
+	scheduled := make([]scheduledRule, len(rules))
+	for i, rule := range rules {
+		scheduled[i] = withSchedule(rule, now)
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"rules": rules,
+		"rules": scheduled,
 		"count": len(rules),
 	})
 }
@@ -519,60 +619,32 @@ func (rs *RulesServer) createRule(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Name is required", http.StatusBadRequest)
 		return
 	}
-
-	if rule.Tools == "" {
-		rule.Tools = "*"
-	}
-	if rule.Scope == "" {
-		rule.Scope = "all"
-	}
-	if rule.Action == "" {
-		rule.Action = "block"
+	if _, err := ParseSchedule(rule.Schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	result, err := rs.db.Exec(`
-		INSERT INTO rules (name, pattern, topics, tools, scope, action, is_regex, is_semantic, block_all, enabled)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, rule.Name, rule.Pattern, rule.Topics, rule.Tools, rule.Scope, rule.Action,
-		rule.IsRegex, rule.IsSemantic, rule.BlockAll, true)
-
-	if err != nil {
+	if err := rs.store.Create(&rule); err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	rule.ID = int(id)
-	rule.Enabled = true
-
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(rule)
 }
 
 func (rs *RulesServer) getRule(w http.ResponseWriter, id int) {
-	var rule Rule
-	var pattern, topics sql.NullString
-	err := rs.db.QueryRow(`
-		SELECT id, name, pattern, topics, tools, scope, action,
-		       is_regex, is_semantic, COALESCE(block_all, 0), enabled, created_at, updated_at
-		FROM rules WHERE id = ?
-	`, id).Scan(
-		&rule.ID, &rule.Name, &pattern, &topics, &rule.Tools,
-		&rule.Scope, &rule.Action, &rule.IsRegex, &rule.IsSemantic,
-		&rule.BlockAll, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt,
-	)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Rule not found", http.StatusNotFound)
-		return
-	}
+	rule, err := rs.store.Get(id)
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	rule.Pattern = pattern.String
-	rule.Topics = topics.String
 
-	json.NewEncoder(w).Encode(rule)
+	json.NewEncoder(w).Encode(withSchedule(*rule, time.Now()))
 }
 
 func (rs *RulesServer) updateRule(w http.ResponseWriter, r *http.Request, id int) {
@@ -582,27 +654,21 @@ func (rs *RulesServer) updateRule(w http.ResponseWriter, r *http.Request, id int
 		return
 	}
 
-	_, err := rs.db.Exec(`
-		UPDATE rules SET
-			name = ?, pattern = ?, topics = ?, tools = ?, scope = ?,
-			action = ?, is_regex = ?, is_semantic = ?, block_all = ?, enabled = ?,
-			updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-	`, rule.Name, rule.Pattern, rule.Topics, rule.Tools, rule.Scope,
-		rule.Action, rule.IsRegex, rule.IsSemantic, rule.BlockAll, rule.Enabled, id)
-
-	if err != nil {
+	rule.ID = id
+	if _, err := ParseSchedule(rule.Schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := rs.store.Update(&rule); err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
-	rule.ID = id
 	json.NewEncoder(w).Encode(rule)
 }
 
 func (rs *RulesServer) deleteRule(w http.ResponseWriter, id int) {
-	_, err := rs.db.Exec("DELETE FROM rules WHERE id = ?", id)
-	if err != nil {
+	if err := rs.store.Delete(id); err != nil && !strings.Contains(err.Error(), "not found") {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
@@ -662,13 +728,94 @@ func (rs *RulesServer) handleTools(w http.ResponseWriter, r *http.Request) {
 
 // handleHealth returns server health status
 func (rs *RulesServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	dbCheck := checkDatabase(r.Context(), rs.db)
+	status := HealthOK
+	if dbCheck.Status == HealthUnhealthy {
+		status = HealthUnhealthy
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	if status != HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "ok",
-		"port":   rs.port,
+		"status":         status,
+		"port":           rs.port,
+		"version":        proxy.Version,
+		"uptime_seconds": int64(proxy.Uptime().Seconds()),
+		"database":       dbCheck,
 	})
 }
 
+// permissionSyncInterval is how often rules and settings.json are synced
+// when the permission sync is on.
+const permissionSyncInterval = 10 * time.Second
+
+// syncPermissionsLoop runs the permission sync in its stored direction until
+// stop is closed.
+func (rs *RulesServer) syncPermissionsLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(permissionSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		state, err := rs.store.loadPermissionSync()
+		if err != nil || state.Direction == SyncOff {
+			continue
+		}
+		report, err := rs.store.SyncPermissions(rs.settingsPath, "")
+		if err != nil {
+			rs.logError("Permission sync failed: %v", err)
+			continue
+		}
+		if report.ClaudeChanges > 0 || report.ArmourChanges > 0 {
+			rs.logInfo("Permission sync: %d settings.json entries and %d rules changed", report.ClaudeChanges, report.ArmourChanges)
+		}
+	}
+}
+
+// handlePermissionSync reports how rules and settings.json permissions
+// compare (GET), or stores the sync direction if given and syncs now (POST).
+func (rs *RulesServer) handlePermissionSync(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var report *PermissionSyncReport
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		report, err = rs.store.PermissionSyncStatus(rs.settingsPath)
+	case http.MethodPost:
+		var req struct {
+			Direction string `json:"direction"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Direction != "" {
+			if err := rs.store.SetPermissionSyncDirection(req.Direction); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		report, err = rs.store.SyncPermissions(rs.settingsPath, "")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		rs.logError("Permission sync failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
 // GetPort returns the server port
 func (rs *RulesServer) GetPort() int {
 	return rs.port
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RulesStore provides CRUD access to the shared rules database used by the
+// rules server, the CLI and PreToolUse hooks.
+type RulesStore struct {
+	db *sql.DB
+	q  rulesQuerier // db, or the transaction of a store made by inTx
+}
+
+// rulesQuerier runs the store's statements, on the database or in a
+// transaction.
+type rulesQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// DefaultRulesDBPath returns the default location of the shared rules database.
+func DefaultRulesDBPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".armour", "rules.db")
+}
+
+// OpenRulesStore opens (and initializes if needed) the rules database at path.
+func OpenRulesStore(path string) (*RulesStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create rules directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	store, err := NewRulesStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewRulesStore wraps an existing database handle, initializing the schema.
+func NewRulesStore(db *sql.DB) (*RulesStore, error) {
+	if err := initRulesDB(db); err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return &RulesStore{db: db, q: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *RulesStore) Close() error {
+	return s.db.Close()
+}
+
+// inTx runs fn on a store whose changes are committed together if fn
+// succeeds and rolled back if it fails. Inside a transaction fn joins it.
+func (s *RulesStore) inTx(fn func(tx *RulesStore) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := fn(&RulesStore{db: s.db, q: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+const ruleColumns = `id, name, pattern, topics, tools, scope, action,
+		       is_regex, is_semantic, COALESCE(block_all, 0), COALESCE(schedule, ''), enabled, created_at, updated_at`
+
+func scanRule(scanner interface{ Scan(...interface{}) error }) (Rule, error) {
+	var rule Rule
+	var pattern, topics sql.NullString
+	err := scanner.Scan(
+		&rule.ID, &rule.Name, &pattern, &topics, &rule.Tools,
+		&rule.Scope, &rule.Action, &rule.IsRegex, &rule.IsSemantic,
+		&rule.BlockAll, &rule.Schedule, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	rule.Pattern = pattern.String
+	rule.Topics = topics.String
+	return rule, err
+}
+
+func (s *RulesStore) queryRules(query string, args ...interface{}) ([]Rule, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// List returns all rules, newest first.
+func (s *RulesStore) List() ([]Rule, error) {
+	return s.queryRules("SELECT " + ruleColumns + " FROM rules ORDER BY id DESC")
+}
+
+// Enabled returns enabled rules for the given scope in evaluation order.
+func (s *RulesStore) Enabled(scope string) ([]Rule, error) {
+	return s.queryRules("SELECT "+ruleColumns+" FROM rules WHERE enabled = 1 AND (scope = ? OR scope = 'all') ORDER BY id", scope)
+}
+
+// Get returns a single rule by ID.
+func (s *RulesStore) Get(id int) (*Rule, error) {
+	rule, err := scanRule(s.q.QueryRow("SELECT "+ruleColumns+" FROM rules WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("rule not found: id=%d", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// Create inserts a new enabled rule, filling in defaults, and sets rule.ID.
+func (s *RulesStore) Create(rule *Rule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if rule.Tools == "" {
+		rule.Tools = "*"
+	}
+	if rule.Scope == "" {
+		rule.Scope = "all"
+	}
+	if rule.Action == "" {
+		rule.Action = "block"
+	}
+	if _, err := ParseSchedule(rule.Schedule); err != nil {
+		return err
+	}
+
+	result, err := s.q.Exec(`
+		INSERT INTO rules (name, pattern, topics, tools, scope, action, is_regex, is_semantic, block_all, schedule, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.Name, rule.Pattern, rule.Topics, rule.Tools, rule.Scope, rule.Action,
+		rule.IsRegex, rule.IsSemantic, rule.BlockAll, rule.Schedule, true)
+	if err != nil {
+		return fmt.Errorf("failed to create rule: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	rule.ID = int(id)
+	rule.Enabled = true
+	return nil
+}
+
+// Update overwrites all fields of the rule with the given ID.
+func (s *RulesStore) Update(rule *Rule) error {
+	if _, err := ParseSchedule(rule.Schedule); err != nil {
+		return err
+	}
+	_, err := s.q.Exec(`
+		UPDATE rules SET
+			name = ?, pattern = ?, topics = ?, tools = ?, scope = ?,
+			action = ?, is_regex = ?, is_semantic = ?, block_all = ?, schedule = ?, enabled = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, rule.Name, rule.Pattern, rule.Topics, rule.Tools, rule.Scope,
+		rule.Action, rule.IsRegex, rule.IsSemantic, rule.BlockAll, rule.Schedule, rule.Enabled, rule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update rule: %w", err)
+	}
+	return nil
+}
+
+// SetEnabled enables or disables a rule.
+func (s *RulesStore) SetEnabled(id int, enabled bool) error {
+	result, err := s.q.Exec("UPDATE rules SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", enabled, id)
+	if err != nil {
+		return fmt.Errorf("failed to update rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("rule not found: id=%d", id)
+	}
+	return nil
+}
+
+// Delete removes a rule.
+func (s *RulesStore) Delete(id int) error {
+	result, err := s.q.Exec("DELETE FROM rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("rule not found: id=%d", id)
+	}
+	return nil
+}
+
+// GetSetting returns a stored setting, or "" if it is not set.
+func (s *RulesStore) GetSetting(key string) (string, error) {
+	var value string
+	err := s.q.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read setting %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetSetting stores a setting; an empty value removes it.
+func (s *RulesStore) SetSetting(key, value string) error {
+	var err error
+	if value == "" {
+		_, err = s.q.Exec("DELETE FROM settings WHERE key = ?", key)
+	} else {
+		_, err = s.q.Exec(`
+			INSERT INTO settings (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+		`, key, value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write setting %s: %w", key, err)
+	}
+	return nil
+}
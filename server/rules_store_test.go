@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// TestRulesStoreCRUD tests the shared rules store used by the CLI and rules server
+func TestRulesStoreCRUD(t *testing.T) {
+	store, err := OpenRulesStore(filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("Failed to open rules store: %v", err)
+	}
+	defer store.Close()
+
+	rule := &Rule{Name: "no-rm", Pattern: "rm -rf", Tools: "Bash"}
+	if err := store.Create(rule); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if rule.ID == 0 || rule.Action != "block" || rule.Scope != "all" {
+		t.Errorf("Expected defaults to be filled in, got %+v", rule)
+	}
+
+	rs := &RulesServer{store: store}
+	resp := rs.Evaluate(context.Background(), CheckRequest{Tool: "Bash", Content: "rm -rf /"})
+	if resp.Allowed || resp.RuleID != rule.ID {
+		t.Errorf("Expected rule %d to block, got %+v", rule.ID, resp)
+	}
+
+	tagged := &Rule{Name: "prod-readonly", BlockAll: true, Tools: "tag:prod"}
+	if err := store.Create(tagged); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if resp := rs.Evaluate(context.Background(), CheckRequest{Tool: "db:query", Tags: []string{"prod"}}); resp.Allowed || resp.RuleID != tagged.ID {
+		t.Errorf("Expected tag rule %d to block, got %+v", tagged.ID, resp)
+	}
+	if resp := rs.Evaluate(context.Background(), CheckRequest{Tool: "db:query", Tags: []string{"dev"}}); !resp.Allowed {
+		t.Errorf("Expected untagged server to be allowed, got %+v", resp)
+	}
+	if err := store.Delete(tagged.ID); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+
+	ask := &Rule{Name: "confirm-deploy", BlockAll: true, Tools: "ci:deploy", Action: "ask"}
+	if err := store.Create(ask); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	if resp := rs.Evaluate(context.Background(), CheckRequest{Tool: "ci:deploy"}); resp.Allowed || resp.Decision != "ask" {
+		t.Errorf("Expected ask rule to hold the call for approval, got %+v", resp)
+	}
+	if err := store.Delete(ask.ID); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+
+	if err := store.SetEnabled(rule.ID, false); err != nil {
+		t.Fatalf("Failed to disable rule: %v", err)
+	}
+	resp = rs.Evaluate(context.Background(), CheckRequest{Tool: "Bash", Content: "rm -rf /"})
+	if !resp.Allowed {
+		t.Errorf("Expected disabled rule to be skipped, got %+v", resp)
+	}
+
+	rules, err := store.List()
+	if err != nil || len(rules) != 1 || rules[0].Enabled {
+		t.Fatalf("Expected one disabled rule, got %+v (err=%v)", rules, err)
+	}
+
+	if err := store.Delete(rule.ID); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	if err := store.Delete(rule.ID); err == nil {
+		t.Error("Expected error deleting a missing rule")
+	}
+	if _, err := store.Get(rule.ID); err == nil {
+		t.Error("Expected error getting a deleted rule")
+	}
+}
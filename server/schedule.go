@@ -0,0 +1,274 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleHorizon is how far ahead NextChange looks: every window repeats
+// weekly, so a schedule that doesn't change within eight days never does.
+const scheduleHorizon = 8 * 24 * time.Hour
+
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Schedule is a set of weekly time windows in local time, written as
+// entries separated by semicolons, each a list of days, a time range or both:
+//
+//	Mon-Fri 09:00-17:00
+//	Mon-Fri 19:00-07:00; Sat,Sun
+//	22:00-06:00
+//
+// A range ending at or before its start runs past midnight into the next
+// day. A schedule without windows is always active.
+type Schedule struct {
+	spec    string
+	windows []scheduleWindow
+}
+
+type scheduleWindow struct {
+	days       [7]bool
+	start, end int // minutes since midnight; end <= start wraps past midnight
+}
+
+// ParseSchedule parses a schedule. An empty spec is always active.
+func ParseSchedule(spec string) (Schedule, error) {
+	s := Schedule{spec: strings.TrimSpace(spec)}
+	if s.spec == "" {
+		return s, nil
+	}
+	for _, entry := range strings.Split(s.spec, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 || len(fields) > 2 {
+			return Schedule{}, fmt.Errorf("invalid schedule entry %q (want days, a time range or both)", strings.TrimSpace(entry))
+		}
+		w := scheduleWindow{start: 0, end: 24 * 60}
+		daysSet := false
+		for _, field := range fields {
+			if strings.Contains(field, ":") {
+				start, end, err := parseScheduleRange(field)
+				if err != nil {
+					return Schedule{}, err
+				}
+				w.start, w.end = start, end
+				continue
+			}
+			if daysSet {
+				return Schedule{}, fmt.Errorf("invalid schedule entry %q (want days, a time range or both)", strings.TrimSpace(entry))
+			}
+			days, err := parseScheduleDays(field)
+			if err != nil {
+				return Schedule{}, err
+			}
+			w.days, daysSet = days, true
+		}
+		if !daysSet {
+			for d := range w.days {
+				w.days[d] = true
+			}
+		}
+		s.windows = append(s.windows, w)
+	}
+	return s, nil
+}
+
+// parseScheduleDays parses a comma-separated list of days and day ranges
+// such as Mon-Fri,Sun.
+func parseScheduleDays(field string) ([7]bool, error) {
+	var days [7]bool
+	for _, part := range strings.Split(strings.ToLower(field), ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := scheduleDays[from]
+		if !ok {
+			return days, fmt.Errorf("invalid schedule day %q (want Mon, Tue, ... Sun)", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = scheduleDays[to]; !ok {
+				return days, fmt.Errorf("invalid schedule day %q (want Mon, Tue, ... Sun)", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseScheduleRange parses HH:MM-HH:MM into minutes since midnight.
+func parseScheduleRange(field string) (start, end int, err error) {
+	from, to, ok := strings.Cut(field, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid schedule time range %q (want HH:MM-HH:MM)", field)
+	}
+	if start, err = parseScheduleTime(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseScheduleTime(to); err != nil {
+		return 0, 0, err
+	}
+	if start == end || start == 24*60 {
+		return 0, 0, fmt.Errorf("invalid schedule time range %q", field)
+	}
+	return start, end, nil
+}
+
+func parseScheduleTime(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || len(m) != 2 || hour < 0 || minute < 0 || minute > 59 ||
+		hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid schedule time %q (want HH:MM)", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// IsZero reports whether the schedule has no windows and so is always active.
+func (s Schedule) IsZero() bool {
+	return len(s.windows) == 0
+}
+
+// String returns the schedule as written.
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// Active reports whether t, taken in local time, falls in one of the windows.
+func (s Schedule) Active(t time.Time) bool {
+	if s.IsZero() {
+		return true
+	}
+	t = t.In(time.Local)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	yesterday := (day + 6) % 7
+	for _, w := range s.windows {
+		if w.end > w.start {
+			if w.days[day] && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// Past midnight: the evening part today or the morning part of yesterday's window
+		if (w.days[day] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextChange returns when the schedule next becomes active if it is
+// inactive at t, or inactive if it is active, to the minute. It returns the
+// zero time if that never happens.
+func (s Schedule) NextChange(t time.Time) time.Time {
+	if s.IsZero() {
+		return time.Time{}
+	}
+	active := s.Active(t)
+	// Step in wall-clock minutes so daylight saving shifts are honoured
+	next := t.In(time.Local).Truncate(time.Minute)
+	for end := t.Add(scheduleHorizon); next.Before(end); {
+		next = next.Add(time.Minute)
+		if s.Active(next) != active {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// ScheduledMode switches the policy mode while its schedule is active.
+type ScheduledMode struct {
+	Mode     PolicyMode `yaml:"mode" json:"mode"`
+	Schedule string     `yaml:"schedule" json:"schedule"`
+}
+
+// ModeSchedule overrides the policy mode at set times, e.g. strict at night
+// while unattended agents run. The first active entry wins; outside every
+// entry the configured mode applies.
+type ModeSchedule []ScheduledMode
+
+// IsZero reports whether there are no scheduled modes.
+func (ms ModeSchedule) IsZero() bool {
+	return len(ms) == 0
+}
+
+// Validate checks the modes and schedules.
+func (ms ModeSchedule) Validate() error {
+	for i, entry := range ms {
+		switch entry.Mode {
+		case StrictMode, ModerateMode, PermissiveMode:
+		default:
+			return fmt.Errorf("mode_schedule %d: invalid mode %q", i+1, entry.Mode)
+		}
+		if strings.TrimSpace(entry.Schedule) == "" {
+			return fmt.Errorf("mode_schedule %d: schedule is required", i+1)
+		}
+		if _, err := ParseSchedule(entry.Schedule); err != nil {
+			return fmt.Errorf("mode_schedule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// String renders the entries for diffs, e.g. "strict@Mon-Fri 19:00-07:00".
+func (ms ModeSchedule) String() string {
+	parts := make([]string, len(ms))
+	for i, entry := range ms {
+		parts[i] = string(entry.Mode) + "@" + entry.Schedule
+	}
+	return strings.Join(parts, " | ")
+}
+
+// ModeAt returns the scheduled mode at t, or false if no entry is active.
+func (ms ModeSchedule) ModeAt(t time.Time) (PolicyMode, bool) {
+	for _, entry := range ms {
+		if sched, err := ParseSchedule(entry.Schedule); err == nil && sched.Active(t) {
+			return entry.Mode, true
+		}
+	}
+	return "", false
+}
+
+// NextChange returns the first time after t at which any entry's schedule
+// starts or stops, or the zero time if none ever does.
+func (ms ModeSchedule) NextChange(t time.Time) time.Time {
+	var next time.Time
+	for _, entry := range ms {
+		sched, err := ParseSchedule(entry.Schedule)
+		if err != nil {
+			continue
+		}
+		if change := sched.NextChange(t); !change.IsZero() && (next.IsZero() || change.Before(next)) {
+			next = change
+		}
+	}
+	return next
+}
+
+func encodeModeSchedule(ms ModeSchedule) (string, error) {
+	if ms.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(ms)
+	return string(data), err
+}
+
+func decodeModeSchedule(raw string) (ModeSchedule, error) {
+	var ms ModeSchedule
+	if raw == "" {
+		return ms, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &ms); err != nil {
+		return ms, fmt.Errorf("invalid stored mode schedule: %w", err)
+	}
+	return ms, nil
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	// 2026-03-02 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.Local)
+	}
+
+	office, err := ParseSchedule("Mon-Fri 09:00-17:00")
+	if err != nil {
+		t.Fatalf("failed to parse schedule: %v", err)
+	}
+	for _, c := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(2, 9, 0), true},
+		{at(6, 16, 59), true},
+		{at(2, 17, 0), false},
+		{at(2, 8, 59), false},
+		{at(7, 12, 0), false}, // Saturday
+	} {
+		if got := office.Active(c.t); got != c.want {
+			t.Errorf("office hours at %s: got %v, want %v", c.t.Format(time.RFC1123), got, c.want)
+		}
+	}
+	if next := office.NextChange(at(6, 18, 30)); !next.Equal(at(9, 9, 0)) {
+		t.Errorf("expected next activation Monday 09:00, got %s", next)
+	}
+	if next := office.NextChange(at(2, 10, 15)); !next.Equal(at(2, 17, 0)) {
+		t.Errorf("expected the window to end at 17:00, got %s", next)
+	}
+
+	// Windows ending before they start run past midnight
+	nights, err := ParseSchedule("Mon-Fri 19:00-07:00; Sat,Sun")
+	if err != nil {
+		t.Fatalf("failed to parse schedule: %v", err)
+	}
+	for _, c := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(2, 23, 0), true},
+		{at(3, 6, 59), true},  // Tuesday morning, from Monday night
+		{at(2, 6, 0), false},  // Monday morning: Sunday's window is the whole day, which ended at midnight
+		{at(7, 6, 0), true},   // Saturday morning, from Friday night
+		{at(8, 12, 0), true},  // Sunday
+		{at(4, 12, 0), false}, // Wednesday noon
+	} {
+		if got := nights.Active(c.t); got != c.want {
+			t.Errorf("nights at %s: got %v, want %v", c.t.Format(time.RFC1123), got, c.want)
+		}
+	}
+
+	always, _ := ParseSchedule("")
+	if !always.Active(at(4, 3, 0)) || !always.NextChange(at(4, 3, 0)).IsZero() {
+		t.Errorf("expected an empty schedule always active")
+	}
+
+	for _, spec := range []string{"Mon-Fry", "9-17", "09:00-09:00", "25:00-26:00", "Mon Tue", "09:60-10:00", "Mon-Fri 09:00-17:00 extra"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestScheduledRulesAndModes(t *testing.T) {
+	store, err := OpenRulesStore(filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("failed to open rules store: %v", err)
+	}
+	defer store.Close()
+	rs := &RulesServer{store: store}
+
+	now := time.Now()
+	today := strings.ToLower(now.Weekday().String()[:3])
+	tomorrow := strings.ToLower(now.AddDate(0, 0, 1).Weekday().String()[:3])
+
+	// Only rules whose schedule is active now are enforced
+	if err := store.Create(&Rule{Name: "deploys-tomorrow", Tools: "deploy", BlockAll: true, Schedule: tomorrow}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	if resp := rs.Evaluate(context.Background(), CheckRequest{Tool: "deploy"}); !resp.Allowed {
+		t.Errorf("expected a rule scheduled for another day not enforced, got %+v", resp)
+	}
+	if err := store.Create(&Rule{Name: "deploys-today", Tools: "deploy", BlockAll: true, Schedule: today}); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	if resp := rs.Evaluate(context.Background(), CheckRequest{Tool: "deploy"}); resp.Allowed {
+		t.Errorf("expected a rule scheduled for today enforced, got %+v", resp)
+	}
+	if err := store.Create(&Rule{Name: "bad", Schedule: "someday"}); err == nil {
+		t.Errorf("expected an invalid schedule rejected")
+	}
+
+	rules, _ := store.List()
+	for _, rule := range rules {
+		sr := withSchedule(rule, now)
+		if rule.Name == "deploys-tomorrow" && (sr.Active || sr.NextActivation == nil) {
+			t.Errorf("expected the inactive rule to show its next activation, got %+v", sr)
+		}
+		if rule.Name == "deploys-today" && (!sr.Active || sr.ActiveUntil == nil) {
+			t.Errorf("expected the active rule to show when it lapses, got %+v", sr)
+		}
+	}
+
+	// A scheduled mode overrides the base mode while active
+	pm := NewPolicyManager(nil)
+	pm.SetModeSchedule(ModeSchedule{{Mode: StrictMode, Schedule: tomorrow}})
+	if pm.GetMode() != ModerateMode {
+		t.Errorf("expected the base mode outside the schedule, got %s", pm.GetMode())
+	}
+	pm.SetModeSchedule(ModeSchedule{{Mode: StrictMode, Schedule: today}})
+	if pm.GetMode() != StrictMode || pm.GetBaseMode() != ModerateMode {
+		t.Errorf("expected strict mode while scheduled, got %s (base %s)", pm.GetMode(), pm.GetBaseMode())
+	}
+	if err := (ModeSchedule{{Mode: "paranoid", Schedule: today}}).Validate(); err == nil {
+		t.Errorf("expected an invalid scheduled mode rejected")
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is a single argument that failed validation against a tool's
+// inputSchema. Field is a dotted path such as "options.tags[2]"; the empty
+// string refers to the arguments object itself.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidateToolArguments checks tools/call arguments against a tool's
+// advertised JSON schema and returns every violation found.
+//
+// It implements the keywords MCP servers commonly use (type, required,
+// properties, additionalProperties, items, enum, const, string/number/array
+// bounds and pattern). Other keywords such as anyOf or $ref are ignored, so
+// unusual schemas fail open rather than blocking valid calls.
+func ValidateToolArguments(schema map[string]interface{}, arguments json.RawMessage) []FieldError {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	var args interface{} = map[string]interface{}{}
+	if trimmed := strings.TrimSpace(string(arguments)); trimmed != "" && trimmed != "null" {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return []FieldError{{Message: "arguments are not valid JSON: " + err.Error()}}
+		}
+	}
+
+	var errs []FieldError
+	validateValue(schema, args, "", &errs)
+	return errs
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string, errs *[]FieldError) {
+	fail := func(format string, a ...interface{}) {
+		*errs = append(*errs, FieldError{Field: path, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonType(value)
+		if !typeMatches(types, actual, value) {
+			fail("expected %s, got %s", strings.Join(types, " or "), actual)
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if reflect.DeepEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %s", formatJSONList(enum))
+		}
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		fail("must equal %s", formatJSONList([]interface{}{c}))
+	}
+
+	switch v := value.(type) {
+	case string:
+		n := float64(utf8.RuneCountInString(v))
+		if min, ok := schemaNumber(schema, "minLength"); ok && n < min {
+			fail("must be at least %g characters", min)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && n > max {
+			fail("must be at most %g characters", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("must match pattern %q", pattern)
+			}
+		}
+
+	case float64:
+		if min, ok := schemaNumber(schema, "minimum"); ok && v < min {
+			fail("must be >= %g", min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && v > max {
+			fail("must be <= %g", max)
+		}
+		if min, ok := schemaNumber(schema, "exclusiveMinimum"); ok && v <= min {
+			fail("must be > %g", min)
+		}
+		if max, ok := schemaNumber(schema, "exclusiveMaximum"); ok && v >= max {
+			fail("must be < %g", max)
+		}
+
+	case []interface{}:
+		n := float64(len(v))
+		if min, ok := schemaNumber(schema, "minItems"); ok && n < min {
+			fail("must have at least %g items", min)
+		}
+		if max, ok := schemaNumber(schema, "maxItems"); ok && n > max {
+			fail("must have at most %g items", max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, present := v[name]; name != "" && !present {
+					*errs = append(*errs, FieldError{Field: joinField(path, name), Message: "is required"})
+				}
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if propSchema, ok := properties[name].(map[string]interface{}); ok {
+				validateValue(propSchema, v[name], joinField(path, name), errs)
+				continue
+			}
+			if _, declared := properties[name]; declared {
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					*errs = append(*errs, FieldError{Field: joinField(path, name), Message: "is not an allowed property"})
+				}
+			case map[string]interface{}:
+				validateValue(additional, v[name], joinField(path, name), errs)
+			}
+		}
+	}
+}
+
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func typeMatches(types []string, actual string, value interface{}) bool {
+	for _, t := range types {
+		if t == actual {
+			return true
+		}
+		if t == "integer" && actual == "number" {
+			if f := value.(float64); f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	n, ok := schema[key].(float64)
+	return n, ok
+}
+
+func joinField(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func formatJSONList(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		data, _ := json.Marshal(v)
+		parts[i] = string(data)
+	}
+	return strings.Join(parts, ", ")
+}
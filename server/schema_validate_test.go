@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateToolArguments(t *testing.T) {
+	var schema map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"repo":  {"type": "string", "pattern": "^[\\w-]+/[\\w-]+$"},
+			"state": {"type": "string", "enum": ["open", "closed"]},
+			"limit": {"type": "integer", "minimum": 1, "maximum": 100},
+			"labels": {"type": "array", "items": {"type": "string"}, "maxItems": 3},
+			"filter": {
+				"type": "object",
+				"properties": {"author": {"type": "string", "minLength": 1}},
+				"additionalProperties": false
+			}
+		},
+		"required": ["repo"]
+	}`), &schema)
+
+	tests := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{"valid", `{"repo":"a/b","state":"open","limit":10,"labels":["bug"],"filter":{"author":"x"}}`, nil},
+		{"missing required", `{}`, []string{"repo: is required"}},
+		{"null arguments", ``, []string{"repo: is required"}},
+		{"wrong type", `{"repo":42}`, []string{"repo: expected string, got number"}},
+		{"enum and bounds", `{"repo":"a/b","state":"merged","limit":1.5}`, []string{
+			"limit: expected integer, got number",
+			`state: must be one of "open", "closed"`,
+		}},
+		{"nested", `{"repo":"a/b","labels":["x",2],"filter":{"author":"","extra":true}}`, []string{
+			"filter.author: must be at least 1 characters",
+			"filter.extra: is not an allowed property",
+			"labels[1]: expected string, got number",
+		}},
+		{"pattern", `{"repo":"not a repo"}`, []string{`repo: must match pattern "^[\\w-]+/[\\w-]+$"`}},
+		{"not an object", `[1]`, []string{"expected object, got array"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range ValidateToolArguments(schema, json.RawMessage(tt.args)) {
+				got = append(got, e.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidateToolArgumentsWithoutSchema(t *testing.T) {
+	if errs := ValidateToolArguments(nil, json.RawMessage(`{"anything":1}`)); errs != nil {
+		t.Errorf("expected no errors without a schema, got %v", errs)
+	}
+	// Unsupported keywords are ignored rather than rejecting the call
+	schema := map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "string"}}}
+	if errs := ValidateToolArguments(schema, json.RawMessage(`{"a":1}`)); errs != nil {
+		t.Errorf("expected unsupported keywords to be ignored, got %v", errs)
+	}
+}
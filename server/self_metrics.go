@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// SelfMetrics is the proxy's view of its own resource use, served by
+// /api/v1/debug and printed by `mcp-proxy doctor`.
+type SelfMetrics struct {
+	Version       string    `json:"version"`
+	GoVersion     string    `json:"go_version"`
+	PID           int       `json:"pid"`
+	CollectedAt   time.Time `json:"collected_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"` // live heap objects
+	HeapInuseBytes uint64 `json:"heap_inuse_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	SysBytes       uint64 `json:"sys_bytes"` // obtained from the OS
+	NumGC          uint32 `json:"num_gc"`
+	GCPauseTotalMS int64  `json:"gc_pause_total_ms"`
+
+	Subprocesses  int   `json:"subprocesses"`             // running stdio backends
+	DBSizeBytes   int64 `json:"db_size_bytes"`            // pages in use; -1 if unknown
+	TraceEvents   int   `json:"trace_events"`             // events in the trace buffer
+	TraceCapacity int   `json:"trace_capacity,omitempty"` // events the buffer holds before dropping the oldest
+}
+
+// CollectSelfMetrics reads the runtime's memory statistics and the size of
+// the proxy's database, backends and trace buffer. Any of db, backends and
+// trace may be nil.
+func CollectSelfMetrics(ctx context.Context, db *sql.DB, backends *BackendManager, trace *proxy.TraceRecorder) SelfMetrics {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	m := SelfMetrics{
+		Version:        proxy.Version,
+		GoVersion:      runtime.Version(),
+		PID:            os.Getpid(),
+		CollectedAt:    time.Now().UTC(),
+		UptimeSeconds:  int64(proxy.Uptime().Seconds()),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapInuseBytes: mem.HeapInuse,
+		HeapObjects:    mem.HeapObjects,
+		SysBytes:       mem.Sys,
+		NumGC:          mem.NumGC,
+		GCPauseTotalMS: time.Duration(mem.PauseTotalNs).Milliseconds(),
+		DBSizeBytes:    databaseSize(ctx, db),
+	}
+	if backends != nil {
+		m.Subprocesses = backends.Subprocesses()
+	}
+	if trace != nil {
+		m.TraceEvents, m.TraceCapacity = trace.Usage()
+	}
+	return m
+}
+
+// databaseSize returns the bytes of the SQLite database in use, or -1.
+func databaseSize(ctx context.Context, db *sql.DB) int64 {
+	if db == nil {
+		return -1
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	var pages, pageSize int64
+	if err := db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return -1
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return -1
+	}
+	return pages * pageSize
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestCollectSelfMetrics tests that the runtime, database and trace buffer
+// are measured
+func TestCollectSelfMetrics(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "proxy.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE notes (body TEXT)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	trace := proxy.NewTraceRecorder(10)
+	trace.Add(proxy.TraceEvent{Stage: "forward"})
+
+	m := CollectSelfMetrics(context.Background(), db, nil, trace)
+	if m.Goroutines == 0 || m.HeapAllocBytes == 0 || m.PID == 0 {
+		t.Errorf("expected runtime metrics, got %+v", m)
+	}
+	if m.DBSizeBytes <= 0 {
+		t.Errorf("expected the database size, got %d", m.DBSizeBytes)
+	}
+	if m.TraceEvents != 1 || m.TraceCapacity != 10 {
+		t.Errorf("expected 1/10 trace events, got %d/%d", m.TraceEvents, m.TraceCapacity)
+	}
+	if m := CollectSelfMetrics(context.Background(), nil, nil, nil); m.DBSizeBytes != -1 {
+		t.Errorf("expected -1 without a database, got %d", m.DBSizeBytes)
+	}
+}
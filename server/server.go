@@ -1,13 +1,16 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -19,12 +22,87 @@ type Config struct {
 	LogLevel       string
 	DBPath         string
 	ConfigPath     string
+	Registry       *proxy.ServerRegistry // HTTP mode: servers to proxy; when set, ConfigPath is not read
 	Mode           string
 	AllowedOrigins []string
+	AllowedHosts   []string // extra Host headers accepted when listening on loopback
+	TLS            TLSConfig
+	TenantsPath    string // HTTP mode: tenants file mapping tenants to registries and policies (see TenantsFile)
+	RecordPath     string // stdio mode: record the session to this JSONL file
+	MaxMessageSize int    // largest JSON-RPC message accepted from clients (default proxy.DefaultMaxMessageSize)
+
+	// HTTP listener tuning (HTTP mode)
+	DisableCompression bool          // don't compress responses for clients that accept it
+	IdleTimeout        time.Duration // how long idle keep-alive connections stay open (default defaultIdleTimeout)
+	H2C                bool          // serve HTTP/2 without TLS to clients that speak it directly
+
+	// Work queue limits (see NewWorkQueue); zero uses the defaults
+	Workers            int
+	SessionConcurrency int
+	QueueSize          int
+
+	PingInterval time.Duration // stdio mode: backend keepalive interval; 0 uses the default, negative disables
+
+	// Dashboard tokens (stdio mode); without an admin token the dashboard is open to localhost
+	DashboardAdminToken  string
+	DashboardViewerToken string
+
+	Management ManagementConfig // stdio mode: remote management API for a fleet controller
+
+	// Executables run as out-of-process policy checkers (see ExternalPolicyChecker)
+	PolicyPlugins []string
+	OPAURL        string // Data API URL of the decision in an OPA server (see OPAPolicyChecker)
+
+	// File the head of the audit log is appended to every AuditAnchorInterval (see AuditAnchor)
+	AuditAnchorPath     string
+	AuditAnchorInterval time.Duration
+
+	// Audit entries and trace events older than this are purged hourly; 0 keeps them
+	Retention time.Duration
+
+	// Register the built-in demo server (see AddDemoServer)
+	DemoServer bool
+
+	// stdio mode: allow faults to be injected into backends (see ChaosFault)
+	Chaos bool
+
+	// Trace events to keep (see proxy.ParseTraceSampling), whether kept events
+	// are written to the database, and how long they are kept there
+	TraceSample    string
+	TracePersist   bool
+	TraceRetention time.Duration
+}
+
+// pingInterval returns the backend keepalive interval, or 0 if disabled.
+func (c Config) pingInterval() time.Duration {
+	switch {
+	case c.PingInterval < 0:
+		return 0
+	case c.PingInterval == 0:
+		return DefaultPingInterval
+	}
+	return c.PingInterval
+}
+
+// idleTimeout returns the keep-alive timeout of the HTTP listener.
+func (c Config) idleTimeout() time.Duration {
+	if c.IdleTimeout > 0 {
+		return c.IdleTimeout
+	}
+	return defaultIdleTimeout
+}
+
+// maxMessageSize returns the configured message size limit or the default.
+func (c Config) maxMessageSize() int {
+	if c.MaxMessageSize > 0 {
+		return c.MaxMessageSize
+	}
+	return proxy.DefaultMaxMessageSize
 }
 
 type Server struct {
 	config       Config
+	tlsConfig    *tls.Config
 	db           *sql.DB
 	httpServer   *http.Server
 	listener     net.Listener
@@ -38,6 +116,21 @@ type Server struct {
 	forwarder    *proxy.Forwarder
 	logger       *proxy.Logger
 	trace        *proxy.TraceRecorder
+	traceStore   *TraceStore // nil unless trace events are persisted
+	blocklist    *BlocklistMiddleware
+	statsTracker *StatsTracker
+	trust        TrustPolicy
+	egress       EgressPolicy
+	dlp          DLPPolicy
+	cedar        *cedarPolicySet
+	authorizer   AuthorizerPolicy
+	audit        AuditPolicy
+	killSwitch   *KillSwitch
+	offline      *OfflineSwitch
+	checkers     []PolicyChecker         // compiled-in and external policy checkers
+	mocks        map[string]*MockFixture // fixtures of "mock" transport servers
+	tenants      map[string]*tenant      // by name; empty unless a tenants file is configured
+	queue        *WorkQueue
 	mu           sync.RWMutex
 	shutdown     chan struct{}
 }
@@ -46,6 +139,11 @@ func NewServer(config Config) (*Server, error) {
 	var db *sql.DB
 	var err error
 
+	tlsConfig, err := config.TLS.Build()
+	if err != nil {
+		return nil, err
+	}
+
 	if config.DBPath != "" {
 		db, err = sql.Open("sqlite", "file:"+config.DBPath)
 	} else {
@@ -63,40 +161,133 @@ func NewServer(config Config) (*Server, error) {
 
 	traceRecorder := proxy.NewTraceRecorder(200)
 
-	registry, err := proxy.LoadServerRegistry(config.ConfigPath)
+	// With tenants, the proxy's own registry is optional
+	registry := &proxy.ServerRegistry{}
+	if config.Registry != nil {
+		if err := config.Registry.Validate(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		registry = config.Registry
+	} else if config.ConfigPath != "" || config.TenantsPath == "" {
+		registry, err = proxy.LoadServerRegistry(config.ConfigPath)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to load server registry: %v", err)
+		}
+	}
+
+	if config.DemoServer {
+		AddDemoServer(registry)
+	}
+
+	// Every server is exposed on its own path (/mcp/<name> by default)
+	mocks, err := loadRoutes(registry)
 	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to load server registry: %v", err)
+		return nil, err
+	}
+
+	logger := proxy.NewLogger(config.LogLevel)
+	statsTracker := NewStatsTracker()
+
+	killSwitch, err := NewKillSwitch(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	offline, err := NewOfflineSwitch(db)
+	if err == nil {
+		err = offline.ApplyEnv()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	checkers, err := newPolicyCheckers(config)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
 
 	s := &Server{
 		config:       config,
+		tlsConfig:    tlsConfig,
 		db:           db,
 		proxyManager: proxy.NewProxy(db),
 		sessionMgr:   proxy.NewSessionManager(db),
 		resourceMgr:  proxy.NewResourceManager(),
 		oauth:        proxy.NewOAuth(),
-		securityMgr:  proxy.NewSecurityManager(),
+		securityMgr:  proxy.NewSecurityManagerForListener(config.ListenAddr, config.AllowedOrigins, config.AllowedHosts),
 		auditLog:     proxy.NewAuditLog(),
 		registry:     registry,
 		forwarder:    proxy.NewForwarder(proxy.WithForwarderTracer(traceRecorder)),
-		logger:       proxy.NewLogger(config.LogLevel),
+		logger:       logger,
 		shutdown:     make(chan struct{}),
 		trace:        traceRecorder,
+		blocklist:    newProxyBlocklist(db, os.Getenv("ANTHROPIC_API_KEY"), statsTracker, logger, traceRecorder, registry),
+		statsTracker: statsTracker,
+		trust:        DefaultTrustPolicy(),
+		checkers:     checkers,
+		killSwitch:   killSwitch,
+		offline:      offline,
+		queue:        NewWorkQueue(config.Workers, config.SessionConcurrency, config.QueueSize),
+		mocks:        mocks,
+		tenants:      make(map[string]*tenant),
+	}
+
+	if config.TenantsPath != "" {
+		tf, err := LoadTenantsFile(config.TenantsPath)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		for _, tc := range tf.Tenants {
+			t, err := loadTenant(tc, s.blocklist)
+			if err != nil {
+				db.Close()
+				return nil, err
+			}
+			s.tenants[tc.Name] = t
+			logger.Info("tenant %s: %d server(s) under %s%s/", tc.Name, len(t.registry.Servers), tenantPathPrefix, tc.Name)
+		}
 	}
 
-	for _, origin := range config.AllowedOrigins {
-		s.securityMgr.AddAllowedOrigin(origin)
+	if s.traceStore, err = configureTracing(config, db, traceRecorder, logger); err != nil {
+		closePolicyCheckers(checkers)
+		db.Close()
+		return nil, err
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", s.handleHealth)
 	mux.HandleFunc("/mcp", s.handleMCP)
 	mux.HandleFunc("/trace", s.handleTrace)
+	mux.HandleFunc("/queue", s.handleQueue)
+	mux.HandleFunc("/", s.handleRoute)
+
+	var handler http.Handler = mux
+	if !config.DisableCompression {
+		handler = compressResponses(handler)
+	}
+
+	// HTTP/2 is negotiated over TLS; H2C also takes it in cleartext
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(config.H2C)
+	if tlsConfig != nil {
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
 
 	s.httpServer = &http.Server{
-		Addr:    config.ListenAddr,
-		Handler: mux,
+		Addr:              config.ListenAddr,
+		Handler:           s.securityMgr.Middleware(handler),
+		Protocols:         protocols,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       config.idleTimeout(),
 	}
 
 	return s, nil
@@ -108,9 +299,25 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	report := BuildHealthReport(r.Context(), s.db, nil, "")
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	w.WriteHeader(report.HTTPStatus())
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   report.Status,
+		"version":  report.Version,
+		"database": report.Database.Status,
+	})
+}
+
+// handleQueue reports request queue depth and throughput.
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.queue.Stats())
 }
 
 // handleTrace exposes recent translation/forwarding steps for observability.
@@ -131,17 +338,90 @@ func (s *Server) handleTrace(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// SetTrustPolicy replaces the trust policy applied to tool calls. All servers
+// in HTTP mode come from the registry, so they are explicit unless overridden.
+func (s *Server) SetTrustPolicy(tp TrustPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trust = tp
+}
+
+// SetCedarPolicy sets the Cedar policies tool calls are authorized against.
+func (s *Server) SetCedarPolicy(cp CedarPolicy) {
+	compiled := cp.compile()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cedar = compiled
+}
+
+// SetAuthorizerPolicy sets the service "delegate" rules hand decisions to.
+func (s *Server) SetAuthorizerPolicy(ap AuthorizerPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizer = ap
+}
+
+// SetAuditPolicy sets which tool calls keep their arguments in the audit log
+// and which tools are quiet.
+func (s *Server) SetAuditPolicy(ap AuditPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = ap
+	s.statsTracker.SetQuietTools(ap.IsQuiet)
+}
+
+// SetDLPPolicy sets the actions for sensitive data in tool call arguments.
+func (s *Server) SetDLPPolicy(dp DLPPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dlp = dp
+}
+
+// SetEgressPolicy sets the hosts URL arguments of tool calls may point at.
+func (s *Server) SetEgressPolicy(ep EgressPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.egress = ep
+}
+
+// AddRule stores a blocklist rule in the proxy's database and applies it at
+// once. Without a rules server, this is where the proxy's rules live.
+func (s *Server) AddRule(rule *BlocklistRule) error {
+	if err := CreateBlocklistRule(s.db, rule); err != nil {
+		return err
+	}
+	return s.blocklist.RefreshRulesCache()
+}
+
+// GetKillSwitch returns the switch that blocks every tool call while engaged.
+func (s *Server) GetKillSwitch() *KillSwitch {
+	return s.killSwitch
+}
+
+// GetOfflineSwitch returns the switch that blocks network-bound calls while on.
+func (s *Server) GetOfflineSwitch() *OfflineSwitch {
+	return s.offline
+}
+
+// GetRegistry returns the server registry backing the HTTP routes.
+func (s *Server) GetRegistry() *proxy.ServerRegistry {
+	return s.registry
+}
+
+// handleMCP serves the shared /mcp endpoint, selecting the backend with the
+// MCP-Server-Id header or the ?server= query parameter.
 func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
-	origin := r.Header.Get("Origin")
-	if origin != "" {
-		if err := s.securityMgr.ValidateOrigin(origin); err != nil {
-			s.logger.Warn("invalid origin: %s", origin)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
+	t, _, status, err := s.resolveTenant(r)
+	if err != nil {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
+	s.serveSharedMCP(w, r, t)
+}
 
-	if s.registry == nil {
+func (s *Server) serveSharedMCP(w http.ResponseWriter, r *http.Request, t *tenant) {
+	if t.registry == nil {
 		s.logger.Error("server registry not configured")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "server registry not configured"})
@@ -161,7 +441,7 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 		serverID = r.URL.Query().Get("server")
 	}
 
-	server := s.registry.GetServer(serverID)
+	server := t.registry.GetServer(serverID)
 	if server == nil {
 		s.logger.Warn("server not found: %s", serverID)
 		w.WriteHeader(http.StatusBadRequest)
@@ -169,11 +449,51 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.serveMCP(w, r, t, server, sessionID)
+}
+
+// handleRoute serves per-server paths such as /mcp/github, so several
+// backends can sit behind a single hostname, and tenant paths such as
+// /tenants/payments/mcp/github.
+func (s *Server) handleRoute(w http.ResponseWriter, r *http.Request) {
+	t, path, status, err := s.resolveTenant(r)
+	if err != nil {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if path == "/mcp" {
+		s.serveSharedMCP(w, r, t)
+		return
+	}
+
+	var server *proxy.ServerEntry
+	if t.registry != nil {
+		server = t.registry.GetServerByPath(path)
+	}
+	if server == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "no server routed at " + r.URL.Path})
+		return
+	}
+
+	sessionID := r.Header.Get(proxy.HeaderSessionID)
+	if sessionID == "" {
+		s.logger.Debug("missing session ID header")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "missing MCP-Session-Id header"})
+		return
+	}
+
+	s.serveMCP(w, r, t, server, sessionID)
+}
+
+func (s *Server) serveMCP(w http.ResponseWriter, r *http.Request, t *tenant, server *proxy.ServerEntry, sessionID string) {
 	s.logger.Debug("incoming %s %s (session: %s, server: %s)", r.Method, r.RequestURI, sessionID, server.Name)
 
 	switch r.Method {
 	case http.MethodPost:
-		s.handleMCPPost(w, r, server, sessionID)
+		s.handleMCPPost(w, r, t, server, sessionID)
 	case http.MethodGet:
 		s.handleMCPGet(w, r, server, sessionID)
 	default:
@@ -181,24 +501,92 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) handleMCPPost(w http.ResponseWriter, r *http.Request, server *proxy.ServerEntry, sessionID string) {
-	if server.Transport != "http" {
-		s.logger.Error("POST on non-http server: %s", server.Transport)
+func (s *Server) handleMCPPost(w http.ResponseWriter, r *http.Request, t *tenant, server *proxy.ServerEntry, sessionID string) {
+	// Every call gets a request ID, the client's own if it sent a usable one,
+	// to follow it through the logs, audit log and traces
+	requestID := r.Header.Get(HeaderRequestID)
+	if !validRequestID(requestID) {
+		requestID = NewRequestID()
+	}
+	ctx := WithRequestID(r.Context(), requestID)
+	logger := loggerFor(ctx, s.logger)
+	w.Header().Set(HeaderRequestID, requestID)
+
+	if server.Transport != "http" && server.Transport != "mock" {
+		logger.Error("POST on non-http server: %s", server.Transport)
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "POST only supported for http transport"})
+		json.NewEncoder(w).Encode(map[string]string{"error": "POST only supported for http and mock transports"})
+		return
+	}
+
+	limit := s.config.maxMessageSize()
+	request, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to read request body"})
+		return
+	}
+	if len(request) > limit {
+		logger.Warn("rejecting %s request over %d bytes", server.Name, limit)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		resp := requestTooLarge(nil, limit)
+		resp.Error.Data = withRequestIDData(resp.Error.Data, requestID)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	release, err := s.queue.Acquire(ctx, sessionID)
+	if err == ErrQueueFull {
+		logger.Warn("request queue full, rejecting %s request", server.Name)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   &JSONRPCError{Code: -32000, Message: "Server busy", Data: withRequestIDData("too many requests in flight, retry later", requestID)},
+		})
+		return
+	}
+	if err != nil {
+		return // client went away while queued
+	}
+	defer release()
+
+	// Denied requests are answered by the proxy and never reach the backend
+	if denied := s.enforcePolicy(ctx, t, server, sessionID, request); denied != nil {
+		w.Header().Set(proxy.HeaderProtocolVersion, proxy.MCPProtocolVersion)
+		w.Header().Set(proxy.HeaderSessionID, sessionID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(denied)
+		return
+	}
+	request = s.redactRequest(t, request)
+
+	if fixture := t.mocks[server.Name]; fixture != nil {
+		w.Header().Set(proxy.HeaderProtocolVersion, proxy.MCPProtocolVersion)
+		w.Header().Set(proxy.HeaderSessionID, sessionID)
+		reply := fixture.Handle(request)
+		if reply == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply)
 		return
 	}
 
-	body, statusCode, err := s.forwarder.ForwardPOST(server.URL, sessionID, r.Body)
+	body, statusCode, err := s.forwarder.ForwardPOST(server.URL, sessionID, bytes.NewReader(request))
 	if err != nil {
-		s.logger.Error("failed to forward POST: %v", err)
+		logger.Error("failed to forward POST: %v", err)
+		s.traceFailure(ctx, server.Name, http.MethodPost, "http", err)
 		w.WriteHeader(http.StatusBadGateway)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "request_id": requestID})
 		return
 	}
 	defer body.Close()
 
-	s.logger.Debug("upstream response: %d", statusCode)
+	logger.Debug("upstream response: %d", statusCode)
 
 	w.Header().Set(proxy.HeaderProtocolVersion, proxy.MCPProtocolVersion)
 	w.Header().Set(proxy.HeaderSessionID, sessionID)
@@ -211,6 +599,7 @@ func (s *Server) handleMCPPost(w http.ResponseWriter, r *http.Request, server *p
 				Method:    http.MethodPost,
 				Transport: "http",
 				Detail:    "no content response",
+				RequestID: requestID,
 			})
 		}
 		return
@@ -226,6 +615,8 @@ func (s *Server) handleMCPPost(w http.ResponseWriter, r *http.Request, server *p
 			Method:    http.MethodPost,
 			Transport: "http",
 			Detail:    fmt.Sprintf("upstream status %d", statusCode),
+			Error:     statusCode >= http.StatusBadRequest,
+			RequestID: requestID,
 		})
 	}
 }
@@ -242,6 +633,7 @@ func (s *Server) handleMCPGet(w http.ResponseWriter, r *http.Request, server *pr
 	resp, err := s.forwarder.ForwardGET(server.URL, sessionID, lastEventID)
 	if err != nil {
 		s.logger.Error("failed to forward GET: %v", err)
+		s.traceFailure(r.Context(), server.Name, http.MethodGet, "sse", err)
 		w.WriteHeader(http.StatusBadGateway)
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
@@ -270,6 +662,21 @@ func (s *Server) handleMCPGet(w http.ResponseWriter, r *http.Request, server *pr
 	}
 }
 
+// traceFailure records a request that never reached the upstream server.
+func (s *Server) traceFailure(ctx context.Context, server, method, transport string, err error) {
+	if s.trace != nil {
+		s.trace.Add(proxy.TraceEvent{
+			Stage:     "forward",
+			Server:    server,
+			Method:    method,
+			Transport: transport,
+			Detail:    fmt.Sprintf("forward failed: %v", err),
+			Error:     true,
+			RequestID: RequestIDFrom(ctx),
+		})
+	}
+}
+
 func (s *Server) ListenAndServe(ctx context.Context) error {
 	var err error
 	s.listener, err = net.Listen("tcp", s.config.ListenAddr)
@@ -277,11 +684,21 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 	defer s.listener.Close()
+	if s.tlsConfig != nil {
+		s.listener = tls.NewListener(s.listener, s.tlsConfig)
+	}
+
+	if s.config.AuditAnchorPath != "" && s.db != nil {
+		defer startAuditAnchors(ctx, s.db, s.config, s.logger)()
+	}
+	if s.config.Retention > 0 {
+		defer startRetention(ctx, s.db, s.trace, s.config, s.logger)()
+	}
 
 	errChan := make(chan error, 1)
 
 	go func() {
-		s.logger.Info("server started on %s", s.listener.Addr().String())
+		s.logger.Info("server started on %s (tls: %t, client certs: %s)", s.listener.Addr().String(), s.tlsConfig != nil, s.clientAuthMode())
 		errChan <- s.httpServer.Serve(s.listener)
 	}()
 
@@ -300,6 +717,23 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 	return nil
 }
 
+func (s *Server) clientAuthMode() string {
+	switch {
+	case s.tlsConfig == nil || s.tlsConfig.ClientAuth == tls.NoClientCert:
+		return "off"
+	case len(s.config.TLS.ClientFingerprints) > 0:
+		return fmt.Sprintf("%d allowlisted", len(s.config.TLS.ClientFingerprints))
+	default:
+		return "CA-verified"
+	}
+}
+
+// Handler returns the proxy's HTTP handler, for serving it from another
+// program's listener instead of ListenAndServe.
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
 func (s *Server) GetListenAddr() string {
 	if s.listener != nil {
 		return s.listener.Addr().String()
@@ -311,6 +745,10 @@ func (s *Server) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	closePolicyCheckers(s.checkers)
+	if s.traceStore != nil {
+		s.traceStore.Close()
+	}
 	if s.db != nil {
 		s.db.Close()
 	}
@@ -374,3 +812,10 @@ func initDB(db *sql.DB) error {
 }
 
 const shutdownTimeout = 30 * time.Second
+
+const (
+	// defaultIdleTimeout keeps keep-alive connections of clients that poll
+	// open between requests.
+	defaultIdleTimeout = 2 * time.Minute
+	readHeaderTimeout  = 10 * time.Second
+)
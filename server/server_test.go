@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -282,3 +283,155 @@ func makeTestConfig(t *testing.T, addr string) (Config, *httptest.Server) {
 		Mode:       "http",
 	}, upstream
 }
+
+func TestPathRoutingAppliesPolicy(t *testing.T) {
+	var upstreamHits int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"ok":true}}`))
+	}))
+	defer upstream.Close()
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "servers.json")
+	configJSON := fmt.Sprintf(`{
+  "servers": [
+    {"name": "github", "transport": "http", "url": "%s"},
+    {"name": "database", "transport": "http", "url": "%s", "path": "/mcp/db"}
+  ]
+}`, upstream.URL, upstream.URL)
+	if err := os.WriteFile(configPath, []byte(configJSON), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("ARMOUR_RULES_URL", "")
+
+	server, err := NewServer(Config{
+		ListenAddr: "127.0.0.1:0",
+		ConfigPath: configPath,
+		DBPath:     filepath.Join(tmpDir, "proxy.db"),
+		Mode:       "http",
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer server.Close()
+	server.blocklist.SetRulesServerURL("")
+
+	if _, err := server.db.Exec(`INSERT INTO blocklist_rules (pattern, description, is_regex, is_semantic) VALUES ('rm -rf', 'destructive shell', 1, 0)`); err != nil {
+		t.Fatalf("failed to insert rule: %v", err)
+	}
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Host = "127.0.0.1"
+		req.Header.Set(proxy.HeaderSessionID, "route-session")
+		rec := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/mcp/db", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`); rec.Code != http.StatusOK || upstreamHits != 1 {
+		t.Fatalf("expected /mcp/db to be forwarded, got %d (hits %d)", rec.Code, upstreamHits)
+	}
+	if rec := post("/mcp/missing", `{}`); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unrouted path, got %d", rec.Code)
+	}
+
+	rec := post("/mcp/github", `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"run","arguments":{"query":"rm -rf /"}}}`)
+	var resp JSONRPCResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrCodeDenied {
+		t.Fatalf("expected blocklist denial, got %+v", resp)
+	}
+	if data, _ := resp.Error.Data.(map[string]interface{}); data["blocked_by"] != BlockedByRule || data["rule_id"] == nil || data["tool"] != "github:run" {
+		t.Errorf("expected rule block data, got %+v", resp.Error.Data)
+	}
+	if id := rec.Header().Get(HeaderRequestID); id == "" || resp.Error.Data.(map[string]interface{})["request_id"] != id {
+		t.Errorf("expected the denial to carry the %s header's request ID %q, got %+v", HeaderRequestID, id, resp.Error.Data)
+	} else if entries, _ := QueryAuditLog(server.db, AuditFilter{RequestID: id}); len(entries) != 1 || !entries[0].Blocked {
+		t.Errorf("expected the denial in the audit log under %s, got %+v", id, entries)
+	}
+	if upstreamHits != 1 {
+		t.Errorf("denied call reached the backend")
+	}
+
+	server.SetTrustPolicy(TrustPolicy{Explicit: TrustDeny, Servers: map[string]TrustTier{"database": TrustConfig}})
+	rec = post("/mcp/github", `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"list_issues"}}`)
+	resp = JSONRPCResponse{}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Error == nil {
+		t.Fatalf("expected trust denial for github, got %+v", resp)
+	}
+	if data, _ := resp.Error.Data.(map[string]interface{}); data["blocked_by"] != BlockedByTrust || data["action"] != string(TrustDeny) {
+		t.Errorf("expected trust block data, got %+v", resp.Error.Data)
+	}
+	if rec := post("/mcp/db", `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"query"}}`); upstreamHits != 2 {
+		t.Errorf("expected database call to be forwarded, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	entries, err := QueryAuditLog(server.db, AuditFilter{})
+	if err != nil {
+		t.Fatalf("failed to query audit log: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("expected 3 audit entries, got %d", len(entries))
+	}
+}
+
+func TestOversizedRequestRejected(t *testing.T) {
+	config, upstream := makeTestConfig(t, "127.0.0.1:0")
+	defer upstream.Close()
+	config.MaxMessageSize = 1024
+	t.Setenv("ARMOUR_RULES_URL", "")
+
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer server.Close()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"text":"` + strings.Repeat("x", 1024) + `"}}}`
+	req := httptest.NewRequest(http.MethodPost, "/mcp/test", strings.NewReader(body))
+	req.Host = "127.0.0.1"
+	req.Header.Set(proxy.HeaderSessionID, "big-session")
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "Request too large") {
+		t.Errorf("expected 413 with a JSON-RPC error, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestQueueEndpoint(t *testing.T) {
+	config, upstream := makeTestConfig(t, "127.0.0.1:0")
+	defer upstream.Close()
+	config.Workers = 3
+	t.Setenv("ARMOUR_RULES_URL", "")
+
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer server.Close()
+
+	post := httptest.NewRequest(http.MethodPost, "/mcp/test", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	post.Host = "127.0.0.1"
+	post.Header.Set(proxy.HeaderSessionID, "queue-session")
+	server.httpServer.Handler.ServeHTTP(httptest.NewRecorder(), post)
+
+	req := httptest.NewRequest(http.MethodGet, "/queue", nil)
+	req.Host = "127.0.0.1"
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+
+	var stats WorkQueueStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode queue stats: %v", err)
+	}
+	if stats.Workers != 3 || stats.Completed != 1 || stats.InFlight != 0 {
+		t.Errorf("unexpected queue stats: %+v", stats)
+	}
+}
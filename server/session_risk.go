@@ -0,0 +1,194 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionActiveWindow is how recent a session's last call must be for
+	// it to count as active.
+	sessionActiveWindow = 15 * time.Minute
+	// sessionScanLimit bounds how many audit entries ListSessions reads.
+	sessionScanLimit = 20000
+	// sessionBurstCalls in one minute flags a session as bursting.
+	sessionBurstCalls = 30
+)
+
+// Anomaly flags of a session: the block reasons that point at an agent
+// acting against the user, plus bursts of calls. Each adds its weight to
+// the risk score once.
+var sessionAnomalyWeights = map[string]int{
+	"decoy_called":       60,
+	"sensitive_data":     25,
+	"egress_denied":      25,
+	"path_outside_roots": 25,
+	"kill_switch":        15,
+	"burst":              15,
+}
+
+// SessionSummary is an agent session as seen in the audit log, with a risk
+// score to decide which session to look at first.
+type SessionSummary struct {
+	SessionID   string    `json:"session_id"`
+	Transport   string    `json:"transport,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Active      bool      `json:"active"`
+	Calls       int       `json:"calls"`
+	Blocked     int       `json:"blocked"`
+	Destructive int       `json:"destructive"` // destructive tools and SQL deletes or schema changes
+	Anomalies   []string  `json:"anomalies,omitempty"`
+	Servers     []string  `json:"servers,omitempty"`
+	RiskScore   int       `json:"risk_score"` // 0-100
+}
+
+// score computes the risk score: 10 per blocked attempt, 5 per destructive
+// call and the weight of each anomaly flag, capped at 100.
+func (ss *SessionSummary) score() {
+	score := 10*ss.Blocked + 5*ss.Destructive
+	for _, flag := range ss.Anomalies {
+		score += sessionAnomalyWeights[flag]
+	}
+	ss.RiskScore = min(score, 100)
+}
+
+// isDestructiveCall reports whether an audited call destroys data: a tool
+// named like rm or delete_*, or SQL that deletes rows or changes the schema.
+func isDestructiveCall(e AuditEntry) bool {
+	if e.Method != "tools/call" {
+		return false
+	}
+	if isDestructivePattern(e.ToolName) {
+		return true
+	}
+	for _, class := range strings.Split(e.StatementClass, ",") {
+		if class == SQLDelete || class == SQLDDL {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeSession aggregates the entries of one session, oldest first.
+func summarizeSession(sessionID string, entries []AuditEntry, now time.Time) SessionSummary {
+	ss := SessionSummary{SessionID: sessionID}
+	flags := make(map[string]bool)
+	servers := make(map[string]bool)
+	var window []time.Time
+	for _, e := range entries {
+		if ss.FirstSeen.IsZero() || e.Timestamp.Before(ss.FirstSeen) {
+			ss.FirstSeen = e.Timestamp
+		}
+		if e.Timestamp.After(ss.LastSeen) {
+			ss.LastSeen = e.Timestamp
+		}
+		if ss.Transport == "" {
+			ss.Transport = e.Transport
+		}
+		if e.ServerID != "" {
+			servers[e.ServerID] = true
+		}
+		ss.Calls++
+		if e.Blocked {
+			ss.Blocked++
+			if _, ok := sessionAnomalyWeights[e.BlockReason]; ok {
+				flags[e.BlockReason] = true
+			}
+		}
+		if isDestructiveCall(e) {
+			ss.Destructive++
+		}
+
+		window = append(window, e.Timestamp)
+		for len(window) > 0 && e.Timestamp.Sub(window[0]) > time.Minute {
+			window = window[1:]
+		}
+		if len(window) >= sessionBurstCalls {
+			flags["burst"] = true
+		}
+	}
+
+	for flag := range flags {
+		ss.Anomalies = append(ss.Anomalies, flag)
+	}
+	sort.Strings(ss.Anomalies)
+	for server := range servers {
+		ss.Servers = append(ss.Servers, server)
+	}
+	sort.Strings(ss.Servers)
+	ss.Active = now.Sub(ss.LastSeen) < sessionActiveWindow
+	ss.score()
+	return ss
+}
+
+// ListSessions summarizes the sessions in the audit log, riskiest first and
+// then most recent first. limit caps the number returned (0 for no cap).
+func ListSessions(db *sql.DB, activeOnly bool, limit int) ([]SessionSummary, error) {
+	if err := ensureAuditSchema(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`
+		SELECT id, timestamp, COALESCE(server_id, ''), COALESCE(method, ''), COALESCE(tool_name, ''),
+		       session_id, COALESCE(transport, ''), COALESCE(blocked, 0),
+		       COALESCE(block_reason, ''), COALESCE(statement_class, '')
+		FROM audit_log WHERE COALESCE(session_id, '') != ''
+		ORDER BY id DESC LIMIT ?`, sessionScanLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	bySession := make(map[string][]AuditEntry)
+	for rows.Next() {
+		var e AuditEntry
+		var blocked int
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.ServerID, &e.Method, &e.ToolName,
+			&e.SessionID, &e.Transport, &blocked, &e.BlockReason, &e.StatementClass); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.Blocked = blocked == 1
+		// Rows come newest first; prepend to keep each session oldest first
+		bySession[e.SessionID] = append([]AuditEntry{e}, bySession[e.SessionID]...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+
+	now := time.Now()
+	sessions := []SessionSummary{}
+	for id, entries := range bySession {
+		ss := summarizeSession(id, entries, now)
+		if activeOnly && !ss.Active {
+			continue
+		}
+		sessions = append(sessions, ss)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].RiskScore != sessions[j].RiskScore {
+			return sessions[i].RiskScore > sessions[j].RiskScore
+		}
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+// GetSession returns the summary and audit entries of one session, or nil
+// if the audit log has no entries for it.
+func GetSession(db *sql.DB, sessionID string) (*SessionSummary, []AuditEntry, error) {
+	entries, err := QueryAuditLog(db, AuditFilter{Session: sessionID, Limit: sessionScanLimit})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil, nil
+	}
+	ss := summarizeSession(sessionID, entries, time.Now())
+	return &ss, entries, nil
+}
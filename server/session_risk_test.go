@@ -0,0 +1,80 @@
+package server
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestListSessionsScoresRisk tests session aggregation, anomaly flags and
+// ordering by risk
+func TestListSessionsScoresRisk(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+	entries := []AuditEntry{
+		// quiet: two reads an hour ago
+		{SessionID: "quiet", ServerID: "fs", Method: "tools/call", ToolName: "fs:read_file", Timestamp: old},
+		{SessionID: "quiet", ServerID: "fs", Method: "tools/call", ToolName: "fs:list", Timestamp: old.Add(time.Second)},
+		// risky: reads a secret, deletes rows, then calls a decoy
+		{SessionID: "risky", ServerID: "pg", Method: "tools/call", ToolName: "pg:query", StatementClass: "delete", Timestamp: now.Add(-time.Minute)},
+		{SessionID: "risky", ServerID: "fs", Method: "tools/call", ToolName: "fs:delete_file", Blocked: true, BlockReason: "blocklist_match", Timestamp: now.Add(-30 * time.Second)},
+		{SessionID: "risky", ServerID: "secrets", Method: "tools/call", ToolName: "secrets:read_aws_credentials", Blocked: true, BlockReason: "decoy_called", Timestamp: now},
+		// no session: not listed
+		{ServerID: "fs", Method: "tools/call", ToolName: "fs:list", Timestamp: now},
+	}
+	for i := 0; i < sessionBurstCalls; i++ {
+		entries = append(entries, AuditEntry{SessionID: "busy", ServerID: "web", Method: "tools/call", ToolName: "web:fetch", Timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	for _, e := range entries {
+		if err := RecordAuditEntry(db, e); err != nil {
+			t.Fatalf("Failed to record entry: %v", err)
+		}
+	}
+
+	sessions, err := ListSessions(db, false, 0)
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	var order []string
+	for _, ss := range sessions {
+		order = append(order, ss.SessionID)
+	}
+	if strings.Join(order, ",") != "risky,busy,quiet" {
+		t.Fatalf("expected sessions riskiest first, got %v", order)
+	}
+
+	risky := sessions[0]
+	if risky.Calls != 3 || risky.Blocked != 2 || risky.Destructive != 2 || strings.Join(risky.Anomalies, ",") != "decoy_called" {
+		t.Errorf("unexpected risky session summary: %+v", risky)
+	}
+	if risky.RiskScore != 90 || !risky.Active || strings.Join(risky.Servers, ",") != "fs,pg,secrets" {
+		t.Errorf("expected score 90 for an active session on three servers, got %+v", risky)
+	}
+	if busy := sessions[1]; strings.Join(busy.Anomalies, ",") != "burst" || busy.RiskScore != 15 {
+		t.Errorf("expected a burst of calls flagged, got %+v", busy)
+	}
+	if quiet := sessions[2]; quiet.RiskScore != 0 || quiet.Active {
+		t.Errorf("expected an inactive session without risk, got %+v", quiet)
+	}
+
+	active, err := ListSessions(db, true, 1)
+	if err != nil || len(active) != 1 || active[0].SessionID != "risky" {
+		t.Errorf("expected only the riskiest active session, got %+v (err=%v)", active, err)
+	}
+
+	summary, sessionEntries, err := GetSession(db, "risky")
+	if err != nil || summary == nil || summary.RiskScore != risky.RiskScore || len(sessionEntries) != 3 {
+		t.Errorf("expected the risky session's drill-down, got %+v %d entries (err=%v)", summary, len(sessionEntries), err)
+	}
+	if summary, _, err := GetSession(db, "missing"); err != nil || summary != nil {
+		t.Errorf("expected no summary for an unknown session, got %+v (err=%v)", summary, err)
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// SessionKeyEnv names the stdio stream a proxy serves. A supervisor that
+// restarts the proxy on the same stream sets it so the new process finds
+// the session its predecessor saved; otherwise the parent process ID is used.
+const SessionKeyEnv = "ARMOUR_SESSION_KEY"
+
+// stdioSessionState is what a stdio session needs to carry on after the
+// proxy restarts without the client sending initialize again.
+type stdioSessionState struct {
+	Version       string              `json:"version"`
+	ClientInfo    *proxy.ClientInfo   `json:"client_info,omitempty"`
+	ClientCaps    *proxy.Capabilities `json:"client_capabilities,omitempty"`
+	Subscriptions []string            `json:"subscriptions,omitempty"` // armour:// URIs
+	Tools         []string            `json:"tools,omitempty"`         // namespaced tools the client was last shown
+	AuditSession  string              `json:"audit_session"`
+}
+
+// stdioSessionKey returns the key of the stdio stream this process serves.
+func stdioSessionKey() string {
+	if key := os.Getenv(SessionKeyEnv); key != "" {
+		return key
+	}
+	return "ppid-" + strconv.Itoa(os.Getppid())
+}
+
+func initSessionStateTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS stdio_sessions (
+			key TEXT PRIMARY KEY,
+			state TEXT NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create stdio_sessions table: %w", err)
+	}
+	return nil
+}
+
+// persistsSession reports whether the session is saved: an in-memory
+// database doesn't outlive the process, so only with -db.
+func (s *StdioServer) persistsSession() bool {
+	return s.config.DBPath != ""
+}
+
+// saveSession persists the session so a restarted proxy can resume it.
+// Failures are logged: the session itself carries on regardless.
+func (s *StdioServer) saveSession() {
+	if !s.persistsSession() {
+		return
+	}
+	s.mu.RLock()
+	state := stdioSessionState{
+		Version:       s.version,
+		ClientInfo:    s.clientInfo,
+		ClientCaps:    s.clientCaps,
+		Subscriptions: make([]string, 0, len(s.subscriptions)),
+		AuditSession:  s.auditSession,
+	}
+	for uri := range s.subscriptions {
+		state.Subscriptions = append(state.Subscriptions, uri)
+	}
+	s.mu.RUnlock()
+	slices.Sort(state.Subscriptions)
+	state.Tools = s.toolNames()
+
+	data, _ := json.Marshal(state)
+	_, err := s.db.Exec(`
+		INSERT INTO stdio_sessions (key, state, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET state = excluded.state, updated_at = excluded.updated_at
+	`, s.sessionKey, string(data), time.Now().UTC())
+	if err != nil {
+		s.logger.Warn("failed to save session state: %v", err)
+	}
+}
+
+// clearSession forgets the saved session once the client has closed the
+// stream, so a later process with the same key starts afresh.
+func (s *StdioServer) clearSession() {
+	if !s.persistsSession() {
+		return
+	}
+	if _, err := s.db.Exec("DELETE FROM stdio_sessions WHERE key = ?", s.sessionKey); err != nil {
+		s.logger.Warn("failed to clear session state: %v", err)
+	}
+}
+
+// loadSession returns the saved session of this process's stream, or nil.
+func (s *StdioServer) loadSession() (*stdioSessionState, error) {
+	if !s.persistsSession() {
+		return nil, nil
+	}
+	var data string
+	err := s.db.QueryRow("SELECT state FROM stdio_sessions WHERE key = ?", s.sessionKey).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state stdioSessionState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("invalid saved session: %w", err)
+	}
+	return &state, nil
+}
+
+// resumeSession picks up the saved session when the first message a new
+// process reads is not initialize, i.e. the proxy was restarted mid-session.
+// Backends are started as on initialize; once they are up, subscriptions
+// are renewed and the client is told if the tools changed. It reports
+// whether there was a session to resume.
+func (s *StdioServer) resumeSession(ctx context.Context) bool {
+	state, err := s.loadSession()
+	if err != nil {
+		s.logger.Warn("failed to load session state: %v", err)
+	}
+	if state == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	s.version = state.Version
+	s.clientInfo = state.ClientInfo
+	s.clientCaps = state.ClientCaps
+	if state.AuditSession != "" {
+		s.auditSession = state.AuditSession
+	}
+	for _, uri := range state.Subscriptions {
+		s.subscriptions[uri] = true
+	}
+	s.mu.Unlock()
+	if state.ClientInfo != nil {
+		s.statsTracker.SetSessionClient(s.auditSession, state.ClientInfo.Name)
+	}
+	s.logger.Info("resuming session after a restart (protocol %s, %d subscription(s))", state.Version, len(state.Subscriptions))
+
+	s.startBackends(ctx, func() {
+		// The client keeps the capabilities it was first given; serve what
+		// the backends support now
+		caps := s.aggregateCapabilities()
+		s.mu.Lock()
+		s.serverCaps = caps
+		s.mu.Unlock()
+		for _, uri := range state.Subscriptions {
+			backendName, originalURI := parseArmourURI(uri)
+			if err := s.backendManager.SubscribeToResource(ctx, backendName, originalURI); err != nil {
+				s.logger.Warn("failed to renew subscription to %s: %v", uri, err)
+			}
+		}
+		if !slices.Equal(s.toolNames(), state.Tools) {
+			if err := s.sendNotification("notifications/tools/list_changed", nil); err != nil {
+				s.logger.Warn("failed to announce tools/list_changed: %v", err)
+			}
+		}
+	})
+	caps := s.aggregateCapabilities()
+	s.mu.Lock()
+	s.serverCaps = caps
+	s.mu.Unlock()
+	s.initialized = true
+	return true
+}
+
+// notInitialized answers a request that came before initialize. After a
+// restart with no session to resume, it says how to recover.
+func (s *StdioServer) notInitialized(id interface{}) JSONRPCResponse {
+	if s.sessionLost {
+		return s.makeError(id, -32603, "Not initialized", "the proxy restarted and could not restore this MCP session; reconnect the server to initialize it again")
+	}
+	return s.makeError(id, -32603, "Not initialized", "Call initialize first")
+}
+
+// toolNames returns the sorted names of the registered tools.
+func (s *StdioServer) toolNames() []string {
+	var names []string
+	for _, tool := range s.toolRegistry.ListAllTools() {
+		names = append(names, tool.Name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestStdioSessionResume(t *testing.T) {
+	config := Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}
+	t.Setenv(SessionKeyEnv, "test-stream")
+
+	srv, err := NewStdioServer(config, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	params := `{"protocolVersion":"2025-03-26","clientInfo":{"name":"test","version":"1.0"},"capabilities":{}}`
+	resp, _ := srv.handleRequest(context.Background(), JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(params)}).(JSONRPCResponse)
+	if resp.Error != nil {
+		t.Fatalf("expected initialize to succeed, got %+v", resp.Error)
+	}
+	srv.mu.Lock()
+	srv.subscriptions["armour://files/notes.txt"] = true
+	srv.mu.Unlock()
+	srv.saveSession()
+	srv.Close()
+
+	// A restarted proxy on the same stream answers without a new initialize
+	run := func() (JSONRPCResponse, *StdioServer) {
+		t.Helper()
+		srv, err := NewStdioServer(config, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+		if err != nil {
+			t.Fatalf("failed to create server: %v", err)
+		}
+		defer srv.Close()
+		var out bytes.Buffer
+		srv.reader = proxy.NewMessageReader(strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`+"\n"), srv.maxMessageSize)
+		srv.out = &out
+		if err := srv.Run(context.Background()); err != nil {
+			t.Fatalf("expected clean EOF, got %v", err)
+		}
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", out.String(), err)
+		}
+		return resp, srv
+	}
+	resp, resumed := run()
+	if resp.Error != nil {
+		t.Errorf("expected resumed session to list tools, got %+v", resp.Error)
+	}
+	if resumed.version != "2025-03-26" || !resumed.subscriptions["armour://files/notes.txt"] {
+		t.Errorf("expected saved version and subscription, got %s %v", resumed.version, resumed.subscriptions)
+	}
+
+	// The clean EOF forgot the session, so the next restart must re-initialize
+	resp, _ = run()
+	if resp.Error == nil {
+		t.Fatalf("expected a re-initialize prompt, got %+v", resp)
+	}
+	if data, _ := resp.Error.Data.(map[string]interface{}); !strings.Contains(fmt.Sprint(data["detail"]), "restarted") {
+		t.Errorf("expected a re-initialize prompt, got %+v", resp)
+	}
+}
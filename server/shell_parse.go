@@ -0,0 +1,637 @@
+package server
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxShellDepth bounds how deep bash -c, eval and command substitutions are
+// unwrapped, so a crafted argument can't make the parser recurse forever.
+const maxShellDepth = 8
+
+// shellArgKeys are the arguments of Bash-like tools that hold a shell script.
+var shellArgKeys = []string{"command", "cmd", "script"}
+
+// ShellCommand is one simple command a shell script would run.
+type ShellCommand struct {
+	Name    string         // program, without its directory
+	Args    []string       // arguments with quoting and escapes removed
+	Env     []string       // NAME=value assignments before the command
+	Sources []ShellCommand // commands whose output it reads: earlier pipeline stages and substitutions
+}
+
+// String renders the command as its program and arguments, space separated.
+func (c ShellCommand) String() string {
+	return strings.TrimSpace(c.Name + " " + strings.Join(c.Args, " "))
+}
+
+var (
+	// Wrappers run the rest of their arguments as a command
+	shellWrappers = map[string]bool{
+		"sudo": true, "doas": true, "env": true, "nohup": true, "time": true, "nice": true,
+		"command": true, "exec": true, "builtin": true, "xargs": true, "timeout": true, "stdbuf": true, "setsid": true,
+	}
+	// Wrapper options that take a value, e.g. sudo -u root
+	shellWrapperValueOpts = map[string]string{
+		"sudo": "-u -g -C -h -p -U -r -t -D", "doas": "-u -C", "env": "-u -C", "nice": "-n",
+		"xargs": "-I -n -P -L -s -d -E -a", "timeout": "-s -k",
+	}
+	// Keywords that start, continue or end a compound command rather than name a program
+	shellKeywords = map[string]bool{
+		"if": true, "then": true, "else": true, "elif": true, "fi": true, "do": true, "done": true,
+		"while": true, "until": true, "!": true, "{": true, "}": true, "esac": true,
+	}
+	shellInterpreters = map[string]bool{
+		"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true, "fish": true,
+		"python": true, "python2": true, "python3": true, "perl": true, "ruby": true, "node": true, "php": true,
+		"eval": true, "source": true, ".": true,
+	}
+	// Interpreters whose -c argument is itself a shell script
+	shellNames       = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true, "fish": true}
+	shellFetchers    = map[string]bool{"curl": true, "wget": true, "fetch": true, "aria2c": true, "nc": true, "ncat": true}
+	shellAssignment  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+	shellIdentifier  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	shellRootTargets = map[string]bool{"~": true, "~/": true, "~/*": true, "$HOME": true, "${HOME}": true, "$HOME/": true, "/*": true}
+)
+
+// ParseShell splits a shell script into the simple commands it would run.
+// It understands quoting, escapes, pipes, &&, ||, ;, command and process
+// substitution, $'..' strings, $IFS word splitting and variables assigned
+// earlier in the script, and unwraps sudo, env, xargs, bash -c and eval, so
+// `r”m -rf /`, `a=rm; $a -rf /` and `sudo sh -c "rm -rf /"` all yield
+// an rm command. It doesn't run anything or expand globs.
+func ParseShell(script string) []ShellCommand {
+	return parseShellDepth(script, 0, map[string]string{})
+}
+
+func parseShellDepth(script string, depth int, vars map[string]string) []ShellCommand {
+	if depth > maxShellDepth {
+		return nil
+	}
+	p := &shellParser{src: []rune(script), depth: depth, vars: vars}
+	p.parse()
+	return p.out
+}
+
+type shellParser struct {
+	src   []rune
+	pos   int
+	depth int
+	vars  map[string]string // simple assignments seen so far
+	out   []ShellCommand    // every command found, in execution order
+
+	// State of the simple command being read
+	words      []string
+	word       strings.Builder
+	inWord     bool
+	quoted     bool // the current word has quoted parts
+	subs       []ShellCommand
+	dropNext   bool // the next word is a redirection target
+	pipeline   []ShellCommand
+	pipeToNext bool
+	last       *ShellCommand // the last command recorded, for `(a) | b`
+}
+
+func (p *shellParser) peek(offset int) rune {
+	if p.pos+offset < len(p.src) {
+		return p.src[p.pos+offset]
+	}
+	return 0
+}
+
+func (p *shellParser) endWord() {
+	if p.inWord {
+		if p.dropNext {
+			p.dropNext = false
+		} else {
+			p.words = append(p.words, p.word.String())
+		}
+	}
+	p.word.Reset()
+	p.inWord, p.quoted = false, false
+}
+
+func (p *shellParser) write(s string) {
+	p.word.WriteString(s)
+	p.inWord = true
+}
+
+func (p *shellParser) parse() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.endWord()
+			p.pos++
+		case c == '\\':
+			if p.peek(1) != '\n' && p.pos+1 < len(p.src) {
+				p.write(string(p.src[p.pos+1]))
+			}
+			p.pos += 2
+		case c == '\'':
+			end := p.indexFrom(p.pos+1, '\'')
+			p.write(string(p.src[p.pos+1 : end]))
+			p.quoted = true
+			p.pos = end + 1
+		case c == '"':
+			p.readDoubleQuoted()
+		case c == '`':
+			p.pos++
+			inner := p.readUntil('`')
+			p.substitute(inner)
+			p.write("`" + inner + "`")
+		case c == '$':
+			p.readDollar()
+		case c == '#' && !p.inWord:
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case (c == '<' || c == '>') && p.peek(1) == '(':
+			p.pos += 2
+			inner := p.readBalanced()
+			p.substitute(inner)
+			p.write(string(c) + "(" + inner + ")")
+		case c == '<' || c == '>' || (c == '&' && p.peek(1) == '>'):
+			p.readRedirect()
+		case strings.ContainsRune("|&;\n()", c):
+			p.readOperator()
+		default:
+			p.write(string(c))
+			p.pos++
+		}
+	}
+	p.finishCommand("")
+}
+
+// indexFrom returns the index of the next r at or after i, or the end of input.
+func (p *shellParser) indexFrom(i int, r rune) int {
+	for ; i < len(p.src); i++ {
+		if p.src[i] == r {
+			return i
+		}
+	}
+	return len(p.src)
+}
+
+// readUntil returns the text up to the next unescaped r and moves past it.
+func (p *shellParser) readUntil(r rune) string {
+	var b strings.Builder
+	for p.pos < len(p.src) && p.src[p.pos] != r {
+		if p.src[p.pos] == '\\' && p.pos+1 < len(p.src) {
+			p.pos++
+		}
+		b.WriteRune(p.src[p.pos])
+		p.pos++
+	}
+	p.pos++
+	return b.String()
+}
+
+// readBalanced returns the text up to the ')' closing an already consumed
+// '(' and moves past it, skipping parentheses inside quotes.
+func (p *shellParser) readBalanced() string {
+	start, depth := p.pos, 1
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '\'':
+			p.pos = p.indexFrom(p.pos+1, '\'')
+		case '"':
+			for p.pos++; p.pos < len(p.src) && p.src[p.pos] != '"'; p.pos++ {
+				if p.src[p.pos] == '\\' {
+					p.pos++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				inner := string(p.src[start:p.pos])
+				p.pos++
+				return inner
+			}
+		}
+		p.pos++
+	}
+	return string(p.src[start:])
+}
+
+// substitute parses the script of a command or process substitution. Its
+// commands run before the one being read, which reads their output.
+func (p *shellParser) substitute(script string) {
+	cmds := parseShellDepth(script, p.depth+1, p.vars)
+	p.out = append(p.out, cmds...)
+	p.subs = append(p.subs, cmds...)
+}
+
+func (p *shellParser) readDoubleQuoted() {
+	p.pos++
+	p.quoted = true
+	p.inWord = true
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		c := p.src[p.pos]
+		switch {
+		case c == '\\' && strings.ContainsRune("\"\\$`\n", p.peek(1)):
+			if p.peek(1) != '\n' {
+				p.word.WriteRune(p.peek(1))
+			}
+			p.pos += 2
+		case c == '`':
+			p.pos++
+			inner := p.readUntil('`')
+			p.substitute(inner)
+			p.word.WriteString("`" + inner + "`")
+		case c == '$' && p.peek(1) == '(':
+			p.readDollar()
+		case c == '$':
+			// Variables inside quotes aren't split, but known values are used
+			p.pos++
+			if name := p.readVarName(); name != "" {
+				if v, ok := p.vars[name]; ok {
+					p.word.WriteString(v)
+				} else {
+					p.word.WriteString("$" + name)
+				}
+			} else {
+				p.word.WriteRune('$')
+			}
+		default:
+			p.word.WriteRune(c)
+			p.pos++
+		}
+	}
+	p.pos++
+}
+
+// readVarName reads $NAME or ${NAME} after the '$' and returns NAME.
+func (p *shellParser) readVarName() string {
+	if p.peek(0) == '{' {
+		p.pos++
+		return p.readUntil('}')
+	}
+	name := shellIdentifier.FindString(string(p.src[p.pos:min(len(p.src), p.pos+64)]))
+	p.pos += len([]rune(name))
+	return name
+}
+
+func (p *shellParser) readDollar() {
+	switch p.peek(1) {
+	case '(':
+		p.pos += 2
+		if p.peek(0) == '(' {
+			// Arithmetic: no commands inside
+			p.pos++
+			inner := p.readBalanced()
+			p.readBalanced()
+			p.write("$((" + inner + "))")
+			return
+		}
+		inner := p.readBalanced()
+		p.substitute(inner)
+		p.write("$(" + inner + ")")
+	case '\'':
+		p.pos += 2
+		p.write(p.readANSIC())
+		p.quoted = true
+	default:
+		p.pos++
+		name := p.readVarName()
+		switch {
+		case name == "":
+			p.write("$")
+		case isIFS(name):
+			// Unquoted $IFS splits words like a space
+			p.endWord()
+		default:
+			if v, ok := p.vars[name]; ok {
+				p.write(v)
+			} else {
+				p.write("$" + name)
+			}
+		}
+	}
+}
+
+// isIFS reports whether a variable expansion is $IFS, including forms like
+// ${IFS%??} that still expand to whitespace.
+func isIFS(name string) bool {
+	return strings.HasPrefix(name, "IFS") && (len(name) == 3 || !shellIdentifier.MatchString("A"+name[3:4]))
+}
+
+// readANSIC decodes a $'...' string, whose escapes (\x72, \162, \n) can
+// spell out a command name.
+func (p *shellParser) readANSIC() string {
+	var b strings.Builder
+	for p.pos < len(p.src) && p.src[p.pos] != '\'' {
+		c := p.src[p.pos]
+		p.pos++
+		if c != '\\' || p.pos >= len(p.src) {
+			b.WriteRune(c)
+			continue
+		}
+		e := p.src[p.pos]
+		p.pos++
+		switch {
+		case e == 'n':
+			b.WriteRune('\n')
+		case e == 't':
+			b.WriteRune('\t')
+		case e == 'x':
+			b.WriteRune(p.readCode(16, 2))
+		case e == 'u':
+			b.WriteRune(p.readCode(16, 4))
+		case e >= '0' && e <= '7':
+			p.pos--
+			b.WriteRune(p.readCode(8, 3))
+		default:
+			b.WriteRune(e)
+		}
+	}
+	p.pos++
+	return b.String()
+}
+
+// readCode reads up to n digits in base and returns the rune they encode.
+func (p *shellParser) readCode(base, n int) rune {
+	digits := "01234567"
+	if base == 16 {
+		digits = "0123456789abcdefABCDEF"
+	}
+	start := p.pos
+	for p.pos < len(p.src) && p.pos-start < n && strings.ContainsRune(digits, p.src[p.pos]) {
+		p.pos++
+	}
+	v, err := strconv.ParseInt(string(p.src[start:p.pos]), base, 32)
+	if err != nil {
+		return 0
+	}
+	return rune(v)
+}
+
+// readRedirect skips a redirection operator; its target isn't an argument.
+// A file descriptor number right before the operator (2>) is dropped too.
+func (p *shellParser) readRedirect() {
+	if p.inWord && !p.quoted && isDigits(p.word.String()) {
+		p.word.Reset()
+		p.inWord = false
+	}
+	p.endWord()
+	for p.pos < len(p.src) && strings.ContainsRune("<>&|", p.src[p.pos]) {
+		p.pos++
+	}
+	// >&2 and <&- name a descriptor rather than a file
+	if p.src[p.pos-1] == '&' {
+		for p.pos < len(p.src) && (p.src[p.pos] == '-' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+			p.pos++
+		}
+		return
+	}
+	p.dropNext = true
+}
+
+func (p *shellParser) readOperator() {
+	p.endWord()
+	op := string(p.src[p.pos])
+	p.pos++
+	switch pair := op + string(p.peek(0)); pair {
+	case "||", "|&", "&&", ";;":
+		op = pair
+		p.pos++
+	}
+	p.finishCommand(op)
+}
+
+// finishCommand records the simple command read so far; op is the operator
+// that ended it.
+func (p *shellParser) finishCommand(op string) {
+	p.endWord()
+	p.dropNext = false
+	words, subs := p.words, p.subs
+	p.words, p.subs = nil, nil
+
+	var sources []ShellCommand
+	if p.pipeToNext {
+		sources = append(sources, p.pipeline...)
+	}
+	sources = append(sources, subs...)
+
+	cmd, ok := p.command(words, sources)
+	if ok {
+		p.last = &cmd
+	}
+	switch {
+	case op == "|" || op == "|&":
+		if p.last != nil {
+			p.pipeline = append(p.pipeline, *p.last)
+		}
+		p.pipeToNext = true
+	case !ok && (op == "" || op == "\n" || op == "(") && p.pipeToNext:
+		// A pipe may continue on the next line or into a subshell
+	default:
+		p.last = nil
+		p.pipeline, p.pipeToNext = nil, false
+	}
+}
+
+// command unwraps one simple command and records it with any commands it
+// runs in turn. It reports false if the words name no command.
+func (p *shellParser) command(words []string, sources []ShellCommand) (ShellCommand, bool) {
+	var env []string
+	for len(words) > 0 && shellAssignment.MatchString(words[0]) {
+		env = append(env, words[0])
+		words = words[1:]
+	}
+	for len(words) > 0 && shellKeywords[words[0]] {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		// A bare assignment sets a variable for the rest of the script
+		for _, assignment := range env {
+			name, value, _ := strings.Cut(assignment, "=")
+			p.vars[name] = value
+		}
+		return ShellCommand{}, false
+	}
+	if words[0] == "for" || words[0] == "case" || words[0] == "function" {
+		return ShellCommand{}, false
+	}
+
+	cmd := ShellCommand{Name: path.Base(words[0]), Args: words[1:], Env: env, Sources: sources}
+	p.out = append(p.out, cmd)
+
+	switch {
+	case shellWrappers[cmd.Name]:
+		valueOpts := strings.Fields(shellWrapperValueOpts[cmd.Name])
+		rest := cmd.Args
+		for len(rest) > 0 && (strings.HasPrefix(rest[0], "-") || shellAssignment.MatchString(rest[0]) ||
+			(cmd.Name == "timeout" && isDigits(strings.TrimRight(rest[0], "smhd")))) {
+			if slices.Contains(valueOpts, rest[0]) && len(rest) > 1 {
+				rest = rest[1:]
+			}
+			rest = rest[1:]
+		}
+		if len(rest) > 0 {
+			p.command(rest, sources)
+		}
+	case cmd.Name == "eval":
+		p.out = append(p.out, parseShellDepth(strings.Join(cmd.Args, " "), p.depth+1, p.vars)...)
+	case shellNames[cmd.Name]:
+		for i, arg := range cmd.Args {
+			if isCommandOpt(arg) && i+1 < len(cmd.Args) {
+				p.out = append(p.out, parseShellDepth(cmd.Args[i+1], p.depth+1, p.vars)...)
+				break
+			}
+		}
+	}
+	return cmd, true
+}
+
+// isCommandOpt reports whether an interpreter option is -c, alone or
+// combined with others as in bash -lc.
+func isCommandOpt(arg string) bool {
+	return strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.HasSuffix(arg, "c")
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ShellFindings names the dangerous patterns in parsed commands:
+//
+//   - pipe-to-shell: a script downloaded with curl or wget is run, as in
+//     `curl x | sh` or `bash -c "$(curl x)"`
+//   - decode-exec: decoded data is run, as in `echo .. | base64 -d | sh`
+//   - rm-root: a recursive rm of /, ~ or $HOME
+func ShellFindings(cmds []ShellCommand) []string {
+	seen := make(map[string]bool)
+	var findings []string
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			findings = append(findings, f)
+		}
+	}
+
+	for _, cmd := range cmds {
+		if runsInput(cmd) {
+			for _, src := range cmd.Sources {
+				if shellFetchers[src.Name] {
+					add("pipe-to-shell")
+				}
+				if isDecoder(src) {
+					add("decode-exec")
+				}
+			}
+		}
+		if isRecursiveRootRemoval(cmd) {
+			add("rm-root")
+		}
+	}
+	return findings
+}
+
+// runsInput reports whether an interpreter runs a script it reads from its
+// input or a substitution rather than from a file named in its arguments.
+func runsInput(cmd ShellCommand) bool {
+	if !shellInterpreters[cmd.Name] {
+		return false
+	}
+	if cmd.Name == "eval" || cmd.Name == "source" || cmd.Name == "." {
+		return true
+	}
+	for i, arg := range cmd.Args {
+		switch {
+		case arg == "-s" || arg == "-":
+			return true
+		case isCommandOpt(arg):
+			// -c SCRIPT: the script is input if it is a substitution
+			return i+1 < len(cmd.Args) && isSubstitution(cmd.Args[i+1])
+		case strings.HasPrefix(arg, "-"):
+			continue
+		default:
+			return isSubstitution(arg) || arg == "/dev/stdin"
+		}
+	}
+	return true
+}
+
+func isSubstitution(arg string) bool {
+	return strings.HasPrefix(arg, "$(") || strings.HasPrefix(arg, "`") || strings.HasPrefix(arg, "<(")
+}
+
+func isDecoder(cmd ShellCommand) bool {
+	switch cmd.Name {
+	case "base64", "base32", "basenc", "openssl":
+		for _, arg := range cmd.Args {
+			if arg == "-d" || arg == "-D" || arg == "--decode" {
+				return true
+			}
+		}
+	case "xxd":
+		for _, arg := range cmd.Args {
+			if strings.HasPrefix(arg, "-r") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isRecursiveRootRemoval(cmd ShellCommand) bool {
+	if cmd.Name != "rm" {
+		return false
+	}
+	recursive, root := false, false
+	for _, arg := range cmd.Args {
+		switch {
+		case arg == "--recursive":
+			recursive = true
+		case arg == "--no-preserve-root":
+			root = true
+		case strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--"):
+			recursive = recursive || strings.ContainsAny(arg, "rR")
+		case shellRootTargets[arg] || (strings.HasPrefix(arg, "/") && path.Clean(arg) == "/"):
+			root = true
+		}
+	}
+	return recursive && root
+}
+
+// shellScript returns the script argument of a Bash-like tool call.
+func shellScript(args map[string]interface{}) string {
+	for _, key := range shellArgKeys {
+		if script, ok := args[key].(string); ok && script != "" {
+			return script
+		}
+	}
+	return ""
+}
+
+// shellContent renders a script for rule matching: one normalized command
+// per line, followed by a shell:<finding> line per ShellFindings result. A
+// regex rule like `(?m)^rm -rf /$` or a literal rule `shell:pipe-to-shell`
+// then matches what would actually run, however it is quoted.
+func shellContent(script string) string {
+	cmds := ParseShell(script)
+	lines := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		lines = append(lines, cmd.String())
+	}
+	for _, f := range ShellFindings(cmds) {
+		lines = append(lines, "shell:"+f)
+	}
+	return strings.Join(lines, "\n")
+}
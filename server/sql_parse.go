@@ -0,0 +1,296 @@
+package server
+
+import (
+	"slices"
+	"strings"
+)
+
+// Statement classes reported by ParseSQL.
+const (
+	SQLSelect = "select" // SELECT, SHOW, EXPLAIN, ...
+	SQLInsert = "insert" // INSERT, REPLACE
+	SQLUpdate = "update" // UPDATE, MERGE
+	SQLDelete = "delete"
+	SQLDDL    = "ddl" // schema and privilege changes: CREATE, ALTER, DROP, TRUNCATE, GRANT, ...
+	SQLOther  = "other"
+)
+
+// sqlArgKeys are argument names whose value is SQL whenever it parses as a
+// statement. Other string arguments, including the "query" of search tools,
+// must also contain a clause keyword.
+var sqlArgKeys = []string{"sql", "statement", "stmt"}
+
+var sqlVerbClasses = map[string]string{
+	"SELECT": SQLSelect, "VALUES": SQLSelect, "SHOW": SQLSelect, "EXPLAIN": SQLSelect, "DESCRIBE": SQLSelect, "DESC": SQLSelect,
+	"INSERT": SQLInsert, "REPLACE": SQLInsert,
+	"UPDATE": SQLUpdate, "MERGE": SQLUpdate, "UPSERT": SQLUpdate,
+	"DELETE": SQLDelete,
+	"CREATE": SQLDDL, "ALTER": SQLDDL, "DROP": SQLDDL, "TRUNCATE": SQLDDL, "RENAME": SQLDDL, "COMMENT": SQLDDL,
+	"GRANT": SQLDDL, "REVOKE": SQLDDL,
+	"BEGIN": SQLOther, "COMMIT": SQLOther, "ROLLBACK": SQLOther, "SET": SQLOther, "USE": SQLOther, "PRAGMA": SQLOther,
+	"CALL": SQLOther, "EXEC": SQLOther, "EXECUTE": SQLOther, "COPY": SQLOther, "VACUUM": SQLOther, "ANALYZE": SQLOther,
+}
+
+// sqlClauseKeywords tell SQL apart from prose that starts with "update" or
+// "delete" in arguments not named like SQL.
+var sqlClauseKeywords = []string{"FROM", "INTO", "SET", "WHERE", "TABLE", "VALUES", "INDEX", "VIEW", "DATABASE", "SCHEMA"}
+
+// SQLStatement is one statement of a SQL argument.
+type SQLStatement struct {
+	Verb  string // leading keyword of the statement, upper case
+	Class string // one of the SQL* classes
+	Where bool   // an UPDATE or DELETE has a WHERE clause that isn't always true
+}
+
+// Unbounded reports whether an UPDATE or DELETE touches every row.
+func (st SQLStatement) Unbounded() bool {
+	return (st.Verb == "UPDATE" || st.Verb == "DELETE") && !st.Where
+}
+
+// ParseSQL splits SQL into statements and classifies each by its verb. It
+// skips comments, string literals and quoted identifiers, looks through WITH
+// clauses to the statement they belong to, and treats WHERE 1=1 and WHERE
+// true as no WHERE at all. It isn't a validating parser: text that doesn't
+// start with a known verb yields no statement.
+func ParseSQL(sql string) []SQLStatement {
+	var statements []SQLStatement
+	for _, tokens := range splitSQL(sql) {
+		if st, ok := classifySQL(tokens); ok {
+			statements = append(statements, st)
+		}
+	}
+	return statements
+}
+
+// sqlToken is a keyword or identifier (upper-cased), a literal, or a single
+// punctuation character.
+type sqlToken struct {
+	text    string
+	literal bool
+}
+
+// splitSQL tokenizes sql and splits the tokens into statements at ';'.
+func splitSQL(sql string) [][]sqlToken {
+	var statements [][]sqlToken
+	var current []sqlToken
+	src := []rune(sql)
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '-' && i+1 < len(src) && src[i+1] == '-', c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			i = runeIndex(src, i+2, "*/") + 2
+		case c == '\'' || c == '"' || c == '`':
+			// Quotes are doubled to escape them inside literals
+			j := i + 1
+			for j < len(src) && (src[j] != c || (j+1 < len(src) && src[j+1] == c)) {
+				if src[j] == c || src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			current = append(current, sqlToken{text: string(src[i:min(j+1, len(src))]), literal: true})
+			i = j + 1
+		case c == '$' && i+1 < len(src) && (src[i+1] == '$' || isSQLIdentRune(src[i+1])):
+			// Postgres dollar quoting: $tag$ ... $tag$
+			j := i + 1
+			for j < len(src) && isSQLIdentRune(src[j]) {
+				j++
+			}
+			if j >= len(src) || src[j] != '$' {
+				current = append(current, sqlToken{text: string(src[i:j])})
+				i = j
+				continue
+			}
+			tag := string(src[i : j+1])
+			i = runeIndex(src, j+1, tag) + len([]rune(tag))
+			current = append(current, sqlToken{text: tag, literal: true})
+		case c == ';':
+			if len(current) > 0 {
+				statements = append(statements, current)
+			}
+			current = nil
+			i++
+		case isSQLIdentRune(c):
+			j := i
+			for j < len(src) && isSQLIdentRune(src[j]) {
+				j++
+			}
+			current = append(current, sqlToken{text: strings.ToUpper(string(src[i:j]))})
+			i = j
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			current = append(current, sqlToken{text: string(c)})
+			i++
+		}
+	}
+	if len(current) > 0 {
+		statements = append(statements, current)
+	}
+	return statements
+}
+
+// runeIndex returns the index of sub in src at or after from, or len(src).
+func runeIndex(src []rune, from int, sub string) int {
+	if from > len(src) {
+		return len(src)
+	}
+	if n := strings.Index(string(src[from:]), sub); n >= 0 {
+		return from + len([]rune(string(src[from:])[:n]))
+	}
+	return len(src)
+}
+
+func isSQLIdentRune(c rune) bool {
+	return c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c > 127
+}
+
+// classifySQL finds the verb of a statement: its first keyword, or for
+// WITH ... the first verb outside the parenthesized definitions.
+func classifySQL(tokens []sqlToken) (SQLStatement, bool) {
+	start := 0
+	for start < len(tokens) && tokens[start].text == "(" {
+		start++
+	}
+	if start == len(tokens) {
+		return SQLStatement{}, false
+	}
+
+	verbAt := start
+	if tokens[start].text == "WITH" {
+		verbAt = -1
+		depth := 0
+		for i := start + 1; i < len(tokens); i++ {
+			switch tokens[i].text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			default:
+				if _, ok := sqlVerbClasses[tokens[i].text]; ok && depth == 0 && !tokens[i].literal {
+					verbAt = i
+				}
+			}
+			if verbAt >= 0 {
+				break
+			}
+		}
+		if verbAt < 0 {
+			return SQLStatement{}, false
+		}
+	}
+
+	verb := tokens[verbAt].text
+	class, ok := sqlVerbClasses[verb]
+	if !ok || tokens[verbAt].literal {
+		return SQLStatement{}, false
+	}
+	st := SQLStatement{Verb: verb, Class: class}
+	if verb == "UPDATE" || verb == "DELETE" {
+		st.Where = hasBoundingWhere(tokens[verbAt+1:])
+	}
+	return st, true
+}
+
+// hasBoundingWhere reports whether a statement body has a top-level WHERE
+// clause that can be false.
+func hasBoundingWhere(tokens []sqlToken) bool {
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case t.text == "WHERE" && depth == 0 && !t.literal:
+			return !alwaysTrue(tokens[i+1:])
+		}
+	}
+	return false
+}
+
+// alwaysTrue reports whether a WHERE condition is a tautology like 1=1 or
+// TRUE, up to the clauses that may follow it.
+func alwaysTrue(tokens []sqlToken) bool {
+	var cond strings.Builder
+	for _, t := range tokens {
+		if !t.literal && slices.Contains([]string{"RETURNING", "ORDER", "LIMIT"}, t.text) {
+			break
+		}
+		if t.text != "(" && t.text != ")" {
+			cond.WriteString(t.text)
+		}
+	}
+	switch c := cond.String(); c {
+	case "TRUE", "1", "1=1", "''=''", "'1'='1'", "1<>0", "1!=0", "NOT0", "NOTFALSE":
+		return true
+	}
+	return false
+}
+
+// sqlArgument returns the name and text of the first argument that holds SQL.
+func sqlArgument(args map[string]interface{}) (string, string) {
+	for _, key := range sqlArgKeys {
+		if text, ok := args[key].(string); ok && len(ParseSQL(text)) > 0 {
+			return key, text
+		}
+	}
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		text, ok := args[key].(string)
+		if !ok || slices.Contains(sqlArgKeys, key) || len(ParseSQL(text)) == 0 {
+			continue
+		}
+		for _, stmt := range splitSQL(text) {
+			for _, t := range stmt {
+				if !t.literal && slices.Contains(sqlClauseKeywords, t.text) {
+					return key, text
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// SQLStatementClass returns the classes of the statements in a call's SQL
+// argument, comma separated in order of first appearance, or "" if it has
+// none. It is what the audit log records for database tool calls.
+func SQLStatementClass(args map[string]interface{}) string {
+	_, text := sqlArgument(args)
+	var classes []string
+	for _, st := range ParseSQL(text) {
+		if !slices.Contains(classes, st.Class) {
+			classes = append(classes, st.Class)
+		}
+	}
+	return strings.Join(classes, ",")
+}
+
+// sqlContent renders the statements of a SQL argument for rule matching: a
+// sql:<class> line per statement class, plus sql:unbounded-delete or
+// sql:unbounded-update for an UPDATE or DELETE without a WHERE clause. A
+// literal rule `sql:ddl` on `tag:db` then blocks schema changes on every
+// database server, whatever the statement's case, comments or quoting.
+func sqlContent(sql string) string {
+	var lines []string
+	add := func(line string) {
+		if !slices.Contains(lines, line) {
+			lines = append(lines, line)
+		}
+	}
+	for _, st := range ParseSQL(sql) {
+		add("sql:" + st.Class)
+		if st.Unbounded() {
+			add("sql:unbounded-" + strings.ToLower(st.Verb))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
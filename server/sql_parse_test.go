@@ -0,0 +1,107 @@
+package server
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestParseSQL tests statement classification and unbounded writes
+func TestParseSQL(t *testing.T) {
+	tests := []struct {
+		sql       string
+		classes   string // statement classes, comma separated
+		unbounded bool
+	}{
+		{"SELECT * FROM users WHERE id = 1", "select", false},
+		{"  -- comment\n/* block; */ select 1", "select", false},
+		{"INSERT INTO t (a) VALUES ('x; DROP TABLE t')", "insert", false},
+		{"update users set admin = true where id = 7", "update", false},
+		{"UPDATE users SET admin = true", "update", true},
+		{"DELETE FROM sessions", "delete", true},
+		{"DELETE FROM sessions WHERE 1=1", "delete", true},
+		{"DELETE FROM sessions WHERE (TRUE) RETURNING id", "delete", true},
+		{"DELETE FROM sessions WHERE expires < now()", "delete", false},
+		{"DELETE FROM a WHERE id IN (SELECT id FROM b)", "delete", false},
+		{"DELETE FROM a USING (SELECT 1 WHERE x) b", "delete", true},
+		{"WITH old AS (SELECT id FROM t WHERE x) DELETE FROM t", "delete", true},
+		{"create table x (id int); drop index y", "ddl,ddl", false},
+		{"TRUNCATE logs", "ddl", false},
+		{"BEGIN; DELETE FROM t WHERE id = 1; COMMIT;", "other,delete,other", false},
+		{"CREATE FUNCTION f() RETURNS int AS $$ DELETE FROM t; $$ LANGUAGE sql", "ddl", false},
+		{"hello world", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			var classes []string
+			unbounded := false
+			for _, st := range ParseSQL(tt.sql) {
+				classes = append(classes, st.Class)
+				unbounded = unbounded || st.Unbounded()
+			}
+			if got := strings.Join(classes, ","); got != tt.classes || unbounded != tt.unbounded {
+				t.Errorf("expected %q unbounded=%v, got %q unbounded=%v", tt.classes, tt.unbounded, got, unbounded)
+			}
+		})
+	}
+
+	if class := SQLStatementClass(map[string]interface{}{"query": "DELETE FROM jobs WHERE done"}); class != "delete" {
+		t.Errorf("expected SQL in a query argument to be classified, got %q", class)
+	}
+	if class := SQLStatementClass(map[string]interface{}{"query": "update me on the weather"}); class != "" {
+		t.Errorf("expected prose not to be classified, got %q", class)
+	}
+}
+
+// TestSQLRulesOnDatabaseServers tests blocking DDL and unbounded DELETE on
+// tagged database servers and recording the statement class
+func TestSQLRulesOnDatabaseServers(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "sql.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := initDB(db); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	rule := &BlocklistRule{Pattern: `sql:(ddl|unbounded-delete)`, Action: "block", IsRegex: true, Tools: "tag:db", Enabled: true, Permissions: DefaultPermissions("block")}
+	if err := CreateBlocklistRule(db, rule); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+	bm := NewBlocklistMiddleware(db, "", nil, nil, nil)
+	bm.SetRegistry(&proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "pg", Tags: []string{"db"}}}})
+
+	for _, tc := range []struct {
+		sql     string
+		allowed bool
+	}{
+		{"SELECT * FROM users", true},
+		{"DELETE FROM users WHERE id = 3", true},
+		{"delete from users", false},
+		{"/* cleanup */ DROP TABLE users", false},
+	} {
+		result, err := bm.Check("tools/call", "pg:query", map[string]interface{}{"sql": tc.sql})
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if result.Allowed != tc.allowed {
+			t.Errorf("%s: expected allowed=%v, got %+v", tc.sql, tc.allowed, result)
+		}
+	}
+
+	args := map[string]interface{}{"sql": "DROP TABLE users"}
+	if err := RecordAuditEntry(db, AuditEntry{ServerID: "pg", Method: "tools/call", ToolName: "pg:query", StatementClass: SQLStatementClass(args)}); err != nil {
+		t.Fatalf("Failed to record entry: %v", err)
+	}
+	entries, err := QueryAuditLog(db, AuditFilter{})
+	if err != nil || len(entries) != 1 || entries[0].StatementClass != "ddl" {
+		t.Fatalf("expected the statement class in the audit log, got %+v (err=%v)", entries, err)
+	}
+	if line := FormatAuditEntry(entries[0]); !strings.Contains(line, "[sql:ddl]") {
+		t.Errorf("expected the statement class in the tail output, got %q", line)
+	}
+}
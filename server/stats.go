@@ -15,9 +15,18 @@ type StatsTracker struct {
 	blockedToolsCount  map[string]int64  // Count per tool name
 	allowedToolsCount  map[string]int64  // Count per tool name
 	blockedByReason    map[string]int64  // Count by blocking reason (strict_mode, policy, destructive)
+	schemaViolations   map[string]int64  // Output schema violations per backend
+	contentTypes       map[string]int64  // Tool result content items by type (text, image, audio, ...)
+	contentFiltered    int64             // Content items replaced by the content policy
+	spendByTool        map[string]float64 // Cost-weighted spend per tool this session
+	sessions           map[string]*OriginStats // Calls by session ID, with the session's client
+	quiet              func(toolName string) bool // Tools whose allowed calls are left out of the headline stats
+	quietCallsTotal    int64             // Allowed calls of quiet tools
+	quietToolsCount    map[string]int64  // Allowed calls of quiet tools per tool name
 
 	// Time-series data
 	dailyStats map[string]*DailyStats   // YYYY-MM-DD -> stats
+	series     *seriesStore             // per-minute counts and latencies, downsampled as they age
 
 	// Configuration
 	startTime time.Time
@@ -39,7 +48,13 @@ func NewStatsTracker() *StatsTracker {
 		blockedToolsCount: make(map[string]int64),
 		allowedToolsCount: make(map[string]int64),
 		blockedByReason:   make(map[string]int64),
+		schemaViolations:  make(map[string]int64),
+		contentTypes:      make(map[string]int64),
+		spendByTool:       make(map[string]float64),
+		sessions:          make(map[string]*OriginStats),
+		quietToolsCount:   make(map[string]int64),
 		dailyStats:        make(map[string]*DailyStats),
+		series:            newSeriesStore(),
 		startTime:         time.Now(),
 	}
 }
@@ -52,6 +67,7 @@ func (st *StatsTracker) RecordBlockedCall(toolName string, reason string) {
 	st.blockedCallsTotal++
 	st.blockedToolsCount[toolName]++
 	st.blockedByReason[reason]++
+	st.series.record(seriesBucket{blocked: 1})
 
 	// Update daily stats
 	today := time.Now().Format("2006-01-02")
@@ -68,13 +84,29 @@ func (st *StatsTracker) RecordBlockedCall(toolName string, reason string) {
 	st.dailyStats[today].BlockingReasons[reason]++
 }
 
+// SetQuietTools sets the tools whose allowed calls are counted apart from the
+// headline stats, so noisy tools don't drown out meaningful events.
+func (st *StatsTracker) SetQuietTools(quiet func(toolName string) bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.quiet = quiet
+}
+
 // RecordAllowedCall records a tool call that was allowed.
 func (st *StatsTracker) RecordAllowedCall(toolName string) {
 	st.mu.Lock()
 	defer st.mu.Unlock()
 
+	if st.quiet != nil && st.quiet(toolName) {
+		st.quietCallsTotal++
+		st.quietToolsCount[toolName]++
+		return
+	}
+
 	st.allowedCallsTotal++
 	st.allowedToolsCount[toolName]++
+	st.series.record(seriesBucket{allowed: 1})
 
 	// Update daily stats
 	today := time.Now().Format("2006-01-02")
@@ -90,6 +122,145 @@ func (st *StatsTracker) RecordAllowedCall(toolName string) {
 	st.dailyStats[today].UniqueTools[toolName]++
 }
 
+// RecordSchemaViolation records a tool result that didn't match its outputSchema.
+func (st *StatsTracker) RecordSchemaViolation(backendID string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.schemaViolations[backendID]++
+}
+
+// RecordContent records the content item types of a tool result and how many
+// items the content policy replaced.
+func (st *StatsTracker) RecordContent(types []string, filtered int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, t := range types {
+		st.contentTypes[t]++
+	}
+	st.contentFiltered += int64(filtered)
+}
+
+// RecordSpend records the cost weight of an allowed tool call.
+func (st *StatsTracker) RecordSpend(toolName string, cost float64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.spendByTool[toolName] += cost
+}
+
+// maxSessionStats caps the sessions counted; the least recently seen is
+// forgotten to make room, so long-running HTTP-mode proxies stay bounded.
+const maxSessionStats = 1000
+
+// topSessionStats is how many sessions a snapshot lists, most blocked first.
+const topSessionStats = 20
+
+// unknownClient names the client of a session that sent no clientInfo.
+const unknownClient = "unknown"
+
+// SetSessionClient records the client application of a session, from the
+// clientInfo.name of its initialize request.
+func (st *StatsTracker) SetSessionClient(sessionID, client string) {
+	if sessionID == "" {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.session(sessionID).Client = client
+}
+
+// RecordSessionCall counts an allowed or blocked call against the session
+// it was made in, and so against the session's client.
+func (st *StatsTracker) RecordSessionCall(sessionID string, blocked bool) {
+	if sessionID == "" {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	origin := st.session(sessionID)
+	if blocked {
+		origin.Blocked++
+	} else {
+		origin.Allowed++
+	}
+}
+
+// session returns the stats of a session, adding them if needed. The
+// caller holds st.mu.
+func (st *StatsTracker) session(sessionID string) *OriginStats {
+	origin := st.sessions[sessionID]
+	if origin == nil {
+		if len(st.sessions) >= maxSessionStats {
+			oldest := ""
+			for id, o := range st.sessions {
+				if oldest == "" || o.LastSeen.Before(st.sessions[oldest].LastSeen) {
+					oldest = id
+				}
+			}
+			delete(st.sessions, oldest)
+		}
+		origin = &OriginStats{Name: sessionID}
+		st.sessions[sessionID] = origin
+	}
+	origin.LastSeen = time.Now()
+	return origin
+}
+
+// originStats returns the busiest sessions and the totals of every client
+// application, both with the most blocked calls first. The caller holds
+// st.mu.
+func (st *StatsTracker) originStats() (sessions, clients []OriginStats) {
+	byClient := make(map[string]*OriginStats)
+	sessions = make([]OriginStats, 0, len(st.sessions))
+	for _, o := range st.sessions {
+		session := *o
+		if session.Client == "" {
+			session.Client = unknownClient
+		}
+		sessions = append(sessions, session)
+
+		client := byClient[session.Client]
+		if client == nil {
+			client = &OriginStats{Name: session.Client}
+			byClient[session.Client] = client
+		}
+		client.Allowed += session.Allowed
+		client.Blocked += session.Blocked
+		client.Sessions++
+		if session.LastSeen.After(client.LastSeen) {
+			client.LastSeen = session.LastSeen
+		}
+	}
+	clients = make([]OriginStats, 0, len(byClient))
+	for _, c := range byClient {
+		clients = append(clients, *c)
+	}
+	sortOrigins(sessions)
+	sortOrigins(clients)
+	if len(sessions) > topSessionStats {
+		sessions = sessions[:topSessionStats]
+	}
+	return sessions, clients
+}
+
+// sortOrigins orders origins by blocked calls, then all calls, then name.
+func sortOrigins(origins []OriginStats) {
+	sort.Slice(origins, func(i, j int) bool {
+		a, b := origins[i], origins[j]
+		if a.Blocked != b.Blocked {
+			return a.Blocked > b.Blocked
+		}
+		if a.Allowed+a.Blocked != b.Allowed+b.Blocked {
+			return a.Allowed+a.Blocked > b.Allowed+b.Blocked
+		}
+		return a.Name < b.Name
+	})
+}
+
 // GetStats returns the current aggregate statistics.
 func (st *StatsTracker) GetStats() StatsSnapshot {
 	st.mu.RLock()
@@ -100,6 +271,7 @@ func (st *StatsTracker) GetStats() StatsSnapshot {
 	if totalCalls > 0 {
 		blockRate = float64(st.blockedCallsTotal) / float64(totalCalls) * 100
 	}
+	sessions, clients := st.originStats()
 
 	return StatsSnapshot{
 		Timestamp:          time.Now().Unix(),
@@ -112,6 +284,13 @@ func (st *StatsTracker) GetStats() StatsSnapshot {
 		BlockedByReason:    st.copyMap(st.blockedByReason),
 		TopBlockedTools:    st.topTools(st.blockedToolsCount, 5),
 		TopAllowedTools:    st.topTools(st.allowedToolsCount, 5),
+		SchemaViolations:   st.copyMap(st.schemaViolations),
+		ContentTypes:       st.copyMap(st.contentTypes),
+		ContentFiltered:    st.contentFiltered,
+		SpendByTool:        st.copySpend(st.spendByTool),
+		QuietCallsTotal:    st.quietCallsTotal,
+		BySession:          sessions,
+		ByClient:           clients,
 		Uptime:             time.Since(st.startTime).Seconds(),
 	}
 }
@@ -236,6 +415,31 @@ func (st *StatsTracker) GetGitHubREADMEStats() string {
 	return output
 }
 
+// ToolHits returns the allowed and blocked calls of every tool called
+// since startup, by tool name.
+func (st *StatsTracker) ToolHits() map[string]ToolHits {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	hits := make(map[string]ToolHits, len(st.allowedToolsCount)+len(st.blockedToolsCount))
+	for name, count := range st.allowedToolsCount {
+		h := hits[name]
+		h.Allowed = count
+		hits[name] = h
+	}
+	for name, count := range st.blockedToolsCount {
+		h := hits[name]
+		h.Blocked = count
+		hits[name] = h
+	}
+	for name, count := range st.quietToolsCount {
+		h := hits[name]
+		h.Allowed += count
+		hits[name] = h
+	}
+	return hits
+}
+
 // Helper methods
 
 func (st *StatsTracker) copyMap(m map[string]int64) map[string]int64 {
@@ -246,6 +450,14 @@ func (st *StatsTracker) copyMap(m map[string]int64) map[string]int64 {
 	return copy
 }
 
+func (st *StatsTracker) copySpend(m map[string]float64) map[string]float64 {
+	copy := make(map[string]float64)
+	for k, v := range m {
+		copy[k] = v
+	}
+	return copy
+}
+
 func (st *StatsTracker) topTools(tools map[string]int64, limit int) []ToolStat {
 	// Convert to slice for sorting
 	toolStats := make([]ToolStat, 0, len(tools))
@@ -276,15 +488,39 @@ type StatsSnapshot struct {
 	BlockedByReason     map[string]int64  `json:"blocked_by_reason"`
 	TopBlockedTools     []ToolStat        `json:"top_blocked_tools"`
 	TopAllowedTools     []ToolStat        `json:"top_allowed_tools"`
+	SchemaViolations    map[string]int64  `json:"schema_violations_by_backend"`
+	ContentTypes        map[string]int64  `json:"content_types"`
+	ContentFiltered     int64             `json:"content_filtered"`
+	SpendByTool         map[string]float64 `json:"spend_by_tool"`
+	QuietCallsTotal     int64             `json:"quiet_calls_total"` // allowed calls of quiet tools, not in the counts above
+	BySession           []OriginStats     `json:"by_session"` // busiest sessions, most blocked first
+	ByClient            []OriginStats     `json:"by_client"`  // per clientInfo.name, most blocked first
 	Uptime              float64           `json:"uptime_seconds"`
 }
 
+// OriginStats counts the allowed and blocked calls of one session or one
+// client application (clientInfo.name, e.g. claude-code or cursor).
+type OriginStats struct {
+	Name     string    `json:"name"`
+	Client   string    `json:"client,omitempty"`   // for a session, the client it belongs to
+	Sessions int       `json:"sessions,omitempty"` // for a client, the sessions counted
+	Allowed  int64     `json:"allowed"`
+	Blocked  int64     `json:"blocked"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
 // ToolStat represents a statistic for a single tool.
 type ToolStat struct {
 	Name  string `json:"name"`
 	Count int64  `json:"count"`
 }
 
+// ToolHits counts the calls of one tool by outcome.
+type ToolHits struct {
+	Allowed int64 `json:"allowed"`
+	Blocked int64 `json:"blocked"`
+}
+
 // WeeklyStats aggregates statistics over a week.
 type WeeklyStats struct {
 	Period          string
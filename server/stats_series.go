@@ -0,0 +1,322 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSeriesPoints caps the points of one time-series query.
+const maxSeriesPoints = 2000
+
+// seriesRollupInterval is how often old buckets are downsampled.
+const seriesRollupInterval = time.Hour
+
+// seriesTiers are the resolutions call counts are kept at, finest first.
+// Buckets older than a tier's retention are rolled up into the next tier;
+// the last tier's are deleted. This keeps the stats_buckets table bounded
+// at roughly 2880 + 2160 + 730 rows.
+var seriesTiers = []struct {
+	step time.Duration
+	keep time.Duration
+}{
+	{time.Minute, 48 * time.Hour},
+	{time.Hour, 90 * 24 * time.Hour},
+	{24 * time.Hour, 2 * 365 * 24 * time.Hour},
+}
+
+// seriesSteps are the steps picked when a query doesn't give one.
+var seriesSteps = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour,
+}
+
+// SeriesPoint is one step of a stats time series. Latencies are of the
+// allowed tool calls that reached a backend.
+type SeriesPoint struct {
+	Time         time.Time `json:"time"`
+	Blocked      int64     `json:"blocked"`
+	Allowed      int64     `json:"allowed"`
+	LatencyAvgMs float64   `json:"latency_avg_ms"`
+	LatencyMaxMs float64   `json:"latency_max_ms"`
+}
+
+// StatsSeries is blocked/allowed/latency series over a window, oldest point
+// first. Data older than two days is kept per hour and data older than 90
+// days per day, so steps finer than that show it in the step the hour or
+// day starts in.
+type StatsSeries struct {
+	Window string        `json:"window"`
+	Step   string        `json:"step"`
+	Points []SeriesPoint `json:"points"`
+}
+
+// seriesBucket holds the counts of one bucket.
+type seriesBucket struct {
+	blocked, allowed, latencyCount int64
+	latencySumMs, latencyMaxMs     float64
+}
+
+func (b *seriesBucket) add(o seriesBucket) {
+	b.blocked += o.blocked
+	b.allowed += o.allowed
+	b.latencyCount += o.latencyCount
+	b.latencySumMs += o.latencySumMs
+	b.latencyMaxMs = max(b.latencyMaxMs, o.latencyMaxMs)
+}
+
+// seriesStore keeps per-minute counts in memory and writes each minute to
+// the stats_buckets table once it's over. Without a database the minutes
+// of the first tier's retention stay in memory.
+type seriesStore struct {
+	mu         sync.Mutex
+	db         *sql.DB
+	pending    map[int64]*seriesBucket // minute start (unix) -> counts not yet written
+	lastRollup time.Time
+	now        func() time.Time
+}
+
+func newSeriesStore() *seriesStore {
+	return &seriesStore{pending: make(map[int64]*seriesBucket), now: time.Now}
+}
+
+// open creates the stats_buckets table and starts writing to db.
+func (ss *seriesStore) open(db *sql.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS stats_buckets (
+			resolution INTEGER NOT NULL,
+			bucket INTEGER NOT NULL,
+			blocked INTEGER NOT NULL DEFAULT 0,
+			allowed INTEGER NOT NULL DEFAULT 0,
+			latency_count INTEGER NOT NULL DEFAULT 0,
+			latency_sum_ms REAL NOT NULL DEFAULT 0,
+			latency_max_ms REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY (resolution, bucket)
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create stats_buckets table: %w", err)
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.db = db
+	return ss.flush(ss.minute())
+}
+
+// minute returns the start of the current minute. Callers hold mu.
+func (ss *seriesStore) minute() int64 {
+	return ss.now().Unix() / 60 * 60
+}
+
+// record adds counts to the current minute, writing out earlier minutes.
+func (ss *seriesStore) record(counts seriesBucket) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	minute := ss.minute()
+	bucket, ok := ss.pending[minute]
+	if !ok {
+		// A new minute: the ones before it are complete
+		ss.flush(minute)
+		bucket = &seriesBucket{}
+		ss.pending[minute] = bucket
+	}
+	bucket.add(counts)
+}
+
+// flush writes the pending minutes before a minute to the database and
+// downsamples old buckets if due. Without a database it drops minutes past
+// the first tier's retention instead. Minutes that failed to write are
+// kept for the next flush. Callers hold mu.
+func (ss *seriesStore) flush(before int64) error {
+	if ss.db == nil {
+		cutoff := ss.now().Add(-seriesTiers[0].keep).Unix()
+		for minute := range ss.pending {
+			if minute < cutoff {
+				delete(ss.pending, minute)
+			}
+		}
+		return nil
+	}
+	for minute, b := range ss.pending {
+		if minute >= before {
+			continue
+		}
+		if _, err := ss.db.Exec(`
+			INSERT INTO stats_buckets (resolution, bucket, blocked, allowed, latency_count, latency_sum_ms, latency_max_ms)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (resolution, bucket) DO UPDATE SET
+				blocked = blocked + excluded.blocked,
+				allowed = allowed + excluded.allowed,
+				latency_count = latency_count + excluded.latency_count,
+				latency_sum_ms = latency_sum_ms + excluded.latency_sum_ms,
+				latency_max_ms = MAX(latency_max_ms, excluded.latency_max_ms)
+		`, 60, minute, b.blocked, b.allowed, b.latencyCount, b.latencySumMs, b.latencyMaxMs); err != nil {
+			return fmt.Errorf("failed to write stats bucket: %w", err)
+		}
+		delete(ss.pending, minute)
+	}
+	if ss.now().Sub(ss.lastRollup) < seriesRollupInterval {
+		return nil
+	}
+	if err := ss.rollup(); err != nil {
+		return err
+	}
+	ss.lastRollup = ss.now()
+	return nil
+}
+
+// rollup sums the buckets past each tier's retention into the next tier
+// and deletes them. Callers hold mu.
+func (ss *seriesStore) rollup() error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to downsample stats: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := ss.now().Unix()
+	for i, tier := range seriesTiers {
+		resolution := int64(tier.step / time.Second)
+		cutoff := now - int64(tier.keep/time.Second)
+		if i < len(seriesTiers)-1 {
+			// Only whole buckets of the next tier are rolled up
+			next := int64(seriesTiers[i+1].step / time.Second)
+			cutoff = cutoff / next * next
+			if _, err := tx.Exec(`
+				INSERT INTO stats_buckets (resolution, bucket, blocked, allowed, latency_count, latency_sum_ms, latency_max_ms)
+				SELECT ?, bucket - bucket % ?, SUM(blocked), SUM(allowed), SUM(latency_count), SUM(latency_sum_ms), MAX(latency_max_ms)
+				FROM stats_buckets WHERE resolution = ? AND bucket < ?
+				GROUP BY bucket - bucket % ?
+				ON CONFLICT (resolution, bucket) DO UPDATE SET
+					blocked = blocked + excluded.blocked,
+					allowed = allowed + excluded.allowed,
+					latency_count = latency_count + excluded.latency_count,
+					latency_sum_ms = latency_sum_ms + excluded.latency_sum_ms,
+					latency_max_ms = MAX(latency_max_ms, excluded.latency_max_ms)
+			`, next, next, resolution, cutoff, next); err != nil {
+				return fmt.Errorf("failed to downsample stats: %w", err)
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM stats_buckets WHERE resolution = ? AND bucket < ?", resolution, cutoff); err != nil {
+			return fmt.Errorf("failed to downsample stats: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to downsample stats: %w", err)
+	}
+	return nil
+}
+
+// series returns the points of a window ending with the step that contains
+// the current time. A zero step picks one giving at most 120 points.
+func (ss *seriesStore) series(window, step time.Duration) (StatsSeries, error) {
+	if window <= 0 {
+		return StatsSeries{}, fmt.Errorf("window must be positive")
+	}
+	if step == 0 {
+		step = seriesSteps[len(seriesSteps)-1]
+		for _, s := range seriesSteps {
+			if window/s <= 120 {
+				step = s
+				break
+			}
+		}
+	}
+	if step < time.Minute || step%time.Minute != 0 {
+		return StatsSeries{}, fmt.Errorf("step must be a whole number of minutes")
+	}
+	n := int64((window + step - 1) / step)
+	if n > maxSeriesPoints {
+		return StatsSeries{}, fmt.Errorf("window %s at step %s is over %d points", window, step, maxSeriesPoints)
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	stepSec := int64(step / time.Second)
+	end := (ss.now().Unix()/stepSec + 1) * stepSec
+	start := end - n*stepSec
+	buckets := make([]seriesBucket, n)
+	add := func(at int64, b seriesBucket) {
+		if at >= start && at < end {
+			buckets[(at-start)/stepSec].add(b)
+		}
+	}
+
+	if ss.db != nil {
+		if err := ss.flush(ss.minute()); err != nil {
+			return StatsSeries{}, err
+		}
+		rows, err := ss.db.Query(`
+			SELECT bucket, blocked, allowed, latency_count, latency_sum_ms, latency_max_ms
+			FROM stats_buckets WHERE bucket >= ? AND bucket < ?
+		`, start, end)
+		if err != nil {
+			return StatsSeries{}, fmt.Errorf("failed to query stats: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var at int64
+			var b seriesBucket
+			if err := rows.Scan(&at, &b.blocked, &b.allowed, &b.latencyCount, &b.latencySumMs, &b.latencyMaxMs); err != nil {
+				return StatsSeries{}, fmt.Errorf("failed to scan stats bucket: %w", err)
+			}
+			add(at, b)
+		}
+		if err := rows.Err(); err != nil {
+			return StatsSeries{}, fmt.Errorf("failed to query stats: %w", err)
+		}
+	}
+	for minute, b := range ss.pending {
+		add(minute, *b)
+	}
+
+	result := StatsSeries{Window: window.String(), Step: step.String(), Points: make([]SeriesPoint, n)}
+	for i, b := range buckets {
+		point := SeriesPoint{
+			Time:         time.Unix(start+int64(i)*stepSec, 0).UTC(),
+			Blocked:      b.blocked,
+			Allowed:      b.allowed,
+			LatencyMaxMs: b.latencyMaxMs,
+		}
+		if b.latencyCount > 0 {
+			point.LatencyAvgMs = b.latencySumMs / float64(b.latencyCount)
+		}
+		result.Points[i] = point
+	}
+	return result, nil
+}
+
+// ParseSeriesDuration parses a window or step like 24h, 5m or 7d.
+func ParseSeriesDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// PersistSeries keeps the stats time series in db, so it survives restarts.
+func (st *StatsTracker) PersistSeries(db *sql.DB) error {
+	return st.series.open(db)
+}
+
+// RecordLatency records how long an allowed tool call took at its backend.
+func (st *StatsTracker) RecordLatency(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	st.series.record(seriesBucket{latencyCount: 1, latencySumMs: ms, latencyMaxMs: ms})
+}
+
+// Series returns blocked/allowed/latency series over the last window at
+// step; a zero step picks one.
+func (st *StatsTracker) Series(window, step time.Duration) (StatsSeries, error) {
+	return st.series.series(window, step)
+}
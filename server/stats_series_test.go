@@ -0,0 +1,140 @@
+package server
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestStatsSeries tests that calls and latencies land in the step they were
+// recorded in, and survive being written to the database
+func TestStatsSeries(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "stats.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+	st := NewStatsTracker()
+	st.series.now = func() time.Time { return now }
+	if err := st.PersistSeries(db); err != nil {
+		t.Fatalf("failed to persist series: %v", err)
+	}
+
+	st.RecordBlockedCall("github:delete_repo", "policy")
+	st.RecordAllowedCall("github:list_repos")
+	st.RecordLatency(100 * time.Millisecond)
+	now = now.Add(6 * time.Minute)
+	st.RecordAllowedCall("github:list_repos")
+	st.RecordLatency(300 * time.Millisecond)
+	st.RecordLatency(100 * time.Millisecond)
+
+	series, err := st.Series(time.Hour, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("failed to get series: %v", err)
+	}
+	if len(series.Points) != 12 || series.Step != "5m0s" {
+		t.Fatalf("expected 12 points at 5m, got %d at %s", len(series.Points), series.Step)
+	}
+	last := series.Points[11]
+	if !last.Time.Equal(time.Date(2026, 3, 1, 12, 5, 0, 0, time.UTC)) {
+		t.Errorf("expected the last point to contain now, got %s", last.Time)
+	}
+	if last.Allowed != 1 || last.Blocked != 0 || last.LatencyAvgMs != 200 || last.LatencyMaxMs != 300 {
+		t.Errorf("unexpected last point: %+v", last)
+	}
+	if p := series.Points[10]; p.Allowed != 1 || p.Blocked != 1 || p.LatencyAvgMs != 100 {
+		t.Errorf("unexpected first point with calls: %+v", p)
+	}
+
+	// The first minute was written when the next one started
+	var rows int
+	db.QueryRow("SELECT COUNT(*) FROM stats_buckets WHERE resolution = 60").Scan(&rows)
+	if rows != 1 {
+		t.Errorf("expected 1 minute bucket written, got %d", rows)
+	}
+
+	if _, err := st.Series(time.Hour, 0); err != nil {
+		t.Errorf("expected a step picked, got %v", err)
+	}
+	if _, err := st.Series(30*24*time.Hour, time.Minute); err == nil {
+		t.Error("expected a window with too many points rejected")
+	}
+	if _, err := st.Series(time.Hour, 30*time.Second); err == nil {
+		t.Error("expected a step under a minute rejected")
+	}
+}
+
+// TestStatsSeriesDownsampling tests that minutes older than two days are
+// rolled up into hours, and hours older than 90 days into days
+func TestStatsSeriesDownsampling(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "stats.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	ss := newSeriesStore()
+	ss.now = func() time.Time { return now }
+	if err := ss.open(db); err != nil {
+		t.Fatalf("failed to open series: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		ss.record(seriesBucket{blocked: 1, latencyCount: 1, latencySumMs: 10, latencyMaxMs: float64(10 * (i + 1))})
+		now = now.Add(time.Minute)
+	}
+
+	count := func(resolution int) int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM stats_buckets WHERE resolution = ?", resolution).Scan(&n)
+		return n
+	}
+
+	now = start.Add(50 * time.Hour)
+	ss.record(seriesBucket{allowed: 1})
+	if count(60) != 0 || count(3600) != 1 {
+		t.Fatalf("expected the minutes rolled into an hour, got %d minutes and %d hours", count(60), count(3600))
+	}
+	series, err := ss.series(3*24*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to get series: %v", err)
+	}
+	var hour SeriesPoint
+	for _, p := range series.Points {
+		if p.Time.Equal(start) {
+			hour = p
+		}
+	}
+	if hour.Blocked != 3 || hour.LatencyAvgMs != 10 || hour.LatencyMaxMs != 30 {
+		t.Errorf("expected the hour to sum its minutes, got %+v", hour)
+	}
+
+	now = start.Add(100 * 24 * time.Hour)
+	ss.record(seriesBucket{allowed: 1})
+	if count(3600) != 0 || count(86400) != 2 {
+		t.Errorf("expected the hours rolled into days, got %d hours and %d days", count(3600), count(86400))
+	}
+
+	now = start.Add(3 * 365 * 24 * time.Hour)
+	ss.record(seriesBucket{allowed: 1})
+	if count(86400) != 0 {
+		t.Errorf("expected days past retention deleted, got %d", count(86400))
+	}
+}
+
+func TestParseSeriesDuration(t *testing.T) {
+	for in, want := range map[string]time.Duration{"5m": 5 * time.Minute, "24h": 24 * time.Hour, "7d": 7 * 24 * time.Hour} {
+		if got, err := ParseSeriesDuration(in); err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s (err=%v)", in, want, got, err)
+		}
+	}
+	for _, in := range []string{"", "d", "-1d", "soon"} {
+		if _, err := ParseSeriesDuration(in); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
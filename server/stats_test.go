@@ -0,0 +1,55 @@
+package server
+
+import "testing"
+
+// TestToolHits tests that allowed and blocked calls are counted per tool
+func TestToolHits(t *testing.T) {
+	st := NewStatsTracker()
+	st.RecordAllowedCall("github:list_repos")
+	st.RecordAllowedCall("github:list_repos")
+	st.RecordBlockedCall("github:list_repos", "policy")
+	st.RecordBlockedCall("github:delete_repo", "destructive")
+
+	hits := st.ToolHits()
+	if h := hits["github:list_repos"]; h.Allowed != 2 || h.Blocked != 1 {
+		t.Errorf("unexpected hits for list_repos: %+v", h)
+	}
+	if h := hits["github:delete_repo"]; h.Allowed != 0 || h.Blocked != 1 {
+		t.Errorf("unexpected hits for delete_repo: %+v", h)
+	}
+	if len(hits) != 2 {
+		t.Errorf("expected 2 tools, got %d", len(hits))
+	}
+}
+
+// TestOriginStats tests that calls are counted per session and per client
+func TestOriginStats(t *testing.T) {
+	st := NewStatsTracker()
+	st.SetSessionClient("s1", "claude-code")
+	st.SetSessionClient("s2", "cursor")
+	st.SetSessionClient("s3", "cursor")
+	st.RecordSessionCall("s1", false)
+	st.RecordSessionCall("s2", true)
+	st.RecordSessionCall("s2", false)
+	st.RecordSessionCall("s3", true)
+	st.RecordSessionCall("s4", false) // never initialized
+	st.RecordSessionCall("", true)    // not counted
+
+	stats := st.GetStats()
+	if len(stats.BySession) != 4 || stats.BySession[0].Blocked != 1 || stats.BySession[0].Name != "s2" || stats.BySession[0].Client != "cursor" {
+		t.Errorf("expected sessions with the most blocked first, got %+v", stats.BySession)
+	}
+	want := map[string]OriginStats{
+		"cursor":      {Sessions: 2, Allowed: 1, Blocked: 2},
+		"claude-code": {Sessions: 1, Allowed: 1},
+		unknownClient: {Sessions: 1, Allowed: 1},
+	}
+	if len(stats.ByClient) != len(want) || stats.ByClient[0].Name != "cursor" {
+		t.Fatalf("expected cursor first of 3 clients, got %+v", stats.ByClient)
+	}
+	for _, c := range stats.ByClient {
+		if w := want[c.Name]; c.Sessions != w.Sessions || c.Allowed != w.Allowed || c.Blocked != w.Blocked {
+			t.Errorf("unexpected stats for %s: %+v", c.Name, c)
+		}
+	}
+}
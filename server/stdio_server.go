@@ -1,7 +1,7 @@
 package server
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -38,16 +39,124 @@ type StdioServer struct {
 	statsTracker   *StatsTracker
 
 	// Request/response handling
-	scanner *bufio.Scanner
-	encoder *json.Encoder
+	reader  *proxy.MessageReader
+	out     io.Writer
+	writeMu sync.Mutex // serializes stdout writes (responses and notifications)
 	mu      sync.RWMutex
 
+	pluginWatcher *PluginWatcher
+
+	// Trust tiers for discovered servers; approvals remembered for the session
+	trust          TrustPolicy
+	trustApprovals map[string]bool
+	elicitSeq      int
+
+	// Cost weights and budgets; over-budget approvals remembered for the session
+	costs           CostPolicy
+	costTracker     *CostTracker
+	budgetApprovals map[string]bool
+
+	// Decisions taken on blocked calls through their dashboard links
+	approvals *ApprovalStore
+
+	// The last request blocked in this session, for proxy:explain-block
+	lastBlock *BlockExplanation
+
+	// Panic button: blocks every tool call while engaged
+	killSwitch *KillSwitch
+
+	// Blocks network-bound tool calls while on
+	offline *OfflineSwitch
+
+	// Files tool calls were about to change, for restoring from the dashboard
+	undo       *UndoJournal
+	undoPolicy UndoPolicy
+
+	// Servers found by discovery, held back until approved
+	discovery *DiscoveryGate
+
+	// Package versions of npx/uvx servers, locked when servers.lock_packages is set
+	packages *PackageLocker
+
+	// Hosts URL arguments may point at
+	egress EgressPolicy
+
+	// Sensitive data classes scanned for in arguments to remote backends
+	dlp DLPPolicy
+
+	// Honeypot tools listed to agents; calling one is an incident
+	decoys DecoyPolicy
+
+	// Custom policy checks after the built-in ones; approvals of their
+	// "ask" verdicts remembered for the session, by checker and tool
+	policyCheckers  []PolicyChecker
+	pluginApprovals map[string]bool
+
+	// Overrides of the guessed tool classes; strict mode hides all but read
+	toolClasses ToolClasses
+
+	// Cedar policies tool calls are authorized against; nil allows all
+	cedar *cedarPolicySet
+
+	// External service deciding the calls "delegate" rules match
+	authorizer AuthorizerPolicy
+
+	// Which tool calls keep their arguments in the audit log
+	audit AuditPolicy
+
+	// Changes made to tool call arguments before they are checked and forwarded
+	rewrites RewritePolicy
+
+	// Policy reminders appended to the descriptions of risky tools
+	guardPrompts GuardPromptPolicy
+
+	// Tools whose calls held for approval get a preview of their changes
+	previews PreviewPolicy
+
+	// Session ID of this process in the audit log
+	auditSession string
+
+	// Git repository the proxy was started in, for policies and the audit log
+	repo *repoTracker
+
+	// Validate tools/call arguments against the tool's inputSchema
+	validateArgs bool
+
+	maxMessageSize int
+
+	// Requests run concurrently on a bounded pool; responses to our own
+	// requests upstream are routed back by id
+	queue           *WorkQueue
+	workers         sync.WaitGroup
+	reading         bool
+	pendingUpstream map[string]chan interface{}
+	inflight        map[string]context.CancelFunc // client requests being handled, by idKey, guarded by mu
+	framing         proxy.Framing                 // framing of the client's last message, guarded by writeMu
+
 	// Lifecycle
 	initialized bool
+	version     string // protocol version negotiated with the client
 	clientInfo  *proxy.ClientInfo
 	serverCaps  *proxy.Capabilities
 	clientCaps  *proxy.Capabilities
 	trace       *proxy.TraceRecorder
+	traceStore  *TraceStore // nil unless trace events are persisted
+	recorder    *proxy.SessionRecorder
+
+	// Saved so a restarted proxy can resume the session (see resumeSession)
+	sessionKey    string
+	subscriptions map[string]bool // armour:// URIs the client subscribed to, guarded by mu
+	resumeTried   bool
+	sessionLost   bool // nothing to resume, so requests are told to re-initialize
+}
+
+// stdioSessionID is the work queue session of the single stdio client.
+const stdioSessionID = "stdio"
+
+// newStdioAuditSession names the session of one stdio proxy process in the
+// audit log, so the sessions of different runs can be told apart.
+func newStdioAuditSession() string {
+	return "stdio-" + generateSessionID()[:16]
 }
 
 // NewStdioServer creates a new stdio-based MCP proxy server.
@@ -70,31 +179,95 @@ func NewStdioServer(config Config, registry *proxy.ServerRegistry, statsTracker
 		securityMgr.AddAllowedOrigin(origin)
 	}
 
+	if config.DemoServer && registry != nil {
+		AddDemoServer(registry)
+	}
+
 	// Create tool registry (shared with backend manager)
 	toolRegistry := NewToolRegistry()
 
+	if tracer == nil {
+		tracer = proxy.NewTraceRecorder(200)
+	}
+
 	// Create backend manager (will use the shared tool registry)
 	backendManager := NewBackendManager(registry, logger, toolRegistry, tracer)
+	if config.Chaos {
+		backendManager.EnableChaos()
+	}
 
 	// Create blocklist middleware
-	if tracer == nil {
-		tracer = proxy.NewTraceRecorder(200)
+	blocklist := newProxyBlocklist(db, apiKey, statsTracker, logger, tracer, registry)
+
+	costTracker, err := NewCostTracker(db)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
-	blocklist := NewBlocklistMiddleware(db, apiKey, statsTracker, logger, tracer)
 
-	// Configure rules server URL if available (for instant rule updates)
-	if rulesURL := os.Getenv("ARMOUR_RULES_URL"); rulesURL != "" {
-		blocklist.SetRulesServerURL(rulesURL)
-		logger.Info("using rules server at %s for instant policy enforcement", rulesURL)
-	} else {
-		// Default to localhost:8084 if server is running
-		defaultRulesURL := "http://127.0.0.1:8084"
-		if CheckRulesServer(8084) {
-			blocklist.SetRulesServerURL(defaultRulesURL)
-			logger.Info("detected rules server at %s", defaultRulesURL)
+	killSwitch, err := NewKillSwitch(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	offline, err := NewOfflineSwitch(db)
+	if err == nil {
+		err = offline.ApplyEnv()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	undo, err := NewUndoJournal(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	discovery, err := NewDiscoveryGate(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	provenance, err := NewProvenanceStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	packages, err := NewPackageLocker(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := initSessionStateTable(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	checkers, err := newPolicyCheckers(config)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if statsTracker != nil {
+		if err := statsTracker.PersistSeries(db); err != nil {
+			db.Close()
+			return nil, err
 		}
 	}
 
+	traceStore, err := configureTracing(config, db, tracer, logger)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	s := &StdioServer{
 		config:         config,
 		db:             db,
@@ -111,11 +284,58 @@ func NewStdioServer(config Config, registry *proxy.ServerRegistry, statsTracker
 		backendManager: backendManager,
 		toolRegistry:   toolRegistry,
 		statsTracker:   statsTracker,
-		scanner:        bufio.NewScanner(os.Stdin),
-		encoder:        json.NewEncoder(os.Stdout),
+		reader:         proxy.NewMessageReader(os.Stdin, config.maxMessageSize()),
+		out:            os.Stdout,
 		initialized:    false,
 		trace:          tracer,
-	}
+		traceStore:     traceStore,
+		trust:          DefaultTrustPolicy(),
+		trustApprovals: make(map[string]bool),
+		validateArgs:   argumentValidationEnabled(),
+		maxMessageSize: config.maxMessageSize(),
+
+		queue:           NewWorkQueue(config.Workers, config.SessionConcurrency, config.QueueSize),
+		pendingUpstream: make(map[string]chan interface{}),
+		inflight:        make(map[string]context.CancelFunc),
+
+		costTracker:     costTracker,
+		budgetApprovals: make(map[string]bool),
+
+		policyCheckers:  checkers,
+		pluginApprovals: make(map[string]bool),
+
+		approvals:    NewApprovalStore(),
+		killSwitch:   killSwitch,
+		offline:      offline,
+		undo:         undo,
+		discovery:    discovery,
+		packages:     packages,
+		auditSession: newStdioAuditSession(),
+		repo:         newRepoTracker(workingDir()),
+
+		sessionKey:    stdioSessionKey(),
+		subscriptions: make(map[string]bool),
+	}
+	backendManager.SetNotificationHandler(s.relayNotification)
+	backendManager.SetProvenanceStore(provenance)
+	s.pluginWatcher = NewPluginWatcher(backendManager, logger, func(added, removed []string) {
+		if err := s.sendNotification("notifications/tools/list_changed", nil); err != nil {
+			logger.Warn("failed to announce tools/list_changed: %v", err)
+		}
+	})
+	discovery.OnApprove(func(srv DiscoveredServer) {
+		admitted, err := backendManager.AdmitPendingServer(srv.Name)
+		if err != nil {
+			logger.Warn("%v", err)
+		}
+		if !admitted {
+			return
+		}
+		logger.Info("discovered server %s approved", srv.Name)
+		if err := s.sendNotification("notifications/tools/list_changed", nil); err != nil {
+			logger.Warn("failed to announce tools/list_changed: %v", err)
+		}
+	})
 
 	return s, nil
 }
@@ -135,6 +355,199 @@ func (s *StdioServer) GetDB() *sql.DB {
 	return s.db
 }
 
+// SetTrustPolicy sets the per-tier defaults applied to tool calls
+func (s *StdioServer) SetTrustPolicy(tp TrustPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trust = tp
+}
+
+// SetRecorder records the session's client and backend traffic.
+func (s *StdioServer) SetRecorder(recorder *proxy.SessionRecorder) {
+	s.recorder = recorder
+	s.backendManager.SetRecorder(recorder)
+}
+
+// SetCostPolicy sets the tool cost weights and spend budgets
+func (s *StdioServer) SetCostPolicy(cp CostPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.costs = cp
+}
+
+// SetEgressPolicy sets the hosts URL arguments may point at
+func (s *StdioServer) SetEgressPolicy(ep EgressPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.egress = ep
+}
+
+// SetDLPPolicy sets the actions for sensitive data sent to remote backends
+func (s *StdioServer) SetDLPPolicy(dp DLPPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dlp = dp
+}
+
+// SetDecoyPolicy sets the honeypot tools injected into tools/list
+func (s *StdioServer) SetDecoyPolicy(dp DecoyPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.decoys = dp
+}
+
+// SetToolClasses sets the tool class overrides used to hide tools in strict mode
+func (s *StdioServer) SetToolClasses(tc ToolClasses) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.toolClasses = tc
+}
+
+// SetCedarPolicy sets the Cedar policies tool calls are authorized against
+func (s *StdioServer) SetCedarPolicy(cp CedarPolicy) {
+	compiled := cp.compile()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cedar = compiled
+}
+
+// SetAuthorizerPolicy sets the service "delegate" rules hand decisions to
+func (s *StdioServer) SetAuthorizerPolicy(ap AuthorizerPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizer = ap
+}
+
+// SetAuditPolicy sets which tool calls keep their arguments in the audit log
+// and which tools are quiet
+func (s *StdioServer) SetAuditPolicy(ap AuditPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = ap
+	s.statsTracker.SetQuietTools(ap.IsQuiet)
+}
+
+// SetRewritePolicy sets the changes made to tool call arguments
+func (s *StdioServer) SetRewritePolicy(rp RewritePolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rewrites = rp
+}
+
+// SetGuardPrompts sets the policy reminders added to tool descriptions
+func (s *StdioServer) SetGuardPrompts(gp GuardPromptPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guardPrompts = gp
+}
+
+// SetPreviewPolicy sets the tools whose changes are previewed for approval
+func (s *StdioServer) SetPreviewPolicy(pp PreviewPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.previews = pp
+}
+
+// SetUndoPolicy sets the tools whose calls snapshot files for undo
+func (s *StdioServer) SetUndoPolicy(up UndoPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.undoPolicy = up
+}
+
+// SetStoredPolicy sets the sections of an applied policy file that take
+// effect without a restart
+func (s *StdioServer) SetStoredPolicy(sp StoredPolicy) {
+	s.SetTrustPolicy(sp.Trust)
+	s.SetCostPolicy(sp.Costs)
+	s.SetEgressPolicy(sp.Egress)
+	s.SetDLPPolicy(sp.DLP)
+	s.SetDecoyPolicy(sp.Decoys)
+	s.SetToolClasses(sp.Classes)
+	s.SetCedarPolicy(sp.Cedar)
+	s.SetAuthorizerPolicy(sp.Authorizer)
+	s.SetAuditPolicy(sp.Audit)
+	s.SetRewritePolicy(sp.Rewrites)
+	s.SetGuardPrompts(sp.GuardPrompts)
+	s.SetPreviewPolicy(sp.Previews)
+	s.SetUndoPolicy(sp.Undo)
+	s.SetApproveDiscovered(sp.ApproveDiscovered)
+	s.SetBinaryPins(sp.BinaryPins)
+	s.SetLockPackages(sp.LockPackages)
+}
+
+// SetLockPackages locks servers launched through npx or uvx to the package
+// version they first ran
+func (s *StdioServer) SetLockPackages(on bool) {
+	if on {
+		s.backendManager.SetPackageLocker(s.packages)
+	} else {
+		s.backendManager.SetPackageLocker(nil)
+	}
+}
+
+// SetBinaryPins sets the binary hashes stdio servers must match to start
+func (s *StdioServer) SetBinaryPins(pins BinaryPins) {
+	s.backendManager.SetBinaryPins(pins)
+}
+
+// SetApproveDiscovered holds servers found in ~/.claude.json or plugins back
+// until they are approved on the dashboard
+func (s *StdioServer) SetApproveDiscovered(on bool) {
+	if on {
+		s.backendManager.SetDiscoveryGate(s.discovery)
+	} else {
+		s.backendManager.SetDiscoveryGate(nil)
+	}
+}
+
+// hiddenTool reports whether the policy mode keeps a tool out of sight:
+// strict mode hides tools that write, execute or delete.
+func (s *StdioServer) hiddenTool(tool RegisteredTool) bool {
+	if s.policyManager == nil || s.policyManager.GetMode() != StrictMode {
+		return false
+	}
+	s.mu.RLock()
+	classes := s.toolClasses
+	s.mu.RUnlock()
+	return classes.HiddenInStrictMode(tool)
+}
+
+// GetBackendManager returns the backend manager
+func (s *StdioServer) GetBackendManager() *BackendManager {
+	return s.backendManager
+}
+
+// GetWorkQueue returns the queue bounding concurrent requests
+func (s *StdioServer) GetWorkQueue() *WorkQueue {
+	return s.queue
+}
+
+// GetApprovals returns the store behind the approval links in block errors
+func (s *StdioServer) GetApprovals() *ApprovalStore {
+	return s.approvals
+}
+
+// GetKillSwitch returns the switch that blocks every tool call while engaged
+func (s *StdioServer) GetKillSwitch() *KillSwitch {
+	return s.killSwitch
+}
+
+// GetOfflineSwitch returns the switch that blocks network-bound tool calls while on
+func (s *StdioServer) GetOfflineSwitch() *OfflineSwitch {
+	return s.offline
+}
+
+// GetUndoJournal returns the snapshots of files changed by tool calls
+func (s *StdioServer) GetUndoJournal() *UndoJournal {
+	return s.undo
+}
+
+// GetDiscoveryGate returns the servers found by discovery and their approval
+func (s *StdioServer) GetDiscoveryGate() *DiscoveryGate {
+	return s.discovery
+}
+
 // GetToolRegistry returns the tool registry for accessing aggregated tools
 func (s *StdioServer) GetToolRegistry() *ToolRegistry {
 	return s.toolRegistry
@@ -142,6 +555,10 @@ func (s *StdioServer) GetToolRegistry() *ToolRegistry {
 
 // Close closes the server resources including the database
 func (s *StdioServer) Close() error {
+	closePolicyCheckers(s.policyCheckers)
+	if s.traceStore != nil {
+		s.traceStore.Close()
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -154,54 +571,221 @@ func (s *StdioServer) Run(ctx context.Context) error {
 	s.logger.Info("stdio server started")
 	defer s.logger.Info("stdio server stopped")
 
-	// Configure scanner for large inputs
-	s.scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	if org := s.blocklist.GetOrgPolicy(); org != nil {
+		org.Start(ctx)
+	}
+	if s.config.AuditAnchorPath != "" && s.db != nil {
+		defer startAuditAnchors(ctx, s.db, s.config, s.logger)()
+	}
+	if s.config.Retention > 0 {
+		defer startRetention(ctx, s.db, s.trace, s.config, s.logger)()
+	}
+
+	// From here on only this loop reads stdin; forwardUpstream gets its
+	// answers through pendingUpstream.
+	s.mu.Lock()
+	s.reading = true
+	s.mu.Unlock()
+	defer s.workers.Wait()
 
-	for s.scanner.Scan() {
+	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
-		line := s.scanner.Bytes()
-		if len(line) == 0 {
+		msg, err := s.reader.ReadMessage()
+		if err == io.EOF {
+			// The client closed the session; nothing is left to resume
+			s.clearSession()
+			return nil
+		}
+		// The response to this message is framed like it, whatever the
+		// client sends while it is handled; messages we start use the
+		// framing of the client's last message
+		framing := s.reader.Framing()
+		s.writeMu.Lock()
+		s.framing = framing
+		s.writeMu.Unlock()
+		if err == proxy.ErrMessageTooLarge {
+			// The oversized message was skipped; keep the session alive.
+			s.logger.Error("rejecting message over %d bytes", s.maxMessageSize)
+			if err := s.writeMessage(requestTooLarge(nil, s.maxMessageSize)); err != nil {
+				return err
+			}
 			continue
 		}
-
-		// Parse JSON-RPC request
-		var request JSONRPCRequest
-		if err := json.Unmarshal(line, &request); err != nil {
-			s.logger.Error("failed to parse JSON-RPC request: %v", err)
-			s.sendError(request.ID, -32700, "Parse error")
+		if err != nil {
+			return fmt.Errorf("read error: %w", err)
+		}
+		s.recorder.Record(proxy.DirClientToProxy, "", msg)
+
+		// A Content-Length frame can be empty or hold only whitespace
+		msg = bytes.TrimSpace(msg)
+		if len(msg) == 0 {
+			s.logger.Error("rejecting empty message")
+			if err := s.writeResponse(s.makeError(nil, -32600, "Invalid Request", "empty message"), framing); err != nil {
+				return err
+			}
 			continue
 		}
 
-		// Route to appropriate handler
-		response := s.handleRequest(ctx, request)
-		if response == nil {
-			continue
+		var envelope struct {
+			ID     interface{} `json:"id"`
+			Method string      `json:"method"`
 		}
-		if err := s.encoder.Encode(response); err != nil {
-			s.logger.Error("failed to encode response: %v", err)
-			return err
+		if msg[0] != '[' {
+			if err := json.Unmarshal(msg, &envelope); err != nil {
+				s.logger.Error("failed to parse JSON-RPC request: %v", err)
+				s.sendError(nil, -32700, "Parse error")
+				continue
+			}
+			// A response to one of our own requests (e.g. elicitation)
+			if envelope.Method == "" && envelope.ID != nil {
+				if !s.deliverUpstream(envelope.ID, msg) {
+					s.logger.Warn("dropping response to unknown request %v", envelope.ID)
+				}
+				continue
+			}
+			// initialize sets up session state every later request depends
+			// on, so it is handled before anything else is read
+			if envelope.Method == "initialize" {
+				s.resumeTried = true
+				if response := s.handleMessage(ctx, msg); response != nil {
+					if err := s.writeResponse(response, framing); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
+
+		// Anything else first means the proxy restarted mid-session
+		if !s.initialized && !s.resumeTried {
+			s.resumeTried = true
+			if !s.resumeSession(ctx) {
+				s.sessionLost = true
+			}
+		}
+
+		reqCtx, done := ctx, func() {}
+		if envelope.ID != nil && envelope.Method != "" {
+			reqCtx, done = s.trackRequest(ctx, envelope.ID)
 		}
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			defer done()
+			release, err := s.queue.Acquire(reqCtx, stdioSessionID)
+			if err != nil {
+				if err == ErrQueueFull {
+					s.logger.Warn("request queue full, rejecting %s", envelope.Method)
+					// Notifications get no response, not even an error
+					if envelope.ID != nil {
+						s.writeResponse(s.makeError(envelope.ID, -32000, "Server busy", "too many requests in flight, retry later"), framing)
+					}
+				}
+				return
+			}
+			defer release()
+
+			response := s.handleMessage(reqCtx, msg)
+			// A request the client cancelled gets no response
+			if reqCtx.Err() != nil && ctx.Err() == nil {
+				s.logger.Debug("request %v was cancelled by the client", envelope.ID)
+				return
+			}
+			if response != nil {
+				if err := s.writeResponse(response, framing); err != nil {
+					s.logger.Error("failed to encode response: %v", err)
+				}
+			}
+		}()
 	}
+}
 
-	if err := s.scanner.Err(); err != nil && err != io.EOF {
-		return fmt.Errorf("scanner error: %w", err)
+// handleMessage parses and handles a single request or a batch, returning
+// the response to write, if any.
+func (s *StdioServer) handleMessage(ctx context.Context, msg []byte) interface{} {
+	msg = bytes.TrimSpace(msg)
+	if len(msg) == 0 {
+		return s.makeError(nil, -32600, "Invalid Request", "empty message")
+	}
+	if msg[0] == '[' {
+		return s.handleBatch(ctx, msg)
 	}
 
-	return nil
+	// Parse JSON-RPC request
+	var request JSONRPCRequest
+	if err := json.Unmarshal(msg, &request); err != nil {
+		s.logger.Error("failed to parse JSON-RPC request: %v", err)
+		return s.makeError(request.ID, -32700, "Parse error", nil)
+	}
+
+	// Route to appropriate handler
+	response := s.handleRequest(ctx, request)
+	// Notifications never get a response, not even an error
+	if request.ID == nil {
+		return nil
+	}
+	return response
+}
+
+// handleBatch handles a JSON-RPC batch, returning the array of responses or
+// nil when the batch held only notifications.
+func (s *StdioServer) handleBatch(ctx context.Context, msg []byte) interface{} {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil {
+		s.logger.Error("failed to parse JSON-RPC batch: %v", err)
+		return s.makeError(nil, -32700, "Parse error", nil)
+	}
+	if len(batch) == 0 {
+		return s.makeError(nil, -32600, "Invalid Request", "empty batch")
+	}
+
+	var responses []interface{}
+	for _, item := range batch {
+		var request JSONRPCRequest
+		if err := json.Unmarshal(item, &request); err != nil || request.Method == "" {
+			responses = append(responses, s.makeError(nil, -32600, "Invalid Request", nil))
+			continue
+		}
+		response := s.handleRequest(ctx, request)
+		// Notifications in a batch never get a response
+		if response != nil && request.ID != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
 }
 
-// handleRequest routes a JSON-RPC request to the appropriate handler.
+// handleRequest handles a JSON-RPC request under a fresh request ID, which
+// follows it into the logs, audit log and traces and is added to any error
+// returned to the client.
 func (s *StdioServer) handleRequest(ctx context.Context, request JSONRPCRequest) interface{} {
+	ctx = ensureRequestID(ctx)
+	response := s.dispatch(ctx, request)
+	if resp, ok := response.(JSONRPCResponse); ok && resp.Error != nil {
+		resp.Error.Data = withRequestIDData(resp.Error.Data, RequestIDFrom(ctx))
+	}
+	return response
+}
+
+// dispatch routes a JSON-RPC request to the appropriate handler.
+func (s *StdioServer) dispatch(ctx context.Context, request JSONRPCRequest) interface{} {
 	switch request.Method {
 	case "initialize":
 		return s.handleInitialize(ctx, request)
+	case "ping":
+		return s.makeResult(request.ID, map[string]interface{}{})
 	case "notifications/initialized":
 		return s.handleInitialized(ctx, request)
+	case "notifications/cancelled":
+		return s.handleCancelled(request)
 	case "tools/list":
 		return s.handleToolsList(ctx, request)
 	case "tools/call":
@@ -245,42 +829,74 @@ func (s *StdioServer) handleInitialize(ctx context.Context, request JSONRPCReque
 		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
 	}
 
-	// Validate protocol version
-	if err := proxy.ValidateProtocolVersion(params.ProtocolVersion, proxy.MCPProtocolVersion); err != nil {
-		return s.makeError(request.ID, -32602, "Protocol version mismatch", err.Error())
+	if params.ProtocolVersion == "" {
+		return s.makeError(request.ID, -32602, "Invalid params", "protocolVersion is required")
 	}
+	// Answer with the client's version if we speak it, otherwise our newest;
+	// the client disconnects if it can't use what we offer.
+	protocolVersion := proxy.NegotiateProtocolVersion(params.ProtocolVersion)
 
+	s.mu.Lock()
+	s.version = protocolVersion
 	s.clientInfo = &params.ClientInfo
 	s.clientCaps = &params.Capabilities
+	s.mu.Unlock()
+	s.statsTracker.SetSessionClient(s.auditSession, params.ClientInfo.Name)
 
-	// Initialize all backends (non-blocking - do in background)
-	go func() {
-		if err := s.backendManager.Initialize(ctx); err != nil {
-			s.logger.Error("failed to initialize backends: %v", err)
-		}
-	}()
+	// Initialize all backends (non-blocking - do in background); the tools
+	// they bring are saved with the session
+	started := s.startBackends(ctx, s.saveSession)
 
-	// Aggregate capabilities from all backends
-	s.serverCaps = s.aggregateCapabilities()
-
-	// For compatibility, advertise server capabilities (do not AND with client caps)
-	finalCaps := *s.serverCaps
+	// Capabilities can't change after initialize, so give backends a moment
+	// to answer theirs before aggregating
+	select {
+	case <-started:
+	case <-time.After(capabilityWait):
+	case <-ctx.Done():
+	}
+	caps := s.aggregateCapabilities()
+	s.mu.Lock()
+	s.serverCaps = caps
+	s.mu.Unlock()
 
 	s.initialized = true
+	s.saveSession()
 
 	// Build response
 	result := map[string]interface{}{
 		"serverInfo": map[string]string{
 			"name":    "mcp-go-proxy",
-			"version": "1.0.16",
+			"version": proxy.Version,
 		},
-		"capabilities":    finalCaps,
-		"protocolVersion": proxy.MCPProtocolVersion,
+		"capabilities":    caps,
+		"protocolVersion": protocolVersion,
 	}
 
 	return s.makeResult(request.ID, result)
 }
 
+// startBackends initializes the backends in the background and calls ready
+// once they are up. The returned channel is closed after ready returns.
+func (s *StdioServer) startBackends(ctx context.Context, ready func()) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.backendManager.Initialize(ctx); err != nil {
+			s.logger.Error("failed to initialize backends: %v", err)
+		}
+		// Pick up plugins installed or removed mid-session
+		s.pluginWatcher.Start(ctx)
+		s.backendManager.StartKeepalive(ctx, s.config.pingInterval())
+		ready()
+	}()
+	return done
+}
+
+// versionAdapter translates results from backendID for the client's protocol version.
+func (s *StdioServer) versionAdapter(backendID string) proxy.VersionAdapter {
+	return proxy.NewVersionAdapter(s.version, s.backendManager.ProtocolVersion(backendID))
+}
+
 // handleInitialized handles the initialized notification.
 func (s *StdioServer) handleInitialized(ctx context.Context, request JSONRPCRequest) interface{} {
 	s.logger.Debug("client initialized")
@@ -291,7 +907,7 @@ func (s *StdioServer) handleInitialized(ctx context.Context, request JSONRPCRequ
 // handleToolsList aggregates and returns all available tools from all backends.
 func (s *StdioServer) handleToolsList(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	// Check blocklist for tools/list permission
@@ -301,8 +917,11 @@ func (s *StdioServer) handleToolsList(ctx context.Context, request JSONRPCReques
 			s.logger.Error("blocklist check failed: %v", err)
 		}
 		if !result.Allowed {
-			s.statsTracker.RecordBlockedCall("tools/list", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
-			return s.makeError(request.ID, -32001, "Operation denied", result.Error.Message)
+			block := ruleBlock(result, "", DashboardURL)
+			if !s.approvals.Granted(block, nil) {
+				s.statsTracker.RecordBlockedCall("tools/list", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
+				return s.makeDenied(request.ID, block)
+			}
 		}
 	}
 
@@ -310,7 +929,13 @@ func (s *StdioServer) handleToolsList(ctx context.Context, request JSONRPCReques
 	// This ensures all backends have a chance to register their tools
 	s.backendManager.WaitForInitialization(ctx, 5*time.Second)
 
-	tools := s.toolRegistry.ListAllTools()
+	tools := slices.DeleteFunc(s.toolRegistry.ListAllTools(), s.hiddenTool)
+	if !proxy.SupportsStructuredOutput(s.version) {
+		for i := range tools {
+			tools[i].OutputSchema = nil
+		}
+	}
+	s.guardToolDescriptions(tools)
 
 	// Add built-in proxy tools (available even with no backends)
 	builtInTools := []RegisteredTool{
@@ -330,6 +955,22 @@ func (s *StdioServer) handleToolsList(ctx context.Context, request JSONRPCReques
 				"properties": map[string]interface{}{},
 			},
 		},
+		{
+			Name:        "proxy:health",
+			Description: "Check the health of the proxy and its backend servers, to tell a broken server from a failing call",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "proxy:explain-block",
+			Description: "Explain why the proxy blocked the last call (rule, pattern and what to do instead) rather than retrying it",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
 		{
 			Name:        "proxy:open-dashboard",
 			Description: "Open the Sentinel Proxy management dashboard in your browser",
@@ -358,92 +999,789 @@ func (s *StdioServer) handleToolsList(ctx context.Context, request JSONRPCReques
 	// Combine built-in tools with backend tools
 	allTools := append(builtInTools, tools...)
 
-	result := map[string]interface{}{
-		"tools": allTools,
+	// Decoys go last; a backend tool of the same name wins
+	s.mu.RLock()
+	decoys := s.decoys.Decoys()
+	s.mu.RUnlock()
+	for _, decoy := range decoyListing(decoys) {
+		if _, err := s.toolRegistry.GetTool(decoy.Name); err != nil {
+			allTools = append(allTools, decoy)
+		}
+	}
+
+	result := map[string]interface{}{
+		"tools": allTools,
+	}
+
+	return s.makeResult(request.ID, result)
+}
+
+// handleToolsCall routes a tool call to the appropriate backend and returns the result.
+func (s *StdioServer) handleToolsCall(ctx context.Context, request JSONRPCRequest) interface{} {
+	if !s.initialized {
+		return s.notInitialized(request.ID)
+	}
+	logger := loggerFor(ctx, s.logger)
+
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
+	}
+
+	// Handle built-in proxy tools
+	switch params.Name {
+	case "proxy:detect-servers":
+		return s.handleProxyDetectServers(request.ID)
+	case "proxy:server-status":
+		return s.handleProxyServerStatus(request.ID)
+	case "proxy:health":
+		return s.handleProxyHealth(ctx, request.ID)
+	case "proxy:explain-block":
+		return s.handleProxyExplainBlock(request.ID)
+	case "proxy:open-dashboard":
+		return s.handleProxyOpenDashboard(request.ID)
+	case "proxy:migrate-config":
+		return s.handleProxyMigrateConfig(request.ID, params.Arguments)
+	}
+
+	if s.isDecoy(params.Name) {
+		return s.makeDenied(request.ID, s.tripDecoy(ctx, params.Name))
+	}
+
+	if block := s.killSwitch.Check("tools/call", params.Name); block != nil {
+		s.statsTracker.RecordBlockedCall(params.Name, "killswitch")
+		s.recordKillSwitchAudit(ctx, "tools/call", params.Name)
+		return s.makeDenied(request.ID, block)
+	}
+
+	// Parse arguments for blocklist checking
+	var argsMap map[string]interface{}
+	if err := json.Unmarshal(params.Arguments, &argsMap); err != nil {
+		// If we can't parse, use empty map for blocklist check
+		argsMap = make(map[string]interface{})
+	}
+
+	// Check blocklist for tools/call permission
+	if s.blocklist != nil {
+		result, err := s.blocklist.Check("tools/call", params.Name, argsMap)
+		if err != nil {
+			logger.Error("blocklist check failed: %v", err)
+		}
+		result = s.delegate(ctx, params.Name, argsMap, result)
+		if !result.Allowed {
+			block := ruleBlock(result, params.Name, DashboardURL)
+			if !s.approvals.Granted(block, argsMap) {
+				s.statsTracker.RecordBlockedCall(params.Name, fmt.Sprintf("blocklist:%s", result.DeniedOperation))
+				s.recordToolCallAudit(ctx, params.Name, argsMap, result)
+				return s.makeDeniedCall(request.ID, block, argsMap)
+			}
+		}
+	}
+
+	// Get the backend that owns this tool
+	backendID, err := s.toolRegistry.GetToolBackend(params.Name)
+	if err != nil {
+		logger.Warn("tool not found: %s", params.Name)
+		return s.makeError(request.ID, -32602, "Tool not found", params.Name)
+	}
+
+	// Get the original tool name (without the backend namespace prefix)
+	tool, err := s.toolRegistry.GetTool(params.Name)
+	if err != nil {
+		logger.Warn("tool metadata not found: %s", params.Name)
+		return s.makeError(request.ID, -32602, "Tool not found", params.Name)
+	}
+
+	// Tools hidden from tools/list don't exist as far as the agent knows
+	if s.hiddenTool(*tool) {
+		logger.Info("tool %s is hidden in strict mode", params.Name)
+		s.statsTracker.RecordBlockedCall(params.Name, "strict_hidden")
+		return s.makeError(request.ID, -32602, "Tool not found", params.Name)
+	}
+
+	// Rewrite the arguments the policy wants changed, e.g. forcing a dry run
+	if rewritten, changes := s.rewriteArgs(params.Name, argsMap); len(changes) > 0 {
+		logger.Info("rewrote %s arguments: %s", params.Name, strings.Join(changes, "; "))
+		if data, err := json.Marshal(rewritten); err == nil {
+			argsMap, params.Arguments = rewritten, data
+			ctx = withRewrites(ctx, changes)
+		}
+	}
+
+	// Reject malformed calls before they reach the user or the backend
+	if s.validateArgs {
+		if fieldErrs := ValidateToolArguments(tool.InputSchema, params.Arguments); len(fieldErrs) > 0 {
+			s.recordInvalidArgumentsAudit(ctx, params.Name, argsMap, fieldErrs)
+			return s.makeError(request.ID, -32602, "Invalid params", map[string]interface{}{
+				"tool":   params.Name,
+				"errors": fieldErrs,
+			})
+		}
+	}
+
+	// Keep file arguments inside the backend's allowed roots
+	if violations := CheckPaths(s.backendManager.AllowedRoots(backendID), s.backendManager.WorkingDir(backendID), argsMap); len(violations) > 0 {
+		block := sandboxBlock(params.Name, violations)
+		if !s.approvals.Granted(block, argsMap) {
+			s.statsTracker.RecordBlockedCall(params.Name, "sandbox")
+			s.recordSandboxAudit(ctx, params.Name, argsMap, block.Reason)
+			return s.makeDeniedCall(request.ID, block, argsMap)
+		}
+	}
+
+	// Keep URL arguments away from metadata endpoints and forbidden hosts
+	s.mu.RLock()
+	egress := s.egress
+	s.mu.RUnlock()
+	if violations := CheckEgress(egress, argsMap); len(violations) > 0 {
+		block := egressBlock(params.Name, violations)
+		if !s.approvals.Granted(block, argsMap) {
+			s.statsTracker.RecordBlockedCall(params.Name, "egress")
+			s.recordEgressAudit(ctx, params.Name, argsMap, block.Reason)
+			return s.makeDeniedCall(request.ID, block, argsMap)
+		}
+	}
+
+	// Keep calls off the network in offline mode
+	if block := s.checkOffline(ctx, params.Name, backendID, argsMap); block != nil {
+		return s.makeDenied(request.ID, block)
+	}
+
+	// Keep sensitive data from leaving the machine with remote backends
+	if s.backendManager.IsRemote(backendID) {
+		s.mu.RLock()
+		dlp := s.dlp
+		s.mu.RUnlock()
+		if findings, redacted := ScanDLP(dlp, argsMap); len(findings) > 0 {
+			if block := dlpBlock(params.Name, findings); block != nil && !s.approvals.Granted(block, argsMap) {
+				s.statsTracker.RecordBlockedCall(params.Name, "dlp")
+				s.recordDLPAudit(ctx, params.Name, argsMap, block.Reason)
+				return s.makeDeniedCall(request.ID, block, argsMap)
+			}
+			logger.Warn("sensitive data in %s arguments: %s", params.Name, formatDLPFindings(findings))
+			if data, err := json.Marshal(redacted); err == nil {
+				params.Arguments = data
+			}
+		}
+	}
+
+	// Authorize the call against the Cedar policies
+	if block := s.checkCedar(ctx, params.Name, argsMap); block != nil {
+		s.statsTracker.RecordBlockedCall(params.Name, "cedar")
+		return s.makeDeniedCall(request.ID, block, argsMap)
+	}
+
+	// Apply the organization's own policy checks
+	if block := s.checkPlugins(ctx, params.Name, argsMap); block != nil {
+		s.statsTracker.RecordBlockedCall(params.Name, "plugin")
+		return s.makeDeniedCall(request.ID, block, argsMap)
+	}
+
+	// Apply the trust tier of the owning backend
+	if block := s.checkTrust(ctx, backendID, params.Name, argsMap); block != nil {
+		s.statsTracker.RecordBlockedCall(params.Name, "trust")
+		return s.makeDeniedCall(request.ID, block, argsMap)
+	}
+
+	// Hold the call if it would exceed a spend budget
+	cost, block := s.checkBudget(ctx, backendID, params.Name, argsMap)
+	if block != nil {
+		s.statsTracker.RecordBlockedCall(params.Name, "budget")
+		return s.makeDeniedCall(request.ID, block, argsMap)
+	}
+
+	// Record allowed call
+	if s.statsTracker != nil {
+		s.statsTracker.RecordAllowedCall(params.Name)
+	}
+	s.recordToolCallAudit(ctx, params.Name, argsMap, nil)
+	s.snapshotForUndo(RequestIDFrom(ctx), backendID, *tool, argsMap)
+
+	// Route to backend with the original tool name
+	started := time.Now()
+	response, err := s.backendManager.CallTool(ctx, backendID, tool.OriginalName, params.Arguments)
+	if err != nil {
+		logger.Error("tool call failed: %v", err)
+		return s.makeError(request.ID, -32603, "Tool call failed", err.Error())
+	}
+	if s.statsTracker != nil {
+		s.statsTracker.RecordLatency(time.Since(started))
+	}
+	if cost > 0 {
+		if err := s.costTracker.Record(params.Name, cost); err != nil {
+			logger.Warn("%v", err)
+		}
+		if s.statsTracker != nil {
+			s.statsTracker.RecordSpend(params.Name, cost)
+		}
+	}
+
+	// Results are passed on even if they violate the outputSchema; the
+	// violation is logged and counted per backend so flaky servers show up
+	result := NormalizeToolResult(response)
+	if fieldErrs := ValidateToolOutput(tool.OutputSchema, result); len(fieldErrs) > 0 {
+		logger.Warn("%s returned output violating its outputSchema: %s", params.Name, formatFieldErrors(fieldErrs))
+		if s.statsTracker != nil {
+			s.statsTracker.RecordSchemaViolation(backendID)
+		}
+	}
+
+	strict := s.policyManager != nil && s.policyManager.GetMode() == StrictMode
+	types, notices := FilterContent(result, contentPolicyFromEnv(strict))
+	for _, notice := range notices {
+		logger.Warn("%s: %s", params.Name, notice)
+	}
+	if s.statsTracker != nil {
+		s.statsTracker.RecordContent(types, len(notices))
+	}
+
+	return s.makeResult(request.ID, s.versionAdapter(backendID).AdaptToolResult(result))
+}
+
+// recordToolCallAudit writes a tools/call decision to the audit log.
+// A nil result means the call was allowed.
+func (s *StdioServer) recordToolCallAudit(ctx context.Context, toolName string, args map[string]interface{}, result *BlocklistCheckResult) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:       backend,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		StatementClass: SQLStatementClass(args),
+	}
+	if result != nil && !result.Allowed {
+		entry.Blocked = true
+		entry.BlockReason = "blocklist_match"
+		entry.DeniedOperation = result.DeniedOperation
+		if result.MatchedRule != nil {
+			entry.MatchedPattern = result.MatchedRule.Pattern
+			entry.RuleAction = result.MatchedRule.Action
+		}
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// recordCallAudit writes a tools/call entry to the audit log with the
+// call's arguments, if the audit policy keeps them for the tool's class.
+func (s *StdioServer) recordCallAudit(ctx context.Context, entry AuditEntry, args map[string]interface{}) {
+	s.mu.RLock()
+	audit, classes := s.audit, s.toolClasses
+	s.mu.RUnlock()
+	entry.Quiet = !entry.Blocked && audit.IsQuiet(entry.ToolName)
+	if !audit.IsZero() {
+		tool := RegisteredTool{Name: entry.ToolName}
+		if registered, err := s.toolRegistry.GetTool(entry.ToolName); err == nil {
+			tool = *registered
+		}
+		entry.Arguments = audit.Arguments(classes.Classify(tool), entry.Blocked, args)
+	}
+	s.recordAudit(ctx, entry)
+}
+
+// recordAudit writes an entry to the audit log under this process's session
+// and the request ID carried by ctx, and counts the call for the session.
+func (s *StdioServer) recordAudit(ctx context.Context, entry AuditEntry) {
+	entry.SessionID = s.auditSession
+	entry.RequestID = RequestIDFrom(ctx)
+	entry.Rewrites = rewritesFrom(ctx)
+	if repo := s.repo.Current(); repo != nil {
+		entry.Repo, entry.Branch = repo.Name, repo.Branch
+		if entry.Repo == "" {
+			entry.Repo = repo.Root
+		}
+	}
+	if !entry.Quiet {
+		s.statsTracker.RecordSessionCall(entry.SessionID, entry.Blocked)
+	}
+	if err := RecordAuditEntry(s.db, entry); err != nil {
+		s.logger.Warn("failed to record audit entry: %v", err)
+	}
+}
+
+// rewriteArgs applies the rewrites section to a call's arguments in the
+// current policy mode.
+func (s *StdioServer) rewriteArgs(toolName string, args map[string]interface{}) (map[string]interface{}, []string) {
+	s.mu.RLock()
+	rewrites := s.rewrites
+	s.mu.RUnlock()
+	mode := ModerateMode
+	if s.policyManager != nil {
+		mode = s.policyManager.GetMode()
+	}
+	return rewrites.Apply(toolName, mode, args)
+}
+
+// recordInvalidArgumentsAudit writes a schema validation failure to the audit log.
+func (s *StdioServer) recordInvalidArgumentsAudit(ctx context.Context, toolName string, args map[string]interface{}, fieldErrs []FieldError) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:       backend,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		Blocked:        true,
+		BlockReason:    "invalid_arguments",
+		MatchedPattern: formatFieldErrors(fieldErrs),
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// argumentValidationEnabled reports whether tools/call arguments are checked
+// against input schemas; ARMOUR_VALIDATE_ARGS=false turns it off.
+func argumentValidationEnabled() bool {
+	switch strings.ToLower(os.Getenv("ARMOUR_VALIDATE_ARGS")) {
+	case "0", "false", "off", "no":
+		return false
+	}
+	return true
+}
+
+// checkTrust applies the backend's trust tier to a tool call. It returns nil
+// if the call may proceed, otherwise why it was denied.
+func (s *StdioServer) checkTrust(ctx context.Context, backendID, toolName string, args map[string]interface{}) *BlockError {
+	s.mu.RLock()
+	tp := s.trust
+	approved := s.trustApprovals[toolName]
+	s.mu.RUnlock()
+
+	tier := tp.TierFor(backendID, s.backendManager.TrustTier(backendID))
+	decision := tp.Decision(tier)
+
+	var reason string
+	switch decision {
+	case TrustAllow:
+		return nil
+	case TrustDeny:
+		reason = fmt.Sprintf("%s servers are denied by the trust policy (server %s)", tier, backendID)
+	case TrustAsk:
+		if approved {
+			return nil
+		}
+		ok, err := s.confirmToolCall(ctx, backendID, toolName, tier)
+		if ok {
+			return nil
+		}
+		if err != nil {
+			reason = fmt.Sprintf("%s servers require approval (server %s): %v", tier, backendID, err)
+		} else {
+			reason = fmt.Sprintf("call to %s was not approved", toolName)
+		}
+	}
+
+	block := &BlockError{
+		BlockedBy: BlockedByTrust,
+		Action:    string(decision),
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    reason,
+		Appeal:    "set trust.servers." + backendID + ": explicit in the policy file to trust this server",
 	}
+	if s.approvals.Granted(block, args) {
+		return nil
+	}
+	s.recordTrustAudit(ctx, toolName, args, tier, decision)
+	return block
+}
 
-	return s.makeResult(request.ID, result)
+// delegate asks the authorizer to decide a call a "delegate" rule matched.
+func (s *StdioServer) delegate(ctx context.Context, toolName string, args map[string]interface{}, result *BlocklistCheckResult) *BlocklistCheckResult {
+	s.mu.RLock()
+	authorizer := s.authorizer
+	s.mu.RUnlock()
+	backendID, _ := parseNamespacedName(toolName)
+	return authorizer.delegate(ctx, result, "tools/call", PolicyCall{
+		Server:    backendID,
+		Tool:      toolName,
+		Arguments: args,
+		SessionID: s.auditSession,
+		Transport: "stdio",
+		Host:      CurrentHostContext(),
+		Repo:      s.repo.Current(),
+	})
 }
 
-// handleToolsCall routes a tool call to the appropriate backend and returns the result.
-func (s *StdioServer) handleToolsCall(ctx context.Context, request JSONRPCRequest) interface{} {
-	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+// checkCedar authorizes a call against the Cedar policies.
+func (s *StdioServer) checkCedar(ctx context.Context, toolName string, args map[string]interface{}) *BlockError {
+	s.mu.RLock()
+	cedar := s.cedar
+	s.mu.RUnlock()
+	backendID, _ := parseNamespacedName(toolName)
+	decision := cedar.authorize(PolicyCall{
+		Server:    backendID,
+		Tool:      toolName,
+		Arguments: args,
+		SessionID: s.auditSession,
+		Transport: "stdio",
+		Host:      CurrentHostContext(),
+		Repo:      s.repo.Current(),
+	})
+	for _, e := range decision.Errors {
+		s.logger.Warn("cedar policy %s ignored for %s", e, toolName)
+	}
+	if decision.Allowed {
+		return nil
+	}
+	block := cedarBlock(toolName, decision)
+	if s.approvals.Granted(block, args) {
+		return nil
+	}
+	s.recordCedarAudit(ctx, toolName, args, decision.Policy)
+	return block
+}
+
+// checkPlugins runs the policy checkers on a call, asking the user to approve
+// it when a checker says so.
+func (s *StdioServer) checkPlugins(ctx context.Context, toolName string, args map[string]interface{}) *BlockError {
+	if len(s.policyCheckers) == 0 {
+		return nil
+	}
+	backendID, _ := parseNamespacedName(toolName)
+	checker, verdict := runPolicyCheckers(ctx, s.policyCheckers, PolicyCall{
+		Server:    backendID,
+		Tool:      toolName,
+		Arguments: args,
+		SessionID: s.auditSession,
+		Transport: "stdio",
+		Host:      CurrentHostContext(),
+		Repo:      s.repo.Current(),
+	})
+	if verdict.Decision == PolicyAllow {
+		return nil
+	}
+
+	block := pluginBlock(checker, toolName, verdict)
+	if verdict.Decision == PolicyAsk {
+		key := checker + "\x00" + toolName
+		s.mu.RLock()
+		approved := s.pluginApprovals[key]
+		s.mu.RUnlock()
+		if approved {
+			return nil
+		}
+		message := fmt.Sprintf("Allow %s? %s", toolName, block.Reason)
+		ok, remember, err := s.elicitApproval(ctx, "plugin", message, "Don't ask again for this tool until the proxy restarts")
+		if ok {
+			if remember {
+				s.mu.Lock()
+				s.pluginApprovals[key] = true
+				s.mu.Unlock()
+			}
+			return nil
+		}
+		if err != nil {
+			block.Reason = fmt.Sprintf("%s (approval required: %v)", block.Reason, err)
+		}
+	}
+	if s.approvals.Granted(block, args) {
+		return nil
 	}
+	s.recordPluginAudit(ctx, toolName, args, verdict.Decision, pluginMatch(checker, verdict, block))
+	return block
+}
 
-	var params struct {
-		Name      string          `json:"name"`
-		Arguments json.RawMessage `json:"arguments"`
+// confirmToolCall asks the user to approve a call via MCP elicitation.
+func (s *StdioServer) confirmToolCall(ctx context.Context, backendID, toolName string, tier TrustTier) (bool, error) {
+	message := fmt.Sprintf("Allow %s? It is provided by %s, a %s-discovered MCP server.", toolName, backendID, tier)
+	ok, remember, err := s.elicitApproval(ctx, "trust", message, "Don't ask again for this tool until the proxy restarts")
+	if ok && remember {
+		s.mu.Lock()
+		s.trustApprovals[toolName] = true
+		s.mu.Unlock()
 	}
+	return ok, err
+}
 
-	if err := json.Unmarshal(request.Params, &params); err != nil {
-		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
+// elicitApproval asks the user to accept or decline via MCP elicitation,
+// offering to remember the answer for the rest of the session.
+func (s *StdioServer) elicitApproval(ctx context.Context, kind, message, rememberHint string) (accepted, remember bool, err error) {
+	if s.clientCaps == nil || s.clientCaps.Elicitation == nil {
+		return false, false, fmt.Errorf("client does not support elicitation")
 	}
 
-	// Handle built-in proxy tools
-	switch params.Name {
-	case "proxy:detect-servers":
-		return s.handleProxyDetectServers(request.ID)
-	case "proxy:server-status":
-		return s.handleProxyServerStatus(request.ID)
-	case "proxy:open-dashboard":
-		return s.handleProxyOpenDashboard(request.ID)
-	case "proxy:migrate-config":
-		return s.handleProxyMigrateConfig(request.ID, params.Arguments)
+	s.mu.Lock()
+	s.elicitSeq++
+	id := fmt.Sprintf("armour-%s-%d", kind, s.elicitSeq)
+	s.mu.Unlock()
+
+	resp, err := s.forwardUpstream(ctx, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  "elicitation/create",
+		"params": map[string]interface{}{
+			"message": message,
+			"requestedSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"remember": map[string]interface{}{
+						"type":        "boolean",
+						"title":       "Allow for the rest of this session",
+						"description": rememberHint,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return false, false, err
 	}
 
-	// Parse arguments for blocklist checking
-	var argsMap map[string]interface{}
-	if err := json.Unmarshal(params.Arguments, &argsMap); err != nil {
-		// If we can't parse, use empty map for blocklist check
-		argsMap = make(map[string]interface{})
+	data, _ := json.Marshal(resp)
+	var reply struct {
+		Result struct {
+			Action  string `json:"action"`
+			Content struct {
+				Remember bool `json:"remember"`
+			} `json:"content"`
+		} `json:"result"`
 	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return false, false, fmt.Errorf("invalid elicitation response: %w", err)
+	}
+	if reply.Result.Action != "accept" {
+		return false, false, nil
+	}
+	return true, reply.Result.Content.Remember, nil
+}
 
-	// Check blocklist for tools/call permission
-	if s.blocklist != nil {
-		result, err := s.blocklist.Check("tools/call", params.Name, argsMap)
-		if err != nil {
-			s.logger.Error("blocklist check failed: %v", err)
+// checkBudget weighs a tool call against the cost policy. It returns the
+// call's cost and, if the call would exceed a budget and was not approved,
+// why it was denied.
+func (s *StdioServer) checkBudget(ctx context.Context, backendID, toolName string, args map[string]interface{}) (float64, *BlockError) {
+	s.mu.RLock()
+	cp := s.costs
+	s.mu.RUnlock()
+
+	cost := cp.Weight(toolName, backendID)
+	exceeded := s.costTracker.Check(cp, toolName, cost)
+	if exceeded == nil {
+		return cost, nil
+	}
+
+	key := exceeded.Period + ":" + exceeded.Budget.Label()
+	var reason string
+	switch exceeded.Budget.EffectiveAction() {
+	case BudgetBlock:
+		reason = exceeded.Error()
+	default:
+		s.mu.RLock()
+		approved := s.budgetApprovals[key]
+		s.mu.RUnlock()
+		if approved {
+			return cost, nil
 		}
-		if !result.Allowed {
-			s.statsTracker.RecordBlockedCall(params.Name, fmt.Sprintf("blocklist:%s", result.DeniedOperation))
-			return s.makeError(request.ID, -32001, "Operation denied", result.Error.Message)
+		message := fmt.Sprintf("Allow %s? The %s. This call costs %g.", toolName, exceeded.Error(), cost)
+		ok, remember, err := s.elicitApproval(ctx, "budget", message, "Don't ask again for this budget until the proxy restarts")
+		if ok {
+			if remember {
+				s.mu.Lock()
+				s.budgetApprovals[key] = true
+				s.mu.Unlock()
+			}
+			return cost, nil
+		}
+		if err != nil {
+			reason = fmt.Sprintf("%s and the call requires approval: %v", exceeded.Error(), err)
+		} else {
+			reason = fmt.Sprintf("call to %s was not approved: %s", toolName, exceeded.Error())
 		}
 	}
 
-	// Get the backend that owns this tool
-	backendID, err := s.toolRegistry.GetToolBackend(params.Name)
-	if err != nil {
-		s.logger.Warn("tool not found: %s", params.Name)
-		return s.makeError(request.ID, -32602, "Tool not found", params.Name)
+	block := &BlockError{
+		BlockedBy: BlockedByBudget,
+		Action:    string(exceeded.Budget.EffectiveAction()),
+		Operation: "tools_call",
+		Tool:      toolName,
+		Reason:    reason,
+		Appeal:    DashboardURL,
 	}
-
-	// Get the original tool name (without the backend namespace prefix)
-	tool, err := s.toolRegistry.GetTool(params.Name)
-	if err != nil {
-		s.logger.Warn("tool metadata not found: %s", params.Name)
-		return s.makeError(request.ID, -32602, "Tool not found", params.Name)
+	if s.approvals.Granted(block, args) {
+		return cost, nil
 	}
+	s.recordBudgetAudit(ctx, toolName, args, exceeded)
+	return cost, block
+}
 
-	// Record allowed call
-	if s.statsTracker != nil {
-		s.statsTracker.RecordAllowedCall(params.Name)
+// recordBudgetAudit writes a budget denial to the audit log.
+func (s *StdioServer) recordBudgetAudit(ctx context.Context, toolName string, args map[string]interface{}, exceeded *BudgetExceeded) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:       backend,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		Blocked:        true,
+		BlockReason:    "budget_exceeded",
+		MatchedPattern: exceeded.Budget.Label(),
+		RuleAction:     string(exceeded.Budget.EffectiveAction()),
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// recordTrustAudit writes a trust-policy denial to the audit log.
+func (s *StdioServer) recordTrustAudit(ctx context.Context, toolName string, args map[string]interface{}, tier TrustTier, decision TrustDecision) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:    backend,
+		Method:      "tools/call",
+		ToolName:    toolName,
+		Transport:   "stdio",
+		Blocked:     true,
+		BlockReason: "trust_" + string(tier),
+		RuleAction:  string(decision),
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// recordSandboxAudit writes a call with paths outside the allowed roots to the audit log.
+func (s *StdioServer) recordSandboxAudit(ctx context.Context, toolName string, args map[string]interface{}, violations string) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:       backend,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		Blocked:        true,
+		BlockReason:    "path_outside_roots",
+		MatchedPattern: violations,
+		RuleAction:     "block",
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// recordEgressAudit writes a call with URLs the egress policy forbids to the audit log.
+func (s *StdioServer) recordEgressAudit(ctx context.Context, toolName string, args map[string]interface{}, violations string) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:       backend,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		Blocked:        true,
+		BlockReason:    "egress_denied",
+		MatchedPattern: violations,
+		RuleAction:     "block",
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// recordCedarAudit writes a call the Cedar policies deny to the audit log,
+// with the forbid policy that matched, if any.
+func (s *StdioServer) recordCedarAudit(ctx context.Context, toolName string, args map[string]interface{}, policy string) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:       backend,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		Blocked:        true,
+		BlockReason:    "cedar_forbid",
+		MatchedPattern: policy,
+		RuleAction:     "forbid",
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// recordPluginAudit writes a call a policy checker denied to the audit log.
+func (s *StdioServer) recordPluginAudit(ctx context.Context, toolName string, args map[string]interface{}, decision, match string) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:       backend,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		Blocked:        true,
+		BlockReason:    "policy_plugin",
+		MatchedPattern: match,
+		RuleAction:     decision,
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// recordDLPAudit writes a call blocked for sensitive arguments to the audit log.
+func (s *StdioServer) recordDLPAudit(ctx context.Context, toolName string, args map[string]interface{}, findings string) {
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:       backend,
+		Method:         "tools/call",
+		ToolName:       toolName,
+		Transport:      "stdio",
+		Blocked:        true,
+		BlockReason:    "sensitive_data",
+		MatchedPattern: findings,
+		RuleAction:     "block",
+	}
+	s.recordCallAudit(ctx, entry, args)
+}
+
+// isDecoy reports whether a tool call is for a decoy rather than a backend tool.
+func (s *StdioServer) isDecoy(toolName string) bool {
+	s.mu.RLock()
+	decoy := s.decoys.IsDecoy(toolName)
+	s.mu.RUnlock()
+	if !decoy {
+		return false
 	}
+	_, err := s.toolRegistry.GetTool(toolName)
+	return err != nil
+}
 
-	// Route to backend with the original tool name
-	response, err := s.backendManager.CallTool(ctx, backendID, tool.OriginalName, params.Arguments)
-	if err != nil {
-		s.logger.Error("tool call failed: %v", err)
-		return s.makeError(request.ID, -32603, "Tool call failed", err.Error())
+// tripDecoy raises the alarm for a call to a decoy tool: the call is
+// audited as an incident and, if the policy says so, the kill switch is
+// engaged. Approvals don't apply; no one should call a decoy.
+func (s *StdioServer) tripDecoy(ctx context.Context, toolName string) *BlockError {
+	s.mu.RLock()
+	killSwitch := s.decoys.KillSwitch
+	s.mu.RUnlock()
+
+	loggerFor(ctx, s.logger).Error("ALERT: decoy tool %s was called; the agent may be prompt-injected", toolName)
+	s.statsTracker.RecordBlockedCall(toolName, "decoy")
+	backend, _ := parseNamespacedName(toolName)
+	entry := AuditEntry{
+		ServerID:    backend,
+		Method:      "tools/call",
+		ToolName:    toolName,
+		Transport:   "stdio",
+		Blocked:     true,
+		BlockReason: "decoy_called",
+		RuleAction:  "block",
+	}
+	s.recordAudit(ctx, entry)
+
+	if killSwitch {
+		state := KillSwitchState{Engaged: true, Reason: "decoy tool " + toolName + " was called"}
+		if err := s.killSwitch.Set(state); err != nil {
+			s.logger.Error("failed to engage kill switch: %v", err)
+		}
 	}
+	return decoyBlock(toolName)
+}
 
-	return s.makeResult(request.ID, response)
+// recordKillSwitchAudit writes a request stopped by the kill switch to the audit log.
+func (s *StdioServer) recordKillSwitchAudit(ctx context.Context, method, name string) {
+	backend, _ := parseArmourURI(name)
+	if backend == "" {
+		backend, _ = parseNamespacedName(name)
+	}
+	entry := AuditEntry{
+		ServerID:    backend,
+		Method:      method,
+		ToolName:    name,
+		Transport:   "stdio",
+		Blocked:     true,
+		BlockReason: "kill_switch",
+		RuleAction:  "block",
+	}
+	s.recordAudit(ctx, entry)
 }
 
 // handleResourcesList aggregates resources from all backends.
 func (s *StdioServer) handleResourcesList(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	// Check blocklist for resources/list permission
@@ -453,43 +1791,28 @@ func (s *StdioServer) handleResourcesList(ctx context.Context, request JSONRPCRe
 			s.logger.Error("blocklist check failed: %v", err)
 		}
 		if !result.Allowed {
-			s.statsTracker.RecordBlockedCall("resources/list", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
-			return s.makeError(request.ID, -32001, "Operation denied", result.Error.Message)
+			block := ruleBlock(result, "", DashboardURL)
+			if !s.approvals.Granted(block, nil) {
+				s.statsTracker.RecordBlockedCall("resources/list", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
+				return s.makeDenied(request.ID, block)
+			}
 		}
 	}
 
-	s.backendManager.WaitForInitialization(ctx, 5*time.Second)
-
-	// Aggregate resources from all backends
-	var allResources []interface{}
-	backends := s.backendManager.GetInitializedBackends()
+	cursor, err := parseListCursor(request.Params)
+	if err != nil {
+		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
+	}
 
-	for _, backend := range backends {
-		// Call resources/list on backend
-		resources, err := s.backendManager.ListResources(ctx, backend.config.Name)
-		if err != nil {
-			s.logger.Warn("failed to list resources from backend %s: %v", backend.config.Name, err)
-			continue
-		}
+	s.backendManager.WaitForInitialization(ctx, 5*time.Second)
 
-		// Namespace URIs with armour://servername/original-uri
-		for _, resource := range resources {
-			resourceMap, ok := resource.(map[string]interface{})
-			if !ok {
-				// If it's not a map, just add it as-is
-				allResources = append(allResources, resource)
-				continue
-			}
-			if uri, ok := resourceMap["uri"].(string); ok {
-				resourceMap["uri"] = fmt.Sprintf("armour://%s/%s", backend.config.Name, uri)
-			}
-			allResources = append(allResources, resourceMap)
+	// Aggregate resources from all backends, namespacing URIs as armour://servername/original-uri
+	resources, next := s.aggregateList(ctx, cursor, s.backendManager.ListResources, func(backendID string, resource map[string]interface{}) {
+		if uri, ok := resource["uri"].(string); ok {
+			resource["uri"] = armourURI(backendID, uri)
 		}
-	}
-
-	result := map[string]interface{}{
-		"resources": allResources,
-	}
+	})
+	result := listResult("resources", resources, next)
 
 	return s.makeResult(request.ID, result)
 }
@@ -497,7 +1820,7 @@ func (s *StdioServer) handleResourcesList(ctx context.Context, request JSONRPCRe
 // handleResourcesRead routes resource read request to appropriate backend.
 func (s *StdioServer) handleResourcesRead(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	var params struct {
@@ -508,6 +1831,12 @@ func (s *StdioServer) handleResourcesRead(ctx context.Context, request JSONRPCRe
 		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
 	}
 
+	if block := s.killSwitch.Check("resources/read", params.URI); block != nil {
+		s.statsTracker.RecordBlockedCall("resources/read", "killswitch")
+		s.recordKillSwitchAudit(ctx, "resources/read", params.URI)
+		return s.makeDenied(request.ID, block)
+	}
+
 	// Check blocklist for resources/read permission
 	if s.blocklist != nil {
 		result, err := s.blocklist.Check("resources/read", params.URI, nil)
@@ -515,8 +1844,11 @@ func (s *StdioServer) handleResourcesRead(ctx context.Context, request JSONRPCRe
 			s.logger.Error("blocklist check failed: %v", err)
 		}
 		if !result.Allowed {
-			s.statsTracker.RecordBlockedCall("resources/read", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
-			return s.makeError(request.ID, -32001, "Operation denied", result.Error.Message)
+			block := ruleBlock(result, params.URI, DashboardURL)
+			if !s.approvals.Granted(block, nil) {
+				s.statsTracker.RecordBlockedCall("resources/read", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
+				return s.makeDenied(request.ID, block)
+			}
 		}
 	}
 
@@ -543,7 +1875,7 @@ func (s *StdioServer) handleResourcesRead(ctx context.Context, request JSONRPCRe
 // handlePromptsList aggregates prompts from all backends.
 func (s *StdioServer) handlePromptsList(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	// Check blocklist for prompts/list permission
@@ -553,43 +1885,29 @@ func (s *StdioServer) handlePromptsList(ctx context.Context, request JSONRPCRequ
 			s.logger.Error("blocklist check failed: %v", err)
 		}
 		if !result.Allowed {
-			s.statsTracker.RecordBlockedCall("prompts/list", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
-			return s.makeError(request.ID, -32001, "Operation denied", result.Error.Message)
+			block := ruleBlock(result, "", DashboardURL)
+			if !s.approvals.Granted(block, nil) {
+				s.statsTracker.RecordBlockedCall("prompts/list", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
+				return s.makeDenied(request.ID, block)
+			}
 		}
 	}
 
-	s.backendManager.WaitForInitialization(ctx, 5*time.Second)
-
-	// Aggregate prompts from all backends
-	var allPrompts []interface{}
-	backends := s.backendManager.GetInitializedBackends()
+	cursor, err := parseListCursor(request.Params)
+	if err != nil {
+		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
+	}
 
-	for _, backend := range backends {
-		// Call prompts/list on backend
-		prompts, err := s.backendManager.ListPrompts(ctx, backend.config.Name)
-		if err != nil {
-			s.logger.Warn("failed to list prompts from backend %s: %v", backend.config.Name, err)
-			continue
-		}
+	s.backendManager.WaitForInitialization(ctx, 5*time.Second)
 
-		// Namespace prompt names as servername:promptname
-		for _, prompt := range prompts {
-			promptMap, ok := prompt.(map[string]interface{})
-			if !ok {
-				// If it's not a map, just add it as-is
-				allPrompts = append(allPrompts, prompt)
-				continue
-			}
-			if name, ok := promptMap["name"].(string); ok {
-				promptMap["name"] = fmt.Sprintf("%s:%s", backend.config.Name, name)
-			}
-			allPrompts = append(allPrompts, promptMap)
+	// Aggregate prompts from all backends, namespacing names as servername:promptname
+	registry := s.backendManager.Prompts()
+	prompts, next := s.aggregateList(ctx, cursor, s.backendManager.ListPrompts, func(backendID string, prompt map[string]interface{}) {
+		if name, ok := prompt["name"].(string); ok {
+			prompt["name"] = registry.Register(backendID, name)
 		}
-	}
-
-	result := map[string]interface{}{
-		"prompts": allPrompts,
-	}
+	})
+	result := listResult("prompts", prompts, next)
 
 	return s.makeResult(request.ID, result)
 }
@@ -597,7 +1915,7 @@ func (s *StdioServer) handlePromptsList(ctx context.Context, request JSONRPCRequ
 // handlePromptsGet routes prompt get request to appropriate backend.
 func (s *StdioServer) handlePromptsGet(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	var params struct {
@@ -616,13 +1934,16 @@ func (s *StdioServer) handlePromptsGet(ctx context.Context, request JSONRPCReque
 			s.logger.Error("blocklist check failed: %v", err)
 		}
 		if !result.Allowed {
-			s.statsTracker.RecordBlockedCall("prompts/get", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
-			return s.makeError(request.ID, -32001, "Operation denied", result.Error.Message)
+			block := ruleBlock(result, params.Name, DashboardURL)
+			if !s.approvals.Granted(block, nil) {
+				s.statsTracker.RecordBlockedCall("prompts/get", fmt.Sprintf("blocklist:%s", result.DeniedOperation))
+				return s.makeDenied(request.ID, block)
+			}
 		}
 	}
 
-	// Parse namespaced prompt name (servername:promptname)
-	backendName, promptName := parseNamespacedName(params.Name)
+	// Map the namespaced prompt name (servername:promptname) to its backend
+	backendName, promptName := s.resolvePrompt(params.Name)
 	if backendName == "" {
 		return s.makeError(request.ID, -32602, "Invalid prompt name", "Must be in format servername:promptname")
 	}
@@ -633,10 +1954,30 @@ func (s *StdioServer) handlePromptsGet(ctx context.Context, request JSONRPCReque
 		s.logger.Warn("failed to get prompt from backend %s: %v", backendName, err)
 		return s.makeError(request.ID, -32603, "Prompt retrieval failed", err.Error())
 	}
+	if result, ok := prompt.(map[string]interface{}); ok {
+		prompt = s.versionAdapter(backendName).AdaptPromptResult(result)
+	}
 
 	return s.makeResult(request.ID, prompt)
 }
 
+// resolvePrompt returns the backend and original name of a namespaced prompt
+// name. Names the client didn't get from prompts/list, e.g. from before a
+// restart, are split after the longest backend name they start with, so
+// backend and prompt names may both contain colons.
+func (s *StdioServer) resolvePrompt(name string) (backendID, promptName string) {
+	if backendID, promptName, ok := s.backendManager.Prompts().Resolve(name); ok {
+		return backendID, promptName
+	}
+	for _, backend := range s.backendManager.GetInitializedBackends() {
+		id := backend.config.Name
+		if strings.HasPrefix(name, id+":") && len(id) > len(backendID) {
+			backendID, promptName = id, name[len(id)+1:]
+		}
+	}
+	return backendID, promptName
+}
+
 // handleProxyDetectServers detects and returns a list of existing MCP servers.
 func (s *StdioServer) handleProxyDetectServers(id interface{}) interface{} {
 	detector, err := cmd.NewServerDetector()
@@ -686,6 +2027,7 @@ func (s *StdioServer) handleProxyServerStatus(id interface{}) interface{} {
 			"name":        backend.config.Name,
 			"transport":   backend.config.Transport,
 			"initialized": backend.initialized,
+			"health":      backend.Health(),
 		})
 	}
 
@@ -694,7 +2036,7 @@ func (s *StdioServer) handleProxyServerStatus(id interface{}) interface{} {
 
 // handleProxyOpenDashboard opens the dashboard in the default browser.
 func (s *StdioServer) handleProxyOpenDashboard(id interface{}) interface{} {
-	dashboardURL := "http://localhost:13337"
+	dashboardURL := DashboardURL
 
 	// Open browser (platform-specific)
 	var cmd *exec.Cmd
@@ -761,42 +2103,59 @@ func (s *StdioServer) handleProxyMigrateConfig(id interface{}, args json.RawMess
 	return s.makeResult(id, result)
 }
 
-// handleCompletionComplete routes completion request to appropriate backend.
+// handleCompletionComplete routes a completion request to the backend that
+// owns the ref, or merges the answers of all backends if the ref isn't
+// namespaced.
 func (s *StdioServer) handleCompletionComplete(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	var params struct {
-		Ref      string      `json:"ref"`
-		Argument interface{} `json:"argument,omitempty"`
-		MetaData interface{} `json:"_meta,omitempty"`
+		Ref      CompletionRef `json:"ref"`
+		Argument *struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"argument"`
+		Context interface{} `json:"context,omitempty"`
 	}
 
 	if err := json.Unmarshal(request.Params, &params); err != nil {
 		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
 	}
+	if err := params.Ref.validate(); err != nil {
+		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
+	}
+	if params.Argument == nil || params.Argument.Name == "" {
+		return s.makeError(request.ID, -32602, "Invalid params", "argument.name is required")
+	}
 
-	// Parse ref to extract backend name
-	backendName, _ := parseNamespacedName(params.Ref)
+	backendName, ref := params.Ref.backendRef()
+	if params.Ref.Type == "ref/prompt" {
+		backendName, ref.Name = s.resolvePrompt(params.Ref.Name)
+		if backendName == "" {
+			ref.Name = params.Ref.Name
+		}
+	}
 	if backendName == "" {
-		return s.makeError(request.ID, -32602, "Invalid ref", "Must be in format servername:ref")
+		completion := s.completeAll(ctx, params.Ref, params.Argument, params.Context)
+		return s.makeResult(request.ID, map[string]interface{}{"completion": completion})
 	}
 
 	// Call completion/complete on the appropriate backend
-	completion, err := s.backendManager.GetCompletion(ctx, backendName, params.Ref, params.Argument, params.MetaData)
+	completion, err := s.backendManager.GetCompletion(ctx, backendName, ref, params.Argument, params.Context)
 	if err != nil {
 		s.logger.Warn("failed to get completion from backend %s: %v", backendName, err)
 		return s.makeError(request.ID, -32603, "Completion failed", err.Error())
 	}
 
-	return s.makeResult(request.ID, completion)
+	return s.makeResult(request.ID, map[string]interface{}{"completion": completion.clamp()})
 }
 
 // handleSamplingCreateMessage forwards sampling request upstream to Claude.
 func (s *StdioServer) handleSamplingCreateMessage(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	// Forward the sampling/createMessage request to Claude (upstream) via stdout
@@ -821,7 +2180,7 @@ func (s *StdioServer) handleSamplingCreateMessage(ctx context.Context, request J
 // handleElicitationCreate forwards elicitation request upstream to Claude.
 func (s *StdioServer) handleElicitationCreate(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	// Forward the elicitation/create request to Claude (upstream) via stdout
@@ -845,7 +2204,7 @@ func (s *StdioServer) handleElicitationCreate(ctx context.Context, request JSONR
 // handleResourcesSubscribe routes resource subscription to backend.
 func (s *StdioServer) handleResourcesSubscribe(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	var params struct {
@@ -861,6 +2220,9 @@ func (s *StdioServer) handleResourcesSubscribe(ctx context.Context, request JSON
 	if backendName == "" {
 		return s.makeError(request.ID, -32602, "Invalid resource URI", "Must be in format armour://servername/original-uri")
 	}
+	if err := s.checkSubscribe(backendName); err != nil {
+		return s.makeError(request.ID, -32601, "Resource subscriptions not supported", err.Error())
+	}
 
 	// Call resources/subscribe on the appropriate backend
 	err := s.backendManager.SubscribeToResource(ctx, backendName, originalURI)
@@ -868,14 +2230,37 @@ func (s *StdioServer) handleResourcesSubscribe(ctx context.Context, request JSON
 		s.logger.Warn("failed to subscribe to resource on backend %s: %v", backendName, err)
 		return s.makeError(request.ID, -32603, "Subscription failed", err.Error())
 	}
+	s.mu.Lock()
+	s.subscriptions[params.URI] = true
+	s.mu.Unlock()
+	s.saveSession()
 
 	return s.makeResult(request.ID, map[string]interface{}{})
 }
 
+// checkSubscribe returns an error unless the proxy advertised
+// resources.subscribe and the backend owning a resource supports it.
+func (s *StdioServer) checkSubscribe(backendName string) error {
+	s.mu.RLock()
+	caps := s.serverCaps
+	s.mu.RUnlock()
+	if !supportsSubscribe(caps) {
+		return fmt.Errorf("no backend supports resources/subscribe")
+	}
+	backendCaps := s.backendManager.BackendCapabilities(backendName)
+	if backendCaps == nil {
+		return fmt.Errorf("backend %s is not connected", backendName)
+	}
+	if !supportsSubscribe(backendCaps) {
+		return fmt.Errorf("backend %s does not support resources/subscribe", backendName)
+	}
+	return nil
+}
+
 // handleResourcesUnsubscribe routes resource unsubscription to backend.
 func (s *StdioServer) handleResourcesUnsubscribe(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
 	var params struct {
@@ -891,6 +2276,9 @@ func (s *StdioServer) handleResourcesUnsubscribe(ctx context.Context, request JS
 	if backendName == "" {
 		return s.makeError(request.ID, -32602, "Invalid resource URI", "Must be in format armour://servername/original-uri")
 	}
+	if err := s.checkSubscribe(backendName); err != nil {
+		return s.makeError(request.ID, -32601, "Resource subscriptions not supported", err.Error())
+	}
 
 	// Call resources/unsubscribe on the appropriate backend
 	err := s.backendManager.UnsubscribeFromResource(ctx, backendName, originalURI)
@@ -898,6 +2286,10 @@ func (s *StdioServer) handleResourcesUnsubscribe(ctx context.Context, request JS
 		s.logger.Warn("failed to unsubscribe from resource on backend %s: %v", backendName, err)
 		return s.makeError(request.ID, -32603, "Unsubscription failed", err.Error())
 	}
+	s.mu.Lock()
+	delete(s.subscriptions, params.URI)
+	s.mu.Unlock()
+	s.saveSession()
 
 	return s.makeResult(request.ID, map[string]interface{}{})
 }
@@ -905,95 +2297,50 @@ func (s *StdioServer) handleResourcesUnsubscribe(ctx context.Context, request JS
 // handleResourcesTemplatesList aggregates resource templates from all backends.
 func (s *StdioServer) handleResourcesTemplatesList(ctx context.Context, request JSONRPCRequest) interface{} {
 	if !s.initialized {
-		return s.makeError(request.ID, -32603, "Not initialized", "Call initialize first")
+		return s.notInitialized(request.ID)
 	}
 
-	s.backendManager.WaitForInitialization(ctx, 5*time.Second)
-
-	// Aggregate resource templates from all backends
-	var allTemplates []interface{}
-	backends := s.backendManager.GetInitializedBackends()
+	cursor, err := parseListCursor(request.Params)
+	if err != nil {
+		return s.makeError(request.ID, -32602, "Invalid params", err.Error())
+	}
 
-	for _, backend := range backends {
-		// Call resources/templates/list on backend
-		templates, err := s.backendManager.ListResourceTemplates(ctx, backend.config.Name)
-		if err != nil {
-			s.logger.Warn("failed to list resource templates from backend %s: %v", backend.config.Name, err)
-			continue
-		}
+	s.backendManager.WaitForInitialization(ctx, 5*time.Second)
 
-		// Namespace URIs with armour://servername/original-uri
-		for _, template := range templates {
-			templateMap, ok := template.(map[string]interface{})
-			if !ok {
-				// If it's not a map, just add it as-is
-				allTemplates = append(allTemplates, template)
-				continue
-			}
-			if uriTemplate, ok := templateMap["uriTemplate"].(string); ok {
-				templateMap["uriTemplate"] = fmt.Sprintf("armour://%s/%s", backend.config.Name, uriTemplate)
-			}
-			allTemplates = append(allTemplates, templateMap)
+	// Aggregate resource templates from all backends, namespacing URIs as armour://servername/original-uri
+	templates, next := s.aggregateList(ctx, cursor, s.backendManager.ListResourceTemplates, func(backendID string, template map[string]interface{}) {
+		if uriTemplate, ok := template["uriTemplate"].(string); ok {
+			template["uriTemplate"] = armourTemplateURI(backendID, uriTemplate)
 		}
-	}
-
-	result := map[string]interface{}{
-		"resourceTemplates": allTemplates,
-	}
+	})
+	result := listResult("resourceTemplates", templates, next)
 
 	return s.makeResult(request.ID, result)
 }
 
-// aggregateCapabilities combines capabilities from all initialized backends.
-// Even with no backends, the proxy always supports tools (at minimum the built-in ones).
+// capabilityWait is how long initialize waits for backends to connect so
+// their capabilities can be taken into account.
+const capabilityWait = 2 * time.Second
+
+// aggregateCapabilities returns the server capabilities the proxy advertises:
+// what it serves end to end, not a union of backend capabilities. Tools,
+// resources and prompts lists are aggregated (empty if no backend has any)
+// and completions are routed to the owning backend. resources.subscribe and
+// prompts.listChanged are advertised only if a connected backend supports
+// them, since the proxy can only relay the notifications backends send; a
+// backend that connects after initialize adds neither. Subscriptions to a
+// backend without support are refused. Resource list changes are not
+// relayed, and logging is withheld because logging/setLevel is not served.
+// Sampling, elicitation and roots are client capabilities and never belong in
+// a server's answer.
 func (s *StdioServer) aggregateCapabilities() *proxy.Capabilities {
-	// Always advertise sampling.tools and listChanged so clients will call tools/list
-	caps := &proxy.Capabilities{
-		Sampling: &proxy.SamplingCapability{
-			Tools: true,
-		},
-		Tools: &proxy.ToolsCapability{
-			ListChanged: true,
-		},
-		ListChanged: true,
+	return &proxy.Capabilities{
+		// The proxy itself emits notifications/tools/list_changed when plugins change
+		Tools:       &proxy.ToolsCapability{ListChanged: true},
+		Resources:   &proxy.ResourcesCapability{Subscribe: s.backendManager.anyBackend(supportsSubscribe)},
+		Prompts:     &proxy.PromptsCapability{ListChanged: s.backendManager.anyBackend(supportsPromptsListChanged)},
+		Completions: &proxy.CompletionsCapability{},
 	}
-
-	// Merge backend capabilities (logical OR: advertise anything any backend supports)
-	backends := s.backendManager.GetInitializedBackends()
-	if len(backends) == 0 {
-		return caps
-	}
-
-	for _, backend := range backends {
-		if backend.Capabilities != nil {
-			if backend.Capabilities.Sampling != nil {
-				if caps.Sampling == nil {
-					caps.Sampling = &proxy.SamplingCapability{}
-				}
-				caps.Sampling.Tools = caps.Sampling.Tools || backend.Capabilities.Sampling.Tools
-			}
-			if backend.Capabilities.Elicitation != nil {
-				if caps.Elicitation == nil {
-					caps.Elicitation = &proxy.ElicitationCapability{}
-				}
-				caps.Elicitation.Enabled = caps.Elicitation.Enabled || backend.Capabilities.Elicitation.Enabled
-			}
-			if backend.Capabilities.Tools != nil {
-				if caps.Tools == nil {
-					caps.Tools = &proxy.ToolsCapability{}
-				}
-				caps.Tools.ListChanged = caps.Tools.ListChanged || backend.Capabilities.Tools.ListChanged
-			}
-			caps.ListChanged = caps.ListChanged || backend.Capabilities.ListChanged
-			caps.Subscribe = caps.Subscribe || backend.Capabilities.Subscribe
-			// Handle Logging as interface{} (can be bool or object)
-			backendLogging := proxy.ToBoolean(backend.Capabilities.Logging)
-			currentLogging := proxy.ToBoolean(caps.Logging)
-			caps.Logging = currentLogging || backendLogging
-		}
-	}
-
-	return caps
 }
 
 // Helper methods for building responses
@@ -1041,8 +2388,82 @@ func (s *StdioServer) makeError(id interface{}, code int, message string, data i
 	}
 }
 
+// makeDenied builds the error response for a request blocked by policy,
+// with a link the admin can follow to let the call through.
+func (s *StdioServer) makeDenied(id interface{}, block *BlockError) JSONRPCResponse {
+	return s.makeDeniedCall(id, block, nil)
+}
+
+// makeDeniedCall is makeDenied for a tools/call, whose arguments are shown
+// on the dashboard's approvals page.
+func (s *StdioServer) makeDeniedCall(id interface{}, block *BlockError, args map[string]interface{}) JSONRPCResponse {
+	// The kill switch is released from the dashboard, not bypassed per call,
+	// and decoys are never meant to be called
+	if s.approvals != nil && block.BlockedBy != BlockedByKillSwitch && block.BlockedBy != BlockedByDecoy {
+		approval := s.approvals.RequestCall(block, args)
+		block.ApprovalURL = DashboardURL + "/?approval=" + approval.Token
+		if args != nil {
+			s.previewCall(approval.Token, block.Tool, args)
+		}
+	}
+	s.rememberBlock(block)
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   deniedError(block),
+	}
+}
+
+// requestTooLarge is the error returned for a message over the size limit.
+// The message is never parsed, so the id is usually unknown (null).
+func requestTooLarge(id interface{}, limit int) JSONRPCResponse {
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &JSONRPCError{
+			Code:    -32600,
+			Message: "Request too large",
+			Data:    map[string]int{"maxBytes": limit},
+		},
+	}
+}
+
 func (s *StdioServer) sendError(id interface{}, code int, message string) error {
-	return s.encoder.Encode(s.makeError(id, code, message, nil))
+	return s.writeMessage(s.makeError(id, code, message, nil))
+}
+
+// writeMessage encodes a JSON-RPC message to stdout, framed the way the
+// client framed its last message.
+func (s *StdioServer) writeMessage(msg interface{}) error {
+	s.writeMu.Lock()
+	framing := s.framing
+	s.writeMu.Unlock()
+	return s.writeResponse(msg, framing)
+}
+
+// writeResponse encodes a JSON-RPC message to stdout with the given
+// framing, that of the request it answers.
+func (s *StdioServer) writeResponse(msg interface{}, framing proxy.Framing) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.recorder.Record(proxy.DirProxyToClient, "", data)
+	return proxy.WriteMessage(s.out, framing, data)
+}
+
+// sendNotification writes a server-initiated JSON-RPC notification to the client.
+func (s *StdioServer) sendNotification(method string, params interface{}) error {
+	msg := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+	}
+	if params != nil {
+		msg["params"] = params
+	}
+	return s.writeMessage(msg)
 }
 
 func initializeDB(dbPath string) (*sql.DB, error) {
@@ -1095,24 +2516,6 @@ func initDBSchema(db *sql.DB) error {
 	return err
 }
 
-// parseArmourURI parses an armour://servername/original-uri into components
-func parseArmourURI(uri string) (serverName, originalURI string) {
-	if !strings.HasPrefix(uri, "armour://") {
-		return "", ""
-	}
-
-	// Remove armour:// prefix
-	remainder := strings.TrimPrefix(uri, "armour://")
-
-	// Find the first slash to separate servername from path
-	idx := strings.Index(remainder, "/")
-	if idx == -1 {
-		return remainder, ""
-	}
-
-	return remainder[:idx], remainder[idx+1:]
-}
-
 // parseNamespacedName parses a namespaced name like "servername:itemname"
 func parseNamespacedName(name string) (serverName, itemName string) {
 	parts := strings.SplitN(name, ":", 2)
@@ -1130,30 +2533,47 @@ func (s *StdioServer) forwardUpstream(ctx context.Context, req map[string]interf
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	// The Run loop hands us the response with our id; register before
+	// sending so it can't arrive first
+	respChan := make(chan interface{}, 1)
+	errChan := make(chan error, 1)
+	key := idKey(req["id"])
+	s.mu.Lock()
+	s.pendingUpstream[key] = respChan
+	reading := s.reading
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pendingUpstream, key)
+		s.mu.Unlock()
+	}()
+
 	// Send to stdout (to Claude)
-	if err := s.encoder.Encode(json.RawMessage(reqData)); err != nil {
+	if err := s.writeMessage(json.RawMessage(reqData)); err != nil {
 		return nil, fmt.Errorf("failed to send request upstream: %w", err)
 	}
 
-	// Read response from stdin (from Claude)
-	// Use a channel to wait for the response with timeout
-	respChan := make(chan interface{}, 1)
-	errChan := make(chan error, 1)
-
-	go func() {
-		var resp interface{}
-		if s.scanner.Scan() {
-			if err := json.Unmarshal(s.scanner.Bytes(), &resp); err != nil {
+	// Without a Run loop (replay), read the response from stdin ourselves
+	if !reading {
+		go func() {
+			msg, err := s.reader.ReadMessage()
+			if err == io.EOF {
+				errChan <- fmt.Errorf("EOF while reading upstream response")
+				return
+			}
+			if err != nil {
+				errChan <- err
+				return
+			}
+			s.recorder.Record(proxy.DirClientToProxy, "", msg)
+			var resp interface{}
+			if err := json.Unmarshal(msg, &resp); err != nil {
 				errChan <- err
 				return
 			}
 			respChan <- resp
-		} else if err := s.scanner.Err(); err != nil {
-			errChan <- err
-		} else {
-			errChan <- fmt.Errorf("EOF while reading upstream response")
-		}
-	}()
+		}()
+	}
 
 	// Wait for response with timeout
 	select {
@@ -1167,3 +2587,57 @@ func (s *StdioServer) forwardUpstream(ctx context.Context, req map[string]interf
 		return nil, fmt.Errorf("timeout waiting for upstream response")
 	}
 }
+
+// trackRequest returns the context to handle a client request in, which
+// notifications/cancelled for its ID cancels, and the func to call once the
+// request is done.
+func (s *StdioServer) trackRequest(ctx context.Context, id interface{}) (context.Context, func()) {
+	reqCtx, cancel := context.WithCancel(ctx)
+	key := idKey(id)
+	s.mu.Lock()
+	s.inflight[key] = cancel
+	s.mu.Unlock()
+	return reqCtx, func() {
+		s.mu.Lock()
+		delete(s.inflight, key)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// handleCancelled cancels the client request a notifications/cancelled
+// names. Requests that already finished, or never existed, are ignored.
+func (s *StdioServer) handleCancelled(request JSONRPCRequest) interface{} {
+	var params struct {
+		RequestID interface{} `json:"requestId"`
+		Reason    string      `json:"reason"`
+	}
+	if err := json.Unmarshal(request.Params, &params); err != nil || params.RequestID == nil {
+		s.logger.Warn("ignoring notifications/cancelled without a requestId")
+		return nil
+	}
+	s.mu.RLock()
+	cancel, ok := s.inflight[idKey(params.RequestID)]
+	s.mu.RUnlock()
+	if ok {
+		s.logger.Debug("client cancelled request %v: %s", params.RequestID, params.Reason)
+		cancel()
+	}
+	return nil
+}
+
+// deliverUpstream passes a client response to the forwardUpstream call
+// waiting for it. It reports false if nothing is waiting for id.
+func (s *StdioServer) deliverUpstream(id interface{}, msg []byte) bool {
+	s.mu.Lock()
+	respChan, ok := s.pendingUpstream[idKey(id)]
+	delete(s.pendingUpstream, idKey(id))
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	var resp interface{}
+	json.Unmarshal(msg, &resp)
+	respChan <- resp
+	return true
+}
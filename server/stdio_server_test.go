@@ -0,0 +1,388 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestStdioServerBatchesAndFraming(t *testing.T) {
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db"), MaxMessageSize: 1024}, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+
+	input := `[{"jsonrpc":"2.0","id":1,"method":"tools/list"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":2,"method":"bogus"},5]` + "\n" +
+		`[]` + "\n" +
+		strings.Repeat("x", 1025) + "\n" +
+		"Content-Length: 46\r\n\r\n" + `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`
+	var out bytes.Buffer
+	srv.reader = proxy.NewMessageReader(strings.NewReader(input), srv.maxMessageSize)
+	srv.out = &out
+
+	if err := srv.Run(context.Background()); err != nil {
+		t.Fatalf("expected clean EOF, got %v", err)
+	}
+
+	// Requests run concurrently, so replies may come in any order
+	reader := proxy.NewMessageReader(&out, 0)
+	var batch []JSONRPCResponse
+	errs := make(map[string]bool)
+	var framed *JSONRPCResponse
+	for {
+		msg, err := reader.ReadMessage()
+		if err != nil {
+			break
+		}
+		if msg[0] == '[' {
+			if err := json.Unmarshal(msg, &batch); err != nil {
+				t.Fatalf("invalid batch response %s: %v", msg, err)
+			}
+			continue
+		}
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			t.Fatalf("invalid response %s: %v", msg, err)
+		}
+		if resp.ID == float64(3) && reader.Framing() == proxy.FramingContentLength {
+			framed = &resp
+		} else if resp.Error != nil {
+			errs[resp.Error.Message] = true
+		}
+	}
+
+	if len(batch) != 3 || batch[0].ID != float64(1) || batch[1].Error == nil || batch[2].Error == nil || batch[2].Error.Code != -32600 {
+		t.Errorf("expected three batch responses, got %+v", batch)
+	}
+	for _, want := range []string{"Invalid Request", "Request too large"} {
+		if !errs[want] {
+			t.Errorf("expected %q error, got %v", want, errs)
+		}
+	}
+	if framed == nil {
+		t.Error("expected Content-Length framed reply to id 3")
+	}
+}
+
+func TestStdioServerRoutesUpstreamResponses(t *testing.T) {
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+
+	// The client answers our elicitation while another request is pending
+	in, clientOut := io.Pipe()
+	var out bytes.Buffer
+	srv.reader = proxy.NewMessageReader(in, 0)
+	srv.out = &out
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(context.Background()) }()
+
+	result := make(chan interface{}, 1)
+	go func() {
+		for {
+			srv.mu.RLock()
+			reading := srv.reading
+			srv.mu.RUnlock()
+			if reading {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		resp, err := srv.forwardUpstream(context.Background(), map[string]interface{}{"jsonrpc": "2.0", "id": "armour-test-1", "method": "elicitation/create"})
+		if err != nil {
+			t.Errorf("forwardUpstream failed: %v", err)
+		}
+		result <- resp
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	io.WriteString(clientOut, `{"jsonrpc":"2.0","id":7,"method":"tools/list"}`+"\n")
+	io.WriteString(clientOut, `{"jsonrpc":"2.0","id":"armour-test-1","result":{"action":"accept"}}`+"\n")
+
+	select {
+	case resp := <-result:
+		if data, _ := json.Marshal(resp); !strings.Contains(string(data), "accept") {
+			t.Errorf("expected elicitation result, got %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("elicitation response was not routed")
+	}
+
+	clientOut.Close()
+	if err := <-done; err != nil {
+		t.Errorf("expected clean EOF, got %v", err)
+	}
+	if stats := srv.GetWorkQueue().Stats(); stats.Completed != 1 || stats.InFlight != 0 {
+		t.Errorf("expected one completed request, got %+v", stats)
+	}
+}
+
+func TestStdioServerFramesEachResponseLikeItsRequest(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: slow
+    delay: 300ms
+    text: slow
+  - name: fast
+    text: fast
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{{Name: "mock", Transport: "mock", Fixture: fixture}}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 0, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	// The slow call is still running when a newline-framed request arrives
+	slow := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"mock:slow","arguments":{}}}`
+	input := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(slow), slow) +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"mock:fast","arguments":{}}}` + "\n"
+	var out bytes.Buffer
+	srv.reader = proxy.NewMessageReader(strings.NewReader(input), 0)
+	srv.out = &out
+	if err := srv.Run(ctx); err != nil {
+		t.Fatalf("expected clean EOF, got %v", err)
+	}
+
+	framings := map[float64]proxy.Framing{}
+	reader := proxy.NewMessageReader(&out, 0)
+	for {
+		msg, err := reader.ReadMessage()
+		if err != nil {
+			break
+		}
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			t.Fatalf("invalid response %s: %v", msg, err)
+		}
+		if id, ok := resp.ID.(float64); ok {
+			framings[id] = reader.Framing()
+		}
+	}
+	if len(framings) != 2 || framings[1] != proxy.FramingContentLength || framings[2] == proxy.FramingContentLength {
+		t.Errorf("expected id 1 framed with Content-Length and id 2 by newline, got %v", framings)
+	}
+}
+
+func TestStdioServerEmptyFrame(t *testing.T) {
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+
+	// An empty frame and a whitespace-only one, then a request that must
+	// still be answered
+	ping := `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+	input := "Content-Length: 0\r\n\r\n" + "Content-Length: 3\r\n\r\n \r\n" +
+		fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(ping), ping)
+	var out bytes.Buffer
+	srv.reader = proxy.NewMessageReader(strings.NewReader(input), 0)
+	srv.out = &out
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Run(ctx); err != nil {
+		t.Fatalf("expected clean EOF, got %v", err)
+	}
+
+	var codes []int
+	answered := false
+	reader := proxy.NewMessageReader(&out, 0)
+	for {
+		msg, err := reader.ReadMessage()
+		if err != nil {
+			break
+		}
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			t.Fatalf("invalid response %s: %v", msg, err)
+		}
+		if resp.Error != nil {
+			codes = append(codes, resp.Error.Code)
+		} else if resp.ID == float64(1) {
+			answered = true
+		}
+	}
+	if len(codes) != 2 || codes[0] != -32600 || codes[1] != -32600 {
+		t.Errorf("expected two Invalid Request errors, got %v", codes)
+	}
+	if !answered {
+		t.Error("expected the ping after the empty frames answered")
+	}
+}
+
+func TestStdioServerFullQueue(t *testing.T) {
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+
+	// Fill the queue: one request running and one waiting
+	srv.queue = NewWorkQueue(1, 1, 1)
+	ctx := context.Background()
+	release, err := srv.queue.Acquire(ctx, stdioSessionID)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	waiting := make(chan struct{})
+	go func() {
+		defer close(waiting)
+		if release, err := srv.queue.Acquire(ctx, stdioSessionID); err == nil {
+			release()
+		}
+	}()
+	for srv.queue.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	input := `{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}` + "\n" +
+		`{"jsonrpc":"2.0","id":5,"method":"tools/list"}` + "\n"
+	var out bytes.Buffer
+	srv.reader = proxy.NewMessageReader(strings.NewReader(input), 0)
+	srv.out = &out
+	if err := srv.Run(ctx); err != nil {
+		t.Fatalf("expected clean EOF, got %v", err)
+	}
+	release()
+	<-waiting
+
+	var resp JSONRPCResponse
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &resp) != nil {
+		t.Fatalf("expected a single response, for the request only, got %q", out.String())
+	}
+	if resp.ID != float64(5) || resp.Error == nil || resp.Error.Message != "Server busy" {
+		t.Errorf("expected the request rejected as busy, got %+v", resp)
+	}
+}
+
+func TestInitializeConformance(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "initialize_result_2025-06-18.json"))
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+
+	tests := []struct {
+		requested string
+		expected  string
+	}{
+		{proxy.MCPProtocolVersion, proxy.MCPProtocolVersion},
+		{"2025-03-26", "2025-03-26"},
+		{"2024-11-05", "2024-11-05"},
+		{"2099-01-01", proxy.MCPProtocolVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requested, func(t *testing.T) {
+			srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+			if err != nil {
+				t.Fatalf("failed to create server: %v", err)
+			}
+			defer srv.Close()
+
+			// A client offering every client capability must not see them echoed back
+			params := `{"protocolVersion":"` + tt.requested + `","clientInfo":{"name":"test","version":"1.0"},"capabilities":{"roots":{"listChanged":true},"sampling":{},"elicitation":{}}}`
+			resp, ok := srv.handleRequest(context.Background(), JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(params)}).(JSONRPCResponse)
+			if !ok || resp.Error != nil {
+				t.Fatalf("expected initialize to succeed, got %+v", resp)
+			}
+
+			result, _ := json.Marshal(resp.Result)
+			if errs := ValidateToolArguments(schema, result); len(errs) > 0 {
+				t.Errorf("initialize result %s does not conform: %v", result, errs)
+			}
+			var parsed struct {
+				ProtocolVersion string             `json:"protocolVersion"`
+				Capabilities    proxy.Capabilities `json:"capabilities"`
+			}
+			json.Unmarshal(result, &parsed)
+			if parsed.ProtocolVersion != tt.expected {
+				t.Errorf("expected protocol version %s, got %s", tt.expected, parsed.ProtocolVersion)
+			}
+			if parsed.Capabilities.Tools == nil || !parsed.Capabilities.Tools.ListChanged {
+				t.Errorf("expected tools.listChanged, got %s", result)
+			}
+		})
+	}
+
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, &proxy.ServerRegistry{}, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	resp, _ := srv.handleRequest(context.Background(), JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"capabilities":{}}`)}).(JSONRPCResponse)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("expected invalid params without protocolVersion, got %+v", resp)
+	}
+}
+
+func TestStdioServerAdaptsResultsForOlderClient(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: weather
+    outputSchema: {type: object, properties: {temperature: {type: number}}}
+    result:
+      content: [{type: resource_link, uri: "file:///forecast.md", name: forecast}]
+      structuredContent: {temperature: 21.5}
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "wx", Transport: "mock", Fixture: fixture},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-03-26"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+	if v := srv.backendManager.ProtocolVersion("wx"); v != proxy.MCPProtocolVersion {
+		t.Fatalf("expected backend on %s, got %q", proxy.MCPProtocolVersion, v)
+	}
+
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "tools/list"}).(JSONRPCResponse)
+	if data, _ := json.Marshal(resp.Result); strings.Contains(string(data), "outputSchema") {
+		t.Errorf("expected outputSchema hidden from a 2025-03-26 client, got %s", data)
+	}
+
+	resp = srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "tools/call", Params: json.RawMessage(`{"name":"wx:weather","arguments":{}}`)}).(JSONRPCResponse)
+	if resp.Error != nil {
+		t.Fatalf("expected tool call to succeed, got %+v", resp.Error)
+	}
+	data, _ := json.Marshal(resp.Result)
+	if strings.Contains(string(data), "structuredContent") || strings.Contains(string(data), "resource_link") {
+		t.Errorf("expected 2025-06-18 content translated, got %s", data)
+	}
+	if !strings.Contains(string(data), "forecast: file:///forecast.md") || !strings.Contains(string(data), `{\"temperature\":21.5}`) {
+		t.Errorf("expected link and structured data as text, got %s", data)
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TenantHeader names the tenant of an HTTP-mode request. Requests may also
+// name it with a /tenants/<name>/ path prefix.
+const TenantHeader = "X-Armour-Tenant"
+
+// tenantPathPrefix starts the paths of tenant routes, e.g.
+// /tenants/payments/mcp/github.
+const tenantPathPrefix = "/tenants/"
+
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// TenantsFile lists the tenants of a multi-tenant HTTP proxy. Relative paths
+// are resolved against the file's directory.
+//
+//	{"tenants": [
+//	  {"name": "payments", "config": "payments/servers.json", "policy": "payments/policy.yaml",
+//	   "client_fingerprints": ["3F:A2:..."]}
+//	]}
+type TenantsFile struct {
+	Tenants []TenantConfig `json:"tenants"`
+}
+
+// TenantConfig is one tenant: its server registry and policy file. With
+// ClientFingerprints, only clients presenting one of those TLS certificates
+// may use the tenant.
+type TenantConfig struct {
+	Name               string   `json:"name"`
+	ConfigPath         string   `json:"config"`
+	PolicyPath         string   `json:"policy,omitempty"` // trust, egress, dlp, cedar and authorizer sections apply
+	ClientFingerprints []string `json:"client_fingerprints,omitempty"`
+}
+
+// LoadTenantsFile reads and validates a tenants file.
+func LoadTenantsFile(path string) (*TenantsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+	var tf TenantsFile
+	if err := json.Unmarshal(data, &tf); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	seen := make(map[string]bool)
+	for i := range tf.Tenants {
+		tc := &tf.Tenants[i]
+		if !tenantNamePattern.MatchString(tc.Name) {
+			return nil, fmt.Errorf("tenant %q: names use lowercase letters, digits, - and _", tc.Name)
+		}
+		if seen[tc.Name] {
+			return nil, fmt.Errorf("tenant %s is listed twice", tc.Name)
+		}
+		seen[tc.Name] = true
+		if tc.ConfigPath == "" {
+			return nil, fmt.Errorf("tenant %s: config is required", tc.Name)
+		}
+		tc.ConfigPath = resolveTenantPath(dir, tc.ConfigPath)
+		if tc.PolicyPath != "" {
+			tc.PolicyPath = resolveTenantPath(dir, tc.PolicyPath)
+		}
+		for j, fp := range tc.ClientFingerprints {
+			if tc.ClientFingerprints[j], err = NormalizeFingerprint(fp); err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", tc.Name, err)
+			}
+		}
+	}
+	return &tf, nil
+}
+
+func resolveTenantPath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// tenant is what requests for one tenant see: its servers, blocklist view
+// and policies. Audit entries are tagged with its name. The unnamed tenant
+// is the proxy's own registry and policy.
+type tenant struct {
+	name         string
+	registry     *proxy.ServerRegistry
+	mocks        map[string]*MockFixture
+	blocklist    *BlocklistMiddleware
+	trust        TrustPolicy
+	egress       EgressPolicy
+	dlp          DLPPolicy
+	cedar        *cedarPolicySet
+	authorizer   AuthorizerPolicy
+	fingerprints map[string]bool // allowed client certificates; empty allows any client
+}
+
+// loadTenant builds a tenant from its config; rules come from the proxy's
+// blocklist but tags resolve against the tenant's servers.
+func loadTenant(tc TenantConfig, blocklist *BlocklistMiddleware) (*tenant, error) {
+	registry, err := proxy.LoadServerRegistry(tc.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tc.Name, err)
+	}
+	mocks, err := loadRoutes(registry)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tc.Name, err)
+	}
+
+	t := &tenant{
+		name:      tc.Name,
+		registry:  registry,
+		mocks:     mocks,
+		blocklist: blocklist.withRegistry(registry),
+		trust:     DefaultTrustPolicy(),
+	}
+	if tc.PolicyPath != "" {
+		pf, err := LoadPolicyFile(tc.PolicyPath)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tc.Name, err)
+		}
+		if !pf.Trust.IsZero() {
+			t.trust = pf.Trust
+		}
+		t.egress = pf.Egress
+		t.dlp = pf.DLP
+		t.cedar = pf.Cedar.compile()
+		t.authorizer = pf.Authorizer
+	}
+	if len(tc.ClientFingerprints) > 0 {
+		t.fingerprints = make(map[string]bool, len(tc.ClientFingerprints))
+		for _, fp := range tc.ClientFingerprints {
+			t.fingerprints[fp] = true
+		}
+	}
+	return t, nil
+}
+
+// loadRoutes checks that no server takes a path the proxy serves itself and
+// loads the fixtures of "mock" transport servers.
+func loadRoutes(registry *proxy.ServerRegistry) (map[string]*MockFixture, error) {
+	mocks := make(map[string]*MockFixture)
+	for _, srv := range registry.Servers {
+		route := srv.RoutePath()
+		switch route {
+		case "/", "/healthz", "/mcp", "/trace", "/queue":
+			return nil, fmt.Errorf("server %s: path %s is reserved", srv.Name, route)
+		}
+		if strings.HasPrefix(route, tenantPathPrefix) {
+			return nil, fmt.Errorf("server %s: paths under %s are reserved", srv.Name, tenantPathPrefix)
+		}
+		if srv.Transport == "mock" {
+			fixture, err := LoadMockFixture(srv.Fixture)
+			if err != nil {
+				return nil, fmt.Errorf("server %s: %v", srv.Name, err)
+			}
+			mocks[srv.Name] = fixture
+		}
+	}
+	return mocks, nil
+}
+
+// allows reports whether the request's client certificate may use the tenant.
+func (t *tenant) allows(r *http.Request) bool {
+	if len(t.fingerprints) == 0 {
+		return true
+	}
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return false
+	}
+	return t.fingerprints[CertFingerprint(r.TLS.PeerCertificates[0].Raw)]
+}
+
+// defaultTenant returns the proxy's own registry and current policies.
+func (s *Server) defaultTenant() *tenant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &tenant{
+		registry:   s.registry,
+		mocks:      s.mocks,
+		blocklist:  s.blocklist,
+		trust:      s.trust,
+		egress:     s.egress,
+		dlp:        s.dlp,
+		cedar:      s.cedar,
+		authorizer: s.authorizer,
+	}
+}
+
+// resolveTenant returns the tenant a request is for, named by TenantHeader or
+// a /tenants/<name>/ path prefix, and the request path within the tenant.
+// Requests that name no tenant use the proxy's own registry.
+func (s *Server) resolveTenant(r *http.Request) (*tenant, string, int, error) {
+	path := r.URL.Path
+	name := r.Header.Get(TenantHeader)
+	if rest, ok := strings.CutPrefix(path, tenantPathPrefix); ok && len(s.tenants) > 0 {
+		fromPath, route, _ := strings.Cut(rest, "/")
+		if name != "" && name != fromPath {
+			return nil, "", http.StatusBadRequest, fmt.Errorf("%s header %q does not match the path's tenant %q", TenantHeader, name, fromPath)
+		}
+		name, path = fromPath, "/"+route
+	}
+	if name == "" {
+		return s.defaultTenant(), path, 0, nil
+	}
+
+	t := s.tenants[name]
+	if t == nil {
+		return nil, "", http.StatusNotFound, fmt.Errorf("unknown tenant %s", name)
+	}
+	if !t.allows(r) {
+		return nil, "", http.StatusForbidden, fmt.Errorf("client certificate not allowed for tenant %s", name)
+	}
+	return t, path, 0, nil
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestLoadTenantsFile tests path resolution and validation of tenants
+func TestLoadTenantsFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "tenants.json")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write tenants file: %v", err)
+		}
+		return path
+	}
+
+	tf, err := LoadTenantsFile(write(`{"tenants": [{"name": "payments", "config": "payments/servers.json", "client_fingerprints": ["sha256:` + strings.Repeat("AB:", 31) + `AB"]}]}`))
+	if err != nil {
+		t.Fatalf("failed to load tenants file: %v", err)
+	}
+	if got := tf.Tenants[0].ConfigPath; got != filepath.Join(dir, "payments", "servers.json") {
+		t.Errorf("expected config resolved against the file, got %s", got)
+	}
+	if got := tf.Tenants[0].ClientFingerprints[0]; got != strings.Repeat("ab", 32) {
+		t.Errorf("expected a normalized fingerprint, got %s", got)
+	}
+
+	for _, bad := range []string{
+		`{"tenants": [{"name": "Payments", "config": "a.json"}]}`,
+		`{"tenants": [{"name": "a", "config": "a.json"}, {"name": "a", "config": "b.json"}]}`,
+		`{"tenants": [{"name": "a"}]}`,
+		`{"tenants": [{"name": "a", "config": "a.json", "client_fingerprints": ["nope"]}]}`,
+	} {
+		if _, err := LoadTenantsFile(write(bad)); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+// TestTenantIsolation tests that tenants get their own servers, policies and
+// audit partition
+func TestTenantIsolation(t *testing.T) {
+	hits := map[string]int{}
+	upstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[name]++
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+		}))
+	}
+	payments, search := upstream("payments"), upstream("search")
+	defer payments.Close()
+	defer search.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"payments/servers.json": fmt.Sprintf(`{"servers": [{"name": "github", "transport": "http", "url": "%s"}]}`, payments.URL),
+		"search/servers.json":   fmt.Sprintf(`{"servers": [{"name": "github", "transport": "http", "url": "%s"}]}`, search.URL),
+		"search/policy.yaml":    "trust:\n  explicit: deny\n",
+		"tenants.json": `{"tenants": [
+			{"name": "payments", "config": "payments/servers.json"},
+			{"name": "search", "config": "search/servers.json", "policy": "search/policy.yaml"},
+			{"name": "locked", "config": "payments/servers.json", "client_fingerprints": ["` + strings.Repeat("ab", 32) + `"]}
+		]}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	t.Setenv("ARMOUR_RULES_URL", "")
+
+	server, err := NewServer(Config{
+		ListenAddr:  "127.0.0.1:0",
+		TenantsPath: filepath.Join(dir, "tenants.json"),
+		DBPath:      filepath.Join(dir, "proxy.db"),
+		Mode:        "http",
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer server.Close()
+
+	post := func(path, tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"list_issues"}}`))
+		req.Host = "127.0.0.1"
+		req.Header.Set(proxy.HeaderSessionID, "tenant-session")
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		rec := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/tenants/payments/mcp/github", ""); rec.Code != http.StatusOK || hits["payments"] != 1 || hits["search"] != 0 {
+		t.Errorf("expected the payments backend, got %d (hits %v)", rec.Code, hits)
+	}
+	if rec := post("/mcp?server=github", "payments"); rec.Code != http.StatusOK || hits["payments"] != 2 {
+		t.Errorf("expected the shared endpoint to use the header's tenant, got %d (hits %v)", rec.Code, hits)
+	}
+
+	rec := post("/mcp/github", "search")
+	var resp JSONRPCResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Error == nil || hits["search"] != 0 {
+		t.Errorf("expected the search tenant's trust policy to deny the call, got %+v", resp)
+	}
+
+	if rec := post("/mcp/github", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected no servers outside a tenant, got %d", rec.Code)
+	}
+	if rec := post("/tenants/unknown/mcp/github", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown tenant, got %d", rec.Code)
+	}
+	if rec := post("/tenants/payments/mcp/github", "search"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 when header and path disagree, got %d", rec.Code)
+	}
+	if rec := post("/tenants/locked/mcp/github", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the tenant's client certificate, got %d", rec.Code)
+	}
+
+	entries, err := QueryAuditLog(server.db, AuditFilter{Tenant: "search"})
+	if err != nil {
+		t.Fatalf("failed to query audit log: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Tenant != "search" || !entries[0].Blocked {
+		t.Errorf("expected the search tenant's denial in its partition, got %+v", entries)
+	}
+	if entries, _ := QueryAuditLog(server.db, AuditFilter{Tenant: "payments"}); len(entries) != 2 {
+		t.Errorf("expected 2 payments entries, got %d", len(entries))
+	}
+}
@@ -0,0 +1,50 @@
+//go:build wasip1
+
+// wasm-misbehave is a policy module that fails the way the tests name the
+// tool: it traps, loops forever, grows its memory without bound, answers
+// with something other than a verdict or points outside its memory.
+package main
+
+import (
+	"encoding/json"
+	"unsafe"
+)
+
+var input, output []byte
+
+// hoard keeps what grow allocates reachable.
+var hoard [][]byte
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	input = make([]byte, size+1)
+	return uint32(uintptr(unsafe.Pointer(&input[0])))
+}
+
+//go:wasmexport check
+func check(ptr, size uint32) uint64 {
+	var call struct {
+		Tool string `json:"tool"`
+	}
+	json.Unmarshal(unsafe.Slice((*byte)(unsafe.Pointer(uintptr(ptr))), size), &call)
+	switch call.Tool {
+	case "wasm:trap":
+		panic("trap")
+	case "wasm:loop":
+		for {
+		}
+	case "wasm:grow":
+		for {
+			hoard = append(hoard, make([]byte, 1<<20))
+		}
+	case "wasm:garbage":
+		output = []byte("not a verdict")
+	case "wasm:range":
+		return 0xfffffff0<<32 | 16
+	default:
+		output = []byte(`{"decision":"allow"}`)
+	}
+	return uint64(uintptr(unsafe.Pointer(&output[0])))<<32 | uint64(len(output))
+}
+
+func main() {}
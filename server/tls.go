@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSConfig configures TLS on the HTTP-mode listener. With ClientCAFile or
+// ClientFingerprints set, clients must present a certificate (mutual TLS).
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // verify client certs against this CA bundle
+	// ClientFingerprints allowlists client certs by SHA-256 fingerprint.
+	// Without a CA, self-signed client certs are accepted if allowlisted.
+	ClientFingerprints []string
+}
+
+// Enabled reports whether any TLS option is set.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.ClientCAFile != "" || len(c.ClientFingerprints) > 0
+}
+
+// Build returns the server tls.Config, or nil if TLS is not enabled.
+func (c TLSConfig) Build() (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("TLS requires both a certificate and a key")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if len(c.ClientFingerprints) > 0 {
+		allowed := make(map[string]bool, len(c.ClientFingerprints))
+		for _, fp := range c.ClientFingerprints {
+			normalized, err := NormalizeFingerprint(fp)
+			if err != nil {
+				return nil, err
+			}
+			allowed[normalized] = true
+		}
+		if config.ClientAuth == tls.NoClientCert {
+			// Chain verification is replaced by the allowlist below
+			config.ClientAuth = tls.RequireAnyClientCert
+		}
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("client certificate required")
+			}
+			fp := CertFingerprint(rawCerts[0])
+			if !allowed[fp] {
+				return fmt.Errorf("client certificate %s is not allowlisted", fp)
+			}
+			return nil
+		}
+	}
+
+	return config, nil
+}
+
+// CertFingerprint returns the lowercase hex SHA-256 of a DER certificate.
+func CertFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint accepts SHA-256 fingerprints as plain hex or in the
+// colon-separated form printed by `openssl x509 -fingerprint -sha256`, with
+// an optional "sha256:" prefix, and returns lowercase hex.
+func NormalizeFingerprint(fp string) (string, error) {
+	s := strings.TrimSpace(fp)
+	if i := strings.LastIndex(s, "="); i >= 0 {
+		s = s[i+1:] // "sha256 Fingerprint=AB:CD:..."
+	}
+	s = strings.TrimPrefix(strings.ToLower(s), "sha256:")
+	s = strings.ReplaceAll(s, ":", "")
+	if b, err := hex.DecodeString(s); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 certificate fingerprint %q", fp)
+	}
+	return s, nil
+}
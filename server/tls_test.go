@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a self-signed cert/key pair for 127.0.0.1 and
+// returns the paths and the loaded certificate.
+func writeSelfSignedCert(t *testing.T, dir, name string) (certFile, keyFile string, cert tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("failed to write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load key pair: %v", err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestNormalizeFingerprint(t *testing.T) {
+	hex := strings.Repeat("ab", 32)
+	colons := strings.ToUpper(strings.TrimSuffix(strings.Repeat("AB:", 32), ":"))
+
+	for _, in := range []string{hex, colons, "sha256:" + hex, "sha256 Fingerprint=" + colons} {
+		got, err := NormalizeFingerprint(in)
+		if err != nil {
+			t.Errorf("NormalizeFingerprint(%q) failed: %v", in, err)
+		} else if got != hex {
+			t.Errorf("NormalizeFingerprint(%q) = %q, want %q", in, got, hex)
+		}
+	}
+
+	for _, in := range []string{"", "abcd", strings.Repeat("zz", 32)} {
+		if _, err := NormalizeFingerprint(in); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
+
+func TestTLSConfigRequiresKeyPair(t *testing.T) {
+	if _, err := (TLSConfig{ClientFingerprints: []string{strings.Repeat("ab", 32)}}).Build(); err == nil {
+		t.Error("expected error for client allowlist without a server certificate")
+	}
+	if cfg, err := (TLSConfig{}).Build(); err != nil || cfg != nil {
+		t.Errorf("expected TLS disabled, got %v, %v", cfg, err)
+	}
+}
+
+func TestClientFingerprintAllowlist(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey, _ := writeSelfSignedCert(t, dir, "server")
+	_, _, allowed := writeSelfSignedCert(t, dir, "allowed-agent")
+	_, _, denied := writeSelfSignedCert(t, dir, "other-agent")
+
+	config, upstream := makeTestConfig(t, getAvailableAddr(t))
+	defer upstream.Close()
+	config.TLS = TLSConfig{
+		CertFile:           serverCert,
+		KeyFile:            serverKey,
+		ClientFingerprints: []string{CertFingerprint(allowed.Certificate[0])},
+	}
+
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go server.ListenAndServe(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	get := func(certs ...tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       certs,
+		}}}
+		resp, err := client.Get("https://" + config.ListenAddr + "/healthz")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if err := get(allowed); err != nil {
+		t.Errorf("allowlisted client was rejected: %v", err)
+	}
+	if err := get(denied); err == nil {
+		t.Error("expected non-allowlisted client to be rejected")
+	}
+	if err := get(); err == nil {
+		t.Error("expected client without certificate to be rejected")
+	}
+}
+
+func TestHTTP2OverTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey, _ := writeSelfSignedCert(t, dir, "server")
+
+	config, upstream := makeTestConfig(t, getAvailableAddr(t))
+	defer upstream.Close()
+	config.TLS = TLSConfig{CertFile: serverCert, KeyFile: serverKey}
+
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go server.ListenAndServe(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + config.ListenAddr + "/healthz")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2, got %s", resp.Proto)
+	}
+}
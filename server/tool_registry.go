@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 )
 
@@ -21,6 +22,7 @@ type RegisteredTool struct {
 	Name         string                 `json:"name"`
 	Description  string                 `json:"description,omitempty"`
 	InputSchema  map[string]interface{} `json:"inputSchema,omitempty"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
 	BackendID    string                 `json:"backendId,omitempty"`
 	OriginalName string                 `json:"originalName,omitempty"` // Name without namespace prefix
 }
@@ -58,6 +60,7 @@ func (tr *ToolRegistry) RegisterBackendTools(backendID string, tools []Tool) err
 			Name:         namespacedName,
 			Description:  tool.Description,
 			InputSchema:  tool.InputSchema,
+			OutputSchema: tool.OutputSchema,
 			BackendID:    backendID,
 			OriginalName: tool.Name,
 		}
@@ -94,7 +97,8 @@ func (tr *ToolRegistry) GetTool(toolName string) (*RegisteredTool, error) {
 	return tool, nil
 }
 
-// ListAllTools returns all registered tools, namespaced with their backend IDs.
+// ListAllTools returns all registered tools, namespaced with their backend
+// IDs, sorted by name so tools/list is the same every time.
 func (tr *ToolRegistry) ListAllTools() []RegisteredTool {
 	tr.mu.RLock()
 	defer tr.mu.RUnlock()
@@ -103,6 +107,7 @@ func (tr *ToolRegistry) ListAllTools() []RegisteredTool {
 	for _, tool := range tr.tools {
 		tools = append(tools, *tool)
 	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
 
 	return tools
 }
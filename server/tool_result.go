@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// NormalizeToolResult coerces a backend's tools/call result into a consistent
+// MCP CallToolResult: a "content" array of typed items and a boolean
+// "isError". It repairs common deviations:
+//
+//   - bare strings, arrays or missing results instead of a result object
+//   - content given as a string or single item, or items without a "type"
+//   - isError given as a string or number
+//   - a legacy {"error": "..."} field instead of isError
+//   - structuredContent without a text fallback in content
+func NormalizeToolResult(raw interface{}) map[string]interface{} {
+	result, ok := raw.(map[string]interface{})
+	if !ok {
+		switch v := raw.(type) {
+		case nil:
+			return map[string]interface{}{"content": []interface{}{}}
+		case []interface{}:
+			return map[string]interface{}{"content": normalizeContent(v)}
+		default:
+			return map[string]interface{}{"content": []interface{}{textContent(v)}}
+		}
+	}
+
+	normalized := make(map[string]interface{}, len(result)+1)
+	for k, v := range result {
+		normalized[k] = v
+	}
+
+	var content []interface{}
+	switch c := result["content"].(type) {
+	case []interface{}:
+		content = normalizeContent(c)
+	case nil:
+		content = []interface{}{}
+	default:
+		content = normalizeContent([]interface{}{c})
+	}
+
+	if isErr, present := result["isError"]; present {
+		normalized["isError"] = truthy(isErr)
+	} else if legacy, present := result["error"]; present && legacy != nil && legacy != false {
+		normalized["isError"] = true
+		delete(normalized, "error")
+		if len(content) == 0 {
+			content = append(content, textContent(legacy))
+		}
+	}
+
+	if structured, ok := result["structuredContent"]; ok && len(content) == 0 {
+		content = append(content, textContent(structured))
+	}
+
+	normalized["content"] = content
+	return normalized
+}
+
+// ValidateToolOutput checks a normalized result's structuredContent against a
+// tool's outputSchema. Error results are not checked.
+func ValidateToolOutput(schema map[string]interface{}, result map[string]interface{}) []FieldError {
+	if len(schema) == 0 || result["isError"] == true {
+		return nil
+	}
+	structured, ok := result["structuredContent"]
+	if !ok {
+		return []FieldError{{Message: "structuredContent is missing but the tool declares an outputSchema"}}
+	}
+	var errs []FieldError
+	validateValue(schema, structured, "", &errs)
+	return errs
+}
+
+func normalizeContent(items []interface{}) []interface{} {
+	content := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			content = append(content, textContent(item))
+			continue
+		}
+		if _, typed := m["type"].(string); !typed {
+			m = inferContentType(m)
+		}
+		content = append(content, m)
+	}
+	return content
+}
+
+// inferContentType fills in "type" for an untyped content item.
+func inferContentType(item map[string]interface{}) map[string]interface{} {
+	typed := make(map[string]interface{}, len(item)+1)
+	for k, v := range item {
+		typed[k] = v
+	}
+	mimeType, _ := item["mimeType"].(string)
+	switch {
+	case item["text"] != nil:
+		typed["type"] = "text"
+	case item["data"] != nil && strings.HasPrefix(mimeType, "image/"):
+		typed["type"] = "image"
+	case item["data"] != nil && strings.HasPrefix(mimeType, "audio/"):
+		typed["type"] = "audio"
+	case item["resource"] != nil:
+		typed["type"] = "resource"
+	case item["uri"] != nil:
+		typed["type"] = "resource_link"
+	default:
+		return textContent(item)
+	}
+	return typed
+}
+
+func textContent(v interface{}) map[string]interface{} {
+	text, ok := v.(string)
+	if !ok {
+		data, err := json.Marshal(v)
+		if err != nil {
+			text = fmt.Sprint(v)
+		} else {
+			text = string(data)
+		}
+	}
+	return map[string]interface{}{"type": "text", "text": text}
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		v = strings.ToLower(strings.TrimSpace(v))
+		return v != "" && v != "false" && v != "0" && v != "no"
+	case float64:
+		return v != 0
+	case nil:
+		return false
+	}
+	return true
+}
+
+// formatFieldErrors joins validation errors for logs and audit entries.
+func formatFieldErrors(errs []FieldError) string {
+	parts := make([]string, len(errs))
+	for i, e := range errs {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeToolResult(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"already valid", `{"content":[{"type":"text","text":"hi"}],"isError":false}`,
+			`{"content":[{"type":"text","text":"hi"}],"isError":false}`},
+		{"bare string", `"done"`, `{"content":[{"type":"text","text":"done"}]}`},
+		{"null", `null`, `{"content":[]}`},
+		{"string content", `{"content":"hi"}`, `{"content":[{"type":"text","text":"hi"}]}`},
+		{"untyped items", `{"content":[{"text":"a"},{"data":"AAAA","mimeType":"image/png"},{"uri":"file:///x"},"b"]}`,
+			`{"content":[{"type":"text","text":"a"},{"type":"image","data":"AAAA","mimeType":"image/png"},{"type":"resource_link","uri":"file:///x"},{"type":"text","text":"b"}]}`},
+		{"string isError", `{"content":[],"isError":"true"}`, `{"content":[],"isError":true}`},
+		{"legacy error", `{"error":"rate limited"}`, `{"content":[{"type":"text","text":"rate limited"}],"isError":true}`},
+		{"structured only", `{"structuredContent":{"n":1}}`,
+			`{"content":[{"type":"text","text":"{\"n\":1}"}],"structuredContent":{"n":1}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw, want interface{}
+			json.Unmarshal([]byte(tt.raw), &raw)
+			json.Unmarshal([]byte(tt.want), &want)
+
+			got := NormalizeToolResult(raw)
+			// Round-trip so typed slices compare equal to decoded JSON
+			data, _ := json.Marshal(got)
+			var gotJSON interface{}
+			json.Unmarshal(data, &gotJSON)
+			if !reflect.DeepEqual(gotJSON, want) {
+				t.Errorf("got %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateToolOutput(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"count": map[string]interface{}{"type": "integer"}},
+		"required":   []interface{}{"count"},
+	}
+
+	check := func(raw string) []FieldError {
+		var v interface{}
+		json.Unmarshal([]byte(raw), &v)
+		return ValidateToolOutput(schema, NormalizeToolResult(v))
+	}
+
+	if errs := check(`{"content":[],"structuredContent":{"count":3}}`); errs != nil {
+		t.Errorf("expected valid output, got %v", errs)
+	}
+	if errs := check(`{"content":[],"structuredContent":{"count":"3"}}`); len(errs) != 1 || errs[0].Field != "count" {
+		t.Errorf("expected count type error, got %v", errs)
+	}
+	if errs := check(`{"content":[{"type":"text","text":"3"}]}`); len(errs) != 1 {
+		t.Errorf("expected missing structuredContent error, got %v", errs)
+	}
+	if errs := check(`{"content":[],"isError":true}`); errs != nil {
+		t.Errorf("expected error results to skip validation, got %v", errs)
+	}
+	if errs := ValidateToolOutput(nil, map[string]interface{}{}); errs != nil {
+		t.Errorf("expected no validation without outputSchema, got %v", errs)
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// settingToolClasses persists the applied tool_classes section in the rules store.
+const settingToolClasses = "tool_classes"
+
+// What a tool does, as far as strict mode is concerned. Strict mode hides
+// every class but read from tools/list.
+const (
+	ToolClassRead   = "read"
+	ToolClassWrite  = "write"
+	ToolClassExec   = "exec"
+	ToolClassDelete = "delete"
+)
+
+var toolClasses = []string{ToolClassRead, ToolClassWrite, ToolClassExec, ToolClassDelete}
+
+// Words in a tool name that mark it as running code or changing state.
+// Destructive names are recognized by isDestructivePattern.
+var (
+	execToolWords  = []string{"exec", "execute", "run", "shell", "bash", "sh", "eval", "command", "cmd", "spawn", "terminal", "script"}
+	writeToolWords = []string{
+		"write", "create", "update", "edit", "put", "post", "upload", "insert", "modify", "patch", "set", "save",
+		"move", "rename", "append", "commit", "push", "send", "add", "merge", "publish", "deploy", "apply",
+	}
+)
+
+// ClassifyTool guesses the class of a tool from its name and input schema:
+// delete for destructive names (rm, delete_*, drop_*, ...), exec for names
+// like run_command or tools taking a command or script argument, write for
+// names like create_* or update_*, and read otherwise.
+func ClassifyTool(name string, inputSchema map[string]interface{}) string {
+	_, base := parseNamespacedName(name)
+	if base == "" {
+		base = name
+	}
+	if isDestructivePattern(strings.ToLower(base)) {
+		return ToolClassDelete
+	}
+
+	words := toolNameWords(base)
+	for _, word := range words {
+		if slices.Contains(execToolWords, word) {
+			return ToolClassExec
+		}
+	}
+	props, _ := inputSchema["properties"].(map[string]interface{})
+	for _, key := range shellArgKeys {
+		if _, ok := props[key]; ok {
+			return ToolClassExec
+		}
+	}
+	for _, word := range words {
+		if slices.Contains(writeToolWords, word) {
+			return ToolClassWrite
+		}
+	}
+	return ToolClassRead
+}
+
+// toolNameWords splits a tool name like createPullRequest or delete-branch
+// into lower-case words.
+func toolNameWords(name string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// ToolClasses overrides the class ClassifyTool guesses for tools. It is
+// the `tool_classes:` section of a policy file, keyed by namespaced tool
+// name; a trailing or leading * matches like other tool patterns:
+//
+//	tool_classes:
+//	  github:create_issue: read   # keep it listed in strict mode
+//	  db:vacuum: delete
+type ToolClasses map[string]string
+
+// Validate checks the class of each override.
+func (tc ToolClasses) Validate() error {
+	for pattern, class := range tc {
+		if pattern == "" {
+			return fmt.Errorf("tool_classes: empty tool name")
+		}
+		if !slices.Contains(toolClasses, class) {
+			return fmt.Errorf("tool_classes.%s: invalid class %q (want one of %s)", pattern, class, strings.Join(toolClasses, ", "))
+		}
+	}
+	return nil
+}
+
+// Classify returns the class of a tool: its override if one matches, the
+// exact name before patterns, or the class ClassifyTool guesses.
+func (tc ToolClasses) Classify(tool RegisteredTool) string {
+	if class, ok := tc[tool.Name]; ok {
+		return class
+	}
+	for _, pattern := range tc.patterns() {
+		if matchWildcard(tool.Name, pattern) {
+			return tc[pattern]
+		}
+	}
+	return ClassifyTool(tool.Name, tool.InputSchema)
+}
+
+// patterns returns the override keys, longest (most specific) first.
+func (tc ToolClasses) patterns() []string {
+	patterns := make([]string, 0, len(tc))
+	for pattern := range tc {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	return patterns
+}
+
+// HiddenInStrictMode reports whether strict mode keeps a tool out of tools/list.
+func (tc ToolClasses) HiddenInStrictMode(tool RegisteredTool) bool {
+	return tc.Classify(tool) != ToolClassRead
+}
+
+// String renders the overrides compactly for diffs, e.g. "db:vacuum=delete github:create_issue=read".
+func (tc ToolClasses) String() string {
+	parts := make([]string, 0, len(tc))
+	for pattern, class := range tc {
+		parts = append(parts, pattern+"="+class)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func encodeToolClasses(tc ToolClasses) (string, error) {
+	if len(tc) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(tc)
+	return string(data), err
+}
+
+func decodeToolClasses(raw string) (ToolClasses, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var tc ToolClasses
+	if err := json.Unmarshal([]byte(raw), &tc); err != nil {
+		return nil, fmt.Errorf("invalid stored tool classes: %w", err)
+	}
+	return tc, nil
+}
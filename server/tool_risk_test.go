@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestClassifyTool tests the class guessed from tool names and schemas
+func TestClassifyTool(t *testing.T) {
+	commandSchema := map[string]interface{}{"properties": map[string]interface{}{"command": map[string]interface{}{"type": "string"}}}
+	tests := []struct {
+		name   string
+		schema map[string]interface{}
+		class  string
+	}{
+		{"fs:read_file", nil, ToolClassRead},
+		{"github:list_issues", nil, ToolClassRead},
+		{"fs:delete_file", nil, ToolClassDelete},
+		{"db:drop_table", nil, ToolClassDelete},
+		{"shell:run_command", nil, ToolClassExec},
+		{"jupyter:executeCell", nil, ToolClassExec},
+		{"ssh:connect", commandSchema, ToolClassExec},
+		{"github:createPullRequest", nil, ToolClassWrite},
+		{"fs:write-file", nil, ToolClassWrite},
+		{"slack:send_message", nil, ToolClassWrite},
+		{"github:get_settings", nil, ToolClassRead},
+	}
+	for _, tt := range tests {
+		if got := ClassifyTool(tt.name, tt.schema); got != tt.class {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.class, got)
+		}
+	}
+
+	overrides := ToolClasses{"github:create_issue": ToolClassRead, "github:*": ToolClassWrite, "db:vacuum": ToolClassDelete}
+	for name, class := range map[string]string{
+		"github:create_issue": ToolClassRead,   // exact name wins over the pattern
+		"github:list_issues":  ToolClassWrite,  // pattern wins over the guess
+		"db:vacuum":           ToolClassDelete, // guessed read
+		"fs:read_file":        ToolClassRead,
+	} {
+		if got := overrides.Classify(RegisteredTool{Name: name}); got != class {
+			t.Errorf("%s: expected override %s, got %s", name, class, got)
+		}
+	}
+	if err := (ToolClasses{"x:y": "dangerous"}).Validate(); err == nil {
+		t.Error("expected an unknown class to be invalid")
+	}
+}
+
+// TestStrictModeHidesRiskyTools tests that strict mode drops write, exec
+// and delete tools from tools/list and treats calls to them as unknown
+func TestStrictModeHidesRiskyTools(t *testing.T) {
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "search", Transport: "mock", Fixture: writeTestFixture(t)},
+	}}
+	policyManager := NewPolicyManager(nil)
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(t.TempDir(), "armour.db")}, registry, NewStatsTracker(), policyManager, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"` + proxy.MCPProtocolVersion + `"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	listed := func() string {
+		resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 2, Method: "tools/list"}).(JSONRPCResponse)
+		data, _ := json.Marshal(resp.Result)
+		return string(data)
+	}
+
+	if got := listed(); !strings.Contains(got, "search:delete_repo") {
+		t.Fatalf("expected all tools listed in moderate mode, got %s", got)
+	}
+
+	policyManager.SetMode(StrictMode)
+	got := listed()
+	if strings.Contains(got, "search:delete_repo") || !strings.Contains(got, "search:web_search") || !strings.Contains(got, "proxy:server-status") {
+		t.Errorf("expected only read tools listed in strict mode, got %s", got)
+	}
+	resp := srv.handleRequest(ctx, JSONRPCRequest{ID: 3, Method: "tools/call", Params: json.RawMessage(`{"name":"search:delete_repo","arguments":{"repo":"armour"}}`)}).(JSONRPCResponse)
+	if resp.Error == nil || resp.Error.Message != "Tool not found" {
+		t.Errorf("expected a hidden tool to be unknown, got %+v", resp)
+	}
+
+	srv.SetToolClasses(ToolClasses{"search:delete_repo": ToolClassRead})
+	if got := listed(); !strings.Contains(got, "search:delete_repo") {
+		t.Errorf("expected an override to keep the tool listed, got %s", got)
+	}
+}
+
+// TestPolicyFileToolClassesRoundTrip tests that tool class overrides are
+// stored and exported
+func TestPolicyFileToolClassesRoundTrip(t *testing.T) {
+	rs, err := NewRulesServer(RulesServerConfig{DBPath: filepath.Join(t.TempDir(), "rules.db")})
+	if err != nil {
+		t.Fatalf("Failed to create rules server: %v", err)
+	}
+	defer rs.db.Close()
+
+	pf := &PolicyFile{Classes: ToolClasses{"github:create_issue": ToolClassRead}}
+	diff, err := ApplyPolicy(rs.store, pf)
+	if err != nil {
+		t.Fatalf("Failed to apply policy: %v", err)
+	}
+	if !strings.Contains(FormatPolicyDiff(diff), `~ tool_classes: "" -> "github:create_issue=read"`) {
+		t.Errorf("Expected tool_classes change in the diff, got %q", FormatPolicyDiff(diff))
+	}
+	sp, err := LoadStoredPolicy(rs.store)
+	if err != nil || sp.Classes.String() != pf.Classes.String() {
+		t.Errorf("Expected stored tool classes %q, got %+v (err=%v)", pf.Classes.String(), sp, err)
+	}
+	exported, err := ExportPolicy(rs.store)
+	if err != nil || exported.Classes.String() != pf.Classes.String() {
+		t.Errorf("Expected exported tool classes %q, got %+v (err=%v)", pf.Classes.String(), exported, err)
+	}
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// DefaultTraceRetention is how long persisted trace events are kept unless
+// configured otherwise.
+const DefaultTraceRetention = 7 * 24 * time.Hour
+
+const (
+	traceStoreBuffer = 1024        // events waiting to be written before new ones are dropped
+	traceStoreBatch  = 100         // events written per transaction at most
+	traceStoreFlush  = time.Second // how often waiting events are written
+)
+
+// traceEventsSchema keeps the time in Unix nanoseconds so ranges and
+// retention are plain integer comparisons.
+const traceEventsSchema = `
+	CREATE TABLE IF NOT EXISTS trace_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time_ns INTEGER NOT NULL,
+		stage TEXT,
+		server TEXT,
+		method TEXT,
+		transport TEXT,
+		detail TEXT,
+		attachment TEXT,
+		error INTEGER NOT NULL DEFAULT 0,
+		request_id TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_trace_events_time ON trace_events(time_ns);`
+
+// ensureTraceSchema creates trace_events, adding columns that tables
+// created by earlier versions lack.
+func ensureTraceSchema(db *sql.DB) error {
+	if _, err := db.Exec(traceEventsSchema); err != nil {
+		return fmt.Errorf("failed to create trace_events table: %w", err)
+	}
+	// Ignore "duplicate column" errors; the column already exists.
+	_, _ = db.Exec(`ALTER TABLE trace_events ADD COLUMN request_id TEXT`)
+	return nil
+}
+
+// TraceStore persists the events a TraceRecorder keeps, so intermittent
+// problems can be looked into after they have scrolled out of the recorder's
+// buffer. Events are written in the background in batches; if the database
+// falls behind, new events are dropped rather than slowing requests down.
+// Events older than the retention are purged hourly.
+type TraceStore struct {
+	db        *sql.DB
+	retention time.Duration
+	logger    Logger
+	events    chan proxy.TraceEvent
+	dropped   int64
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTraceStore creates the trace_events table if needed and starts writing.
+// A zero retention uses DefaultTraceRetention; a negative one keeps events.
+func NewTraceStore(db *sql.DB, retention time.Duration, logger Logger) (*TraceStore, error) {
+	if err := ensureTraceSchema(db); err != nil {
+		return nil, err
+	}
+	if retention == 0 {
+		retention = DefaultTraceRetention
+	}
+	if logger == nil {
+		logger = &noOpLogger{}
+	}
+	ts := &TraceStore{
+		db:        db,
+		retention: retention,
+		logger:    logger,
+		events:    make(chan proxy.TraceEvent, traceStoreBuffer),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go ts.run()
+	return ts, nil
+}
+
+// Record queues an event to be written. It never blocks.
+func (ts *TraceStore) Record(event proxy.TraceEvent) {
+	select {
+	case ts.events <- event:
+	default:
+		atomic.AddInt64(&ts.dropped, 1)
+	}
+}
+
+// Dropped returns how many events were dropped because writes fell behind.
+func (ts *TraceStore) Dropped() int64 {
+	return atomic.LoadInt64(&ts.dropped)
+}
+
+// Close writes the queued events and stops the store.
+func (ts *TraceStore) Close() {
+	ts.closeOnce.Do(func() {
+		close(ts.stop)
+		<-ts.done
+	})
+}
+
+func (ts *TraceStore) run() {
+	defer close(ts.done)
+	flush := time.NewTicker(traceStoreFlush)
+	defer flush.Stop()
+	purge := time.NewTicker(retentionInterval)
+	defer purge.Stop()
+	ts.purge()
+
+	var batch []proxy.TraceEvent
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := insertTraceEvents(ts.db, batch); err != nil {
+			ts.logger.Warn("failed to persist %d trace events: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case event := <-ts.events:
+			if batch = append(batch, event); len(batch) >= traceStoreBatch {
+				write()
+			}
+		case <-flush.C:
+			write()
+		case <-purge.C:
+			ts.purge()
+		case <-ts.stop:
+			for {
+				select {
+				case event := <-ts.events:
+					batch = append(batch, event)
+				default:
+					write()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (ts *TraceStore) purge() {
+	if ts.retention < 0 {
+		return
+	}
+	n, err := PurgeStoredTraces(ts.db, time.Now().Add(-ts.retention))
+	if err != nil {
+		ts.logger.Warn("failed to purge stored trace events: %v", err)
+	} else if n > 0 {
+		ts.logger.Info("purged %d stored trace events older than %s", n, ts.retention)
+	}
+}
+
+func insertTraceEvents(db *sql.DB, events []proxy.TraceEvent) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO trace_events (time_ns, stage, server, method, transport, detail, attachment, error, request_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, ev := range events {
+		if _, err := stmt.Exec(ev.Time.UnixNano(), ev.Stage, ev.Server, ev.Method, ev.Transport, ev.Detail, ev.Attachment, ev.Error, ev.RequestID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// TraceQuery selects stored trace events. Zero fields don't filter.
+type TraceQuery struct {
+	Since      time.Time
+	Until      time.Time
+	Server     string
+	RequestID  string
+	ErrorsOnly bool
+	Limit      int // the newest events are returned; 0 means 1000
+}
+
+// ParseTraceQuery reads a TraceQuery from ?since=, ?until= (dates, RFC 3339
+// times or ages such as 2h, as for purges), ?server=, ?request_id=,
+// ?errors=true and ?limit=.
+func ParseTraceQuery(values url.Values, now time.Time) (TraceQuery, error) {
+	var q TraceQuery
+	var err error
+	if v := values.Get("since"); v != "" {
+		if q.Since, err = ParsePurgeBefore(v, now); err != nil {
+			return q, err
+		}
+	}
+	if v := values.Get("until"); v != "" {
+		if q.Until, err = ParsePurgeBefore(v, now); err != nil {
+			return q, err
+		}
+	}
+	q.Server = values.Get("server")
+	q.RequestID = values.Get("request_id")
+	q.ErrorsOnly, _ = strconv.ParseBool(values.Get("errors"))
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	return q, nil
+}
+
+// QueryStoredTraces returns stored trace events matching q, oldest first.
+func QueryStoredTraces(db *sql.DB, q TraceQuery) ([]proxy.TraceEvent, error) {
+	if err := ensureTraceSchema(db); err != nil {
+		return nil, err
+	}
+	query := `SELECT time_ns, COALESCE(stage, ''), COALESCE(server, ''), COALESCE(method, ''), COALESCE(transport, ''),
+		COALESCE(detail, ''), COALESCE(attachment, ''), error, COALESCE(request_id, '') FROM trace_events WHERE 1=1`
+	var args []interface{}
+	if !q.Since.IsZero() {
+		query += ` AND time_ns >= ?`
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		query += ` AND time_ns < ?`
+		args = append(args, q.Until.UnixNano())
+	}
+	if q.Server != "" {
+		query += ` AND server = ?`
+		args = append(args, q.Server)
+	}
+	if q.RequestID != "" {
+		query += ` AND request_id = ?`
+		args = append(args, q.RequestID)
+	}
+	if q.ErrorsOnly {
+		query += ` AND error = 1`
+	}
+	if q.Limit <= 0 {
+		q.Limit = 1000
+	}
+	query += ` ORDER BY time_ns DESC, id DESC LIMIT ?`
+	args = append(args, q.Limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trace events: %w", err)
+	}
+	defer rows.Close()
+	var events []proxy.TraceEvent
+	for rows.Next() {
+		var ev proxy.TraceEvent
+		var ns int64
+		if err := rows.Scan(&ns, &ev.Stage, &ev.Server, &ev.Method, &ev.Transport, &ev.Detail, &ev.Attachment, &ev.Error, &ev.RequestID); err != nil {
+			return nil, fmt.Errorf("failed to read trace events: %w", err)
+		}
+		ev.Time = time.Unix(0, ns)
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// PurgeStoredTraces deletes stored trace events recorded before a time.
+func PurgeStoredTraces(db *sql.DB, before time.Time) (int64, error) {
+	if err := ensureTraceSchema(db); err != nil {
+		return 0, err
+	}
+	result, err := db.Exec(`DELETE FROM trace_events WHERE time_ns < ?`, before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge trace events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// configureTracing applies the configured sampling to a proxy's trace
+// recorder and, with TracePersist, returns the store its events are written
+// to. The store is nil without persistence.
+func configureTracing(config Config, db *sql.DB, tracer *proxy.TraceRecorder, logger Logger) (*TraceStore, error) {
+	sampling, err := proxy.ParseTraceSampling(config.TraceSample)
+	if err != nil {
+		return nil, err
+	}
+	tracer.SetSampling(sampling)
+	if !config.TracePersist || db == nil {
+		return nil, nil
+	}
+	store, err := NewTraceStore(db, config.TraceRetention, logger)
+	if err != nil {
+		return nil, err
+	}
+	tracer.SetSink(store.Record)
+	return store, nil
+}
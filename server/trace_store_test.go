@@ -0,0 +1,72 @@
+package server
+
+import (
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestTraceStore(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "armour.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	tracer := proxy.NewTraceRecorder(2)
+	store, err := configureTracing(Config{TraceSample: "errors", TracePersist: true}, db, tracer, nil)
+	if err != nil {
+		t.Fatalf("failed to configure tracing: %v", err)
+	}
+	tracer.Add(proxy.TraceEvent{Stage: "response", Server: "github", Detail: "completed ok"})
+	for i := 0; i < 3; i++ {
+		tracer.Add(proxy.TraceEvent{Stage: "response", Server: "github", Detail: "completed timeout", Error: true})
+	}
+	tracer.Add(proxy.TraceEvent{Stage: "translate", Server: "jira", Detail: "init failed", Error: true})
+	store.Close()
+
+	// Persisted events outlive the in-memory buffer
+	if events, _ := tracer.Usage(); events != 2 {
+		t.Errorf("expected the buffer to hold 2 events, got %d", events)
+	}
+	events, err := QueryStoredTraces(db, TraceQuery{})
+	if err != nil {
+		t.Fatalf("failed to query trace events: %v", err)
+	}
+	if len(events) != 4 || events[3].Server != "jira" || !events[0].Error {
+		t.Fatalf("expected the 4 failures oldest first, got %+v", events)
+	}
+	if events, _ := QueryStoredTraces(db, TraceQuery{Server: "github", Limit: 2}); len(events) != 2 || events[1].Server != "github" {
+		t.Errorf("expected the newest 2 github events, got %+v", events)
+	}
+
+	q, err := ParseTraceQuery(url.Values{"since": {"1h"}, "server": {"jira"}, "errors": {"true"}}, time.Now())
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+	if events, _ := QueryStoredTraces(db, q); len(events) != 1 {
+		t.Errorf("expected 1 jira event in the last hour, got %+v", events)
+	}
+	if _, err := ParseTraceQuery(url.Values{"limit": {"-1"}}, time.Now()); err == nil {
+		t.Error("expected a negative limit to be rejected")
+	}
+
+	result, err := Purge(db, tracer, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if result.Traces != 6 {
+		t.Errorf("expected 4 stored and 2 buffered events purged, got %d", result.Traces)
+	}
+	if events, _ := QueryStoredTraces(db, TraceQuery{}); len(events) != 0 {
+		t.Errorf("expected no stored events after the purge, got %+v", events)
+	}
+
+	if _, err := configureTracing(Config{TraceSample: "often"}, db, tracer, nil); err == nil {
+		t.Error("expected an invalid sampling to be rejected")
+	}
+}
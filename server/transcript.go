@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// TranscriptCall is a tool call made in a Claude Code conversation, as
+// recorded in its transcript (~/.claude/projects/<project>/<session>.jsonl).
+type TranscriptCall struct {
+	Line      int                    `json:"line"`
+	SessionID string                 `json:"session_id,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	ID        string                 `json:"id"`    // the tool_use id
+	Name      string                 `json:"name"`  // as Claude Code calls it, e.g. Bash or mcp__github__create_issue
+	Tool      string                 `json:"tool"`  // as rules name it, e.g. Bash or github:create_issue
+	Scope     string                 `json:"scope"` // native or mcp
+	Input     map[string]interface{} `json:"input"`
+}
+
+// transcriptEntry is the part of a transcript line that holds tool calls;
+// user messages, tool results and summaries have no tool_use blocks.
+type transcriptEntry struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"sessionId"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   struct {
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// ParseTranscript returns the tool calls of a Claude Code transcript, in
+// the order they were made.
+func ParseTranscript(r io.Reader) ([]TranscriptCall, error) {
+	var calls []TranscriptCall
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry transcriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if entry.Type != "assistant" {
+			continue
+		}
+		// A message's content is a string or a list of blocks
+		var blocks []struct {
+			Type  string                 `json:"type"`
+			ID    string                 `json:"id"`
+			Name  string                 `json:"name"`
+			Input map[string]interface{} `json:"input"`
+		}
+		if json.Unmarshal(entry.Message.Content, &blocks) != nil {
+			continue
+		}
+		for _, block := range blocks {
+			if block.Type != "tool_use" || block.Name == "" {
+				continue
+			}
+			tool, scope := transcriptToolName(block.Name)
+			calls = append(calls, TranscriptCall{
+				Line:      line,
+				SessionID: entry.SessionID,
+				Timestamp: entry.Timestamp,
+				ID:        block.ID,
+				Name:      block.Name,
+				Tool:      tool,
+				Scope:     scope,
+				Input:     block.Input,
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	return calls, nil
+}
+
+// LoadTranscript reads the tool calls of the Claude Code transcript at path.
+func LoadTranscript(path string) ([]TranscriptCall, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer f.Close()
+	calls, err := ParseTranscript(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return calls, nil
+}
+
+// transcriptToolName maps a Claude Code tool name to the name rules match
+// and the scope of the call: mcp__github__create_issue is github:create_issue
+// in the mcp scope. Tools of servers behind the proxy are already namespaced,
+// so mcp__armour__github:create_issue is github:create_issue too.
+func transcriptToolName(name string) (string, string) {
+	rest, ok := strings.CutPrefix(name, "mcp__")
+	if !ok {
+		return name, "native"
+	}
+	server, tool, ok := strings.Cut(rest, "__")
+	if !ok {
+		return rest, "mcp"
+	}
+	if strings.Contains(tool, ":") {
+		return tool, "mcp"
+	}
+	return server + ":" + tool, "mcp"
+}
+
+// TranscriptVerdict is the decision the current rules make on a transcript call.
+type TranscriptVerdict struct {
+	TranscriptCall
+	Decision string `json:"decision"` // allow, block or ask
+	Reason   string `json:"reason,omitempty"`
+	RuleID   int    `json:"rule_id,omitempty"`
+}
+
+// TranscriptReport summarizes how the current rules would have decided the
+// tool calls of a transcript.
+type TranscriptReport struct {
+	Calls    int                 `json:"calls"`
+	Allowed  int                 `json:"allowed"`
+	Blocked  int                 `json:"blocked"`
+	Asked    int                 `json:"asked"`
+	Verdicts []TranscriptVerdict `json:"verdicts"`
+}
+
+// AnalyzeTranscript checks each call of a transcript against the enabled
+// rules as the PreToolUse hook and the proxy would, without running it.
+// Semantic rules see the calls that came before in the same session. Rules
+// that target tags don't match MCP calls, since a transcript doesn't say
+// which tags their servers have.
+func (rs *RulesServer) AnalyzeTranscript(ctx context.Context, calls []TranscriptCall) *TranscriptReport {
+	report := &TranscriptReport{Verdicts: []TranscriptVerdict{}}
+	history := callHistoryFromEnv()
+	for _, call := range calls {
+		content, _ := json.Marshal(call.Input)
+		resp := rs.Evaluate(ctx, CheckRequest{
+			Tool:    call.Tool,
+			Method:  "tools/call",
+			Content: string(content),
+			Scope:   call.Scope,
+			History: history.Recent(call.SessionID),
+		})
+		history.Record(call.SessionID, call.Tool, call.Input)
+
+		report.Calls++
+		switch resp.Decision {
+		case "block":
+			report.Blocked++
+		case "ask":
+			report.Asked++
+		default:
+			report.Allowed++
+		}
+		report.Verdicts = append(report.Verdicts, TranscriptVerdict{
+			TranscriptCall: call,
+			Decision:       resp.Decision,
+			Reason:         resp.Reason,
+			RuleID:         resp.RuleID,
+		})
+	}
+	return report
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTranscript = `{"type":"summary","summary":"Clean up the repo"}
+{"type":"user","sessionId":"s1","message":{"role":"user","content":"remove the build output and open an issue"}}
+{"type":"assistant","sessionId":"s1","timestamp":"2025-06-01T10:00:00Z","message":{"role":"assistant","content":[{"type":"text","text":"Removing it."},{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"rm -rf build"}}]}}
+{"type":"user","sessionId":"s1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":""}]}}
+
+{"type":"assistant","sessionId":"s1","message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_2","name":"Read","input":{"file_path":"README.md"}},{"type":"tool_use","id":"toolu_3","name":"mcp__github__create_issue","input":{"title":"Build cleanup"}}]}}
+{"type":"assistant","sessionId":"s1","message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_4","name":"mcp__armour__github:delete_repo","input":{"repo":"acme/app"}}]}}
+`
+
+func TestAnalyzeTranscript(t *testing.T) {
+	calls, err := ParseTranscript(strings.NewReader(testTranscript))
+	if err != nil {
+		t.Fatalf("failed to parse transcript: %v", err)
+	}
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 calls, got %+v", calls)
+	}
+	if c := calls[0]; c.Line != 3 || c.ID != "toolu_1" || c.Tool != "Bash" || c.Scope != "native" || c.SessionID != "s1" || c.Timestamp.IsZero() {
+		t.Errorf("unexpected first call %+v", c)
+	}
+	if c := calls[2]; c.Line != 6 || c.Name != "mcp__github__create_issue" || c.Tool != "github:create_issue" || c.Scope != "mcp" {
+		t.Errorf("unexpected MCP call %+v", c)
+	}
+	if c := calls[3]; c.Tool != "github:delete_repo" || c.Scope != "mcp" {
+		t.Errorf("unexpected proxied call %+v", c)
+	}
+
+	rs, err := NewRulesServer(RulesServerConfig{DBPath: filepath.Join(t.TempDir(), "rules.db")})
+	if err != nil {
+		t.Fatalf("failed to create rules server: %v", err)
+	}
+	defer rs.db.Close()
+	for _, rule := range []Rule{
+		{Name: "no-rm-rf", Tools: "Bash", Scope: "native", Pattern: "rm -rf"},
+		{Name: "no-repo-deletes", Tools: "github:delete_repo", BlockAll: true},
+		{Name: "review-issues", Tools: "github:create_issue", Scope: "native", BlockAll: true},
+	} {
+		if err := rs.store.Create(&rule); err != nil {
+			t.Fatalf("failed to create rule: %v", err)
+		}
+	}
+
+	report := rs.AnalyzeTranscript(context.Background(), calls)
+	if report.Calls != 4 || report.Blocked != 2 || report.Allowed != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for i, want := range []string{"Blocked by rule: no-rm-rf", "", "", "Blocked by rule: no-repo-deletes"} {
+		if v := report.Verdicts[i]; v.Reason != want || (want == "") != (v.Decision == "allow") {
+			t.Errorf("call %d: expected %q, got %+v", i, want, v)
+		}
+	}
+
+	if _, err := ParseTranscript(strings.NewReader("{\"type\":\"assistant\"}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// TrustTier ranks how a backend came to be proxied. Explicitly configured
+// servers are trusted most, then servers discovered from ~/.claude.json,
+// then servers discovered from installed plugins.
+type TrustTier string
+
+const (
+	TrustExplicit TrustTier = "explicit"
+	TrustConfig   TrustTier = "config"
+	TrustPlugin   TrustTier = "plugin"
+)
+
+// TrustDecision is the default handling of tool calls for a tier.
+type TrustDecision string
+
+const (
+	TrustAllow TrustDecision = "allow"
+	TrustAsk   TrustDecision = "ask" // confirm each call with the user via elicitation
+	TrustDeny  TrustDecision = "deny"
+)
+
+// settingTrustPolicy persists the applied trust section in the rules store.
+const settingTrustPolicy = "trust_policy"
+
+// TrustPolicy maps trust tiers to default decisions, with per-server tier
+// overrides. It is the `trust:` section of a policy file:
+//
+//	trust:
+//	  plugin: ask
+//	  servers:
+//	    github: explicit
+type TrustPolicy struct {
+	Explicit TrustDecision        `yaml:"explicit,omitempty" json:"explicit,omitempty"`
+	Config   TrustDecision        `yaml:"config,omitempty" json:"config,omitempty"`
+	Plugin   TrustDecision        `yaml:"plugin,omitempty" json:"plugin,omitempty"`
+	Servers  map[string]TrustTier `yaml:"servers,omitempty" json:"servers,omitempty"`
+}
+
+// DefaultTrustPolicy allows explicit and config-discovered servers and asks
+// before calling tools on plugin-discovered ones.
+func DefaultTrustPolicy() TrustPolicy {
+	return TrustPolicy{
+		Explicit: TrustAllow,
+		Config:   TrustAllow,
+		Plugin:   TrustAsk,
+	}
+}
+
+// IsZero reports whether the policy sets nothing.
+func (tp TrustPolicy) IsZero() bool {
+	return tp.Explicit == "" && tp.Config == "" && tp.Plugin == "" && len(tp.Servers) == 0
+}
+
+// Validate checks decisions and tier overrides.
+func (tp TrustPolicy) Validate() error {
+	for tier, d := range map[TrustTier]TrustDecision{TrustExplicit: tp.Explicit, TrustConfig: tp.Config, TrustPlugin: tp.Plugin} {
+		switch d {
+		case "", TrustAllow, TrustAsk, TrustDeny:
+		default:
+			return fmt.Errorf("trust.%s: invalid decision %q", tier, d)
+		}
+	}
+	for name, tier := range tp.Servers {
+		switch tier {
+		case TrustExplicit, TrustConfig, TrustPlugin:
+		default:
+			return fmt.Errorf("trust.servers.%s: invalid tier %q", name, tier)
+		}
+	}
+	return nil
+}
+
+// TierFor returns the effective tier of a server, honouring per-server overrides.
+func (tp TrustPolicy) TierFor(server string, discovered TrustTier) TrustTier {
+	if tier, ok := tp.Servers[server]; ok {
+		return tier
+	}
+	return discovered
+}
+
+// Decision returns the decision for a tier, falling back to the defaults.
+func (tp TrustPolicy) Decision(tier TrustTier) TrustDecision {
+	defaults := DefaultTrustPolicy()
+	pick := func(d, fallback TrustDecision) TrustDecision {
+		if d == "" {
+			return fallback
+		}
+		return d
+	}
+	switch tier {
+	case TrustExplicit:
+		return pick(tp.Explicit, defaults.Explicit)
+	case TrustConfig:
+		return pick(tp.Config, defaults.Config)
+	default:
+		return pick(tp.Plugin, defaults.Plugin)
+	}
+}
+
+// String renders the policy compactly for diffs, e.g. "config=allow plugin=ask github=explicit".
+func (tp TrustPolicy) String() string {
+	if tp.IsZero() {
+		return ""
+	}
+	s := fmt.Sprintf("explicit=%s config=%s plugin=%s", tp.Decision(TrustExplicit), tp.Decision(TrustConfig), tp.Decision(TrustPlugin))
+	names := make([]string, 0, len(tp.Servers))
+	for name := range tp.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s += fmt.Sprintf(" %s=%s", name, tp.Servers[name])
+	}
+	return s
+}
+
+func encodeTrustPolicy(tp TrustPolicy) (string, error) {
+	if tp.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(tp)
+	return string(data), err
+}
+
+func decodeTrustPolicy(raw string) (TrustPolicy, error) {
+	var tp TrustPolicy
+	if raw == "" {
+		return tp, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &tp); err != nil {
+		return tp, fmt.Errorf("invalid stored trust policy: %w", err)
+	}
+	return tp, nil
+}
+
+// TrustTier reports how a backend was added: plugin discovery, ~/.claude.json
+// discovery, or explicit configuration.
+func (bm *BackendManager) TrustTier(name string) TrustTier {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	switch {
+	case bm.pluginServers[name]:
+		return TrustPlugin
+	case bm.configServers[name]:
+		return TrustConfig
+	default:
+		return TrustExplicit
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// TestTrustPolicyDecisions tests tier defaults, overrides and validation
+func TestTrustPolicyDecisions(t *testing.T) {
+	var empty TrustPolicy
+	if empty.Decision(TrustExplicit) != TrustAllow || empty.Decision(TrustConfig) != TrustAllow || empty.Decision(TrustPlugin) != TrustAsk {
+		t.Error("Expected default decisions explicit=allow config=allow plugin=ask")
+	}
+
+	tp := TrustPolicy{Config: TrustAsk, Plugin: TrustDeny, Servers: map[string]TrustTier{"github": TrustExplicit}}
+	if tp.Decision(TrustConfig) != TrustAsk || tp.Decision(TrustPlugin) != TrustDeny {
+		t.Errorf("Expected configured decisions, got config=%s plugin=%s", tp.Decision(TrustConfig), tp.Decision(TrustPlugin))
+	}
+	if tier := tp.TierFor("github", TrustPlugin); tier != TrustExplicit {
+		t.Errorf("Expected per-server override to promote github, got %s", tier)
+	}
+	if tier := tp.TierFor("other", TrustPlugin); tier != TrustPlugin {
+		t.Errorf("Expected discovered tier without override, got %s", tier)
+	}
+
+	if err := (TrustPolicy{Plugin: "maybe"}).Validate(); err == nil {
+		t.Error("Expected invalid decision to fail validation")
+	}
+	if err := (TrustPolicy{Servers: map[string]TrustTier{"x": "root"}}).Validate(); err == nil {
+		t.Error("Expected invalid tier to fail validation")
+	}
+}
+
+// TestBackendTrustTier tests that discovery records how each server was added
+func TestBackendTrustTier(t *testing.T) {
+	bm := NewBackendManager(&proxy.ServerRegistry{}, proxy.NewLogger("error"), NewToolRegistry(), nil)
+	bm.pluginServers["from-plugin"] = true
+	bm.configServers["from-config"] = true
+
+	cases := map[string]TrustTier{"from-plugin": TrustPlugin, "from-config": TrustConfig, "configured": TrustExplicit}
+	for name, want := range cases {
+		if got := bm.TrustTier(name); got != want {
+			t.Errorf("TrustTier(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+// TestPolicyFileTrustRoundTrip tests applying, loading and exporting the trust section
+func TestPolicyFileTrustRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenRulesStore(filepath.Join(dir, "rules.db"))
+	if err != nil {
+		t.Fatalf("Failed to open rules store: %v", err)
+	}
+	defer store.Close()
+
+	policyPath := filepath.Join(dir, DefaultPolicyFile)
+	content := `
+trust:
+  plugin: deny
+  servers:
+    github: explicit
+`
+	if err := os.WriteFile(policyPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+	pf, err := LoadPolicyFile(policyPath)
+	if err != nil {
+		t.Fatalf("Failed to load policy file: %v", err)
+	}
+
+	diff, err := ApplyPolicy(store, pf)
+	if err != nil {
+		t.Fatalf("Failed to apply policy: %v", err)
+	}
+	if diff.Empty() {
+		t.Error("Expected trust change to show up in the diff")
+	}
+
+	sp, err := LoadStoredPolicy(store)
+	if err != nil {
+		t.Fatalf("Failed to load stored policy: %v", err)
+	}
+	if sp.Trust.Decision(TrustPlugin) != TrustDeny || sp.Trust.TierFor("github", TrustPlugin) != TrustExplicit {
+		t.Errorf("Expected stored trust policy, got %+v", sp.Trust)
+	}
+
+	exported, err := ExportPolicy(store)
+	if err != nil {
+		t.Fatalf("Failed to export policy: %v", err)
+	}
+	if exported.Trust.String() != pf.Trust.String() {
+		t.Errorf("Expected exported trust %q, got %q", pf.Trust.String(), exported.Trust.String())
+	}
+
+	diff, err = DiffPolicy(store, pf)
+	if err != nil || !diff.Empty() {
+		t.Errorf("Expected no drift after apply, got %+v (err=%v)", diff, err)
+	}
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// settingUndoPolicy persists the applied undo section in the rules store.
+const settingUndoPolicy = "undo_policy"
+
+// Defaults for the undo section's limits.
+const (
+	defaultUndoMaxBytes = 1 << 20
+	defaultUndoKeep     = 500
+)
+
+// UndoPolicy snapshots the files a tool call is about to change, so the
+// dashboard can restore them if the agent makes a mess:
+//
+//	undo:
+//	  classes: [write, delete]
+//	  tools: ["fs:move_file"]
+//	  max_bytes: 1048576
+//	  keep: 500
+//
+// The files named by path arguments (see CheckPaths) of calls to tools of
+// the listed classes or names are read before the call is forwarded. Files
+// larger than max_bytes, directories and symlinks are skipped, and files
+// that don't exist yet are recorded as such, so restoring removes them.
+// Only the newest keep snapshots are kept. Snapshots are taken in stdio
+// mode, for local servers.
+type UndoPolicy struct {
+	Classes  []string `yaml:"classes,omitempty" json:"classes,omitempty"`
+	Tools    []string `yaml:"tools,omitempty" json:"tools,omitempty"`         // tool names; * matches a prefix or suffix
+	MaxBytes int64    `yaml:"max_bytes,omitempty" json:"max_bytes,omitempty"` // 1 MiB if 0
+	Keep     int      `yaml:"keep,omitempty" json:"keep,omitempty"`           // 500 if 0
+}
+
+// IsZero reports whether the section is empty.
+func (up UndoPolicy) IsZero() bool {
+	return len(up.Classes) == 0 && len(up.Tools) == 0 && up.MaxBytes == 0 && up.Keep == 0
+}
+
+// Validate checks the tool classes and limits.
+func (up UndoPolicy) Validate() error {
+	if up.IsZero() {
+		return nil
+	}
+	if len(up.Classes) == 0 && len(up.Tools) == 0 {
+		return fmt.Errorf("undo: classes or tools is required")
+	}
+	for _, class := range up.Classes {
+		if !slices.Contains(toolClasses, class) {
+			return fmt.Errorf("undo.classes: invalid tool class %q (want one of %s)", class, strings.Join(toolClasses, ", "))
+		}
+	}
+	for i, pattern := range up.Tools {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("undo.tools[%d]: tool name is empty", i)
+		}
+	}
+	if up.MaxBytes < 0 {
+		return fmt.Errorf("undo.max_bytes: must not be negative")
+	}
+	if up.Keep < 0 {
+		return fmt.Errorf("undo.keep: must not be negative")
+	}
+	return nil
+}
+
+// String renders the policy compactly for diffs, e.g.
+// "classes=write,delete tools=fs:move_file max_bytes=1048576".
+func (up UndoPolicy) String() string {
+	var parts []string
+	if len(up.Classes) > 0 {
+		parts = append(parts, "classes="+strings.Join(up.Classes, ","))
+	}
+	if len(up.Tools) > 0 {
+		parts = append(parts, "tools="+strings.Join(up.Tools, ","))
+	}
+	if up.MaxBytes > 0 {
+		parts = append(parts, fmt.Sprintf("max_bytes=%d", up.MaxBytes))
+	}
+	if up.Keep > 0 {
+		parts = append(parts, fmt.Sprintf("keep=%d", up.Keep))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Applies reports whether calls to a tool of the given class are snapshotted.
+func (up UndoPolicy) Applies(toolName, class string) bool {
+	if slices.Contains(up.Classes, class) {
+		return true
+	}
+	for _, pattern := range up.Tools {
+		if matchWildcard(toolName, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (up UndoPolicy) maxBytes() int64 {
+	if up.MaxBytes > 0 {
+		return up.MaxBytes
+	}
+	return defaultUndoMaxBytes
+}
+
+func (up UndoPolicy) keep() int {
+	if up.Keep > 0 {
+		return up.Keep
+	}
+	return defaultUndoKeep
+}
+
+// UndoSnapshot is the content a file had before a tool call changed it.
+type UndoSnapshot struct {
+	ID         int64      `json:"id"`
+	Timestamp  time.Time  `json:"timestamp"`
+	SessionID  string     `json:"session_id,omitempty"`
+	RequestID  string     `json:"request_id,omitempty"`
+	ToolName   string     `json:"tool_name"`
+	Path       string     `json:"path"`
+	Existed    bool       `json:"existed"` // false if the file didn't exist before the call
+	Size       int64      `json:"size"`
+	RestoredAt *time.Time `json:"restored_at,omitempty"`
+
+	content []byte
+	mode    os.FileMode
+}
+
+// SnapshotFiles reads the files named by the path arguments of a call, as
+// they are before it. Relative paths resolve against cwd or the first of
+// roots, as CheckPaths resolves them.
+// Files over maxBytes and anything but regular files are skipped.
+func SnapshotFiles(roots []string, cwd string, args map[string]interface{}, maxBytes int64) []UndoSnapshot {
+	var snapshots []UndoSnapshot
+	seen := make(map[string]bool)
+	for _, key := range sortedKeys(args) {
+		value, ok := args[key].(string)
+		if !ok || value == "" || !isPathKey(key) {
+			continue
+		}
+		path, err := filepath.Abs(resolveArgumentPath(roots, cwd, value))
+		if err != nil || seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			snapshots = append(snapshots, UndoSnapshot{Path: path})
+			continue
+		}
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxBytes {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, UndoSnapshot{
+			Path:    path,
+			Existed: true,
+			Size:    int64(len(content)),
+			content: content,
+			mode:    info.Mode().Perm(),
+		})
+	}
+	return snapshots
+}
+
+// UndoJournal keeps file snapshots in the proxy database, so a restore from
+// the dashboard works across restarts and for every proxy using the database.
+type UndoJournal struct {
+	db *sql.DB
+}
+
+// NewUndoJournal creates an undo journal backed by db.
+func NewUndoJournal(db *sql.DB) (*UndoJournal, error) {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS undo_journal (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			timestamp TIMESTAMP NOT NULL,
+			session_id TEXT,
+			request_id TEXT,
+			tool_name TEXT NOT NULL,
+			path TEXT NOT NULL,
+			existed INTEGER NOT NULL,
+			mode INTEGER NOT NULL DEFAULT 0,
+			content BLOB,
+			restored_at TIMESTAMP
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create undo_journal table: %w", err)
+	}
+	return &UndoJournal{db: db}, nil
+}
+
+// Record saves snapshots and drops all but the newest keep.
+func (j *UndoJournal) Record(snapshots []UndoSnapshot, keep int) error {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, snap := range snapshots {
+		if snap.Timestamp.IsZero() {
+			snap.Timestamp = time.Now()
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO undo_journal (timestamp, session_id, request_id, tool_name, path, existed, mode, content)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, snap.Timestamp, snap.SessionID, snap.RequestID, snap.ToolName, snap.Path, snap.Existed, int64(snap.mode), snap.content); err != nil {
+			return fmt.Errorf("failed to record undo snapshot: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM undo_journal WHERE id NOT IN (SELECT id FROM undo_journal ORDER BY id DESC LIMIT ?)
+	`, keep); err != nil {
+		return fmt.Errorf("failed to prune undo journal: %w", err)
+	}
+	return tx.Commit()
+}
+
+// List returns up to limit snapshots, newest first, without their content.
+func (j *UndoJournal) List(limit int) ([]UndoSnapshot, error) {
+	rows, err := j.db.Query(`
+		SELECT id, timestamp, COALESCE(session_id, ''), COALESCE(request_id, ''), tool_name, path, existed, LENGTH(content), restored_at
+		FROM undo_journal ORDER BY id DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list undo snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []UndoSnapshot{}
+	for rows.Next() {
+		var snap UndoSnapshot
+		var size sql.NullInt64
+		var restored sql.NullTime
+		if err := rows.Scan(&snap.ID, &snap.Timestamp, &snap.SessionID, &snap.RequestID, &snap.ToolName, &snap.Path, &snap.Existed, &size, &restored); err != nil {
+			return nil, err
+		}
+		snap.Size = size.Int64
+		if restored.Valid {
+			snap.RestoredAt = &restored.Time
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, rows.Err()
+}
+
+// Restore puts a file back the way it was before the call: the content it
+// had, or removed if the call created it. Each snapshot is restored once.
+func (j *UndoJournal) Restore(id int64) (*UndoSnapshot, error) {
+	var snap UndoSnapshot
+	var mode int64
+	var restored sql.NullTime
+	err := j.db.QueryRow(`
+		SELECT id, timestamp, tool_name, path, existed, mode, content, restored_at FROM undo_journal WHERE id = ?
+	`, id).Scan(&snap.ID, &snap.Timestamp, &snap.ToolName, &snap.Path, &snap.Existed, &mode, &snap.content, &restored)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("undo snapshot %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load undo snapshot: %w", err)
+	}
+	if restored.Valid {
+		return nil, fmt.Errorf("undo snapshot %d was already restored at %s", id, restored.Time.Local().Format(time.DateTime))
+	}
+
+	if snap.Existed {
+		if err := os.MkdirAll(filepath.Dir(snap.Path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", snap.Path, err)
+		}
+		if err := os.WriteFile(snap.Path, snap.content, os.FileMode(mode)); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", snap.Path, err)
+		}
+		// WriteFile keeps the mode of a file that is still there
+		if err := os.Chmod(snap.Path, os.FileMode(mode)); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", snap.Path, err)
+		}
+	} else if err := os.Remove(snap.Path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove %s: %w", snap.Path, err)
+	}
+
+	now := time.Now()
+	if _, err := j.db.Exec("UPDATE undo_journal SET restored_at = ? WHERE id = ?", now, id); err != nil {
+		return nil, fmt.Errorf("failed to mark undo snapshot restored: %w", err)
+	}
+	snap.RestoredAt = &now
+	snap.Size = int64(len(snap.content))
+	return &snap, nil
+}
+
+// snapshotForUndo records the files a call is about to change, if the undo
+// policy covers the tool. Failures are logged: the call goes ahead anyway.
+func (s *StdioServer) snapshotForUndo(requestID, backendID string, tool RegisteredTool, args map[string]interface{}) {
+	s.mu.RLock()
+	undo, classes := s.undoPolicy, s.toolClasses
+	s.mu.RUnlock()
+	if s.undo == nil || undo.IsZero() || !undo.Applies(tool.Name, classes.Classify(tool)) || s.backendManager.IsRemote(backendID) {
+		return
+	}
+	snapshots := SnapshotFiles(s.backendManager.AllowedRoots(backendID), s.backendManager.WorkingDir(backendID), args, undo.maxBytes())
+	if len(snapshots) == 0 {
+		return
+	}
+	now := time.Now()
+	for i := range snapshots {
+		snapshots[i].Timestamp = now
+		snapshots[i].SessionID = s.auditSession
+		snapshots[i].RequestID = requestID
+		snapshots[i].ToolName = tool.Name
+	}
+	if err := s.undo.Record(snapshots, undo.keep()); err != nil {
+		s.logger.Warn("%v", err)
+	}
+}
+
+func encodeUndoPolicy(up UndoPolicy) (string, error) {
+	if up.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(up)
+	return string(data), err
+}
+
+func decodeUndoPolicy(raw string) (UndoPolicy, error) {
+	var up UndoPolicy
+	if raw == "" {
+		return up, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &up); err != nil {
+		return up, fmt.Errorf("invalid stored undo policy: %w", err)
+	}
+	return up, nil
+}
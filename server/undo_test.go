@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+func TestUndoJournal(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.go")
+	os.WriteFile(existing, []byte("package main\n"), 0600)
+	large := filepath.Join(dir, "big.bin")
+	os.WriteFile(large, make([]byte, 100), 0644)
+
+	snapshots := SnapshotFiles([]string{dir}, "", map[string]interface{}{
+		"path":        "main.go",
+		"destination": filepath.Join(dir, "new.go"),
+		"source":      large,
+		"dir":         dir,
+		"content":     "package other\n",
+	}, 64)
+	if len(snapshots) != 2 || snapshots[0].Path != filepath.Join(dir, "new.go") || snapshots[0].Existed ||
+		snapshots[1].Path != existing || !snapshots[1].Existed || snapshots[1].Size != 13 {
+		t.Fatalf("expected the new and existing files snapshotted, got %+v", snapshots)
+	}
+	for i := range snapshots {
+		snapshots[i].ToolName = "fs:move_file"
+	}
+
+	journal, err := NewUndoJournal(openChainTestDB(t))
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+	if err := journal.Record(snapshots, 10); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+
+	// The call overwrites one file and creates the other
+	os.WriteFile(existing, []byte("package other\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0644)
+
+	listed, err := journal.List(10)
+	if err != nil || len(listed) != 2 || listed[0].Path != existing || listed[0].Size != 13 || listed[0].RestoredAt != nil {
+		t.Fatalf("expected both snapshots newest first, got %+v (err=%v)", listed, err)
+	}
+	for _, snap := range listed {
+		if _, err := journal.Restore(snap.ID); err != nil {
+			t.Fatalf("failed to restore %s: %v", snap.Path, err)
+		}
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "package main\n" {
+		t.Errorf("expected the old content back, got %q", data)
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0600 {
+		t.Errorf("expected the old mode back, got %v", info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.go")); !os.IsNotExist(err) {
+		t.Errorf("expected the created file removed, got %v", err)
+	}
+	if _, err := journal.Restore(listed[0].ID); err == nil || !strings.Contains(err.Error(), "already restored") {
+		t.Errorf("expected a second restore to fail, got %v", err)
+	}
+	if listed, _ := journal.List(10); listed[0].RestoredAt == nil {
+		t.Errorf("expected the snapshot marked restored, got %+v", listed[0])
+	}
+
+	// Only the newest snapshots are kept
+	if err := journal.Record([]UndoSnapshot{{ToolName: "fs:write_file", Path: existing}}, 2); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+	if listed, _ := journal.List(10); len(listed) != 2 || listed[0].ToolName != "fs:write_file" {
+		t.Errorf("expected the journal pruned to 2 snapshots, got %+v", listed)
+	}
+
+	for _, bad := range []UndoPolicy{
+		{MaxBytes: 10},
+		{Classes: []string{"dangerous"}},
+		{Tools: []string{" "}},
+		{Classes: []string{ToolClassWrite}, Keep: -1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestStdioSnapshotsFilesForUndo(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "notes.txt")
+	os.WriteFile(target, []byte("before\n"), 0644)
+	fixture := filepath.Join(dir, "fixture.yaml")
+	os.WriteFile(fixture, []byte(`
+tools:
+  - name: write_file
+    text: written
+  - name: read_file
+    text: read
+`), 0644)
+	registry := &proxy.ServerRegistry{Servers: []proxy.ServerEntry{
+		{Name: "fs", Transport: "mock", Fixture: fixture},
+	}}
+	srv, err := NewStdioServer(Config{LogLevel: "error", DBPath: filepath.Join(dir, "armour.db")}, registry, NewStatsTracker(), NewPolicyManager(nil), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	defer srv.Close()
+	srv.backendManager.isolated = true
+	srv.SetUndoPolicy(UndoPolicy{Classes: []string{ToolClassWrite}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.handleRequest(ctx, JSONRPCRequest{ID: 1, Method: "initialize", Params: json.RawMessage(`{"protocolVersion":"2025-06-18"}`)})
+	srv.backendManager.WaitForInitialization(ctx, 5*time.Second)
+
+	for i, name := range []string{"fs:write_file", "fs:read_file"} {
+		params, _ := json.Marshal(map[string]interface{}{"name": name, "arguments": map[string]interface{}{"path": target, "content": "after\n"}})
+		if resp := srv.handleRequest(WithRequestID(ctx, "req-"+name), JSONRPCRequest{ID: i + 2, Method: "tools/call", Params: params}).(JSONRPCResponse); resp.Error != nil {
+			t.Fatalf("expected %s to go through, got %+v", name, resp.Error)
+		}
+	}
+
+	snapshots, err := srv.GetUndoJournal().List(10)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("expected only the write snapshotted, got %+v (err=%v)", snapshots, err)
+	}
+	if snap := snapshots[0]; snap.ToolName != "fs:write_file" || snap.Path != target || snap.RequestID != "req-fs:write_file" || snap.SessionID == "" {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+}
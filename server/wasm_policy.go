@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Limits on a WebAssembly policy module: how much memory it may use and
+// how long it may take to decide on a call before the call is blocked.
+const (
+	DefaultWASMMemoryLimit = 16 << 20 // bytes
+	DefaultWASMTimeout     = 100 * time.Millisecond
+)
+
+// wasmPageSize is the size of a WebAssembly memory page.
+const wasmPageSize = 64 << 10
+
+// isWASMModule reports whether a -policy-plugins entry is a WebAssembly
+// module rather than an executable.
+func isWASMModule(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".wasm")
+}
+
+// WASMPolicyChecker runs a WebAssembly policy module in the proxy's own
+// process, sandboxed by wazero: the module gets no filesystem or network,
+// only wazero's fake clocks, a memory cap and a deadline for each call.
+//
+// The module exports alloc(len) ptr, which returns a buffer for the proxy to
+// write the JSON PolicyCall to, and check(ptr, len) u64, which decides on it
+// and returns the pointer (high 32 bits) and length (low 32 bits) of a JSON
+// PolicyVerdict. A trap, a call past the deadline, running out of memory or
+// output that isn't a verdict blocks the call; the next call gets a fresh
+// instance of the module.
+type WASMPolicyChecker struct {
+	path     string
+	timeout  time.Duration
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+
+	mu  sync.Mutex
+	mod api.Module // nil until started, and after a failed call
+}
+
+// NewWASMPolicyChecker compiles the module at path and starts it, so a
+// module that doesn't load fails when the proxy starts rather than on the
+// first call. memoryLimit is in bytes.
+func NewWASMPolicyChecker(path string, memoryLimit int, timeout time.Duration) (*WASMPolicyChecker, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WASM policy plugin: %w", err)
+	}
+
+	ctx := context.Background()
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(memoryLimit / wasmPageSize)).
+		WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	// Go and TinyGo modules import WASI; without a filesystem or network
+	// configured it only gives them arguments, environment and fake clocks
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	compiled, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, name := range []string{"alloc", "check"} {
+		if _, ok := compiled.ExportedFunctions()[name]; !ok {
+			runtime.Close(ctx)
+			return nil, fmt.Errorf("%s: module does not export %s", path, name)
+		}
+	}
+
+	c := &WASMPolicyChecker{path: path, timeout: timeout, runtime: runtime, compiled: compiled}
+	if err := c.start(); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *WASMPolicyChecker) Name() string {
+	return filepath.Base(c.path)
+}
+
+func (c *WASMPolicyChecker) Check(ctx context.Context, call PolicyCall) (PolicyVerdict, error) {
+	input, err := json.Marshal(call)
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mod == nil {
+		if err := c.start(); err != nil {
+			return PolicyVerdict{}, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	verdict, err := c.check(ctx, input)
+	if err != nil {
+		// A trapped or stopped module can be in any state
+		c.mod.Close(context.Background())
+		c.mod = nil
+		if ctx.Err() != nil {
+			return PolicyVerdict{}, fmt.Errorf("no answer within %v", c.timeout)
+		}
+		return PolicyVerdict{}, err
+	}
+	return verdict, nil
+}
+
+// start instantiates the module, running a reactor's _initialize. It may
+// take as long as an external plugin may take to answer.
+func (c *WASMPolicyChecker) start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultPluginTimeout)
+	defer cancel()
+	mod, err := c.runtime.InstantiateModule(ctx, c.compiled, wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"))
+	if err != nil {
+		return fmt.Errorf("failed to start module: %w", err)
+	}
+	c.mod = mod
+	return nil
+}
+
+// check passes input to the module's check and reads back its verdict.
+func (c *WASMPolicyChecker) check(ctx context.Context, input []byte) (PolicyVerdict, error) {
+	results, err := c.mod.ExportedFunction("alloc").Call(ctx, uint64(len(input)))
+	if err != nil {
+		return PolicyVerdict{}, fmt.Errorf("alloc failed: %w", err)
+	}
+	ptr := uint32(results[0])
+	if !c.mod.Memory().Write(ptr, input) {
+		return PolicyVerdict{}, fmt.Errorf("alloc returned a buffer outside the module's memory")
+	}
+
+	results, err = c.mod.ExportedFunction("check").Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return PolicyVerdict{}, fmt.Errorf("check failed: %w", err)
+	}
+	output, ok := c.mod.Memory().Read(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return PolicyVerdict{}, fmt.Errorf("check returned a verdict outside the module's memory")
+	}
+	var verdict PolicyVerdict
+	if err := json.Unmarshal(output, &verdict); err != nil {
+		return PolicyVerdict{}, fmt.Errorf("invalid verdict: %w", err)
+	}
+	return verdict, nil
+}
+
+// Close releases the module and its runtime.
+func (c *WASMPolicyChecker) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runtime.Close(context.Background())
+	c.mod = nil
+}
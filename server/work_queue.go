@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Default work queue limits. Agents tend to fire bursts of tool calls, so a
+// handful run at once and the rest wait their turn rather than piling up.
+const (
+	DefaultWorkers            = 16
+	DefaultSessionConcurrency = 8
+	DefaultQueueSize          = 256
+)
+
+// ErrQueueFull is returned by WorkQueue.Acquire when too many requests are waiting.
+var ErrQueueFull = errors.New("request queue is full")
+
+// WorkQueue bounds how many requests are handled at once, in total and per
+// session. Requests over the limits wait in a bounded queue; once that is
+// full they are rejected, so memory stays flat under bursty clients.
+type WorkQueue struct {
+	slots      chan struct{}
+	perSession int
+	queueSize  int
+
+	mu        sync.Mutex
+	sessions  map[string]*sessionSlots
+	queued    int
+	inFlight  int
+	peak      int
+	rejected  int64
+	completed int64
+}
+
+type sessionSlots struct {
+	slots chan struct{}
+	refs  int
+}
+
+// WorkQueueStats is a snapshot of queue depth and throughput.
+type WorkQueueStats struct {
+	Workers            int   `json:"workers"`
+	SessionConcurrency int   `json:"session_concurrency"`
+	QueueSize          int   `json:"queue_size"`
+	InFlight           int   `json:"in_flight"`
+	Queued             int   `json:"queued"`
+	PeakQueued         int   `json:"peak_queued"`
+	Rejected           int64 `json:"rejected"`
+	Completed          int64 `json:"completed"`
+}
+
+// NewWorkQueue creates a queue; zero or negative limits use the defaults.
+func NewWorkQueue(workers, perSession, queueSize int) *WorkQueue {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if perSession <= 0 {
+		perSession = DefaultSessionConcurrency
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &WorkQueue{
+		slots:      make(chan struct{}, workers),
+		perSession: perSession,
+		queueSize:  queueSize,
+		sessions:   make(map[string]*sessionSlots),
+	}
+}
+
+// Acquire waits for a free slot for session and returns the function that
+// frees it. It fails with ErrQueueFull if the queue is full, or with the
+// context's error if ctx ends while waiting.
+func (q *WorkQueue) Acquire(ctx context.Context, session string) (release func(), err error) {
+	q.mu.Lock()
+	if q.queued >= q.queueSize {
+		q.rejected++
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	q.queued++
+	if q.queued > q.peak {
+		q.peak = q.queued
+	}
+	ss := q.sessions[session]
+	if ss == nil {
+		ss = &sessionSlots{slots: make(chan struct{}, q.perSession)}
+		q.sessions[session] = ss
+	}
+	ss.refs++
+	q.mu.Unlock()
+
+	// Session first, so one busy session can't hold global slots while it waits on itself
+	select {
+	case ss.slots <- struct{}{}:
+	case <-ctx.Done():
+		q.leave(session, ss, false)
+		return nil, ctx.Err()
+	}
+	select {
+	case q.slots <- struct{}{}:
+	case <-ctx.Done():
+		<-ss.slots
+		q.leave(session, ss, false)
+		return nil, ctx.Err()
+	}
+
+	q.mu.Lock()
+	q.queued--
+	q.inFlight++
+	q.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-q.slots
+			<-ss.slots
+			q.leave(session, ss, true)
+		})
+	}, nil
+}
+
+// leave drops a request from the queue (or, if ran, from the in-flight count)
+// and forgets the session once nothing of it remains.
+func (q *WorkQueue) leave(session string, ss *sessionSlots, ran bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if ran {
+		q.inFlight--
+		q.completed++
+	} else {
+		q.queued--
+	}
+	ss.refs--
+	if ss.refs == 0 {
+		delete(q.sessions, session)
+	}
+}
+
+// Stats returns the current queue depth and counters.
+func (q *WorkQueue) Stats() WorkQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return WorkQueueStats{
+		Workers:            cap(q.slots),
+		SessionConcurrency: q.perSession,
+		QueueSize:          q.queueSize,
+		InFlight:           q.inFlight,
+		Queued:             q.queued,
+		PeakQueued:         q.peak,
+		Rejected:           q.rejected,
+		Completed:          q.completed,
+	}
+}
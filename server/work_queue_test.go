@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWorkQueueLimits(t *testing.T) {
+	q := NewWorkQueue(2, 1, 1)
+	ctx := context.Background()
+
+	releaseA, err := q.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Session a is at its limit, so its next request waits in the queue
+	acquired := make(chan func(), 1)
+	go func() {
+		release, err := q.Acquire(ctx, "a")
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		acquired <- release
+	}()
+	waitFor(t, func() bool { return q.Stats().Queued == 1 })
+
+	// The queue holds one request; the next is rejected
+	if _, err := q.Acquire(ctx, "b"); err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	releaseA()
+	releaseA() // releasing twice is harmless
+	(<-acquired)()
+
+	// Another session isn't held back by a's limit
+	releaseB, err := q.Acquire(ctx, "b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	releaseB()
+
+	stats := q.Stats()
+	if stats.Completed != 3 || stats.Rejected != 1 || stats.PeakQueued != 1 || stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if len(q.sessions) != 0 {
+		t.Errorf("expected idle sessions to be forgotten, got %d", len(q.sessions))
+	}
+}
+
+func TestWorkQueueContextCancel(t *testing.T) {
+	q := NewWorkQueue(1, 1, 4)
+	release, _ := q.Acquire(context.Background(), "a")
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Acquire(ctx, "b"); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if stats := q.Stats(); stats.Queued != 0 {
+		t.Errorf("expected cancelled request to leave the queue, got %+v", stats)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/user/mcp-go-proxy/cmd"
+)
+
+const serviceUsage = `usage: mcp-proxy service <command> [flags]
+
+COMMANDS:
+  install     Install and start the rules server and HTTP proxy as user services
+              (systemd user units on Linux, launchd agents on macOS)
+  uninstall   Stop and remove the services
+  print       Print the unit files without installing them
+
+Run 'mcp-proxy service install -help' for flags.
+`
+
+func handleServiceCommand(args []string) {
+	if len(args) < 1 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, serviceUsage)
+		os.Exit(2)
+	}
+
+	sub := args[0]
+	switch sub {
+	case "install", "uninstall", "print":
+	default:
+		fmt.Fprint(os.Stderr, serviceUsage)
+		os.Exit(2)
+	}
+
+	config := cmd.DefaultServiceConfig()
+	fs := cmd.NewFlagSet("service "+sub, "[FLAGS]", "")
+	fs.StringVar(&config.ListenAddr, "listen", "ARMOUR_LISTEN", config.ListenAddr, "HTTP proxy listen address")
+	fs.IntVar(&config.RulesPort, "rules-port", "ARMOUR_RULES_PORT", config.RulesPort, "Rules server port")
+	fs.StringVar(&config.ConfigPath, "config", "ARMOUR_CONFIG", config.ConfigPath, "Server registry for the HTTP proxy")
+	fs.StringVar(&config.RulesDB, "db", "ARMOUR_RULES_DB", config.RulesDB, "Rules database path")
+	fs.StringVar(&config.LogDir, "log-dir", "", config.LogDir, "Directory for service logs")
+	fs.StringVar(&config.LogLevel, "log-level", "ARMOUR_LOG_LEVEL", config.LogLevel, "Log level: debug, info, warn, error")
+	fs.StringVar(&config.Binary, "binary", "", config.Binary, "mcp-proxy binary the services run")
+	addJSONFlag(fs)
+	fs.MustParse(args[1:])
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		exitWithError("service "+sub, err)
+	}
+	units, err := cmd.ServiceUnits(runtime.GOOS, homeDir, config)
+	if err != nil {
+		exitWithError("service "+sub, err)
+	}
+
+	switch sub {
+	case "print":
+		if jsonOutput {
+			emitJSON("service print", map[string]interface{}{"units": units})
+			return
+		}
+		for _, u := range units {
+			fmt.Printf("# %s\n%s\n", u.Path, u.Content)
+		}
+	case "install":
+		if _, err := os.Stat(config.ConfigPath); err != nil {
+			exitWithError("service install", fmt.Errorf("server registry %s not found; run 'mcp-proxy migrate' or pass -config", config.ConfigPath))
+		}
+		if err := cmd.InstallServices(runtime.GOOS, units, config.LogDir); err != nil {
+			exitWithError("service install", err)
+		}
+		if jsonOutput {
+			emitJSON("service install", map[string]interface{}{"units": units, "log_dir": config.LogDir})
+			return
+		}
+		for _, u := range units {
+			fmt.Printf("✓ Installed %s (%s)\n", u.Name, u.Path)
+		}
+		fmt.Printf("Logs: %s\n", config.LogDir)
+	case "uninstall":
+		if err := cmd.UninstallServices(runtime.GOOS, units); err != nil {
+			exitWithError("service uninstall", err)
+		}
+		if jsonOutput {
+			emitJSON("service uninstall", map[string]interface{}{"units": units})
+			return
+		}
+		for _, u := range units {
+			fmt.Printf("✓ Removed %s\n", u.Name)
+		}
+	}
+}
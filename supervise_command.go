@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/user/mcp-go-proxy/cmd"
+	"github.com/user/mcp-go-proxy/proxy"
+)
+
+// handleSuperviseCommand runs the proxy with the arguments after -- as a
+// child process, restarting it with backoff when it crashes.
+func handleSuperviseCommand(args []string) {
+	fs := cmd.NewFlagSet("supervise", "[-crash-dir DIR] [-keep-dumps N] [-max-restarts N] [-backoff D] -- [FLAGS]", "Run the proxy and restart it when it crashes")
+	sup := &cmd.Supervisor{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr, Logger: proxy.NewLogger("info")}
+	fs.StringVar(&sup.CrashDir, "crash-dir", "ARMOUR_CRASH_DIR", cmd.DefaultCrashDir(), "Directory for crash dumps (the end of the proxy's stderr)")
+	fs.IntVar(&sup.KeepDumps, "keep-dumps", "", 10, "Number of crash dumps to keep")
+	fs.IntVar(&sup.MaxRestarts, "max-restarts", "", 5, "Give up after this many restarts in a row, each crashing within a minute (0: never)")
+	fs.DurationVar(&sup.Backoff, "backoff", "", time.Second, "Delay before a restart, doubled for each crash in a row up to 1m")
+	fs.MustParse(args)
+	if sup.Backoff <= 0 {
+		exitWithError("supervise", fmt.Errorf("-backoff must be positive"))
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		exitWithError("supervise", err)
+	}
+	sup.Command = append([]string{binary}, fs.Args()...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	code, err := sup.Run(ctx)
+	if err != nil {
+		exitWithError("supervise", err)
+	}
+	if code < 0 {
+		code = 1
+	}
+	os.Exit(code)
+}